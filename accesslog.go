@@ -24,17 +24,66 @@ type accessLog struct {
 	Duration               int    `logevent:"duration"`
 	HTTPContentType        string `logevent:"http_content_type"`
 	Status                 int    `logevent:"status"`
-	Message                string `logevent:"message,default=access"`
+	// ServerTiming contains the durations, in milliseconds, that the upstream
+	// reported for each phase of handling the request through the
+	// Server-Timing header. Comparing them with Duration separates network
+	// time from server time.
+	ServerTiming map[string]float64 `logevent:"server_timing"`
+	// The connection phase timings, in milliseconds, are only set for
+	// requests made with a context from WithTrace.
+	DNSDuration       int  `logevent:"dns_duration"`
+	ConnectDuration   int  `logevent:"connect_duration"`
+	TLSDuration       int  `logevent:"tls_duration"`
+	FirstByteDuration int  `logevent:"first_byte_duration"`
+	ConnReused        bool `logevent:"conn_reused"`
+	// The TLS fields describe the negotiated connection parameters of HTTPS
+	// requests. TLSPeerFingerprint is the SHA-256 fingerprint of the server
+	// certificate.
+	TLSVersion         string `logevent:"tls_version"`
+	TLSCipherSuite     string `logevent:"tls_cipher_suite"`
+	TLSProtocol        string `logevent:"tls_protocol"`
+	TLSPeerFingerprint string `logevent:"tls_peer_fingerprint"`
+	// Tags contains the tags added to the request context with WithTags.
+	Tags map[string]string `logevent:"tags"`
+	// Baggage contains the entries selected with AccessLogOptionBaggage.
+	Baggage map[string]string `logevent:"baggage"`
+	// Streaming is set for streaming responses. The duration of these only
+	// covers the time until the response headers were received.
+	Streaming bool `logevent:"streaming"`
+	// Annotations contains the values recorded by inner decorators with
+	// Annotate, such as the number of attempts made.
+	Annotations map[string]interface{} `logevent:"annotations"`
+	// Scrubbed is set when credentials were removed from the URL before it
+	// was logged.
+	Scrubbed bool   `logevent:"scrubbed"`
+	Message  string `logevent:"message,default=access"`
 }
 
 type loggingTransport struct {
 	Wrapped http.RoundTripper
+	baggage []string
+}
+
+// AccessLogOption is a configuration for the access log decorator.
+type AccessLogOption func(*loggingTransport) *loggingTransport
+
+// AccessLogOptionBaggage includes the W3C Baggage entries with the given keys
+// from the request context in each log.
+func AccessLogOptionBaggage(keys ...string) AccessLogOption {
+	return func(t *loggingTransport) *loggingTransport {
+		t.baggage = keys
+		return t
+	}
 }
 
 // RoundTrip writes structured access logs for the request.
 func (c *loggingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if LoggingSkipped(r.Context()) {
+		return c.Wrapped.RoundTrip(r)
+	}
 	var dstPortStr = r.URL.Port()
 	var dstPort, _ = strconv.Atoi(dstPortStr)
+	var scrubbed, found = ScrubURLCredentials(r.URL)
 	var a = accessLog{
 		Time:                   time.Now().UTC().Format(time.RFC3339Nano),
 		Host:                   r.URL.Hostname(),
@@ -45,26 +94,69 @@ func (c *loggingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
 		HTTPReferrer:           r.Referer(),
 		HTTPUserAgent:          r.UserAgent(),
 		URIPath:                r.URL.Path,
-		URIQuery:               r.URL.Query().Encode(),
+		URIQuery:               scrubbed.Query().Encode(),
 		Scheme:                 r.URL.Scheme,
+		Scrubbed:               found,
 	}
+	r = r.WithContext(WithAnnotations(r.Context()))
 	var start = time.Now()
 	var resp, e = c.Wrapped.RoundTrip(r)
 	a.Duration = int(time.Since(start).Nanoseconds() / 1e6)
 	if e == nil {
 		a.Status = resp.StatusCode
 		a.HTTPContentType = resp.Header.Get("Content-Type")
+		a.ServerTiming = serverTimingMillis(resp.Header)
+		a.Streaming = IsStreamingResponse(resp)
+		if info, ok := TLSInfoFromResponse(resp); ok {
+			a.TLSVersion = info.Version
+			a.TLSCipherSuite = info.CipherSuite
+			a.TLSProtocol = info.NegotiatedProtocol
+			if len(info.PeerCertificates) > 0 {
+				a.TLSPeerFingerprint = info.PeerCertificates[0]
+			}
+		}
 	} else {
 		a.Status = ErrorToStatusCode(e)
 	}
+	if trace := TraceFromContext(r.Context()); trace != nil {
+		var timings = trace.Timings()
+		a.DNSDuration = int(timings.DNS.Milliseconds())
+		a.ConnectDuration = int(timings.Connect.Milliseconds())
+		a.TLSDuration = int(timings.TLSHandshake.Milliseconds())
+		a.FirstByteDuration = int(timings.FirstByte.Milliseconds())
+		a.ConnReused = timings.ConnReused
+	}
+	if tags := TagsFromContext(r.Context()); len(tags) > 0 {
+		a.Tags = tags
+	}
+	if len(c.baggage) > 0 {
+		a.Baggage = filterBaggage(BaggageFromContext(r.Context()), c.baggage)
+	}
+	a.Annotations = Annotations(r.Context())
 	logevent.FromContext(r.Context()).Info(a)
 	return resp, e
 }
 
+func serverTimingMillis(header http.Header) map[string]float64 {
+	var timings = ParseServerTiming(header)
+	if len(timings) < 1 {
+		return nil
+	}
+	var millis = make(map[string]float64, len(timings))
+	for _, timing := range timings {
+		millis[timing.Name] = float64(timing.Duration) / float64(time.Millisecond)
+	}
+	return millis
+}
+
 // NewAccessLog configures a RoundTripper decorator that generates log
 // details for each request.
-func NewAccessLog() func(http.RoundTripper) http.RoundTripper {
+func NewAccessLog(opts ...AccessLogOption) func(http.RoundTripper) http.RoundTripper {
 	return func(next http.RoundTripper) http.RoundTripper {
-		return &loggingTransport{Wrapped: next}
+		var t = &loggingTransport{Wrapped: next}
+		for _, opt := range opts {
+			t = opt(t)
+		}
+		return t
 	}
 }
@@ -61,3 +61,79 @@ func TestAccessLogError(t *testing.T) {
 	wrapped := NewAccessLog()(rt)
 	_, _ = wrapped.RoundTrip(req)
 }
+
+func TestAccessLogServerTiming(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	logger := NewMockLogger(ctrl)
+	rt := NewMockRoundTripper(ctrl)
+
+	req := httptest.NewRequest(http.MethodGet, "https://localhost/", http.NoBody)
+	req = req.WithContext(logevent.NewContext(req.Context(), logger))
+	logger.EXPECT().Info(gomock.Any()).Do(func(event interface{}) {
+		assert.Equal(t, map[string]float64{"db": 53, "app": 47.5}, event.(accessLog).ServerTiming)
+	})
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Server-Timing": []string{"db;dur=53, app;dur=47.5"}},
+		Body:       http.NoBody,
+	}
+	rt.EXPECT().RoundTrip(gomock.Any()).Return(resp, nil)
+	wrapped := NewAccessLog()(rt)
+	_, _ = wrapped.RoundTrip(req)
+}
+
+func TestAccessLogBaggage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	logger := NewMockLogger(ctrl)
+	rt := NewMockRoundTripper(ctrl)
+
+	req := httptest.NewRequest(http.MethodGet, "https://localhost/", http.NoBody)
+	var ctx = WithBaggage(logevent.NewContext(req.Context(), logger), map[string]string{"tenant": "acme", "secret": "value"})
+	req = req.WithContext(ctx)
+	logger.EXPECT().Info(gomock.Any()).Do(func(event interface{}) {
+		assert.Equal(t, map[string]string{"tenant": "acme"}, event.(accessLog).Baggage)
+	})
+	rt.EXPECT().RoundTrip(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: http.NoBody}, nil)
+	wrapped := NewAccessLog(AccessLogOptionBaggage("tenant"))(rt)
+	_, _ = wrapped.RoundTrip(req)
+}
+
+func TestAccessLogTags(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	logger := NewMockLogger(ctrl)
+	rt := NewMockRoundTripper(ctrl)
+
+	req := httptest.NewRequest(http.MethodGet, "https://localhost/users/123", http.NoBody)
+	var ctx = WithTags(logevent.NewContext(req.Context(), logger), map[string]string{TagOperation: "get-user"})
+	req = req.WithContext(ctx)
+	logger.EXPECT().Info(gomock.Any()).Do(func(event interface{}) {
+		assert.Equal(t, map[string]string{TagOperation: "get-user"}, event.(accessLog).Tags)
+	})
+	rt.EXPECT().RoundTrip(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: http.NoBody}, nil)
+	wrapped := NewAccessLog()(rt)
+	_, _ = wrapped.RoundTrip(req)
+}
+
+func TestAccessLogScrubsCredentials(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	logger := NewMockLogger(ctrl)
+	rt := NewMockRoundTripper(ctrl)
+
+	req := httptest.NewRequest(http.MethodGet, "https://localhost/download?X-Amz-Signature=abc&page=2&api_key=secret", http.NoBody)
+	req = req.WithContext(logevent.NewContext(req.Context(), logger))
+	logger.EXPECT().Info(gomock.Any()).Do(func(event interface{}) {
+		assert.Equal(t, "X-Amz-Signature=%5BREDACTED%5D&api_key=%5BREDACTED%5D&page=2", event.(accessLog).URIQuery)
+		assert.True(t, event.(accessLog).Scrubbed)
+	})
+	rt.EXPECT().RoundTrip(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: http.NoBody}, nil)
+	wrapped := NewAccessLog()(rt)
+	_, _ = wrapped.RoundTrip(req)
+}
@@ -0,0 +1,111 @@
+package transport
+
+import (
+	"context"
+	"sync"
+)
+
+// AnnotationKey identifies an annotation and the type of its value.
+type AnnotationKey[T any] struct {
+	name string
+}
+
+// NewAnnotationKey creates a key for annotations with the given name. Keys
+// with the same name refer to the same annotation and must use the same type.
+func NewAnnotationKey[T any](name string) AnnotationKey[T] {
+	return AnnotationKey[T]{name: name}
+}
+
+// Name of the annotation as it appears in the result of Annotations.
+func (k AnnotationKey[T]) Name() string {
+	return k.name
+}
+
+var (
+	// AnnotationAttempt is the one-based index of the Retry attempt being
+	// made. It is set before each attempt is sent.
+	AnnotationAttempt = NewAnnotationKey[int]("attempt")
+	// AnnotationHedgeIndex is the one-based index of the hedged request that
+	// produced the response returned by the Hedger.
+	AnnotationHedgeIndex = NewAnnotationKey[int]("hedge_index")
+	// AnnotationBreakerState is the state of the circuit breaker that handled
+	// the request, such as "closed" or "open".
+	AnnotationBreakerState = NewAnnotationKey[string]("breaker_state")
+	// AnnotationUpstreamErrorCode is the code that an upstream gave for a
+	// failed request, as parsed by the UpstreamErrorParsing decorator.
+	AnnotationUpstreamErrorCode = NewAnnotationKey[string]("upstream_error_code")
+	// AnnotationContentEncoding is the content coding that a server chose for
+	// a response, such as "gzip" or "identity", as recorded by the
+	// AcceptEncoding decorator.
+	AnnotationContentEncoding = NewAnnotationKey[string]("content_encoding")
+)
+
+type annotationsKey struct{}
+
+// annotationBag is shared by every decorator handling a request so that
+// values set by inner decorators are visible to the outer ones that installed
+// it. The Hedger annotates from multiple goroutines so access is locked.
+type annotationBag struct {
+	lock   sync.Mutex
+	values map[string]interface{}
+}
+
+// WithAnnotations installs an empty annotation bag in the context for
+// decorators to record values in with Annotate. Decorators that report on
+// requests, such as the access log, call it before handing the request to the
+// transport they wrap and read the result with Annotations once it returns.
+// The context is returned unchanged if it already has a bag so that every
+// decorator in a chain shares the same one.
+func WithAnnotations(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(annotationsKey{}).(*annotationBag); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, annotationsKey{}, &annotationBag{values: make(map[string]interface{})})
+}
+
+// Annotate records a value in the annotation bag of the context. It does
+// nothing if WithAnnotations has not been applied to the context.
+func Annotate[T any](ctx context.Context, key AnnotationKey[T], value T) {
+	var bag, ok = ctx.Value(annotationsKey{}).(*annotationBag)
+	if !ok {
+		return
+	}
+	bag.lock.Lock()
+	defer bag.lock.Unlock()
+	bag.values[key.name] = value
+}
+
+// Annotation returns a single value from the annotation bag of the context.
+func Annotation[T any](ctx context.Context, key AnnotationKey[T]) (T, bool) {
+	var zero T
+	var bag, ok = ctx.Value(annotationsKey{}).(*annotationBag)
+	if !ok {
+		return zero, false
+	}
+	bag.lock.Lock()
+	defer bag.lock.Unlock()
+	var value, found = bag.values[key.name].(T)
+	if !found {
+		return zero, false
+	}
+	return value, true
+}
+
+// Annotations returns a copy of every value in the annotation bag of the
+// context, keyed by annotation name. The result is nil if there are none.
+func Annotations(ctx context.Context) map[string]interface{} {
+	var bag, ok = ctx.Value(annotationsKey{}).(*annotationBag)
+	if !ok {
+		return nil
+	}
+	bag.lock.Lock()
+	defer bag.lock.Unlock()
+	if len(bag.values) < 1 {
+		return nil
+	}
+	var result = make(map[string]interface{}, len(bag.values))
+	for k, v := range bag.values {
+		result[k] = v
+	}
+	return result
+}
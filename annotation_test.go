@@ -0,0 +1,58 @@
+package transport
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/asecurityteam/logevent/v2"
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnnotations(t *testing.T) {
+	var ctx = context.Background()
+	Annotate(ctx, AnnotationAttempt, 1)
+	assert.Nil(t, Annotations(ctx), "annotated a context without a bag")
+
+	ctx = WithAnnotations(ctx)
+	assert.Nil(t, Annotations(ctx))
+	var inner = WithAnnotations(context.WithValue(ctx, tagsKey{}, nil))
+	Annotate(inner, AnnotationAttempt, 2)
+	Annotate(inner, NewAnnotationKey[string]("custom"), "value")
+
+	var attempt, ok = Annotation(ctx, AnnotationAttempt)
+	require.True(t, ok, "inner decorators did not share the bag")
+	assert.Equal(t, 2, attempt)
+	var _, found = Annotation(ctx, AnnotationBreakerState)
+	assert.False(t, found)
+	assert.Equal(t, map[string]interface{}{"attempt": 2, "custom": "value"}, Annotations(ctx))
+	assert.Equal(t, "hedge_index", AnnotationHedgeIndex.Name())
+}
+
+func TestAccessLogAnnotations(t *testing.T) {
+	var ctrl = gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var logger = NewMockLogger(ctrl)
+	var wrapped = NewMockRoundTripper(ctrl)
+	var rt = Chain{
+		NewAccessLog(),
+		NewRetrier(NewFixedBackoffPolicy(time.Millisecond), NewStatusCodeRetryPolicy(http.StatusInternalServerError)),
+		NewHedger(NewFixedBackoffPolicy(time.Hour)),
+	}.Apply(wrapped)
+	wrapped.EXPECT().RoundTrip(gomock.Any()).Return(&http.Response{StatusCode: http.StatusInternalServerError, Body: http.NoBody}, nil)
+	wrapped.EXPECT().RoundTrip(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil)
+	logger.EXPECT().Info(gomock.Any()).Do(func(event interface{}) {
+		assert.Equal(t, map[string]interface{}{"attempt": 2, "hedge_index": 1}, event.(accessLog).Annotations)
+	})
+
+	var req = httptest.NewRequest(http.MethodGet, "https://localhost/", http.NoBody)
+	req = req.WithContext(logevent.NewContext(req.Context(), logger))
+	var resp, e = rt.RoundTrip(req)
+	require.NoError(t, e)
+	_ = resp.Body.Close()
+}
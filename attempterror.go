@@ -0,0 +1,46 @@
+package transport
+
+import "time"
+
+// AttemptError wraps every error returned by the Retry, RetryAfter, and
+// Hedger decorators with details about the attempts that were made. The
+// original error remains available through errors.Is and errors.As.
+type AttemptError struct {
+	err      error
+	attempts int
+	hedges   int
+	elapsed  time.Duration
+}
+
+func newAttemptError(err error, attempts int, hedges int, elapsed time.Duration) error {
+	if err == nil {
+		return nil
+	}
+	return &AttemptError{err: err, attempts: attempts, hedges: hedges, elapsed: elapsed}
+}
+
+// Error returns the message of the wrapped error.
+func (e *AttemptError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the wrapped error.
+func (e *AttemptError) Unwrap() error {
+	return e.err
+}
+
+// Attempts returns the number of requests sent to the wrapped transport.
+func (e *AttemptError) Attempts() int {
+	return e.attempts
+}
+
+// Hedges returns the number of additional requests launched by a Hedger
+// while waiting on the first one.
+func (e *AttemptError) Hedges() int {
+	return e.hedges
+}
+
+// Elapsed returns the total time spent before the error was returned.
+func (e *AttemptError) Elapsed() time.Duration {
+	return e.elapsed
+}
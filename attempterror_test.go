@@ -0,0 +1,88 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttemptErrorNil(t *testing.T) {
+	assert.Nil(t, newAttemptError(nil, 1, 0, time.Second))
+}
+
+func TestAttemptErrorRetry(t *testing.T) {
+	t.Parallel()
+
+	var ctrl = gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var wrapped = NewMockRoundTripper(ctrl)
+	var rt = NewRetrier(
+		NewFixedBackoffPolicy(0),
+		NewLimitedRetryPolicy(2, NewTimeoutRetryPolicy(time.Minute)),
+	)(wrapped)
+	wrapped.EXPECT().RoundTrip(gomock.Any()).Return(nil, context.DeadlineExceeded).Times(3)
+
+	var req, _ = http.NewRequest(http.MethodGet, "/", nil)
+	var _, e = rt.RoundTrip(req)
+	assert.ErrorIs(t, e, context.DeadlineExceeded)
+	assert.Equal(t, context.DeadlineExceeded.Error(), e.Error())
+	var attemptErr *AttemptError
+	require.True(t, errors.As(e, &attemptErr))
+	assert.Equal(t, 3, attemptErr.Attempts())
+	assert.Equal(t, 0, attemptErr.Hedges())
+	assert.True(t, attemptErr.Elapsed() >= 0)
+}
+
+func TestAttemptErrorRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	var ctrl = gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var wrapped = NewMockRoundTripper(ctrl)
+	var rt = NewRetryAfter()(wrapped)
+	var boom = errors.New("boom")
+	wrapped.EXPECT().RoundTrip(gomock.Any()).Return(nil, boom)
+
+	var req, _ = http.NewRequest(http.MethodGet, "/", nil)
+	var _, e = rt.RoundTrip(req)
+	assert.ErrorIs(t, e, boom)
+	var attemptErr *AttemptError
+	require.True(t, errors.As(e, &attemptErr))
+	assert.Equal(t, 1, attemptErr.Attempts())
+}
+
+func TestAttemptErrorHedger(t *testing.T) {
+	t.Parallel()
+
+	var ctrl = gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var wrapped = NewMockRoundTripper(ctrl)
+	var rt = NewHedger(NewFixedBackoffPolicy(time.Millisecond))(wrapped)
+	var boom = errors.New("boom")
+	var calls atomic.Int32
+	wrapped.EXPECT().RoundTrip(gomock.Any()).DoAndReturn(func(r *http.Request) (*http.Response, error) {
+		if calls.Add(1) < 2 {
+			<-r.Context().Done()
+			return nil, r.Context().Err()
+		}
+		return nil, boom
+	}).MinTimes(2)
+
+	var req, _ = http.NewRequest(http.MethodGet, "/", nil)
+	var _, e = rt.RoundTrip(req)
+	assert.ErrorIs(t, e, boom)
+	var attemptErr *AttemptError
+	require.True(t, errors.As(e, &attemptErr))
+	assert.True(t, attemptErr.Attempts() >= 2)
+	assert.Equal(t, attemptErr.Attempts()-1, attemptErr.Hedges())
+}
@@ -0,0 +1,431 @@
+package transport
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	awsIMDSEndpoint = "http://169.254.169.254"
+	awsSTSEndpoint  = "https://sts.amazonaws.com"
+	// awsIMDSTokenTimeout bounds the first request to the instance metadata
+	// service.
+	awsIMDSTokenTimeout = 2 * time.Second
+	// awsCredentialsEarlyExpiry is how long before their expiry cached
+	// credentials are replaced so that they do not expire in flight.
+	awsCredentialsEarlyExpiry = 5 * time.Minute
+)
+
+// AWSCredentials are the keys used to sign requests with SigV4. Expires is
+// zero for long-term credentials that do not expire.
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Expires         time.Time
+}
+
+// AWSCredentialsProvider retrieves AWS credentials.
+type AWSCredentialsProvider interface {
+	AWSCredentials(ctx context.Context) (AWSCredentials, error)
+}
+
+// AWSCredentialsProviderFunc converts a function to an
+// AWSCredentialsProvider.
+type AWSCredentialsProviderFunc func(ctx context.Context) (AWSCredentials, error)
+
+// AWSCredentials calls the wrapped function.
+func (f AWSCredentialsProviderFunc) AWSCredentials(ctx context.Context) (AWSCredentials, error) {
+	return f(ctx)
+}
+
+// ErrNoAWSCredentials is returned by providers that find no credentials in
+// their source so that a chain moves on to the next provider.
+var ErrNoAWSCredentials = errors.New("transport: no AWS credentials found")
+
+// NewStaticAWSCredentials creates a provider that always returns the given
+// credentials.
+func NewStaticAWSCredentials(credentials AWSCredentials) AWSCredentialsProvider {
+	return AWSCredentialsProviderFunc(func(context.Context) (AWSCredentials, error) {
+		return credentials, nil
+	})
+}
+
+// NewEnvAWSCredentials creates a provider that reads AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY, and AWS_SESSION_TOKEN from the environment.
+func NewEnvAWSCredentials() AWSCredentialsProvider {
+	return AWSCredentialsProviderFunc(func(context.Context) (AWSCredentials, error) {
+		var credentials = AWSCredentials{
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}
+		if credentials.AccessKeyID == "" || credentials.SecretAccessKey == "" {
+			return AWSCredentials{}, ErrNoAWSCredentials
+		}
+		return credentials, nil
+	})
+}
+
+// NewSharedAWSCredentials creates a provider that reads the profile named by
+// AWS_PROFILE, or the default profile, from the shared credentials file and
+// then the shared config file. The files are AWS_SHARED_CREDENTIALS_FILE and
+// AWS_CONFIG_FILE, or ~/.aws/credentials and ~/.aws/config. The files are
+// read on every call so that rotated keys are picked up.
+func NewSharedAWSCredentials() AWSCredentialsProvider {
+	return AWSCredentialsProviderFunc(func(context.Context) (AWSCredentials, error) {
+		var profile = os.Getenv("AWS_PROFILE")
+		if profile == "" {
+			profile = "default"
+		}
+		var home, _ = os.UserHomeDir()
+		var credentialsFile = os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+		if credentialsFile == "" {
+			credentialsFile = filepath.Join(home, ".aws", "credentials")
+		}
+		var configFile = os.Getenv("AWS_CONFIG_FILE")
+		if configFile == "" {
+			configFile = filepath.Join(home, ".aws", "config")
+		}
+		var configSection = "profile " + profile
+		if profile == "default" {
+			configSection = profile
+		}
+		for _, source := range []struct{ file, section string }{
+			{credentialsFile, profile},
+			{configFile, configSection},
+		} {
+			var values, e = readINISection(source.file, source.section)
+			if errors.Is(e, os.ErrNotExist) {
+				continue
+			}
+			if e != nil {
+				return AWSCredentials{}, e
+			}
+			if values["aws_access_key_id"] != "" && values["aws_secret_access_key"] != "" {
+				return AWSCredentials{
+					AccessKeyID:     values["aws_access_key_id"],
+					SecretAccessKey: values["aws_secret_access_key"],
+					SessionToken:    values["aws_session_token"],
+				}, nil
+			}
+		}
+		return AWSCredentials{}, ErrNoAWSCredentials
+	})
+}
+
+// readINISection returns the keys of a section of an INI file.
+func readINISection(file string, section string) (map[string]string, error) {
+	var f, e = os.Open(file)
+	if e != nil {
+		return nil, e
+	}
+	defer f.Close()
+	var values = make(map[string]string)
+	var current string
+	var scanner = bufio.NewScanner(f)
+	for scanner.Scan() {
+		var line = strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			current = strings.TrimSpace(line[1 : len(line)-1])
+		case current == section:
+			if key, value, ok := strings.Cut(line, "="); ok {
+				values[strings.ToLower(strings.TrimSpace(key))] = strings.TrimSpace(value)
+			}
+		}
+	}
+	return values, scanner.Err()
+}
+
+// AWSCredentialsOption is a configuration for the providers that request
+// credentials from AWS.
+type AWSCredentialsOption func(*awsCredentialsClient) *awsCredentialsClient
+
+type awsCredentialsClient struct {
+	endpoint string
+	client   *http.Client
+}
+
+// AWSCredentialsOptionEndpoint replaces the address of the instance metadata
+// service or of STS.
+func AWSCredentialsOptionEndpoint(endpoint string) AWSCredentialsOption {
+	return func(c *awsCredentialsClient) *awsCredentialsClient {
+		c.endpoint = strings.TrimSuffix(endpoint, "/")
+		return c
+	}
+}
+
+// AWSCredentialsOptionClient sets the client used to request credentials.
+// The default is a client created by NewClient.
+func AWSCredentialsOptionClient(client *http.Client) AWSCredentialsOption {
+	return func(c *awsCredentialsClient) *awsCredentialsClient {
+		c.client = client
+		return c
+	}
+}
+
+func newAWSCredentialsClient(endpoint string, opts ...AWSCredentialsOption) *awsCredentialsClient {
+	var c = &awsCredentialsClient{endpoint: endpoint}
+	for _, opt := range opts {
+		c = opt(c)
+	}
+	if c.client == nil {
+		c.client = NewClient()
+	}
+	return c
+}
+
+func (c *awsCredentialsClient) do(req *http.Request) ([]byte, error) {
+	var resp, e = c.client.Do(req)
+	if e != nil {
+		return nil, e
+	}
+	defer resp.Body.Close()
+	var body []byte
+	if body, e = io.ReadAll(io.LimitReader(resp.Body, 1<<20)); e != nil {
+		return nil, e
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("transport: AWS credentials request failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
+
+// NewIMDSAWSCredentials creates a provider that requests the credentials of
+// the instance profile role from the EC2 instance metadata service using
+// IMDSv2 session tokens. AWS_EC2_METADATA_SERVICE_ENDPOINT replaces the
+// address of the service and AWS_EC2_METADATA_DISABLED=true disables the
+// provider.
+func NewIMDSAWSCredentials(opts ...AWSCredentialsOption) AWSCredentialsProvider {
+	var endpoint = awsIMDSEndpoint
+	if configured := os.Getenv("AWS_EC2_METADATA_SERVICE_ENDPOINT"); configured != "" {
+		endpoint = strings.TrimSuffix(configured, "/")
+	}
+	var c = newAWSCredentialsClient(endpoint, opts...)
+	return AWSCredentialsProviderFunc(func(ctx context.Context) (AWSCredentials, error) {
+		if strings.EqualFold(os.Getenv("AWS_EC2_METADATA_DISABLED"), "true") {
+			return AWSCredentials{}, ErrNoAWSCredentials
+		}
+		// Outside of EC2 the service is unreachable, which should not hold up
+		// the request for long.
+		var tokenCtx, cancel = context.WithTimeout(ctx, awsIMDSTokenTimeout)
+		defer cancel()
+		var req, e = http.NewRequestWithContext(tokenCtx, http.MethodPut, c.endpoint+"/latest/api/token", nil)
+		if e != nil {
+			return AWSCredentials{}, e
+		}
+		req.Header.Set("X-Aws-Ec2-Metadata-Token-Ttl-Seconds", "21600")
+		var token []byte
+		if token, e = c.do(req); e != nil {
+			return AWSCredentials{}, fmt.Errorf("%w: instance metadata service: %v", ErrNoAWSCredentials, e)
+		}
+		var get = func(path string) ([]byte, error) {
+			var req, e = http.NewRequestWithContext(ctx, http.MethodGet, c.endpoint+path, nil)
+			if e != nil {
+				return nil, e
+			}
+			req.Header.Set("X-Aws-Ec2-Metadata-Token", string(token))
+			return c.do(req)
+		}
+		var roles []byte
+		if roles, e = get("/latest/meta-data/iam/security-credentials/"); e != nil {
+			return AWSCredentials{}, e
+		}
+		var role, _, _ = strings.Cut(strings.TrimSpace(string(roles)), "\n")
+		if role == "" {
+			return AWSCredentials{}, ErrNoAWSCredentials
+		}
+		var body []byte
+		if body, e = get("/latest/meta-data/iam/security-credentials/" + url.PathEscape(role)); e != nil {
+			return AWSCredentials{}, e
+		}
+		var document struct {
+			AccessKeyID     string `json:"AccessKeyId"`
+			SecretAccessKey string
+			Token           string
+			Expiration      time.Time
+		}
+		if e = json.Unmarshal(body, &document); e != nil {
+			return AWSCredentials{}, e
+		}
+		return AWSCredentials{
+			AccessKeyID:     document.AccessKeyID,
+			SecretAccessKey: document.SecretAccessKey,
+			SessionToken:    document.Token,
+			Expires:         document.Expiration,
+		}, nil
+	})
+}
+
+// NewWebIdentityAWSCredentials creates a provider that exchanges the token in
+// AWS_WEB_IDENTITY_TOKEN_FILE for credentials of AWS_ROLE_ARN using
+// AssumeRoleWithWebIdentity, as set up by IAM roles for service accounts on
+// EKS. AWS_ROLE_SESSION_NAME names the session. The token file is read on
+// every call because it is rotated. STS is reached in AWS_REGION when it is
+// set.
+func NewWebIdentityAWSCredentials(opts ...AWSCredentialsOption) AWSCredentialsProvider {
+	var endpoint = awsSTSEndpoint
+	if region := os.Getenv("AWS_REGION"); region != "" {
+		endpoint = "https://sts." + region + ".amazonaws.com"
+	}
+	var c = newAWSCredentialsClient(endpoint, opts...)
+	return AWSCredentialsProviderFunc(func(ctx context.Context) (AWSCredentials, error) {
+		var tokenFile, role = os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE"), os.Getenv("AWS_ROLE_ARN")
+		if tokenFile == "" || role == "" {
+			return AWSCredentials{}, ErrNoAWSCredentials
+		}
+		var token, e = os.ReadFile(tokenFile)
+		if e != nil {
+			return AWSCredentials{}, e
+		}
+		var session = os.Getenv("AWS_ROLE_SESSION_NAME")
+		if session == "" {
+			session = fmt.Sprintf("transport-%d", time.Now().UnixNano())
+		}
+		var form = url.Values{
+			"Action":           []string{"AssumeRoleWithWebIdentity"},
+			"Version":          []string{"2011-06-15"},
+			"RoleArn":          []string{role},
+			"RoleSessionName":  []string{session},
+			"WebIdentityToken": []string{strings.TrimSpace(string(token))},
+		}
+		var req *http.Request
+		if req, e = http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint+"/", strings.NewReader(form.Encode())); e != nil {
+			return AWSCredentials{}, e
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		var body []byte
+		if body, e = c.do(req); e != nil {
+			return AWSCredentials{}, e
+		}
+		var document struct {
+			Credentials struct {
+				AccessKeyID     string `xml:"AccessKeyId"`
+				SecretAccessKey string
+				SessionToken    string
+				Expiration      time.Time
+			} `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+		}
+		if e = xml.Unmarshal(body, &document); e != nil {
+			return AWSCredentials{}, e
+		}
+		return AWSCredentials{
+			AccessKeyID:     document.Credentials.AccessKeyID,
+			SecretAccessKey: document.Credentials.SecretAccessKey,
+			SessionToken:    document.Credentials.SessionToken,
+			Expires:         document.Credentials.Expiration,
+		}, nil
+	})
+}
+
+// NewAWSCredentialsChain creates a provider that returns the credentials of
+// the first provider that finds any. Providers that return
+// ErrNoAWSCredentials are skipped and any other error stops the chain.
+func NewAWSCredentialsChain(providers ...AWSCredentialsProvider) AWSCredentialsProvider {
+	return AWSCredentialsProviderFunc(func(ctx context.Context) (AWSCredentials, error) {
+		for _, provider := range providers {
+			var credentials, e = provider.AWSCredentials(ctx)
+			if errors.Is(e, ErrNoAWSCredentials) {
+				continue
+			}
+			return credentials, e
+		}
+		return AWSCredentials{}, ErrNoAWSCredentials
+	})
+}
+
+// NewDefaultAWSCredentials creates the standard chain of the AWS SDKs, which
+// finds credentials in the environment, from a web identity token on EKS, in
+// the shared credentials and config files, and from the instance metadata
+// service on EC2, in that order. The credentials are cached and refreshed
+// before they expire. The options, such as AWSCredentialsOptionClient, apply
+// to the web identity and instance metadata providers.
+func NewDefaultAWSCredentials(opts ...AWSCredentialsOption) *CachedAWSCredentials {
+	return NewCachedAWSCredentials(NewAWSCredentialsChain(
+		NewEnvAWSCredentials(),
+		NewWebIdentityAWSCredentials(opts...),
+		NewSharedAWSCredentials(),
+		NewIMDSAWSCredentials(opts...),
+	))
+}
+
+// CachedAWSCredentials is an AWSCredentialsProvider that keeps the
+// credentials of another provider until shortly before they expire.
+// Credentials without an expiry are kept for the TTL.
+type CachedAWSCredentials struct {
+	provider    AWSCredentialsProvider
+	ttl         time.Duration
+	clock       Clock
+	lock        sync.Mutex
+	credentials AWSCredentials
+	refresh     time.Time
+}
+
+// CachedAWSCredentialsOption is a configuration for the
+// CachedAWSCredentials.
+type CachedAWSCredentialsOption func(*CachedAWSCredentials) *CachedAWSCredentials
+
+// CachedAWSCredentialsOptionTTL sets how long credentials that do not expire
+// are used before they are retrieved again so that rotated keys in the
+// environment or shared files are picked up. The default is 15 minutes.
+func CachedAWSCredentialsOptionTTL(ttl time.Duration) CachedAWSCredentialsOption {
+	return func(c *CachedAWSCredentials) *CachedAWSCredentials {
+		c.ttl = ttl
+		return c
+	}
+}
+
+// CachedAWSCredentialsOptionClock configures the Clock used to expire
+// credentials.
+func CachedAWSCredentialsOptionClock(clock Clock) CachedAWSCredentialsOption {
+	return func(c *CachedAWSCredentials) *CachedAWSCredentials {
+		c.clock = clock
+		return c
+	}
+}
+
+// NewCachedAWSCredentials creates an empty CachedAWSCredentials over the
+// provider.
+func NewCachedAWSCredentials(provider AWSCredentialsProvider, opts ...CachedAWSCredentialsOption) *CachedAWSCredentials {
+	var c = &CachedAWSCredentials{provider: provider, ttl: 15 * time.Minute, clock: NewSystemClock()}
+	for _, opt := range opts {
+		c = opt(c)
+	}
+	return c
+}
+
+// AWSCredentials returns the cached credentials or retrieves new ones. The
+// lock is held while retrieving so that concurrent requests share a single
+// refresh.
+func (c *CachedAWSCredentials) AWSCredentials(ctx context.Context) (AWSCredentials, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	var now = c.clock.Now()
+	if c.credentials.AccessKeyID != "" && now.Before(c.refresh) {
+		return c.credentials, nil
+	}
+	var credentials, e = c.provider.AWSCredentials(ctx)
+	if e != nil {
+		return AWSCredentials{}, e
+	}
+	c.credentials = credentials
+	c.refresh = now.Add(c.ttl)
+	if !credentials.Expires.IsZero() {
+		c.refresh = credentials.Expires.Add(-awsCredentialsEarlyExpiry)
+	}
+	return credentials, nil
+}
@@ -0,0 +1,233 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// clearAWSEnvironment unsets the variables read by the AWS credential
+// providers for the duration of the test.
+func clearAWSEnvironment(t *testing.T) {
+	for _, name := range []string{
+		"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN", "AWS_PROFILE",
+		"AWS_SHARED_CREDENTIALS_FILE", "AWS_CONFIG_FILE", "AWS_WEB_IDENTITY_TOKEN_FILE",
+		"AWS_ROLE_ARN", "AWS_ROLE_SESSION_NAME", "AWS_REGION",
+		"AWS_EC2_METADATA_SERVICE_ENDPOINT", "AWS_EC2_METADATA_DISABLED",
+	} {
+		t.Setenv(name, "")
+	}
+	t.Setenv("HOME", t.TempDir())
+}
+
+func TestEnvAWSCredentials(t *testing.T) {
+	clearAWSEnvironment(t)
+	var _, e = NewEnvAWSCredentials().AWSCredentials(context.Background())
+	assert.True(t, errors.Is(e, ErrNoAWSCredentials))
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "token")
+	var credentials, _ = NewEnvAWSCredentials().AWSCredentials(context.Background())
+	assert.Equal(t, AWSCredentials{AccessKeyID: "AKID", SecretAccessKey: "secret", SessionToken: "token"}, credentials)
+}
+
+func TestSharedAWSCredentials(t *testing.T) {
+	clearAWSEnvironment(t)
+	var dir = t.TempDir()
+	var credentialsFile = filepath.Join(dir, "credentials")
+	var configFile = filepath.Join(dir, "config")
+	require.NoError(t, os.WriteFile(credentialsFile, []byte(`
+[default]
+aws_access_key_id = AKIDDEFAULT
+aws_secret_access_key = default-secret
+
+# comment
+[dev]
+aws_access_key_id=AKIDDEV
+aws_secret_access_key=dev-secret
+aws_session_token=dev-token
+`), 0600))
+	require.NoError(t, os.WriteFile(configFile, []byte(`
+[profile staging]
+region = us-west-2
+aws_access_key_id = AKIDSTAGING
+aws_secret_access_key = staging-secret
+`), 0600))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", credentialsFile)
+	t.Setenv("AWS_CONFIG_FILE", configFile)
+
+	var provider = NewSharedAWSCredentials()
+	var credentials, e = provider.AWSCredentials(context.Background())
+	require.NoError(t, e)
+	assert.Equal(t, "AKIDDEFAULT", credentials.AccessKeyID)
+
+	t.Setenv("AWS_PROFILE", "dev")
+	credentials, _ = provider.AWSCredentials(context.Background())
+	assert.Equal(t, AWSCredentials{AccessKeyID: "AKIDDEV", SecretAccessKey: "dev-secret", SessionToken: "dev-token"}, credentials)
+
+	t.Setenv("AWS_PROFILE", "staging")
+	credentials, _ = provider.AWSCredentials(context.Background())
+	assert.Equal(t, "AKIDSTAGING", credentials.AccessKeyID)
+
+	t.Setenv("AWS_PROFILE", "missing")
+	_, e = provider.AWSCredentials(context.Background())
+	assert.True(t, errors.Is(e, ErrNoAWSCredentials))
+}
+
+func TestIMDSAWSCredentials(t *testing.T) {
+	clearAWSEnvironment(t)
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/latest/api/token":
+			assert.Equal(t, http.MethodPut, r.Method)
+			assert.Equal(t, "21600", r.Header.Get("X-Aws-Ec2-Metadata-Token-Ttl-Seconds"))
+			_, _ = w.Write([]byte("imds-token"))
+			return
+		}
+		if r.Header.Get("X-Aws-Ec2-Metadata-Token") != "imds-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/latest/meta-data/iam/security-credentials/":
+			_, _ = w.Write([]byte("instance-role\n"))
+		case "/latest/meta-data/iam/security-credentials/instance-role":
+			_, _ = w.Write([]byte(`{"Code":"Success","AccessKeyId":"ASIAIMDS","SecretAccessKey":"imds-secret","Token":"imds-session","Expiration":"2030-01-01T00:00:00Z"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	var credentials, e = NewIMDSAWSCredentials(AWSCredentialsOptionEndpoint(server.URL)).AWSCredentials(context.Background())
+	require.NoError(t, e)
+	assert.Equal(t, AWSCredentials{
+		AccessKeyID:     "ASIAIMDS",
+		SecretAccessKey: "imds-secret",
+		SessionToken:    "imds-session",
+		Expires:         time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+	}, credentials)
+
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+	_, e = NewIMDSAWSCredentials(AWSCredentialsOptionEndpoint(server.URL)).AWSCredentials(context.Background())
+	assert.True(t, errors.Is(e, ErrNoAWSCredentials))
+
+	server.Close()
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "")
+	_, e = NewIMDSAWSCredentials(AWSCredentialsOptionEndpoint(server.URL)).AWSCredentials(context.Background())
+	assert.True(t, errors.Is(e, ErrNoAWSCredentials), "unreachable service should not stop the chain")
+}
+
+func TestWebIdentityAWSCredentials(t *testing.T) {
+	clearAWSEnvironment(t)
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "AssumeRoleWithWebIdentity", r.PostForm.Get("Action"))
+		assert.Equal(t, "arn:aws:iam::123456789012:role/app", r.PostForm.Get("RoleArn"))
+		assert.Equal(t, "app-session", r.PostForm.Get("RoleSessionName"))
+		assert.Equal(t, "projected-token", r.PostForm.Get("WebIdentityToken"))
+		_, _ = fmt.Fprint(w, `<AssumeRoleWithWebIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleWithWebIdentityResult>
+    <Credentials>
+      <AccessKeyId>ASIAWEB</AccessKeyId>
+      <SecretAccessKey>web-secret</SecretAccessKey>
+      <SessionToken>web-session</SessionToken>
+      <Expiration>2030-01-01T00:00:00Z</Expiration>
+    </Credentials>
+  </AssumeRoleWithWebIdentityResult>
+</AssumeRoleWithWebIdentityResponse>`)
+	}))
+	defer server.Close()
+	var provider = NewWebIdentityAWSCredentials(AWSCredentialsOptionEndpoint(server.URL))
+	var _, e = provider.AWSCredentials(context.Background())
+	assert.True(t, errors.Is(e, ErrNoAWSCredentials))
+
+	var tokenFile = filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("projected-token\n"), 0600))
+	t.Setenv("AWS_WEB_IDENTITY_TOKEN_FILE", tokenFile)
+	t.Setenv("AWS_ROLE_ARN", "arn:aws:iam::123456789012:role/app")
+	t.Setenv("AWS_ROLE_SESSION_NAME", "app-session")
+	var credentials AWSCredentials
+	credentials, e = provider.AWSCredentials(context.Background())
+	require.NoError(t, e)
+	assert.Equal(t, AWSCredentials{
+		AccessKeyID:     "ASIAWEB",
+		SecretAccessKey: "web-secret",
+		SessionToken:    "web-session",
+		Expires:         time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+	}, credentials)
+}
+
+func TestAWSCredentialsChain(t *testing.T) {
+	var missing = AWSCredentialsProviderFunc(func(context.Context) (AWSCredentials, error) {
+		return AWSCredentials{}, ErrNoAWSCredentials
+	})
+	var failing = AWSCredentialsProviderFunc(func(context.Context) (AWSCredentials, error) {
+		return AWSCredentials{}, errors.New("failed")
+	})
+	var found = NewStaticAWSCredentials(AWSCredentials{AccessKeyID: "AKID", SecretAccessKey: "secret"})
+
+	var credentials, e = NewAWSCredentialsChain(missing, found, failing).AWSCredentials(context.Background())
+	require.NoError(t, e)
+	assert.Equal(t, "AKID", credentials.AccessKeyID)
+	_, e = NewAWSCredentialsChain(missing, failing, found).AWSCredentials(context.Background())
+	assert.EqualError(t, e, "failed")
+	_, e = NewAWSCredentialsChain(missing).AWSCredentials(context.Background())
+	assert.True(t, errors.Is(e, ErrNoAWSCredentials))
+}
+
+func TestDefaultAWSCredentials(t *testing.T) {
+	clearAWSEnvironment(t)
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDENV")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	var credentials, e = NewDefaultAWSCredentials().AWSCredentials(context.Background())
+	require.NoError(t, e)
+	assert.Equal(t, "AKIDENV", credentials.AccessKeyID)
+}
+
+func TestCachedAWSCredentials(t *testing.T) {
+	var clock = newFakeClock()
+	var retrievals int
+	var expiring = true
+	var provider = AWSCredentialsProviderFunc(func(context.Context) (AWSCredentials, error) {
+		retrievals = retrievals + 1
+		var credentials = AWSCredentials{AccessKeyID: fmt.Sprintf("ASIA%d", retrievals), SecretAccessKey: "secret"}
+		if expiring {
+			credentials.Expires = clock.Now().Add(time.Hour)
+		}
+		return credentials, nil
+	})
+	var cached = NewCachedAWSCredentials(provider, CachedAWSCredentialsOptionClock(clock), CachedAWSCredentialsOptionTTL(time.Minute))
+
+	var credentials, _ = cached.AWSCredentials(context.Background())
+	assert.Equal(t, "ASIA1", credentials.AccessKeyID)
+	clock.advance(54 * time.Minute)
+	credentials, _ = cached.AWSCredentials(context.Background())
+	assert.Equal(t, "ASIA1", credentials.AccessKeyID)
+	clock.advance(time.Minute)
+	credentials, _ = cached.AWSCredentials(context.Background())
+	assert.Equal(t, "ASIA2", credentials.AccessKeyID, "credentials were not refreshed before expiry")
+
+	// Credentials that do not expire are kept for the TTL.
+	expiring = false
+	clock.advance(time.Hour)
+	credentials, _ = cached.AWSCredentials(context.Background())
+	assert.Equal(t, "ASIA3", credentials.AccessKeyID)
+	clock.advance(30 * time.Second)
+	credentials, _ = cached.AWSCredentials(context.Background())
+	assert.Equal(t, "ASIA3", credentials.AccessKeyID)
+	clock.advance(30 * time.Second)
+	credentials, _ = cached.AWSCredentials(context.Background())
+	assert.Equal(t, "ASIA4", credentials.AccessKeyID)
+}
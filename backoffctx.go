@@ -0,0 +1,77 @@
+package transport
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// BackofferCtx is a counterpart for Backoffer that is given the context and
+// the number of attempts made so far, starting at one for the delay before
+// the first retry. Implementations can compute delays from the attempt number
+// instead of tracking it themselves, which allows a single instance to be
+// safely shared between requests.
+//
+// Every decorator that uses a Backoffer calls BackoffCtx instead of Backoff
+// when the Backoffer also implements BackofferCtx.
+type BackofferCtx interface {
+	BackoffCtx(ctx context.Context, attempt int, r *http.Request, response *http.Response, e error) time.Duration
+}
+
+// BackofferCtxFunc converts a function to a BackofferCtx.
+type BackofferCtxFunc func(ctx context.Context, attempt int, r *http.Request, response *http.Response, e error) time.Duration
+
+// BackoffCtx calls the wrapped function.
+func (f BackofferCtxFunc) BackoffCtx(ctx context.Context, attempt int, r *http.Request, response *http.Response, e error) time.Duration {
+	return f(ctx, attempt, r, response, e)
+}
+
+// NewBackofferCtxPolicy generates a BackoffPolicy from a BackofferCtx. The
+// BackofferCtx is shared by every Backoffer the policy creates. Each Backoffer
+// counts its own attempts for callers that use the Backoff method.
+func NewBackofferCtxPolicy(backoffer BackofferCtx) BackoffPolicy {
+	return func() Backoffer {
+		return &ctxBackoffer{wrapped: backoffer}
+	}
+}
+
+// ctxBackoffer adapts a BackofferCtx to the Backoffer interface.
+type ctxBackoffer struct {
+	wrapped  BackofferCtx
+	attempts int
+}
+
+// Backoff counts each call as an attempt and uses the context of the request.
+func (b *ctxBackoffer) Backoff(r *http.Request, response *http.Response, e error) time.Duration {
+	b.attempts = b.attempts + 1
+	var ctx = context.Background()
+	if r != nil {
+		ctx = r.Context()
+	}
+	return b.wrapped.BackoffCtx(ctx, b.attempts, r, response, e)
+}
+
+// BackoffCtx calls the wrapped BackofferCtx.
+func (b *ctxBackoffer) BackoffCtx(ctx context.Context, attempt int, r *http.Request, response *http.Response, e error) time.Duration {
+	return b.wrapped.BackoffCtx(ctx, attempt, r, response, e)
+}
+
+// AdaptBackoffer converts a Backoffer to a BackofferCtx. Backoffers that do
+// not implement BackofferCtx ignore the context and attempt number.
+func AdaptBackoffer(backoffer Backoffer) BackofferCtx {
+	if withCtx, ok := backoffer.(BackofferCtx); ok {
+		return withCtx
+	}
+	return BackofferCtxFunc(func(_ context.Context, _ int, r *http.Request, response *http.Response, e error) time.Duration {
+		return backoffer.Backoff(r, response, e)
+	})
+}
+
+// backoffFor computes a delay using BackoffCtx when the Backoffer supports
+// it.
+func backoffFor(backoffer Backoffer, ctx context.Context, attempt int, r *http.Request, response *http.Response, e error) time.Duration {
+	if withCtx, ok := backoffer.(BackofferCtx); ok {
+		return withCtx.BackoffCtx(ctx, attempt, r, response, e)
+	}
+	return backoffer.Backoff(r, response, e)
+}
@@ -0,0 +1,71 @@
+package transport
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type countingBackoffer struct {
+	calls int
+}
+
+func (b *countingBackoffer) Backoff(*http.Request, *http.Response, error) time.Duration {
+	b.calls = b.calls + 1
+	return time.Duration(b.calls) * time.Millisecond
+}
+
+func TestExponentialBackoffCtx(t *testing.T) {
+	var backoffer = NewExponentialBackoffPolicy(time.Millisecond)().(BackofferCtx)
+	var ctx = context.Background()
+	assert.Equal(t, time.Millisecond, backoffer.BackoffCtx(ctx, 0, nil, nil, nil))
+	assert.Equal(t, time.Millisecond, backoffer.BackoffCtx(ctx, 1, nil, nil, nil))
+	assert.Equal(t, 4*time.Millisecond, backoffer.BackoffCtx(ctx, 3, nil, nil, nil))
+	assert.Equal(t, 4*time.Millisecond, backoffer.BackoffCtx(ctx, 3, nil, nil, nil), "BackoffCtx modified the Backoffer")
+	assert.Greater(t, backoffer.BackoffCtx(ctx, 100, nil, nil, nil), time.Duration(0), "delay overflowed")
+}
+
+func TestJitteredBackoffCtx(t *testing.T) {
+	var backoffer = NewPercentJitteredBackoffPolicy(NewExponentialBackoffPolicy(time.Second), 0)().(BackofferCtx)
+	assert.Equal(t, 2*time.Second, backoffer.BackoffCtx(context.Background(), 2, nil, nil, nil))
+}
+
+func TestAdaptBackoffer(t *testing.T) {
+	var legacy = &countingBackoffer{}
+	var adapted = AdaptBackoffer(legacy)
+	assert.Equal(t, time.Millisecond, adapted.BackoffCtx(context.Background(), 5, nil, nil, nil))
+	assert.Equal(t, 2*time.Millisecond, adapted.BackoffCtx(context.Background(), 5, nil, nil, nil))
+
+	var fixed = &FixedBackoffer{wait: time.Second}
+	assert.Equal(t, BackofferCtx(fixed), AdaptBackoffer(fixed))
+}
+
+func TestNewBackofferCtxPolicy(t *testing.T) {
+	var ctrl = gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var attempts []int
+	var policy = NewBackofferCtxPolicy(BackofferCtxFunc(func(_ context.Context, attempt int, _ *http.Request, _ *http.Response, _ error) time.Duration {
+		attempts = append(attempts, attempt)
+		return 0
+	}))
+	var legacy = policy()
+	_ = legacy.Backoff(nil, nil, nil)
+	_ = legacy.Backoff(nil, nil, nil)
+	assert.Equal(t, []int{1, 2}, attempts)
+
+	attempts = nil
+	var wrapped = NewMockRoundTripper(ctrl)
+	var rt = NewRetrier(policy, NewLimitedRetryPolicy(2, NewStatusCodeRetryPolicy(http.StatusInternalServerError)))(wrapped)
+	wrapped.EXPECT().RoundTrip(gomock.Any()).Return(&http.Response{StatusCode: http.StatusInternalServerError, Body: http.NoBody}, nil).Times(3)
+	var req, _ = http.NewRequest(http.MethodGet, "/", nil)
+	var resp, e = rt.RoundTrip(req)
+	require.NoError(t, e)
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.Equal(t, []int{1, 2}, attempts)
+}
@@ -0,0 +1,110 @@
+package transport
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+type baggageKey struct{}
+
+// WithBaggage adds W3C Baggage entries to the context. Entries already in the
+// context are kept unless they are replaced by one with the same key.
+func WithBaggage(ctx context.Context, entries map[string]string) context.Context {
+	var existing = BaggageFromContext(ctx)
+	for k, v := range entries {
+		existing[k] = v
+	}
+	return context.WithValue(ctx, baggageKey{}, existing)
+}
+
+// BaggageFromContext returns a copy of the Baggage entries in the context.
+func BaggageFromContext(ctx context.Context) map[string]string {
+	var entries, _ = ctx.Value(baggageKey{}).(map[string]string)
+	var result = make(map[string]string, len(entries))
+	for k, v := range entries {
+		result[k] = v
+	}
+	return result
+}
+
+// ParseBaggage parses the value of a W3C baggage header. Entry properties are
+// discarded and malformed entries are skipped.
+func ParseBaggage(value string) map[string]string {
+	var entries = make(map[string]string)
+	for _, member := range strings.Split(value, ",") {
+		member, _, _ = strings.Cut(member, ";")
+		var k, v, ok = strings.Cut(member, "=")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" {
+			continue
+		}
+		var decoded, e = url.PathUnescape(strings.TrimSpace(v))
+		if e != nil {
+			continue
+		}
+		entries[k] = decoded
+	}
+	return entries
+}
+
+func formatBaggage(entries map[string]string) string {
+	var keys = make([]string, 0, len(entries))
+	for k := range entries {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var members = make([]string, 0, len(keys))
+	for _, k := range keys {
+		members = append(members, k+"="+url.PathEscape(entries[k]))
+	}
+	return strings.Join(members, ",")
+}
+
+// filterBaggage returns the entries whose keys are in the allowlist.
+func filterBaggage(entries map[string]string, allowed []string) map[string]string {
+	var filtered = make(map[string]string, len(allowed))
+	for _, k := range allowed {
+		if v, ok := entries[k]; ok {
+			filtered[k] = v
+		}
+	}
+	return filtered
+}
+
+// Baggage is a decorator that forwards an allowlisted set of the W3C Baggage
+// entries found in the request context to the upstream.
+type Baggage struct {
+	wrapped http.RoundTripper
+	allowed []string
+}
+
+// RoundTrip writes the allowed entries to the baggage header of the request
+// and calls the wrapped transport. Entries already in the header are kept.
+func (c *Baggage) RoundTrip(r *http.Request) (*http.Response, error) {
+	var entries = filterBaggage(BaggageFromContext(r.Context()), c.allowed)
+	if len(entries) < 1 {
+		return c.wrapped.RoundTrip(r)
+	}
+	r = r.Clone(r.Context())
+	if r.Header == nil {
+		r.Header = http.Header{}
+	}
+	for k, v := range ParseBaggage(strings.Join(r.Header.Values("baggage"), ",")) {
+		if _, ok := entries[k]; !ok {
+			entries[k] = v
+		}
+	}
+	r.Header.Set("baggage", formatBaggage(entries))
+	return c.wrapped.RoundTrip(r)
+}
+
+// NewBaggage configures a RoundTripper decorator that forwards the Baggage
+// entries with the given keys. Entries with any other key are never sent.
+func NewBaggage(allowed ...string) func(http.RoundTripper) http.RoundTripper {
+	return func(wrapped http.RoundTripper) http.RoundTripper {
+		return &Baggage{wrapped: wrapped, allowed: allowed}
+	}
+}
@@ -0,0 +1,41 @@
+package transport
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBaggage(t *testing.T) {
+	assert.Equal(t, map[string]string{
+		"tenant": "a b",
+		"flag":   "on",
+	}, ParseBaggage("tenant=a%20b;prop=1, flag = on,broken,=empty,bad=%zz"))
+}
+
+func TestWithBaggage(t *testing.T) {
+	var ctx = WithBaggage(context.Background(), map[string]string{"a": "1", "b": "2"})
+	ctx = WithBaggage(ctx, map[string]string{"b": "3"})
+	var entries = BaggageFromContext(ctx)
+	assert.Equal(t, map[string]string{"a": "1", "b": "3"}, entries)
+	entries["c"] = "4"
+	assert.NotContains(t, BaggageFromContext(ctx), "c")
+}
+
+func TestBaggage(t *testing.T) {
+	var fixture = &fixtureHeaderTransport{Response: &http.Response{StatusCode: http.StatusOK}}
+	var rt = NewBaggage("tenant", "feature")(fixture)
+
+	var req, _ = http.NewRequest(http.MethodGet, "/", nil)
+	_, _ = rt.RoundTrip(req)
+	assert.Empty(t, fixture.Request.Header.Get("baggage"))
+
+	var ctx = WithBaggage(context.Background(), map[string]string{"tenant": "acme co", "secret": "value"})
+	req, _ = http.NewRequestWithContext(ctx, http.MethodGet, "/", nil)
+	req.Header.Set("baggage", "existing=1,tenant=other")
+	_, _ = rt.RoundTrip(req)
+	assert.Equal(t, "existing=1,tenant=acme%20co", fixture.Request.Header.Get("baggage"))
+	assert.Equal(t, "existing=1,tenant=other", req.Header.Get("baggage"))
+}
@@ -0,0 +1,141 @@
+package transport
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// cancelBody releases the context of a request attempt once the caller is
+// done with the response body. The context cannot be canceled when the
+// decorator returns because the standard library transport stops emitting
+// body content as soon as the request context ends.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+	once   *sync.Once
+}
+
+// Close the wrapped body and release the attempt context.
+func (b *cancelBody) Close() error {
+	var e = b.ReadCloser.Close()
+	b.once.Do(b.cancel)
+	return e
+}
+
+// cancelReadWriteBody preserves the io.Writer implementation of bodies for
+// 101 Switching Protocols responses.
+type cancelReadWriteBody struct {
+	*cancelBody
+	writer io.Writer
+}
+
+func (b *cancelReadWriteBody) Write(p []byte) (int, error) {
+	return b.writer.Write(p)
+}
+
+// withCancelBody ties the lifetime of the context behind the cancel function
+// to the response body. The context is canceled immediately if there is no
+// body to read.
+func withCancelBody(response *http.Response, cancel context.CancelFunc) *http.Response {
+	if response == nil || response.Body == nil {
+		cancel()
+		return response
+	}
+	var body = &cancelBody{ReadCloser: response.Body, cancel: cancel, once: &sync.Once{}}
+	if writer, ok := response.Body.(io.Writer); ok {
+		response.Body = &cancelReadWriteBody{cancelBody: body, writer: writer}
+		return response
+	}
+	response.Body = body
+	return response
+}
+
+// maxDrainBytes bounds how much of an unwanted response body is read before
+// it is closed. Reading a small body to EOF allows the connection to be
+// reused, while a large one is cheaper to abandon than to download.
+const maxDrainBytes = 64 << 10
+
+// drainBody reads a bounded amount of an unwanted response body and closes it
+// so that the underlying connection can return to the idle pool. It must be
+// called before the context of the request is canceled.
+func drainBody(response *http.Response) {
+	if response == nil || response.Body == nil {
+		return
+	}
+	_, _ = io.CopyN(io.Discard, response.Body, maxDrainBytes)
+	_ = response.Body.Close()
+}
+
+// trailerBody forwards the trailers of the response or request that produced a
+// body to a different one that the body was moved to. Response trailers are
+// only available once the body has been read to EOF, and the standard library
+// transport may install a new Trailer map on the original response at that
+// point. Request trailers may be set by the caller while the body is read.
+// Either way they cannot be copied when the body is moved.
+type trailerBody struct {
+	io.ReadCloser
+	from func() http.Header
+	to   *http.Header
+	once *sync.Once
+}
+
+func (b *trailerBody) Read(p []byte) (int, error) {
+	var n, e = b.ReadCloser.Read(p)
+	if e == io.EOF {
+		b.once.Do(b.copyTrailers)
+	}
+	return n, e
+}
+
+func (b *trailerBody) copyTrailers() {
+	var from = b.from()
+	if len(from) < 1 {
+		return
+	}
+	if *b.to == nil {
+		*b.to = make(http.Header, len(from))
+	}
+	for k, v := range from {
+		(*b.to)[k] = v
+	}
+}
+
+// withBody returns a shallow copy of the response that reads from the given
+// body. The trailers of the original response are copied to the new one when
+// the body reaches EOF. Decorators that replace or wrap a response body
+// should use this rather than copying the response themselves. An
+// http.NoBody replacement is set as is because there is nothing left to
+// read.
+func withBody(response *http.Response, body io.ReadCloser) *http.Response {
+	var replaced = *response
+	replaced.Trailer = response.Trailer.Clone()
+	if body == http.NoBody {
+		replaced.Body = body
+		return &replaced
+	}
+	replaced.Body = &trailerBody{
+		ReadCloser: body,
+		from:       func() http.Header { return response.Trailer },
+		to:         &replaced.Trailer,
+		once:       &sync.Once{},
+	}
+	return &replaced
+}
+
+// withRequestBody returns a clone of the request that sends the given body.
+// Trailers that the caller sets on the original request while its body is
+// read are copied to the clone when the new body reaches EOF. Decorators that
+// replace or wrap a request body should use this rather than cloning the
+// request themselves.
+func withRequestBody(r *http.Request, body io.ReadCloser) *http.Request {
+	var replaced = r.Clone(r.Context())
+	replaced.Body = &trailerBody{
+		ReadCloser: body,
+		from:       func() http.Header { return r.Trailer },
+		to:         &replaced.Trailer,
+		once:       &sync.Once{},
+	}
+	return replaced
+}
@@ -0,0 +1,157 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type readWriteCloser struct {
+	bytes.Buffer
+}
+
+func (*readWriteCloser) Close() error {
+	return nil
+}
+
+func TestWithCancelBody(t *testing.T) {
+	var ctx, cancel = context.WithCancel(context.Background())
+	var resp = withCancelBody(&http.Response{Body: io.NopCloser(bytes.NewBufferString("body"))}, cancel)
+	var b, _ = io.ReadAll(resp.Body)
+	assert.Equal(t, "body", string(b))
+	assert.Nil(t, ctx.Err())
+	assert.NoError(t, resp.Body.Close())
+	assert.ErrorIs(t, ctx.Err(), context.Canceled)
+	assert.NoError(t, resp.Body.Close())
+
+	ctx, cancel = context.WithCancel(context.Background())
+	assert.Nil(t, withCancelBody(nil, cancel))
+	assert.ErrorIs(t, ctx.Err(), context.Canceled)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	resp = withCancelBody(&http.Response{}, cancel)
+	assert.Nil(t, resp.Body)
+	assert.ErrorIs(t, ctx.Err(), context.Canceled)
+}
+
+func TestWithCancelBodyPreservesWriter(t *testing.T) {
+	var _, cancel = context.WithCancel(context.Background())
+	var rw = &readWriteCloser{}
+	var resp = withCancelBody(&http.Response{StatusCode: http.StatusSwitchingProtocols, Body: rw}, cancel)
+	var writer, ok = resp.Body.(io.Writer)
+	assert.True(t, ok)
+	_, _ = writer.Write([]byte("upgraded"))
+	assert.Equal(t, "upgraded", rw.String())
+	assert.NoError(t, resp.Body.Close())
+}
+
+func newTrailerServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "Declared")
+		_, _ = w.Write([]byte("body"))
+		w.Header().Set("Declared", "declared")
+		w.Header().Set(http.TrailerPrefix+"Undeclared", "undeclared")
+	}))
+}
+
+func TestWithBodyPreservesTrailers(t *testing.T) {
+	var server = newTrailerServer()
+	defer server.Close()
+
+	var original, e = http.Get(server.URL)
+	require.NoError(t, e)
+	var resp = withBody(original, io.NopCloser(io.MultiReader(strings.NewReader("prefix-"), original.Body)))
+	var b, _ = io.ReadAll(resp.Body)
+	assert.NoError(t, resp.Body.Close())
+	assert.Equal(t, "prefix-body", string(b))
+	assert.Equal(t, "declared", resp.Trailer.Get("Declared"))
+	assert.Equal(t, "undeclared", resp.Trailer.Get("Undeclared"))
+}
+
+func TestDecoratorsPreserveTrailers(t *testing.T) {
+	var server = newTrailerServer()
+	defer server.Close()
+
+	var chain = Chain{
+		NewRetryAfter(),
+		NewRetrier(NewFixedBackoffPolicy(0), NewStatusCodeRetryPolicy(http.StatusInternalServerError)),
+		NewHedger(NewFixedBackoffPolicy(time.Second)),
+	}
+	var client = &http.Client{Transport: chain.Apply(http.DefaultTransport)}
+	var resp, e = client.Get(server.URL)
+	require.NoError(t, e)
+	var b, _ = io.ReadAll(resp.Body)
+	assert.NoError(t, resp.Body.Close())
+	assert.Equal(t, "body", string(b))
+	assert.Equal(t, "declared", resp.Trailer.Get("Declared"))
+	assert.Equal(t, "undeclared", resp.Trailer.Get("Undeclared"))
+}
+
+func TestRetryDrainingReusesConnections(t *testing.T) {
+	var calls, connections int
+	var server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = calls + 1
+		switch calls {
+		case 1, 2:
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(strings.Repeat("error", 100)))
+		case 3:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte("slow down"))
+		default:
+			_, _ = w.Write([]byte("ok"))
+		}
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			connections = connections + 1
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	var base = &http.Transport{}
+	defer base.CloseIdleConnections()
+	var chain = Chain{
+		NewRetryAfter(),
+		NewRetrier(NewFixedBackoffPolicy(0), NewLimitedRetryPolicy(2, NewStatusCodeRetryPolicy(http.StatusInternalServerError))),
+	}
+	var client = &http.Client{Transport: chain.Apply(base)}
+	var resp, e = client.Get(server.URL)
+	require.NoError(t, e)
+	var b, _ = io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	assert.Equal(t, "ok", string(b))
+	assert.Equal(t, 4, calls)
+	assert.Equal(t, 1, connections)
+}
+
+func TestWithRequestBodyPreservesTrailers(t *testing.T) {
+	var req, _ = http.NewRequest(http.MethodPost, "https://example.com/", nil)
+	req.Trailer = http.Header{"Checksum": nil}
+	req.Body = io.NopCloser(strings.NewReader("body"))
+	var replaced = withRequestBody(req, io.NopCloser(io.MultiReader(strings.NewReader("prefix-"), readerFunc(func(p []byte) (int, error) {
+		// The caller sets trailer values while the body is read.
+		req.Trailer.Set("Checksum", "abc")
+		return req.Body.Read(p)
+	}))))
+	var b, _ = io.ReadAll(replaced.Body)
+	assert.Equal(t, "prefix-body", string(b))
+	assert.Equal(t, "abc", replaced.Trailer.Get("Checksum"))
+}
+
+type readerFunc func(p []byte) (int, error)
+
+func (f readerFunc) Read(p []byte) (int, error) {
+	return f(p)
+}
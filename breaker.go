@@ -0,0 +1,521 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const circuitBreakerSource = "circuit_breaker"
+
+const (
+	defaultBreakerThreshold = 5
+	defaultBreakerOpenFor   = 30 * time.Second
+)
+
+// ErrCircuitOpen is returned by the circuit breaker decorator for requests it
+// rejects without sending.
+var ErrCircuitOpen = errors.New("transport: circuit breaker is open")
+
+// CircuitState is the state of a single circuit.
+type CircuitState int
+
+const (
+	// CircuitClosed circuits send every request.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen circuits reject every request until the open duration has
+	// passed.
+	CircuitOpen
+	// CircuitHalfOpen circuits send a limited number of probe requests to
+	// decide whether to close again.
+	CircuitHalfOpen
+)
+
+// String returns the lower case name of the state.
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half_open"
+	default:
+		return "closed"
+	}
+}
+
+// CircuitRecord is the state of a circuit as exchanged with a CircuitStore.
+type CircuitRecord struct {
+	State CircuitState
+	// Until is the time an open circuit begins probing.
+	Until time.Time
+	// Updated is the time of the transition that produced the record. The
+	// most recent record wins when replicas disagree.
+	Updated time.Time
+}
+
+// CircuitStore shares circuit transitions between the replicas of a service
+// so that a circuit opened by one replica is opened by the others rather
+// than each of them rediscovering the outage. Sharing is eventually
+// consistent: replicas publish their transitions and read the records of
+// others periodically.
+type CircuitStore interface {
+	// Load returns the record for the key, or false if there is none.
+	Load(ctx context.Context, key string) (CircuitRecord, bool, error)
+	// Save replaces the record for the key.
+	Save(ctx context.Context, key string, record CircuitRecord) error
+}
+
+// MemoryCircuitStore is a CircuitStore held in process memory. It shares
+// circuits between breakers of a single process and is useful in tests.
+type MemoryCircuitStore struct {
+	lock    sync.Mutex
+	records map[string]CircuitRecord
+}
+
+// NewMemoryCircuitStore creates an empty MemoryCircuitStore.
+func NewMemoryCircuitStore() *MemoryCircuitStore {
+	return &MemoryCircuitStore{records: make(map[string]CircuitRecord)}
+}
+
+// Load returns the record for the key.
+func (s *MemoryCircuitStore) Load(_ context.Context, key string) (CircuitRecord, bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var record, ok = s.records[key]
+	return record, ok, nil
+}
+
+// Save replaces the record for the key.
+func (s *MemoryCircuitStore) Save(_ context.Context, key string, record CircuitRecord) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.records[key] = record
+	return nil
+}
+
+type circuit struct {
+	state    CircuitState
+	failures int
+	until    time.Time
+	updated  time.Time
+	probes   int
+	// successes is the number of consecutive probes that have succeeded.
+	successes int
+	// synced is the last time the circuit was compared with the store.
+	synced time.Time
+}
+
+// CircuitBreaker tracks a circuit per key, which defaults to the request host.
+// A circuit opens after a number of consecutive failures and rejects requests
+// with ErrCircuitOpen until the open duration has passed. It then lets a
+// limited number of probe requests through and closes once enough of them
+// succeed in a row or opens again as soon as one fails. It is safe for
+// concurrent use.
+type CircuitBreaker struct {
+	lock      sync.Mutex
+	circuits  map[string]*circuit
+	key       func(*http.Request) string
+	failure   func(*http.Response, error) bool
+	threshold int
+	openFor   time.Duration
+	jitter    time.Duration
+	probes    int
+	successes int
+	random    func() float64
+	fallback  func(*http.Request, error) (*http.Response, error)
+	clock     Clock
+	store     CircuitStore
+	syncEvery time.Duration
+}
+
+// CircuitBreakerOption is a configuration for the CircuitBreaker.
+type CircuitBreakerOption func(*CircuitBreaker) *CircuitBreaker
+
+// CircuitBreakerOptionKey sets the function that selects the circuit a request
+// belongs to. The default is the host of the request URL.
+func CircuitBreakerOptionKey(key func(*http.Request) string) CircuitBreakerOption {
+	return func(b *CircuitBreaker) *CircuitBreaker {
+		b.key = key
+		return b
+	}
+}
+
+// CircuitBreakerOptionFailure sets the function that classifies outcomes as
+// failures. The default treats errors and 5xx status codes as failures.
+func CircuitBreakerOptionFailure(failure func(*http.Response, error) bool) CircuitBreakerOption {
+	return func(b *CircuitBreaker) *CircuitBreaker {
+		b.failure = failure
+		return b
+	}
+}
+
+// CircuitBreakerOptionThreshold sets the number of consecutive failures that
+// open a circuit. The default is 5.
+func CircuitBreakerOptionThreshold(threshold int) CircuitBreakerOption {
+	return func(b *CircuitBreaker) *CircuitBreaker {
+		b.threshold = threshold
+		return b
+	}
+}
+
+// CircuitBreakerOptionOpenDuration sets how long a circuit rejects requests
+// before probing. The default is 30 seconds.
+func CircuitBreakerOptionOpenDuration(d time.Duration) CircuitBreakerOption {
+	return func(b *CircuitBreaker) *CircuitBreaker {
+		b.openFor = d
+		return b
+	}
+}
+
+// CircuitBreakerOptionOpenJitter adds a randomized jitter to the open duration
+// that is plus or minus the duration value given. Jitter keeps the replicas
+// of a service from probing a recovering upstream at the same moment.
+func CircuitBreakerOptionOpenJitter(jitter time.Duration) CircuitBreakerOption {
+	return func(b *CircuitBreaker) *CircuitBreaker {
+		b.jitter = jitter
+		return b
+	}
+}
+
+// CircuitBreakerOptionProbes sets the number of probe requests a half-open
+// circuit allows at the same time. Requests beyond the limit are rejected
+// with ErrCircuitOpen. The default is 1.
+func CircuitBreakerOptionProbes(probes int) CircuitBreakerOption {
+	return func(b *CircuitBreaker) *CircuitBreaker {
+		b.probes = probes
+		return b
+	}
+}
+
+// CircuitBreakerOptionSuccesses sets the number of consecutive probe requests
+// that must succeed before a half-open circuit closes. The default is 1.
+func CircuitBreakerOptionSuccesses(successes int) CircuitBreakerOption {
+	return func(b *CircuitBreaker) *CircuitBreaker {
+		b.successes = successes
+		return b
+	}
+}
+
+// CircuitBreakerOptionRandSource configures the source of randomness used to
+// compute the open jitter. The source must not be used elsewhere.
+func CircuitBreakerOptionRandSource(source *rand.Rand) CircuitBreakerOption {
+	return func(b *CircuitBreaker) *CircuitBreaker {
+		b.random = lockedRandom(source)
+		return b
+	}
+}
+
+// CircuitBreakerOptionFallback sets a function that produces the outcome of
+// requests rejected by an open circuit, such as a cached or default response,
+// instead of returning ErrCircuitOpen to the caller. The function receives the
+// rejection error, which wraps ErrCircuitOpen. ServiceUnavailableFallback
+// responds with a well formed 503.
+func CircuitBreakerOptionFallback(fallback func(*http.Request, error) (*http.Response, error)) CircuitBreakerOption {
+	return func(b *CircuitBreaker) *CircuitBreaker {
+		b.fallback = fallback
+		return b
+	}
+}
+
+// ServiceUnavailableFallback is a fallback for the CircuitBreaker that
+// responds to rejected requests with a 503 Service Unavailable whose body is
+// the rejection error.
+func ServiceUnavailableFallback(r *http.Request, e error) (*http.Response, error) {
+	var body = e.Error()
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", http.StatusServiceUnavailable, http.StatusText(http.StatusServiceUnavailable)),
+		StatusCode:    http.StatusServiceUnavailable,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"text/plain; charset=utf-8"}},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       r,
+	}, nil
+}
+
+// CircuitBreakerOptionClock configures the Clock used to time open circuits.
+func CircuitBreakerOptionClock(clock Clock) CircuitBreakerOption {
+	return func(b *CircuitBreaker) *CircuitBreaker {
+		b.clock = clock
+		return b
+	}
+}
+
+// CircuitBreakerOptionStore shares circuit transitions through the store.
+// Transitions are saved as they happen and each circuit reads the store at
+// most once per sync interval, adopting the record if it is newer than its
+// own state. Failures to reach the store are ignored so that the breaker
+// keeps working on local state.
+func CircuitBreakerOptionStore(store CircuitStore, syncInterval time.Duration) CircuitBreakerOption {
+	return func(b *CircuitBreaker) *CircuitBreaker {
+		b.store = store
+		b.syncEvery = syncInterval
+		return b
+	}
+}
+
+// NewCircuitBreaker creates a CircuitBreaker with every circuit closed.
+func NewCircuitBreaker(opts ...CircuitBreakerOption) *CircuitBreaker {
+	var b = &CircuitBreaker{
+		circuits:  make(map[string]*circuit),
+		key:       hostKey,
+		failure:   defaultHealthFailure,
+		threshold: defaultBreakerThreshold,
+		openFor:   defaultBreakerOpenFor,
+		probes:    1,
+		successes: 1,
+		random:    rand.Float64,
+		clock:     NewSystemClock(),
+	}
+	for _, opt := range opts {
+		b = opt(b)
+	}
+	b.normalize()
+	return b
+}
+
+func (b *CircuitBreaker) normalize() {
+	if b.threshold < 1 {
+		b.threshold = 1
+	}
+	if b.probes < 1 {
+		b.probes = 1
+	}
+	if b.successes < 1 {
+		b.successes = 1
+	}
+}
+
+// Reconfigure applies the options to a breaker that is in use while keeping
+// the state of its circuits, so that thresholds can be tuned during an
+// incident without closing every open circuit. Only the threshold, open
+// duration, open jitter, probes, and successes options take effect; any
+// others are ignored. Open circuits stay open until the time chosen when
+// they opened.
+func (b *CircuitBreaker) Reconfigure(opts ...CircuitBreakerOption) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	var updated = &CircuitBreaker{threshold: b.threshold, openFor: b.openFor, jitter: b.jitter, probes: b.probes, successes: b.successes}
+	for _, opt := range opts {
+		updated = opt(updated)
+	}
+	updated.normalize()
+	b.threshold = updated.threshold
+	b.openFor = updated.openFor
+	b.jitter = updated.jitter
+	b.probes = updated.probes
+	b.successes = updated.successes
+}
+
+// Key returns the key of the circuit the request belongs to.
+func (b *CircuitBreaker) Key(r *http.Request) string {
+	return b.key(r)
+}
+
+// State returns the current state of the circuit for the key.
+func (b *CircuitBreaker) State(key string) CircuitState {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if c, ok := b.circuits[key]; ok {
+		return c.state
+	}
+	return CircuitClosed
+}
+
+// Keys returns the key of every circuit in sorted order.
+func (b *CircuitBreaker) Keys() []string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	var keys = make([]string, 0, len(b.circuits))
+	for k := range b.circuits {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (b *CircuitBreaker) circuitFor(key string) *circuit {
+	var c, ok = b.circuits[key]
+	if !ok {
+		c = &circuit{}
+		b.circuits[key] = c
+	}
+	return c
+}
+
+// sync adopts the stored record for the key if it is newer than the local
+// state. It is called without holding the lock.
+func (b *CircuitBreaker) sync(ctx context.Context, key string) {
+	if b.store == nil {
+		return
+	}
+	var now = b.clock.Now()
+	b.lock.Lock()
+	var c = b.circuitFor(key)
+	if now.Sub(c.synced) < b.syncEvery && !c.synced.IsZero() {
+		b.lock.Unlock()
+		return
+	}
+	c.synced = now
+	b.lock.Unlock()
+
+	var record, ok, e = b.store.Load(ctx, key)
+	if e != nil || !ok {
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if !record.Updated.After(c.updated) {
+		return
+	}
+	var opened = c.state != CircuitOpen && record.State == CircuitOpen
+	c.state = record.State
+	c.until = record.Until
+	c.updated = record.Updated
+	c.failures = 0
+	c.probes = 0
+	c.successes = 0
+	if opened {
+		emitEvent(ctx, TransportEvent{Type: EventCircuitOpened, Source: circuitBreakerSource})
+	}
+}
+
+// publish saves a transition to the store. It is called without holding the
+// lock.
+func (b *CircuitBreaker) publish(ctx context.Context, key string, record CircuitRecord) {
+	if b.store == nil {
+		return
+	}
+	_ = b.store.Save(ctx, key, record)
+}
+
+// Allow reports whether a request for the key may be sent. Requests that are
+// allowed must be followed by a call to Record with their outcome.
+func (b *CircuitBreaker) Allow(ctx context.Context, key string) bool {
+	b.sync(ctx, key)
+	var now = b.clock.Now()
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	var c = b.circuitFor(key)
+	switch c.state {
+	case CircuitOpen:
+		if now.Before(c.until) {
+			return false
+		}
+		c.state = CircuitHalfOpen
+		c.probes = 0
+		c.successes = 0
+		fallthrough
+	case CircuitHalfOpen:
+		if c.probes >= b.probes {
+			return false
+		}
+		c.probes = c.probes + 1
+		return true
+	default:
+		return true
+	}
+}
+
+// Record updates the circuit for the key with the outcome of a request that
+// Allow let through.
+func (b *CircuitBreaker) Record(ctx context.Context, key string, failed bool) {
+	var now = b.clock.Now()
+	b.lock.Lock()
+	var c = b.circuitFor(key)
+	var transition bool
+	switch c.state {
+	case CircuitHalfOpen:
+		c.probes = c.probes - 1
+		if failed {
+			b.open(c, now)
+			transition = true
+			break
+		}
+		c.successes = c.successes + 1
+		if c.successes >= b.successes {
+			c.state = CircuitClosed
+			c.failures = 0
+			c.updated = now
+			transition = true
+		}
+	case CircuitClosed:
+		if !failed {
+			c.failures = 0
+			break
+		}
+		c.failures = c.failures + 1
+		if c.failures >= b.threshold {
+			b.open(c, now)
+			transition = true
+		}
+	default:
+		// Outcomes of requests sent before the circuit opened do not
+		// change it.
+	}
+	var record = CircuitRecord{State: c.state, Until: c.until, Updated: c.updated}
+	b.lock.Unlock()
+	if !transition {
+		return
+	}
+	if record.State == CircuitOpen {
+		emitEvent(ctx, TransportEvent{Type: EventCircuitOpened, Source: circuitBreakerSource})
+	}
+	b.publish(ctx, key, record)
+}
+
+func (b *CircuitBreaker) open(c *circuit, now time.Time) {
+	var renderedJitter = time.Duration(b.random() * float64(b.jitter))
+	if b.random()*100 > 50 {
+		renderedJitter = -renderedJitter
+	}
+	c.state = CircuitOpen
+	c.until = now.Add(b.openFor + renderedJitter)
+	c.updated = now
+	c.failures = 0
+}
+
+type circuitBreakerTransport struct {
+	wrapped http.RoundTripper
+	breaker *CircuitBreaker
+}
+
+// RoundTrip rejects the request, or hands it to the fallback, if its circuit
+// is open and records the outcome otherwise. The body of a rejected request
+// is closed unless a fallback handles it.
+func (c *circuitBreakerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	var key = c.breaker.key(r)
+	var allowed = c.breaker.Allow(r.Context(), key)
+	Annotate(r.Context(), AnnotationBreakerState, c.breaker.State(key).String())
+	if !allowed {
+		var e = fmt.Errorf("%w: %s", ErrCircuitOpen, key)
+		if c.breaker.fallback != nil {
+			return c.breaker.fallback(r, e)
+		}
+		if r.Body != nil {
+			_ = r.Body.Close()
+		}
+		return nil, e
+	}
+	var resp, e = c.wrapped.RoundTrip(r)
+	c.breaker.Record(r.Context(), key, c.breaker.failure(resp, e))
+	return resp, e
+}
+
+// NewCircuitBreaking configures a RoundTripper decorator that applies the
+// breaker to every request. Installing it inside a retry decorator lets each
+// attempt count towards the breaker while rejected attempts fail fast.
+func NewCircuitBreaking(breaker *CircuitBreaker) func(http.RoundTripper) http.RoundTripper {
+	return func(wrapped http.RoundTripper) http.RoundTripper {
+		return &circuitBreakerTransport{wrapped: wrapped, breaker: breaker}
+	}
+}
@@ -0,0 +1,270 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCircuitBreakerOpensAndProbes(t *testing.T) {
+	var clock = newFakeClock()
+	var breaker = NewCircuitBreaker(
+		CircuitBreakerOptionThreshold(2),
+		CircuitBreakerOptionOpenDuration(time.Second),
+		CircuitBreakerOptionClock(clock),
+	)
+	var collector = &eventCollector{}
+	var ctx = WithEventSubscriber(context.Background(), collector)
+
+	require.True(t, breaker.Allow(ctx, "host"))
+	breaker.Record(ctx, "host", true)
+	require.True(t, breaker.Allow(ctx, "host"))
+	breaker.Record(ctx, "host", false)
+	require.True(t, breaker.Allow(ctx, "host"))
+	breaker.Record(ctx, "host", true)
+	assert.Equal(t, CircuitClosed, breaker.State("host"))
+	require.True(t, breaker.Allow(ctx, "host"))
+	breaker.Record(ctx, "host", true)
+	assert.Equal(t, CircuitOpen, breaker.State("host"))
+	assert.Equal(t, []EventType{EventCircuitOpened}, collector.types())
+	assert.False(t, breaker.Allow(ctx, "host"))
+
+	clock.advance(time.Second)
+	assert.True(t, breaker.Allow(ctx, "host"))
+	assert.Equal(t, CircuitHalfOpen, breaker.State("host"))
+	assert.False(t, breaker.Allow(ctx, "host"), "only one probe is allowed")
+	breaker.Record(ctx, "host", true)
+	assert.Equal(t, CircuitOpen, breaker.State("host"))
+	assert.False(t, breaker.Allow(ctx, "host"))
+
+	clock.advance(time.Second)
+	assert.True(t, breaker.Allow(ctx, "host"))
+	breaker.Record(ctx, "host", false)
+	assert.Equal(t, CircuitClosed, breaker.State("host"))
+	assert.Equal(t, []string{"host"}, breaker.Keys())
+}
+
+func TestCircuitBreaking(t *testing.T) {
+	var calls int
+	var breaker = NewCircuitBreaker(CircuitBreakerOptionThreshold(1))
+	var rt = NewCircuitBreaking(breaker)(RoundTripperFunc(func(*http.Request) (*http.Response, error) {
+		calls = calls + 1
+		return &http.Response{StatusCode: http.StatusBadGateway, Body: http.NoBody}, nil
+	}))
+	var req, _ = http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	req = req.WithContext(WithAnnotations(req.Context()))
+
+	var resp, e = rt.RoundTrip(req)
+	require.NoError(t, e)
+	assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	var state, _ = Annotation(req.Context(), AnnotationBreakerState)
+	assert.Equal(t, "closed", state)
+
+	_, e = rt.RoundTrip(req)
+	assert.True(t, errors.Is(e, ErrCircuitOpen))
+	assert.Equal(t, http.StatusServiceUnavailable, ErrorToStatusCode(e))
+	assert.Equal(t, 1, calls)
+	state, _ = Annotation(req.Context(), AnnotationBreakerState)
+	assert.Equal(t, "open", state)
+}
+
+func TestCircuitBreakerSharedStore(t *testing.T) {
+	var clock = newFakeClock()
+	var store = NewMemoryCircuitStore()
+	var opts = []CircuitBreakerOption{
+		CircuitBreakerOptionThreshold(1),
+		CircuitBreakerOptionOpenDuration(time.Minute),
+		CircuitBreakerOptionClock(clock),
+		CircuitBreakerOptionStore(store, time.Second),
+	}
+	var first, second = NewCircuitBreaker(opts...), NewCircuitBreaker(opts...)
+	var ctx = context.Background()
+
+	require.True(t, second.Allow(ctx, "host"))
+	second.Record(ctx, "host", false)
+	require.True(t, first.Allow(ctx, "host"))
+	first.Record(ctx, "host", true)
+	var record, ok, _ = store.Load(ctx, "host")
+	require.True(t, ok)
+	assert.Equal(t, CircuitOpen, record.State)
+
+	// The second breaker read the store recently so keeps its own state until
+	// the sync interval passes.
+	assert.True(t, second.Allow(ctx, "host"))
+	second.Record(ctx, "host", false)
+	clock.advance(time.Second)
+	assert.False(t, second.Allow(ctx, "host"))
+	assert.Equal(t, CircuitOpen, second.State("host"))
+
+	// A probe that closes the circuit on one replica closes it on the others.
+	clock.advance(time.Minute)
+	require.True(t, second.Allow(ctx, "host"))
+	second.Record(ctx, "host", false)
+	assert.Equal(t, CircuitClosed, second.State("host"))
+	clock.advance(time.Second)
+	assert.True(t, first.Allow(ctx, "host"))
+	assert.Equal(t, CircuitClosed, first.State("host"))
+}
+
+type failingCircuitStore struct{}
+
+func (failingCircuitStore) Load(context.Context, string) (CircuitRecord, bool, error) {
+	return CircuitRecord{}, false, errors.New("unavailable")
+}
+
+func (failingCircuitStore) Save(context.Context, string, CircuitRecord) error {
+	return errors.New("unavailable")
+}
+
+func TestCircuitBreakerStoreFailure(t *testing.T) {
+	var breaker = NewCircuitBreaker(
+		CircuitBreakerOptionThreshold(1),
+		CircuitBreakerOptionStore(failingCircuitStore{}, 0),
+	)
+	var ctx = context.Background()
+	require.True(t, breaker.Allow(ctx, "host"))
+	breaker.Record(ctx, "host", true)
+	assert.False(t, breaker.Allow(ctx, "host"))
+}
+
+func TestCircuitStateString(t *testing.T) {
+	assert.Equal(t, "closed", CircuitClosed.String())
+	assert.Equal(t, "open", CircuitOpen.String())
+	assert.Equal(t, "half_open", CircuitHalfOpen.String())
+}
+
+func TestCircuitBreakerProbes(t *testing.T) {
+	var clock = newFakeClock()
+	var breaker = NewCircuitBreaker(
+		CircuitBreakerOptionThreshold(1),
+		CircuitBreakerOptionOpenDuration(time.Second),
+		CircuitBreakerOptionProbes(2),
+		CircuitBreakerOptionSuccesses(3),
+		CircuitBreakerOptionClock(clock),
+	)
+	var ctx = context.Background()
+	require.True(t, breaker.Allow(ctx, "host"))
+	breaker.Record(ctx, "host", true)
+	clock.advance(time.Second)
+
+	assert.True(t, breaker.Allow(ctx, "host"))
+	assert.True(t, breaker.Allow(ctx, "host"))
+	assert.False(t, breaker.Allow(ctx, "host"), "only two probes are allowed at once")
+	breaker.Record(ctx, "host", false)
+	breaker.Record(ctx, "host", false)
+	assert.Equal(t, CircuitHalfOpen, breaker.State("host"))
+	assert.True(t, breaker.Allow(ctx, "host"))
+	breaker.Record(ctx, "host", false)
+	assert.Equal(t, CircuitClosed, breaker.State("host"))
+
+	// A failed probe reopens the circuit and resets the successes.
+	require.True(t, breaker.Allow(ctx, "host"))
+	breaker.Record(ctx, "host", true)
+	clock.advance(time.Second)
+	require.True(t, breaker.Allow(ctx, "host"))
+	breaker.Record(ctx, "host", false)
+	require.True(t, breaker.Allow(ctx, "host"))
+	breaker.Record(ctx, "host", true)
+	assert.Equal(t, CircuitOpen, breaker.State("host"))
+	clock.advance(time.Second)
+	require.True(t, breaker.Allow(ctx, "host"))
+	breaker.Record(ctx, "host", false)
+	assert.Equal(t, CircuitHalfOpen, breaker.State("host"))
+}
+
+func TestCircuitBreakerOpenJitter(t *testing.T) {
+	var clock = newFakeClock()
+	var breaker = NewCircuitBreaker(
+		CircuitBreakerOptionThreshold(1),
+		CircuitBreakerOptionOpenDuration(10*time.Second),
+		CircuitBreakerOptionOpenJitter(5*time.Second),
+		CircuitBreakerOptionRandSource(rand.New(rand.NewSource(1))),
+		CircuitBreakerOptionClock(clock),
+	)
+	var ctx = context.Background()
+	var opened = map[time.Duration]bool{}
+	for x := 0; x < 20; x = x + 1 {
+		require.True(t, breaker.Allow(ctx, "host"))
+		breaker.Record(ctx, "host", true)
+		var waited time.Duration
+		for !breaker.Allow(ctx, "host") {
+			clock.advance(100 * time.Millisecond)
+			waited = waited + 100*time.Millisecond
+		}
+		assert.GreaterOrEqual(t, waited, 5*time.Second)
+		assert.LessOrEqual(t, waited, 15*time.Second)
+		opened[waited] = true
+		breaker.Record(ctx, "host", false)
+	}
+	assert.Greater(t, len(opened), 1)
+}
+
+func TestCircuitBreakingFallback(t *testing.T) {
+	var breaker = NewCircuitBreaker(
+		CircuitBreakerOptionThreshold(1),
+		CircuitBreakerOptionFallback(ServiceUnavailableFallback),
+	)
+	var rt = NewCircuitBreaking(breaker)(RoundTripperFunc(func(*http.Request) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	}))
+	var req, _ = http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	var _, e = rt.RoundTrip(req)
+	require.Error(t, e)
+
+	var resp, eFallback = rt.RoundTrip(req)
+	require.NoError(t, eFallback)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, req, resp.Request)
+	var body, _ = io.ReadAll(resp.Body)
+	assert.Equal(t, "transport: circuit breaker is open: example.com", string(body))
+	assert.Equal(t, int64(len(body)), resp.ContentLength)
+}
+
+func TestCircuitBreakingFallbackReceivesError(t *testing.T) {
+	var cached = &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}
+	var received error
+	var breaker = NewCircuitBreaker(
+		CircuitBreakerOptionThreshold(1),
+		CircuitBreakerOptionFallback(func(r *http.Request, e error) (*http.Response, error) {
+			received = e
+			return cached, nil
+		}),
+	)
+	var req, _ = http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	require.True(t, breaker.Allow(req.Context(), "example.com"))
+	breaker.Record(req.Context(), "example.com", true)
+
+	var rt = NewCircuitBreaking(breaker)(RoundTripperFunc(func(*http.Request) (*http.Response, error) {
+		t.Fatal("request sent while the circuit is open")
+		return nil, nil
+	}))
+	var resp, e = rt.RoundTrip(req)
+	require.NoError(t, e)
+	assert.Equal(t, cached, resp)
+	assert.True(t, errors.Is(received, ErrCircuitOpen))
+}
+
+func TestCircuitBreakingClosesRejectedBody(t *testing.T) {
+	var breaker = NewCircuitBreaker(CircuitBreakerOptionThreshold(1))
+	var rt = NewCircuitBreaking(breaker)(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		_ = r.Body.Close()
+		return &http.Response{StatusCode: http.StatusBadGateway, Body: http.NoBody}, nil
+	}))
+	var req, _ = http.NewRequest(http.MethodPost, "http://example.com/", strings.NewReader("first"))
+	var _, e = rt.RoundTrip(req)
+	require.NoError(t, e)
+
+	var body = &closeRecordingBody{Reader: strings.NewReader("payload")}
+	req, _ = http.NewRequest(http.MethodPost, "http://example.com/", body)
+	_, e = rt.RoundTrip(req)
+	assert.True(t, errors.Is(e, ErrCircuitOpen))
+	assert.True(t, body.closed, "rejected request body was not closed")
+}
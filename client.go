@@ -0,0 +1,120 @@
+package transport
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultClientTimeout         = 30 * time.Second
+	defaultResponseHeaderTimeout = 10 * time.Second
+	defaultMaxRedirects          = 10
+)
+
+// ErrRedirectDowngrade is returned when a redirect would move a request from
+// HTTPS to plaintext HTTP.
+var ErrRedirectDowngrade = errors.New("refusing to follow redirect from https to http")
+
+type clientSettings struct {
+	options       []Option
+	chain         Chain
+	timeout       time.Duration
+	checkRedirect func(*http.Request, []*http.Request) error
+	jar           http.CookieJar
+}
+
+// ClientOption is a configuration for the NewClient constructor.
+type ClientOption func(*clientSettings) *clientSettings
+
+// ClientOptionTransport appends Options that are applied to the underlying
+// Transport. These are applied after the client defaults and may override
+// them.
+func ClientOptionTransport(opts ...Option) ClientOption {
+	return func(c *clientSettings) *clientSettings {
+		c.options = append(c.options, opts...)
+		return c
+	}
+}
+
+// ClientOptionChain appends Decorators to the chain that wraps the
+// underlying Transport.
+func ClientOptionChain(decorators ...Decorator) ClientOption {
+	return func(c *clientSettings) *clientSettings {
+		c.chain = append(c.chain, decorators...)
+		return c
+	}
+}
+
+// ClientOptionTimeout sets the overall timeout for a request, including
+// reading the response body. A zero value disables the timeout.
+func ClientOptionTimeout(timeout time.Duration) ClientOption {
+	return func(c *clientSettings) *clientSettings {
+		c.timeout = timeout
+		return c
+	}
+}
+
+// ClientOptionCheckRedirect installs a custom redirect policy.
+func ClientOptionCheckRedirect(check func(*http.Request, []*http.Request) error) ClientOption {
+	return func(c *clientSettings) *clientSettings {
+		c.checkRedirect = check
+		return c
+	}
+}
+
+// ClientOptionCookieJar installs a cookie jar in the client.
+func ClientOptionCookieJar(jar http.CookieJar) ClientOption {
+	return func(c *clientSettings) *clientSettings {
+		c.jar = jar
+		return c
+	}
+}
+
+// NewSecureRedirectPolicy generates a redirect policy that follows up to max
+// redirects and refuses to downgrade from HTTPS to HTTP.
+func NewSecureRedirectPolicy(max int) func(*http.Request, []*http.Request) error {
+	return func(r *http.Request, via []*http.Request) error {
+		if len(via) >= max {
+			return fmt.Errorf("stopped after %d redirects", max)
+		}
+		if len(via) > 0 && via[len(via)-1].URL.Scheme == "https" && r.URL.Scheme != "https" {
+			return ErrRedirectDowngrade
+		}
+		return nil
+	}
+}
+
+// NewClient generates an http.Client with production oriented defaults. By
+// default, the client:
+//
+//   - Requires TLS 1.2 or higher.
+//   - Waits at most 10 seconds for response headers.
+//   - Limits the full request, including the body, to 30 seconds.
+//   - Follows up to 10 redirects and never downgrades from HTTPS to HTTP.
+//   - Removes credential headers from redirects that leave the registrable
+//     domain of the original request.
+//
+// Decorators given with ClientOptionChain are applied to the Transport in the
+// same way as Chain.Apply.
+func NewClient(opts ...ClientOption) *http.Client {
+	var c = &clientSettings{
+		options: []Option{
+			OptionTLSClientConfig(&tls.Config{MinVersion: tls.VersionTLS12}),
+			OptionResponseHeaderTimeout(defaultResponseHeaderTimeout),
+		},
+		timeout:       defaultClientTimeout,
+		checkRedirect: NewStripCredentials().CheckRedirect(NewSecureRedirectPolicy(defaultMaxRedirects)),
+	}
+	for _, opt := range opts {
+		c = opt(c)
+	}
+	return &http.Client{
+		Transport:     c.chain.Apply(New(c.options...)),
+		Timeout:       c.timeout,
+		CheckRedirect: c.checkRedirect,
+		Jar:           c.jar,
+	}
+}
@@ -0,0 +1,70 @@
+package transport
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewClientDefaults(t *testing.T) {
+	var client = NewClient()
+	assert.Equal(t, defaultClientTimeout, client.Timeout)
+	assert.NotNil(t, client.CheckRedirect)
+	assert.Nil(t, client.Jar)
+	var tr, ok = client.Transport.(*http.Transport)
+	require.True(t, ok)
+	assert.Equal(t, uint16(tls.VersionTLS12), tr.TLSClientConfig.MinVersion)
+	assert.Equal(t, defaultResponseHeaderTimeout, tr.ResponseHeaderTimeout)
+}
+
+func TestNewClientOptions(t *testing.T) {
+	var jar, _ = cookiejar.New(nil)
+	var decorated bool
+	var decorator = func(wrapped http.RoundTripper) http.RoundTripper {
+		decorated = true
+		return wrapped
+	}
+	var client = NewClient(
+		ClientOptionTimeout(time.Second),
+		ClientOptionCookieJar(jar),
+		ClientOptionCheckRedirect(nil),
+		ClientOptionTransport(OptionResponseHeaderTimeout(time.Millisecond)),
+		ClientOptionChain(decorator),
+	)
+	assert.Equal(t, time.Second, client.Timeout)
+	assert.Equal(t, jar, client.Jar)
+	assert.Nil(t, client.CheckRedirect)
+	assert.True(t, decorated)
+	assert.Equal(t, time.Millisecond, client.Transport.(*http.Transport).ResponseHeaderTimeout)
+}
+
+func TestSecureRedirectPolicy(t *testing.T) {
+	var policy = NewSecureRedirectPolicy(2)
+	var secure = &http.Request{URL: &url.URL{Scheme: "https", Host: "localhost"}}
+	var plain = &http.Request{URL: &url.URL{Scheme: "http", Host: "localhost"}}
+
+	assert.NoError(t, policy(secure, []*http.Request{plain}))
+	assert.NoError(t, policy(secure, []*http.Request{secure}))
+	assert.ErrorIs(t, policy(plain, []*http.Request{secure}), ErrRedirectDowngrade)
+	assert.Error(t, policy(secure, []*http.Request{secure, secure}))
+}
+
+func TestNewClientStripsCredentialsOnRedirect(t *testing.T) {
+	var client = NewClient()
+	var original, _ = http.NewRequest(http.MethodGet, "https://api.example.com/", nil)
+	var next, _ = http.NewRequest(http.MethodGet, "https://other.example.net/", nil)
+	next.Header.Set("Authorization", "Bearer token")
+	next.Header.Set("Cookie", "session=1")
+	require.NoError(t, client.CheckRedirect(next, []*http.Request{original}))
+	assert.Empty(t, next.Header.Get("Authorization"))
+	assert.Empty(t, next.Header.Get("Cookie"))
+
+	var plain, _ = http.NewRequest(http.MethodGet, "http://api.example.com/", nil)
+	assert.ErrorIs(t, client.CheckRedirect(plain, []*http.Request{original}), ErrRedirectDowngrade)
+}
@@ -0,0 +1,95 @@
+package transport
+
+import (
+	"context"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+)
+
+// ClientCertificateSource provides the client certificate presented in each
+// TLS handshake. Sources that return short lived certificates, such as those
+// issued by a service mesh, let connections made after a rotation use the new
+// certificate without rebuilding the transport.
+type ClientCertificateSource interface {
+	ClientCertificate(ctx context.Context) (*tls.Certificate, error)
+}
+
+// ClientCertificateSourceFunc converts a function to a
+// ClientCertificateSource.
+type ClientCertificateSourceFunc func(ctx context.Context) (*tls.Certificate, error)
+
+// ClientCertificate calls the wrapped function.
+func (f ClientCertificateSourceFunc) ClientCertificate(ctx context.Context) (*tls.Certificate, error) {
+	return f(ctx)
+}
+
+// OptionClientCertificateSource installs a GetClientCertificate function in
+// the TLSClientConfig of the Transport that asks the source for the
+// certificate of every new connection. Existing connections keep the
+// certificate they were established with, so the option pairs well with a
+// Recycler whose TTL is shorter than the certificate lifetime. Apply it after
+// OptionTLSClientConfig.
+func OptionClientCertificateSource(source ClientCertificateSource) Option {
+	return func(t *http.Transport) *http.Transport {
+		var config = t.TLSClientConfig.Clone()
+		if config == nil {
+			config = &tls.Config{}
+		}
+		config.GetClientCertificate = func(info *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return source.ClientCertificate(info.Context())
+		}
+		t.TLSClientConfig = config
+		return t
+	}
+}
+
+// ErrNotSPIFFEID is returned by a SPIFFE certificate source when the
+// certificate it receives does not contain a SPIFFE ID.
+var ErrNotSPIFFEID = errors.New("transport: certificate does not contain a SPIFFE ID")
+
+// SPIFFESVIDFetcher returns the current X.509 SVID of the workload as its
+// certificate chain, leaf first, and private key. This package does not
+// include a Workload API client. The X509Source of go-spiffe, which watches
+// the Workload API socket and keeps the SVID rotated in memory, can be
+// adapted with:
+//
+//	func(context.Context) ([]*x509.Certificate, crypto.Signer, error) {
+//		svid, err := source.GetX509SVID()
+//		if err != nil {
+//			return nil, nil, err
+//		}
+//		return svid.Certificates, svid.PrivateKey, nil
+//	}
+type SPIFFESVIDFetcher func(ctx context.Context) ([]*x509.Certificate, crypto.Signer, error)
+
+// NewSPIFFECertificateSource creates a ClientCertificateSource that presents
+// the current X.509 SVID of the workload. The SVID is fetched for every new
+// connection so rotations by the Workload API take effect immediately.
+func NewSPIFFECertificateSource(fetch SPIFFESVIDFetcher) ClientCertificateSource {
+	return ClientCertificateSourceFunc(func(ctx context.Context) (*tls.Certificate, error) {
+		var certificates, key, e = fetch(ctx)
+		if e != nil {
+			return nil, e
+		}
+		if len(certificates) < 1 || !hasSPIFFEID(certificates[0]) {
+			return nil, ErrNotSPIFFEID
+		}
+		var certificate = &tls.Certificate{PrivateKey: key, Leaf: certificates[0]}
+		for _, c := range certificates {
+			certificate.Certificate = append(certificate.Certificate, c.Raw)
+		}
+		return certificate, nil
+	})
+}
+
+func hasSPIFFEID(c *x509.Certificate) bool {
+	for _, u := range c.URIs {
+		if u.Scheme == "spiffe" && u.Host != "" {
+			return true
+		}
+	}
+	return false
+}
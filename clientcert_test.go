@@ -0,0 +1,89 @@
+package transport
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestCertificate creates a self-signed certificate valid for the given
+// lifetime and returns it along with its private key.
+func newTestCertificate(t *testing.T, uri string, lifetime time.Duration) (*x509.Certificate, crypto.Signer) {
+	var key, e = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, e)
+	var template = &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(lifetime),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if uri != "" {
+		var u, _ = url.Parse(uri)
+		template.URIs = []*url.URL{u}
+	}
+	var der []byte
+	der, e = x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	require.NoError(t, e)
+	var certificate *x509.Certificate
+	certificate, e = x509.ParseCertificate(der)
+	require.NoError(t, e)
+	return certificate, key
+}
+
+func TestOptionClientCertificateSourceSPIFFE(t *testing.T) {
+	var svid, key = newTestCertificate(t, "spiffe://example.org/service", time.Hour)
+	var server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.TLS.PeerCertificates[0].URIs[0].String()))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	var fetches int
+	var tr = New(
+		OptionTLSClientConfig(server.Client().Transport.(*http.Transport).TLSClientConfig),
+		OptionClientCertificateSource(NewSPIFFECertificateSource(func(context.Context) ([]*x509.Certificate, crypto.Signer, error) {
+			fetches = fetches + 1
+			return []*x509.Certificate{svid}, key, nil
+		})),
+	)
+	defer tr.CloseIdleConnections()
+	var req, _ = http.NewRequest(http.MethodGet, server.URL, nil)
+	var resp, e = tr.RoundTrip(req)
+	require.NoError(t, e)
+	defer resp.Body.Close()
+	var body = make([]byte, 64)
+	var n, _ = resp.Body.Read(body)
+	assert.Equal(t, "spiffe://example.org/service", string(body[:n]))
+	assert.Equal(t, 1, fetches)
+}
+
+func TestSPIFFECertificateSourceErrors(t *testing.T) {
+	var plain, key = newTestCertificate(t, "", time.Hour)
+	var _, e = NewSPIFFECertificateSource(func(context.Context) ([]*x509.Certificate, crypto.Signer, error) {
+		return []*x509.Certificate{plain}, key, nil
+	}).ClientCertificate(context.Background())
+	assert.ErrorIs(t, e, ErrNotSPIFFEID)
+
+	var unavailable = errors.New("workload api unavailable")
+	_, e = NewSPIFFECertificateSource(func(context.Context) ([]*x509.Certificate, crypto.Signer, error) {
+		return nil, nil, unavailable
+	}).ClientCertificate(context.Background())
+	assert.ErrorIs(t, e, unavailable)
+}
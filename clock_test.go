@@ -0,0 +1,130 @@
+package transport
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClock is a Clock that never sleeps. Every timer fires immediately and
+// advances the clock by its duration so that code under test observes the
+// time it would have waited.
+type fakeClock struct {
+	lock  sync.Mutex
+	now   time.Time
+	waits []time.Duration
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(0, 0)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	return c.NewTimer(d).C()
+}
+
+func (c *fakeClock) NewTimer(d time.Duration) Timer {
+	var t = &fakeTimer{clock: c, c: make(chan time.Time, 1)}
+	t.Reset(d)
+	return t
+}
+
+func (c *fakeClock) advance(d time.Duration) time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.waits = append(c.waits, d)
+	c.now = c.now.Add(d)
+	return c.now
+}
+
+func (c *fakeClock) recorded() []time.Duration {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]time.Duration(nil), c.waits...)
+}
+
+type fakeTimer struct {
+	clock *fakeClock
+	c     chan time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	select {
+	case <-t.c:
+		return true
+	default:
+		return false
+	}
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	var active = t.Stop()
+	t.c <- t.clock.advance(d)
+	return active
+}
+
+func TestSystemClock(t *testing.T) {
+	var clock = NewSystemClock()
+	var before = time.Now()
+	assert.False(t, clock.Now().Before(before))
+
+	var timer = clock.NewTimer(time.Hour)
+	assert.True(t, timer.Stop())
+	assert.False(t, timer.Reset(time.Millisecond))
+	<-timer.C()
+	<-clock.After(time.Millisecond)
+}
+
+func TestRetryUsesClock(t *testing.T) {
+	var calls int
+	var wrapped = RoundTripperFunc(func(*http.Request) (*http.Response, error) {
+		calls = calls + 1
+		if calls < 3 {
+			return &http.Response{StatusCode: http.StatusServiceUnavailable, Body: http.NoBody}, nil
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	})
+	var clock = newFakeClock()
+	var rt = NewRetrierWithOptions(
+		NewFixedBackoffPolicy(time.Hour),
+		[]RetryPolicy{NewStatusCodeRetryPolicy(http.StatusServiceUnavailable)},
+		RetryOptionClock(clock),
+	)(wrapped)
+	var req, _ = http.NewRequest(http.MethodGet, "/", nil)
+	var resp, e = rt.RoundTrip(req)
+	assert.NoError(t, e)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, []time.Duration{time.Hour, time.Hour}, clock.recorded())
+}
+
+func TestRecyclerUsesClock(t *testing.T) {
+	var generations int
+	var factory = func() http.RoundTripper {
+		generations = generations + 1
+		return RoundTripperFunc(func(*http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+		})
+	}
+	var clock = newFakeClock()
+	var r = NewRecycler(factory, RecycleOptionTTL(time.Minute), RecycleOptionClock(clock))
+	_ = r.getTransport()
+	assert.Equal(t, 2, generations)
+	_ = r.getTransport()
+	assert.Equal(t, 2, generations)
+	clock.advance(2 * time.Minute)
+	_ = r.getTransport()
+	assert.Equal(t, 3, generations)
+}
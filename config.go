@@ -0,0 +1,399 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	// BackoffTypeFixed selects the FixedBackoffer.
+	BackoffTypeFixed = "fixed"
+	// BackoffTypeExponential selects the ExponentialBackoffer.
+	BackoffTypeExponential = "exponential"
+)
+
+// BackoffConfig describes a BackoffPolicy.
+type BackoffConfig struct {
+	Type   string        `description:"Backoff strategy. One of fixed or exponential."`
+	Wait   time.Duration `description:"Base duration to wait between attempts."`
+	Jitter float64       `description:"Percentage of jitter, between 0 and 1, to apply to the backoff. Zero disables jitter."`
+}
+
+// Name of the configuration root.
+func (*BackoffConfig) Name() string {
+	return "backoff"
+}
+
+// Policy renders the BackoffPolicy described by the configuration. A nil
+// configuration renders a policy with no delay.
+func (c *BackoffConfig) Policy() (BackoffPolicy, error) {
+	if c == nil {
+		return NewFixedBackoffPolicy(0), nil
+	}
+	var policy BackoffPolicy
+	switch c.Type {
+	case BackoffTypeFixed, "":
+		policy = NewFixedBackoffPolicy(c.Wait)
+	case BackoffTypeExponential:
+		policy = NewExponentialBackoffPolicy(c.Wait)
+	default:
+		return nil, fmt.Errorf("unknown backoff type %q", c.Type)
+	}
+	if c.Jitter > 0 {
+		policy = NewPercentJitteredBackoffPolicy(policy, c.Jitter)
+	}
+	return policy, nil
+}
+
+// RetryConfig describes a Retry decorator.
+type RetryConfig struct {
+	Enabled bool           `description:"Enable the retry decorator."`
+	Limit   int            `description:"Maximum number of retries for a single request."`
+	Codes   []int          `description:"Response status codes that trigger a retry."`
+	Timeout time.Duration  `description:"Per-attempt timeout that triggers a retry. Zero disables timeout retries."`
+	Overall time.Duration  `description:"Timeout for the request across all attempts and the waits between them. Zero means no limit."`
+	Backoff *BackoffConfig `description:"Delay between retries."`
+}
+
+// Name of the configuration root.
+func (*RetryConfig) Name() string {
+	return "retry"
+}
+
+// Decorator renders the Retry decorator described by the configuration.
+func (c *RetryConfig) Decorator() (Decorator, error) {
+	var backoff, e = c.Backoff.Policy()
+	if e != nil {
+		return nil, e
+	}
+	var policies []RetryPolicy
+	if len(c.Codes) > 0 {
+		policies = append(policies, NewStatusCodeRetryPolicy(c.Codes...))
+	}
+	if c.Timeout > 0 {
+		policies = append(policies, NewAttemptTimeoutRetryPolicy())
+	}
+	return NewRetrierWithOptions(backoff, []RetryPolicy{NewLimitedRetryPolicy(c.Limit, policies...)}, RetryOptionTimeouts(c.Timeout, c.Overall)), nil
+}
+
+// RetryAfterConfig describes a RetryAfter decorator.
+type RetryAfterConfig struct {
+	Enabled bool `description:"Enable retries of 429 responses using the Retry-After header."`
+}
+
+// Name of the configuration root.
+func (*RetryAfterConfig) Name() string {
+	return "retryafter"
+}
+
+// HedgeConfig describes a Hedger decorator.
+type HedgeConfig struct {
+	Enabled bool           `description:"Enable the hedging decorator."`
+	Backoff *BackoffConfig `description:"Delay between hedged requests."`
+}
+
+// Name of the configuration root.
+func (*HedgeConfig) Name() string {
+	return "hedge"
+}
+
+// Decorator renders the Hedger decorator described by the configuration.
+func (c *HedgeConfig) Decorator() (Decorator, error) {
+	var backoff, e = c.Backoff.Policy()
+	if e != nil {
+		return nil, e
+	}
+	return NewHedger(backoff), nil
+}
+
+// BreakerConfig describes a CircuitBreaker decorator. Zero values select the
+// defaults of the CircuitBreaker.
+type BreakerConfig struct {
+	Enabled      bool          `description:"Enable the circuit breaker decorator."`
+	Threshold    int           `description:"Number of consecutive failures that open a circuit."`
+	OpenDuration time.Duration `description:"Amount of time an open circuit rejects requests before probing."`
+	OpenJitter   time.Duration `description:"Random amount of time, plus or minus, added to the open duration."`
+	Probes       int           `description:"Number of probe requests a half-open circuit allows at the same time."`
+	Successes    int           `description:"Number of consecutive successful probes that close a circuit."`
+
+	breaker *CircuitBreaker
+}
+
+// Name of the configuration root.
+func (*BreakerConfig) Name() string {
+	return "breaker"
+}
+
+func (c *BreakerConfig) options() []CircuitBreakerOption {
+	var threshold = c.Threshold
+	if threshold == 0 {
+		threshold = defaultBreakerThreshold
+	}
+	var openFor = c.OpenDuration
+	if openFor == 0 {
+		openFor = defaultBreakerOpenFor
+	}
+	return []CircuitBreakerOption{
+		CircuitBreakerOptionThreshold(threshold),
+		CircuitBreakerOptionOpenDuration(openFor),
+		CircuitBreakerOptionOpenJitter(c.OpenJitter),
+		CircuitBreakerOptionProbes(c.Probes),
+		CircuitBreakerOptionSuccesses(c.Successes),
+	}
+}
+
+// Breaker returns the CircuitBreaker that the chain uses, creating it on
+// first use, so that the service can inspect its circuits.
+func (c *BreakerConfig) Breaker() *CircuitBreaker {
+	if c.breaker == nil {
+		c.breaker = NewCircuitBreaker(c.options()...)
+	}
+	return c.breaker
+}
+
+// Decorator renders the CircuitBreaker decorator described by the
+// configuration.
+func (c *BreakerConfig) Decorator() Decorator {
+	return NewCircuitBreaking(c.Breaker())
+}
+
+// QuotaConfig describes a QuotaThrottle decorator.
+type QuotaConfig struct {
+	Enabled   bool          `description:"Enable pacing of requests under the rate limits reported by upstreams."`
+	MaxWait   time.Duration `description:"Maximum amount of time a request waits for its quota. Zero means no limit."`
+	PaceBelow int           `description:"Number of remaining requests under which requests are paced. Zero paces every request."`
+
+	throttle *QuotaThrottle
+}
+
+// Name of the configuration root.
+func (*QuotaConfig) Name() string {
+	return "quota"
+}
+
+func (c *QuotaConfig) options() []QuotaThrottleOption {
+	return []QuotaThrottleOption{QuotaThrottleOptionMaxWait(c.MaxWait), QuotaThrottleOptionPaceBelow(c.PaceBelow)}
+}
+
+// Throttle returns the QuotaThrottle that the chain uses, creating it on
+// first use, so that the service can inspect the quotas it has learned.
+func (c *QuotaConfig) Throttle() *QuotaThrottle {
+	if c.throttle == nil {
+		c.throttle = NewQuotaThrottle(c.options()...)
+	}
+	return c.throttle
+}
+
+// Decorator renders the QuotaThrottle decorator described by the
+// configuration.
+func (c *QuotaConfig) Decorator() Decorator {
+	return NewQuotaThrottling(c.Throttle())
+}
+
+// HeaderConfig describes a set of static request headers.
+type HeaderConfig struct {
+	Values map[string]string `description:"Static headers to add to every outgoing request."`
+}
+
+// Name of the configuration root.
+func (*HeaderConfig) Name() string {
+	return "headers"
+}
+
+// Decorator renders a decorator that sets all of the configured headers.
+func (c *HeaderConfig) Decorator() Decorator {
+	var headers = make(http.Header, len(c.Values))
+	for name, value := range c.Values {
+		headers.Set(name, value)
+	}
+	return func(wrapped http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			r = r.Clone(r.Context())
+			for name, values := range headers {
+				r.Header[name] = append([]string(nil), values...)
+			}
+			return wrapped.RoundTrip(r)
+		})
+	}
+}
+
+// MetricsConfig describes latency histograms recorded for every request and
+// the counters published with expvar.
+type MetricsConfig struct {
+	Enabled bool          `description:"Enable latency histograms for every outgoing request."`
+	Window  time.Duration `description:"Rolling window covered by the latency histograms."`
+	Tag     string        `description:"Tag, added with WithTags, that latencies are grouped by. Empty groups them by host."`
+	Expvar  string        `description:"Name under which request, retry, hedge, and breaker counters are published with expvar. Empty disables publication."`
+
+	recorder *LatencyRecorder
+}
+
+// Name of the configuration root.
+func (*MetricsConfig) Name() string {
+	return "metrics"
+}
+
+// Recorder returns the LatencyRecorder that the chain records latencies in,
+// creating it on first use, so that the service can read or export them.
+func (c *MetricsConfig) Recorder() *LatencyRecorder {
+	if c.recorder == nil {
+		var opts []LatencyRecorderOption
+		if c.Window > 0 {
+			opts = append(opts, LatencyRecorderOptionWindow(c.Window))
+		}
+		if c.Tag != "" {
+			opts = append(opts, LatencyRecorderOptionKey(LatencyKeyTag(c.Tag)))
+		}
+		c.recorder = NewLatencyRecorder(opts...)
+	}
+	return c.recorder
+}
+
+// AccessLogConfig describes an access log decorator.
+type AccessLogConfig struct {
+	Enabled bool `description:"Enable access logs for every outgoing request."`
+}
+
+// Name of the configuration root.
+func (*AccessLogConfig) Name() string {
+	return "accesslog"
+}
+
+// ChainConfig declaratively describes a decorated transport. Decorators
+// are always assembled in the same order, from outermost to innermost:
+// access log, metrics, expvar counters, headers, retry-after, retry, hedging,
+// quota throttling, circuit breaking, and then any extensions resolved from
+// the DefaultRegistry in the order they are listed.
+type ChainConfig struct {
+	MaxIdleConns          int               `description:"Maximum number of idle connections across all hosts."`
+	MaxIdleConnsPerHost   int               `description:"Maximum number of idle connections per host."`
+	IdleConnTimeout       time.Duration     `description:"Maximum amount of time an idle connection remains open."`
+	ResponseHeaderTimeout time.Duration     `description:"Maximum amount of time to wait for response headers. Zero means no limit."`
+	AccessLog             *AccessLogConfig  `description:"Access log settings."`
+	Metrics               *MetricsConfig    `description:"Latency metrics settings."`
+	Headers               *HeaderConfig     `description:"Static request header settings."`
+	RetryAfter            *RetryAfterConfig `description:"Retry-After settings."`
+	Retry                 *RetryConfig      `description:"Retry settings."`
+	Hedge                 *HedgeConfig      `description:"Hedging settings."`
+	Quota                 *QuotaConfig      `description:"Rate limit quota settings."`
+	Breaker               *BreakerConfig    `description:"Circuit breaker settings."`
+	Extensions            []ExtensionConfig `description:"Registered decorators to append to the chain."`
+}
+
+// Name of the configuration root.
+func (*ChainConfig) Name() string {
+	return "transport"
+}
+
+// Chain renders the decorators described by the configuration.
+func (c *ChainConfig) Chain() (Chain, error) {
+	var chain Chain
+	if c.AccessLog != nil && c.AccessLog.Enabled {
+		chain = append(chain, NewAccessLog())
+	}
+	if c.Metrics != nil && c.Metrics.Enabled {
+		chain = append(chain, NewLatencyRecording(c.Metrics.Recorder()))
+	}
+	if c.Metrics != nil && c.Metrics.Expvar != "" {
+		chain = append(chain, NewExpvarCounting(NewExpvarCounters(c.Metrics.Expvar)))
+	}
+	if c.Headers != nil && len(c.Headers.Values) > 0 {
+		chain = append(chain, c.Headers.Decorator())
+	}
+	if c.RetryAfter != nil && c.RetryAfter.Enabled {
+		chain = append(chain, NewRetryAfter())
+	}
+	if c.Retry != nil && c.Retry.Enabled {
+		var d, e = c.Retry.Decorator()
+		if e != nil {
+			return nil, fmt.Errorf("retry: %w", e)
+		}
+		chain = append(chain, d)
+	}
+	if c.Hedge != nil && c.Hedge.Enabled {
+		var d, e = c.Hedge.Decorator()
+		if e != nil {
+			return nil, fmt.Errorf("hedge: %w", e)
+		}
+		chain = append(chain, d)
+	}
+	if c.Quota != nil && c.Quota.Enabled {
+		chain = append(chain, c.Quota.Decorator())
+	}
+	if c.Breaker != nil && c.Breaker.Enabled {
+		chain = append(chain, c.Breaker.Decorator())
+	}
+	for _, ext := range c.Extensions {
+		var d, e = DefaultRegistry.Build(ext.Name, ext.Unmarshal)
+		if e != nil {
+			return nil, e
+		}
+		chain = append(chain, d)
+	}
+	return chain, nil
+}
+
+// Options renders the transport Options described by the configuration.
+func (c *ChainConfig) Options() []Option {
+	var opts []Option
+	if c.MaxIdleConns > 0 {
+		opts = append(opts, OptionMaxIdleConns(c.MaxIdleConns))
+	}
+	if c.MaxIdleConnsPerHost > 0 {
+		opts = append(opts, OptionMaxIdleConnsPerHost(c.MaxIdleConnsPerHost))
+	}
+	if c.IdleConnTimeout > 0 {
+		opts = append(opts, OptionIdleConnTimeout(c.IdleConnTimeout))
+	}
+	if c.ResponseHeaderTimeout > 0 {
+		opts = append(opts, OptionResponseHeaderTimeout(c.ResponseHeaderTimeout))
+	}
+	return opts
+}
+
+// ChainComponent implements the settings.Component interface for a
+// configuration driven, decorated transport Factory.
+type ChainComponent struct{}
+
+// NewChainComponent populates the default values.
+func NewChainComponent() *ChainComponent {
+	return &ChainComponent{}
+}
+
+// Settings generates a config populated with default values.
+func (*ChainComponent) Settings() *ChainConfig {
+	return &ChainConfig{
+		MaxIdleConns:    100,
+		IdleConnTimeout: 90 * time.Second,
+		AccessLog:       &AccessLogConfig{},
+		Metrics:         &MetricsConfig{Window: time.Minute},
+		Headers:         &HeaderConfig{},
+		RetryAfter:      &RetryAfterConfig{},
+		Retry: &RetryConfig{
+			Limit:   3,
+			Codes:   []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+			Backoff: &BackoffConfig{Type: BackoffTypeFixed, Wait: 50 * time.Millisecond, Jitter: .2},
+		},
+		Hedge: &HedgeConfig{
+			Backoff: &BackoffConfig{Type: BackoffTypeFixed, Wait: 50 * time.Millisecond},
+		},
+		Quota: &QuotaConfig{},
+		Breaker: &BreakerConfig{
+			Threshold:    defaultBreakerThreshold,
+			OpenDuration: defaultBreakerOpenFor,
+			Probes:       1,
+			Successes:    1,
+		},
+	}
+}
+
+// New constructs a Factory from a config.
+func (*ChainComponent) New(_ context.Context, conf *ChainConfig) (Factory, error) {
+	var chain, e = conf.Chain()
+	if e != nil {
+		return nil, e
+	}
+	return chain.ApplyFactory(NewFactory(conf.Options()...)), nil
+}
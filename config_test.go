@@ -0,0 +1,114 @@
+package transport
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackoffConfigPolicy(t *testing.T) {
+	var conf *BackoffConfig
+	var policy, e = conf.Policy()
+	require.NoError(t, e)
+	assert.Equal(t, time.Duration(0), policy().Backoff(nil, nil, nil))
+
+	conf = &BackoffConfig{Type: BackoffTypeExponential, Wait: time.Millisecond}
+	policy, e = conf.Policy()
+	require.NoError(t, e)
+	var b = policy()
+	assert.Equal(t, time.Millisecond, b.Backoff(nil, nil, nil))
+	assert.Equal(t, 2*time.Millisecond, b.Backoff(nil, nil, nil))
+
+	conf = &BackoffConfig{Type: BackoffTypeFixed, Wait: time.Millisecond, Jitter: .5}
+	policy, e = conf.Policy()
+	require.NoError(t, e)
+	assert.IsType(t, &PercentJitteredBackoffer{}, policy())
+
+	conf = &BackoffConfig{Type: "unknown"}
+	_, e = conf.Policy()
+	assert.Error(t, e)
+}
+
+func TestChainConfigChain(t *testing.T) {
+	var conf = NewChainComponent().Settings()
+	var chain, e = conf.Chain()
+	require.NoError(t, e)
+	assert.Empty(t, chain)
+
+	conf.AccessLog.Enabled = true
+	conf.Headers.Values = map[string]string{"x-key": "value"}
+	conf.RetryAfter.Enabled = true
+	conf.Retry.Enabled = true
+	conf.Hedge.Enabled = true
+	conf.Metrics.Enabled = true
+	conf.Quota.Enabled = true
+	conf.Breaker.Enabled = true
+	chain, e = conf.Chain()
+	require.NoError(t, e)
+	assert.Len(t, chain, 8)
+
+	conf.Retry.Backoff.Type = "unknown"
+	_, e = conf.Chain()
+	assert.Error(t, e)
+}
+
+func TestChainComponentNew(t *testing.T) {
+	var cmp = NewChainComponent()
+	var conf = cmp.Settings()
+	conf.Headers.Values = map[string]string{"x-key": "value"}
+	var factory, e = cmp.New(context.Background(), conf)
+	require.NoError(t, e)
+	var rt = factory()
+	assert.NotNil(t, rt)
+
+	var seen string
+	var base = RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		seen = r.Header.Get("X-Key")
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	})
+	chain, e := conf.Chain()
+	require.NoError(t, e)
+	var req, _ = http.NewRequest(http.MethodGet, "/", nil)
+	_, _ = chain.Apply(base).RoundTrip(req)
+	assert.Equal(t, "value", seen)
+}
+
+func TestHeaderConfigDecorator(t *testing.T) {
+	var conf = &HeaderConfig{Values: map[string]string{"x-key": "value"}}
+	var rt = conf.Decorator()(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		r.Header["X-Key"][0] = "modified"
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	}))
+	for x := 0; x < 2; x = x + 1 {
+		var req, _ = http.NewRequest(http.MethodGet, "/", nil)
+		_, _ = rt.RoundTrip(req)
+		assert.Empty(t, req.Header.Get("X-Key"), "request was modified")
+	}
+	var seen string
+	rt = conf.Decorator()(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		seen = r.Header.Get("X-Key")
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	}))
+	var req, _ = http.NewRequest(http.MethodGet, "/", nil)
+	_, _ = rt.RoundTrip(req)
+	assert.Equal(t, "value", seen)
+}
+
+func TestMetricsConfig(t *testing.T) {
+	var conf = NewChainComponent().Settings()
+	conf.Metrics.Enabled = true
+	conf.Metrics.Tag = "operation"
+	var chain, e = conf.Chain()
+	require.NoError(t, e)
+	var rt = chain.Apply(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	}))
+	var req, _ = http.NewRequest(http.MethodGet, "/", nil)
+	req = req.WithContext(WithTags(req.Context(), map[string]string{"operation": "get-item"}))
+	_, _ = rt.RoundTrip(req)
+	assert.Equal(t, []string{"get-item"}, conf.Metrics.Recorder().Keys())
+}
@@ -0,0 +1,160 @@
+package transport
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"syscall"
+)
+
+// The HTTP/2 errors are matched by the package path and name of their
+// concrete types because the implementation bundled with net/http does not
+// export them and its package has changed between Go releases. Those of
+// golang.org/x/net are listed for clients that configure it directly.
+var (
+	goAwayErrorTypes = map[string]bool{
+		"net/http.http2GoAwayError":           true,
+		"net/http/internal/http2.GoAwayError": true,
+		"golang.org/x/net/http2.GoAwayError":  true,
+	}
+	connectionErrorTypes = map[string]bool{
+		"net/http.http2ConnectionError":           true,
+		"net/http/internal/http2.ConnectionError": true,
+		"golang.org/x/net/http2.ConnectionError":  true,
+	}
+	streamErrorTypes = map[string]bool{
+		"net/http.http2StreamError":           true,
+		"net/http/internal/http2.StreamError": true,
+		"golang.org/x/net/http2.StreamError":  true,
+	}
+)
+
+// errorTypeName returns the package path and name of the concrete type of
+// the error, such as net.OpError.
+func errorTypeName(e error) string {
+	var t = reflect.TypeOf(e)
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.PkgPath() + "." + t.Name()
+}
+
+// anyError reports whether match is true for the error or any error that it
+// wraps, following every branch of errors combined with errors.Join.
+func anyError(e error, match func(error) bool) bool {
+	for e != nil {
+		if match(e) {
+			return true
+		}
+		switch wrapped := e.(type) {
+		case interface{ Unwrap() []error }:
+			for _, branch := range wrapped.Unwrap() {
+				if anyError(branch, match) {
+					return true
+				}
+			}
+			return false
+		case interface{ Unwrap() error }:
+			e = wrapped.Unwrap()
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// connectionLostMessages identify errors raised by the standard library when
+// a connection is closed by the server, often while idle, before or during a
+// request. Several of these are created with errors.New or lose their type
+// when formatted so they are matched by message.
+var connectionLostMessages = []string{
+	"server closed idle connection",
+	"http2: server sent GOAWAY",
+	"http2: client connection lost",
+	"http2: client connection force closed",
+	"stream error: stream ID",
+}
+
+// IsConnectionLostError reports whether the error was caused by the
+// connection being reset or closed by the server, including HTTP/2 GOAWAY
+// frames and stream errors. These errors are usually safe to retry once
+// because the request was rejected or never reached the server.
+func IsConnectionLostError(e error) bool {
+	if e == nil {
+		return false
+	}
+	if errors.Is(e, syscall.ECONNRESET) {
+		return true
+	}
+	var typed = anyError(e, func(current error) bool {
+		var name = errorTypeName(current)
+		return goAwayErrorTypes[name] || streamErrorTypes[name]
+	})
+	if typed {
+		return true
+	}
+	var message = e.Error()
+	for _, lost := range connectionLostMessages {
+		if strings.Contains(message, lost) {
+			return true
+		}
+	}
+	return false
+}
+
+// connectionProtocolMessages identify HTTP/2 errors that affect an entire
+// connection rather than a single request.
+var connectionProtocolMessages = []string{
+	"http2: server sent GOAWAY",
+	"connection error: ",
+	"http2: client connection lost",
+}
+
+// IsConnectionProtocolError reports whether the error was caused by an HTTP/2
+// GOAWAY frame or another connection level protocol error. Unlike the errors
+// matched by IsConnectionLostError, these indicate a problem with the
+// connection that does not resolve on its own if they repeat.
+func IsConnectionProtocolError(e error) bool {
+	if e == nil {
+		return false
+	}
+	var typed = anyError(e, func(current error) bool {
+		var name = errorTypeName(current)
+		return goAwayErrorTypes[name] || connectionErrorTypes[name]
+	})
+	if typed {
+		return true
+	}
+	var message = e.Error()
+	for _, protocol := range connectionProtocolMessages {
+		if strings.Contains(message, protocol) {
+			return true
+		}
+	}
+	return false
+}
+
+// ConnectionLostRetrier retries a request once if it failed because the
+// connection was lost, as reported by IsConnectionLostError.
+type ConnectionLostRetrier struct {
+	retried bool
+}
+
+// NewConnectionLostRetryPolicy generates a RetryPolicy that retries each
+// request at most once when its connection is reset or closed by the server.
+func NewConnectionLostRetryPolicy() RetryPolicy {
+	return func() Retrier {
+		return &ConnectionLostRetrier{}
+	}
+}
+
+// Retry the request if the connection was lost and it has not already been
+// retried for that reason.
+func (r *ConnectionLostRetrier) Retry(req *http.Request, resp *http.Response, e error) bool {
+	if r.retried || !IsConnectionLostError(e) {
+		return false
+	}
+	r.retried = true
+	return true
+}
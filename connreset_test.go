@@ -0,0 +1,97 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeGoAwayError struct{}
+
+func (fakeGoAwayError) Error() string {
+	return "goaway"
+}
+
+type fakeConnectionError uint32
+
+func (fakeConnectionError) Error() string {
+	return "protocol"
+}
+
+// registerFakeHTTP2Errors matches the fake errors as if they were the HTTP/2
+// errors of the standard library, which cannot be created outside of it.
+func registerFakeHTTP2Errors(t *testing.T) {
+	var goAway, connection = errorTypeName(fakeGoAwayError{}), errorTypeName(fakeConnectionError(1))
+	goAwayErrorTypes[goAway] = true
+	connectionErrorTypes[connection] = true
+	t.Cleanup(func() {
+		delete(goAwayErrorTypes, goAway)
+		delete(connectionErrorTypes, connection)
+	})
+}
+
+func TestErrorTypeName(t *testing.T) {
+	assert.Equal(t, "net.OpError", errorTypeName(&net.OpError{}))
+	assert.Equal(t, "net/url.Error", errorTypeName(&url.Error{}))
+	assert.Equal(t, "github.com/asecurityteam/transport.fakeGoAwayError", errorTypeName(fakeGoAwayError{}))
+}
+
+func TestIsConnectionLostError(t *testing.T) {
+	assert.False(t, IsConnectionLostError(fakeGoAwayError{}), "matched an unknown type by its name")
+	registerFakeHTTP2Errors(t)
+	var reset = &url.Error{Op: "Get", URL: "http://localhost", Err: &net.OpError{
+		Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET),
+	}}
+	assert.True(t, IsConnectionLostError(reset))
+	assert.True(t, IsConnectionLostError(errors.New("http: server closed idle connection")))
+	assert.True(t, IsConnectionLostError(errors.New(`http2: server sent GOAWAY and closed the connection; LastStreamID=1, ErrCode=NO_ERROR, debug=""`)))
+	assert.True(t, IsConnectionLostError(errors.New("stream error: stream ID 3; REFUSED_STREAM")))
+	assert.True(t, IsConnectionLostError(fmt.Errorf("wrapped: %w", fakeGoAwayError{})))
+	assert.False(t, IsConnectionLostError(nil))
+	assert.False(t, IsConnectionLostError(errors.New("connection refused")))
+	assert.False(t, IsConnectionLostError(syscall.ECONNREFUSED))
+}
+
+func TestIsConnectionProtocolError(t *testing.T) {
+	assert.False(t, IsConnectionProtocolError(fakeConnectionError(1)), "matched an unknown type by its name")
+	registerFakeHTTP2Errors(t)
+	assert.True(t, IsConnectionProtocolError(fmt.Errorf("wrapped: %w", fakeGoAwayError{})))
+	assert.True(t, IsConnectionProtocolError(fakeConnectionError(1)))
+	assert.True(t, IsConnectionProtocolError(errors.Join(errors.New("closing"), fmt.Errorf("wrapped: %w", fakeConnectionError(1)))))
+	assert.True(t, IsConnectionProtocolError(fmt.Errorf("first: %w, second: %w", errors.New("closing"), fakeGoAwayError{})))
+	assert.False(t, IsConnectionProtocolError(errors.Join(errors.New("closing"), errors.New("protocol"))))
+	assert.True(t, IsConnectionProtocolError(errors.New(`http2: server sent GOAWAY and closed the connection; LastStreamID=1, ErrCode=NO_ERROR, debug=""`)))
+	assert.True(t, IsConnectionProtocolError(errors.New("connection error: PROTOCOL_ERROR")))
+	assert.False(t, IsConnectionProtocolError(errors.New("stream error: stream ID 3; PROTOCOL_ERROR")))
+	assert.False(t, IsConnectionProtocolError(syscall.ECONNRESET))
+	assert.False(t, IsConnectionProtocolError(nil))
+}
+
+func TestConnectionLostRetrierRetriesOnce(t *testing.T) {
+	var policy = NewConnectionLostRetryPolicy()
+	var retrier = policy()
+	var lost = errors.New("http: server closed idle connection")
+	assert.False(t, retrier.Retry(nil, &http.Response{StatusCode: http.StatusInternalServerError}, nil))
+	assert.True(t, retrier.Retry(nil, nil, lost))
+	assert.False(t, retrier.Retry(nil, nil, lost))
+	assert.True(t, policy().Retry(nil, nil, lost), "retriers must not share state between requests")
+}
+
+func TestRetryConnectionLost(t *testing.T) {
+	var calls int
+	var rt = NewRetrier(NewFixedBackoffPolicy(0), NewConnectionLostRetryPolicy())(RoundTripperFunc(func(*http.Request) (*http.Response, error) {
+		calls = calls + 1
+		return nil, syscall.ECONNRESET
+	}))
+	var req, _ = http.NewRequest(http.MethodGet, "/", nil)
+	var _, e = rt.RoundTrip(req)
+	assert.ErrorIs(t, e, syscall.ECONNRESET)
+	assert.Equal(t, 2, calls)
+}
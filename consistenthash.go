@@ -0,0 +1,164 @@
+package transport
+
+import (
+	"hash/fnv"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// ConsistentHash is a Rotator strategy that sends every request with the same
+// key to the same instance so that upstream caches and session affine
+// backends see a stable connection per entity. Instances are placed on a hash
+// ring at a number of points each. When an instance is unavailable, such as
+// after an outlier ejection, only the keys it owned move to the next instance
+// on the ring. Requests without a key are sent round-robin.
+type ConsistentHash struct {
+	key       func(*http.Request) string
+	replicas  int
+	instances int
+	points    []uint64
+	owners    []int
+	next      atomic.Uint64
+}
+
+// ConsistentHashOption is a configuration for the ConsistentHash strategy.
+type ConsistentHashOption func(*ConsistentHash) *ConsistentHash
+
+// ConsistentHashOptionReplicas sets the number of points each instance has on
+// the hash ring. More points spread keys more evenly. The default is 100.
+func ConsistentHashOptionReplicas(replicas int) ConsistentHashOption {
+	return func(c *ConsistentHash) *ConsistentHash {
+		c.replicas = replicas
+		return c
+	}
+}
+
+// HashKeyHeader returns a key function that routes requests by the value of
+// a request header.
+func HashKeyHeader(name string) func(*http.Request) string {
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
+
+// HashKeyPathSegment returns a key function that routes requests by the
+// zero-based segment of the URL path, such that segment 1 of /users/123 is
+// "123".
+func HashKeyPathSegment(segment int) func(*http.Request) string {
+	return func(r *http.Request) string {
+		var segments = strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/")
+		if segment < 0 || segment >= len(segments) {
+			return ""
+		}
+		return segments[segment]
+	}
+}
+
+// HashKeyContext returns a key function that routes requests by a string
+// value stored in the request context.
+func HashKeyContext(key interface{}) func(*http.Request) string {
+	return func(r *http.Request) string {
+		var value, _ = r.Context().Value(key).(string)
+		return value
+	}
+}
+
+// RotatorOptionConsistentHash replaces the round-robin rotation with the
+// ConsistentHash strategy, routing requests by the key the function returns.
+func RotatorOptionConsistentHash(key func(*http.Request) string, opts ...ConsistentHashOption) RotatorOption {
+	return func(r *Rotator) *Rotator {
+		r.newStrategy = func(instances int) rotatorStrategy {
+			return newConsistentHash(instances, key, opts...)
+		}
+		return r
+	}
+}
+
+func newConsistentHash(instances int, key func(*http.Request) string, opts ...ConsistentHashOption) *ConsistentHash {
+	var c = &ConsistentHash{key: key, replicas: 100, instances: instances}
+	for _, opt := range opts {
+		c = opt(c)
+	}
+	if c.replicas < 1 {
+		c.replicas = 1
+	}
+	type point struct {
+		hash  uint64
+		owner int
+	}
+	var ring = make([]point, 0, instances*c.replicas)
+	for x := 0; x < instances; x = x + 1 {
+		for y := 0; y < c.replicas; y = y + 1 {
+			ring = append(ring, point{hash: hashKey(strconv.Itoa(x) + "-" + strconv.Itoa(y)), owner: x})
+		}
+	}
+	sort.Slice(ring, func(i int, j int) bool {
+		return ring[i].hash < ring[j].hash
+	})
+	c.points = make([]uint64, len(ring))
+	c.owners = make([]int, len(ring))
+	for x, p := range ring {
+		c.points[x] = p.hash
+		c.owners[x] = p.owner
+	}
+	return c
+}
+
+// hashKey hashes the key with FNV-1a and mixes the result because FNV alone
+// clusters similar short keys, such as sequential IDs, on the ring.
+func hashKey(key string) uint64 {
+	var h = fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	var x = h.Sum64()
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+// Owner returns the offset of the instance that requests with the key are
+// sent to while every instance is available.
+func (c *ConsistentHash) Owner(key string) int {
+	return c.owners[c.search(key)]
+}
+
+// search returns the position of the first point on the ring at or after the
+// hash of the key.
+func (c *ConsistentHash) search(key string) int {
+	var h = hashKey(key)
+	var position = sort.Search(len(c.points), func(i int) bool {
+		return c.points[i] >= h
+	})
+	return position % len(c.points)
+}
+
+func (c *ConsistentHash) pick(r *http.Request, available func(offset int) bool) (int, func()) {
+	var instances = c.instances
+	var key string
+	if r != nil && c.key != nil {
+		key = c.key(r)
+	}
+	if key == "" {
+		var offset = int(c.next.Add(1) % uint64(instances))
+		for x := 0; x < instances; x = x + 1 {
+			if candidate := (offset + x) % instances; available(candidate) {
+				return candidate, func() {}
+			}
+		}
+		return offset, func() {}
+	}
+	var start = c.search(key)
+	for x := 0; x < len(c.owners); x = x + 1 {
+		var owner = c.owners[(start+x)%len(c.owners)]
+		if available(owner) {
+			return owner, func() {}
+		}
+	}
+	return c.owners[start], func() {}
+}
+
+// reset has nothing to forget because ownership depends only on the offset
+// of an instance, which a replacement keeps.
+func (c *ConsistentHash) reset(int) {}
@@ -0,0 +1,72 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsistentHashIsSticky(t *testing.T) {
+	var instances []int
+	var created int
+	var factory = func() http.RoundTripper {
+		var instance = created
+		created = created + 1
+		return RoundTripperFunc(func(*http.Request) (*http.Response, error) {
+			instances = append(instances, instance)
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+		})
+	}
+	var rotator = NewRotator(factory, RotatorOptionInstances(4), RotatorOptionConsistentHash(HashKeyPathSegment(1)))
+	var owners = make(map[int]bool)
+	for x := 0; x < 50; x = x + 1 {
+		var req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("http://example.com/users/%d", x), nil)
+		instances = nil
+		for y := 0; y < 3; y = y + 1 {
+			_, _ = rotator.RoundTrip(req)
+		}
+		assert.Equal(t, []int{instances[0], instances[0], instances[0]}, instances)
+		owners[instances[0]] = true
+	}
+	assert.Len(t, owners, 4, "keys were not spread across instances")
+}
+
+func TestConsistentHashUnavailable(t *testing.T) {
+	var c = newConsistentHash(3, HashKeyHeader("X-Tenant"))
+	var moved, kept int
+	for x := 0; x < 100; x = x + 1 {
+		var req, _ = http.NewRequest(http.MethodGet, "http://example.com/", nil)
+		req.Header.Set("X-Tenant", fmt.Sprintf("tenant-%d", x))
+		var owner = c.Owner(fmt.Sprintf("tenant-%d", x))
+		var selected, _ = c.pick(req, func(offset int) bool { return offset != 0 })
+		assert.NotEqual(t, 0, selected)
+		if owner == 0 {
+			moved = moved + 1
+			continue
+		}
+		assert.Equal(t, owner, selected, "key of an available instance moved")
+		kept = kept + 1
+	}
+	assert.Greater(t, moved, 0)
+	assert.Greater(t, kept, 0)
+}
+
+func TestConsistentHashKeys(t *testing.T) {
+	type ctxKey struct{}
+	var req, _ = http.NewRequest(http.MethodGet, "http://example.com/a/b", nil)
+	req.Header.Set("X-Session", "session")
+	req = req.WithContext(context.WithValue(req.Context(), ctxKey{}, "entity"))
+	assert.Equal(t, "session", HashKeyHeader("X-Session")(req))
+	assert.Equal(t, "a", HashKeyPathSegment(0)(req))
+	assert.Equal(t, "", HashKeyPathSegment(2)(req))
+	assert.Equal(t, "entity", HashKeyContext(ctxKey{})(req))
+
+	// Requests without a key are spread round-robin.
+	var c = newConsistentHash(2, HashKeyHeader("X-Missing"))
+	var first, _ = c.pick(req, func(int) bool { return true })
+	var second, _ = c.pick(req, func(int) bool { return true })
+	assert.NotEqual(t, first, second)
+}
@@ -0,0 +1,89 @@
+package transport
+
+import (
+	"context"
+	"net/http"
+)
+
+// DefaultCorrelationIDHeader is the header used by the CorrelationID
+// decorator unless configured otherwise.
+const DefaultCorrelationIDHeader = "X-Request-ID"
+
+type correlationIDKey struct{}
+
+// WithCorrelationID installs a correlation ID in the context for propagation
+// by the CorrelationID decorator.
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, id)
+}
+
+// CorrelationIDFromContext returns the correlation ID installed with
+// WithCorrelationID or an empty string.
+func CorrelationIDFromContext(ctx context.Context) string {
+	var id, _ = ctx.Value(correlationIDKey{}).(string)
+	return id
+}
+
+// CorrelationIDExtractor returns the correlation ID found in a context, or an
+// empty string if there is none. It allows IDs placed in the context by
+// inbound HTTP middleware, such as that of runhttp, to be propagated without
+// this package depending on the middleware.
+type CorrelationIDExtractor func(context.Context) string
+
+// CorrelationID is a decorator that copies the correlation ID of the inbound
+// request, found in the context, to outgoing requests.
+type CorrelationID struct {
+	wrapped   http.RoundTripper
+	header    string
+	extractor CorrelationIDExtractor
+}
+
+// CorrelationIDOption is a configuration for the CorrelationID decorator.
+type CorrelationIDOption func(*CorrelationID) *CorrelationID
+
+// CorrelationIDOptionHeader sets the name of the header that carries the ID.
+func CorrelationIDOptionHeader(name string) CorrelationIDOption {
+	return func(c *CorrelationID) *CorrelationID {
+		c.header = name
+		return c
+	}
+}
+
+// CorrelationIDOptionExtractor replaces the default lookup of IDs installed
+// with WithCorrelationID.
+func CorrelationIDOptionExtractor(extractor CorrelationIDExtractor) CorrelationIDOption {
+	return func(c *CorrelationID) *CorrelationID {
+		c.extractor = extractor
+		return c
+	}
+}
+
+// RoundTrip sets the correlation ID header, unless the request already has
+// one, and calls the wrapped transport.
+func (c *CorrelationID) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.Header.Get(c.header) != "" {
+		return c.wrapped.RoundTrip(r)
+	}
+	var id = c.extractor(r.Context())
+	if id == "" {
+		return c.wrapped.RoundTrip(r)
+	}
+	r = r.Clone(r.Context())
+	if r.Header == nil {
+		r.Header = http.Header{}
+	}
+	r.Header.Set(c.header, id)
+	return c.wrapped.RoundTrip(r)
+}
+
+// NewCorrelationID configures a RoundTripper decorator that propagates the
+// correlation ID of the inbound request to outgoing requests.
+func NewCorrelationID(opts ...CorrelationIDOption) func(http.RoundTripper) http.RoundTripper {
+	return func(wrapped http.RoundTripper) http.RoundTripper {
+		var c = &CorrelationID{wrapped: wrapped, header: DefaultCorrelationIDHeader, extractor: CorrelationIDFromContext}
+		for _, opt := range opts {
+			c = opt(c)
+		}
+		return c
+	}
+}
@@ -0,0 +1,42 @@
+package transport
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCorrelationID(t *testing.T) {
+	var fixture = &fixtureHeaderTransport{Response: &http.Response{StatusCode: http.StatusOK}}
+	var rt = NewCorrelationID()(fixture)
+
+	var req, _ = http.NewRequest(http.MethodGet, "/", nil)
+	_, _ = rt.RoundTrip(req)
+	assert.Empty(t, fixture.Request.Header.Get(DefaultCorrelationIDHeader))
+
+	req = req.WithContext(WithCorrelationID(context.Background(), "abc"))
+	_, _ = rt.RoundTrip(req)
+	assert.Equal(t, "abc", fixture.Request.Header.Get(DefaultCorrelationIDHeader))
+	assert.Empty(t, req.Header.Get(DefaultCorrelationIDHeader))
+
+	req.Header.Set(DefaultCorrelationIDHeader, "existing")
+	_, _ = rt.RoundTrip(req)
+	assert.Equal(t, "existing", fixture.Request.Header.Get(DefaultCorrelationIDHeader))
+}
+
+func TestCorrelationIDOptions(t *testing.T) {
+	type inboundKey struct{}
+	var fixture = &fixtureHeaderTransport{Response: &http.Response{StatusCode: http.StatusOK}}
+	var rt = NewCorrelationID(
+		CorrelationIDOptionHeader("X-Correlation-ID"),
+		CorrelationIDOptionExtractor(func(ctx context.Context) string {
+			var id, _ = ctx.Value(inboundKey{}).(string)
+			return id
+		}),
+	)(fixture)
+	var req, _ = http.NewRequestWithContext(context.WithValue(context.Background(), inboundKey{}, "inbound"), http.MethodGet, "/", nil)
+	_, _ = rt.RoundTrip(req)
+	assert.Equal(t, "inbound", fixture.Request.Header.Get("X-Correlation-ID"))
+}
@@ -0,0 +1,205 @@
+package transport
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DebugSource reports the state of a decorator or tracker for the
+// DebugHandler. The returned value must be encodable as JSON.
+type DebugSource interface {
+	Debug() interface{}
+}
+
+// DebugSourceFunc converts a function to a DebugSource.
+type DebugSourceFunc func() interface{}
+
+// Debug calls the wrapped function.
+func (f DebugSourceFunc) Debug() interface{} {
+	return f()
+}
+
+// DebugSnapshot is the state of every source registered with a
+// DebugHandler, keyed by the name each one was registered with.
+type DebugSnapshot struct {
+	Time    time.Time              `json:"time"`
+	Sources map[string]interface{} `json:"sources"`
+}
+
+// DebugHandler collects DebugSources into a single JSON document describing
+// the chain composition, breaker states, rate limit quotas, connection pool
+// usage, and error rates of a service's clients. It is meant to be mounted on
+// an internal admin port and not exposed to untrusted callers. A request
+// with a source query parameter renders only the named sources.
+type DebugHandler struct {
+	lock    sync.RWMutex
+	sources map[string]DebugSource
+}
+
+// NewDebugHandler creates a DebugHandler with no sources.
+func NewDebugHandler() *DebugHandler {
+	return &DebugHandler{sources: make(map[string]DebugSource)}
+}
+
+// Register adds a source under the name, replacing any source previously
+// registered with it.
+func (h *DebugHandler) Register(name string, source DebugSource) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.sources[name] = source
+}
+
+// Snapshot collects the current state of the named sources, or of every
+// source if no names are given.
+func (h *DebugHandler) Snapshot(names ...string) DebugSnapshot {
+	h.lock.RLock()
+	var sources = make(map[string]DebugSource, len(h.sources))
+	for name, source := range h.sources {
+		sources[name] = source
+	}
+	h.lock.RUnlock()
+	if len(names) > 0 {
+		var selected = make(map[string]DebugSource, len(names))
+		for _, name := range names {
+			if source, ok := sources[name]; ok {
+				selected[name] = source
+			}
+		}
+		sources = selected
+	}
+	var snapshot = DebugSnapshot{Time: time.Now().UTC(), Sources: make(map[string]interface{}, len(sources))}
+	for name, source := range sources {
+		snapshot.Sources[name] = source.Debug()
+	}
+	return snapshot
+}
+
+// ServeHTTP writes the current snapshot as JSON.
+func (h *DebugHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var snapshot = h.Snapshot(r.URL.Query()["source"]...)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	var encoder = json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(snapshot)
+}
+
+// redactedValue replaces configuration values that may hold credentials.
+const redactedValue = "[redacted]"
+
+// ChainConfigDebugSource reports the decorators that the configuration
+// renders, from outermost to innermost, and the settings of each. The
+// config function is called on every report so that the current
+// configuration of a ReloadableChain can be shown by passing its Config
+// method. Header values are redacted because they often hold credentials;
+// extension settings are reported as given.
+func ChainConfigDebugSource(config func() *ChainConfig) DebugSource {
+	return DebugSourceFunc(func() interface{} {
+		var c = config()
+		var decorators = []map[string]interface{}{}
+		var add = func(name string, settings interface{}) {
+			decorators = append(decorators, map[string]interface{}{"name": name, "settings": settings})
+		}
+		if c.AccessLog != nil && c.AccessLog.Enabled {
+			add(c.AccessLog.Name(), c.AccessLog)
+		}
+		if c.Metrics != nil && c.Metrics.Enabled {
+			add(c.Metrics.Name(), c.Metrics)
+		}
+		if c.Metrics != nil && c.Metrics.Expvar != "" {
+			add("expvar", map[string]string{"Name": c.Metrics.Expvar})
+		}
+		if c.Headers != nil && len(c.Headers.Values) > 0 {
+			var headers = make(map[string]string, len(c.Headers.Values))
+			for name := range c.Headers.Values {
+				headers[name] = redactedValue
+			}
+			add(c.Headers.Name(), &HeaderConfig{Values: headers})
+		}
+		if c.RetryAfter != nil && c.RetryAfter.Enabled {
+			add(c.RetryAfter.Name(), c.RetryAfter)
+		}
+		if c.Retry != nil && c.Retry.Enabled {
+			add(c.Retry.Name(), c.Retry)
+		}
+		if c.Hedge != nil && c.Hedge.Enabled {
+			add(c.Hedge.Name(), c.Hedge)
+		}
+		for _, ext := range c.Extensions {
+			add(ext.Name, ext.Settings)
+		}
+		return map[string]interface{}{
+			"decorators": decorators,
+			"transport": map[string]interface{}{
+				"MaxIdleConns":          c.MaxIdleConns,
+				"MaxIdleConnsPerHost":   c.MaxIdleConnsPerHost,
+				"IdleConnTimeout":       c.IdleConnTimeout.String(),
+				"ResponseHeaderTimeout": c.ResponseHeaderTimeout.String(),
+			},
+		}
+	})
+}
+
+// CircuitBreakerDebugSource reports the state of every circuit of the
+// breaker.
+func CircuitBreakerDebugSource(breaker *CircuitBreaker) DebugSource {
+	return DebugSourceFunc(func() interface{} {
+		var keys = breaker.Keys()
+		var states = make(map[string]string, len(keys))
+		for _, key := range keys {
+			states[key] = breaker.State(key).String()
+		}
+		return states
+	})
+}
+
+// QuotaThrottleDebugSource reports every quota of the throttle along with
+// its saturation, which is the fraction of the quota used, or -1 if the
+// upstream does not report the size of the quota.
+func QuotaThrottleDebugSource(throttle *QuotaThrottle) DebugSource {
+	return DebugSourceFunc(func() interface{} {
+		var quotas = throttle.Quotas()
+		var report = make(map[string]interface{}, len(quotas))
+		for key, quota := range quotas {
+			var saturation = -1.0
+			if quota.Limit > 0 {
+				saturation = float64(quota.Limit-quota.Remaining) / float64(quota.Limit)
+			}
+			report[key] = map[string]interface{}{
+				"limit":      quota.Limit,
+				"remaining":  quota.Remaining,
+				"reset":      quota.Reset,
+				"saturation": saturation,
+			}
+		}
+		return report
+	})
+}
+
+// PoolTrackerDebugSource reports the connection pool usage of every host
+// recorded by the tracker.
+func PoolTrackerDebugSource(tracker *PoolTracker) DebugSource {
+	return DebugSourceFunc(func() interface{} {
+		var keys = tracker.Keys()
+		var report = make(map[string]PoolStats, len(keys))
+		for _, key := range keys {
+			report[key] = tracker.Stats(key)
+		}
+		return report
+	})
+}
+
+// HealthTrackerDebugSource reports the recent error rate of every key
+// recorded by the tracker.
+func HealthTrackerDebugSource(tracker *HealthTracker) DebugSource {
+	return DebugSourceFunc(func() interface{} {
+		var keys = tracker.Keys()
+		var report = make(map[string]HealthStats, len(keys))
+		for _, key := range keys {
+			report[key] = tracker.Stats(key)
+		}
+		return report
+	})
+}
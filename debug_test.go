@@ -0,0 +1,85 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebugHandler(t *testing.T) {
+	var conf = NewChainComponent().Settings()
+	conf.Retry.Enabled = true
+	conf.Headers.Values = map[string]string{"Authorization": "Bearer secret"}
+	conf.Extensions = []ExtensionConfig{{Name: "throttle", Settings: map[string]interface{}{"rate": 10}}}
+
+	var breaker = NewCircuitBreaker(CircuitBreakerOptionThreshold(1))
+	breaker.Record(context.Background(), "api.example.com", true)
+
+	var throttle = NewQuotaThrottle()
+	var req, _ = http.NewRequest(http.MethodGet, "https://api.example.com/", nil)
+	throttle.Observe(req, &http.Response{Header: http.Header{
+		"Ratelimit-Limit":     []string{"100"},
+		"Ratelimit-Remaining": []string{"25"},
+		"Ratelimit-Reset":     []string{"60"},
+	}})
+
+	var health = NewHealthTracker()
+	health.Record("api.example.com", true)
+	health.Record("api.example.com", false)
+
+	var pool = NewPoolTracker()
+	pool.update("api.example.com", func(s *PoolStats) { s.NewConns = 2 })
+
+	var handler = NewDebugHandler()
+	handler.Register("chain", ChainConfigDebugSource(func() *ChainConfig { return conf }))
+	handler.Register("breaker", CircuitBreakerDebugSource(breaker))
+	handler.Register("quota", QuotaThrottleDebugSource(throttle))
+	handler.Register("health", HealthTrackerDebugSource(health))
+	handler.Register("pool", PoolTrackerDebugSource(pool))
+
+	var w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/transport", nil))
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.NotContains(t, w.Body.String(), "secret", "header values were not redacted")
+	var snapshot struct {
+		Time    time.Time
+		Sources struct {
+			Chain struct {
+				Decorators []struct {
+					Name     string
+					Settings map[string]interface{}
+				}
+			}
+			Breaker map[string]string
+			Quota   map[string]map[string]interface{}
+			Health  map[string]HealthStats
+			Pool    map[string]PoolStats
+		}
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &snapshot))
+	assert.False(t, snapshot.Time.IsZero())
+
+	var names []string
+	for _, d := range snapshot.Sources.Chain.Decorators {
+		names = append(names, d.Name)
+	}
+	assert.Equal(t, []string{"headers", "retry", "throttle"}, names)
+	assert.Equal(t, map[string]interface{}{"rate": 10.0}, snapshot.Sources.Chain.Decorators[2].Settings)
+	assert.Equal(t, map[string]string{"api.example.com": "open"}, snapshot.Sources.Breaker)
+	assert.Equal(t, 0.75, snapshot.Sources.Quota["api.example.com"]["saturation"])
+	assert.Equal(t, 0.5, snapshot.Sources.Health["api.example.com"].ErrorRate)
+	assert.Equal(t, int64(2), snapshot.Sources.Pool["api.example.com"].NewConns)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/transport?source=breaker&source=missing", nil))
+	var filtered DebugSnapshot
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &filtered))
+	assert.Len(t, filtered.Sources, 1)
+	assert.Contains(t, filtered.Sources, "breaker")
+}
@@ -0,0 +1,214 @@
+package transport
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+)
+
+const dialRetrySource = "dial_retry"
+
+// DialContextFunc is the signature of the functions used by http.Transport to
+// establish connections.
+type DialContextFunc func(ctx context.Context, network string, addr string) (net.Conn, error)
+
+// DialRetry retries failures to establish a connection before any part of a
+// request is written. Because nothing has reached the server, these retries
+// are safe for every request and do not consume the budget of the retry
+// decorators.
+type DialRetry struct {
+	attempts  int
+	base      time.Duration
+	max       time.Duration
+	retryable func(error) bool
+	clock     Clock
+}
+
+// DialRetryOption is a configuration for the DialRetry.
+type DialRetryOption func(*DialRetry) *DialRetry
+
+// DialRetryOptionAttempts sets the total number of attempts made to establish
+// a connection. The default is 3.
+func DialRetryOptionAttempts(attempts int) DialRetryOption {
+	return func(d *DialRetry) *DialRetry {
+		d.attempts = attempts
+		return d
+	}
+}
+
+// DialRetryOptionBackoff sets the delay before the first retry and the
+// maximum delay. The delay doubles after each retry. The defaults are 10
+// milliseconds and 100 milliseconds.
+func DialRetryOptionBackoff(base time.Duration, max time.Duration) DialRetryOption {
+	return func(d *DialRetry) *DialRetry {
+		d.base = base
+		d.max = max
+		return d
+	}
+}
+
+// DialRetryOptionRetryable sets the function that decides whether a failed
+// attempt is retried. The default retries every error except context
+// cancellation and DNS lookups that found no such host.
+func DialRetryOptionRetryable(retryable func(error) bool) DialRetryOption {
+	return func(d *DialRetry) *DialRetry {
+		d.retryable = retryable
+		return d
+	}
+}
+
+// DialRetryOptionClock configures the Clock used to wait between attempts.
+func DialRetryOptionClock(clock Clock) DialRetryOption {
+	return func(d *DialRetry) *DialRetry {
+		d.clock = clock
+		return d
+	}
+}
+
+func defaultDialRetryable(e error) bool {
+	if isContextError(e) {
+		return false
+	}
+	var dnsErr *net.DNSError
+	if errors.As(e, &dnsErr) && dnsErr.IsNotFound {
+		return false
+	}
+	return true
+}
+
+// NewDialRetry creates a DialRetry.
+func NewDialRetry(opts ...DialRetryOption) *DialRetry {
+	var d = &DialRetry{
+		attempts:  3,
+		base:      10 * time.Millisecond,
+		max:       100 * time.Millisecond,
+		retryable: defaultDialRetryable,
+		clock:     NewSystemClock(),
+	}
+	for _, opt := range opts {
+		d = opt(d)
+	}
+	if d.attempts < 1 {
+		d.attempts = 1
+	}
+	return d
+}
+
+// do calls the function until it succeeds, returns an error that is not
+// retryable, or runs out of attempts.
+func (d *DialRetry) do(ctx context.Context, dial func() (net.Conn, error)) (net.Conn, error) {
+	var delay = d.base
+	for attempt := 1; ; attempt = attempt + 1 {
+		var conn, e = dial()
+		if e == nil || attempt >= d.attempts || !d.retryable(e) {
+			return conn, e
+		}
+		emitEvent(ctx, TransportEvent{
+			Type: EventRetryScheduled, Source: dialRetrySource,
+			Err: e, Attempt: attempt, Delay: delay,
+		})
+		var timer = d.clock.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, e
+		case <-timer.C():
+		}
+		delay = delay * 2
+		if delay > d.max {
+			delay = d.max
+		}
+	}
+}
+
+// DialContext wraps the dial function so that failed attempts to connect are
+// retried.
+func (d *DialRetry) DialContext(dial DialContextFunc) DialContextFunc {
+	return func(ctx context.Context, network string, addr string) (net.Conn, error) {
+		return d.do(ctx, func() (net.Conn, error) {
+			return dial(ctx, network, addr)
+		})
+	}
+}
+
+// DialTLSContext wraps the dial function with a TLS handshake using the
+// config so that failures to either connect or complete the handshake are
+// retried. The ServerName of the config defaults to the host being dialed.
+// The handshake of each attempt is limited by the timeout if it is not zero.
+func (d *DialRetry) DialTLSContext(dial DialContextFunc, config *tls.Config, timeout time.Duration) DialContextFunc {
+	return func(ctx context.Context, network string, addr string) (net.Conn, error) {
+		var cfg = config.Clone()
+		if cfg == nil {
+			cfg = &tls.Config{}
+		}
+		if cfg.ServerName == "" {
+			var host, _, e = net.SplitHostPort(addr)
+			if e != nil {
+				host = addr
+			}
+			cfg.ServerName = host
+		}
+		return d.do(ctx, func() (net.Conn, error) {
+			var raw, e = dial(ctx, network, addr)
+			if e != nil {
+				return nil, e
+			}
+			var handshakeCtx, cancel = ctx, func() {}
+			if timeout > 0 {
+				handshakeCtx, cancel = context.WithTimeout(ctx, timeout)
+			}
+			defer cancel()
+			var conn = tls.Client(raw, cfg)
+			if e = conn.HandshakeContext(handshakeCtx); e != nil {
+				_ = raw.Close()
+				return nil, e
+			}
+			return conn, nil
+		})
+	}
+}
+
+func transportDialContext(t *http.Transport) DialContextFunc {
+	if t.DialContext != nil {
+		return t.DialContext
+	}
+	return (&net.Dialer{}).DialContext
+}
+
+// OptionDialRetry wraps the DialContext of the Transport so that failures to
+// establish TCP connections are retried before the request is written.
+func OptionDialRetry(opts ...DialRetryOption) Option {
+	return func(t *http.Transport) *http.Transport {
+		t.DialContext = NewDialRetry(opts...).DialContext(transportDialContext(t))
+		return t
+	}
+}
+
+// OptionDialTLSRetry installs a DialTLSContext in the Transport that performs
+// the TLS handshake itself so that failures to either connect or complete the
+// handshake are retried. The handshake uses the TLSClientConfig and
+// TLSHandshakeTimeout of the Transport and offers HTTP/2 when
+// ForceAttemptHTTP2 is set. Apply it after any options that change those
+// values.
+func OptionDialTLSRetry(opts ...DialRetryOption) Option {
+	return func(t *http.Transport) *http.Transport {
+		t.DialTLSContext = transportDialTLSContext(t, NewDialRetry(opts...))
+		return t
+	}
+}
+
+// transportDialTLSContext creates a TLS dial function that connects and
+// performs the handshake as the Transport would itself.
+func transportDialTLSContext(t *http.Transport, d *DialRetry) DialContextFunc {
+	var config = t.TLSClientConfig.Clone()
+	if config == nil {
+		config = &tls.Config{}
+	}
+	if len(config.NextProtos) < 1 && t.ForceAttemptHTTP2 {
+		config.NextProtos = []string{"h2", "http/1.1"}
+	}
+	return d.DialTLSContext(transportDialContext(t), config, t.TLSHandshakeTimeout)
+}
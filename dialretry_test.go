@@ -0,0 +1,81 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDialRetryDialContext(t *testing.T) {
+	var clock = newFakeClock()
+	var calls int
+	var dial = func(context.Context, string, string) (net.Conn, error) {
+		calls = calls + 1
+		if calls < 3 {
+			return nil, errors.New("connection refused")
+		}
+		var client, server = net.Pipe()
+		_ = server.Close()
+		return client, nil
+	}
+	var collector = &eventCollector{}
+	var ctx = WithEventSubscriber(context.Background(), collector)
+	var retry = NewDialRetry(DialRetryOptionClock(clock), DialRetryOptionBackoff(10*time.Millisecond, 15*time.Millisecond))
+	var conn, e = retry.DialContext(dial)(ctx, "tcp", "example.com:443")
+	require.NoError(t, e)
+	_ = conn.Close()
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, []time.Duration{10 * time.Millisecond, 15 * time.Millisecond}, clock.recorded())
+	assert.Equal(t, []EventType{EventRetryScheduled, EventRetryScheduled}, collector.types())
+
+	calls = 0
+	_, e = NewDialRetry(DialRetryOptionClock(clock), DialRetryOptionAttempts(2)).DialContext(dial)(ctx, "tcp", "example.com:443")
+	assert.Error(t, e)
+	assert.Equal(t, 2, calls)
+}
+
+func TestDialRetryNotRetryable(t *testing.T) {
+	var calls int
+	var dial = func(context.Context, string, string) (net.Conn, error) {
+		calls = calls + 1
+		return nil, &net.DNSError{Err: "no such host", Name: "missing.example.com", IsNotFound: true}
+	}
+	var _, e = NewDialRetry().DialContext(dial)(context.Background(), "tcp", "missing.example.com:443")
+	assert.Error(t, e)
+	assert.Equal(t, 1, calls)
+}
+
+func TestOptionDialTLSRetry(t *testing.T) {
+	var server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	var calls int
+	var dialer = &net.Dialer{}
+	var tr = New(
+		OptionTLSClientConfig(server.Client().Transport.(*http.Transport).TLSClientConfig),
+		OptionDialContext(func(ctx context.Context, network string, addr string) (net.Conn, error) {
+			calls = calls + 1
+			if calls == 1 {
+				return nil, errors.New("connection refused")
+			}
+			return dialer.DialContext(ctx, network, addr)
+		}),
+		OptionDialTLSRetry(DialRetryOptionClock(newFakeClock())),
+	)
+	defer tr.CloseIdleConnections()
+	var req, _ = http.NewRequest(http.MethodGet, server.URL, nil)
+	var resp, e = tr.RoundTrip(req)
+	require.NoError(t, e)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, 2, calls)
+}
@@ -0,0 +1,111 @@
+package transport
+
+import (
+	"crypto/md5" // nolint:gosec
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DigestAlgorithm identifies a hash algorithm used for body digests.
+type DigestAlgorithm string
+
+const (
+	// DigestSHA256 adds a sha-256 entry to the Content-Digest header.
+	DigestSHA256 DigestAlgorithm = "sha-256"
+	// DigestSHA512 adds a sha-512 entry to the Content-Digest header.
+	DigestSHA512 DigestAlgorithm = "sha-512"
+	// DigestMD5 sets the legacy Content-MD5 header for APIs that still
+	// require it.
+	DigestMD5 DigestAlgorithm = "md5"
+)
+
+func (a DigestAlgorithm) hash() hash.Hash {
+	switch a {
+	case DigestSHA512:
+		return sha512.New()
+	case DigestMD5:
+		return md5.New() // nolint:gosec
+	default:
+		return sha256.New()
+	}
+}
+
+// ContentDigest is a decorator that adds digests of the request body to every
+// request that has one, using the Content-Digest header defined by RFC 9530
+// and, optionally, the legacy Content-MD5 header. The digest is computed for
+// every request that passes through it so that, when installed inside a retry
+// or hedging decorator, each replayed body is digested again.
+type ContentDigest struct {
+	wrapped    http.RoundTripper
+	algorithms []DigestAlgorithm
+}
+
+// RoundTrip digests the request body and calls the wrapped transport. Bodies
+// of requests with a GetBody function are digested from a fresh copy.
+// Other bodies are read into memory so that they can be digested and sent.
+func (c *ContentDigest) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return c.wrapped.RoundTrip(r)
+	}
+	var hashes = make([]hash.Hash, len(c.algorithms))
+	var writers = make([]io.Writer, len(c.algorithms))
+	for x, algorithm := range c.algorithms {
+		hashes[x] = algorithm.hash()
+		writers[x] = hashes[x]
+	}
+	var digester = io.MultiWriter(writers...)
+	if r.GetBody != nil {
+		r = r.Clone(r.Context())
+		var body, e = r.GetBody()
+		if e != nil {
+			_ = r.Body.Close()
+			return nil, e
+		}
+		_, e = io.Copy(digester, body)
+		_ = body.Close()
+		if e != nil {
+			_ = r.Body.Close()
+			return nil, e
+		}
+	} else {
+		var content, e = readBody(io.TeeReader(r.Body, digester))
+		_ = r.Body.Close()
+		if e != nil {
+			return nil, e
+		}
+		r = withRequestBody(r, newReplayBody(content))
+		r.GetBody = func() (io.ReadCloser, error) {
+			return newReplayBody(content), nil
+		}
+	}
+
+	var digests = make([]string, 0, len(c.algorithms))
+	for x, algorithm := range c.algorithms {
+		var encoded = base64.StdEncoding.EncodeToString(hashes[x].Sum(nil))
+		if algorithm == DigestMD5 {
+			r.Header.Set("Content-MD5", encoded)
+			continue
+		}
+		digests = append(digests, string(algorithm)+"=:"+encoded+":")
+	}
+	if len(digests) > 0 {
+		r.Header.Set("Content-Digest", strings.Join(digests, ", "))
+	}
+	return c.wrapped.RoundTrip(r)
+}
+
+// NewContentDigest configures a RoundTripper decorator that adds digests of
+// request bodies using the given algorithms. The default is DigestSHA256.
+func NewContentDigest(algorithms ...DigestAlgorithm) func(http.RoundTripper) http.RoundTripper {
+	if len(algorithms) < 1 {
+		algorithms = []DigestAlgorithm{DigestSHA256}
+	}
+	return func(wrapped http.RoundTripper) http.RoundTripper {
+		return &ContentDigest{wrapped: wrapped, algorithms: algorithms}
+	}
+}
@@ -0,0 +1,59 @@
+package transport
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContentDigestReplayedBodies(t *testing.T) {
+	var digests, bodies []string
+	var base = RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		var body, _ = io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		digests = append(digests, r.Header.Get("Content-Digest"))
+		return &http.Response{StatusCode: http.StatusBadGateway, Body: http.NoBody}, nil
+	})
+	var rt = NewRetrier(
+		NewFixedBackoffPolicy(0),
+		NewLimitedRetryPolicy(1, NewStatusCodeRetryPolicy(http.StatusBadGateway)),
+	)(NewContentDigest()(base))
+	var req, _ = http.NewRequest(http.MethodPost, "https://example.com/", io.NopCloser(strings.NewReader("hello")))
+	var resp, e = rt.RoundTrip(req)
+	require.NoError(t, e)
+	assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	var expected = "sha-256=:LPJNul+wow4m6DsqxbninhsWHlwfp0JecwQzYpOLmCQ=:"
+	assert.Equal(t, []string{expected, expected}, digests)
+	assert.Equal(t, []string{"hello", "hello"}, bodies)
+}
+
+func TestContentDigestAlgorithms(t *testing.T) {
+	var seen *http.Request
+	var body string
+	var rt = NewContentDigest(DigestSHA256, DigestSHA512, DigestMD5)(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		seen = r
+		if r.Body != nil {
+			var content, _ = io.ReadAll(r.Body)
+			body = string(content)
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	}))
+	// Requests from http.NewRequest with a strings.Reader have a GetBody.
+	var req, _ = http.NewRequest(http.MethodPut, "https://example.com/", strings.NewReader("hello"))
+	var _, e = rt.RoundTrip(req)
+	require.NoError(t, e)
+	assert.Equal(t, "hello", body)
+	assert.Equal(t, "XUFAKrxLKna5cZ2REBfFkg==", seen.Header.Get("Content-MD5"))
+	var digest = seen.Header.Get("Content-Digest")
+	assert.True(t, strings.HasPrefix(digest, "sha-256=:LPJNul+wow4m6DsqxbninhsWHlwfp0JecwQzYpOLmCQ=:, sha-512=:"), digest)
+	assert.Empty(t, req.Header.Get("Content-Digest"), "original request was modified")
+
+	var get, _ = http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	_, e = rt.RoundTrip(get)
+	require.NoError(t, e)
+	assert.Empty(t, seen.Header.Get("Content-Digest"))
+}
@@ -0,0 +1,141 @@
+package transport
+
+import (
+	"context"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+const dnsWatcherSource = "dns_watcher"
+
+// DNSWatcher periodically resolves a hostname and signals when the set of
+// addresses it resolves to changes. The signal channel is intended for use
+// with RecycleOptionChannel so that transports holding connections to stale
+// addresses, such as after a failover behind DNS, are replaced promptly.
+type DNSWatcher struct {
+	host      string
+	interval  time.Duration
+	lookup    func(ctx context.Context, host string) ([]string, error)
+	clock     Clock
+	signal    chan struct{}
+	lock      sync.Mutex
+	addresses []string
+}
+
+// DNSWatcherOption is a configuration for the DNSWatcher.
+type DNSWatcherOption func(*DNSWatcher) *DNSWatcher
+
+// DNSWatcherOptionInterval configures how often the hostname is resolved. The
+// default is 30 seconds.
+func DNSWatcherOptionInterval(interval time.Duration) DNSWatcherOption {
+	return func(w *DNSWatcher) *DNSWatcher {
+		w.interval = interval
+		return w
+	}
+}
+
+// DNSWatcherOptionLookup configures the function used to resolve the hostname.
+// The default is net.DefaultResolver.LookupHost.
+func DNSWatcherOptionLookup(lookup func(ctx context.Context, host string) ([]string, error)) DNSWatcherOption {
+	return func(w *DNSWatcher) *DNSWatcher {
+		w.lookup = lookup
+		return w
+	}
+}
+
+// DNSWatcherOptionClock configures the Clock used to schedule lookups.
+func DNSWatcherOptionClock(clock Clock) DNSWatcherOption {
+	return func(w *DNSWatcher) *DNSWatcher {
+		w.clock = clock
+		return w
+	}
+}
+
+// NewDNSWatcher creates a DNSWatcher for the given hostname. The watcher does
+// nothing until Run is called.
+func NewDNSWatcher(host string, opts ...DNSWatcherOption) *DNSWatcher {
+	var w = &DNSWatcher{
+		host:     host,
+		interval: 30 * time.Second,
+		lookup:   net.DefaultResolver.LookupHost,
+		clock:    NewSystemClock(),
+		signal:   make(chan struct{}, 1),
+	}
+	for _, opt := range opts {
+		w = opt(w)
+	}
+	return w
+}
+
+// Signal returns the channel that receives a value each time the address set
+// changes. Changes that occur while a previous signal is still pending are
+// coalesced into that signal.
+func (w *DNSWatcher) Signal() chan struct{} {
+	return w.signal
+}
+
+// Addresses returns the most recently resolved address set in sorted order.
+func (w *DNSWatcher) Addresses() []string {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return append([]string(nil), w.addresses...)
+}
+
+// Check resolves the hostname once and signals if the result differs from the
+// previous successful lookup. The first successful lookup only records the
+// address set. Failed lookups leave the recorded set unchanged.
+func (w *DNSWatcher) Check(ctx context.Context) error {
+	var addresses, e = w.lookup(ctx, w.host)
+	if e != nil {
+		return e
+	}
+	addresses = append([]string(nil), addresses...)
+	sort.Strings(addresses)
+
+	w.lock.Lock()
+	var previous = w.addresses
+	w.addresses = addresses
+	w.lock.Unlock()
+
+	if previous == nil || sameAddresses(previous, addresses) {
+		return nil
+	}
+	select {
+	case w.signal <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Run checks the hostname on the configured interval until the context is
+// cancelled. Lookup errors are ignored so that a transient resolver failure
+// does not trigger a recycle.
+func (w *DNSWatcher) Run(ctx context.Context) {
+	defer labelGoroutine(ctx, dnsWatcherSource, "")()
+	_ = w.Check(ctx)
+	var timer = w.clock.NewTimer(w.interval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C():
+			_ = w.Check(ctx)
+			timer.Reset(w.interval)
+		}
+	}
+}
+
+func sameAddresses(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for offset := range a {
+		if a[offset] != b[offset] {
+			return false
+		}
+	}
+	return true
+}
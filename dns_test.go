@@ -0,0 +1,93 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func staticLookup(results ...[]string) func(context.Context, string) ([]string, error) {
+	var calls int
+	return func(context.Context, string) ([]string, error) {
+		var result = results[calls]
+		if calls < len(results)-1 {
+			calls = calls + 1
+		}
+		if result == nil {
+			return nil, errors.New("no such host")
+		}
+		return result, nil
+	}
+}
+
+func TestDNSWatcherCheck(t *testing.T) {
+	var w = NewDNSWatcher("example.com", DNSWatcherOptionLookup(staticLookup(
+		[]string{"10.0.0.2", "10.0.0.1"},
+		[]string{"10.0.0.1", "10.0.0.2"},
+		nil,
+		[]string{"10.0.0.3"},
+	)))
+	var ctx = context.Background()
+
+	require.NoError(t, w.Check(ctx))
+	assert.Equal(t, []string{"10.0.0.1", "10.0.0.2"}, w.Addresses())
+	assert.Len(t, w.Signal(), 0, "signalled on the first lookup")
+
+	require.NoError(t, w.Check(ctx))
+	assert.Len(t, w.Signal(), 0, "signalled when only the order changed")
+
+	require.Error(t, w.Check(ctx))
+	assert.Equal(t, []string{"10.0.0.1", "10.0.0.2"}, w.Addresses())
+	assert.Len(t, w.Signal(), 0, "signalled on a failed lookup")
+
+	require.NoError(t, w.Check(ctx))
+	assert.Equal(t, []string{"10.0.0.3"}, w.Addresses())
+	assert.Len(t, w.Signal(), 1)
+}
+
+func TestDNSWatcherRun(t *testing.T) {
+	var ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	var lookup = staticLookup([]string{"10.0.0.1"}, []string{"10.0.0.2"}, []string{"10.0.0.3"})
+	var calls int
+	var clock = newFakeClock()
+	var w = NewDNSWatcher(
+		"example.com",
+		DNSWatcherOptionClock(clock),
+		DNSWatcherOptionInterval(time.Minute),
+		DNSWatcherOptionLookup(func(ctx context.Context, host string) ([]string, error) {
+			calls = calls + 1
+			if calls == 3 {
+				cancel()
+			}
+			return lookup(ctx, host)
+		}),
+	)
+	w.Run(ctx)
+	assert.GreaterOrEqual(t, calls, 3)
+	assert.Equal(t, time.Minute, clock.recorded()[0])
+	assert.Len(t, w.Signal(), 1, "changes were not coalesced into one signal")
+}
+
+func TestDNSWatcherRecycles(t *testing.T) {
+	var generations int
+	var factory = func() http.RoundTripper {
+		generations = generations + 1
+		return &roundTripperForRecycleTests{v: "dns"}
+	}
+	var w = NewDNSWatcher("example.com", DNSWatcherOptionLookup(staticLookup(
+		[]string{"10.0.0.1"},
+		[]string{"10.0.0.2"},
+	)))
+	var r = NewRecycler(factory, RecycleOptionChannel(w.Signal()))
+	require.NoError(t, w.Check(context.Background()))
+	require.NoError(t, w.Check(context.Background()))
+	time.Sleep(time.Millisecond) // Wait for the background listener to activate
+	_ = r.getTransport()
+	assert.Equal(t, 2, generations)
+}
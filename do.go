@@ -0,0 +1,84 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// maxStatusErrorBody is the most of a response body kept by a StatusError.
+const maxStatusErrorBody = 64 << 10
+
+// StatusError is returned by Do when the response has a status outside of the
+// 2xx range.
+type StatusError struct {
+	// Response is the response that was rejected. The body has already been
+	// read and closed so only the status and headers are available.
+	Response *http.Response
+	// StatusCode is the status of the response.
+	StatusCode int
+	// Body contains up to the first 64KiB of the response body.
+	Body []byte
+}
+
+func newStatusError(response *http.Response) *StatusError {
+	var body, _ = io.ReadAll(io.LimitReader(response.Body, maxStatusErrorBody))
+	drainBody(response)
+	return &StatusError{
+		Response:   withBody(response, http.NoBody),
+		StatusCode: response.StatusCode,
+		Body:       body,
+	}
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("transport: unexpected response status %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// Is matches ErrClientStatus and ErrServerStatus according to the class of
+// the status.
+func (e *StatusError) Is(target error) bool {
+	return statusClassIs(e.StatusCode, target)
+}
+
+// Do sends the request with the client, which is expected to carry the
+// decorator chain, and decodes a successful JSON response into a value of
+// type T. Responses with a status outside of the 2xx range produce a
+// *StatusError. Errors from the chain, such as a RetryExhaustedError or a
+// SignatureError, are returned unchanged so that they can be inspected with
+// errors.As. Retries and response validation are left to the decorators of
+// the client rather than repeated here.
+//
+// Empty responses, such as a 204 No Content, produce the zero value of T. The
+// Accept header is set to application/json unless the request already has
+// one, and the body is always closed before Do returns.
+func Do[T any](ctx context.Context, client *http.Client, req *http.Request) (T, error) {
+	var result T
+	req = req.WithContext(ctx)
+	if req.Header.Get("Accept") == "" {
+		req.Header = req.Header.Clone()
+		if req.Header == nil {
+			req.Header = http.Header{}
+		}
+		req.Header.Set("Accept", "application/json")
+	}
+	var resp, e = client.Do(req)
+	if e != nil {
+		return result, e
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return result, newStatusError(resp)
+	}
+	defer drainBody(resp)
+	if resp.StatusCode == http.StatusNoContent {
+		return result, nil
+	}
+	if e = json.NewDecoder(resp.Body).Decode(&result); e != nil && !errors.Is(e, io.EOF) {
+		var u, _ = ScrubURLCredentials(req.URL)
+		return result, fmt.Errorf("transport: decoding response from %s: %w", u, e)
+	}
+	return result, nil
+}
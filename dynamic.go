@@ -0,0 +1,89 @@
+package transport
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// DynamicChain is an ordered collection of Decorators that may be replaced
+// at runtime. Any RoundTripper produced by the DynamicChain picks up the new
+// set of Decorators on the next request after a Swap.
+type DynamicChain struct {
+	current atomic.Pointer[dynamicChainState]
+}
+
+// dynamicChainState is an immutable snapshot of the decorators. The pointer
+// identity of the snapshot is used by applied transports to detect a Swap.
+type dynamicChainState struct {
+	chain Chain
+}
+
+// NewDynamicChain generates a DynamicChain with the given initial set of
+// Decorators.
+func NewDynamicChain(decorators ...Decorator) *DynamicChain {
+	var c = &DynamicChain{}
+	c.Swap(decorators)
+	return c
+}
+
+// Swap atomically replaces the Decorator set and returns the previous one.
+func (c *DynamicChain) Swap(decorators []Decorator) Chain {
+	var chain = make(Chain, len(decorators))
+	copy(chain, decorators)
+	var previous = c.current.Swap(&dynamicChainState{chain: chain})
+	if previous == nil {
+		return nil
+	}
+	return previous.chain
+}
+
+// Chain returns a copy of the currently active Decorator set.
+func (c *DynamicChain) Chain() Chain {
+	var state = c.current.Load()
+	var chain = make(Chain, len(state.chain))
+	copy(chain, state.chain)
+	return chain
+}
+
+// Apply wraps the given RoundTripper with the Decorator chain. The returned
+// RoundTripper re-applies the chain whenever the Decorator set is swapped.
+func (c *DynamicChain) Apply(base http.RoundTripper) http.RoundTripper {
+	return &dynamicTransport{chain: c, base: base}
+}
+
+// ApplyFactory wraps the given Factory such that all new instances produced
+// will be decorated with the contents of the chain.
+func (c *DynamicChain) ApplyFactory(base Factory) Factory {
+	return func() http.RoundTripper {
+		return c.Apply(base())
+	}
+}
+
+type dynamicApplied struct {
+	state   *dynamicChainState
+	wrapped http.RoundTripper
+}
+
+type dynamicTransport struct {
+	chain   *DynamicChain
+	base    http.RoundTripper
+	applied atomic.Pointer[dynamicApplied]
+}
+
+func (d *dynamicTransport) current() http.RoundTripper {
+	var state = d.chain.current.Load()
+	var applied = d.applied.Load()
+	if applied != nil && applied.state == state {
+		return applied.wrapped
+	}
+	// Concurrent requests may race to rebuild after a swap. Both results are
+	// equivalent so the last one stored wins without further coordination.
+	applied = &dynamicApplied{state: state, wrapped: state.chain.Apply(d.base)}
+	d.applied.Store(applied)
+	return applied.wrapped
+}
+
+// RoundTrip applies the currently active Decorator set to the request.
+func (d *dynamicTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	return d.current().RoundTrip(r)
+}
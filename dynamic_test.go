@@ -0,0 +1,72 @@
+package transport
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+func TestDynamicChainSwap(t *testing.T) {
+	var annotations []string
+	var annotator = func(annotation string) Decorator {
+		return func(wrapped http.RoundTripper) http.RoundTripper {
+			return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+				annotations = append(annotations, annotation)
+				return wrapped.RoundTrip(r)
+			})
+		}
+	}
+	var base = RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		return nil, errors.New("")
+	})
+
+	var chain = NewDynamicChain(annotator("one"), annotator("two"))
+	var result = chain.Apply(base)
+	_, _ = result.RoundTrip(nil)
+	if len(annotations) != 2 || annotations[0] != "one" || annotations[1] != "two" {
+		t.Fatalf("decorators applied incorrectly: %v", annotations)
+	}
+
+	annotations = nil
+	var previous = chain.Swap([]Decorator{annotator("three")})
+	if len(previous) != 2 {
+		t.Fatalf("expected previous chain of 2 but got %d", len(previous))
+	}
+	_, _ = result.RoundTrip(nil)
+	if len(annotations) != 1 || annotations[0] != "three" {
+		t.Fatalf("swapped decorators not applied: %v", annotations)
+	}
+
+	annotations = nil
+	chain.Swap(nil)
+	_, _ = result.RoundTrip(nil)
+	if len(annotations) != 0 {
+		t.Fatalf("expected no decorators but got: %v", annotations)
+	}
+}
+
+func TestDynamicChainConcurrentSwap(t *testing.T) {
+	var base = RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK}, nil
+	})
+	var nop = func(wrapped http.RoundTripper) http.RoundTripper { return wrapped }
+	var chain = NewDynamicChain(nop)
+	var result = chain.ApplyFactory(func() http.RoundTripper { return base })()
+	var wg sync.WaitGroup
+	for x := 0; x < 10; x = x + 1 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, _ = result.RoundTrip(nil)
+		}()
+		go func() {
+			defer wg.Done()
+			chain.Swap([]Decorator{nop, nop})
+		}()
+	}
+	wg.Wait()
+	if len(chain.Chain()) != 2 {
+		t.Fatal("unexpected chain length after swaps")
+	}
+}
@@ -0,0 +1,221 @@
+package transport
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ContentDecoder wraps a response body in a reader that undoes one content
+// coding, such as gzip.
+type ContentDecoder func(body io.Reader) (io.ReadCloser, error)
+
+// GzipDecoder decodes the gzip content coding.
+func GzipDecoder(body io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(body)
+}
+
+// DeflateDecoder decodes the deflate content coding, which is data in the zlib
+// format.
+func DeflateDecoder(body io.Reader) (io.ReadCloser, error) {
+	return zlib.NewReader(body)
+}
+
+// UnsupportedEncodingError is returned by the AcceptEncoding decorator when
+// a server responds with a content coding that it cannot decode.
+type UnsupportedEncodingError struct {
+	// Response is the rejected response. The body has already been drained
+	// and closed so only the status and headers are available.
+	Response *http.Response
+	// Encoding is the content coding that could not be decoded.
+	Encoding string
+}
+
+func (e *UnsupportedEncodingError) Error() string {
+	return fmt.Sprintf("transport: unsupported content encoding %q", e.Encoding)
+}
+
+// AcceptEncoding is a decorator that manages the Accept-Encoding header of
+// every request and decodes the responses so that callers always receive an
+// unencoded body whichever coding the server chose. The coding of each
+// response is recorded under AnnotationContentEncoding.
+type AcceptEncoding struct {
+	wrapped   http.RoundTripper
+	encodings []string
+	decoders  map[string]ContentDecoder
+}
+
+// AcceptEncodingOption is a configuration for the AcceptEncoding decorator.
+type AcceptEncodingOption func(*AcceptEncoding) *AcceptEncoding
+
+// AcceptEncodingOptionDecoder advertises a content coding, such as br or
+// zstd, and decodes responses that use it with the decoder. Codings are
+// advertised in the order they are added, after gzip and deflate, and a
+// decoder given for an existing coding replaces the built in one. The package
+// does not decode br or zstd on its own so that it needs no compression
+// libraries; an adapter for a brotli library might look like:
+//
+//	transport.AcceptEncodingOptionDecoder("br", func(body io.Reader) (io.ReadCloser, error) {
+//		return io.NopCloser(brotli.NewReader(body)), nil
+//	})
+func AcceptEncodingOptionDecoder(encoding string, decoder ContentDecoder) AcceptEncodingOption {
+	encoding = strings.ToLower(encoding)
+	return func(a *AcceptEncoding) *AcceptEncoding {
+		if _, ok := a.decoders[encoding]; !ok {
+			a.encodings = append(a.encodings, encoding)
+		}
+		a.decoders[encoding] = decoder
+		return a
+	}
+}
+
+// AcceptEncodingOptionEncodings limits the codings that are advertised, in
+// order of preference, to the given ones. Each must be gzip, deflate, or
+// have been added with AcceptEncodingOptionDecoder before this option.
+// Responses that use a known coding are still decoded when it is not
+// advertised.
+func AcceptEncodingOptionEncodings(encodings ...string) AcceptEncodingOption {
+	return func(a *AcceptEncoding) *AcceptEncoding {
+		a.encodings = a.encodings[:0]
+		for _, encoding := range encodings {
+			encoding = strings.ToLower(encoding)
+			if _, ok := a.decoders[encoding]; ok {
+				a.encodings = append(a.encodings, encoding)
+			}
+		}
+		return a
+	}
+}
+
+// RoundTrip sends the request with the managed Accept-Encoding header and
+// decodes the response body. Range requests are sent without the header
+// because a range of an encoded body cannot be decoded on its own.
+func (c *AcceptEncoding) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.Header.Get("Range") != "" {
+		var resp, e = c.wrapped.RoundTrip(r)
+		return c.decode(r, resp, e)
+	}
+	var header = r.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	if len(c.encodings) > 0 {
+		header.Set("Accept-Encoding", strings.Join(c.encodings, ", "))
+	} else {
+		header.Set("Accept-Encoding", "identity")
+	}
+	var replaced = r.Clone(r.Context())
+	replaced.Header = header
+	var resp, e = c.wrapped.RoundTrip(replaced)
+	return c.decode(r, resp, e)
+}
+
+func (c *AcceptEncoding) decode(r *http.Request, resp *http.Response, e error) (*http.Response, error) {
+	if e != nil {
+		return resp, e
+	}
+	var codings = parseContentEncoding(resp.Header.Get("Content-Encoding"))
+	if len(codings) < 1 {
+		Annotate(r.Context(), AnnotationContentEncoding, "identity")
+		return resp, nil
+	}
+	Annotate(r.Context(), AnnotationContentEncoding, strings.Join(codings, ", "))
+	if r.Method == http.MethodHead || resp.Body == nil || resp.Body == http.NoBody || resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified {
+		return resp, nil
+	}
+	var decoders = make([]ContentDecoder, 0, len(codings))
+	for x := len(codings) - 1; x >= 0; x = x - 1 {
+		var decoder, ok = c.decoders[codings[x]]
+		if !ok {
+			drainBody(resp)
+			return nil, &UnsupportedEncodingError{Response: withBody(resp, http.NoBody), Encoding: codings[x]}
+		}
+		decoders = append(decoders, decoder)
+	}
+	var decoded = withBody(resp, &decodedBody{raw: resp.Body, decoders: decoders})
+	decoded.Header = resp.Header.Clone()
+	decoded.Header.Del("Content-Encoding")
+	decoded.Header.Del("Content-Length")
+	decoded.ContentLength = -1
+	decoded.Uncompressed = true
+	return decoded, nil
+}
+
+// parseContentEncoding returns the codings of a Content-Encoding header in
+// the order they were applied, without the identity coding.
+func parseContentEncoding(value string) []string {
+	var codings []string
+	for _, coding := range strings.Split(value, ",") {
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "" && coding != "identity" {
+			codings = append(codings, coding)
+		}
+	}
+	return codings
+}
+
+// decodedBody applies the decoders to the raw body when it is first read so
+// that reading the headers of an encoded body does not delay the response.
+type decodedBody struct {
+	raw      io.ReadCloser
+	decoders []ContentDecoder
+	readers  []io.ReadCloser
+	reader   io.Reader
+	err      error
+}
+
+func (b *decodedBody) Read(p []byte) (int, error) {
+	if b.reader == nil && b.err == nil {
+		var reader io.Reader = b.raw
+		for _, decoder := range b.decoders {
+			var decoded, e = decoder(reader)
+			if e == io.EOF {
+				// An empty body carries no encoded data, so it is returned
+				// as an empty decoded body rather than as a failure.
+				b.err = io.EOF
+				break
+			}
+			if e != nil {
+				b.err = fmt.Errorf("transport: decoding response body: %w", e)
+				break
+			}
+			b.readers = append(b.readers, decoded)
+			reader = decoded
+		}
+		b.reader = reader
+	}
+	if b.err != nil {
+		return 0, b.err
+	}
+	return b.reader.Read(p)
+}
+
+// Close releases the decoders and closes the raw body.
+func (b *decodedBody) Close() error {
+	for x := len(b.readers) - 1; x >= 0; x = x - 1 {
+		_ = b.readers[x].Close()
+	}
+	return b.raw.Close()
+}
+
+// NewAcceptEncoding configures a RoundTripper decorator that advertises gzip
+// and deflate, along with any codings added by options, and decodes the
+// responses. It replaces the transparent gzip support of the http.Transport,
+// which is disabled whenever a request sets its own Accept-Encoding header,
+// and should be placed inside of decorators that inspect response bodies.
+func NewAcceptEncoding(opts ...AcceptEncodingOption) func(http.RoundTripper) http.RoundTripper {
+	return func(wrapped http.RoundTripper) http.RoundTripper {
+		var a = &AcceptEncoding{
+			wrapped:   wrapped,
+			encodings: []string{"gzip", "deflate"},
+			decoders:  map[string]ContentDecoder{"gzip": GzipDecoder, "deflate": DeflateDecoder},
+		}
+		for _, opt := range opts {
+			a = opt(a)
+		}
+		return a
+	}
+}
@@ -0,0 +1,183 @@
+package transport
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func gzipBytes(t *testing.T, content string) []byte {
+	var buf bytes.Buffer
+	var w = gzip.NewWriter(&buf)
+	if _, e := w.Write([]byte(content)); e != nil {
+		t.Fatal(e)
+	}
+	if e := w.Close(); e != nil {
+		t.Fatal(e)
+	}
+	return buf.Bytes()
+}
+
+func zlibBytes(t *testing.T, content []byte) []byte {
+	var buf bytes.Buffer
+	var w = zlib.NewWriter(&buf)
+	if _, e := w.Write(content); e != nil {
+		t.Fatal(e)
+	}
+	if e := w.Close(); e != nil {
+		t.Fatal(e)
+	}
+	return buf.Bytes()
+}
+
+func newEncodingTestTransport(encoding string, body []byte, accept *string) RoundTripperFunc {
+	return func(r *http.Request) (*http.Response, error) {
+		*accept = r.Header.Get("Accept-Encoding")
+		var header = http.Header{"Content-Length": []string{"10"}}
+		if encoding != "" {
+			header.Set("Content-Encoding", encoding)
+		}
+		return &http.Response{StatusCode: http.StatusOK, Header: header, ContentLength: int64(len(body)), Body: io.NopCloser(bytes.NewReader(body))}, nil
+	}
+}
+
+func TestAcceptEncoding(t *testing.T) {
+	var tests = []struct {
+		name     string
+		encoding string
+		body     []byte
+	}{
+		{name: "identity", body: []byte("hello world")},
+		{name: "gzip", encoding: "gzip", body: gzipBytes(t, "hello world")},
+		{name: "deflate", encoding: "Deflate", body: zlibBytes(t, []byte("hello world"))},
+		{name: "layered", encoding: "gzip, deflate", body: zlibBytes(t, gzipBytes(t, "hello world"))},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var accept string
+			var rt = NewAcceptEncoding()(newEncodingTestTransport(test.encoding, test.body, &accept))
+			var req, _ = http.NewRequest(http.MethodGet, "http://localhost", http.NoBody)
+			req.Header.Set("Accept-Encoding", "br")
+			req = req.WithContext(WithAnnotations(req.Context()))
+			var resp, e = rt.RoundTrip(req)
+			if e != nil {
+				t.Fatal(e)
+			}
+			if accept != "gzip, deflate" {
+				t.Fatalf("unexpected Accept-Encoding %q", accept)
+			}
+			if req.Header.Get("Accept-Encoding") != "br" {
+				t.Fatal("modified the headers of the original request")
+			}
+			var content, _ = io.ReadAll(resp.Body)
+			_ = resp.Body.Close()
+			if string(content) != "hello world" {
+				t.Fatalf("unexpected body %q", content)
+			}
+			var expected = strings.ToLower(test.encoding)
+			if expected == "" {
+				expected = "identity"
+			} else if resp.Header.Get("Content-Encoding") != "" || resp.Header.Get("Content-Length") != "" || resp.ContentLength != -1 || !resp.Uncompressed {
+				t.Fatal("did not mark the response as decoded")
+			}
+			if encoding, _ := Annotation(req.Context(), AnnotationContentEncoding); encoding != expected {
+				t.Fatalf("expected the encoding %q to be annotated but got %q", expected, encoding)
+			}
+		})
+	}
+}
+
+func TestAcceptEncodingOptionDecoder(t *testing.T) {
+	var accept string
+	var reverse = func(body io.Reader) (io.ReadCloser, error) {
+		var content, e = io.ReadAll(body)
+		for x, y := 0, len(content)-1; x < y; x, y = x+1, y-1 {
+			content[x], content[y] = content[y], content[x]
+		}
+		return io.NopCloser(bytes.NewReader(content)), e
+	}
+	var rt = NewAcceptEncoding(
+		AcceptEncodingOptionDecoder("BR", reverse),
+		AcceptEncodingOptionEncodings("br", "gzip", "zstd"),
+	)(newEncodingTestTransport("br", []byte("dlrow olleh"), &accept))
+	var req, _ = http.NewRequest(http.MethodGet, "http://localhost", http.NoBody)
+	var resp, e = rt.RoundTrip(req)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if accept != "br, gzip" {
+		t.Fatalf("unexpected Accept-Encoding %q", accept)
+	}
+	var content, _ = io.ReadAll(resp.Body)
+	if string(content) != "hello world" {
+		t.Fatalf("unexpected body %q", content)
+	}
+
+	rt = NewAcceptEncoding(AcceptEncodingOptionEncodings())(newEncodingTestTransport("", []byte("hello world"), &accept))
+	if _, e = rt.RoundTrip(req); e != nil {
+		t.Fatal(e)
+	}
+	if accept != "identity" {
+		t.Fatalf("expected identity to be requested but got %q", accept)
+	}
+}
+
+func TestAcceptEncodingUnsupported(t *testing.T) {
+	var accept string
+	var rt = NewAcceptEncoding()(newEncodingTestTransport("zstd", []byte("encoded"), &accept))
+	var req, _ = http.NewRequest(http.MethodGet, "http://localhost", http.NoBody)
+	var resp, e = rt.RoundTrip(req)
+	var unsupported *UnsupportedEncodingError
+	if resp != nil || !errors.As(e, &unsupported) || unsupported.Encoding != "zstd" {
+		t.Fatalf("expected an UnsupportedEncodingError but got %v", e)
+	}
+}
+
+func TestAcceptEncodingInvalidBody(t *testing.T) {
+	var accept string
+	var rt = NewAcceptEncoding()(newEncodingTestTransport("gzip", []byte("not gzip"), &accept))
+	var req, _ = http.NewRequest(http.MethodGet, "http://localhost", http.NoBody)
+	var resp, e = rt.RoundTrip(req)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if _, e = io.ReadAll(resp.Body); e == nil {
+		t.Fatal("expected an error reading an invalid body")
+	}
+	_ = resp.Body.Close()
+}
+
+func TestAcceptEncodingEmptyBody(t *testing.T) {
+	var accept string
+	var rt = NewAcceptEncoding()(newEncodingTestTransport("gzip", nil, &accept))
+	var req, _ = http.NewRequest(http.MethodGet, "http://localhost", http.NoBody)
+	var resp, e = rt.RoundTrip(req)
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer resp.Body.Close()
+	if n, e := resp.Body.Read(make([]byte, 8)); n != 0 || e != io.EOF {
+		t.Fatalf("expected io.EOF from an empty body but got %d, %v", n, e)
+	}
+	if body, e := io.ReadAll(resp.Body); e != nil || len(body) != 0 {
+		t.Fatalf("expected an empty body but got %q, %v", body, e)
+	}
+}
+
+func TestAcceptEncodingRange(t *testing.T) {
+	var accept string
+	var rt = NewAcceptEncoding()(newEncodingTestTransport("", []byte("hello"), &accept))
+	var req, _ = http.NewRequest(http.MethodGet, "http://localhost", http.NoBody)
+	req.Header.Set("Range", "bytes=0-4")
+	if _, e := rt.RoundTrip(req); e != nil {
+		t.Fatal(e)
+	}
+	if accept != "" {
+		t.Fatalf("advertised %q for a range request", accept)
+	}
+}
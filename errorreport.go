@@ -0,0 +1,99 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Breadcrumb describes an outgoing request for an error tracking service such
+// as Sentry. URLs never include user information or query strings.
+type Breadcrumb struct {
+	Timestamp  time.Time
+	Method     string
+	URL        string
+	StatusCode int
+	Duration   time.Duration
+	Err        error
+}
+
+// ErrorReporter is implemented by adapters for error tracking services. Both
+// methods receive the request context so that adapters can locate a
+// request-scoped client, such as a Sentry hub.
+type ErrorReporter interface {
+	// AddBreadcrumb records an outgoing request.
+	AddBreadcrumb(ctx context.Context, breadcrumb Breadcrumb)
+	// ReportError reports a request that failed without a response.
+	ReportError(ctx context.Context, err error, metadata map[string]string)
+}
+
+// ErrorReporting is a decorator that records every request as a Breadcrumb
+// and reports requests that fail with an error to an ErrorReporter.
+type ErrorReporting struct {
+	wrapped  http.RoundTripper
+	reporter ErrorReporter
+}
+
+// RoundTrip calls the wrapped transport and reports the outcome.
+func (c *ErrorReporting) RoundTrip(r *http.Request) (*http.Response, error) {
+	var start = time.Now()
+	var resp, e = c.wrapped.RoundTrip(r)
+	var breadcrumb = Breadcrumb{
+		Timestamp: start,
+		Method:    r.Method,
+		URL:       scrubURL(r.URL),
+		Duration:  time.Since(start),
+		Err:       e,
+	}
+	if resp != nil {
+		breadcrumb.StatusCode = resp.StatusCode
+	}
+	c.reporter.AddBreadcrumb(r.Context(), breadcrumb)
+	if e != nil {
+		c.reporter.ReportError(r.Context(), e, errorMetadata(r, e))
+	}
+	return resp, e
+}
+
+func errorMetadata(r *http.Request, e error) map[string]string {
+	var metadata = map[string]string{
+		"method": r.Method,
+		"host":   r.URL.Host,
+		"path":   r.URL.Path,
+		"status": strconv.Itoa(ErrorToStatusCode(e)),
+	}
+	for k, v := range TagsFromContext(r.Context()) {
+		if _, ok := metadata[k]; !ok {
+			metadata[k] = v
+		}
+	}
+	var attemptErr *AttemptError
+	if errors.As(e, &attemptErr) {
+		metadata["attempts"] = strconv.Itoa(attemptErr.Attempts())
+		metadata["elapsed"] = attemptErr.Elapsed().String()
+	}
+	var exhaustedErr *RetryExhaustedError
+	if errors.As(e, &exhaustedErr) {
+		metadata["retries_exhausted"] = "true"
+	}
+	return metadata
+}
+
+// scrubURL renders the URL without user information, query, or fragment
+// because those frequently contain credentials.
+func scrubURL(u *url.URL) string {
+	var scrubbed = url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path, RawPath: u.RawPath, Opaque: u.Opaque}
+	return scrubbed.String()
+}
+
+// NewErrorReporting configures a RoundTripper decorator that reports requests
+// to an ErrorReporter. Apply it outside of any retry decorators so that only
+// failures remaining after all retries are reported.
+func NewErrorReporting(reporter ErrorReporter) func(http.RoundTripper) http.RoundTripper {
+	return func(wrapped http.RoundTripper) http.RoundTripper {
+		return &ErrorReporting{wrapped: wrapped, reporter: reporter}
+	}
+}
@@ -2,20 +2,117 @@ package transport
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
+	"net"
 	"net/http"
+	"sync"
+	"syscall"
 )
 
+// StatusSSLCertificateError is the non-standard status code, popularized by
+// nginx, used to report that the upstream presented an invalid certificate.
+const StatusSSLCertificateError = 495
+
+// ErrorStatusMapping pairs an error matcher with the status code that is
+// reported for matching errors.
+type ErrorStatusMapping struct {
+	Match  func(error) bool
+	Status int
+}
+
+// ErrorStatusMappings is the ordered table consulted by ErrorToStatusCode.
+// The first entry that matches an error determines the status code.
+var ErrorStatusMappings = []ErrorStatusMapping{
+	{Match: isContextError, Status: http.StatusGatewayTimeout},
+	{Match: isCertificateError, Status: StatusSSLCertificateError},
+	{Match: isDNSError, Status: http.StatusServiceUnavailable},
+	{Match: isConnectionRefused, Status: http.StatusServiceUnavailable},
+	{Match: isTimeout, Status: http.StatusGatewayTimeout},
+	{Match: isQuotaExhausted, Status: http.StatusTooManyRequests},
+	{Match: isCircuitOpen, Status: http.StatusServiceUnavailable},
+}
+
+var (
+	registeredErrorStatusLock = &sync.RWMutex{}
+	registeredErrorStatus     []ErrorStatusMapping
+)
+
+// RegisterErrorStatus teaches ErrorToStatusCode how to report an
+// application specific error. Registered mappings are consulted in the order
+// they are registered and before any entry in ErrorStatusMappings.
+func RegisterErrorStatus(matcher func(error) bool, status int) {
+	registeredErrorStatusLock.Lock()
+	defer registeredErrorStatusLock.Unlock()
+	registeredErrorStatus = append(registeredErrorStatus, ErrorStatusMapping{Match: matcher, Status: status})
+}
+
+func isQuotaExhausted(err error) bool {
+	return errors.Is(err, ErrQuotaExhausted)
+}
+
+func isCircuitOpen(err error) bool {
+	return errors.Is(err, ErrCircuitOpen)
+}
+
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+func isCertificateError(err error) bool {
+	var verificationErr *tls.CertificateVerificationError
+	var authorityErr x509.UnknownAuthorityError
+	var invalidErr x509.CertificateInvalidError
+	var hostnameErr x509.HostnameError
+	return errors.As(err, &verificationErr) ||
+		errors.As(err, &authorityErr) ||
+		errors.As(err, &invalidErr) ||
+		errors.As(err, &hostnameErr)
+}
+
+func isDNSError(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr)
+}
+
+func isConnectionRefused(err error) bool {
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
 // ErrorToStatusCode attempts to translate an HTTP request error to a meaningful
 // HTTP status code.
 //
 // There can be many reasons why we couldn't get a proper response from the upstream server.
-// This includes timeouts, inability to connect, or the client canceling a request. If the
-// error appears to indicate a timeout occurred, then return 504 Gateway Timeout, otherwise,
-// return 502 Bad Gateway.
+// This includes timeouts, inability to connect, or the client canceling a request. The
+// error is compared against any mappings added with RegisterErrorStatus and then
+// each entry of ErrorStatusMappings in order. By default:
+//
+//   - Context cancellation and deadlines return 504 Gateway Timeout.
+//   - Certificate verification failures return 495.
+//   - DNS resolution failures and refused connections return 503 Service Unavailable.
+//   - Network timeouts, including TLS handshake timeouts, return 504 Gateway Timeout.
+//   - Requests rejected by an open circuit breaker return 503 Service Unavailable.
+//
+// All other errors return 502 Bad Gateway.
 func ErrorToStatusCode(err error) int {
-	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
-		return http.StatusGatewayTimeout
+	registeredErrorStatusLock.RLock()
+	for _, mapping := range registeredErrorStatus {
+		if mapping.Match(err) {
+			registeredErrorStatusLock.RUnlock()
+			return mapping.Status
+		}
+	}
+	registeredErrorStatusLock.RUnlock()
+	for _, mapping := range ErrorStatusMappings {
+		if mapping.Match(err) {
+			return mapping.Status
+		}
 	}
 	return http.StatusBadGateway
 }
@@ -2,12 +2,25 @@ package transport
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"syscall"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 )
 
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
 func TestErrorToStatusCode(t *testing.T) {
 	code := ErrorToStatusCode(context.Canceled)
 	assert.Equal(t, 504, code)
@@ -18,3 +31,38 @@ func TestErrorToStatusCode(t *testing.T) {
 	code = ErrorToStatusCode(errors.New("boom"))
 	assert.Equal(t, 502, code)
 }
+
+func TestErrorToStatusCodeNetworkErrors(t *testing.T) {
+	var wrap = func(err error) error {
+		return &url.Error{Op: "Get", URL: "https://localhost", Err: err}
+	}
+	var tests = []struct {
+		Name     string
+		Err      error
+		Expected int
+	}{
+		{"dns", wrap(&net.DNSError{Err: "no such host", Name: "localhost", IsNotFound: true}), 503},
+		{"refused", wrap(&net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}), 503},
+		{"timeout", wrap(&net.OpError{Op: "dial", Err: timeoutError{}}), 504},
+		{"unknown authority", wrap(x509.UnknownAuthorityError{}), StatusSSLCertificateError},
+		{"hostname", wrap(x509.HostnameError{Certificate: &x509.Certificate{}, Host: "localhost"}), StatusSSLCertificateError},
+		{"verification", wrap(&tls.CertificateVerificationError{Err: errors.New("bad")}), StatusSSLCertificateError},
+		{"wrapped context", fmt.Errorf("failed: %w", context.DeadlineExceeded), 504},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			assert.Equal(t, test.Expected, ErrorToStatusCode(test.Err))
+		})
+	}
+}
+
+var errRegisteredForTest = errors.New("registered")
+
+func TestRegisterErrorStatus(t *testing.T) {
+	assert.Equal(t, 502, ErrorToStatusCode(errRegisteredForTest))
+	RegisterErrorStatus(func(err error) bool {
+		return errors.Is(err, errRegisteredForTest)
+	}, 429)
+	assert.Equal(t, 429, ErrorToStatusCode(fmt.Errorf("wrapped: %w", errRegisteredForTest)))
+	assert.Equal(t, 502, ErrorToStatusCode(errors.New("boom")))
+}
@@ -0,0 +1,124 @@
+package transport
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// EventType identifies the kind of TransportEvent.
+type EventType string
+
+const (
+	// EventAttemptStarted is emitted before a decorator sends a request to
+	// the transport it wraps.
+	EventAttemptStarted EventType = "attempt_started"
+	// EventAttemptFinished is emitted when a request sent to a wrapped
+	// transport returns.
+	EventAttemptFinished EventType = "attempt_finished"
+	// EventRetryScheduled is emitted when a decorator decides to retry and is
+	// about to wait for the backoff delay.
+	EventRetryScheduled EventType = "retry_scheduled"
+	// EventHedgeLaunched is emitted when the Hedger sends an additional
+	// request while waiting on earlier ones.
+	EventHedgeLaunched EventType = "hedge_launched"
+	// EventCircuitOpened is emitted when a circuit breaker begins rejecting
+	// requests.
+	EventCircuitOpened EventType = "circuit_opened"
+	// EventTransportRecycled is emitted when the Recycler replaces its
+	// transport.
+	EventTransportRecycled EventType = "transport_recycled"
+	// EventInstanceEjected is emitted when outlier detection removes a
+	// Rotator instance from the rotation.
+	EventInstanceEjected EventType = "instance_ejected"
+	// EventRegionFailover is emitted when the Failover begins sending
+	// traffic to a different region, whether failing over or back.
+	EventRegionFailover EventType = "region_failover"
+	// EventRequestBlocked is emitted when a policy refuses to send a request.
+	EventRequestBlocked EventType = "request_blocked"
+	// EventSensitiveDataDetected is emitted when a body inspector finds
+	// sensitive data in a request, whether or not the request was blocked.
+	EventSensitiveDataDetected EventType = "sensitive_data_detected"
+	// EventDownloadResumed is emitted when reading a response body failed and
+	// the ResumeDownload requested the rest of the content.
+	EventDownloadResumed EventType = "download_resumed"
+	// EventSoftTimeout is emitted when a request has not received a response
+	// within the threshold of a SoftTimeout. The request continues.
+	EventSoftTimeout EventType = "soft_timeout"
+)
+
+// TransportEvent describes a notable action taken by a decorator. Fields that
+// do not apply to an event type are left as zero values.
+type TransportEvent struct {
+	Type EventType
+	// Time the event was emitted.
+	Time time.Time
+	// Source is the name of the component that emitted the event, such as
+	// "retry" or "hedger".
+	Source string
+	// Request being processed when the event occurred.
+	Request *http.Request
+	// Response and Err contain the result of an attempt.
+	Response *http.Response
+	Err      error
+	// Attempt is the one-based index of the attempt the event relates to.
+	Attempt int
+	// Duration is the time taken by a finished attempt.
+	Duration time.Duration
+	// Delay is the time that will be waited before a scheduled retry or the
+	// length of an ejection.
+	Delay time.Duration
+	// Instance is the zero-based offset of the Rotator instance the event
+	// relates to, or the priority of the region for a failover.
+	Instance int
+	// Region is the name of the region a failover sends traffic to.
+	Region string
+	// Findings names the kinds of sensitive data detected in a request.
+	Findings []string
+}
+
+// EventSubscriber receives TransportEvents. Events are delivered
+// synchronously on the request path so implementations must not block. The
+// Hedger emits events from multiple goroutines so implementations must also be
+// safe for concurrent use.
+type EventSubscriber interface {
+	OnEvent(TransportEvent)
+}
+
+// EventSubscriberFunc converts a function to an EventSubscriber.
+type EventSubscriberFunc func(TransportEvent)
+
+// OnEvent calls the wrapped function.
+func (f EventSubscriberFunc) OnEvent(e TransportEvent) {
+	f(e)
+}
+
+type eventSubscribersKey struct{}
+
+// WithEventSubscriber installs an EventSubscriber in the context. Every
+// decorator handling a request made with the context emits its events to all
+// subscribers installed in it.
+func WithEventSubscriber(ctx context.Context, subscriber EventSubscriber) context.Context {
+	var existing = eventSubscribers(ctx)
+	var subscribers = make([]EventSubscriber, 0, len(existing)+1)
+	subscribers = append(subscribers, existing...)
+	subscribers = append(subscribers, subscriber)
+	return context.WithValue(ctx, eventSubscribersKey{}, subscribers)
+}
+
+func eventSubscribers(ctx context.Context) []EventSubscriber {
+	var subscribers, _ = ctx.Value(eventSubscribersKey{}).([]EventSubscriber)
+	return subscribers
+}
+
+// emitEvent delivers the event to all subscribers in the context.
+func emitEvent(ctx context.Context, event TransportEvent) {
+	var subscribers = eventSubscribers(ctx)
+	if len(subscribers) < 1 {
+		return
+	}
+	event.Time = time.Now()
+	for _, subscriber := range subscribers {
+		subscriber.OnEvent(event)
+	}
+}
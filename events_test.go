@@ -0,0 +1,133 @@
+package transport
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type eventCollector struct {
+	lock   sync.Mutex
+	events []TransportEvent
+}
+
+func (c *eventCollector) OnEvent(e TransportEvent) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.events = append(c.events, e)
+}
+
+func (c *eventCollector) types() []EventType {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	var types = make([]EventType, 0, len(c.events))
+	for _, e := range c.events {
+		types = append(types, e.Type)
+	}
+	return types
+}
+
+func TestWithEventSubscriberMultiple(t *testing.T) {
+	var first, second = &eventCollector{}, &eventCollector{}
+	var ctx = WithEventSubscriber(context.Background(), first)
+	ctx = WithEventSubscriber(ctx, second)
+	emitEvent(ctx, TransportEvent{Type: EventCircuitOpened})
+	emitEvent(context.Background(), TransportEvent{Type: EventCircuitOpened})
+	assert.Equal(t, []EventType{EventCircuitOpened}, first.types())
+	assert.Equal(t, []EventType{EventCircuitOpened}, second.types())
+	assert.False(t, first.events[0].Time.IsZero())
+}
+
+func TestRetryEmitsEvents(t *testing.T) {
+	var ctrl = gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var wrapped = NewMockRoundTripper(ctrl)
+	var rt = NewRetrier(
+		NewFixedBackoffPolicy(time.Millisecond),
+		NewStatusCodeRetryPolicy(http.StatusInternalServerError),
+	)(wrapped)
+	wrapped.EXPECT().RoundTrip(gomock.Any()).Return(&http.Response{StatusCode: http.StatusInternalServerError, Body: http.NoBody}, nil)
+	wrapped.EXPECT().RoundTrip(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil)
+
+	var collector = &eventCollector{}
+	var req, _ = http.NewRequest(http.MethodGet, "/", nil)
+	req = req.WithContext(WithEventSubscriber(req.Context(), collector))
+	var _, e = rt.RoundTrip(req)
+	require.NoError(t, e)
+	assert.Equal(t, []EventType{
+		EventAttemptStarted, EventAttemptFinished, EventRetryScheduled, EventAttemptStarted, EventAttemptFinished,
+	}, collector.types())
+	assert.Equal(t, time.Millisecond, collector.events[2].Delay)
+	assert.Equal(t, 2, collector.events[4].Attempt)
+	assert.Equal(t, retrySource, collector.events[4].Source)
+}
+
+func TestRetryAfterEmitsEvents(t *testing.T) {
+	var ctrl = gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var wrapped = NewMockRoundTripper(ctrl)
+	var rt = NewRetryAfter()(wrapped)
+	wrapped.EXPECT().RoundTrip(gomock.Any()).Return(&http.Response{
+		StatusCode: http.StatusTooManyRequests,
+		Header:     http.Header{"Retry-After": []string{"0"}},
+		Body:       http.NoBody,
+	}, nil)
+	wrapped.EXPECT().RoundTrip(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil)
+
+	var collector = &eventCollector{}
+	var req, _ = http.NewRequest(http.MethodGet, "/", nil)
+	req = req.WithContext(WithEventSubscriber(req.Context(), collector))
+	var _, e = rt.RoundTrip(req)
+	require.NoError(t, e)
+	assert.Equal(t, []EventType{
+		EventAttemptStarted, EventAttemptFinished, EventRetryScheduled, EventAttemptStarted, EventAttemptFinished,
+	}, collector.types())
+}
+
+func TestHedgerEmitsEvents(t *testing.T) {
+	var ctrl = gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var wrapped = NewMockRoundTripper(ctrl)
+	var rt = NewHedger(NewFixedBackoffPolicy(time.Millisecond))(wrapped)
+	var release = make(chan struct{})
+	wrapped.EXPECT().RoundTrip(gomock.Any()).DoAndReturn(func(r *http.Request) (*http.Response, error) {
+		<-release
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	}).MinTimes(1)
+
+	var collector = &eventCollector{}
+	var req, _ = http.NewRequest(http.MethodGet, "/", nil)
+	req = req.WithContext(WithEventSubscriber(req.Context(), collector))
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+	}()
+	var _, e = rt.RoundTrip(req)
+	require.NoError(t, e)
+	assert.Contains(t, collector.types(), EventHedgeLaunched)
+}
+
+func TestRecyclerEmitsEvents(t *testing.T) {
+	var factory = func() http.RoundTripper {
+		return RoundTripperFunc(func(*http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+		})
+	}
+	var r = NewRecycler(factory, RecycleOptionMaxUsage(1))
+	var collector = &eventCollector{}
+	var req, _ = http.NewRequest(http.MethodGet, "/", nil)
+	req = req.WithContext(WithEventSubscriber(req.Context(), collector))
+	_, _ = r.RoundTrip(req)
+	assert.Empty(t, collector.types())
+	_, _ = r.RoundTrip(req)
+	assert.Equal(t, []EventType{EventTransportRecycled}, collector.types())
+}
@@ -0,0 +1,125 @@
+package transport
+
+import (
+	"expvar"
+	"net/http"
+	"sync"
+)
+
+// ExpvarCounters publishes counts of requests, retries, hedges, circuit
+// breaker trips, and cache lookups with the expvar package so that services
+// without a metrics stack can read them from /debug/vars. The counters are
+// published as a single map named by the prefix.
+type ExpvarCounters struct {
+	requests     expvar.Int
+	retries      expvar.Int
+	hedges       expvar.Int
+	breakerTrips expvar.Int
+	cacheHits    expvar.Int
+	cacheMisses  expvar.Int
+}
+
+var (
+	expvarCountersLock sync.Mutex
+	expvarCounters     = make(map[string]*ExpvarCounters)
+)
+
+// NewExpvarCounters returns the ExpvarCounters published under the prefix,
+// publishing them on first use. Published variables cannot be removed so
+// every call with the same prefix shares the same counters, which allows a
+// chain to be rebuilt without losing counts. It panics if the prefix is
+// already used by a variable published elsewhere.
+func NewExpvarCounters(prefix string) *ExpvarCounters {
+	expvarCountersLock.Lock()
+	defer expvarCountersLock.Unlock()
+	if c, ok := expvarCounters[prefix]; ok {
+		return c
+	}
+	var c = &ExpvarCounters{}
+	var m = &expvar.Map{}
+	m.Set("requests", &c.requests)
+	m.Set("retries", &c.retries)
+	m.Set("hedges", &c.hedges)
+	m.Set("breaker_trips", &c.breakerTrips)
+	m.Set("cache_hits", &c.cacheHits)
+	m.Set("cache_misses", &c.cacheMisses)
+	m.Set("cache_hit_ratio", expvar.Func(func() interface{} { return c.CacheHitRatio() }))
+	expvar.Publish(prefix, m)
+	expvarCounters[prefix] = c
+	return c
+}
+
+// OnEvent counts the retries, hedges, and breaker trips reported by the
+// decorators that handle a request.
+func (c *ExpvarCounters) OnEvent(e TransportEvent) {
+	switch e.Type {
+	case EventRetryScheduled:
+		c.retries.Add(1)
+	case EventHedgeLaunched:
+		c.hedges.Add(1)
+	case EventCircuitOpened:
+		c.breakerTrips.Add(1)
+	}
+}
+
+// ObserveCache counts a cache lookup. This package does not include a cache
+// so the method is for caching decorators to report their hits and misses.
+func (c *ExpvarCounters) ObserveCache(hit bool) {
+	if hit {
+		c.cacheHits.Add(1)
+		return
+	}
+	c.cacheMisses.Add(1)
+}
+
+// CacheHitRatio returns the fraction of cache lookups that were hits, or zero
+// if there have been none.
+func (c *ExpvarCounters) CacheHitRatio() float64 {
+	var hits = c.cacheHits.Value()
+	var total = hits + c.cacheMisses.Value()
+	if total == 0 {
+		return 0
+	}
+	return float64(hits) / float64(total)
+}
+
+// Requests returns the number of requests counted.
+func (c *ExpvarCounters) Requests() int64 {
+	return c.requests.Value()
+}
+
+// Retries returns the number of retries counted.
+func (c *ExpvarCounters) Retries() int64 {
+	return c.retries.Value()
+}
+
+// Hedges returns the number of hedged requests counted.
+func (c *ExpvarCounters) Hedges() int64 {
+	return c.hedges.Value()
+}
+
+// BreakerTrips returns the number of times a circuit opened.
+func (c *ExpvarCounters) BreakerTrips() int64 {
+	return c.breakerTrips.Value()
+}
+
+type expvarTransport struct {
+	wrapped  http.RoundTripper
+	counters *ExpvarCounters
+}
+
+// RoundTrip counts the request and subscribes the counters to the events of
+// the decorators it passes through.
+func (c *expvarTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	c.counters.requests.Add(1)
+	return c.wrapped.RoundTrip(r.WithContext(WithEventSubscriber(r.Context(), c.counters)))
+}
+
+// NewExpvarCounting configures a RoundTripper decorator that updates the
+// counters for every request. It must wrap the Retry, Hedger, and circuit
+// breaking decorators for their events to be counted.
+func NewExpvarCounting(counters *ExpvarCounters) func(http.RoundTripper) http.RoundTripper {
+	return func(wrapped http.RoundTripper) http.RoundTripper {
+		return &expvarTransport{wrapped: wrapped, counters: counters}
+	}
+}
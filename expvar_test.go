@@ -0,0 +1,73 @@
+package transport
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpvarCounting(t *testing.T) {
+	// Published variables outlive the test so every run needs its own name.
+	var name = fmt.Sprintf("transport_test_counting_%d", time.Now().UnixNano())
+	var counters = NewExpvarCounters(name)
+	assert.Same(t, counters, NewExpvarCounters(name))
+
+	var breaker = NewCircuitBreaker(CircuitBreakerOptionThreshold(2))
+	var attempts int
+	var rt = Chain{
+		NewExpvarCounting(counters),
+		NewRetrier(NewFixedBackoffPolicy(time.Millisecond), NewLimitedRetryPolicy(1, NewStatusCodeRetryPolicy(http.StatusServiceUnavailable))),
+		NewCircuitBreaking(breaker),
+	}.Apply(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		attempts = attempts + 1
+		return &http.Response{StatusCode: http.StatusServiceUnavailable, Body: http.NoBody}, nil
+	}))
+	var req, _ = http.NewRequest(http.MethodGet, "https://api.example.com/", nil)
+	var _, e = rt.RoundTrip(req)
+	require.NoError(t, e)
+	assert.Equal(t, 2, attempts)
+
+	counters.ObserveCache(true)
+	counters.ObserveCache(true)
+	counters.ObserveCache(true)
+	counters.ObserveCache(false)
+
+	assert.Equal(t, int64(1), counters.Requests())
+	assert.Equal(t, int64(1), counters.Retries())
+	assert.Equal(t, int64(0), counters.Hedges())
+	assert.Equal(t, int64(1), counters.BreakerTrips())
+	assert.Equal(t, .75, counters.CacheHitRatio())
+
+	var published map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(expvar.Get(name).String()), &published))
+	assert.Equal(t, map[string]interface{}{
+		"requests":        1.0,
+		"retries":         1.0,
+		"hedges":          0.0,
+		"breaker_trips":   1.0,
+		"cache_hits":      3.0,
+		"cache_misses":    1.0,
+		"cache_hit_ratio": .75,
+	}, published)
+}
+
+func TestExpvarCountingConfig(t *testing.T) {
+	var conf = NewChainComponent().Settings()
+	conf.Metrics.Expvar = "transport_test_config"
+	var before = NewExpvarCounters("transport_test_config").Requests()
+	var chain, e = conf.Chain()
+	require.NoError(t, e)
+	require.Len(t, chain, 1)
+	var req, _ = http.NewRequest(http.MethodGet, "https://api.example.com/", nil)
+	_, e = chain.Apply(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	})).RoundTrip(req)
+	require.NoError(t, e)
+	assert.Equal(t, before+1, NewExpvarCounters("transport_test_config").Requests())
+}
@@ -0,0 +1,256 @@
+package transport
+
+import (
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const failoverSource = "failover"
+
+// FailoverRegion is an endpoint that the Failover may send requests to.
+type FailoverRegion struct {
+	// Name identifies the region in events and in the result of Active.
+	Name string
+	// URL provides the scheme and host that replace those of requests sent
+	// to the region. Any other part of the URL is ignored.
+	URL *url.URL
+}
+
+type failoverState struct {
+	failures int
+	until    time.Time
+	// downs is the number of times the region has been held down without
+	// staying healthy for the maximum hold-down in between.
+	downs int
+}
+
+// Failover sends every request to the highest priority region that is
+// healthy. A region becomes unhealthy after a number of consecutive failures
+// and is held down, receiving no traffic, for a period that grows each time
+// it fails again soon after returning. Traffic fails back to a region as soon
+// as its hold-down ends. Credential headers are removed from requests sent
+// to a region outside the registrable domain of the original host. It is
+// safe for concurrent use.
+type Failover struct {
+	lock      sync.Mutex
+	regions   []FailoverRegion
+	states    []failoverState
+	active    int
+	failure   func(*http.Response, error) bool
+	threshold int
+	baseHold  time.Duration
+	maxHold   time.Duration
+	clock     Clock
+	strip     *StripCredentials
+}
+
+// FailoverOption is a configuration for the Failover.
+type FailoverOption func(*Failover) *Failover
+
+// FailoverOptionFailure sets the function that classifies outcomes as
+// failures. The default treats errors and 5xx status codes as failures.
+func FailoverOptionFailure(failure func(*http.Response, error) bool) FailoverOption {
+	return func(f *Failover) *Failover {
+		f.failure = failure
+		return f
+	}
+}
+
+// FailoverOptionThreshold sets the number of consecutive failures that make a
+// region unhealthy. The default is 5.
+func FailoverOptionThreshold(threshold int) FailoverOption {
+	return func(f *Failover) *Failover {
+		f.threshold = threshold
+		return f
+	}
+}
+
+// FailoverOptionHoldDown sets the base and maximum hold-down durations. Each
+// hold-down of a region lasts the base duration multiplied by the number of
+// times it has been held down, up to the maximum. The count starts over once
+// a region stays healthy for the maximum duration. The defaults are 30
+// seconds and 5 minutes.
+func FailoverOptionHoldDown(base time.Duration, max time.Duration) FailoverOption {
+	return func(f *Failover) *Failover {
+		f.baseHold = base
+		f.maxHold = max
+		return f
+	}
+}
+
+// FailoverOptionStripCredentials sets the StripCredentials that decides which
+// headers are removed from requests sent to a region in another domain and
+// which domains are trusted. The default is NewStripCredentials().
+func FailoverOptionStripCredentials(strip *StripCredentials) FailoverOption {
+	return func(f *Failover) *Failover {
+		f.strip = strip
+		return f
+	}
+}
+
+// FailoverOptionKeepCredentials sends credential headers to every region,
+// whatever its domain. It is only safe when every region is operated by the
+// same organization as the original host.
+func FailoverOptionKeepCredentials() FailoverOption {
+	return func(f *Failover) *Failover {
+		f.strip = nil
+		return f
+	}
+}
+
+// FailoverOptionClock configures the Clock used to time hold-downs.
+func FailoverOptionClock(clock Clock) FailoverOption {
+	return func(f *Failover) *Failover {
+		f.clock = clock
+		return f
+	}
+}
+
+// NewFailover creates a Failover over the regions, which are given in order
+// of priority.
+func NewFailover(regions []FailoverRegion, opts ...FailoverOption) *Failover {
+	var f = &Failover{
+		regions:   regions,
+		states:    make([]failoverState, len(regions)),
+		failure:   defaultHealthFailure,
+		threshold: 5,
+		baseHold:  30 * time.Second,
+		maxHold:   5 * time.Minute,
+		clock:     NewSystemClock(),
+		strip:     NewStripCredentials(),
+	}
+	for _, opt := range opts {
+		f = opt(f)
+	}
+	if f.threshold < 1 {
+		f.threshold = 1
+	}
+	return f
+}
+
+// Active returns the name of the region that currently receives traffic.
+func (f *Failover) Active() string {
+	if len(f.regions) < 1 {
+		return ""
+	}
+	var now = f.clock.Now()
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.regions[f.choose(now)].Name
+}
+
+// Down returns the names of the regions that are held down, in order of
+// priority.
+func (f *Failover) Down() []string {
+	var now = f.clock.Now()
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	var down []string
+	for x, state := range f.states {
+		if now.Before(state.until) {
+			down = append(down, f.regions[x].Name)
+		}
+	}
+	return down
+}
+
+// choose returns the offset of the highest priority region that is not held
+// down. If every region is held down then the highest priority one is used.
+// It must be called while holding the lock.
+func (f *Failover) choose(now time.Time) int {
+	for x, state := range f.states {
+		if !now.Before(state.until) {
+			return x
+		}
+	}
+	return 0
+}
+
+// record updates the region with the outcome of a request sent to it and
+// reports whether the region was held down as a result.
+func (f *Failover) record(offset int, failed bool) bool {
+	var now = f.clock.Now()
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	var state = &f.states[offset]
+	if !failed {
+		state.failures = 0
+		return false
+	}
+	if now.Before(state.until) {
+		// Outcomes of requests sent before the hold-down, or while every
+		// region is held down, do not extend it.
+		return false
+	}
+	state.failures = state.failures + 1
+	if state.failures < f.threshold {
+		return false
+	}
+	if !state.until.IsZero() && now.Sub(state.until) >= f.maxHold {
+		state.downs = 0
+	}
+	state.downs = state.downs + 1
+	var hold = f.baseHold * time.Duration(state.downs)
+	if hold > f.maxHold {
+		hold = f.maxHold
+	}
+	state.failures = 0
+	state.until = now.Add(hold)
+	return true
+}
+
+type failoverTransport struct {
+	wrapped  http.RoundTripper
+	failover *Failover
+}
+
+// RoundTrip sends the request to the active region and records the outcome.
+func (c *failoverTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	var f = c.failover
+	if len(f.regions) < 1 {
+		return c.wrapped.RoundTrip(r)
+	}
+	var now = f.clock.Now()
+	f.lock.Lock()
+	var offset = f.choose(now)
+	var changed = offset != f.active
+	f.active = offset
+	f.lock.Unlock()
+	var region = f.regions[offset]
+	if changed {
+		emitEvent(r.Context(), TransportEvent{
+			Type:     EventRegionFailover,
+			Source:   failoverSource,
+			Request:  r,
+			Instance: offset,
+			Region:   region.Name,
+		})
+	}
+
+	var original = r.URL.Hostname()
+	r = r.Clone(r.Context())
+	r.URL.Scheme = region.URL.Scheme
+	r.URL.Host = region.URL.Host
+	r.Host = ""
+	if !stripOnHostChange(r) && f.strip != nil && !f.strip.trusted(original, r.URL.Hostname()) {
+		f.strip.strip(r.Header)
+	}
+	var resp, e = c.wrapped.RoundTrip(r)
+	f.record(offset, f.failure(resp, e))
+	return resp, e
+}
+
+// NewFailoverRouting configures a RoundTripper decorator that sends every
+// request to the region selected by the failover. Installing it inside a
+// retry decorator lets retries of failed requests reach the next region once
+// the failed one is held down. Installing it inside
+// StripCredentials.OnHostChange applies that policy, relative to the host
+// the request had before any decorator changed it, in place of the one
+// configured for the Failover.
+func NewFailoverRouting(failover *Failover) func(http.RoundTripper) http.RoundTripper {
+	return func(wrapped http.RoundTripper) http.RoundTripper {
+		return &failoverTransport{wrapped: wrapped, failover: failover}
+	}
+}
@@ -0,0 +1,131 @@
+package transport
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFailoverRouting(t *testing.T) {
+	var clock = newFakeClock()
+	var failover = NewFailover(
+		[]FailoverRegion{
+			{Name: "east", URL: &url.URL{Scheme: "https", Host: "east.example.com"}},
+			{Name: "west", URL: &url.URL{Scheme: "https", Host: "west.example.com"}},
+		},
+		FailoverOptionThreshold(2),
+		FailoverOptionHoldDown(time.Minute, 3*time.Minute),
+		FailoverOptionClock(clock),
+	)
+	var failing = map[string]bool{"east.example.com": true}
+	var hosts []string
+	var rt = NewFailoverRouting(failover)(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		hosts = append(hosts, r.URL.Host)
+		if failing[r.URL.Host] {
+			return &http.Response{StatusCode: http.StatusBadGateway, Body: http.NoBody}, nil
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	}))
+	var collector = &eventCollector{}
+	var req, _ = http.NewRequest(http.MethodGet, "http://api.example.com/path?q=1", nil)
+	req = req.WithContext(WithEventSubscriber(context.Background(), collector))
+
+	for x := 0; x < 3; x = x + 1 {
+		var _, e = rt.RoundTrip(req)
+		require.NoError(t, e)
+	}
+	assert.Equal(t, []string{"east.example.com", "east.example.com", "west.example.com"}, hosts)
+	assert.Equal(t, "api.example.com", req.URL.Host, "request was modified")
+	assert.Equal(t, "west", failover.Active())
+	assert.Equal(t, []string{"east"}, failover.Down())
+	require.Equal(t, []EventType{EventRegionFailover}, collector.types())
+	assert.Equal(t, "west", collector.events[0].Region)
+
+	// Traffic fails back once the hold-down ends.
+	failing["east.example.com"] = false
+	clock.advance(time.Minute)
+	hosts = nil
+	var _, e = rt.RoundTrip(req)
+	require.NoError(t, e)
+	assert.Equal(t, []string{"east.example.com"}, hosts)
+	assert.Equal(t, "east", collector.events[1].Region)
+
+	// Failing again soon after returning holds the region down for longer.
+	failing["east.example.com"] = true
+	_, _ = rt.RoundTrip(req)
+	_, _ = rt.RoundTrip(req)
+	clock.advance(time.Minute)
+	assert.Equal(t, "west", failover.Active())
+	clock.advance(time.Minute)
+	assert.Equal(t, "east", failover.Active())
+}
+
+func TestFailoverAllDown(t *testing.T) {
+	var failover = NewFailover(
+		[]FailoverRegion{
+			{Name: "east", URL: &url.URL{Scheme: "https", Host: "east.example.com"}},
+			{Name: "west", URL: &url.URL{Scheme: "https", Host: "west.example.com"}},
+		},
+		FailoverOptionThreshold(1),
+	)
+	var rt = NewFailoverRouting(failover)(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusServiceUnavailable, Body: http.NoBody}, nil
+	}))
+	var req, _ = http.NewRequest(http.MethodGet, "http://api.example.com/", nil)
+	_, _ = rt.RoundTrip(req)
+	_, _ = rt.RoundTrip(req)
+	assert.Equal(t, []string{"east", "west"}, failover.Down())
+	assert.Equal(t, "east", failover.Active())
+}
+
+func TestFailoverStripsCredentials(t *testing.T) {
+	var regions = []FailoverRegion{
+		{Name: "primary", URL: &url.URL{Scheme: "https", Host: "east.example.com"}},
+		{Name: "recovery", URL: &url.URL{Scheme: "https", Host: "api.recovery.net"}},
+	}
+	for _, tc := range []struct {
+		name  string
+		opts  []FailoverOption
+		strip bool
+	}{
+		{name: "default", strip: true},
+		{name: "trusted", opts: []FailoverOption{FailoverOptionStripCredentials(NewStripCredentials(StripCredentialsOptionTrustedGroup("example.com", "recovery.net")))}},
+		{name: "keep", opts: []FailoverOption{FailoverOptionKeepCredentials()}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var failover = NewFailover(regions, append(tc.opts, FailoverOptionThreshold(1))...)
+			var seen http.Header
+			var rt = NewFailoverRouting(failover)(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+				seen = r.Header.Clone()
+				if r.URL.Host == "east.example.com" {
+					return &http.Response{StatusCode: http.StatusBadGateway, Body: http.NoBody}, nil
+				}
+				return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+			}))
+			var req, _ = http.NewRequest(http.MethodGet, "https://api.example.com/", nil)
+			req.Header.Set("Authorization", "Bearer token")
+			req.Header.Set("Cookie", "session=1")
+
+			var _, e = rt.RoundTrip(req)
+			require.NoError(t, e)
+			assert.Equal(t, "Bearer token", seen.Get("Authorization"), "same registrable domain")
+			assert.Equal(t, "session=1", seen.Get("Cookie"))
+
+			_, e = rt.RoundTrip(req)
+			require.NoError(t, e)
+			if tc.strip {
+				assert.Empty(t, seen.Get("Authorization"))
+				assert.Empty(t, seen.Get("Cookie"))
+			} else {
+				assert.Equal(t, "Bearer token", seen.Get("Authorization"))
+				assert.Equal(t, "session=1", seen.Get("Cookie"))
+			}
+			assert.Equal(t, "Bearer token", req.Header.Get("Authorization"), "request was modified")
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package transport
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// FlagProvider evaluates feature flags, such as those managed by
+// LaunchDarkly or a configuration service.
+type FlagProvider interface {
+	// Enabled reports whether the flag is on for the request. An error
+	// causes the default value of the FeatureFlag to be used.
+	Enabled(r *http.Request, flag string) (bool, error)
+}
+
+// FlagProviderFunc adapts a function to the FlagProvider interface.
+type FlagProviderFunc func(r *http.Request, flag string) (bool, error)
+
+// Enabled calls the function.
+func (f FlagProviderFunc) Enabled(r *http.Request, flag string) (bool, error) {
+	return f(r, flag)
+}
+
+// maxFlagCacheEntries bounds the number of cached evaluations so that a key
+// with many distinct values, such as a user ID, cannot grow without limit.
+const maxFlagCacheEntries = 1024
+
+type flagValue struct {
+	enabled bool
+	expires time.Time
+}
+
+// FeatureFlag is a decorator that sends each request through another
+// decorator only while a feature flag is on, so that behaviors like hedging
+// or canary routing can be rolled out gradually or switched off without a
+// deploy. Evaluations are cached to keep the flag provider off the request
+// path.
+type FeatureFlag struct {
+	provider FlagProvider
+	flag     string
+	fallback bool
+	ttl      time.Duration
+	key      func(*http.Request) string
+	clock    Clock
+	enabled  http.RoundTripper
+	disabled http.RoundTripper
+	lock     sync.Mutex
+	cache    map[string]flagValue
+}
+
+// FeatureFlagOption is a configuration for the FeatureFlag decorator.
+type FeatureFlagOption func(*FeatureFlag) *FeatureFlag
+
+// FeatureFlagOptionDefault sets the value used when the provider returns an
+// error. The default is false so that a failing provider disables the
+// decorator.
+func FeatureFlagOptionDefault(enabled bool) FeatureFlagOption {
+	return func(f *FeatureFlag) *FeatureFlag {
+		f.fallback = enabled
+		return f
+	}
+}
+
+// FeatureFlagOptionCacheTTL sets how long an evaluation is reused. A TTL of
+// zero consults the provider for every request. The default is one second.
+func FeatureFlagOptionCacheTTL(ttl time.Duration) FeatureFlagOption {
+	return func(f *FeatureFlag) *FeatureFlag {
+		f.ttl = ttl
+		return f
+	}
+}
+
+// FeatureFlagOptionCacheKey sets the function that selects the cached
+// evaluation for a request. Flags that target individual users or tenants
+// should key the cache by the same attribute. The default caches a single
+// evaluation for all requests.
+func FeatureFlagOptionCacheKey(key func(*http.Request) string) FeatureFlagOption {
+	return func(f *FeatureFlag) *FeatureFlag {
+		f.key = key
+		return f
+	}
+}
+
+// FeatureFlagOptionClock configures the Clock used to expire cached
+// evaluations.
+func FeatureFlagOptionClock(clock Clock) FeatureFlagOption {
+	return func(f *FeatureFlag) *FeatureFlag {
+		f.clock = clock
+		return f
+	}
+}
+
+// RoundTrip sends the request through the decorator if the flag is on and
+// directly to the wrapped transport otherwise.
+func (f *FeatureFlag) RoundTrip(r *http.Request) (*http.Response, error) {
+	if f.evaluate(r) {
+		return f.enabled.RoundTrip(r)
+	}
+	return f.disabled.RoundTrip(r)
+}
+
+func (f *FeatureFlag) evaluate(r *http.Request) bool {
+	if f.ttl <= 0 {
+		return f.lookup(r)
+	}
+	var key = f.key(r)
+	var now = f.clock.Now()
+	f.lock.Lock()
+	var cached, ok = f.cache[key]
+	f.lock.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.enabled
+	}
+	// Failed evaluations are cached as well so that an unavailable provider
+	// is not called for every request.
+	var enabled = f.lookup(r)
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if _, ok := f.cache[key]; !ok && len(f.cache) >= maxFlagCacheEntries {
+		for k, v := range f.cache {
+			if !now.Before(v.expires) {
+				delete(f.cache, k)
+			}
+		}
+		if len(f.cache) >= maxFlagCacheEntries {
+			f.cache = make(map[string]flagValue)
+		}
+	}
+	f.cache[key] = flagValue{enabled: enabled, expires: now.Add(f.ttl)}
+	return enabled
+}
+
+func (f *FeatureFlag) lookup(r *http.Request) bool {
+	var enabled, e = f.provider.Enabled(r, f.flag)
+	if e != nil {
+		return f.fallback
+	}
+	return enabled
+}
+
+// NewFeatureFlag configures a RoundTripper decorator that applies the
+// decorator to requests only while the flag is enabled by the provider. The
+// decorator is applied once so that its state is kept while the flag is
+// toggled.
+func NewFeatureFlag(provider FlagProvider, flag string, decorator Decorator, opts ...FeatureFlagOption) func(http.RoundTripper) http.RoundTripper {
+	return func(wrapped http.RoundTripper) http.RoundTripper {
+		var f = &FeatureFlag{
+			provider: provider,
+			flag:     flag,
+			ttl:      time.Second,
+			key:      func(*http.Request) string { return "" },
+			clock:    NewSystemClock(),
+			enabled:  decorator(wrapped),
+			disabled: wrapped,
+			cache:    make(map[string]flagValue),
+		}
+		for _, opt := range opts {
+			f = opt(f)
+		}
+		return f
+	}
+}
@@ -0,0 +1,75 @@
+package transport
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeatureFlag(t *testing.T) {
+	var clock = newFakeClock()
+	var enabled = map[string]bool{}
+	var lookups int
+	var failure error
+	var provider = FlagProviderFunc(func(r *http.Request, flag string) (bool, error) {
+		lookups = lookups + 1
+		assert.Equal(t, "hedging", flag)
+		return enabled[r.Header.Get("X-Tenant")], failure
+	})
+	var rt = NewFeatureFlag(provider, "hedging", newHeaderDecorator("flagged"),
+		FeatureFlagOptionClock(clock),
+		FeatureFlagOptionCacheKey(func(r *http.Request) string { return r.Header.Get("X-Tenant") }),
+	)(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{"X-Route": []string{r.Header.Get("X-Route")}}, Body: http.NoBody}, nil
+	}))
+	var send = func(tenant string) string {
+		var req, _ = http.NewRequest(http.MethodGet, "https://example.com/", nil)
+		req.Header.Set("X-Tenant", tenant)
+		var resp, e = rt.RoundTrip(req)
+		require.NoError(t, e)
+		return resp.Header.Get("X-Route")
+	}
+
+	enabled["a"] = true
+	assert.Equal(t, "flagged", send("a"))
+	assert.Equal(t, "", send("b"))
+	assert.Equal(t, 2, lookups)
+
+	enabled["a"] = false
+	assert.Equal(t, "flagged", send("a"), "cached evaluation was not used")
+	assert.Equal(t, 2, lookups)
+
+	clock.advance(time.Second)
+	assert.Equal(t, "", send("a"))
+	assert.Equal(t, 3, lookups)
+
+	clock.advance(time.Second)
+	enabled["a"] = true
+	failure = errors.New("unavailable")
+	assert.Equal(t, "", send("a"), "failed evaluation did not use the default")
+}
+
+func TestFeatureFlagUncached(t *testing.T) {
+	var lookups int
+	var provider = FlagProviderFunc(func(*http.Request, string) (bool, error) {
+		lookups = lookups + 1
+		return false, errors.New("unavailable")
+	})
+	var rt = NewFeatureFlag(provider, "canary", newHeaderDecorator("flagged"),
+		FeatureFlagOptionCacheTTL(0),
+		FeatureFlagOptionDefault(true),
+	)(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{"X-Route": []string{r.Header.Get("X-Route")}}, Body: http.NoBody}, nil
+	}))
+	for x := 0; x < 3; x = x + 1 {
+		var req, _ = http.NewRequest(http.MethodGet, "https://example.com/", nil)
+		var resp, e = rt.RoundTrip(req)
+		require.NoError(t, e)
+		assert.Equal(t, "flagged", resp.Header.Get("X-Route"))
+	}
+	assert.Equal(t, 3, lookups)
+}
@@ -0,0 +1,141 @@
+package transport
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ForwardedInfo describes the inbound request on whose behalf outbound
+// requests are made.
+type ForwardedInfo struct {
+	// For is the address of the client that made the inbound request.
+	For string
+	// Proto is the scheme of the inbound request, either http or https.
+	Proto string
+	// Host is the Host header of the inbound request.
+	Host string
+}
+
+// NewForwardedInfo extracts the ForwardedInfo of an inbound server request.
+func NewForwardedInfo(r *http.Request) ForwardedInfo {
+	var info = ForwardedInfo{Proto: "http", Host: r.Host, For: r.RemoteAddr}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		info.For = host
+	}
+	if r.TLS != nil {
+		info.Proto = "https"
+	}
+	return info
+}
+
+type forwardedInfoKey struct{}
+
+// WithForwardedInfo installs the ForwardedInfo of an inbound request in the
+// context for use by the Forwarded decorator.
+func WithForwardedInfo(ctx context.Context, info ForwardedInfo) context.Context {
+	return context.WithValue(ctx, forwardedInfoKey{}, info)
+}
+
+// ForwardedInfoFromContext returns the ForwardedInfo installed in the context
+// and whether there was one.
+func ForwardedInfoFromContext(ctx context.Context) (ForwardedInfo, bool) {
+	var info, ok = ctx.Value(forwardedInfoKey{}).(ForwardedInfo)
+	return info, ok
+}
+
+// Forwarded is a decorator that describes the inbound request found in the
+// request context using the X-Forwarded-For, X-Forwarded-Proto,
+// X-Forwarded-Host, and Forwarded headers.
+type Forwarded struct {
+	wrapped      http.RoundTripper
+	stripInbound bool
+}
+
+// ForwardedOption is a configuration for the Forwarded decorator.
+type ForwardedOption func(*Forwarded) *Forwarded
+
+// ForwardedOptionStripInbound removes any forwarding headers already present
+// on outgoing requests, such as those copied from an inbound request, so that
+// clients cannot spoof the chain of proxies.
+func ForwardedOptionStripInbound() ForwardedOption {
+	return func(f *Forwarded) *Forwarded {
+		f.stripInbound = true
+		return f
+	}
+}
+
+var forwardingHeaders = []string{"X-Forwarded-For", "X-Forwarded-Proto", "X-Forwarded-Host", "Forwarded"}
+
+// RoundTrip sets the forwarding headers and calls the wrapped transport.
+func (c *Forwarded) RoundTrip(r *http.Request) (*http.Response, error) {
+	var info, ok = ForwardedInfoFromContext(r.Context())
+	if !ok && !c.stripInbound {
+		return c.wrapped.RoundTrip(r)
+	}
+	r = r.Clone(r.Context())
+	if r.Header == nil {
+		r.Header = http.Header{}
+	}
+	if c.stripInbound {
+		for _, name := range forwardingHeaders {
+			r.Header.Del(name)
+		}
+	}
+	if ok {
+		setForwarded(r.Header, info)
+	}
+	return c.wrapped.RoundTrip(r)
+}
+
+func setForwarded(header http.Header, info ForwardedInfo) {
+	var elements = make([]string, 0, 3)
+	if info.For != "" {
+		header.Set("X-Forwarded-For", appendList(header.Values("X-Forwarded-For"), info.For))
+		elements = append(elements, "for="+forwardedNode(info.For))
+	}
+	if info.Proto != "" {
+		header.Set("X-Forwarded-Proto", info.Proto)
+		elements = append(elements, "proto="+info.Proto)
+	}
+	if info.Host != "" {
+		header.Set("X-Forwarded-Host", info.Host)
+		elements = append(elements, "host="+forwardedQuote(info.Host))
+	}
+	if len(elements) > 0 {
+		header.Set("Forwarded", appendList(header.Values("Forwarded"), strings.Join(elements, ";")))
+	}
+}
+
+func appendList(existing []string, value string) string {
+	return strings.Join(append(existing, value), ", ")
+}
+
+// forwardedNode formats an address for the Forwarded header as described by
+// RFC 7239. IPv6 addresses must be bracketed and quoted.
+func forwardedNode(addr string) string {
+	if ip := net.ParseIP(addr); ip != nil && ip.To4() == nil {
+		return `"[` + addr + `]"`
+	}
+	return forwardedQuote(addr)
+}
+
+func forwardedQuote(value string) string {
+	if strings.ContainsAny(value, ":[]\",; ") {
+		return `"` + strings.ReplaceAll(value, `"`, `\"`) + `"`
+	}
+	return value
+}
+
+// NewForwarded configures a RoundTripper decorator that adds forwarding
+// headers to requests made with a context from WithForwardedInfo.
+func NewForwarded(opts ...ForwardedOption) func(http.RoundTripper) http.RoundTripper {
+	return func(wrapped http.RoundTripper) http.RoundTripper {
+		var f = &Forwarded{wrapped: wrapped}
+		for _, opt := range opts {
+			f = opt(f)
+		}
+		return f
+	}
+}
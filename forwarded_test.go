@@ -0,0 +1,53 @@
+package transport
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewForwardedInfo(t *testing.T) {
+	var inbound = httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	inbound.RemoteAddr = "192.0.2.60:4000"
+	assert.Equal(t, ForwardedInfo{For: "192.0.2.60", Proto: "http", Host: "example.com"}, NewForwardedInfo(inbound))
+	inbound.TLS = &tls.ConnectionState{}
+	assert.Equal(t, "https", NewForwardedInfo(inbound).Proto)
+}
+
+func TestForwarded(t *testing.T) {
+	var fixture = &fixtureHeaderTransport{Response: &http.Response{StatusCode: http.StatusOK}}
+	var rt = NewForwarded()(fixture)
+	var ctx = WithForwardedInfo(context.Background(), ForwardedInfo{For: "2001:db8::1", Proto: "https", Host: "example.com"})
+	var req, _ = http.NewRequestWithContext(ctx, http.MethodGet, "http://upstream/", nil)
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+	req.Header.Set("Forwarded", "for=198.51.100.1")
+	var _, e = rt.RoundTrip(req)
+	assert.NoError(t, e)
+	assert.Equal(t, "198.51.100.1, 2001:db8::1", fixture.Request.Header.Get("X-Forwarded-For"))
+	assert.Equal(t, "https", fixture.Request.Header.Get("X-Forwarded-Proto"))
+	assert.Equal(t, "example.com", fixture.Request.Header.Get("X-Forwarded-Host"))
+	assert.Equal(t, `for=198.51.100.1, for="[2001:db8::1]";proto=https;host=example.com`, fixture.Request.Header.Get("Forwarded"))
+	assert.Equal(t, "198.51.100.1", req.Header.Get("X-Forwarded-For"), "the original request was modified")
+}
+
+func TestForwardedStripInbound(t *testing.T) {
+	var fixture = &fixtureHeaderTransport{Response: &http.Response{StatusCode: http.StatusOK}}
+	var rt = NewForwarded(ForwardedOptionStripInbound())(fixture)
+
+	var req, _ = http.NewRequest(http.MethodGet, "http://upstream/", nil)
+	req.Header.Set("X-Forwarded-For", "spoofed")
+	req.Header.Set("X-Forwarded-Host", "spoofed")
+	_, _ = rt.RoundTrip(req)
+	assert.Empty(t, fixture.Request.Header.Get("X-Forwarded-For"))
+	assert.Empty(t, fixture.Request.Header.Get("X-Forwarded-Host"))
+
+	req = req.WithContext(WithForwardedInfo(context.Background(), ForwardedInfo{For: "192.0.2.60"}))
+	_, _ = rt.RoundTrip(req)
+	assert.Equal(t, "192.0.2.60", fixture.Request.Header.Get("X-Forwarded-For"))
+	assert.Equal(t, "for=192.0.2.60", fixture.Request.Header.Get("Forwarded"))
+	assert.Empty(t, fixture.Request.Header.Get("X-Forwarded-Host"))
+}
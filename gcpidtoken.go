@@ -0,0 +1,317 @@
+package transport
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	gcpMetadataURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/identity"
+	gcpTokenURL    = "https://oauth2.googleapis.com/token"
+	// gcpIDTokenEarlyExpiry is how long before its expiry a cached token is
+	// replaced so that it does not expire in flight.
+	gcpIDTokenEarlyExpiry = 5 * time.Minute
+)
+
+// IDTokenSource provides OpenID Connect identity tokens for an audience.
+type IDTokenSource interface {
+	IDToken(ctx context.Context, audience string) (string, error)
+}
+
+// IDTokenSourceFunc converts a function to an IDTokenSource.
+type IDTokenSourceFunc func(ctx context.Context, audience string) (string, error)
+
+// IDToken calls the wrapped function.
+func (f IDTokenSourceFunc) IDToken(ctx context.Context, audience string) (string, error) {
+	return f(ctx, audience)
+}
+
+// GCPIDTokens is an IDTokenSource of Google-signed identity tokens. Tokens
+// are cached per audience and replaced shortly before they expire.
+type GCPIDTokens struct {
+	fetch    func(ctx context.Context, audience string) (string, error)
+	endpoint string
+	client   *http.Client
+	clock    Clock
+	lock     sync.Mutex
+	tokens   map[string]gcpIDToken
+}
+
+type gcpIDToken struct {
+	value   string
+	expires time.Time
+}
+
+// GCPIDTokenOption is a configuration for the GCPIDTokens.
+type GCPIDTokenOption func(*GCPIDTokens) *GCPIDTokens
+
+// GCPIDTokenOptionEndpoint replaces the URL that tokens are requested from,
+// which is the identity endpoint of the metadata server or the token URI of
+// the service account.
+func GCPIDTokenOptionEndpoint(endpoint string) GCPIDTokenOption {
+	return func(g *GCPIDTokens) *GCPIDTokens {
+		g.endpoint = endpoint
+		return g
+	}
+}
+
+// GCPIDTokenOptionClient sets the client used to request tokens. The default
+// is a client created by NewClient.
+func GCPIDTokenOptionClient(client *http.Client) GCPIDTokenOption {
+	return func(g *GCPIDTokens) *GCPIDTokens {
+		g.client = client
+		return g
+	}
+}
+
+// GCPIDTokenOptionClock configures the Clock used to expire cached tokens.
+func GCPIDTokenOptionClock(clock Clock) GCPIDTokenOption {
+	return func(g *GCPIDTokens) *GCPIDTokens {
+		g.clock = clock
+		return g
+	}
+}
+
+func newGCPIDTokens(endpoint string, opts ...GCPIDTokenOption) *GCPIDTokens {
+	var g = &GCPIDTokens{
+		endpoint: endpoint,
+		clock:    NewSystemClock(),
+		tokens:   make(map[string]gcpIDToken),
+	}
+	for _, opt := range opts {
+		g = opt(g)
+	}
+	if g.client == nil {
+		g.client = NewClient()
+	}
+	return g
+}
+
+// NewGCPMetadataIDTokens creates a GCPIDTokens that requests tokens for the
+// default service account from the metadata server, as available on Compute
+// Engine, GKE, Cloud Run, and Cloud Functions.
+func NewGCPMetadataIDTokens(opts ...GCPIDTokenOption) *GCPIDTokens {
+	var g = newGCPIDTokens(gcpMetadataURL, opts...)
+	g.fetch = g.fetchMetadata
+	return g
+}
+
+type gcpServiceAccount struct {
+	ClientEmail  string `json:"client_email"`
+	PrivateKey   string `json:"private_key"`
+	PrivateKeyID string `json:"private_key_id"`
+	TokenURI     string `json:"token_uri"`
+}
+
+// NewGCPServiceAccountIDTokens creates a GCPIDTokens that exchanges
+// assertions signed with the key of a service account for tokens. The
+// credentials are the contents of a service account key file.
+func NewGCPServiceAccountIDTokens(credentials []byte, opts ...GCPIDTokenOption) (*GCPIDTokens, error) {
+	var account gcpServiceAccount
+	if e := json.Unmarshal(credentials, &account); e != nil {
+		return nil, e
+	}
+	var block, _ = pem.Decode([]byte(account.PrivateKey))
+	if block == nil {
+		return nil, errors.New("transport: service account private key is not PEM encoded")
+	}
+	var parsed, e = x509.ParsePKCS8PrivateKey(block.Bytes)
+	if e != nil {
+		return nil, e
+	}
+	var key, ok = parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("transport: service account private key is not an RSA key")
+	}
+	var endpoint = account.TokenURI
+	if endpoint == "" {
+		endpoint = gcpTokenURL
+	}
+	var g = newGCPIDTokens(endpoint, opts...)
+	g.fetch = func(ctx context.Context, audience string) (string, error) {
+		return g.fetchServiceAccount(ctx, account, key, audience)
+	}
+	return g, nil
+}
+
+// IDToken returns a cached token for the audience or requests a new one.
+func (g *GCPIDTokens) IDToken(ctx context.Context, audience string) (string, error) {
+	var now = g.clock.Now()
+	g.lock.Lock()
+	var cached, ok = g.tokens[audience]
+	g.lock.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.value, nil
+	}
+	var token, e = g.fetch(ctx, audience)
+	if e != nil {
+		return "", e
+	}
+	var expires time.Time
+	if expires, e = jwtExpiry(token); e != nil {
+		return "", e
+	}
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.tokens[audience] = gcpIDToken{value: token, expires: expires.Add(-gcpIDTokenEarlyExpiry)}
+	return token, nil
+}
+
+// jwtExpiry reads the exp claim of a token without verifying it.
+func jwtExpiry(token string) (time.Time, error) {
+	var parts = strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, errors.New("transport: identity token is not a JWT")
+	}
+	var payload, e = base64.RawURLEncoding.DecodeString(parts[1])
+	if e != nil {
+		return time.Time{}, e
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if e = json.Unmarshal(payload, &claims); e != nil {
+		return time.Time{}, e
+	}
+	return time.Unix(claims.Exp, 0), nil
+}
+
+func (g *GCPIDTokens) do(req *http.Request) ([]byte, error) {
+	var resp, e = g.client.Do(req)
+	if e != nil {
+		return nil, e
+	}
+	defer resp.Body.Close()
+	var body []byte
+	if body, e = io.ReadAll(io.LimitReader(resp.Body, 1<<20)); e != nil {
+		return nil, e
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("transport: identity token request failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
+
+func (g *GCPIDTokens) fetchMetadata(ctx context.Context, audience string) (string, error) {
+	var query = url.Values{"audience": []string{audience}, "format": []string{"full"}}
+	var req, e = http.NewRequestWithContext(ctx, http.MethodGet, g.endpoint+"?"+query.Encode(), nil)
+	if e != nil {
+		return "", e
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	var body []byte
+	if body, e = g.do(req); e != nil {
+		return "", e
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+func (g *GCPIDTokens) fetchServiceAccount(ctx context.Context, account gcpServiceAccount, key *rsa.PrivateKey, audience string) (string, error) {
+	var now = g.clock.Now()
+	var header, _ = json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": account.PrivateKeyID})
+	var claims, _ = json.Marshal(map[string]interface{}{
+		"iss":             account.ClientEmail,
+		"sub":             account.ClientEmail,
+		"aud":             g.endpoint,
+		"iat":             now.Unix(),
+		"exp":             now.Add(time.Hour).Unix(),
+		"target_audience": audience,
+	})
+	var unsigned = base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	var digest = sha256.Sum256([]byte(unsigned))
+	var signature, e = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if e != nil {
+		return "", e
+	}
+	var form = url.Values{
+		"grant_type": []string{"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  []string{unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)},
+	}
+	var req *http.Request
+	if req, e = http.NewRequestWithContext(ctx, http.MethodPost, g.endpoint, strings.NewReader(form.Encode())); e != nil {
+		return "", e
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	var body []byte
+	if body, e = g.do(req); e != nil {
+		return "", e
+	}
+	var token struct {
+		IDToken string `json:"id_token"`
+	}
+	if e = json.Unmarshal(body, &token); e != nil {
+		return "", e
+	}
+	return token.IDToken, nil
+}
+
+// GCPIdentityToken is a decorator that authenticates requests to Cloud Run,
+// IAP, and other services that accept Google-signed identity tokens by
+// sending a token for the audience of the request as a Bearer token.
+type GCPIdentityToken struct {
+	wrapped  http.RoundTripper
+	source   IDTokenSource
+	audience func(*http.Request) string
+}
+
+// GCPIdentityTokenOption is a configuration for the GCPIdentityToken
+// decorator.
+type GCPIdentityTokenOption func(*GCPIdentityToken) *GCPIdentityToken
+
+// GCPIdentityTokenOptionAudience sets a fixed audience, such as the client ID
+// of an IAP protected resource. The default audience is the scheme and host
+// of the request URL, which is what Cloud Run expects.
+func GCPIdentityTokenOptionAudience(audience string) GCPIdentityTokenOption {
+	return func(g *GCPIdentityToken) *GCPIdentityToken {
+		g.audience = func(*http.Request) string {
+			return audience
+		}
+		return g
+	}
+}
+
+func originAudience(r *http.Request) string {
+	return r.URL.Scheme + "://" + r.URL.Host
+}
+
+// RoundTrip adds an identity token to the request and calls the wrapped
+// transport. The request body is closed if no token can be obtained.
+func (c *GCPIdentityToken) RoundTrip(r *http.Request) (*http.Response, error) {
+	var token, e = c.source.IDToken(r.Context(), c.audience(r))
+	if e != nil {
+		if r.Body != nil {
+			_ = r.Body.Close()
+		}
+		return nil, e
+	}
+	r = r.Clone(r.Context())
+	r.Header.Set("Authorization", "Bearer "+token)
+	return c.wrapped.RoundTrip(r)
+}
+
+// NewGCPIdentityToken configures a RoundTripper decorator that sends
+// identity tokens from the source with every request.
+func NewGCPIdentityToken(source IDTokenSource, opts ...GCPIdentityTokenOption) func(http.RoundTripper) http.RoundTripper {
+	return func(wrapped http.RoundTripper) http.RoundTripper {
+		var g = &GCPIdentityToken{wrapped: wrapped, source: source, audience: originAudience}
+		for _, opt := range opts {
+			g = opt(g)
+		}
+		return g
+	}
+}
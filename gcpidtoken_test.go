@@ -0,0 +1,124 @@
+package transport
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestJWT creates an unsigned token with the audience and expiry.
+func newTestJWT(audience string, expires time.Time) string {
+	var claims = fmt.Sprintf(`{"aud":%q,"exp":%d}`, audience, expires.Unix())
+	return "e30." + base64.RawURLEncoding.EncodeToString([]byte(claims)) + ".c2ln"
+}
+
+func TestGCPMetadataIDTokens(t *testing.T) {
+	var clock = newFakeClock()
+	var requests int
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = requests + 1
+		assert.Equal(t, "Google", r.Header.Get("Metadata-Flavor"))
+		assert.Equal(t, "full", r.URL.Query().Get("format"))
+		_, _ = w.Write([]byte(newTestJWT(r.URL.Query().Get("audience"), clock.Now().Add(time.Hour))))
+	}))
+	defer server.Close()
+	var tokens = NewGCPMetadataIDTokens(GCPIDTokenOptionEndpoint(server.URL), GCPIDTokenOptionClock(clock))
+
+	var seen []string
+	var rt = NewGCPIdentityToken(tokens)(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		seen = append(seen, r.Header.Get("Authorization"))
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	}))
+	var req, _ = http.NewRequest(http.MethodGet, "https://service.run.app/path", nil)
+	_, _ = rt.RoundTrip(req)
+	_, _ = rt.RoundTrip(req)
+	assert.Equal(t, 1, requests, "token was not cached")
+	assert.Equal(t, "Bearer "+newTestJWT("https://service.run.app", time.Unix(3600, 0)), seen[0])
+	assert.Empty(t, req.Header.Get("Authorization"), "original request was modified")
+
+	clock.advance(56 * time.Minute)
+	_, _ = rt.RoundTrip(req)
+	assert.Equal(t, 2, requests, "token was not refreshed before expiry")
+}
+
+func TestGCPServiceAccountIDTokens(t *testing.T) {
+	var key, e = rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, e)
+	var der []byte
+	der, e = x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, e)
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "urn:ietf:params:oauth:grant-type:jwt-bearer", r.PostForm.Get("grant_type"))
+		var parts = strings.Split(r.PostForm.Get("assertion"), ".")
+		require.Len(t, parts, 3)
+		var signature, _ = base64.RawURLEncoding.DecodeString(parts[2])
+		var digest = sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		assert.NoError(t, rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature))
+		var payload, _ = base64.RawURLEncoding.DecodeString(parts[1])
+		var claims map[string]interface{}
+		assert.NoError(t, json.Unmarshal(payload, &claims))
+		assert.Equal(t, "sa@project.iam.gserviceaccount.com", claims["iss"])
+		assert.Equal(t, "client-id.apps.googleusercontent.com", claims["target_audience"])
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"id_token": newTestJWT("client-id.apps.googleusercontent.com", time.Now().Add(time.Hour)),
+		})
+	}))
+	defer server.Close()
+	var credentials, _ = json.Marshal(map[string]string{
+		"type":           "service_account",
+		"client_email":   "sa@project.iam.gserviceaccount.com",
+		"private_key_id": "key-id",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"token_uri":      server.URL,
+	})
+	var tokens *GCPIDTokens
+	tokens, e = NewGCPServiceAccountIDTokens(credentials)
+	require.NoError(t, e)
+
+	var authorization string
+	var rt = NewGCPIdentityToken(tokens, GCPIdentityTokenOptionAudience("client-id.apps.googleusercontent.com"))(
+		RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			authorization = r.Header.Get("Authorization")
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+		}),
+	)
+	var req, _ = http.NewRequest(http.MethodGet, "https://iap.example.com/", nil)
+	_, e = rt.RoundTrip(req)
+	require.NoError(t, e)
+	assert.True(t, strings.HasPrefix(authorization, "Bearer e30."))
+}
+
+func TestGCPIdentityTokenError(t *testing.T) {
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not found", http.StatusNotFound)
+	}))
+	defer server.Close()
+	var rt = NewGCPIdentityToken(NewGCPMetadataIDTokens(GCPIDTokenOptionEndpoint(server.URL)))(
+		RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			t.Fatal("request was sent without a token")
+			return nil, nil
+		}),
+	)
+	var body = &closeRecordingBody{Reader: strings.NewReader("payload")}
+	var req, _ = http.NewRequest(http.MethodPost, "https://service.run.app/", body)
+	var _, e = rt.RoundTrip(req)
+	assert.EqualError(t, e, "transport: identity token request failed with status 404: not found")
+	assert.True(t, body.closed, "request body was not closed")
+	_, e = NewGCPServiceAccountIDTokens([]byte(`{"private_key":"invalid"}`))
+	assert.Error(t, e)
+}
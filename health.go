@@ -0,0 +1,193 @@
+package transport
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// HealthStats summarizes the recent outcomes recorded for a key.
+type HealthStats struct {
+	// Requests is the number of outcomes in the window, which is at most the
+	// size of the tracker.
+	Requests int
+	// Failures is the number of failed outcomes in the window.
+	Failures int
+	// ErrorRate is Failures divided by Requests, or zero if there are none.
+	ErrorRate float64
+	// ConsecutiveFailures is the number of failures recorded since the last
+	// success. It is not limited to the window.
+	ConsecutiveFailures int
+}
+
+type healthRing struct {
+	outcomes    []bool
+	next        int
+	full        bool
+	failures    int
+	consecutive int
+}
+
+func (h *healthRing) record(failed bool) {
+	if h.full && h.outcomes[h.next] {
+		h.failures = h.failures - 1
+	}
+	h.outcomes[h.next] = failed
+	h.next = (h.next + 1) % len(h.outcomes)
+	h.full = h.full || h.next == 0
+	if failed {
+		h.failures = h.failures + 1
+		h.consecutive = h.consecutive + 1
+		return
+	}
+	h.consecutive = 0
+}
+
+func (h *healthRing) stats() HealthStats {
+	var stats = HealthStats{Requests: h.next, Failures: h.failures, ConsecutiveFailures: h.consecutive}
+	if h.full {
+		stats.Requests = len(h.outcomes)
+	}
+	if stats.Requests > 0 {
+		stats.ErrorRate = float64(stats.Failures) / float64(stats.Requests)
+	}
+	return stats
+}
+
+// HealthTracker keeps a sliding window of the most recent request outcomes
+// per key. It is safe for concurrent use so that a single tracker can be
+// shared by every component that reacts to upstream health, such as circuit
+// breakers, instance ejection, and transport recycling.
+type HealthTracker struct {
+	lock    sync.Mutex
+	size    int
+	rings   map[string]*healthRing
+	key     func(*http.Request) string
+	failure func(*http.Response, error) bool
+}
+
+// HealthTrackerOption is a configuration for the HealthTracker.
+type HealthTrackerOption func(*HealthTracker) *HealthTracker
+
+// HealthTrackerOptionSize sets the number of outcomes kept per key. The
+// default is 100.
+func HealthTrackerOptionSize(size int) HealthTrackerOption {
+	return func(t *HealthTracker) *HealthTracker {
+		t.size = size
+		return t
+	}
+}
+
+// HealthTrackerOptionKey sets the function that selects the key a request is
+// recorded under. The default is the host of the request URL.
+func HealthTrackerOptionKey(key func(*http.Request) string) HealthTrackerOption {
+	return func(t *HealthTracker) *HealthTracker {
+		t.key = key
+		return t
+	}
+}
+
+// HealthTrackerOptionFailure sets the function that classifies outcomes as
+// failures. The default treats errors and 5xx status codes as failures.
+func HealthTrackerOptionFailure(failure func(*http.Response, error) bool) HealthTrackerOption {
+	return func(t *HealthTracker) *HealthTracker {
+		t.failure = failure
+		return t
+	}
+}
+
+func hostKey(r *http.Request) string {
+	return r.URL.Host
+}
+
+func defaultHealthFailure(resp *http.Response, e error) bool {
+	return e != nil || resp.StatusCode >= http.StatusInternalServerError
+}
+
+// NewHealthTracker creates an empty HealthTracker.
+func NewHealthTracker(opts ...HealthTrackerOption) *HealthTracker {
+	var t = &HealthTracker{
+		size:    100,
+		rings:   make(map[string]*healthRing),
+		key:     hostKey,
+		failure: defaultHealthFailure,
+	}
+	for _, opt := range opts {
+		t = opt(t)
+	}
+	if t.size < 1 {
+		t.size = 1
+	}
+	return t
+}
+
+// Key returns the key that the request is recorded under.
+func (t *HealthTracker) Key(r *http.Request) string {
+	return t.key(r)
+}
+
+// Observe classifies and records the outcome of a request.
+func (t *HealthTracker) Observe(r *http.Request, resp *http.Response, e error) {
+	t.Record(t.key(r), t.failure(resp, e))
+}
+
+// Record adds an outcome for the key.
+func (t *HealthTracker) Record(key string, failed bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	var ring, ok = t.rings[key]
+	if !ok {
+		ring = &healthRing{outcomes: make([]bool, t.size)}
+		t.rings[key] = ring
+	}
+	ring.record(failed)
+}
+
+// Stats returns the HealthStats for the key.
+func (t *HealthTracker) Stats(key string) HealthStats {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if ring, ok := t.rings[key]; ok {
+		return ring.stats()
+	}
+	return HealthStats{}
+}
+
+// Reset discards all outcomes for the key.
+func (t *HealthTracker) Reset(key string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	delete(t.rings, key)
+}
+
+// Keys returns every key with recorded outcomes in sorted order.
+func (t *HealthTracker) Keys() []string {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	var keys = make([]string, 0, len(t.rings))
+	for k := range t.rings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type healthTransport struct {
+	wrapped http.RoundTripper
+	tracker *HealthTracker
+}
+
+// RoundTrip records the outcome of the request in the tracker.
+func (c *healthTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	var resp, e = c.wrapped.RoundTrip(r)
+	c.tracker.Observe(r, resp, e)
+	return resp, e
+}
+
+// NewHealthTracking configures a RoundTripper decorator that records the
+// outcome of every request in the tracker.
+func NewHealthTracking(tracker *HealthTracker) func(http.RoundTripper) http.RoundTripper {
+	return func(wrapped http.RoundTripper) http.RoundTripper {
+		return &healthTransport{wrapped: wrapped, tracker: tracker}
+	}
+}
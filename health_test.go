@@ -0,0 +1,63 @@
+package transport
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthTrackerWindow(t *testing.T) {
+	var tracker = NewHealthTracker(HealthTrackerOptionSize(4))
+	assert.Equal(t, HealthStats{}, tracker.Stats("host"))
+
+	tracker.Record("host", true)
+	tracker.Record("host", false)
+	tracker.Record("host", true)
+	assert.Equal(t, HealthStats{Requests: 3, Failures: 2, ErrorRate: 2.0 / 3, ConsecutiveFailures: 1}, tracker.Stats("host"))
+
+	tracker.Record("host", true)
+	tracker.Record("host", true)
+	assert.Equal(t, HealthStats{Requests: 4, Failures: 3, ErrorRate: .75, ConsecutiveFailures: 3}, tracker.Stats("host"))
+
+	tracker.Record("host", false)
+	tracker.Record("host", false)
+	assert.Equal(t, HealthStats{Requests: 4, Failures: 2, ErrorRate: .5}, tracker.Stats("host"))
+
+	assert.Equal(t, []string{"host"}, tracker.Keys())
+	tracker.Reset("host")
+	assert.Empty(t, tracker.Keys())
+}
+
+func TestHealthTracking(t *testing.T) {
+	var tracker = NewHealthTracker()
+	var outcomes = []error{nil, errors.New("failed"), nil}
+	var codes = []int{http.StatusOK, 0, http.StatusBadGateway}
+	var rt = NewHealthTracking(tracker)(RoundTripperFunc(func(*http.Request) (*http.Response, error) {
+		var e, code = outcomes[0], codes[0]
+		outcomes, codes = outcomes[1:], codes[1:]
+		if e != nil {
+			return nil, e
+		}
+		return &http.Response{StatusCode: code, Body: http.NoBody}, nil
+	}))
+	var req, _ = http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	for x := 0; x < 3; x = x + 1 {
+		_, _ = rt.RoundTrip(req)
+	}
+	assert.Equal(t, HealthStats{Requests: 3, Failures: 2, ErrorRate: 2.0 / 3, ConsecutiveFailures: 2}, tracker.Stats("example.com"))
+}
+
+func TestHealthTrackerCustomFailure(t *testing.T) {
+	var tracker = NewHealthTracker(
+		HealthTrackerOptionKey(func(*http.Request) string { return "all" }),
+		HealthTrackerOptionFailure(func(resp *http.Response, e error) bool {
+			return e != nil || resp.StatusCode == http.StatusTooManyRequests
+		}),
+	)
+	var req, _ = http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	tracker.Observe(req, &http.Response{StatusCode: http.StatusTooManyRequests}, nil)
+	tracker.Observe(req, &http.Response{StatusCode: http.StatusInternalServerError}, nil)
+	assert.Equal(t, 1, tracker.Stats("all").Failures)
+}
@@ -0,0 +1,139 @@
+package transport
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ComponentHealth is the state of a single monitored component, such as one
+// upstream host or one circuit breaker.
+type ComponentHealth struct {
+	Name    string                 `json:"name"`
+	Healthy bool                   `json:"healthy"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// HealthSnapshot aggregates the health of every component registered with a
+// HealthReporter. It is healthy only if every component is.
+type HealthSnapshot struct {
+	Healthy    bool              `json:"healthy"`
+	Time       time.Time         `json:"time"`
+	Components []ComponentHealth `json:"components"`
+}
+
+// HealthSource is implemented by stateful decorators and trackers that can
+// report on the health of the components they observe.
+type HealthSource interface {
+	Health() []ComponentHealth
+}
+
+// HealthSourceFunc converts a function to a HealthSource.
+type HealthSourceFunc func() []ComponentHealth
+
+// Health calls the wrapped function.
+func (f HealthSourceFunc) Health() []ComponentHealth {
+	return f()
+}
+
+// HealthReporter collects HealthSources into a single HealthSnapshot that a
+// service can expose from its readiness endpoint. It implements http.Handler
+// by writing the snapshot as JSON with a 503 status when unhealthy.
+type HealthReporter struct {
+	lock    sync.RWMutex
+	sources []namedHealthSource
+}
+
+type namedHealthSource struct {
+	name   string
+	source HealthSource
+}
+
+// NewHealthReporter creates a HealthReporter with no sources.
+func NewHealthReporter() *HealthReporter {
+	return &HealthReporter{}
+}
+
+// Register adds a source. The name prefixes the names of the components it
+// reports so that sources of the same kind can be told apart.
+func (r *HealthReporter) Register(name string, source HealthSource) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.sources = append(r.sources, namedHealthSource{name: name, source: source})
+}
+
+// Snapshot collects the current health of every registered source.
+func (r *HealthReporter) Snapshot() HealthSnapshot {
+	r.lock.RLock()
+	var sources = append([]namedHealthSource(nil), r.sources...)
+	r.lock.RUnlock()
+	var snapshot = HealthSnapshot{Healthy: true, Time: time.Now().UTC(), Components: []ComponentHealth{}}
+	for _, source := range sources {
+		for _, component := range source.source.Health() {
+			if source.name != "" {
+				component.Name = source.name + "/" + component.Name
+			}
+			snapshot.Healthy = snapshot.Healthy && component.Healthy
+			snapshot.Components = append(snapshot.Components, component)
+		}
+	}
+	return snapshot
+}
+
+// ServeHTTP writes the current snapshot as JSON.
+func (r *HealthReporter) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	var snapshot = r.Snapshot()
+	w.Header().Set("Content-Type", "application/json")
+	if !snapshot.Healthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(snapshot)
+}
+
+// HealthTrackerSource reports each key of the tracker as a component. A key
+// is unhealthy if its error rate exceeds maxErrorRate or if it has at least
+// maxConsecutive consecutive failures. A maxConsecutive of zero disables the
+// consecutive failure check.
+func HealthTrackerSource(tracker *HealthTracker, maxErrorRate float64, maxConsecutive int) HealthSource {
+	return HealthSourceFunc(func() []ComponentHealth {
+		var keys = tracker.Keys()
+		var components = make([]ComponentHealth, 0, len(keys))
+		for _, key := range keys {
+			var stats = tracker.Stats(key)
+			var healthy = stats.ErrorRate <= maxErrorRate &&
+				(maxConsecutive < 1 || stats.ConsecutiveFailures < maxConsecutive)
+			components = append(components, ComponentHealth{
+				Name:    key,
+				Healthy: healthy,
+				Details: map[string]interface{}{
+					"requests":             stats.Requests,
+					"error_rate":           stats.ErrorRate,
+					"consecutive_failures": stats.ConsecutiveFailures,
+				},
+			})
+		}
+		return components
+	})
+}
+
+// SLOTrackerSource reports each operation of the tracker as a component that
+// is unhealthy while either of its burn rates is above maxBurn.
+func SLOTrackerSource(tracker *SLOTracker, maxBurn float64) HealthSource {
+	return HealthSourceFunc(func() []ComponentHealth {
+		var statuses = tracker.Statuses()
+		var components = make([]ComponentHealth, 0, len(statuses))
+		for _, status := range statuses {
+			components = append(components, ComponentHealth{
+				Name:    status.Operation,
+				Healthy: status.AvailabilityBurn <= maxBurn && status.LatencyBurn <= maxBurn,
+				Details: map[string]interface{}{
+					"requests":          status.Requests,
+					"availability_burn": status.AvailabilityBurn,
+					"latency_burn":      status.LatencyBurn,
+				},
+			})
+		}
+		return components
+	})
+}
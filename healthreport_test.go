@@ -0,0 +1,67 @@
+package transport
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthReporter(t *testing.T) {
+	var tracker = NewHealthTracker()
+	tracker.Record("a.example.com", false)
+	tracker.Record("b.example.com", true)
+	tracker.Record("b.example.com", false)
+
+	var reporter = NewHealthReporter()
+	reporter.Register("hosts", HealthTrackerSource(tracker, .5, 0))
+	var snapshot = reporter.Snapshot()
+	assert.True(t, snapshot.Healthy)
+	require.Len(t, snapshot.Components, 2)
+	assert.Equal(t, "hosts/a.example.com", snapshot.Components[0].Name)
+
+	tracker.Record("b.example.com", true)
+	snapshot = reporter.Snapshot()
+	assert.False(t, snapshot.Healthy)
+	assert.False(t, snapshot.Components[1].Healthy)
+
+	var w = httptest.NewRecorder()
+	reporter.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	var decoded HealthSnapshot
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &decoded))
+	assert.False(t, decoded.Healthy)
+	assert.Len(t, decoded.Components, 2)
+}
+
+func TestHealthTrackerSourceConsecutive(t *testing.T) {
+	var tracker = NewHealthTracker()
+	for x := 0; x < 10; x = x + 1 {
+		tracker.Record("host", false)
+	}
+	tracker.Record("host", true)
+	tracker.Record("host", true)
+	assert.True(t, HealthTrackerSource(tracker, .5, 3).Health()[0].Healthy)
+	tracker.Record("host", true)
+	assert.False(t, HealthTrackerSource(tracker, .5, 3).Health()[0].Healthy)
+}
+
+func TestSLOTrackerSource(t *testing.T) {
+	var tracker = NewSLOTracker(nil, SLOTrackerOptionDefault(SLO{Availability: .5}))
+	tracker.Record("op", false, 0)
+	tracker.Record("op", true, 0)
+	var reporter = NewHealthReporter()
+	reporter.Register("", SLOTrackerSource(tracker, 1))
+	var snapshot = reporter.Snapshot()
+	assert.True(t, snapshot.Healthy)
+	assert.Equal(t, "op", snapshot.Components[0].Name)
+	tracker.Record("op", true, 0)
+	assert.False(t, reporter.Snapshot().Healthy)
+
+	var w = httptest.NewRecorder()
+	NewHealthReporter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,39 @@
+package transport
+
+import "net/http"
+
+// RetrySettings describes the Retry half of a NewHedgedRetrier decorator. The
+// fields match the arguments of NewRetrierWithOptions.
+type RetrySettings struct {
+	Backoff  BackoffPolicy
+	Policies []RetryPolicy
+	Options  []RetryOption
+}
+
+// HedgeSettings describes the Hedger half of a NewHedgedRetrier decorator. The
+// fields match the arguments of NewHedger.
+type HedgeSettings struct {
+	Backoff BackoffPolicy
+	Options []HedgerOption
+}
+
+// NewHedgedRetrier configures a RoundTripper decorator that hedges each
+// attempt for latency and retries the outcome of the hedged attempt for
+// errors. The Hedger is installed inside the Retry decorator so that:
+//
+//   - Each retry starts a new set of hedged requests rather than each hedge
+//     running its own retry sequence.
+//   - Per-attempt contexts created by retry policies, such as the timeout
+//     policy, bound every hedged request of that attempt.
+//   - The request body is captured once by the Retry decorator and replayed
+//     by the Hedger through GetBody rather than buffered again.
+//
+// The combined decorator is equivalent to, and should be used instead of,
+// manually chaining the two decorators in that order.
+func NewHedgedRetrier(retry RetrySettings, hedge HedgeSettings) func(http.RoundTripper) http.RoundTripper {
+	var retrier = NewRetrierWithOptions(retry.Backoff, retry.Policies, retry.Options...)
+	var hedger = NewHedger(hedge.Backoff, hedge.Options...)
+	return func(wrapped http.RoundTripper) http.RoundTripper {
+		return retrier(hedger(wrapped))
+	}
+}
@@ -0,0 +1,57 @@
+package transport
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHedgedRetrierRetriesHedgedOutcome(t *testing.T) {
+	var lock sync.Mutex
+	var bodies []string
+	var calls int
+	var wrapped = RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		var b, _ = io.ReadAll(r.Body)
+		lock.Lock()
+		calls = calls + 1
+		var call = calls
+		bodies = append(bodies, string(b))
+		lock.Unlock()
+		switch call {
+		case 1:
+			// The first attempt stalls so that a hedge is launched.
+			<-r.Context().Done()
+			return nil, r.Context().Err()
+		case 2:
+			return &http.Response{StatusCode: http.StatusInternalServerError, Body: http.NoBody}, nil
+		default:
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+		}
+	})
+	var rt = NewHedgedRetrier(
+		RetrySettings{
+			Backoff:  NewFixedBackoffPolicy(0),
+			Policies: []RetryPolicy{NewLimitedRetryPolicy(2, NewStatusCodeRetryPolicy(http.StatusInternalServerError))},
+		},
+		HedgeSettings{Backoff: NewFixedBackoffPolicy(10 * time.Millisecond)},
+	)(wrapped)
+
+	var req, _ = http.NewRequest(http.MethodPost, "/", io.NopCloser(strings.NewReader("payload")))
+	var resp, e = rt.RoundTrip(req)
+	require.NoError(t, e)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	lock.Lock()
+	defer lock.Unlock()
+	require.Equal(t, 3, calls)
+	for _, body := range bodies {
+		assert.Equal(t, "payload", body)
+	}
+}
@@ -3,94 +3,239 @@ package transport
 import (
 	"context"
 	"net/http"
-	"time"
 )
 
+const hedgerSource = "hedger"
+
 // Hedger is a wrapper that fans out a new request at each time interval defined
 // by the backoff policy, and returns the first response received. For
 // latency-based retries, this will often be a better approach than a
 // "stop-and-retry" policy (such as the TimeoutRetrier). The hedging decorator
 // allows for a worst case request to take up to a maximum configurable timeout,
 // while pessimistically creating new requests before the timeout is reached.
+//
+// Requests with a GetBody function, such as large or streaming uploads, are not
+// buffered. Each hedged request gets a fresh body from GetBody, which must be
+// safe to call concurrently with reads of bodies it previously returned. A
+// hedge is skipped if GetBody fails for it.
 type Hedger struct {
 	wrapped       http.RoundTripper
 	backoffPolicy BackoffPolicy
+	clock         Clock
+	// alternates creates, for each request, the selector of the transport
+	// used by each hedged request. Nil sends every request to wrapped.
+	alternates func() func(hedge int) http.RoundTripper
+	// exclusive spreads the attempts of each request over the instances of
+	// any Rotator that they pass through.
+	exclusive bool
+}
+
+// HedgerOption is a configuration for the Hedger decorator.
+type HedgerOption func(*Hedger) *Hedger
+
+// HedgerOptionClock configures the Clock used to schedule hedged requests.
+func HedgerOptionClock(clock Clock) HedgerOption {
+	return func(h *Hedger) *Hedger {
+		h.clock = clock
+		return h
+	}
+}
+
+// HedgerOptionAlternates configures the Hedger to send hedged requests through
+// the given transports, in order, rather than through the transport it wraps.
+// The first request of each RoundTrip still uses the wrapped transport. Using
+// distinct transports, such as ones bound to different regions, keeps a hedge
+// from waiting on the same slow connection as the request it hedges.
+func HedgerOptionAlternates(transports ...http.RoundTripper) HedgerOption {
+	return func(h *Hedger) *Hedger {
+		if len(transports) < 1 {
+			h.alternates = nil
+			return h
+		}
+		var selector = func(hedge int) http.RoundTripper {
+			return transports[(hedge-1)%len(transports)]
+		}
+		h.alternates = func() func(int) http.RoundTripper {
+			return selector
+		}
+		return h
+	}
+}
+
+// HedgerOptionRotator configures the Hedger to send hedged requests through
+// the given Rotator. Each attempt of a request is given an instance that no
+// other attempt of the request has used, chosen by the strategy of the Rotator
+// and skipping instances ejected by its OutlierDetection, so that hedges are
+// spread over different connections. Once every usable instance has an
+// attempt the hedges are spread over them again. Instances replaced by a
+// rolling Recycler are picked up as they change.
+func HedgerOptionRotator(rotator *Rotator) HedgerOption {
+	return func(h *Hedger) *Hedger {
+		var selector = func(int) http.RoundTripper {
+			return rotator
+		}
+		h.alternates = func() func(int) http.RoundTripper {
+			return selector
+		}
+		h.exclusive = true
+		return h
+	}
 }
 
 type hedgedResponse struct {
 	Response *http.Response
 	Err      error
+	Attempt  int
 }
 
-func (c *Hedger) hedgedRoundTrip(doneCtx context.Context, requestCtx context.Context, r *http.Request, resp chan *hedgedResponse) { // nolint
-	// Create a local context to manage the request cancellation. Because these
-	// are all children of the source parentCtx they will eventually be
-	// canceled when the parent is canceled even if we do not call the cancel
-	// method returned here. The implication is that the source parent context
-	// _must_ end at some point. That is, a background context with no end of
-	// life would cause resources and memory to leak over time.
-	ctx, cancel := context.WithCancel(requestCtx) // nolint
+func (c *Hedger) hedgedRoundTrip(doneCtx context.Context, requestCtx context.Context, wrapped http.RoundTripper, r *http.Request, attempt int, resp chan *hedgedResponse) {
+	// Create a local context to manage the request cancellation. The context
+	// of the winning request is released when its response body is closed.
+	// All others are canceled as soon as the hedger no longer needs them.
+	ctx, cancel := context.WithCancel(requestCtx)
 	// Create a local channel for accepting the results. This allows us to
 	// sink the result and close the goroutine under all conditions including
 	// if the context is canceled because it has a buffer space of one. If it is
 	// never read from then it will eventually be GC'd after the method exits.
 	localResp := make(chan *hedgedResponse, 1)
 	go func() {
-		var response, err = c.wrapped.RoundTrip(r.WithContext(ctx))
-		localResp <- &hedgedResponse{Response: response, Err: err}
+		var req = r.WithContext(ctx)
+		emitEvent(requestCtx, TransportEvent{Type: EventAttemptStarted, Source: hedgerSource, Request: req, Attempt: attempt})
+		var start = c.clock.Now()
+		var response, err = wrapped.RoundTrip(req)
+		emitEvent(requestCtx, TransportEvent{
+			Type: EventAttemptFinished, Source: hedgerSource, Request: req,
+			Response: response, Err: err, Attempt: attempt, Duration: c.clock.Now().Sub(start),
+		})
+		localResp <- &hedgedResponse{Response: response, Err: err, Attempt: attempt}
 	}()
 
+	var result *hedgedResponse
 	select {
-	case resp <- <-localResp:
+	case result = <-localResp:
 	case <-doneCtx.Done():
 		// End work in flight if the parent signals that it needs no more
-		// responses. Because the response channel is unbuffered, all responses
-		// that complete will block on this select until they are read. The
-		// hedger will read only one of them and then trigger the Done() case
-		// for all other
+		// responses.
 		cancel()
+		return
 	}
-} // nolint
+	if result.Err != nil {
+		cancel()
+	} else {
+		result.Response = withCancelBody(result.Response, cancel)
+	}
+	select {
+	case resp <- result:
+	case <-doneCtx.Done():
+		// Because the response channel is unbuffered, all responses that
+		// complete will block on this select until they are read. The hedger
+		// will read only one of them and then trigger the Done() case for all
+		// others.
+		if result.Response != nil && result.Response.Body != nil {
+			_ = result.Response.Body.Close()
+		}
+		cancel()
+	}
+}
 
 // RoundTrip executes a new request at each time interval defined
 // by the backoff policy, and returns the first response received.
 func (c *Hedger) RoundTrip(r *http.Request) (*http.Response, error) {
+	if passThrough(r) {
+		return c.wrapped.RoundTrip(r)
+	}
+	var start = c.clock.Now()
 	var copier, e = newRequestCopier(r)
 	if e != nil {
-		return nil, e
+		return nil, newAttemptError(e, 0, 0, c.clock.Now().Sub(start))
 	}
 	var parentCtx = r.Context()
+	if c.exclusive {
+		parentCtx = withRotatorExclusions(parentCtx)
+	}
 	// doneCtx is used to indicate that the RoundTrip is complete and any
 	// outstanding work should be canceled.
 	var doneCtx, done = context.WithCancel(parentCtx)
 	defer done()
-	// requestCtx is a copy of parentCtx without any modifications. This could
-	// likely just be parentCtx directly. Making a child out of habit.
-	requestCtx, _ := context.WithCancel(parentCtx) // nolint
+	// requestCtx is the parent of every hedged request. It is intentionally
+	// not doneCtx so that the winning response body remains readable after
+	// this method returns.
+	var requestCtx = parentCtx
 
 	var backoffer = c.backoffPolicy()
 	var respChan = make(chan *hedgedResponse)
-	var request = copier.Copy()
+	var request *http.Request
+	request, e = copier.copyRequest()
+	if e != nil {
+		return nil, newAttemptError(e, 0, 0, c.clock.Now().Sub(start))
+	}
 
-	go c.hedgedRoundTrip(doneCtx, requestCtx, request, respChan)
+	var attempts = 1
+	var alternate func(int) http.RoundTripper
+	if c.alternates != nil {
+		alternate = c.alternates()
+	}
+	var first = request
+	goLabeled(requestCtx, hedgerSource, r.URL.Host, func(context.Context) {
+		c.hedgedRoundTrip(doneCtx, requestCtx, c.wrapped, first, 1, respChan)
+	})
 
+	// waits counts the delays computed so far. It differs from attempts
+	// when a hedge is skipped.
+	var waits = 1
+	var delay = backoffFor(backoffer, parentCtx, waits, r, nil, nil)
+	var timer = c.clock.NewTimer(delay)
+	defer timer.Stop()
+	// hedges is nil once the backoff policy returns BackoffStop so that no
+	// more hedges are launched while waiting on those in flight.
+	var hedges = timer.C()
+	if delay == BackoffStop {
+		hedges = nil
+	}
 	for {
 		select {
 		case resp := <-respChan:
-			return resp.Response, resp.Err
+			Annotate(parentCtx, AnnotationHedgeIndex, resp.Attempt)
+			return resp.Response, newAttemptError(resp.Err, attempts, attempts-1, c.clock.Now().Sub(start))
 		case <-parentCtx.Done():
-			return nil, parentCtx.Err()
-		case <-time.After(backoffer.Backoff(r, nil, nil)):
-			request = copier.Copy()
-			go c.hedgedRoundTrip(doneCtx, requestCtx, request, respChan)
+			return nil, newAttemptError(parentCtx.Err(), attempts, attempts-1, c.clock.Now().Sub(start))
+		case <-hedges:
+			waits = waits + 1
+			delay = backoffFor(backoffer, parentCtx, waits, r, nil, nil)
+			if delay == BackoffStop {
+				hedges = nil
+			} else {
+				timer.Reset(delay)
+			}
+			var hedge, hedgeErr = copier.copyRequest()
+			if hedgeErr != nil {
+				// A hedge without a body would fail and could be returned
+				// before a request that is still in flight succeeds.
+				continue
+			}
+			request = hedge
+			attempts = attempts + 1
+			emitEvent(parentCtx, TransportEvent{Type: EventHedgeLaunched, Source: hedgerSource, Request: request, Attempt: attempts})
+			var wrapped = c.wrapped
+			if alternate != nil {
+				wrapped = alternate(attempts - 1)
+			}
+			var hedged, attempt = request, attempts
+			goLabeled(requestCtx, hedgerSource, r.URL.Host, func(context.Context) {
+				c.hedgedRoundTrip(doneCtx, requestCtx, wrapped, hedged, attempt, respChan)
+			})
 		}
 	}
 }
 
 // NewHedger configures a RoundTripper decorator to perform some number of
 // hedged requests.
-func NewHedger(backoffPolicy BackoffPolicy) func(http.RoundTripper) http.RoundTripper {
+func NewHedger(backoffPolicy BackoffPolicy, opts ...HedgerOption) func(http.RoundTripper) http.RoundTripper {
 	return func(wrapped http.RoundTripper) http.RoundTripper {
-		return &Hedger{wrapped: wrapped, backoffPolicy: backoffPolicy}
+		var h = &Hedger{wrapped: wrapped, backoffPolicy: backoffPolicy, clock: NewSystemClock()}
+		for _, opt := range opts {
+			h = opt(h)
+		}
+		return h
 	}
 }
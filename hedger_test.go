@@ -3,15 +3,19 @@ package transport
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestHedgerSuccess(t *testing.T) {
@@ -265,3 +269,218 @@ func TestHedgerConcurrentHeaderModifications(t *testing.T) {
 		t.Fatal("roundtrip took too long to exit")
 	}
 }
+
+type closeRecordingBody struct {
+	io.Reader
+	closed bool
+}
+
+func (b *closeRecordingBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestHedgerGetBody(t *testing.T) {
+	var original = &closeRecordingBody{Reader: strings.NewReader("payload")}
+	var req, _ = http.NewRequest(http.MethodPost, "/", original)
+	var lock sync.Mutex
+	var generated int
+	req.GetBody = func() (io.ReadCloser, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		generated = generated + 1
+		return io.NopCloser(strings.NewReader("payload")), nil
+	}
+	var second = make(chan struct{})
+	var calls int
+	var wrapped = RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		var b, _ = io.ReadAll(r.Body)
+		assert.Equal(t, "payload", string(b))
+		lock.Lock()
+		calls = calls + 1
+		var call = calls
+		lock.Unlock()
+		if call == 1 {
+			<-r.Context().Done()
+			return nil, r.Context().Err()
+		}
+		close(second)
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	})
+	var resp, e = NewHedger(NewFixedBackoffPolicy(5 * time.Millisecond))(wrapped).RoundTrip(req)
+	require.NoError(t, e)
+	_ = resp.Body.Close()
+	<-second
+	assert.True(t, original.closed, "did not release the original body")
+	lock.Lock()
+	defer lock.Unlock()
+	assert.GreaterOrEqual(t, generated, 2)
+}
+
+func TestHedgerSkipsHedgesWithoutBody(t *testing.T) {
+	var req, _ = http.NewRequest(http.MethodPost, "/", strings.NewReader("payload"))
+	var lock sync.Mutex
+	var generated int
+	req.GetBody = func() (io.ReadCloser, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		generated = generated + 1
+		if generated > 1 {
+			return nil, errors.New("source is gone")
+		}
+		return io.NopCloser(strings.NewReader("payload")), nil
+	}
+	var calls int
+	var wrapped = RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		lock.Lock()
+		calls = calls + 1
+		lock.Unlock()
+		time.Sleep(30 * time.Millisecond)
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	})
+	var resp, e = NewHedger(NewFixedBackoffPolicy(5 * time.Millisecond))(wrapped).RoundTrip(req)
+	require.NoError(t, e)
+	_ = resp.Body.Close()
+	lock.Lock()
+	defer lock.Unlock()
+	assert.Equal(t, 1, calls)
+	assert.Greater(t, generated, 1)
+}
+
+func TestHedgerOptionAlternates(t *testing.T) {
+	var lock sync.Mutex
+	var used []string
+	var transportNamed = func(name string, succeed bool) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			lock.Lock()
+			used = append(used, name)
+			lock.Unlock()
+			if !succeed {
+				<-r.Context().Done()
+				return nil, r.Context().Err()
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+		})
+	}
+	var rt = NewHedger(
+		NewFixedBackoffPolicy(5*time.Millisecond),
+		HedgerOptionAlternates(transportNamed("first", false), transportNamed("second", true)),
+	)(transportNamed("wrapped", false))
+	var req, _ = http.NewRequest(http.MethodGet, "/", nil)
+	var resp, e = rt.RoundTrip(req)
+	require.NoError(t, e)
+	_ = resp.Body.Close()
+	lock.Lock()
+	defer lock.Unlock()
+	assert.Equal(t, []string{"wrapped", "first", "second"}, used)
+}
+
+func TestHedgerOptionRotator(t *testing.T) {
+	var lock sync.Mutex
+	var created int
+	var used []int
+	var rotator = NewRotator(func() http.RoundTripper {
+		lock.Lock()
+		var instance = created
+		created = created + 1
+		lock.Unlock()
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			lock.Lock()
+			used = append(used, instance)
+			var count = len(used)
+			lock.Unlock()
+			if count < 3 {
+				<-r.Context().Done()
+				return nil, r.Context().Err()
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+		})
+	}, RotatorOptionInstances(3))
+	var rt = NewHedger(NewFixedBackoffPolicy(5*time.Millisecond), HedgerOptionRotator(rotator))(rotator)
+	var req, _ = http.NewRequest(http.MethodGet, "/", nil)
+	var resp, e = rt.RoundTrip(req)
+	require.NoError(t, e)
+	_ = resp.Body.Close()
+	lock.Lock()
+	defer lock.Unlock()
+	require.Len(t, used, 3)
+	assert.ElementsMatch(t, []int{0, 1, 2}, used, "hedges did not use distinct instances")
+}
+
+func TestHedgerOptionRotatorStrategy(t *testing.T) {
+	var lock sync.Mutex
+	var created int
+	var hedging bool
+	var used []int
+	var rotator = NewRotator(func() http.RoundTripper {
+		var instance = created
+		created = created + 1
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			lock.Lock()
+			if !hedging {
+				lock.Unlock()
+				if instance == 2 {
+					return &http.Response{StatusCode: http.StatusBadGateway, Body: http.NoBody}, nil
+				}
+				return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+			}
+			used = append(used, instance)
+			var count = len(used)
+			lock.Unlock()
+			if count < 3 {
+				<-r.Context().Done()
+				return nil, r.Context().Err()
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+		})
+	},
+		RotatorOptionInstances(4),
+		RotatorOptionConsistentHash(func(r *http.Request) string {
+			return r.URL.Path
+		}),
+		RotatorOptionOutlierDetection(
+			OutlierDetectionOptionInterval(time.Hour),
+			OutlierDetectionOptionConsecutiveFailures(1),
+			OutlierDetectionOptionEjection(time.Hour, time.Hour),
+			OutlierDetectionOptionMaxEjectionPercent(50),
+		),
+	)
+	for x := 0; x < 32 && len(rotator.Ejected()) < 1; x = x + 1 {
+		var req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("http://example.com/%d", x), nil)
+		_, _ = rotator.RoundTrip(req)
+	}
+	require.Equal(t, []int{2}, rotator.Ejected())
+
+	lock.Lock()
+	hedging = true
+	lock.Unlock()
+	var rt = NewHedger(NewFixedBackoffPolicy(5*time.Millisecond), HedgerOptionRotator(rotator))(rotator)
+	var req, _ = http.NewRequest(http.MethodGet, "http://example.com/key", nil)
+	var resp, e = rt.RoundTrip(req)
+	require.NoError(t, e)
+	_ = resp.Body.Close()
+	lock.Lock()
+	defer lock.Unlock()
+	require.Len(t, used, 3)
+	assert.ElementsMatch(t, []int{0, 1, 3}, used, "hedges did not use distinct usable instances")
+}
+
+func TestHedgerBackoffStop(t *testing.T) {
+	var lock sync.Mutex
+	var calls int
+	var wrapped = RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		lock.Lock()
+		calls = calls + 1
+		lock.Unlock()
+		time.Sleep(30 * time.Millisecond)
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	})
+	var rt = NewHedger(NewSleepBudgetBackoffPolicy(NewFixedBackoffPolicy(5*time.Millisecond), 5*time.Millisecond))(wrapped)
+	var req, _ = http.NewRequest(http.MethodGet, "/", nil)
+	var resp, e = rt.RoundTrip(req)
+	require.NoError(t, e)
+	_ = resp.Body.Close()
+	lock.Lock()
+	defer lock.Unlock()
+	assert.Equal(t, 2, calls)
+}
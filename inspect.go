@@ -0,0 +1,229 @@
+package transport
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const bodyInspectionSource = "body_inspection"
+
+// InspectionAction is the outcome of inspecting a request body.
+type InspectionAction int
+
+const (
+	// InspectionAllow sends the request unchanged.
+	InspectionAllow InspectionAction = iota
+	// InspectionRedact sends the request with the body of the result.
+	InspectionRedact
+	// InspectionBlock refuses to send the request.
+	InspectionBlock
+)
+
+// InspectionResult is the outcome of a BodyInspector.
+type InspectionResult struct {
+	Action InspectionAction
+	// Body replaces the request body when the action is InspectionRedact.
+	Body []byte
+	// Findings names the kinds of sensitive data that were detected, such as
+	// "credit_card".
+	Findings []string
+}
+
+// BodyInspector examines request bodies for sensitive data, such as for
+// data loss prevention. The body must not be modified.
+type BodyInspector interface {
+	Inspect(r *http.Request, body []byte) (InspectionResult, error)
+}
+
+// BodyInspectorFunc converts a function to a BodyInspector.
+type BodyInspectorFunc func(r *http.Request, body []byte) (InspectionResult, error)
+
+// Inspect calls the wrapped function.
+func (f BodyInspectorFunc) Inspect(r *http.Request, body []byte) (InspectionResult, error) {
+	return f(r, body)
+}
+
+// SensitivePattern describes a kind of sensitive data for the
+// PatternInspector.
+type SensitivePattern struct {
+	// Name identifies the pattern in findings.
+	Name string
+	// Regexp matches candidate values.
+	Regexp *regexp.Regexp
+	// Validate, if set, rejects candidates that are not real values, such as
+	// numbers that fail a checksum.
+	Validate func(match []byte) bool
+}
+
+var (
+	// PatternCreditCard matches payment card numbers that pass the Luhn
+	// checksum, with or without space or dash separators.
+	PatternCreditCard = SensitivePattern{
+		Name:     "credit_card",
+		Regexp:   regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`),
+		Validate: luhnValid,
+	}
+	// PatternAWSAccessKey matches AWS access key IDs.
+	PatternAWSAccessKey = SensitivePattern{
+		Name:   "aws_access_key",
+		Regexp: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`),
+	}
+	// PatternPrivateKey matches PEM encoded private keys.
+	PatternPrivateKey = SensitivePattern{
+		Name:   "private_key",
+		Regexp: regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`),
+	}
+)
+
+func luhnValid(match []byte) bool {
+	var sum int
+	var double bool
+	for x := len(match) - 1; x >= 0; x = x - 1 {
+		if match[x] < '0' || match[x] > '9' {
+			continue
+		}
+		var digit = int(match[x] - '0')
+		if double {
+			digit = digit * 2
+			if digit > 9 {
+				digit = digit - 9
+			}
+		}
+		sum = sum + digit
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// PatternInspector is a BodyInspector that searches bodies for
+// SensitivePatterns and either blocks the requests that contain them or
+// replaces each match with [REDACTED].
+type PatternInspector struct {
+	action   InspectionAction
+	patterns []SensitivePattern
+}
+
+// NewPatternInspector creates a PatternInspector that takes the action,
+// InspectionBlock or InspectionRedact, when any of the patterns is found.
+func NewPatternInspector(action InspectionAction, patterns ...SensitivePattern) *PatternInspector {
+	return &PatternInspector{action: action, patterns: patterns}
+}
+
+// Inspect searches the body for the patterns.
+func (p *PatternInspector) Inspect(_ *http.Request, body []byte) (InspectionResult, error) {
+	var result = InspectionResult{Action: InspectionAllow, Body: body}
+	for _, pattern := range p.patterns {
+		var found bool
+		var redacted = pattern.Regexp.ReplaceAllFunc(result.Body, func(match []byte) []byte {
+			if pattern.Validate != nil && !pattern.Validate(match) {
+				return match
+			}
+			found = true
+			return []byte("[REDACTED]")
+		})
+		if found {
+			result.Action = p.action
+			result.Findings = append(result.Findings, pattern.Name)
+			result.Body = redacted
+		}
+	}
+	return result, nil
+}
+
+// BodyInspectionError is returned by the BodyInspection decorator for
+// requests that an inspector blocked.
+type BodyInspectionError struct {
+	Findings []string
+}
+
+func (e *BodyInspectionError) Error() string {
+	return fmt.Sprintf("transport: request blocked because its body contains %s", strings.Join(e.Findings, ", "))
+}
+
+// BodyInspection is a decorator that passes request bodies to
+// BodyInspectors before they are sent. Bodies larger than the size limit
+// are sent without inspection so that large uploads are not buffered.
+type BodyInspection struct {
+	wrapped    http.RoundTripper
+	inspectors []BodyInspector
+	maxSize    int64
+}
+
+// BodyInspectionOption is a configuration for the BodyInspection decorator.
+type BodyInspectionOption func(*BodyInspection) *BodyInspection
+
+// BodyInspectionOptionMaxSize sets the largest body, in bytes, that is
+// inspected. The default is 1 MiB.
+func BodyInspectionOptionMaxSize(size int64) BodyInspectionOption {
+	return func(b *BodyInspection) *BodyInspection {
+		b.maxSize = size
+		return b
+	}
+}
+
+// RoundTrip inspects the request body and calls the wrapped transport. Every
+// detection emits EventSensitiveDataDetected. Blocked requests fail with a
+// *BodyInspectionError.
+func (c *BodyInspection) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.Body == nil || r.Body == http.NoBody || r.ContentLength > c.maxSize {
+		return c.wrapped.RoundTrip(r)
+	}
+	var content, e = readBody(io.LimitReader(r.Body, c.maxSize+1))
+	if e != nil {
+		_ = r.Body.Close()
+		return nil, e
+	}
+	if int64(len(content)) > c.maxSize {
+		return c.wrapped.RoundTrip(withRequestBody(r, struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(content), r.Body), r.Body}))
+	}
+	_ = r.Body.Close()
+	var findings []string
+	for _, inspector := range c.inspectors {
+		var result, inspectErr = inspector.Inspect(r, content)
+		if inspectErr != nil {
+			return nil, inspectErr
+		}
+		findings = append(findings, result.Findings...)
+		switch result.Action {
+		case InspectionBlock:
+			sort.Strings(findings)
+			var err = &BodyInspectionError{Findings: findings}
+			emitEvent(r.Context(), TransportEvent{Type: EventSensitiveDataDetected, Source: bodyInspectionSource, Request: r, Err: err, Findings: findings})
+			return nil, err
+		case InspectionRedact:
+			content = result.Body
+		}
+	}
+	if len(findings) > 0 {
+		sort.Strings(findings)
+		emitEvent(r.Context(), TransportEvent{Type: EventSensitiveDataDetected, Source: bodyInspectionSource, Request: r, Findings: findings})
+	}
+	r = withRequestBody(r, newReplayBody(content))
+	r.ContentLength = int64(len(content))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return newReplayBody(content), nil
+	}
+	return c.wrapped.RoundTrip(r)
+}
+
+// NewBodyInspection configures a RoundTripper decorator that passes request
+// bodies to the inspectors in order. A redacted body is given to the
+// following inspectors. Place it before any decorator that signs or digests
+// the body so that they cover the redacted body.
+func NewBodyInspection(inspectors []BodyInspector, opts ...BodyInspectionOption) func(http.RoundTripper) http.RoundTripper {
+	return func(wrapped http.RoundTripper) http.RoundTripper {
+		var b = &BodyInspection{wrapped: wrapped, inspectors: inspectors, maxSize: 1 << 20}
+		for _, opt := range opts {
+			b = opt(b)
+		}
+		return b
+	}
+}
@@ -0,0 +1,241 @@
+package transport
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	latencyWindows = 10
+	// latencyMin is the upper bound of the first histogram bucket. Each
+	// following bucket is latencyGrowth times wider, which bounds the error
+	// of reported quantiles to roughly 19%.
+	latencyMin     = 100 * time.Microsecond
+	latencyGrowth  = 1.189207115 // 2^(1/4)
+	latencyBuckets = 100
+)
+
+var latencyBounds = func() [latencyBuckets]time.Duration {
+	var bounds [latencyBuckets]time.Duration
+	var bound = float64(latencyMin)
+	for x := range bounds {
+		bounds[x] = time.Duration(bound)
+		bound = bound * latencyGrowth
+	}
+	return bounds
+}()
+
+func latencyBucket(d time.Duration) int {
+	if d <= latencyMin {
+		return 0
+	}
+	var x = int(math.Ceil(math.Log(float64(d)/float64(latencyMin)) / math.Log(latencyGrowth)))
+	if x >= latencyBuckets {
+		return latencyBuckets - 1
+	}
+	// Correct for floating point error at bucket boundaries.
+	for x > 0 && d <= latencyBounds[x-1] {
+		x = x - 1
+	}
+	return x
+}
+
+// LatencySnapshot summarizes the latencies recorded within the window.
+type LatencySnapshot struct {
+	Count int64
+	P50   time.Duration
+	P95   time.Duration
+	P99   time.Duration
+}
+
+type latencySlice struct {
+	epoch  int64
+	counts [latencyBuckets]int64
+}
+
+type latencyHistogram struct {
+	slices [latencyWindows]latencySlice
+}
+
+// LatencyRecorder maintains rolling latency histograms per key. It is safe
+// for concurrent use and is intended as a shared signal for decorators that
+// adapt to upstream latency.
+type LatencyRecorder struct {
+	lock       sync.Mutex
+	histograms map[string]*latencyHistogram
+	window     time.Duration
+	clock      Clock
+	key        func(*http.Request) string
+}
+
+// LatencyRecorderOption is a configuration for the LatencyRecorder.
+type LatencyRecorderOption func(*LatencyRecorder) *LatencyRecorder
+
+// LatencyRecorderOptionWindow sets the rolling window covered by snapshots.
+// The default is one minute.
+func LatencyRecorderOptionWindow(window time.Duration) LatencyRecorderOption {
+	return func(r *LatencyRecorder) *LatencyRecorder {
+		r.window = window
+		return r
+	}
+}
+
+// LatencyRecorderOptionKey sets the function that selects the histogram a
+// request is recorded in. The default is LatencyKeyHost.
+func LatencyRecorderOptionKey(key func(*http.Request) string) LatencyRecorderOption {
+	return func(r *LatencyRecorder) *LatencyRecorder {
+		r.key = key
+		return r
+	}
+}
+
+// LatencyRecorderOptionClock configures the Clock used to age out latencies.
+func LatencyRecorderOptionClock(clock Clock) LatencyRecorderOption {
+	return func(r *LatencyRecorder) *LatencyRecorder {
+		r.clock = clock
+		return r
+	}
+}
+
+// LatencyKeyHost records requests by the host of their URL.
+func LatencyKeyHost(r *http.Request) string {
+	return r.URL.Host
+}
+
+// LatencyKeyTag returns a key function that records requests by the value of
+// a tag added with WithTags.
+func LatencyKeyTag(name string) func(*http.Request) string {
+	return func(r *http.Request) string {
+		return tag(r.Context(), name)
+	}
+}
+
+// NewLatencyRecorder creates an empty LatencyRecorder.
+func NewLatencyRecorder(opts ...LatencyRecorderOption) *LatencyRecorder {
+	var r = &LatencyRecorder{
+		histograms: make(map[string]*latencyHistogram),
+		window:     time.Minute,
+		clock:      NewSystemClock(),
+		key:        LatencyKeyHost,
+	}
+	for _, opt := range opts {
+		r = opt(r)
+	}
+	return r
+}
+
+// Key returns the key that the request is recorded under.
+func (r *LatencyRecorder) Key(req *http.Request) string {
+	return r.key(req)
+}
+
+func (r *LatencyRecorder) epoch() int64 {
+	var width = int64(r.window) / latencyWindows
+	if width < 1 {
+		width = 1
+	}
+	return r.clock.Now().UnixNano() / width
+}
+
+// Record adds a latency to the histogram for the key.
+func (r *LatencyRecorder) Record(key string, d time.Duration) {
+	var epoch = r.epoch()
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	var h, ok = r.histograms[key]
+	if !ok {
+		h = &latencyHistogram{}
+		r.histograms[key] = h
+	}
+	var slice = &h.slices[epoch%latencyWindows]
+	if slice.epoch != epoch {
+		*slice = latencySlice{epoch: epoch}
+	}
+	var bucket = latencyBucket(d)
+	slice.counts[bucket] = slice.counts[bucket] + 1
+}
+
+// Snapshot returns the quantiles of the latencies recorded for the key within
+// the window. Quantiles are reported as the upper bound of the histogram
+// bucket that contains them.
+func (r *LatencyRecorder) Snapshot(key string) LatencySnapshot {
+	var epoch = r.epoch()
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	var h, ok = r.histograms[key]
+	if !ok {
+		return LatencySnapshot{}
+	}
+	var counts [latencyBuckets]int64
+	var total int64
+	for _, slice := range h.slices {
+		if epoch-slice.epoch >= latencyWindows {
+			continue
+		}
+		for x, c := range slice.counts {
+			counts[x] = counts[x] + c
+			total = total + c
+		}
+	}
+	return LatencySnapshot{
+		Count: total,
+		P50:   quantile(counts, total, .5),
+		P95:   quantile(counts, total, .95),
+		P99:   quantile(counts, total, .99),
+	}
+}
+
+func quantile(counts [latencyBuckets]int64, total int64, q float64) time.Duration {
+	if total < 1 {
+		return 0
+	}
+	var rank = int64(math.Ceil(q * float64(total)))
+	var seen int64
+	for x, c := range counts {
+		seen = seen + c
+		if seen >= rank {
+			return latencyBounds[x]
+		}
+	}
+	return latencyBounds[latencyBuckets-1]
+}
+
+// Keys returns every key that has recorded a latency, in sorted order.
+func (r *LatencyRecorder) Keys() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	var keys = make([]string, 0, len(r.histograms))
+	for k := range r.histograms {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type latencyTransport struct {
+	wrapped  http.RoundTripper
+	recorder *LatencyRecorder
+}
+
+// RoundTrip records the time taken to receive response headers. Failed
+// requests are not recorded because their latency is often that of a
+// timeout rather than of the upstream.
+func (c *latencyTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	var start = c.recorder.clock.Now()
+	var resp, e = c.wrapped.RoundTrip(r)
+	if e == nil {
+		c.recorder.Record(c.recorder.Key(r), c.recorder.clock.Now().Sub(start))
+	}
+	return resp, e
+}
+
+// NewLatencyRecording configures a RoundTripper decorator that records the
+// latency of every successful request in the recorder.
+func NewLatencyRecording(recorder *LatencyRecorder) func(http.RoundTripper) http.RoundTripper {
+	return func(wrapped http.RoundTripper) http.RoundTripper {
+		return &latencyTransport{wrapped: wrapped, recorder: recorder}
+	}
+}
@@ -0,0 +1,52 @@
+package transport
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLatencyBucket(t *testing.T) {
+	assert.Equal(t, 0, latencyBucket(0))
+	assert.Equal(t, 0, latencyBucket(latencyMin))
+	assert.Equal(t, 1, latencyBucket(latencyMin+1))
+	assert.Equal(t, latencyBuckets-1, latencyBucket(time.Hour))
+	for x := 1; x < latencyBuckets; x = x + 1 {
+		assert.Equal(t, x, latencyBucket(latencyBounds[x]))
+		assert.Equal(t, x, latencyBucket(latencyBounds[x-1]+1))
+	}
+}
+
+func TestLatencyRecorderSnapshot(t *testing.T) {
+	var clock = newFakeClock()
+	var recorder = NewLatencyRecorder(LatencyRecorderOptionClock(clock), LatencyRecorderOptionWindow(10*time.Second))
+	assert.Equal(t, LatencySnapshot{}, recorder.Snapshot("host"))
+	for x := 1; x <= 100; x = x + 1 {
+		recorder.Record("host", time.Duration(x)*time.Millisecond)
+	}
+	var snapshot = recorder.Snapshot("host")
+	assert.Equal(t, int64(100), snapshot.Count)
+	assert.InEpsilon(t, float64(50*time.Millisecond), float64(snapshot.P50), .2)
+	assert.InEpsilon(t, float64(95*time.Millisecond), float64(snapshot.P95), .2)
+	assert.InEpsilon(t, float64(99*time.Millisecond), float64(snapshot.P99), .2)
+	assert.True(t, snapshot.P50 >= 50*time.Millisecond)
+	assert.Equal(t, []string{"host"}, recorder.Keys())
+
+	clock.advance(11 * time.Second)
+	assert.Zero(t, recorder.Snapshot("host").Count)
+}
+
+func TestLatencyRecording(t *testing.T) {
+	var recorder = NewLatencyRecorder(LatencyRecorderOptionKey(LatencyKeyTag(TagOperation)))
+	var rt = NewLatencyRecording(recorder)(RoundTripperFunc(func(*http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	}))
+	var ctx = WithTags(context.Background(), map[string]string{TagOperation: "get-user"})
+	var req, _ = http.NewRequestWithContext(ctx, http.MethodGet, "http://example.com/", nil)
+	_, _ = rt.RoundTrip(req)
+	assert.Equal(t, "get-user", recorder.Key(req))
+	assert.Equal(t, int64(1), recorder.Snapshot("get-user").Count)
+}
@@ -0,0 +1,165 @@
+package transport
+
+import (
+	"math"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// LatencyWeighted is a Rotator strategy that biases traffic away from slow
+// instances using a peak EWMA of their latency. Each request compares two
+// randomly chosen instances and picks the one with the lower cost, which is
+// the latency estimate multiplied by the number of requests it has in flight.
+// The estimate jumps to any latency above it and decays towards lower ones so
+// that a slow instance is avoided quickly and trusted again gradually. A share
+// of requests is sent to a random instance so that slow instances keep being
+// measured and can recover.
+type LatencyWeighted struct {
+	lock      sync.Mutex
+	instances []latencyInstance
+	decay     time.Duration
+	probe     float64
+	random    func() float64
+	clock     Clock
+}
+
+type latencyInstance struct {
+	// ewma is the latency estimate in nanoseconds, or zero if the instance
+	// has not been measured.
+	ewma    float64
+	updated time.Time
+	pending int
+}
+
+// LatencyWeightedOption is a configuration for the LatencyWeighted strategy.
+type LatencyWeightedOption func(*LatencyWeighted) *LatencyWeighted
+
+// LatencyWeightedOptionDecay sets the time constant over which the latency
+// estimate of an instance decays towards lower measurements. The default is
+// 10 seconds.
+func LatencyWeightedOptionDecay(decay time.Duration) LatencyWeightedOption {
+	return func(l *LatencyWeighted) *LatencyWeighted {
+		l.decay = decay
+		return l
+	}
+}
+
+// LatencyWeightedOptionProbe sets the fraction of requests, between zero and
+// one, sent to a random instance regardless of its latency. The default is
+// 0.05.
+func LatencyWeightedOptionProbe(fraction float64) LatencyWeightedOption {
+	return func(l *LatencyWeighted) *LatencyWeighted {
+		l.probe = fraction
+		return l
+	}
+}
+
+// LatencyWeightedOptionRandSource configures the source of randomness used to
+// choose instances. The source must not be used elsewhere.
+func LatencyWeightedOptionRandSource(source *rand.Rand) LatencyWeightedOption {
+	return func(l *LatencyWeighted) *LatencyWeighted {
+		l.random = lockedRandom(source)
+		return l
+	}
+}
+
+// LatencyWeightedOptionClock configures the Clock used to measure latency.
+func LatencyWeightedOptionClock(clock Clock) LatencyWeightedOption {
+	return func(l *LatencyWeighted) *LatencyWeighted {
+		l.clock = clock
+		return l
+	}
+}
+
+// RotatorOptionLatencyWeighted replaces the round-robin rotation with the
+// LatencyWeighted strategy.
+func RotatorOptionLatencyWeighted(opts ...LatencyWeightedOption) RotatorOption {
+	return func(r *Rotator) *Rotator {
+		r.newStrategy = func(instances int) rotatorStrategy {
+			return newLatencyWeighted(instances, opts...)
+		}
+		return r
+	}
+}
+
+func newLatencyWeighted(instances int, opts ...LatencyWeightedOption) *LatencyWeighted {
+	var l = &LatencyWeighted{
+		decay:  10 * time.Second,
+		probe:  .05,
+		random: rand.Float64,
+		clock:  NewSystemClock(),
+	}
+	for _, opt := range opts {
+		l = opt(l)
+	}
+	l.instances = make([]latencyInstance, instances)
+	return l
+}
+
+func (l *LatencyWeighted) cost(offset int) float64 {
+	var instance = l.instances[offset]
+	return instance.ewma * float64(instance.pending+1)
+}
+
+func (l *LatencyWeighted) pick(_ *http.Request, available func(offset int) bool) (int, func()) {
+	var candidates = make([]int, 0, len(l.instances))
+	for x := range l.instances {
+		if available(x) {
+			candidates = append(candidates, x)
+		}
+	}
+	if len(candidates) < 1 {
+		for x := range l.instances {
+			candidates = append(candidates, x)
+		}
+	}
+	var choose = func(n int) int {
+		return int(l.random()*float64(n)) % n
+	}
+	l.lock.Lock()
+	var first = choose(len(candidates))
+	var selected = candidates[first]
+	if len(candidates) > 1 && l.random() >= l.probe {
+		// Choose the second instance from those remaining so that the two
+		// are always distinct.
+		var second = choose(len(candidates) - 1)
+		if second >= first {
+			second = second + 1
+		}
+		if l.cost(candidates[second]) < l.cost(selected) {
+			selected = candidates[second]
+		}
+	}
+	l.instances[selected].pending = l.instances[selected].pending + 1
+	l.lock.Unlock()
+
+	var start = l.clock.Now()
+	return selected, func() {
+		l.observe(selected, start)
+	}
+}
+
+func (l *LatencyWeighted) observe(offset int, start time.Time) {
+	var now = l.clock.Now()
+	var latency = float64(now.Sub(start))
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	var instance = &l.instances[offset]
+	instance.pending = instance.pending - 1
+	if instance.ewma == 0 || latency > instance.ewma {
+		instance.ewma = latency
+	} else {
+		var weight = math.Exp(-float64(now.Sub(instance.updated)) / float64(l.decay))
+		instance.ewma = instance.ewma*weight + latency*(1-weight)
+	}
+	instance.updated = now
+}
+
+func (l *LatencyWeighted) reset(offset int) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	// Requests in flight on the old instance still report their outcome.
+	l.instances[offset] = latencyInstance{pending: l.instances[offset].pending}
+}
@@ -0,0 +1,78 @@
+package transport
+
+import (
+	"math/rand"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLatencyWeightedPrefersFastInstances(t *testing.T) {
+	var clock = newFakeClock()
+	var counts = make([]int, 3)
+	var created int
+	var factory = func() http.RoundTripper {
+		var instance = created
+		created = created + 1
+		return RoundTripperFunc(func(*http.Request) (*http.Response, error) {
+			counts[instance] = counts[instance] + 1
+			var latency = 10 * time.Millisecond
+			if instance == 0 {
+				latency = 100 * time.Millisecond
+			}
+			clock.advance(latency)
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+		})
+	}
+	var rotator = NewRotator(factory, RotatorOptionInstances(3), RotatorOptionLatencyWeighted(
+		LatencyWeightedOptionClock(clock),
+		LatencyWeightedOptionRandSource(rand.New(rand.NewSource(1))),
+	))
+	var req, _ = http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	for x := 0; x < 1000; x = x + 1 {
+		_, _ = rotator.RoundTrip(req)
+	}
+	assert.Less(t, counts[0], 100, "slow instance was not avoided")
+	assert.Greater(t, counts[0], 0, "slow instance was not probed")
+	assert.Greater(t, counts[1], 300)
+	assert.Greater(t, counts[2], 300)
+}
+
+func TestLatencyWeightedPeakAndDecay(t *testing.T) {
+	var clock = newFakeClock()
+	var l = newLatencyWeighted(1, LatencyWeightedOptionClock(clock), LatencyWeightedOptionDecay(time.Second))
+	var available = func(int) bool { return true }
+	var measure = func(latency time.Duration) {
+		var _, done = l.pick(nil, available)
+		clock.advance(latency)
+		done()
+	}
+	measure(10 * time.Millisecond)
+	assert.Equal(t, float64(10*time.Millisecond), l.instances[0].ewma)
+	measure(100 * time.Millisecond)
+	assert.Equal(t, float64(100*time.Millisecond), l.instances[0].ewma, "estimate did not jump to the peak")
+	measure(10 * time.Millisecond)
+	assert.Less(t, l.instances[0].ewma, float64(100*time.Millisecond))
+	assert.Greater(t, l.instances[0].ewma, float64(10*time.Millisecond))
+	clock.advance(time.Minute)
+	measure(10 * time.Millisecond)
+	assert.InDelta(t, float64(10*time.Millisecond), l.instances[0].ewma, float64(time.Millisecond))
+	assert.Equal(t, 0, l.instances[0].pending)
+
+	l.reset(0)
+	assert.Equal(t, latencyInstance{}, l.instances[0])
+}
+
+func TestLatencyWeightedSkipsUnavailable(t *testing.T) {
+	var l = newLatencyWeighted(3, LatencyWeightedOptionRandSource(rand.New(rand.NewSource(1))))
+	for x := 0; x < 100; x = x + 1 {
+		var offset, done = l.pick(nil, func(offset int) bool { return offset != 1 })
+		done()
+		assert.NotEqual(t, 1, offset)
+	}
+	var offset, done = l.pick(nil, func(int) bool { return false })
+	done()
+	assert.Less(t, offset, 3)
+}
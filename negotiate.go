@@ -0,0 +1,172 @@
+package transport
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strings"
+)
+
+const negotiateScheme = "Negotiate"
+
+// NegotiateProvider produces SPNEGO tokens for the Negotiate decorator. This
+// package does not include a Kerberos implementation so providers usually
+// wrap a GSSAPI binding or a pure Go Kerberos client.
+type NegotiateProvider interface {
+	// Token returns the token to send with the request. The challenge is
+	// nil for the first token of an exchange and contains the token sent by
+	// the server in each later step of a multi-step exchange.
+	Token(r *http.Request, challenge []byte) ([]byte, error)
+}
+
+// NegotiateProviderFunc converts a function to a NegotiateProvider.
+type NegotiateProviderFunc func(r *http.Request, challenge []byte) ([]byte, error)
+
+// Token calls the wrapped function.
+func (f NegotiateProviderFunc) Token(r *http.Request, challenge []byte) ([]byte, error) {
+	return f(r, challenge)
+}
+
+// Negotiate is a decorator that answers HTTP Negotiate authentication
+// challenges, as used by Kerberos protected services and proxies, with
+// tokens from a NegotiateProvider. Requests that are challenged are sent
+// again with each token of the exchange, so it should be installed inside any
+// retry decorators so that each attempt completes its own exchange.
+type Negotiate struct {
+	wrapped    http.RoundTripper
+	provider   NegotiateProvider
+	preemptive bool
+	proxy      bool
+	maxSteps   int
+}
+
+// NegotiateOption is a configuration for the Negotiate decorator.
+type NegotiateOption func(*Negotiate) *Negotiate
+
+// NegotiateOptionPreemptive sends a token with the first request rather than
+// waiting to be challenged, which saves a round trip for upstreams that are
+// known to require authentication.
+func NegotiateOptionPreemptive() NegotiateOption {
+	return func(n *Negotiate) *Negotiate {
+		n.preemptive = true
+		return n
+	}
+}
+
+// NegotiateOptionProxy answers 407 challenges from a proxy in the
+// Proxy-Authenticate header, rather than 401 challenges from the upstream,
+// with tokens in the Proxy-Authorization header. The provider is responsible
+// for selecting the service principal of the proxy. Proxies reached through
+// CONNECT for HTTPS requests must be authenticated with
+// http.Transport.GetProxyConnectHeader instead.
+func NegotiateOptionProxy() NegotiateOption {
+	return func(n *Negotiate) *Negotiate {
+		n.proxy = true
+		return n
+	}
+}
+
+// NegotiateOptionMaxSteps limits the number of challenges answered for a
+// single request. The default is 3.
+func NegotiateOptionMaxSteps(steps int) NegotiateOption {
+	return func(n *Negotiate) *Negotiate {
+		n.maxSteps = steps
+		return n
+	}
+}
+
+func (c *Negotiate) headers() (status int, challenge string, authorization string) {
+	if c.proxy {
+		return http.StatusProxyAuthRequired, "Proxy-Authenticate", "Proxy-Authorization"
+	}
+	return http.StatusUnauthorized, "WWW-Authenticate", "Authorization"
+}
+
+// negotiateChallenge finds the Negotiate challenge among the values of the
+// authenticate header and decodes its token, which is nil for a challenge
+// without one.
+func negotiateChallenge(values []string) ([]byte, bool) {
+	for _, value := range values {
+		for _, challenge := range strings.Split(value, ",") {
+			var scheme, token, _ = strings.Cut(strings.TrimSpace(challenge), " ")
+			if !strings.EqualFold(scheme, negotiateScheme) {
+				continue
+			}
+			token = strings.TrimSpace(token)
+			if token == "" {
+				return nil, true
+			}
+			var decoded, e = base64.StdEncoding.DecodeString(token)
+			if e != nil {
+				return nil, false
+			}
+			return decoded, true
+		}
+	}
+	return nil, false
+}
+
+func (c *Negotiate) authorize(r *http.Request, challenge []byte) error {
+	var token, e = c.provider.Token(r, challenge)
+	if e != nil {
+		return e
+	}
+	var _, _, authorization = c.headers()
+	r.Header.Set(authorization, negotiateScheme+" "+base64.StdEncoding.EncodeToString(token))
+	return nil
+}
+
+// RoundTrip sends the request and answers any Negotiate challenges in the
+// response by sending the request again with a token.
+func (c *Negotiate) RoundTrip(r *http.Request) (*http.Response, error) {
+	if passThrough(r) {
+		// Requests that cannot be replayed only receive preemptive tokens.
+		if c.preemptive {
+			r = r.Clone(r.Context())
+			if e := c.authorize(r, nil); e != nil {
+				return nil, e
+			}
+		}
+		return c.wrapped.RoundTrip(r)
+	}
+	var copier, e = newRequestCopier(r)
+	if e != nil {
+		return nil, e
+	}
+	var status, authenticate, _ = c.headers()
+	var authenticated = c.preemptive
+	var challenge []byte
+	for step := 0; ; step = step + 1 {
+		var req = copier.Copy()
+		if authenticated {
+			if e = c.authorize(req, challenge); e != nil {
+				return nil, e
+			}
+		}
+		var resp *http.Response
+		resp, e = c.wrapped.RoundTrip(req)
+		if e != nil || resp.StatusCode != status || step >= c.maxSteps {
+			return resp, e
+		}
+		var token, ok = negotiateChallenge(resp.Header.Values(authenticate))
+		if !ok || (authenticated && token == nil) {
+			// The upstream does not offer Negotiate or rejected the
+			// exchange without continuing it.
+			return resp, nil
+		}
+		drainBody(resp)
+		challenge = token
+		authenticated = true
+	}
+}
+
+// NewNegotiate configures a RoundTripper decorator that authenticates
+// requests with the HTTP Negotiate scheme using tokens from the provider.
+func NewNegotiate(provider NegotiateProvider, opts ...NegotiateOption) func(http.RoundTripper) http.RoundTripper {
+	return func(wrapped http.RoundTripper) http.RoundTripper {
+		var n = &Negotiate{wrapped: wrapped, provider: provider, maxSteps: 3}
+		for _, opt := range opts {
+			n = opt(n)
+		}
+		return n
+	}
+}
@@ -0,0 +1,75 @@
+package transport
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiateMultiStep(t *testing.T) {
+	var authorizations, bodies []string
+	var rt = NewNegotiate(NegotiateProviderFunc(func(r *http.Request, challenge []byte) ([]byte, error) {
+		return append([]byte("client-"), challenge...), nil
+	}))(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		var body, _ = io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		var authorization = r.Header.Get("Authorization")
+		authorizations = append(authorizations, authorization)
+		var resp = &http.Response{StatusCode: http.StatusUnauthorized, Header: http.Header{}, Body: http.NoBody}
+		switch authorization {
+		case "":
+			resp.Header.Add("WWW-Authenticate", `Basic realm="example", Negotiate`)
+		case "Negotiate Y2xpZW50LQ==": // client-
+			resp.Header.Add("WWW-Authenticate", "Negotiate c2VydmVy") // server
+		default:
+			resp.StatusCode = http.StatusOK
+		}
+		return resp, nil
+	}))
+	var req, _ = http.NewRequest(http.MethodPost, "https://example.com/", io.NopCloser(strings.NewReader("body")))
+	var resp, e = rt.RoundTrip(req)
+	require.NoError(t, e)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, []string{"", "Negotiate Y2xpZW50LQ==", "Negotiate Y2xpZW50LXNlcnZlcg=="}, authorizations)
+	assert.Equal(t, []string{"body", "body", "body"}, bodies)
+}
+
+func TestNegotiateRejected(t *testing.T) {
+	var calls int
+	var rt = NewNegotiate(NegotiateProviderFunc(func(*http.Request, []byte) ([]byte, error) {
+		return []byte("token"), nil
+	}), NegotiateOptionPreemptive())(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		calls = calls + 1
+		assert.Equal(t, "Negotiate dG9rZW4=", r.Header.Get("Authorization"))
+		return &http.Response{
+			StatusCode: http.StatusUnauthorized,
+			Header:     http.Header{"Www-Authenticate": []string{"Negotiate"}},
+			Body:       http.NoBody,
+		}, nil
+	}))
+	var req, _ = http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	var resp, e = rt.RoundTrip(req)
+	require.NoError(t, e)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, 1, calls)
+}
+
+func TestNegotiateProxyAndProviderError(t *testing.T) {
+	var rt = NewNegotiate(NegotiateProviderFunc(func(*http.Request, []byte) ([]byte, error) {
+		return nil, errors.New("no credentials")
+	}), NegotiateOptionProxy())(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusProxyAuthRequired,
+			Header:     http.Header{"Proxy-Authenticate": []string{"Negotiate"}},
+			Body:       http.NoBody,
+		}, nil
+	}))
+	var req, _ = http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	var _, e = rt.RoundTrip(req)
+	assert.EqualError(t, e, "no credentials")
+}
@@ -0,0 +1,130 @@
+package transport
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+)
+
+// URLNormalizer is a decorator that canonicalizes the URL of every request so
+// that cache keys, signatures, and logs computed by the decorators it wraps
+// are consistent across call sites.
+type URLNormalizer struct {
+	wrapped    http.RoundTripper
+	sortQuery  bool
+	cleanPaths bool
+}
+
+// URLNormalizerOption is a configuration for the URLNormalizer decorator.
+type URLNormalizerOption func(*URLNormalizer) *URLNormalizer
+
+// URLNormalizerOptionSortQuery sorts query parameters by key. The relative
+// order of values sharing a key is kept.
+func URLNormalizerOptionSortQuery() URLNormalizerOption {
+	return func(n *URLNormalizer) *URLNormalizer {
+		n.sortQuery = true
+		return n
+	}
+}
+
+// URLNormalizerOptionKeepDotSegments disables the removal of "." and ".."
+// segments from request paths.
+func URLNormalizerOptionKeepDotSegments() URLNormalizerOption {
+	return func(n *URLNormalizer) *URLNormalizer {
+		n.cleanPaths = false
+		return n
+	}
+}
+
+// NormalizeURL returns a canonical copy of the URL. The scheme and host are
+// lowercased, the default port for the scheme is removed, and dot segments
+// are removed from the path.
+func NormalizeURL(u *url.URL) *url.URL {
+	return normalizeURL(u, true, false)
+}
+
+func normalizeURL(u *url.URL, cleanPaths bool, sortQuery bool) *url.URL {
+	var normalized = *u
+	normalized.Scheme = strings.ToLower(u.Scheme)
+	normalized.Host = normalizeHost(normalized.Scheme, u.Host)
+	if cleanPaths {
+		normalized.Path = removeDotSegments(u.Path)
+		if u.RawPath != "" {
+			// Segments are defined by the escaped form because an encoded
+			// slash does not separate them.
+			normalized.RawPath = removeDotSegments(u.RawPath)
+			if unescaped, err := url.PathUnescape(normalized.RawPath); err == nil {
+				normalized.Path = unescaped
+			}
+		}
+	}
+	if sortQuery && u.RawQuery != "" {
+		normalized.RawQuery = sortRawQuery(u.RawQuery)
+	}
+	return &normalized
+}
+
+func normalizeHost(scheme string, host string) string {
+	host = strings.ToLower(host)
+	var port = ""
+	if i := strings.LastIndexByte(host, ':'); i >= 0 && !strings.Contains(host[i:], "]") {
+		host, port = host[:i], host[i+1:]
+	}
+	if port == "" || (scheme == "http" && port == "80") || (scheme == "https" && port == "443") {
+		return host
+	}
+	return host + ":" + port
+}
+
+// removeDotSegments applies the algorithm from RFC 3986 section 5.2.4 while
+// keeping any trailing slash.
+func removeDotSegments(p string) string {
+	if p == "" || !strings.Contains(p, ".") {
+		return p
+	}
+	var cleaned = path.Clean(p)
+	if cleaned == "." {
+		cleaned = ""
+	}
+	if !strings.HasPrefix(p, "/") {
+		cleaned = strings.TrimPrefix(cleaned, "/")
+	}
+	var last = p[strings.LastIndexByte(p, '/')+1:]
+	if (strings.HasSuffix(p, "/") || last == "." || last == "..") && !strings.HasSuffix(cleaned, "/") {
+		cleaned = cleaned + "/"
+	}
+	return cleaned
+}
+
+// sortRawQuery orders the query by key without decoding and re-encoding the
+// parameters so that their original escaping is preserved.
+func sortRawQuery(raw string) string {
+	var params = strings.Split(raw, "&")
+	sort.SliceStable(params, func(i int, j int) bool {
+		var a, _, _ = strings.Cut(params[i], "=")
+		var b, _, _ = strings.Cut(params[j], "=")
+		return a < b
+	})
+	return strings.Join(params, "&")
+}
+
+// RoundTrip normalizes the request URL and calls the wrapped transport.
+func (c *URLNormalizer) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.URL = normalizeURL(r.URL, c.cleanPaths, c.sortQuery)
+	return c.wrapped.RoundTrip(r)
+}
+
+// NewURLNormalizer configures a RoundTripper decorator that canonicalizes
+// request URLs as described by NormalizeURL.
+func NewURLNormalizer(opts ...URLNormalizerOption) func(http.RoundTripper) http.RoundTripper {
+	return func(wrapped http.RoundTripper) http.RoundTripper {
+		var n = &URLNormalizer{wrapped: wrapped, cleanPaths: true}
+		for _, opt := range opts {
+			n = opt(n)
+		}
+		return n
+	}
+}
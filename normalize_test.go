@@ -0,0 +1,45 @@
+package transport
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeURL(t *testing.T) {
+	var tests = map[string]string{
+		"HTTP://Example.COM:80/a/./b/../c":   "http://example.com/a/c",
+		"https://example.com:443/a/b/":       "https://example.com/a/b/",
+		"https://example.com:8443/a/b/..":    "https://example.com:8443/a/",
+		"http://[::1]:80/":                   "http://[::1]/",
+		"http://[::1]/x/.":                   "http://[::1]/x/",
+		"https://example.com/a%2Fb/../c?z=1": "https://example.com/c?z=1",
+		"http://example.com":                 "http://example.com",
+	}
+	for raw, expected := range tests {
+		var u, e = url.Parse(raw)
+		assert.NoError(t, e)
+		var original = u.String()
+		assert.Equal(t, expected, NormalizeURL(u).String(), raw)
+		assert.Equal(t, original, u.String(), "input URL was modified")
+	}
+}
+
+func TestURLNormalizerSortQuery(t *testing.T) {
+	var fixture = &fixtureHeaderTransport{Response: &http.Response{StatusCode: http.StatusOK}}
+	var rt = NewURLNormalizer(URLNormalizerOptionSortQuery())(fixture)
+	var req, _ = http.NewRequest(http.MethodGet, "http://EXAMPLE.com:80/./a?b=2&a=1&b=1&c=%20", nil)
+	_, _ = rt.RoundTrip(req)
+	assert.Equal(t, "http://example.com/a?a=1&b=2&b=1&c=%20", fixture.Request.URL.String())
+	assert.Equal(t, "EXAMPLE.com:80", req.URL.Host)
+}
+
+func TestURLNormalizerKeepDotSegments(t *testing.T) {
+	var fixture = &fixtureHeaderTransport{Response: &http.Response{StatusCode: http.StatusOK}}
+	var rt = NewURLNormalizer(URLNormalizerOptionKeepDotSegments())(fixture)
+	var req, _ = http.NewRequest(http.MethodGet, "http://example.com/a/../b?b=2&a=1", nil)
+	_, _ = rt.RoundTrip(req)
+	assert.Equal(t, "http://example.com/a/../b?b=2&a=1", fixture.Request.URL.String())
+}
@@ -0,0 +1,337 @@
+package transport
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const outlierDetectionSource = "outlier_detection"
+
+// OutlierDetection removes Rotator instances that perform worse than their
+// peers from the rotation for a backoff period. An instance is ejected when it
+// fails a number of requests in a row or, at the end of each evaluation
+// interval, when its success rate falls too far below the mean of the other
+// instances or its latency rises too far above their median. This keeps a
+// single bad TCP path from degrading a share of requests indefinitely.
+type OutlierDetection struct {
+	lock               sync.Mutex
+	instances          []outlierInstance
+	next               time.Time
+	interval           time.Duration
+	consecutive        int
+	minRequests        int
+	stdevFactor        float64
+	latencyFactor      float64
+	baseEjection       time.Duration
+	maxEjection        time.Duration
+	maxEjectionPercent int
+	failure            func(*http.Response, error) bool
+	clock              Clock
+}
+
+type outlierInstance struct {
+	requests     int
+	failures     int
+	consecutive  int
+	latency      time.Duration
+	ejections    int
+	ejectedUntil time.Time
+}
+
+// OutlierDetectionOption is a configuration for OutlierDetection.
+type OutlierDetectionOption func(*OutlierDetection) *OutlierDetection
+
+// OutlierDetectionOptionInterval sets how often success rates and latencies
+// are compared. Statistics are reset after each interval. The default is 10
+// seconds.
+func OutlierDetectionOptionInterval(interval time.Duration) OutlierDetectionOption {
+	return func(o *OutlierDetection) *OutlierDetection {
+		o.interval = interval
+		return o
+	}
+}
+
+// OutlierDetectionOptionConsecutiveFailures sets the number of failures in a
+// row that eject an instance immediately. Zero disables the check. The
+// default is 5.
+func OutlierDetectionOptionConsecutiveFailures(failures int) OutlierDetectionOption {
+	return func(o *OutlierDetection) *OutlierDetection {
+		o.consecutive = failures
+		return o
+	}
+}
+
+// OutlierDetectionOptionMinRequests sets the number of requests an instance
+// must handle within an interval before its success rate and latency are
+// compared with the other instances. The default is 10.
+func OutlierDetectionOptionMinRequests(requests int) OutlierDetectionOption {
+	return func(o *OutlierDetection) *OutlierDetection {
+		o.minRequests = requests
+		return o
+	}
+}
+
+// OutlierDetectionOptionSuccessRateFactor sets how many standard deviations
+// below the mean success rate an instance may fall before it is ejected. Zero
+// disables the check. The default is 1.9.
+func OutlierDetectionOptionSuccessRateFactor(factor float64) OutlierDetectionOption {
+	return func(o *OutlierDetection) *OutlierDetection {
+		o.stdevFactor = factor
+		return o
+	}
+}
+
+// OutlierDetectionOptionLatencyFactor sets how many times the median mean
+// latency of the instances an instance may reach before it is ejected. Zero
+// disables the check. The default is 3.
+func OutlierDetectionOptionLatencyFactor(factor float64) OutlierDetectionOption {
+	return func(o *OutlierDetection) *OutlierDetection {
+		o.latencyFactor = factor
+		return o
+	}
+}
+
+// OutlierDetectionOptionEjection sets the base and maximum ejection
+// durations. Each ejection of an instance lasts the base duration multiplied
+// by the number of times it has been ejected, up to the maximum. The defaults
+// are 30 seconds and 5 minutes.
+func OutlierDetectionOptionEjection(base time.Duration, max time.Duration) OutlierDetectionOption {
+	return func(o *OutlierDetection) *OutlierDetection {
+		o.baseEjection = base
+		o.maxEjection = max
+		return o
+	}
+}
+
+// OutlierDetectionOptionMaxEjectionPercent sets the largest share of
+// instances that may be ejected at once. At least one instance always remains
+// in the rotation. The default is 50.
+func OutlierDetectionOptionMaxEjectionPercent(percent int) OutlierDetectionOption {
+	return func(o *OutlierDetection) *OutlierDetection {
+		o.maxEjectionPercent = percent
+		return o
+	}
+}
+
+// OutlierDetectionOptionFailure sets the function that classifies outcomes as
+// failures. The default treats errors and 5xx status codes as failures.
+func OutlierDetectionOptionFailure(failure func(*http.Response, error) bool) OutlierDetectionOption {
+	return func(o *OutlierDetection) *OutlierDetection {
+		o.failure = failure
+		return o
+	}
+}
+
+// OutlierDetectionOptionClock configures the Clock used to measure latency and
+// time ejections.
+func OutlierDetectionOptionClock(clock Clock) OutlierDetectionOption {
+	return func(o *OutlierDetection) *OutlierDetection {
+		o.clock = clock
+		return o
+	}
+}
+
+func newOutlierDetection(instances int, opts ...OutlierDetectionOption) *OutlierDetection {
+	var o = &OutlierDetection{
+		interval:           10 * time.Second,
+		consecutive:        5,
+		minRequests:        10,
+		stdevFactor:        1.9,
+		latencyFactor:      3,
+		baseEjection:       30 * time.Second,
+		maxEjection:        5 * time.Minute,
+		maxEjectionPercent: 50,
+		failure:            defaultHealthFailure,
+		clock:              NewSystemClock(),
+	}
+	for _, opt := range opts {
+		o = opt(o)
+	}
+	o.instances = make([]outlierInstance, instances)
+	o.next = o.clock.Now().Add(o.interval)
+	return o
+}
+
+// ejected reports whether the instance at the offset is out of the rotation.
+func (o *OutlierDetection) ejected(offset int, now time.Time) bool {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	return o.instances[offset].ejectedUntil.After(now)
+}
+
+// Ejected returns the offsets of the instances that are currently out of the
+// rotation.
+func (o *OutlierDetection) Ejected() []int {
+	var now = o.clock.Now()
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	var result []int
+	for x := range o.instances {
+		if o.instances[x].ejectedUntil.After(now) {
+			result = append(result, x)
+		}
+	}
+	return result
+}
+
+// reset clears the statistics and ejection of an instance that has been
+// replaced with a new one.
+func (o *OutlierDetection) reset(offset int) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.instances[offset] = outlierInstance{}
+}
+
+// observe records the outcome of a request sent through the instance at the
+// offset and ejects any instances that have become outliers.
+func (o *OutlierDetection) observe(ctx context.Context, offset int, latency time.Duration, resp *http.Response, e error) {
+	var failed = o.failure(resp, e)
+	var now = o.clock.Now()
+	o.lock.Lock()
+	var ejected []int
+	var instance = &o.instances[offset]
+	instance.requests = instance.requests + 1
+	instance.latency = instance.latency + latency
+	if failed {
+		instance.failures = instance.failures + 1
+		instance.consecutive = instance.consecutive + 1
+	} else {
+		instance.consecutive = 0
+	}
+	if o.consecutive > 0 && instance.consecutive >= o.consecutive && o.eject(offset, now) {
+		ejected = append(ejected, offset)
+	}
+	if !now.Before(o.next) {
+		ejected = append(ejected, o.evaluate(now)...)
+	}
+	var durations = make([]time.Duration, 0, len(ejected))
+	for _, x := range ejected {
+		durations = append(durations, o.instances[x].ejectedUntil.Sub(now))
+	}
+	o.lock.Unlock()
+	for x, instance := range ejected {
+		emitEvent(ctx, TransportEvent{Type: EventInstanceEjected, Source: outlierDetectionSource, Instance: instance, Delay: durations[x]})
+	}
+}
+
+// eject removes the instance from the rotation unless doing so would exceed
+// the maximum ejection percentage. It must be called with the lock held.
+func (o *OutlierDetection) eject(offset int, now time.Time) bool {
+	var instance = &o.instances[offset]
+	if instance.ejectedUntil.After(now) {
+		return false
+	}
+	var ejected int
+	for x := range o.instances {
+		if o.instances[x].ejectedUntil.After(now) {
+			ejected = ejected + 1
+		}
+	}
+	var allowed = len(o.instances) * o.maxEjectionPercent / 100
+	if allowed > len(o.instances)-1 {
+		allowed = len(o.instances) - 1
+	}
+	if ejected >= allowed {
+		return false
+	}
+	instance.ejections = instance.ejections + 1
+	var duration = o.baseEjection * time.Duration(instance.ejections)
+	if o.maxEjection > 0 && duration > o.maxEjection {
+		duration = o.maxEjection
+	}
+	instance.ejectedUntil = now.Add(duration)
+	instance.consecutive = 0
+	return true
+}
+
+// evaluate compares the instances that handled enough requests in the last
+// interval, ejects the outliers, and starts a new interval. It must be called
+// with the lock held.
+func (o *OutlierDetection) evaluate(now time.Time) []int {
+	o.next = now.Add(o.interval)
+	var eligible []int
+	for x := range o.instances {
+		if o.instances[x].requests >= o.minRequests && !o.instances[x].ejectedUntil.After(now) {
+			eligible = append(eligible, x)
+		}
+	}
+	var outliers []int
+	// Comparisons need peers to compare against.
+	if len(eligible) > 1 {
+		outliers = append(outliers, o.successRateOutliers(eligible)...)
+		outliers = append(outliers, o.latencyOutliers(eligible)...)
+	}
+	var ejected []int
+	var seen = make(map[int]bool, len(outliers))
+	for _, x := range outliers {
+		if !seen[x] && o.eject(x, now) {
+			ejected = append(ejected, x)
+		}
+		seen[x] = true
+	}
+	for x := range o.instances {
+		var ejections = o.instances[x].ejections
+		var until = o.instances[x].ejectedUntil
+		// Instances that spend an interval in the rotation without being
+		// ejected work back down to shorter ejections.
+		if ejections > 0 && !until.After(now) {
+			ejections = ejections - 1
+		}
+		o.instances[x] = outlierInstance{ejections: ejections, ejectedUntil: until}
+	}
+	return ejected
+}
+
+func (o *OutlierDetection) successRateOutliers(eligible []int) []int {
+	if o.stdevFactor <= 0 {
+		return nil
+	}
+	var rates = make([]float64, len(eligible))
+	var mean float64
+	for x, offset := range eligible {
+		var instance = o.instances[offset]
+		rates[x] = float64(instance.requests-instance.failures) / float64(instance.requests)
+		mean = mean + rates[x]
+	}
+	mean = mean / float64(len(rates))
+	var variance float64
+	for _, rate := range rates {
+		variance = variance + (rate-mean)*(rate-mean)
+	}
+	var threshold = mean - o.stdevFactor*math.Sqrt(variance/float64(len(rates)))
+	var outliers []int
+	for x, offset := range eligible {
+		if rates[x] < threshold {
+			outliers = append(outliers, offset)
+		}
+	}
+	return outliers
+}
+
+func (o *OutlierDetection) latencyOutliers(eligible []int) []int {
+	if o.latencyFactor <= 0 {
+		return nil
+	}
+	var means = make([]float64, len(eligible))
+	for x, offset := range eligible {
+		var instance = o.instances[offset]
+		means[x] = float64(instance.latency) / float64(instance.requests)
+	}
+	var sorted = append([]float64(nil), means...)
+	sort.Float64s(sorted)
+	var median = sorted[len(sorted)/2]
+	if len(sorted)%2 == 0 {
+		median = (sorted[len(sorted)/2-1] + median) / 2
+	}
+	var outliers []int
+	for x, offset := range eligible {
+		if means[x] > o.latencyFactor*median {
+			outliers = append(outliers, offset)
+		}
+	}
+	return outliers
+}
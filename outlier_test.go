@@ -0,0 +1,148 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newOutlierRotator creates a Rotator whose instances call the handler with
+// their offset.
+func newOutlierRotator(instances int, handler func(instance int) (*http.Response, error), opts ...OutlierDetectionOption) *Rotator {
+	var created int
+	var factory = func() http.RoundTripper {
+		var instance = created % instances
+		created = created + 1
+		return RoundTripperFunc(func(*http.Request) (*http.Response, error) {
+			return handler(instance)
+		})
+	}
+	return NewRotator(factory, RotatorOptionInstances(instances), RotatorOptionOutlierDetection(opts...))
+}
+
+func TestOutlierDetectionConsecutiveFailures(t *testing.T) {
+	var clock = newFakeClock()
+	var counts = make([]int, 3)
+	var rotator = newOutlierRotator(3, func(instance int) (*http.Response, error) {
+		counts[instance] = counts[instance] + 1
+		if instance == 1 {
+			return nil, errors.New("connection reset")
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	},
+		OutlierDetectionOptionClock(clock),
+		OutlierDetectionOptionInterval(time.Hour),
+		OutlierDetectionOptionConsecutiveFailures(2),
+		OutlierDetectionOptionEjection(time.Minute, 5*time.Minute),
+	)
+	var collector = &eventCollector{}
+	var req, _ = http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	req = req.WithContext(WithEventSubscriber(context.Background(), collector))
+	for x := 0; x < 6; x = x + 1 {
+		_, _ = rotator.RoundTrip(req)
+	}
+	assert.Equal(t, []int{1}, rotator.Ejected())
+	assert.Equal(t, []EventType{EventInstanceEjected}, collector.types())
+	assert.Equal(t, 1, collector.events[0].Instance)
+	assert.Equal(t, time.Minute, collector.events[0].Delay)
+	assert.Equal(t, 2, counts[1])
+
+	for x := 0; x < 6; x = x + 1 {
+		_, _ = rotator.RoundTrip(req)
+	}
+	assert.Equal(t, 2, counts[1], "ejected instance was used")
+	assert.Equal(t, 10, counts[0]+counts[2])
+
+	// The instance returns after the ejection and its next ejection is longer.
+	clock.advance(time.Minute)
+	assert.Empty(t, rotator.Ejected())
+	for x := 0; x < 6; x = x + 1 {
+		_, _ = rotator.RoundTrip(req)
+	}
+	assert.Equal(t, []int{1}, rotator.Ejected())
+	assert.Equal(t, 2*time.Minute, collector.events[1].Delay)
+}
+
+func TestOutlierDetectionMaxEjectionPercent(t *testing.T) {
+	var rotator = newOutlierRotator(2, func(int) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusBadGateway, Body: http.NoBody}, nil
+	}, OutlierDetectionOptionConsecutiveFailures(1), OutlierDetectionOptionMaxEjectionPercent(100))
+	var req, _ = http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	for x := 0; x < 4; x = x + 1 {
+		var resp, e = rotator.RoundTrip(req)
+		require.NoError(t, e)
+		assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	}
+	assert.Len(t, rotator.Ejected(), 1, "every instance was ejected")
+}
+
+func TestOutlierDetectionSuccessRate(t *testing.T) {
+	var clock = newFakeClock()
+	var rotator = newOutlierRotator(4, func(instance int) (*http.Response, error) {
+		if instance == 2 {
+			return &http.Response{StatusCode: http.StatusServiceUnavailable, Body: http.NoBody}, nil
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	},
+		OutlierDetectionOptionClock(clock),
+		OutlierDetectionOptionInterval(time.Second),
+		OutlierDetectionOptionConsecutiveFailures(0),
+		OutlierDetectionOptionMinRequests(5),
+		OutlierDetectionOptionSuccessRateFactor(1),
+	)
+	var req, _ = http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	for x := 0; x < 20; x = x + 1 {
+		_, _ = rotator.RoundTrip(req)
+	}
+	assert.Empty(t, rotator.Ejected(), "ejected before the interval ended")
+	clock.advance(time.Second)
+	_, _ = rotator.RoundTrip(req)
+	assert.Equal(t, []int{2}, rotator.Ejected())
+}
+
+func TestOutlierDetectionLatency(t *testing.T) {
+	var clock = newFakeClock()
+	var rotator = newOutlierRotator(3, func(instance int) (*http.Response, error) {
+		var latency = 10 * time.Millisecond
+		if instance == 0 {
+			latency = 200 * time.Millisecond
+		}
+		clock.advance(latency)
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	},
+		OutlierDetectionOptionClock(clock),
+		OutlierDetectionOptionInterval(500*time.Millisecond),
+		OutlierDetectionOptionMinRequests(2),
+	)
+	var req, _ = http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	for x := 0; x < 12; x = x + 1 {
+		_, _ = rotator.RoundTrip(req)
+	}
+	assert.Equal(t, []int{0}, rotator.Ejected())
+}
+
+func TestOutlierDetectionReplaceResets(t *testing.T) {
+	var rotator = newOutlierRotator(2, func(instance int) (*http.Response, error) {
+		if instance == 0 {
+			return nil, errors.New("connection reset")
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	}, OutlierDetectionOptionConsecutiveFailures(1))
+	var req, _ = http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	for x := 0; x < 2; x = x + 1 {
+		_, _ = rotator.RoundTrip(req)
+	}
+	require.Equal(t, []int{0}, rotator.Ejected())
+	rotator.replace(0)
+	assert.Empty(t, rotator.Ejected())
+}
+
+func TestRotatorWithoutOutlierDetection(t *testing.T) {
+	var rotator = NewRotator(func() http.RoundTripper { return &roundTripperForRotatorTests{} })
+	assert.Nil(t, rotator.Ejected())
+}
@@ -0,0 +1,46 @@
+package transport
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+type passThroughKey struct{}
+
+// WithPassThrough overrides whether decorators that buffer and replay
+// requests, such as Retry, RetryAfter, and Hedger, send requests made with the
+// context directly to the transport they wrap. By default protocol upgrade,
+// CONNECT, and streaming requests are passed through.
+func WithPassThrough(ctx context.Context, passThrough bool) context.Context {
+	return context.WithValue(ctx, passThroughKey{}, passThrough)
+}
+
+// IsUpgradeRequest reports whether the request is a CONNECT request or asks
+// to switch protocols, as is done for WebSockets. Replaying these requests
+// is not safe because the connection is taken over by the new protocol.
+func IsUpgradeRequest(r *http.Request) bool {
+	if r.Method == http.MethodConnect {
+		return true
+	}
+	if r.Header.Get("Upgrade") == "" {
+		return false
+	}
+	for _, value := range r.Header.Values("Connection") {
+		for _, token := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// passThrough reports whether a replaying decorator should skip its behavior
+// for the request.
+func passThrough(r *http.Request) bool {
+	if override, ok := r.Context().Value(passThroughKey{}).(bool); ok {
+		return override
+	}
+	return IsUpgradeRequest(r) || IsStreamingRequest(r)
+}
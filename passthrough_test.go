@@ -0,0 +1,73 @@
+package transport
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsUpgradeRequest(t *testing.T) {
+	var connect, _ = http.NewRequest(http.MethodConnect, "https://localhost", nil)
+	assert.True(t, IsUpgradeRequest(connect))
+
+	var websocket, _ = http.NewRequest(http.MethodGet, "https://localhost", nil)
+	websocket.Header.Set("Upgrade", "websocket")
+	websocket.Header.Set("Connection", "keep-alive, Upgrade")
+	assert.True(t, IsUpgradeRequest(websocket))
+
+	websocket.Header.Del("Connection")
+	assert.False(t, IsUpgradeRequest(websocket))
+
+	var plain, _ = http.NewRequest(http.MethodGet, "https://localhost", nil)
+	assert.False(t, IsUpgradeRequest(plain))
+}
+
+func TestDecoratorsPassThroughUpgrades(t *testing.T) {
+	var decorators = map[string]Decorator{
+		"retry":      NewRetrier(NewFixedBackoffPolicy(0), NewLimitedRetryPolicy(3, NewStatusCodeRetryPolicy(http.StatusInternalServerError))),
+		"retryafter": NewRetryAfter(),
+		"hedger":     NewHedger(NewFixedBackoffPolicy(time.Millisecond)),
+	}
+	for name, decorator := range decorators {
+		decorator := decorator
+		t.Run(name, func(t *testing.T) {
+			var req, _ = http.NewRequest(http.MethodGet, "https://localhost", nil)
+			req.Header.Set("Upgrade", "websocket")
+			req.Header.Set("Connection", "Upgrade")
+			var calls int
+			var rt = decorator(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+				calls = calls + 1
+				assert.Equal(t, req, r)
+				time.Sleep(5 * time.Millisecond)
+				return &http.Response{StatusCode: http.StatusTooManyRequests, Body: http.NoBody}, nil
+			}))
+			var resp, e = rt.RoundTrip(req)
+			assert.NoError(t, e)
+			assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+			assert.Equal(t, 1, calls)
+		})
+	}
+}
+
+func TestWithPassThroughOverride(t *testing.T) {
+	var calls int
+	var rt = NewRetrier(
+		NewFixedBackoffPolicy(0),
+		NewLimitedRetryPolicy(1, NewStatusCodeRetryPolicy(http.StatusInternalServerError)),
+	)(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		calls = calls + 1
+		return &http.Response{StatusCode: http.StatusInternalServerError, Body: http.NoBody}, nil
+	}))
+
+	var req, _ = http.NewRequestWithContext(WithPassThrough(context.Background(), true), http.MethodGet, "https://localhost", nil)
+	_, _ = rt.RoundTrip(req)
+	assert.Equal(t, 1, calls)
+
+	calls = 0
+	req, _ = http.NewRequestWithContext(WithPassThrough(context.Background(), false), http.MethodConnect, "https://localhost", nil)
+	_, _ = rt.RoundTrip(req)
+	assert.Equal(t, 2, calls)
+}
@@ -0,0 +1,110 @@
+package transport
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// HostOverride is a chain of decorators used for requests to the hosts that
+// match a pattern.
+type HostOverride struct {
+	// Pattern is a host name, such as api.example.com, or a wildcard that
+	// matches every subdomain of a name, such as *.example.com. A pattern
+	// with a port, such as api.example.com:8443, only matches requests to
+	// that port.
+	Pattern string
+	// Chain applied to requests for the matching hosts.
+	Chain Chain
+}
+
+type hostRoute struct {
+	pattern  string
+	wildcard bool
+	port     bool
+	rt       http.RoundTripper
+}
+
+func (h hostRoute) match(hostname string, hostport string) bool {
+	var host = hostname
+	if h.port {
+		host = hostport
+	}
+	if !h.wildcard {
+		return host == h.pattern
+	}
+	return strings.HasSuffix(host, h.pattern) && len(host) > len(h.pattern)
+}
+
+// PerHost is a decorator that sends each request through the chain of the
+// most specific HostOverride matching its host, or through a default chain
+// when none match, so that one client can honor the timeouts, retry limits,
+// rate limits, and headers that each destination requires. Exact host names
+// take precedence over wildcards and, among wildcards, the longest one wins.
+type PerHost struct {
+	routes   []hostRoute
+	fallback http.RoundTripper
+}
+
+// RoundTrip sends the request through the chain selected for its host.
+func (c *PerHost) RoundTrip(r *http.Request) (*http.Response, error) {
+	return c.route(r).RoundTrip(r)
+}
+
+func (c *PerHost) route(r *http.Request) http.RoundTripper {
+	var hostname = strings.TrimSuffix(strings.ToLower(r.URL.Hostname()), ".")
+	var hostport = hostname
+	if port := r.URL.Port(); port != "" {
+		hostport = hostname + ":" + port
+	}
+	for _, route := range c.routes {
+		if route.match(hostname, hostport) {
+			return route.rt
+		}
+	}
+	return c.fallback
+}
+
+// NewPerHost configures a RoundTripper decorator that selects a chain for
+// each request by its host. Every chain, including the fallback used for
+// hosts without an override, is applied once to the wrapped transport so
+// that stateful decorators are shared by all requests to the same hosts. It
+// panics if a pattern is empty or appears more than once, which is a
+// programming error.
+func NewPerHost(overrides []HostOverride, fallback Chain) func(http.RoundTripper) http.RoundTripper {
+	var seen = make(map[string]bool, len(overrides))
+	for _, override := range overrides {
+		var pattern = strings.ToLower(override.Pattern)
+		if pattern == "" || pattern == "*." || seen[pattern] {
+			panic(fmt.Sprintf("transport: invalid or duplicate host pattern %q", override.Pattern))
+		}
+		seen[pattern] = true
+	}
+	return func(wrapped http.RoundTripper) http.RoundTripper {
+		var p = &PerHost{fallback: fallback.Apply(wrapped)}
+		for _, override := range overrides {
+			var pattern = strings.ToLower(override.Pattern)
+			var wildcard = strings.HasPrefix(pattern, "*.")
+			if wildcard {
+				pattern = pattern[1:]
+			}
+			p.routes = append(p.routes, hostRoute{
+				pattern:  pattern,
+				wildcard: wildcard,
+				port:     strings.Contains(pattern, ":"),
+				rt:       override.Chain.Apply(wrapped),
+			})
+		}
+		sort.SliceStable(p.routes, func(i int, j int) bool {
+			if p.routes[i].wildcard != p.routes[j].wildcard {
+				return !p.routes[i].wildcard
+			}
+			if p.routes[i].port != p.routes[j].port {
+				return p.routes[i].port
+			}
+			return len(p.routes[i].pattern) > len(p.routes[j].pattern)
+		})
+		return p
+	}
+}
@@ -0,0 +1,70 @@
+package transport
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newHeaderDecorator(value string) Decorator {
+	return NewHeader(func(*http.Request) (string, string) {
+		return "X-Route", value
+	})
+}
+
+func TestPerHost(t *testing.T) {
+	var rt = NewPerHost([]HostOverride{
+		{Pattern: "*.example.com", Chain: Chain{newHeaderDecorator("wildcard")}},
+		{Pattern: "API.example.com", Chain: Chain{newHeaderDecorator("exact")}},
+		{Pattern: "*.eu.example.com", Chain: Chain{newHeaderDecorator("longer")}},
+		{Pattern: "api.example.com:8443", Chain: Chain{newHeaderDecorator("port")}},
+	}, Chain{newHeaderDecorator("default")})(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{"X-Route": []string{r.Header.Get("X-Route")}}, Body: http.NoBody}, nil
+	}))
+
+	for url, expected := range map[string]string{
+		"https://api.example.com/":       "exact",
+		"https://api.example.com.:443/":  "exact",
+		"https://api.example.com:8443/":  "port",
+		"https://www.example.com/":       "wildcard",
+		"https://a.b.example.com/":       "wildcard",
+		"https://api.eu.example.com/":    "longer",
+		"https://example.com/":           "default",
+		"https://notexample.com/":        "default",
+		"https://api.example.com.evil/":  "default",
+		"https://partner.example.org/x/": "default",
+	} {
+		var req, _ = http.NewRequest(http.MethodGet, url, nil)
+		var resp, e = rt.RoundTrip(req)
+		require.NoError(t, e)
+		assert.Equal(t, expected, resp.Header.Get("X-Route"), url)
+	}
+}
+
+func TestPerHostSharedState(t *testing.T) {
+	var attempts = map[string]int{}
+	var rt = NewPerHost([]HostOverride{
+		{Pattern: "flaky.example.com", Chain: Chain{
+			NewRetrier(NewFixedBackoffPolicy(time.Millisecond), NewLimitedRetryPolicy(3, NewStatusCodeRetryPolicy(http.StatusServiceUnavailable))),
+		}},
+	}, nil)(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		attempts[r.URL.Host] = attempts[r.URL.Host] + 1
+		return &http.Response{StatusCode: http.StatusServiceUnavailable, Body: http.NoBody}, nil
+	}))
+	for _, url := range []string{"https://flaky.example.com/", "https://stable.example.com/"} {
+		var req, _ = http.NewRequest(http.MethodGet, url, nil)
+		var _, e = rt.RoundTrip(req)
+		require.NoError(t, e)
+	}
+	assert.Equal(t, map[string]int{"flaky.example.com": 4, "stable.example.com": 1}, attempts)
+}
+
+func TestPerHostInvalidPattern(t *testing.T) {
+	assert.Panics(t, func() { NewPerHost([]HostOverride{{Pattern: ""}}, nil) })
+	assert.Panics(t, func() {
+		NewPerHost([]HostOverride{{Pattern: "api.example.com"}, {Pattern: "API.example.com"}}, nil)
+	})
+}
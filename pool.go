@@ -0,0 +1,115 @@
+package transport
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"sync"
+)
+
+// PoolStats summarizes the connections used for requests to a host.
+type PoolStats struct {
+	// InFlight is the number of requests that are waiting for a connection
+	// or whose response body has not been closed.
+	InFlight int64
+	// NewConns is the number of requests sent on a newly dialed connection.
+	NewConns int64
+	// ReusedConns is the number of requests sent on a connection that had
+	// been used before.
+	ReusedConns int64
+	// IdleConns is the number of reused connections that were taken from the
+	// idle pool rather than from a request that had just finished.
+	IdleConns int64
+}
+
+// PoolTracker records how requests use the connection pool of a transport,
+// grouped by host, so that pool exhaustion and poor connection reuse can be
+// spotted. It is safe for concurrent use.
+type PoolTracker struct {
+	lock  sync.Mutex
+	hosts map[string]*PoolStats
+}
+
+// NewPoolTracker creates an empty PoolTracker.
+func NewPoolTracker() *PoolTracker {
+	return &PoolTracker{hosts: make(map[string]*PoolStats)}
+}
+
+// Stats returns the statistics recorded for the host.
+func (t *PoolTracker) Stats(host string) PoolStats {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if stats, ok := t.hosts[host]; ok {
+		return *stats
+	}
+	return PoolStats{}
+}
+
+// Keys returns every host with recorded requests in sorted order.
+func (t *PoolTracker) Keys() []string {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	var keys = make([]string, 0, len(t.hosts))
+	for k := range t.hosts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (t *PoolTracker) update(host string, f func(*PoolStats)) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	var stats, ok = t.hosts[host]
+	if !ok {
+		stats = &PoolStats{}
+		t.hosts[host] = stats
+	}
+	f(stats)
+}
+
+type poolTransport struct {
+	wrapped http.RoundTripper
+	tracker *PoolTracker
+}
+
+// RoundTrip records the connection used by the request in the tracker.
+func (c *poolTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	var host = r.URL.Host
+	c.tracker.update(host, func(s *PoolStats) { s.InFlight = s.InFlight + 1 })
+	var done = func() {
+		c.tracker.update(host, func(s *PoolStats) { s.InFlight = s.InFlight - 1 })
+	}
+	var ctx = httptrace.WithClientTrace(r.Context(), &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			c.tracker.update(host, func(s *PoolStats) {
+				switch {
+				case !info.Reused:
+					s.NewConns = s.NewConns + 1
+				case info.WasIdle:
+					s.ReusedConns = s.ReusedConns + 1
+					s.IdleConns = s.IdleConns + 1
+				default:
+					s.ReusedConns = s.ReusedConns + 1
+				}
+			})
+		},
+	})
+	var resp, e = c.wrapped.RoundTrip(r.WithContext(ctx))
+	if e != nil {
+		done()
+		return resp, e
+	}
+	return withCancelBody(resp, context.CancelFunc(done)), nil
+}
+
+// NewPoolTracking configures a RoundTripper decorator that records the
+// connection pool usage of every request in the tracker. It should be
+// applied directly to the transport so that each attempt made by decorators
+// such as Retry is recorded.
+func NewPoolTracking(tracker *PoolTracker) func(http.RoundTripper) http.RoundTripper {
+	return func(wrapped http.RoundTripper) http.RoundTripper {
+		return &poolTransport{wrapped: wrapped, tracker: tracker}
+	}
+}
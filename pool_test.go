@@ -0,0 +1,49 @@
+package transport
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPoolTracking(t *testing.T) {
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	var host = func() string {
+		var u, _ = url.Parse(server.URL)
+		return u.Host
+	}()
+
+	var tracker = NewPoolTracker()
+	var base = &http.Transport{}
+	defer base.CloseIdleConnections()
+	var rt = NewPoolTracking(tracker)(base)
+
+	var req, _ = http.NewRequest(http.MethodGet, server.URL, nil)
+	var resp, e = rt.RoundTrip(req)
+	require.NoError(t, e)
+	assert.Equal(t, PoolStats{InFlight: 1, NewConns: 1}, tracker.Stats(host))
+	_, _ = io.ReadAll(resp.Body)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, int64(0), tracker.Stats(host).InFlight)
+
+	req, _ = http.NewRequest(http.MethodGet, server.URL, nil)
+	resp, e = rt.RoundTrip(req)
+	require.NoError(t, e)
+	_, _ = io.ReadAll(resp.Body)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, PoolStats{NewConns: 1, ReusedConns: 1, IdleConns: 1}, tracker.Stats(host))
+	assert.Equal(t, []string{host}, tracker.Keys())
+
+	req, _ = http.NewRequest(http.MethodGet, "http://127.0.0.1:1/", nil)
+	_, e = rt.RoundTrip(req)
+	require.Error(t, e)
+	assert.Equal(t, int64(0), tracker.Stats("127.0.0.1:1").InFlight)
+}
@@ -0,0 +1,40 @@
+package transport
+
+import (
+	"context"
+	"runtime/pprof"
+)
+
+const (
+	// PprofLabelComponent is the profiler label that names the decorator or
+	// background component that started a goroutine, such as "hedger".
+	PprofLabelComponent = "transport_component"
+	// PprofLabelHost is the profiler label that names the host a goroutine
+	// sends requests to, when there is one.
+	PprofLabelHost = "transport_host"
+)
+
+func pprofLabels(component string, host string) pprof.LabelSet {
+	if host == "" {
+		return pprof.Labels(PprofLabelComponent, component)
+	}
+	return pprof.Labels(PprofLabelComponent, component, PprofLabelHost, host)
+}
+
+// goLabeled runs the function in a new goroutine that carries the profiler
+// labels of the context along with the component and host, so that goroutine
+// and CPU profiles attribute the work to this package. Goroutines started by
+// the function inherit the labels.
+func goLabeled(ctx context.Context, component string, host string, f func(context.Context)) {
+	go pprof.Do(ctx, pprofLabels(component, host), f)
+}
+
+// labelGoroutine adds the component and host to the profiler labels of the
+// calling goroutine, which is expected to be dedicated to a long running
+// background loop. The returned function restores the labels of the context.
+func labelGoroutine(ctx context.Context, component string, host string) func() {
+	pprof.SetGoroutineLabels(pprof.WithLabels(ctx, pprofLabels(component, host)))
+	return func() {
+		pprof.SetGoroutineLabels(ctx)
+	}
+}
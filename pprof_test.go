@@ -0,0 +1,75 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"runtime/pprof"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// goroutineProfile returns the goroutine profile in its text format, which
+// lists the labels of each goroutine.
+func goroutineProfile(t *testing.T) string {
+	var buf bytes.Buffer
+	require.NoError(t, pprof.Lookup("goroutine").WriteTo(&buf, 1))
+	return buf.String()
+}
+
+func TestPprofLabelsHedger(t *testing.T) {
+	var started = make(chan struct{})
+	var release = make(chan struct{})
+	var rt = NewHedger(NewFixedBackoffPolicy(time.Hour))(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		close(started)
+		<-release
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	}))
+	var done = make(chan error)
+	go func() {
+		var req, _ = http.NewRequest(http.MethodGet, "https://api.example.com/", nil)
+		var resp, e = rt.RoundTrip(req)
+		if e == nil {
+			_ = resp.Body.Close()
+		}
+		done <- e
+	}()
+	<-started
+	var profile = goroutineProfile(t)
+	close(release)
+	require.NoError(t, <-done)
+	assert.Contains(t, profile, `"transport_component":"hedger"`)
+	assert.Contains(t, profile, `"transport_host":"api.example.com"`)
+}
+
+func TestPprofLabelsBackground(t *testing.T) {
+	var ctx = pprof.WithLabels(context.Background(), pprof.Labels("service", "payments"))
+	var labeled = make(chan context.Context)
+	goLabeled(ctx, "prober", "", func(ctx context.Context) {
+		labeled <- ctx
+	})
+	var inner = <-labeled
+	var component, _ = pprof.Label(inner, PprofLabelComponent)
+	assert.Equal(t, "prober", component)
+	var service, _ = pprof.Label(inner, "service")
+	assert.Equal(t, "payments", service, "labels of the context were lost")
+	var _, found = pprof.Label(inner, PprofLabelHost)
+	assert.False(t, found)
+
+	var running = make(chan struct{})
+	var restored = make(chan string)
+	go func() {
+		var restore = labelGoroutine(ctx, "dns_watcher", "")
+		running <- struct{}{}
+		<-running
+		restore()
+		restored <- goroutineProfile(t)
+	}()
+	<-running
+	assert.Contains(t, goroutineProfile(t), `"transport_component":"dns_watcher"`)
+	running <- struct{}{}
+	assert.NotContains(t, <-restored, `"transport_component":"dns_watcher"`)
+}
@@ -0,0 +1,142 @@
+package transport
+
+import (
+	"net/http"
+	"time"
+)
+
+const (
+	presetInternalRetryLimit   = 3
+	presetInternalRetryTimeout = 2 * time.Second
+	presetInternalBackoff      = 50 * time.Millisecond
+	presetExternalRetryLimit   = 2
+	presetExternalBackoff      = 250 * time.Millisecond
+	presetBackoffJitter        = .2
+	// presetExternalPaceBelow is the number of remaining requests in a
+	// quota under which the external API chain starts pacing.
+	presetExternalPaceBelow = 10
+)
+
+type presetConfig struct {
+	latency  *LatencyRecorder
+	breaker  *CircuitBreaker
+	throttle *QuotaThrottle
+}
+
+// PresetOption is a configuration for the preset chains.
+type PresetOption func(*presetConfig) *presetConfig
+
+// PresetOptionLatencyRecorder sets the LatencyRecorder that the internal
+// service chain records latencies in so that they can be read or shared with
+// other decorators. The default is a new recorder for each chain.
+func PresetOptionLatencyRecorder(recorder *LatencyRecorder) PresetOption {
+	return func(c *presetConfig) *presetConfig {
+		c.latency = recorder
+		return c
+	}
+}
+
+// PresetOptionCircuitBreaker sets the CircuitBreaker used by the external API
+// chain so that circuits can be inspected or shared by several clients. The
+// default is a new breaker with the default options for each chain.
+func PresetOptionCircuitBreaker(breaker *CircuitBreaker) PresetOption {
+	return func(c *presetConfig) *presetConfig {
+		c.breaker = breaker
+		return c
+	}
+}
+
+// PresetOptionQuotaThrottle sets the QuotaThrottle used by the external API
+// chain so that every client calling the same API shares its quota. The
+// default is a new throttle for each chain that only paces requests once
+// fewer than ten remain in a quota.
+func PresetOptionQuotaThrottle(throttle *QuotaThrottle) PresetOption {
+	return func(c *presetConfig) *presetConfig {
+		c.throttle = throttle
+		return c
+	}
+}
+
+func newPresetConfig(opts []PresetOption) *presetConfig {
+	var c = &presetConfig{}
+	for _, opt := range opts {
+		c = opt(c)
+	}
+	if c.latency == nil {
+		c.latency = NewLatencyRecorder()
+	}
+	if c.breaker == nil {
+		c.breaker = NewCircuitBreaker()
+	}
+	if c.throttle == nil {
+		c.throttle = NewQuotaThrottle(QuotaThrottleOptionPaceBelow(presetExternalPaceBelow))
+	}
+	return c
+}
+
+// NewInternalServiceChain generates a Chain suited to calling other services
+// within the same network. The chain emits access logs, records latencies in
+// a LatencyRecorder, and retries idempotent requests up to three times, with
+// a jittered 50ms delay, on any 5xx response or when an attempt takes longer
+// than two seconds.
+//
+// The returned Chain may be extended with additional decorators.
+func NewInternalServiceChain(opts ...PresetOption) Chain {
+	var config = newPresetConfig(opts)
+	return Chain{
+		NewAccessLog(),
+		NewLatencyRecording(config.latency),
+		NewRetrier(
+			NewPercentJitteredBackoffPolicy(NewFixedBackoffPolicy(presetInternalBackoff), presetBackoffJitter),
+			NewLimitedRetryPolicy(
+				presetInternalRetryLimit,
+				NewIdempotentRetryPolicy(
+					NewStatusCodeRetryPolicy(
+						http.StatusInternalServerError,
+						http.StatusBadGateway,
+						http.StatusServiceUnavailable,
+						http.StatusGatewayTimeout,
+					),
+					NewTimeoutRetryPolicy(presetInternalRetryTimeout),
+				),
+			),
+		),
+	}
+}
+
+// NewExternalAPIChain generates a Chain suited to calling third party APIs.
+// The chain emits access logs, injects the header from the given provider
+// into every request, honors 429 responses with Retry-After, and retries
+// idempotent requests up to two times with an exponential, jittered delay on
+// 502, 503, and 504 responses. Every attempt passes through a QuotaThrottle,
+// which sends requests without delay until fewer than ten remain in the rate
+// limit the API reports and then spreads the rest until the reset, and a
+// CircuitBreaker so that an API that is down fails fast. The provider may be
+// nil if no authentication header is needed.
+//
+// The returned Chain may be extended with additional decorators.
+func NewExternalAPIChain(auth HeaderProvider, opts ...PresetOption) Chain {
+	var config = newPresetConfig(opts)
+	var chain = Chain{NewAccessLog()}
+	if auth != nil {
+		chain = append(chain, NewHeader(auth))
+	}
+	return append(chain,
+		NewRetryAfter(),
+		NewRetrier(
+			NewPercentJitteredBackoffPolicy(NewExponentialBackoffPolicy(presetExternalBackoff), presetBackoffJitter),
+			NewLimitedRetryPolicy(
+				presetExternalRetryLimit,
+				NewIdempotentRetryPolicy(
+					NewStatusCodeRetryPolicy(
+						http.StatusBadGateway,
+						http.StatusServiceUnavailable,
+						http.StatusGatewayTimeout,
+					),
+				),
+			),
+		),
+		NewQuotaThrottling(config.throttle),
+		NewCircuitBreaking(config.breaker),
+	)
+}
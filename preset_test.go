@@ -0,0 +1,103 @@
+package transport
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/asecurityteam/logevent/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewInternalServiceChain(t *testing.T) {
+	var ctrl = gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var wrapped = NewMockRoundTripper(ctrl)
+	var recorder = NewLatencyRecorder()
+	var chain = NewInternalServiceChain(PresetOptionLatencyRecorder(recorder))
+	require.Len(t, chain, 3)
+
+	wrapped.EXPECT().RoundTrip(gomock.Any()).Return(&http.Response{StatusCode: http.StatusBadGateway, Body: http.NoBody}, nil)
+	wrapped.EXPECT().RoundTrip(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil)
+	var logger = NewMockLogger(ctrl)
+	logger.EXPECT().Info(gomock.Any()).AnyTimes()
+	var req, _ = http.NewRequest(http.MethodGet, "/", nil)
+	req = req.WithContext(logevent.NewContext(req.Context(), logger))
+	var resp, e = chain.Apply(wrapped).RoundTrip(req)
+	require.NoError(t, e)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int64(1), recorder.Snapshot(recorder.Key(req)).Count)
+}
+
+func TestPresetChainsDoNotRetryNonIdempotentRequests(t *testing.T) {
+	for name, chain := range map[string]Chain{
+		"internal": NewInternalServiceChain(),
+		"external": NewExternalAPIChain(nil),
+	} {
+		// The access log is left out because it needs a logger.
+		var attempts int
+		var rt = chain[1:].Apply(RoundTripperFunc(func(*http.Request) (*http.Response, error) {
+			attempts = attempts + 1
+			return &http.Response{StatusCode: http.StatusBadGateway, Body: http.NoBody}, nil
+		}))
+		var req, _ = http.NewRequest(http.MethodPost, "https://example.com/", nil)
+		var resp, e = rt.RoundTrip(req)
+		require.NoError(t, e, name)
+		assert.Equal(t, http.StatusBadGateway, resp.StatusCode, name)
+		assert.Equal(t, 1, attempts, name)
+
+		attempts = 0
+		req, _ = http.NewRequest(http.MethodPost, "https://example.com/", nil)
+		req.Header.Set("Idempotency-Key", "key")
+		_, _ = rt.RoundTrip(req)
+		assert.Greater(t, attempts, 1, name)
+	}
+}
+
+func TestNewExternalAPIChainCircuitBreaker(t *testing.T) {
+	var breaker = NewCircuitBreaker(CircuitBreakerOptionThreshold(1))
+	var attempts int
+	var rt = NewExternalAPIChain(nil, PresetOptionCircuitBreaker(breaker), PresetOptionQuotaThrottle(NewQuotaThrottle()))[1:].Apply(
+		RoundTripperFunc(func(*http.Request) (*http.Response, error) {
+			attempts = attempts + 1
+			return &http.Response{StatusCode: http.StatusInternalServerError, Body: http.NoBody}, nil
+		}),
+	)
+	var req, _ = http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	_, _ = rt.RoundTrip(req)
+	var _, e = rt.RoundTrip(req)
+	assert.Error(t, e, "open circuit did not fail fast")
+	assert.Equal(t, 1, attempts)
+}
+
+func TestNewExternalAPIChainPacesBelowThreshold(t *testing.T) {
+	var config = newPresetConfig(nil)
+	assert.Equal(t, presetExternalPaceBelow, config.throttle.paceBelow)
+}
+
+func TestNewExternalAPIChain(t *testing.T) {
+	var ctrl = gomock.NewController(t)
+	defer ctrl.Finish()
+
+	assert.Len(t, NewExternalAPIChain(nil), 5)
+
+	var wrapped = NewMockRoundTripper(ctrl)
+	var chain = NewExternalAPIChain(func(*http.Request) (string, string) {
+		return "Authorization", "Bearer token"
+	})
+	require.Len(t, chain, 6)
+
+	wrapped.EXPECT().RoundTrip(gomock.Any()).DoAndReturn(func(r *http.Request) (*http.Response, error) {
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	})
+	var logger = NewMockLogger(ctrl)
+	logger.EXPECT().Info(gomock.Any()).AnyTimes()
+	var req, _ = http.NewRequest(http.MethodGet, "/", nil)
+	req = req.WithContext(logevent.NewContext(req.Context(), logger))
+	var resp, e = chain.Apply(wrapped).RoundTrip(req)
+	require.NoError(t, e)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
@@ -0,0 +1,156 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const proberSource = "prober"
+
+// Prober periodically sends a lightweight request to each of a set of target
+// URLs through a RoundTripper. Sending the probes through the same chain as
+// regular traffic feeds decorators that react to upstream health, such as
+// NewHealthTracking, NewCircuitBreaking, and a Rotator with outlier
+// detection, so that failures are detected during idle periods rather than by
+// the first requests that follow them.
+type Prober struct {
+	wrapped  http.RoundTripper
+	targets  []string
+	method   string
+	interval time.Duration
+	timeout  time.Duration
+	clock    Clock
+}
+
+// ProberOption is a configuration for the Prober.
+type ProberOption func(*Prober) *Prober
+
+// ProberOptionInterval configures how often every target is probed. The
+// default is 10 seconds.
+func ProberOptionInterval(interval time.Duration) ProberOption {
+	return func(p *Prober) *Prober {
+		p.interval = interval
+		return p
+	}
+}
+
+// ProberOptionMethod sets the HTTP method of the probes, such as OPTIONS for
+// upstreams that do not support HEAD. The default is HEAD.
+func ProberOptionMethod(method string) ProberOption {
+	return func(p *Prober) *Prober {
+		p.method = method
+		return p
+	}
+}
+
+// ProberOptionTimeout limits the time each probe may take. The default is 5
+// seconds.
+func ProberOptionTimeout(timeout time.Duration) ProberOption {
+	return func(p *Prober) *Prober {
+		p.timeout = timeout
+		return p
+	}
+}
+
+// ProberOptionClock configures the Clock used to schedule probes.
+func ProberOptionClock(clock Clock) ProberOption {
+	return func(p *Prober) *Prober {
+		p.clock = clock
+		return p
+	}
+}
+
+// NewProber creates a Prober that sends probes for the target URLs through
+// the RoundTripper. The prober does nothing until Run is called.
+func NewProber(wrapped http.RoundTripper, targets []string, opts ...ProberOption) *Prober {
+	var p = &Prober{
+		wrapped:  wrapped,
+		targets:  targets,
+		method:   http.MethodHead,
+		interval: 10 * time.Second,
+		timeout:  5 * time.Second,
+		clock:    NewSystemClock(),
+	}
+	for _, opt := range opts {
+		p = opt(p)
+	}
+	return p
+}
+
+// Check probes every target once, concurrently. Probes skip retries and
+// logging so that each failure is observed as it happened. The returned error
+// joins the failure of every probe that failed to produce a response or
+// received a 5xx status code.
+func (p *Prober) Check(ctx context.Context) error {
+	ctx = SkipLogging(SkipRetry(ctx))
+	var lock sync.Mutex
+	var errs []error
+	var wg sync.WaitGroup
+	for _, target := range p.targets {
+		wg.Add(1)
+		var host string
+		if u, e := url.Parse(target); e == nil {
+			host = u.Host
+		}
+		goLabeled(ctx, proberSource, host, func(ctx context.Context) {
+			defer wg.Done()
+			var e = p.probe(ctx, target)
+			if e == nil {
+				return
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			errs = append(errs, fmt.Errorf("transport: probe of %s: %w", target, e))
+		})
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+func (p *Prober) probe(ctx context.Context, target string) error {
+	var cancel = func() {}
+	if p.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+	}
+	defer cancel()
+	var req, e = http.NewRequestWithContext(ctx, p.method, target, nil)
+	if e != nil {
+		return e
+	}
+	var resp *http.Response
+	resp, e = p.wrapped.RoundTrip(req)
+	if e != nil {
+		return e
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("unhealthy status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Run probes the targets on the configured interval until the context is
+// cancelled. Probe failures are ignored here because they have already been
+// observed by the decorators in the chain.
+func (p *Prober) Run(ctx context.Context) {
+	defer labelGoroutine(ctx, proberSource, "")()
+	_ = p.Check(ctx)
+	var timer = p.clock.NewTimer(p.interval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C():
+			_ = p.Check(ctx)
+			timer.Reset(p.interval)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProberCheckFeedsTracker(t *testing.T) {
+	var tracker = NewHealthTracker()
+	var rt = NewHealthTracking(tracker)(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		assert.Equal(t, http.MethodOptions, r.Method)
+		assert.True(t, RetrySkipped(r.Context()))
+		switch r.URL.Host {
+		case "down.example.com":
+			return nil, errors.New("connection refused")
+		case "broken.example.com":
+			return &http.Response{StatusCode: http.StatusBadGateway, Body: http.NoBody}, nil
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	}))
+	var prober = NewProber(rt, []string{
+		"https://up.example.com/health",
+		"https://down.example.com/health",
+		"https://broken.example.com/health",
+	}, ProberOptionMethod(http.MethodOptions))
+
+	var e = prober.Check(context.Background())
+	require.Error(t, e)
+	assert.Contains(t, e.Error(), "down.example.com")
+	assert.Contains(t, e.Error(), "broken.example.com")
+	assert.NotContains(t, e.Error(), "up.example.com")
+	assert.Equal(t, 0, tracker.Stats("up.example.com").Failures)
+	assert.Equal(t, 1, tracker.Stats("down.example.com").Failures)
+	assert.Equal(t, 1, tracker.Stats("broken.example.com").Failures)
+}
+
+func TestProberRun(t *testing.T) {
+	var ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	var calls atomic.Int32
+	var clock = newFakeClock()
+	var prober = NewProber(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		if calls.Add(1) == 3 {
+			cancel()
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	}), []string{"https://example.com/"}, ProberOptionClock(clock), ProberOptionInterval(time.Minute))
+	prober.Run(ctx)
+	assert.GreaterOrEqual(t, calls.Load(), int32(3))
+	assert.Equal(t, time.Minute, clock.recorded()[0])
+}
@@ -0,0 +1,205 @@
+package transport
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ProgressDirection identifies whether a TransferProgress describes the
+// request or the response body.
+type ProgressDirection string
+
+const (
+	// ProgressUpload describes the request body being sent.
+	ProgressUpload ProgressDirection = "upload"
+	// ProgressDownload describes the response body being read.
+	ProgressDownload ProgressDirection = "download"
+)
+
+// TransferProgress describes how much of a body has been transferred.
+type TransferProgress struct {
+	Direction ProgressDirection
+	// Request the body belongs to.
+	Request *http.Request
+	// Bytes transferred so far.
+	Bytes int64
+	// Total length of the body, or -1 if it is not known.
+	Total int64
+	// Percent of the body transferred, or -1 if the total is not known.
+	Percent float64
+	// Rate is the throughput, in bytes per second, since the previous report.
+	Rate float64
+	// Done is set on the final report for the body, which is made when the
+	// body is read to the end, fails, or is closed.
+	Done bool
+	// Err is the error that ended the transfer, if any.
+	Err error
+}
+
+// Progress is a decorator that reports the progress of request and response
+// bodies as they are transferred.
+type Progress struct {
+	wrapped  http.RoundTripper
+	callback func(TransferProgress)
+	interval time.Duration
+	clock    Clock
+}
+
+// ProgressOption is a configuration for the Progress decorator.
+type ProgressOption func(*Progress) *Progress
+
+// ProgressOptionInterval sets the minimum time between reports for a body.
+// The final report is always made. The default is 100ms.
+func ProgressOptionInterval(interval time.Duration) ProgressOption {
+	return func(p *Progress) *Progress {
+		p.interval = interval
+		return p
+	}
+}
+
+// ProgressOptionClock configures the Clock used to time reports and measure
+// throughput.
+func ProgressOptionClock(clock Clock) ProgressOption {
+	return func(p *Progress) *Progress {
+		p.clock = clock
+		return p
+	}
+}
+
+// ProgressChannel returns a callback for NewProgress that sends reports to
+// the channel. Reports are dropped, except for final ones, when the channel
+// is full so that a slow reader never stalls a transfer.
+func ProgressChannel(progress chan<- TransferProgress) func(TransferProgress) {
+	return func(p TransferProgress) {
+		if p.Done {
+			progress <- p
+			return
+		}
+		select {
+		case progress <- p:
+		default:
+		}
+	}
+}
+
+// RoundTrip wraps the request and response bodies so that reading them
+// reports progress.
+func (c *Progress) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.Body != nil && r.Body != http.NoBody {
+		var original = r
+		var total = original.ContentLength
+		if total == 0 {
+			// A request with a body and no length has an unknown length.
+			total = -1
+		}
+		r = r.Clone(r.Context())
+		r.Body = c.track(ProgressUpload, original, original.Body, total)
+		if original.GetBody != nil {
+			r.GetBody = func() (io.ReadCloser, error) {
+				var body, e = original.GetBody()
+				if e != nil {
+					return nil, e
+				}
+				return c.track(ProgressUpload, original, body, total), nil
+			}
+		}
+	}
+	var resp, e = c.wrapped.RoundTrip(r)
+	if e != nil || resp.Body == nil || resp.Body == http.NoBody {
+		return resp, e
+	}
+	return withBody(resp, c.track(ProgressDownload, r, resp.Body, resp.ContentLength)), nil
+}
+
+func (c *Progress) track(direction ProgressDirection, r *http.Request, body io.ReadCloser, total int64) *progressBody {
+	var now = c.clock.Now()
+	return &progressBody{
+		body:     body,
+		progress: c,
+		report:   TransferProgress{Direction: direction, Request: r, Total: total},
+		last:     now,
+	}
+}
+
+// progressBody counts the bytes read from a body and reports them.
+type progressBody struct {
+	body     io.ReadCloser
+	progress *Progress
+	lock     sync.Mutex
+	report   TransferProgress
+	last     time.Time
+	lastSent int64
+}
+
+func (b *progressBody) Read(p []byte) (int, error) {
+	var n, e = b.body.Read(p)
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.report.Bytes = b.report.Bytes + int64(n)
+	switch {
+	case e == io.EOF:
+		b.send(nil)
+	case e != nil:
+		b.send(e)
+	case b.progress.clock.Now().Sub(b.last) >= b.progress.interval:
+		b.emit(b.progress.clock.Now())
+	}
+	return n, e
+}
+
+func (b *progressBody) Close() error {
+	var e = b.body.Close()
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.send(nil)
+	return e
+}
+
+// send makes the final report. It must be called with the lock held.
+func (b *progressBody) send(e error) {
+	if b.report.Done {
+		return
+	}
+	b.report.Done = true
+	b.report.Err = e
+	b.emit(b.progress.clock.Now())
+}
+
+// emit must be called with the lock held.
+func (b *progressBody) emit(now time.Time) {
+	var report = b.report
+	report.Percent = -1
+	if report.Total > 0 {
+		report.Percent = float64(report.Bytes) * 100 / float64(report.Total)
+	} else if report.Total == 0 {
+		report.Percent = 100
+	}
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		report.Rate = float64(report.Bytes-b.lastSent) / elapsed.Seconds()
+	}
+	b.last = now
+	b.lastSent = report.Bytes
+	b.progress.callback(report)
+}
+
+// NewProgress configures a RoundTripper decorator that calls the callback
+// with the progress of every request and response body as it is read. The
+// callback is called on the goroutine reading the body so it must not
+// block. Installing the decorator inside a retry decorator reports the
+// bodies of each attempt.
+func NewProgress(callback func(TransferProgress), opts ...ProgressOption) func(http.RoundTripper) http.RoundTripper {
+	return func(wrapped http.RoundTripper) http.RoundTripper {
+		var p = &Progress{
+			wrapped:  wrapped,
+			callback: callback,
+			interval: 100 * time.Millisecond,
+			clock:    NewSystemClock(),
+		}
+		for _, opt := range opts {
+			p = opt(p)
+		}
+		return p
+	}
+}
@@ -0,0 +1,103 @@
+package transport
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSlowReader returns a reader of the content that reads chunk bytes at a
+// time and advances the clock by a second before each read.
+func newSlowReader(clock *fakeClock, content string, chunk int) io.ReadCloser {
+	var reader = strings.NewReader(content)
+	return io.NopCloser(readerFunc(func(p []byte) (int, error) {
+		clock.advance(time.Second)
+		if len(p) > chunk {
+			p = p[:chunk]
+		}
+		return reader.Read(p)
+	}))
+}
+
+func TestProgressDownload(t *testing.T) {
+	var clock = newFakeClock()
+	var reports []TransferProgress
+	var rt = NewProgress(func(p TransferProgress) {
+		reports = append(reports, p)
+	}, ProgressOptionClock(clock), ProgressOptionInterval(2*time.Second))(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: newSlowReader(clock, "0123456789", 2), ContentLength: 10}, nil
+	}))
+	var req, _ = http.NewRequest(http.MethodGet, "https://example.com/file", nil)
+	var resp, e = rt.RoundTrip(req)
+	require.NoError(t, e)
+	var content, _ = io.ReadAll(resp.Body)
+	assert.Equal(t, "0123456789", string(content))
+	require.NoError(t, resp.Body.Close())
+
+	require.Len(t, reports, 3)
+	for _, report := range reports {
+		assert.Equal(t, ProgressDownload, report.Direction)
+		assert.Equal(t, int64(10), report.Total)
+		assert.Equal(t, req, report.Request)
+	}
+	assert.Equal(t, int64(4), reports[0].Bytes)
+	assert.Equal(t, 40.0, reports[0].Percent)
+	assert.Equal(t, 2.0, reports[0].Rate)
+	assert.False(t, reports[0].Done)
+	assert.Equal(t, int64(8), reports[1].Bytes)
+	assert.Equal(t, int64(10), reports[2].Bytes)
+	assert.Equal(t, 100.0, reports[2].Percent)
+	assert.True(t, reports[2].Done, "closing after EOF reported twice")
+	assert.NoError(t, reports[2].Err)
+}
+
+func TestProgressUpload(t *testing.T) {
+	var clock = newFakeClock()
+	var reports []TransferProgress
+	var failure = errors.New("reset")
+	var rt = NewProgress(func(p TransferProgress) {
+		reports = append(reports, p)
+	}, ProgressOptionClock(clock))(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		var _, e = io.ReadAll(r.Body)
+		require.NoError(t, e)
+		var body io.ReadCloser
+		if body, e = r.GetBody(); e != nil {
+			return nil, e
+		}
+		_, _ = io.ReadAll(body)
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(readerFunc(func([]byte) (int, error) {
+			return 0, failure
+		})), ContentLength: -1}, nil
+	}))
+	var req, _ = http.NewRequest(http.MethodPut, "https://example.com/file", strings.NewReader("content"))
+	var resp, e = rt.RoundTrip(req)
+	require.NoError(t, e)
+	_, e = io.ReadAll(resp.Body)
+	assert.ErrorIs(t, e, failure)
+
+	require.Len(t, reports, 3)
+	assert.Equal(t, ProgressUpload, reports[0].Direction)
+	assert.Equal(t, int64(7), reports[0].Bytes)
+	assert.Equal(t, 100.0, reports[0].Percent)
+	assert.True(t, reports[0].Done)
+	assert.Equal(t, int64(7), reports[1].Bytes, "body from GetBody was not tracked")
+	assert.Equal(t, ProgressDownload, reports[2].Direction)
+	assert.Equal(t, -1.0, reports[2].Percent)
+	assert.ErrorIs(t, reports[2].Err, failure)
+}
+
+func TestProgressChannel(t *testing.T) {
+	var progress = make(chan TransferProgress, 1)
+	var send = ProgressChannel(progress)
+	send(TransferProgress{Bytes: 1})
+	send(TransferProgress{Bytes: 2})
+	assert.Equal(t, int64(1), (<-progress).Bytes, "full channel blocked the transfer")
+	send(TransferProgress{Bytes: 3, Done: true})
+	assert.Equal(t, int64(3), (<-progress).Bytes)
+}
@@ -0,0 +1,341 @@
+package transport
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrQuotaExhausted is returned by the QuotaThrottle when the upstream quota
+// for a request is used up and the reset is further away than the configured
+// maximum wait.
+var ErrQuotaExhausted = errors.New("transport: upstream rate limit quota exhausted")
+
+// QuotaState is the most recent view of an upstream rate limit quota.
+type QuotaState struct {
+	// Limit is the size of the quota, or zero if the upstream does not report
+	// it.
+	Limit int
+	// Remaining is the number of requests left before the reset. It is
+	// reduced locally for each request sent after the last response.
+	Remaining int
+	// Reset is the time at which the quota is replenished.
+	Reset time.Time
+	// Updated is the time of the response the state was parsed from.
+	Updated time.Time
+}
+
+type quotaEntry struct {
+	state QuotaState
+	// next is the earliest time the next paced request may be sent.
+	next time.Time
+}
+
+// QuotaThrottle parses rate limit headers from responses and paces later
+// requests so that they stay under the upstream quota rather than running
+// into it. Requests are spread evenly over the time remaining until the
+// reset, or only once few requests remain when QuotaThrottleOptionPaceBelow
+// is set, and wait for the reset once no requests remain. Quotas are tracked
+// per key, which defaults to the host and credentials of the request. It is
+// safe for concurrent use so that a single throttle can be shared by every
+// client calling the same upstream.
+type QuotaThrottle struct {
+	lock    sync.Mutex
+	quotas  map[string]*quotaEntry
+	key     func(*http.Request) string
+	clock   Clock
+	maxWait time.Duration
+	// paceBelow is the number of remaining requests under which requests
+	// are paced. Zero paces every request.
+	paceBelow int
+	shared    SharedState
+}
+
+// QuotaThrottleOption is a configuration for the QuotaThrottle.
+type QuotaThrottleOption func(*QuotaThrottle) *QuotaThrottle
+
+// QuotaThrottleOptionKey sets the function that selects the quota a request
+// counts against. The default is the host of the request URL combined with a
+// hash of its Authorization header, if any, because most APIs apply quotas
+// per token.
+func QuotaThrottleOptionKey(key func(*http.Request) string) QuotaThrottleOption {
+	return func(t *QuotaThrottle) *QuotaThrottle {
+		t.key = key
+		return t
+	}
+}
+
+// QuotaThrottleOptionMaxWait limits how long a request is held for its quota.
+// Requests that would wait longer fail with ErrQuotaExhausted. The default is
+// no limit, leaving the request context to bound the wait.
+func QuotaThrottleOptionMaxWait(max time.Duration) QuotaThrottleOption {
+	return func(t *QuotaThrottle) *QuotaThrottle {
+		t.maxWait = max
+		return t
+	}
+}
+
+// QuotaThrottleOptionPaceBelow sends requests without delay while the quota
+// has at least the given number of requests remaining and only spreads the
+// rest over the time left until the reset. This keeps a quota that is far
+// from used up from adding latency to every request. The default, zero, paces
+// every request.
+func QuotaThrottleOptionPaceBelow(remaining int) QuotaThrottleOption {
+	return func(t *QuotaThrottle) *QuotaThrottle {
+		t.paceBelow = remaining
+		return t
+	}
+}
+
+// QuotaThrottleOptionSharedState configures the throttle to count the
+// requests sent by every replica of a service against quotas that report
+// their limit. Once the fleet has sent the limit within a window, requests
+// wait for the reset even if this replica has not seen a response reporting
+// that the quota is used up. Failures to reach the SharedState are ignored so
+// that the throttle falls back to local pacing.
+func QuotaThrottleOptionSharedState(state SharedState) QuotaThrottleOption {
+	return func(t *QuotaThrottle) *QuotaThrottle {
+		t.shared = state
+		return t
+	}
+}
+
+// QuotaThrottleOptionClock configures the Clock used to pace requests.
+func QuotaThrottleOptionClock(clock Clock) QuotaThrottleOption {
+	return func(t *QuotaThrottle) *QuotaThrottle {
+		t.clock = clock
+		return t
+	}
+}
+
+func hostTokenKey(r *http.Request) string {
+	var authorization = r.Header.Get("Authorization")
+	if authorization == "" {
+		return r.URL.Host
+	}
+	var sum = sha256.Sum256([]byte(authorization))
+	return r.URL.Host + "#" + hex.EncodeToString(sum[:4])
+}
+
+// NewQuotaThrottle creates a QuotaThrottle with no known quotas.
+func NewQuotaThrottle(opts ...QuotaThrottleOption) *QuotaThrottle {
+	var t = &QuotaThrottle{
+		quotas: make(map[string]*quotaEntry),
+		key:    hostTokenKey,
+		clock:  NewSystemClock(),
+	}
+	for _, opt := range opts {
+		t = opt(t)
+	}
+	return t
+}
+
+// Reconfigure applies the options to a throttle that is in use while keeping
+// the quotas it has learned. Only the maximum wait and pace below options take
+// effect; any others are ignored.
+func (t *QuotaThrottle) Reconfigure(opts ...QuotaThrottleOption) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	var updated = &QuotaThrottle{maxWait: t.maxWait, paceBelow: t.paceBelow}
+	for _, opt := range opts {
+		updated = opt(updated)
+	}
+	t.maxWait = updated.maxWait
+	t.paceBelow = updated.paceBelow
+}
+
+// Key returns the key of the quota that the request counts against.
+func (t *QuotaThrottle) Key(r *http.Request) string {
+	return t.key(r)
+}
+
+// Observe updates the quota of the request from the rate limit headers of the
+// response. Responses without rate limit headers are ignored.
+func (t *QuotaThrottle) Observe(r *http.Request, resp *http.Response) {
+	if resp == nil {
+		return
+	}
+	var now = t.clock.Now()
+	var state, ok = parseQuotaState(resp.Header, now)
+	if !ok {
+		return
+	}
+	var key = t.key(r)
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	var entry, found = t.quotas[key]
+	if !found {
+		entry = &quotaEntry{}
+		t.quotas[key] = entry
+	}
+	entry.state = state
+}
+
+func parseQuotaState(header http.Header, now time.Time) (QuotaState, bool) {
+	for _, names := range rateLimitHeaders {
+		var remaining, e = strconv.Atoi(strings.TrimSpace(header.Get(names.remaining)))
+		if e != nil {
+			continue
+		}
+		var wait, ok = parseRateLimitReset(header.Get(names.reset), now)
+		if !ok {
+			continue
+		}
+		var limit, _ = strconv.Atoi(strings.TrimSpace(header.Get(names.limit)))
+		return QuotaState{Limit: limit, Remaining: remaining, Reset: now.Add(wait), Updated: now}, true
+	}
+	return QuotaState{}, false
+}
+
+// Quota returns the current state of the quota for the key.
+func (t *QuotaThrottle) Quota(key string) (QuotaState, bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if entry, ok := t.quotas[key]; ok {
+		return entry.state, true
+	}
+	return QuotaState{}, false
+}
+
+// Quotas returns the current state of every known quota.
+func (t *QuotaThrottle) Quotas() map[string]QuotaState {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	var result = make(map[string]QuotaState, len(t.quotas))
+	for k, entry := range t.quotas {
+		result[k] = entry.state
+	}
+	return result
+}
+
+// Keys returns the key of every known quota in sorted order.
+func (t *QuotaThrottle) Keys() []string {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	var keys = make([]string, 0, len(t.quotas))
+	for k := range t.quotas {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// reserve claims the next paced slot for the key and returns how long to wait
+// for it. No slot is claimed if the wait would exceed the maximum wait.
+func (t *QuotaThrottle) reserve(key string) (time.Duration, bool) {
+	var now = t.clock.Now()
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	var entry, ok = t.quotas[key]
+	if !ok || !entry.state.Reset.After(now) {
+		// The quota is unknown or its window has passed.
+		return 0, true
+	}
+	if entry.state.Remaining <= 0 {
+		var delay = entry.state.Reset.Sub(now)
+		return delay, t.maxWait <= 0 || delay <= t.maxWait
+	}
+	if t.paceBelow > 0 && entry.state.Remaining >= t.paceBelow {
+		entry.state.Remaining = entry.state.Remaining - 1
+		return 0, true
+	}
+	var slot = now
+	if entry.next.After(slot) {
+		slot = entry.next
+	}
+	var delay = slot.Sub(now)
+	if t.maxWait > 0 && delay > t.maxWait {
+		return delay, false
+	}
+	// The requests that remain are spread over the rest of the window from
+	// this slot onwards.
+	var window = entry.state.Reset.Sub(slot)
+	entry.next = slot.Add(window / time.Duration(entry.state.Remaining))
+	entry.state.Remaining = entry.state.Remaining - 1
+	return delay, true
+}
+
+// reserveShared counts the request against the fleet wide window of the
+// quota and returns how long to wait if the fleet has used it up.
+func (t *QuotaThrottle) reserveShared(ctx context.Context, key string) (time.Duration, bool) {
+	var state, ok = t.Quota(key)
+	var now = t.clock.Now()
+	if !ok || state.Limit <= 0 || !state.Reset.After(now) {
+		return 0, true
+	}
+	var window = state.Reset.Sub(now)
+	t.lock.Lock()
+	var maxWait = t.maxWait
+	t.lock.Unlock()
+	var counter = "quota:" + key + ":" + strconv.FormatInt(state.Reset.Unix(), 10)
+	var sent, e = t.shared.Increment(ctx, counter, 1, window)
+	if e != nil || sent <= int64(state.Limit) {
+		return 0, true
+	}
+	return window, maxWait <= 0 || window <= maxWait
+}
+
+// Wait blocks until the request may be sent under its quota. It returns
+// ErrQuotaExhausted if the wait would exceed the maximum wait, or the context
+// error if the context ends first.
+func (t *QuotaThrottle) Wait(ctx context.Context, r *http.Request) error {
+	var key = t.key(r)
+	var delay, ok = t.reserve(key)
+	if ok && t.shared != nil {
+		var shared time.Duration
+		shared, ok = t.reserveShared(ctx, key)
+		if shared > delay {
+			delay = shared
+		}
+	}
+	if !ok {
+		return fmt.Errorf("%w: next request allowed in %s", ErrQuotaExhausted, delay)
+	}
+	if delay <= 0 {
+		return nil
+	}
+	var timer = t.clock.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C():
+		return nil
+	}
+}
+
+type quotaTransport struct {
+	wrapped  http.RoundTripper
+	throttle *QuotaThrottle
+}
+
+// RoundTrip paces the request under its quota and records the quota reported
+// by the response. The body of a request that is not sent is closed.
+func (c *quotaTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if e := c.throttle.Wait(r.Context(), r); e != nil {
+		if r.Body != nil {
+			_ = r.Body.Close()
+		}
+		return nil, e
+	}
+	var resp, e = c.wrapped.RoundTrip(r)
+	c.throttle.Observe(r, resp)
+	return resp, e
+}
+
+// NewQuotaThrottling configures a RoundTripper decorator that paces requests
+// using the throttle. It should be installed inside any retry decorators so
+// that every attempt is paced.
+func NewQuotaThrottling(throttle *QuotaThrottle) func(http.RoundTripper) http.RoundTripper {
+	return func(wrapped http.RoundTripper) http.RoundTripper {
+		return &quotaTransport{wrapped: wrapped, throttle: throttle}
+	}
+}
@@ -0,0 +1,135 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func quotaResponse(remaining int, reset time.Duration) *http.Response {
+	return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Header: http.Header{
+		"X-Ratelimit-Limit":     []string{"100"},
+		"X-Ratelimit-Remaining": []string{strconv.Itoa(remaining)},
+		"X-Ratelimit-Reset":     []string{strconv.Itoa(int(reset.Seconds()))},
+	}}
+}
+
+func TestQuotaThrottleKey(t *testing.T) {
+	var throttle = NewQuotaThrottle()
+	var anonymous, _ = http.NewRequest(http.MethodGet, "https://api.example.com/", nil)
+	var first, _ = http.NewRequest(http.MethodGet, "https://api.example.com/", nil)
+	first.Header.Set("Authorization", "Bearer first")
+	var second, _ = http.NewRequest(http.MethodGet, "https://api.example.com/", nil)
+	second.Header.Set("Authorization", "Bearer second")
+	assert.Equal(t, "api.example.com", throttle.Key(anonymous))
+	assert.NotEqual(t, throttle.Key(first), throttle.Key(second))
+	assert.NotContains(t, throttle.Key(first), "first", "key exposed the credentials")
+}
+
+func TestQuotaThrottlePacing(t *testing.T) {
+	var clock = newFakeClock()
+	var throttle = NewQuotaThrottle(QuotaThrottleOptionClock(clock))
+	var req, _ = http.NewRequest(http.MethodGet, "https://api.example.com/", nil)
+	var ctx = context.Background()
+
+	require.NoError(t, throttle.Wait(ctx, req))
+	assert.Empty(t, clock.recorded(), "waited without a known quota")
+
+	throttle.Observe(req, quotaResponse(4, 8*time.Second))
+	var state, ok = throttle.Quota("api.example.com")
+	require.True(t, ok)
+	assert.Equal(t, 100, state.Limit)
+	assert.Equal(t, 4, state.Remaining)
+	assert.Equal(t, clock.Now().Add(8*time.Second), state.Reset)
+
+	// Four requests remain over eight seconds so they are sent two seconds
+	// apart. The fake clock advances by each wait.
+	require.NoError(t, throttle.Wait(ctx, req))
+	require.NoError(t, throttle.Wait(ctx, req))
+	require.NoError(t, throttle.Wait(ctx, req))
+	assert.Equal(t, []time.Duration{2 * time.Second, 2 * time.Second}, clock.recorded())
+	assert.Equal(t, 1, throttle.Quotas()["api.example.com"].Remaining)
+	assert.Equal(t, []string{"api.example.com"}, throttle.Keys())
+}
+
+func TestQuotaThrottlePaceBelow(t *testing.T) {
+	var clock = newFakeClock()
+	var throttle = NewQuotaThrottle(QuotaThrottleOptionClock(clock), QuotaThrottleOptionPaceBelow(3))
+	var req, _ = http.NewRequest(http.MethodGet, "https://api.example.com/", nil)
+	var ctx = context.Background()
+
+	// Requests are sent without delay while at least three remain, and the
+	// last two are spread over the rest of the window.
+	throttle.Observe(req, quotaResponse(6, 8*time.Second))
+	for x := 0; x < 4; x = x + 1 {
+		require.NoError(t, throttle.Wait(ctx, req))
+	}
+	assert.Empty(t, clock.recorded(), "paced a quota that was far from used up")
+	assert.Equal(t, 2, throttle.Quotas()["api.example.com"].Remaining)
+	require.NoError(t, throttle.Wait(ctx, req))
+	require.NoError(t, throttle.Wait(ctx, req))
+	assert.Equal(t, []time.Duration{4 * time.Second}, clock.recorded())
+
+	// Without the threshold every request is paced again.
+	throttle.Reconfigure(QuotaThrottleOptionPaceBelow(0))
+	clock.advance(10 * time.Second)
+	throttle.Observe(req, quotaResponse(100, 100*time.Second))
+	require.NoError(t, throttle.Wait(ctx, req))
+	require.NoError(t, throttle.Wait(ctx, req))
+	assert.Equal(t, []time.Duration{4 * time.Second, 10 * time.Second, time.Second}, clock.recorded())
+}
+
+func TestQuotaThrottleExhausted(t *testing.T) {
+	var clock = newFakeClock()
+	var throttle = NewQuotaThrottle(QuotaThrottleOptionClock(clock), QuotaThrottleOptionMaxWait(time.Minute))
+	var req, _ = http.NewRequest(http.MethodGet, "https://api.example.com/", nil)
+
+	throttle.Observe(req, quotaResponse(0, 30*time.Second))
+	require.NoError(t, throttle.Wait(context.Background(), req))
+	assert.Equal(t, []time.Duration{30 * time.Second}, clock.recorded())
+
+	throttle.Observe(req, quotaResponse(0, time.Hour))
+	var e = throttle.Wait(context.Background(), req)
+	assert.True(t, errors.Is(e, ErrQuotaExhausted))
+	assert.Equal(t, http.StatusTooManyRequests, ErrorToStatusCode(e))
+}
+
+func TestQuotaThrottling(t *testing.T) {
+	var clock = newFakeClock()
+	var throttle = NewQuotaThrottle(QuotaThrottleOptionClock(clock))
+	var calls int
+	var rt = NewQuotaThrottling(throttle)(RoundTripperFunc(func(*http.Request) (*http.Response, error) {
+		calls = calls + 1
+		return quotaResponse(0, 10*time.Second), nil
+	}))
+	var req, _ = http.NewRequest(http.MethodGet, "https://api.example.com/", nil)
+	var _, e = rt.RoundTrip(req)
+	require.NoError(t, e)
+	_, e = rt.RoundTrip(req)
+	require.NoError(t, e)
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, []time.Duration{10 * time.Second}, clock.recorded())
+
+}
+
+func TestQuotaThrottlingClosesRejectedBody(t *testing.T) {
+	var clock = newFakeClock()
+	var throttle = NewQuotaThrottle(QuotaThrottleOptionClock(clock), QuotaThrottleOptionMaxWait(time.Minute))
+	var rt = NewQuotaThrottling(throttle)(RoundTripperFunc(func(*http.Request) (*http.Response, error) {
+		t.Fatal("request was sent without quota")
+		return nil, nil
+	}))
+	var body = &closeRecordingBody{Reader: strings.NewReader("payload")}
+	var req, _ = http.NewRequest(http.MethodPost, "https://api.example.com/", body)
+	throttle.Observe(req, quotaResponse(0, time.Hour))
+	var _, e = rt.RoundTrip(req)
+	assert.True(t, errors.Is(e, ErrQuotaExhausted))
+	assert.True(t, body.closed, "rejected request body was not closed")
+}
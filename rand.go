@@ -0,0 +1,17 @@
+package transport
+
+import (
+	"math/rand"
+	"sync"
+)
+
+// lockedRandom adapts a *rand.Rand for concurrent use. Sources created with
+// rand.New are not safe to share between goroutines.
+func lockedRandom(source *rand.Rand) func() float64 {
+	var lock = &sync.Mutex{}
+	return func() float64 {
+		lock.Lock()
+		defer lock.Unlock()
+		return source.Float64()
+	}
+}
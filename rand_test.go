@@ -0,0 +1,63 @@
+package transport
+
+import (
+	"math/rand"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockedRandomConcurrent(t *testing.T) {
+	var random = lockedRandom(rand.New(rand.NewSource(1)))
+	var wg sync.WaitGroup
+	for x := 0; x < 10; x = x + 1 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for y := 0; y < 100; y = y + 1 {
+				var v = random()
+				assert.True(t, v >= 0 && v < 1)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestJitterOptionRandSourceReproducible(t *testing.T) {
+	var backoffs = func(seed int64) []time.Duration {
+		var policy = NewPercentJitteredBackoffPolicy(
+			NewFixedBackoffPolicy(time.Second), .5,
+			JitterOptionRandSource(rand.New(rand.NewSource(seed))),
+		)
+		var backoffer = policy()
+		var results = make([]time.Duration, 0, 5)
+		for x := 0; x < 5; x = x + 1 {
+			results = append(results, backoffer.Backoff(nil, nil, nil))
+		}
+		return results
+	}
+	assert.Equal(t, backoffs(42), backoffs(42))
+	assert.NotEqual(t, backoffs(42), backoffs(43))
+}
+
+func TestRecycleOptionRandSourceReproducible(t *testing.T) {
+	var factory = func() http.RoundTripper {
+		return http.DefaultTransport
+	}
+	var ttl = func(seed int64) time.Time {
+		var r = NewRecycler(
+			factory,
+			RecycleOptionTTL(time.Minute),
+			RecycleOptionTTLJitter(time.Minute),
+			RecycleOptionClock(newFakeClock()),
+			RecycleOptionRandSource(rand.New(rand.NewSource(seed))),
+		)
+		_ = r.getTransport()
+		return r.current.Load().nextTTL
+	}
+	assert.Equal(t, ttl(7), ttl(7))
+	assert.NotEqual(t, ttl(7), ttl(8))
+}
@@ -0,0 +1,134 @@
+package transport
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rateLimitHeaderSet names the headers used by one rate limit convention.
+type rateLimitHeaderSet struct {
+	limit     string
+	remaining string
+	reset     string
+}
+
+// rateLimitHeaders are the rate limit conventions recognized when parsing
+// responses, in order of preference. They cover the IETF RateLimit draft,
+// GitHub and most SaaS APIs, and Twitter.
+var rateLimitHeaders = []rateLimitHeaderSet{
+	{limit: "RateLimit-Limit", remaining: "RateLimit-Remaining", reset: "RateLimit-Reset"},
+	{limit: "X-RateLimit-Limit", remaining: "X-RateLimit-Remaining", reset: "X-RateLimit-Reset"},
+	{limit: "X-Rate-Limit-Limit", remaining: "X-Rate-Limit-Remaining", reset: "X-Rate-Limit-Reset"},
+}
+
+// epochThreshold separates reset values given as a number of seconds from now
+// from those given as a Unix timestamp. No API uses a window anywhere near
+// this long so any larger value is treated as a timestamp.
+const epochThreshold = 1000000000
+
+// RateLimitBackoffer waits until the rate limit window resets when a response
+// reports that no requests remain, and uses a wrapped Backoffer otherwise.
+type RateLimitBackoffer struct {
+	wrapped Backoffer
+	clock   Clock
+	maxWait time.Duration
+}
+
+// RateLimitOption is a configuration for the RateLimitBackoffer.
+type RateLimitOption func(*RateLimitBackoffer) *RateLimitBackoffer
+
+// RateLimitOptionMaxWait limits how long the RateLimitBackoffer waits for a
+// reset. Responses with a reset further away return BackoffStop rather than
+// holding the request for the whole window. The default is no limit.
+func RateLimitOptionMaxWait(max time.Duration) RateLimitOption {
+	return func(b *RateLimitBackoffer) *RateLimitBackoffer {
+		b.maxWait = max
+		return b
+	}
+}
+
+// RateLimitOptionClock configures the Clock used to convert reset timestamps
+// to delays.
+func RateLimitOptionClock(clock Clock) RateLimitOption {
+	return func(b *RateLimitBackoffer) *RateLimitBackoffer {
+		b.clock = clock
+		return b
+	}
+}
+
+// NewRateLimitBackoffPolicy wraps any backoff policy and waits until the reset
+// time given by rate limit headers, such as X-RateLimit-Remaining and
+// X-RateLimit-Reset, when the response reports that no requests remain. The
+// reset may be either a number of seconds or a Unix timestamp. The wrapped
+// policy is used for all other responses. The retry policies must still
+// select the rate limited responses, which are usually 429 or 403, for retry.
+func NewRateLimitBackoffPolicy(wrapped BackoffPolicy, opts ...RateLimitOption) BackoffPolicy {
+	return func() Backoffer {
+		var b = &RateLimitBackoffer{wrapped: wrapped(), clock: NewSystemClock()}
+		for _, opt := range opts {
+			b = opt(b)
+		}
+		return b
+	}
+}
+
+// Backoff until the rate limit reset or for the wrapped value.
+func (b *RateLimitBackoffer) Backoff(r *http.Request, response *http.Response, e error) time.Duration {
+	if wait, ok := b.resetWait(response); ok {
+		return wait
+	}
+	return b.wrapped.Backoff(r, response, e)
+}
+
+// BackoffCtx until the rate limit reset or for the wrapped BackoffCtx value.
+func (b *RateLimitBackoffer) BackoffCtx(ctx context.Context, attempt int, r *http.Request, response *http.Response, e error) time.Duration {
+	if wait, ok := b.resetWait(response); ok {
+		return wait
+	}
+	return backoffFor(b.wrapped, ctx, attempt, r, response, e)
+}
+
+func (b *RateLimitBackoffer) resetWait(response *http.Response) (time.Duration, bool) {
+	if response == nil {
+		return 0, false
+	}
+	for _, names := range rateLimitHeaders {
+		var remaining = strings.TrimSpace(response.Header.Get(names.remaining))
+		if remaining == "" {
+			continue
+		}
+		if remaining != "0" {
+			return 0, false
+		}
+		var wait, ok = parseRateLimitReset(response.Header.Get(names.reset), b.clock.Now())
+		if !ok {
+			return 0, false
+		}
+		if b.maxWait > 0 && wait > b.maxWait {
+			return BackoffStop, true
+		}
+		return wait, true
+	}
+	return 0, false
+}
+
+// parseRateLimitReset converts a reset header value, given as either a number
+// of seconds or a Unix timestamp, to the time remaining until the reset.
+func parseRateLimitReset(value string, now time.Time) (time.Duration, bool) {
+	var seconds, e = strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if e != nil || seconds < 0 || math.IsInf(seconds, 0) || math.IsNaN(seconds) {
+		return 0, false
+	}
+	if seconds < epochThreshold {
+		return time.Duration(seconds * float64(time.Second)), true
+	}
+	var wait = time.Unix(int64(seconds), 0).Sub(now)
+	if wait < 0 {
+		wait = 0
+	}
+	return wait, true
+}
@@ -0,0 +1,54 @@
+package transport
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitBackoffer(t *testing.T) {
+	var clock = newFakeClock()
+	clock.advance(time.Unix(1700000000, 0).Sub(clock.Now()))
+	var backoffer = NewRateLimitBackoffPolicy(
+		NewFixedBackoffPolicy(time.Second),
+		RateLimitOptionClock(clock),
+		RateLimitOptionMaxWait(time.Hour),
+	)()
+	var respond = func(headers map[string]string) *http.Response {
+		var response = &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}}
+		for k, v := range headers {
+			response.Header.Set(k, v)
+		}
+		return response
+	}
+	var epoch = func(d time.Duration) string {
+		return strconv.FormatInt(clock.Now().Add(d).Unix(), 10)
+	}
+
+	var tc = []struct {
+		name     string
+		headers  map[string]string
+		expected time.Duration
+	}{
+		{"no headers", nil, time.Second},
+		{"remaining", map[string]string{"X-RateLimit-Remaining": "3", "X-RateLimit-Reset": epoch(time.Minute)}, time.Second},
+		{"github", map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": epoch(time.Minute)}, time.Minute},
+		{"ietf", map[string]string{"RateLimit-Remaining": "0", "RateLimit-Reset": "30"}, 30 * time.Second},
+		{"twitter", map[string]string{"X-Rate-Limit-Remaining": "0", "X-Rate-Limit-Reset": epoch(5 * time.Second)}, 5 * time.Second},
+		{"past reset", map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": epoch(-time.Minute)}, 0},
+		{"invalid reset", map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": "soon"}, time.Second},
+		{"beyond max", map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": epoch(2 * time.Hour)}, BackoffStop},
+	}
+	for _, c := range tc {
+		t.Run(c.name, func(t *testing.T) {
+			var response = respond(c.headers)
+			assert.Equal(t, c.expected, backoffer.Backoff(nil, response, nil))
+			assert.Equal(t, c.expected, backoffer.(BackofferCtx).BackoffCtx(context.Background(), 1, nil, response, nil))
+		})
+	}
+	assert.Equal(t, time.Second, backoffer.Backoff(nil, nil, nil))
+}
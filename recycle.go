@@ -1,25 +1,48 @@
 package transport
 
 import (
+	"context"
 	"math/rand"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+const recyclerSource = "recycler"
+
 // Recycler is a decorator that discards and regenerates the transport after
 // a given set of triggers.
 type Recycler struct {
-	wrapped      http.RoundTripper
-	ttl          time.Duration
-	ttlJitter    time.Duration
-	nextTTL      time.Time
-	maxUsage     int
-	currentUsage int
-	signals      []chan struct{}
-	signal       chan struct{}
-	lock         *sync.Mutex
-	factory      Factory
+	current   atomic.Pointer[recycledTransport]
+	ttl       time.Duration
+	ttlJitter time.Duration
+	maxUsage  int
+	signals   []chan struct{}
+	signal    chan struct{}
+	stop      chan struct{}
+	stopOnce  sync.Once
+	lock      *sync.Mutex
+	factory   Factory
+	clock     Clock
+	random    func() float64
+	// maxConnErrors is the number of consecutive connection protocol errors
+	// that trigger a recycle, or zero to disable the trigger.
+	maxConnErrors int
+	// rotator is the managed instance set when rolling recycles are enabled.
+	rotator     *Rotator
+	rolling     int
+	rollingNext int
+}
+
+// recycledTransport is a single generation of the managed transport. It is
+// never modified after being published other than the usage counter, which
+// allows requests to read it without holding the Recycler lock.
+type recycledTransport struct {
+	wrapped    http.RoundTripper
+	nextTTL    time.Time
+	usage      atomic.Int64
+	connErrors atomic.Int64
 }
 
 // RecycleOption is a configuration for the Recycler decorator
@@ -60,13 +83,61 @@ func RecycleOptionChannel(signal chan struct{}) RecycleOption {
 	}
 }
 
+// RecycleOptionConnectionErrors configures the recycler to rotate Transports
+// after the given number of consecutive requests fail with connection level
+// protocol errors, such as HTTP/2 GOAWAY frames, as reported by
+// IsConnectionProtocolError. This replaces connections that a server keeps
+// rejecting without requiring a signal channel to be wired up.
+func RecycleOptionConnectionErrors(max int) RecycleOption {
+	return func(r *Recycler) *Recycler {
+		r.maxConnErrors = max
+		return r
+	}
+}
+
+// RecycleOptionRolling configures the recycler to manage a Rotator with the
+// given number of instances and to replace only the oldest instance each time
+// a recycle is triggered rather than the entire set. The TTL is divided
+// between the instances so that each one is still replaced about once per TTL.
+// Spreading the replacements out avoids every connection in a large set
+// reconnecting at the same moment.
+func RecycleOptionRolling(instances int) RecycleOption {
+	return func(r *Recycler) *Recycler {
+		r.rolling = instances
+		return r
+	}
+}
+
+// RecycleOptionRandSource configures the source of randomness used to
+// compute the TTL jitter. The source must not be used elsewhere.
+func RecycleOptionRandSource(source *rand.Rand) RecycleOption {
+	return func(r *Recycler) *Recycler {
+		r.random = lockedRandom(source)
+		return r
+	}
+}
+
+// RecycleOptionClock configures the Clock used to evaluate the TTL.
+func RecycleOptionClock(clock Clock) RecycleOption {
+	return func(r *Recycler) *Recycler {
+		r.clock = clock
+		return r
+	}
+}
+
 // NewRecycler uses the given factory as a source and recycles the transport
 // based on the options given.
 func NewRecycler(factory Factory, opts ...RecycleOption) *Recycler {
-	var r = &Recycler{wrapped: factory(), lock: &sync.Mutex{}, factory: factory, signal: make(chan struct{})}
+	var r = &Recycler{lock: &sync.Mutex{}, factory: factory, signal: make(chan struct{}), stop: make(chan struct{}), clock: NewSystemClock(), random: rand.Float64}
 	for _, opt := range opts {
 		r = opt(r)
 	}
+	if r.rolling > 0 {
+		r.rotator = NewRotator(factory, RotatorOptionInstances(r.rolling))
+		r.current.Store(&recycledTransport{wrapped: r.rotator})
+	} else {
+		r.current.Store(&recycledTransport{wrapped: factory()})
+	}
 	r.listen()
 	return r
 }
@@ -79,52 +150,110 @@ func NewRecyclerFactory(factory Factory, opts ...RecycleOption) Factory {
 	}
 }
 
-func (c *Recycler) resetTransport() http.RoundTripper {
-	c.wrapped = c.factory()
-	c.currentUsage = 0
-	var renderedJitter = time.Duration(rand.Float64() * float64(c.ttlJitter)) // nolint:gosec
-	if rand.Float64()*100 > 50 {                                              // nolint:gosec
+func (c *Recycler) newTransport() *recycledTransport {
+	var renderedJitter = time.Duration(c.random() * float64(c.ttlJitter))
+	if c.random()*100 > 50 {
 		renderedJitter = -renderedJitter
 	}
-	c.nextTTL = time.Now().Add(c.ttl + renderedJitter)
-	return c.wrapped
+	if c.rotator != nil {
+		c.rotator.replace(c.rollingNext)
+		c.rollingNext = (c.rollingNext + 1) % c.rotator.numberOfInstances
+		var step = (c.ttl + renderedJitter) / time.Duration(c.rotator.numberOfInstances)
+		return &recycledTransport{wrapped: c.rotator, nextTTL: c.clock.Now().Add(step)}
+	}
+	return &recycledTransport{wrapped: c.factory(), nextTTL: c.clock.Now().Add(c.ttl + renderedJitter)}
+}
+
+// resetTransport replaces the expired generation. Only one caller performs the
+// replacement; any others that observed the same expired generation use the
+// replacement instead of generating another.
+func (c *Recycler) resetTransport(ctx context.Context, expired *recycledTransport) *recycledTransport {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	var current = c.current.Load()
+	if current != expired {
+		current.usage.Add(1)
+		return current
+	}
+	current = c.newTransport()
+	c.current.Store(current)
+	emitEvent(ctx, TransportEvent{Type: EventTransportRecycled, Source: recyclerSource})
+	return current
 }
 
 func (c *Recycler) listen() {
 	for _, signal := range c.signals {
-		go c.listenOne(signal)
+		goLabeled(context.Background(), recyclerSource, "", func(context.Context) {
+			c.listenOne(signal)
+		})
 	}
 }
 
 func (c *Recycler) listenOne(s chan struct{}) {
-	for range s {
-		c.signal <- struct{}{}
+	for {
+		select {
+		case <-c.stop:
+			return
+		case _, ok := <-s:
+			if !ok {
+				return
+			}
+		}
+		select {
+		case <-c.stop:
+			return
+		case c.signal <- struct{}{}:
+		}
 	}
 }
 
+// Close stops listening to the recycle channels and closes the current
+// transport. Transports from earlier generations are left to the idle
+// connection timeout.
+func (c *Recycler) Close(ctx context.Context) error {
+	c.stopOnce.Do(func() { close(c.stop) })
+	return closeTransport(ctx, c.current.Load().wrapped)
+}
+
 func (c *Recycler) getTransport() http.RoundTripper {
-	c.lock.Lock()
-	defer c.lock.Unlock()
-	if c.maxUsage > 0 {
-		c.currentUsage = c.currentUsage + 1
-		if c.currentUsage > c.maxUsage {
-			return c.resetTransport()
-		}
+	return c.transportFor(context.Background()).wrapped
+}
+
+func (c *Recycler) transportFor(ctx context.Context) *recycledTransport {
+	var current = c.current.Load()
+	if c.maxUsage > 0 && current.usage.Add(1) > int64(c.maxUsage) {
+		return c.resetTransport(ctx, current)
 	}
-	if c.ttl > 0 && time.Now().After(c.nextTTL) {
-		return c.resetTransport()
+	if c.ttl > 0 && c.clock.Now().After(current.nextTTL) {
+		return c.resetTransport(ctx, current)
 	}
 	select {
 	case <-c.signal:
-		return c.resetTransport()
+		return c.resetTransport(ctx, current)
 	default:
 		break
 	}
-	return c.wrapped
+	return current
 }
 
 // RoundTrip applies the discard and regenerate policy.
 func (c *Recycler) RoundTrip(r *http.Request) (*http.Response, error) {
-	var rt = c.getTransport()
-	return rt.RoundTrip(r)
+	var current = c.transportFor(r.Context())
+	var resp, e = current.wrapped.RoundTrip(r)
+	if c.maxConnErrors > 0 {
+		c.observeConnectionError(r.Context(), current, e)
+	}
+	return resp, e
+}
+
+// observeConnectionError tracks consecutive connection level protocol errors
+// from a generation and replaces it once they reach the configured limit.
+func (c *Recycler) observeConnectionError(ctx context.Context, generation *recycledTransport, e error) {
+	if !IsConnectionProtocolError(e) {
+		generation.connErrors.Store(0)
+		return
+	}
+	if generation.connErrors.Add(1) >= int64(c.maxConnErrors) {
+		c.resetTransport(ctx, generation)
+	}
 }
@@ -1,7 +1,9 @@
 package transport
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"testing"
 	"time"
@@ -35,13 +37,13 @@ func TestRecycleOptionTTL(t *testing.T) {
 	}
 
 	var result = r.getTransport()
-	if r.nextTTL.Before(time.Now().Add(time.Second-11*time.Millisecond)) || r.nextTTL.After(time.Now().Add(time.Second+11*time.Millisecond)) {
+	if r.current.Load().nextTTL.Before(time.Now().Add(time.Second-11*time.Millisecond)) || r.current.Load().nextTTL.After(time.Now().Add(time.Second+11*time.Millisecond)) {
 		t.Fatalf("ttl was not generated with the correct jitter")
 	}
 	if r.getTransport() != result {
 		t.Fatal("regenerated transport before ttl")
 	}
-	time.Sleep(time.Until(r.nextTTL) + 5*time.Millisecond)
+	time.Sleep(time.Until(r.current.Load().nextTTL) + 5*time.Millisecond)
 	if r.getTransport() == result {
 		t.Fatal("did not regenerated transport after ttl")
 	}
@@ -61,19 +63,19 @@ func TestRecycleOptionMaxusage(t *testing.T) {
 	}
 
 	var result = r.getTransport()
-	if r.currentUsage != 1 {
+	if r.current.Load().usage.Load() != 1 {
 		t.Fatal("did not track transport usage")
 	}
 	if r.getTransport() != result {
 		t.Fatal("regenerated transport too soon")
 	}
-	if r.currentUsage != 2 {
+	if r.current.Load().usage.Load() != 2 {
 		t.Fatal("did not track transport usage")
 	}
 	if r.getTransport() == result {
 		t.Fatal("did not regenerated transport after max usage")
 	}
-	if r.currentUsage != 0 {
+	if r.current.Load().usage.Load() != 0 {
 		t.Fatal("did not reset transport usage")
 	}
 }
@@ -101,3 +103,125 @@ func TestRecycleOptionChannel(t *testing.T) {
 		t.Fatal("did not regenerate transport after getting a signal")
 	}
 }
+
+func TestRecycleOptionConnectionErrors(t *testing.T) {
+	registerFakeHTTP2Errors(t)
+	var generations int
+	var e error
+	var factory = func() http.RoundTripper {
+		generations = generations + 1
+		return RoundTripperFunc(func(*http.Request) (*http.Response, error) {
+			if e != nil {
+				return nil, e
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+		})
+	}
+	var r = NewRecycler(factory, RecycleOptionConnectionErrors(2))
+	var req, _ = http.NewRequest(http.MethodGet, "/", nil)
+
+	e = fmt.Errorf("wrapped: %w", fakeGoAwayError{})
+	_, _ = r.RoundTrip(req)
+	e = nil
+	_, _ = r.RoundTrip(req)
+	e = fmt.Errorf("wrapped: %w", fakeGoAwayError{})
+	_, _ = r.RoundTrip(req)
+	if generations != 1 {
+		t.Fatal("regenerated transport after errors that were not consecutive")
+	}
+	_, _ = r.RoundTrip(req)
+	if generations != 2 {
+		t.Fatal("did not regenerate transport after consecutive connection errors")
+	}
+	e = errors.New("connection refused")
+	_, _ = r.RoundTrip(req)
+	_, _ = r.RoundTrip(req)
+	if generations != 2 {
+		t.Fatal("regenerated transport after errors that were not protocol errors")
+	}
+}
+
+func TestRecycleOptionRolling(t *testing.T) {
+	var created int
+	var factory = func() http.RoundTripper {
+		created = created + 1
+		return &roundTripperForRecycleTests{v: fmt.Sprint(created)}
+	}
+	var clock = newFakeClock()
+	var r = NewRecycler(factory, RecycleOptionRolling(3), RecycleOptionTTL(3*time.Minute), RecycleOptionClock(clock))
+	if created != 3 {
+		t.Fatal("did not create one transport per instance")
+	}
+	var snapshot = func() []http.RoundTripper {
+		var instances []http.RoundTripper
+		for offset := range r.rotator.instances {
+			instances = append(instances, r.rotator.instances[offset].Load().wrapped)
+		}
+		return instances
+	}
+	var before = snapshot()
+	if r.getTransport() != r.rotator {
+		t.Fatal("did not use the rotator as the transport")
+	}
+	var after = snapshot()
+	if after[0] == before[0] || after[1] != before[1] || after[2] != before[2] {
+		t.Fatal("did not replace only the oldest instance")
+	}
+	if !r.current.Load().nextTTL.Equal(clock.Now().Add(time.Minute)) {
+		t.Fatal("did not divide the TTL between the instances")
+	}
+	clock.advance(2 * time.Minute)
+	_ = r.getTransport()
+	var last = snapshot()
+	if last[0] != after[0] || last[1] == after[1] || last[2] != after[2] {
+		t.Fatal("did not move on to the next oldest instance")
+	}
+	if created != 5 {
+		t.Fatal("replaced more than one instance per recycle")
+	}
+}
+
+func BenchmarkRecyclerParallel(b *testing.B) {
+	var factory = func() http.RoundTripper {
+		return &roundTripperForRecycleTests{v: "string4"}
+	}
+	var r = NewRecycler(factory, RecycleOptionTTL(time.Hour))
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_ = r.getTransport()
+		}
+	})
+}
+
+func TestRecyclerClose(t *testing.T) {
+	var closed []string
+	var factory = func() http.RoundTripper {
+		return &closeRecordingTransport{name: "recycled", closed: &closed}
+	}
+	var signal = make(chan struct{})
+	var r = NewRecycler(factory, RecycleOptionChannel(signal))
+	if e := r.Close(context.Background()); e != nil {
+		t.Fatal(e)
+	}
+	if e := r.Close(context.Background()); e != nil {
+		t.Fatal(e)
+	}
+	if len(closed) != 2 {
+		t.Fatalf("expected the current transport to be closed on each call but got %v", closed)
+	}
+	time.Sleep(time.Millisecond) // Wait for the background listener to stop
+	select {
+	case signal <- struct{}{}:
+		t.Fatal("listener still running after close")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	closed = nil
+	r = NewRecycler(factory, RecycleOptionRolling(3))
+	if e := r.Close(context.Background()); e != nil {
+		t.Fatal(e)
+	}
+	if len(closed) != 3 {
+		t.Fatalf("expected every rotated instance to be closed but got %v", closed)
+	}
+}
@@ -0,0 +1,170 @@
+package transport
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// StripCredentials removes credentials from requests that follow a redirect
+// to a different registrable domain, such as from api.example.com to
+// example.net. The http.Client only removes Authorization and Cookie
+// headers, and forwards custom credential headers such as API keys to every
+// host.
+type StripCredentials struct {
+	headers []string
+	domain  func(host string) string
+	groups  map[string]int
+}
+
+// StripCredentialsOption is a configuration for StripCredentials.
+type StripCredentialsOption func(*StripCredentials) *StripCredentials
+
+// StripCredentialsOptionHeaders adds custom credential headers, such as
+// X-Api-Key, to the Authorization, Proxy-Authorization, Cookie, and CSRF
+// token headers that are removed.
+func StripCredentialsOptionHeaders(headers ...string) StripCredentialsOption {
+	return func(s *StripCredentials) *StripCredentials {
+		s.headers = append(s.headers, headers...)
+		return s
+	}
+}
+
+// StripCredentialsOptionTrustedGroup sets registrable domains, such as
+// example.com and example-cdn.net, that belong to the same organization so
+// that credentials are kept on redirects between them.
+func StripCredentialsOptionTrustedGroup(domains ...string) StripCredentialsOption {
+	return func(s *StripCredentials) *StripCredentials {
+		var group = len(s.groups) + 1
+		for _, domain := range domains {
+			s.groups[strings.ToLower(domain)] = group
+		}
+		return s
+	}
+}
+
+// StripCredentialsOptionRegistrableDomain sets the function that finds the
+// registrable domain of a host. The default uses the last two labels of the
+// host, which is wrong for suffixes such as co.uk, so services that call
+// such hosts should use publicsuffix.EffectiveTLDPlusOne from
+// golang.org/x/net, returning the host itself on error.
+func StripCredentialsOptionRegistrableDomain(domain func(host string) string) StripCredentialsOption {
+	return func(s *StripCredentials) *StripCredentials {
+		s.domain = domain
+		return s
+	}
+}
+
+func lastTwoLabels(host string) string {
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	var labels = strings.Split(host, ".")
+	if len(labels) <= 2 {
+		return host
+	}
+	return strings.Join(labels[len(labels)-2:], ".")
+}
+
+// NewStripCredentials creates a StripCredentials.
+func NewStripCredentials(opts ...StripCredentialsOption) *StripCredentials {
+	var s = &StripCredentials{
+		headers: []string{"Authorization", "Proxy-Authorization", "Cookie", "X-CSRF-Token", "X-XSRF-Token"},
+		domain:  lastTwoLabels,
+		groups:  make(map[string]int),
+	}
+	for _, opt := range opts {
+		s = opt(s)
+	}
+	return s
+}
+
+// trusted reports whether credentials for the previous host may be sent to
+// the next host.
+func (s *StripCredentials) trusted(previous string, next string) bool {
+	var from = s.domain(strings.TrimSuffix(strings.ToLower(previous), "."))
+	var to = s.domain(strings.TrimSuffix(strings.ToLower(next), "."))
+	if from == to {
+		return true
+	}
+	var group, ok = s.groups[from]
+	return ok && s.groups[to] == group
+}
+
+func (s *StripCredentials) strip(header http.Header) {
+	for _, name := range s.headers {
+		header.Del(name)
+	}
+}
+
+// CheckRedirect wraps a redirect policy, such as one created by
+// NewSecureRedirectPolicy, so that credentials are removed from redirects
+// that leave the registrable domain of the original request. A nil policy
+// follows up to 10 redirects like the http.Client.
+func (s *StripCredentials) CheckRedirect(policy func(*http.Request, []*http.Request) error) func(*http.Request, []*http.Request) error {
+	if policy == nil {
+		policy = NewSecureRedirectPolicy(defaultMaxRedirects)
+	}
+	return func(r *http.Request, via []*http.Request) error {
+		if e := policy(r, via); e != nil {
+			return e
+		}
+		if len(via) > 0 && !s.trusted(via[0].URL.Hostname(), r.URL.Hostname()) {
+			s.strip(r.Header)
+		}
+		return nil
+	}
+}
+
+// Decorator returns a RoundTripper decorator that removes credentials from
+// requests that the http.Client created to follow a redirect to a different
+// registrable domain. It is useful when the redirect policy of the client
+// cannot be changed. Because the decorator only sees the previous request
+// of a chain of redirects, CheckRedirect is preferred.
+func (s *StripCredentials) Decorator() func(http.RoundTripper) http.RoundTripper {
+	return func(wrapped http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			if r.Response == nil || r.Response.Request == nil || s.trusted(r.Response.Request.URL.Hostname(), r.URL.Hostname()) {
+				return wrapped.RoundTrip(r)
+			}
+			r = r.Clone(r.Context())
+			s.strip(r.Header)
+			return wrapped.RoundTrip(r)
+		})
+	}
+}
+
+type hostChangeKey struct{}
+
+type hostChange struct {
+	strip *StripCredentials
+	host  string
+}
+
+// OnHostChange returns a RoundTripper decorator that protects credentials
+// from decorators installed inside it that send requests to a different
+// host, such as the Failover. When one of them sends a request to a host
+// outside the registrable domain or trusted group of the original host, the
+// credential headers are removed. Decorators that set credentials for each
+// host should be installed inside the decorator that changes the host so
+// that they derive the headers again.
+func (s *StripCredentials) OnHostChange() func(http.RoundTripper) http.RoundTripper {
+	return func(wrapped http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			var ctx = context.WithValue(r.Context(), hostChangeKey{}, hostChange{strip: s, host: r.URL.Hostname()})
+			return wrapped.RoundTrip(r.WithContext(ctx))
+		})
+	}
+}
+
+// stripOnHostChange is called by decorators that change the host of a
+// request that they own to apply the policy installed by OnHostChange. It
+// reports whether such a policy was installed.
+func stripOnHostChange(r *http.Request) bool {
+	var change, ok = r.Context().Value(hostChangeKey{}).(hostChange)
+	if ok && !change.strip.trusted(change.host, r.URL.Hostname()) {
+		change.strip.strip(r.Header)
+	}
+	return ok
+}
@@ -0,0 +1,114 @@
+package transport
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newRedirectingTransport redirects every request for api.example.com to the
+// target and records the headers of the requests that reach other hosts.
+func newRedirectingTransport(target string, seen *http.Header) http.RoundTripper {
+	return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Host == "api.example.com" {
+			return &http.Response{
+				StatusCode: http.StatusFound,
+				Header:     http.Header{"Location": []string{target}},
+				Body:       http.NoBody,
+				Request:    r,
+			}, nil
+		}
+		*seen = r.Header.Clone()
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: r}, nil
+	})
+}
+
+func newCredentialedRequest(t *testing.T) *http.Request {
+	var req, e = http.NewRequest(http.MethodGet, "https://api.example.com/", nil)
+	require.NoError(t, e)
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("X-Api-Key", "key")
+	req.Header.Set("Accept", "application/json")
+	return req
+}
+
+func TestStripCredentialsCheckRedirect(t *testing.T) {
+	var strip = NewStripCredentials(
+		StripCredentialsOptionHeaders("X-Api-Key"),
+		StripCredentialsOptionTrustedGroup("example.com", "example-cdn.net"),
+	)
+	for _, tc := range []struct {
+		target   string
+		stripped bool
+	}{
+		{target: "https://files.example.com/", stripped: false},
+		{target: "https://assets.example-cdn.net/", stripped: false},
+		{target: "https://attacker.example.org/", stripped: true},
+	} {
+		var seen http.Header
+		var client = &http.Client{
+			Transport:     newRedirectingTransport(tc.target, &seen),
+			CheckRedirect: strip.CheckRedirect(nil),
+		}
+		var resp, e = client.Do(newCredentialedRequest(t))
+		require.NoError(t, e)
+		_ = resp.Body.Close()
+		assert.Equal(t, tc.stripped, seen.Get("X-Api-Key") == "", tc.target)
+		assert.Equal(t, "application/json", seen.Get("Accept"), tc.target)
+	}
+}
+
+func TestStripCredentialsDecorator(t *testing.T) {
+	var seen http.Header
+	var client = &http.Client{
+		Transport: NewStripCredentials(StripCredentialsOptionHeaders("X-Api-Key"), StripCredentialsOptionRegistrableDomain(func(host string) string {
+			return host
+		})).Decorator()(newRedirectingTransport("https://api.example.net/", &seen)),
+	}
+	var req = newCredentialedRequest(t)
+	var resp, e = client.Do(req)
+	require.NoError(t, e)
+	_ = resp.Body.Close()
+	assert.Empty(t, seen.Get("X-Api-Key"))
+	assert.Empty(t, seen.Get("Authorization"))
+	assert.Equal(t, "key", req.Header.Get("X-Api-Key"), "original request was modified")
+
+	assert.Equal(t, "127.0.0.1", lastTwoLabels("127.0.0.1"))
+	assert.Equal(t, "example.com", lastTwoLabels("a.b.example.com"))
+}
+
+func TestStripCredentialsOnHostChange(t *testing.T) {
+	var failover = NewFailover([]FailoverRegion{
+		{Name: "primary", URL: &url.URL{Scheme: "https", Host: "east.example.com"}},
+		{Name: "recovery", URL: &url.URL{Scheme: "https", Host: "api.recovery.net"}},
+	}, FailoverOptionThreshold(1))
+	var seen http.Header
+	var rt = Chain{
+		NewStripCredentials(StripCredentialsOptionHeaders("X-Api-Key")).OnHostChange(),
+		NewFailoverRouting(failover),
+	}.Apply(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		seen = r.Header.Clone()
+		if r.URL.Host == "east.example.com" {
+			return &http.Response{StatusCode: http.StatusBadGateway, Body: http.NoBody}, nil
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	}))
+
+	var req = newCredentialedRequest(t)
+	req.Header.Set("X-CSRF-Token", "csrf")
+	var _, e = rt.RoundTrip(req)
+	require.NoError(t, e)
+	assert.Equal(t, "Bearer token", seen.Get("Authorization"), "same registrable domain")
+	assert.Equal(t, "csrf", seen.Get("X-CSRF-Token"))
+
+	_, e = rt.RoundTrip(req)
+	require.NoError(t, e)
+	assert.Empty(t, seen.Get("Authorization"))
+	assert.Empty(t, seen.Get("X-Api-Key"))
+	assert.Empty(t, seen.Get("X-CSRF-Token"))
+	assert.Equal(t, "application/json", seen.Get("Accept"))
+	assert.Equal(t, "Bearer token", req.Header.Get("Authorization"), "request was modified")
+}
@@ -0,0 +1,290 @@
+package transport
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DecoratorConstructor generates a Decorator from configuration. The unmarshal
+// function decodes the raw configuration for the decorator into the given
+// value in the same way as yaml.Unmarshaler.
+type DecoratorConstructor func(unmarshal func(interface{}) error) (Decorator, error)
+
+// Registry is a named collection of DecoratorConstructors. It enables
+// decorators, including those from other packages, to be referenced by name in
+// declarative configuration.
+type Registry struct {
+	lock         *sync.RWMutex
+	constructors map[string]DecoratorConstructor
+}
+
+// NewRegistry generates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{lock: &sync.RWMutex{}, constructors: make(map[string]DecoratorConstructor)}
+}
+
+// Register a constructor under the given name. Names may only be registered
+// once.
+func (r *Registry) Register(name string, constructor DecoratorConstructor) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if _, ok := r.constructors[name]; ok {
+		return fmt.Errorf("decorator %q is already registered", name)
+	}
+	r.constructors[name] = constructor
+	return nil
+}
+
+// Build the named decorator using the given configuration source.
+func (r *Registry) Build(name string, unmarshal func(interface{}) error) (Decorator, error) {
+	r.lock.RLock()
+	var constructor, ok = r.constructors[name]
+	r.lock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("decorator %q is not registered", name)
+	}
+	var d, e = constructor(unmarshal)
+	if e != nil {
+		return nil, fmt.Errorf("decorator %q: %w", name, e)
+	}
+	return d, nil
+}
+
+// Names returns the sorted set of registered decorator names.
+func (r *Registry) Names() []string {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	var names = make([]string, 0, len(r.constructors))
+	for name := range r.constructors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DefaultRegistry contains all of the decorators provided by this package and
+// is used by ChainConfig to resolve extension decorators.
+var DefaultRegistry = NewRegistry()
+
+// Register a constructor with the DefaultRegistry.
+func Register(name string, constructor DecoratorConstructor) error {
+	return DefaultRegistry.Register(name, constructor)
+}
+
+// ExtensionConfig references a registered decorator by name along with its
+// configuration.
+type ExtensionConfig struct {
+	Name     string                 `description:"Name of the registered decorator."`
+	Settings map[string]interface{} `description:"Configuration values for the decorator."`
+}
+
+// Unmarshal decodes the extension settings into the given value, which must
+// be a pointer. Keys match exported field names without regard to case, and
+// unknown keys are ignored. Durations may be given as strings, such as
+// "50ms", or as numbers of nanoseconds. Strings are converted to numbers and
+// booleans so that values read from the environment can be used.
+func (c ExtensionConfig) Unmarshal(v interface{}) error {
+	if len(c.Settings) < 1 {
+		return nil
+	}
+	var target = reflect.ValueOf(v)
+	if target.Kind() != reflect.Ptr || target.IsNil() {
+		return fmt.Errorf("settings must be decoded into a non-nil pointer, not %T", v)
+	}
+	return decodeSetting(reflect.ValueOf(c.Settings), target.Elem(), c.Name)
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// decodeSetting copies a configuration value into the target, converting
+// between compatible types. The path names the value in errors.
+func decodeSetting(value reflect.Value, target reflect.Value, path string) error {
+	for value.IsValid() && (value.Kind() == reflect.Interface || value.Kind() == reflect.Ptr) {
+		value = value.Elem()
+	}
+	if !value.IsValid() {
+		return nil
+	}
+	if target.Type() == durationType && value.Kind() == reflect.String {
+		var d, e = time.ParseDuration(value.String())
+		if e != nil {
+			return fmt.Errorf("%s: %w", path, e)
+		}
+		target.SetInt(int64(d))
+		return nil
+	}
+	switch target.Kind() {
+	case reflect.Ptr:
+		if target.IsNil() {
+			target.Set(reflect.New(target.Type().Elem()))
+		}
+		return decodeSetting(value, target.Elem(), path)
+	case reflect.Interface:
+		if !value.Type().AssignableTo(target.Type()) {
+			return fmt.Errorf("%s: cannot use %s as %s", path, value.Type(), target.Type())
+		}
+		target.Set(value)
+		return nil
+	case reflect.Struct:
+		if value.Kind() != reflect.Map {
+			return fmt.Errorf("%s: expected a map but got %s", path, value.Type())
+		}
+		var iter = value.MapRange()
+		for iter.Next() {
+			var name = fmt.Sprint(iter.Key().Interface())
+			for x := 0; x < target.NumField(); x = x + 1 {
+				var field = target.Type().Field(x)
+				if field.IsExported() && strings.EqualFold(field.Name, name) {
+					if e := decodeSetting(iter.Value(), target.Field(x), path+"."+field.Name); e != nil {
+						return e
+					}
+					break
+				}
+			}
+		}
+		return nil
+	case reflect.Map:
+		if value.Kind() != reflect.Map {
+			return fmt.Errorf("%s: expected a map but got %s", path, value.Type())
+		}
+		if target.IsNil() {
+			target.Set(reflect.MakeMapWithSize(target.Type(), value.Len()))
+		}
+		var iter = value.MapRange()
+		for iter.Next() {
+			var key = reflect.New(target.Type().Key()).Elem()
+			var name = fmt.Sprint(iter.Key().Interface())
+			if e := decodeSetting(reflect.ValueOf(name), key, path+"."+name); e != nil {
+				return e
+			}
+			var element = reflect.New(target.Type().Elem()).Elem()
+			if e := decodeSetting(iter.Value(), element, path+"."+name); e != nil {
+				return e
+			}
+			target.SetMapIndex(key, element)
+		}
+		return nil
+	case reflect.Slice:
+		if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+			return fmt.Errorf("%s: expected a list but got %s", path, value.Type())
+		}
+		var elements = reflect.MakeSlice(target.Type(), value.Len(), value.Len())
+		for x := 0; x < value.Len(); x = x + 1 {
+			if e := decodeSetting(value.Index(x), elements.Index(x), fmt.Sprintf("%s[%d]", path, x)); e != nil {
+				return e
+			}
+		}
+		target.Set(elements)
+		return nil
+	}
+	return decodeScalar(value, target, path)
+}
+
+// decodeScalar copies a string, boolean, or number into the target.
+func decodeScalar(value reflect.Value, target reflect.Value, path string) error {
+	var e error
+	switch target.Kind() {
+	case reflect.String:
+		if value.Kind() != reflect.String {
+			return fmt.Errorf("%s: expected a string but got %s", path, value.Type())
+		}
+		target.SetString(value.String())
+		return nil
+	case reflect.Bool:
+		switch value.Kind() {
+		case reflect.Bool:
+			target.SetBool(value.Bool())
+			return nil
+		case reflect.String:
+			var b bool
+			if b, e = strconv.ParseBool(value.String()); e == nil {
+				target.SetBool(b)
+				return nil
+			}
+		}
+		return fmt.Errorf("%s: expected a boolean but got %v", path, value.Interface())
+	}
+	var number float64
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		number = float64(value.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		number = float64(value.Uint())
+	case reflect.Float32, reflect.Float64:
+		number = value.Float()
+	case reflect.String:
+		if number, e = strconv.ParseFloat(value.String(), 64); e != nil {
+			return fmt.Errorf("%s: expected a number but got %q", path, value.String())
+		}
+	default:
+		return fmt.Errorf("%s: cannot use %s as %s", path, value.Type(), target.Type())
+	}
+	switch target.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if number != math.Trunc(number) || target.OverflowInt(int64(number)) {
+			return fmt.Errorf("%s: %v does not fit in %s", path, number, target.Type())
+		}
+		target.SetInt(int64(number))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if number < 0 || number != math.Trunc(number) || target.OverflowUint(uint64(number)) {
+			return fmt.Errorf("%s: %v does not fit in %s", path, number, target.Type())
+		}
+		target.SetUint(uint64(number))
+	case reflect.Float32, reflect.Float64:
+		target.SetFloat(number)
+	default:
+		return fmt.Errorf("%s: cannot decode into %s", path, target.Type())
+	}
+	return nil
+}
+
+func init() {
+	var defaults = NewChainComponent().Settings()
+	_ = Register(defaults.AccessLog.Name(), func(unmarshal func(interface{}) error) (Decorator, error) {
+		return NewAccessLog(), nil
+	})
+	_ = Register(defaults.Headers.Name(), func(unmarshal func(interface{}) error) (Decorator, error) {
+		var conf = &HeaderConfig{}
+		if e := unmarshal(conf); e != nil {
+			return nil, e
+		}
+		return conf.Decorator(), nil
+	})
+	_ = Register(defaults.RetryAfter.Name(), func(unmarshal func(interface{}) error) (Decorator, error) {
+		return NewRetryAfter(), nil
+	})
+	_ = Register(defaults.Retry.Name(), func(unmarshal func(interface{}) error) (Decorator, error) {
+		var conf = NewChainComponent().Settings().Retry
+		if e := unmarshal(conf); e != nil {
+			return nil, e
+		}
+		return conf.Decorator()
+	})
+	_ = Register(defaults.Hedge.Name(), func(unmarshal func(interface{}) error) (Decorator, error) {
+		var conf = NewChainComponent().Settings().Hedge
+		if e := unmarshal(conf); e != nil {
+			return nil, e
+		}
+		return conf.Decorator()
+	})
+	_ = Register(defaults.Quota.Name(), func(unmarshal func(interface{}) error) (Decorator, error) {
+		var conf = NewChainComponent().Settings().Quota
+		if e := unmarshal(conf); e != nil {
+			return nil, e
+		}
+		return conf.Decorator(), nil
+	})
+	_ = Register(defaults.Breaker.Name(), func(unmarshal func(interface{}) error) (Decorator, error) {
+		var conf = NewChainComponent().Settings().Breaker
+		if e := unmarshal(conf); e != nil {
+			return nil, e
+		}
+		return conf.Decorator(), nil
+	})
+}
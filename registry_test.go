@@ -0,0 +1,103 @@
+package transport
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistryRegisterAndBuild(t *testing.T) {
+	var r = NewRegistry()
+	var called bool
+	var constructor = func(unmarshal func(interface{}) error) (Decorator, error) {
+		var conf = struct{ Value string }{}
+		if e := unmarshal(&conf); e != nil {
+			return nil, e
+		}
+		called = conf.Value == "expected"
+		return func(wrapped http.RoundTripper) http.RoundTripper { return wrapped }, nil
+	}
+	require.NoError(t, r.Register("test", constructor))
+	assert.Error(t, r.Register("test", constructor))
+	assert.Equal(t, []string{"test"}, r.Names())
+
+	var ext = ExtensionConfig{Name: "test", Settings: map[string]interface{}{"Value": "expected"}}
+	var d, e = r.Build(ext.Name, ext.Unmarshal)
+	require.NoError(t, e)
+	assert.NotNil(t, d)
+	assert.True(t, called)
+
+	_, e = r.Build("missing", ext.Unmarshal)
+	assert.Error(t, e)
+}
+
+func TestRegistryBuildError(t *testing.T) {
+	var r = NewRegistry()
+	require.NoError(t, r.Register("broken", func(func(interface{}) error) (Decorator, error) {
+		return nil, errors.New("broken")
+	}))
+	var _, e = r.Build("broken", ExtensionConfig{}.Unmarshal)
+	assert.Error(t, e)
+}
+
+func TestDefaultRegistryBuiltins(t *testing.T) {
+	assert.Equal(t, []string{"accesslog", "breaker", "headers", "hedge", "quota", "retry", "retryafter"}, DefaultRegistry.Names())
+
+	var conf = NewChainComponent().Settings()
+	conf.Extensions = []ExtensionConfig{
+		{Name: "headers", Settings: map[string]interface{}{"Values": map[string]string{"x-key": "value"}}},
+		{Name: "retry", Settings: map[string]interface{}{"Limit": 1}},
+		{Name: "quota", Settings: map[string]interface{}{"MaxWait": "1s"}},
+		{Name: "breaker", Settings: map[string]interface{}{"Threshold": 2}},
+	}
+	var chain, e = conf.Chain()
+	require.NoError(t, e)
+	assert.Len(t, chain, 4)
+
+	var seen string
+	var base = RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		seen = r.Header.Get("X-Key")
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	})
+	var req, _ = http.NewRequest(http.MethodGet, "/", nil)
+	_, e = chain.Apply(base).RoundTrip(req)
+	require.NoError(t, e)
+	assert.Equal(t, "value", seen)
+
+	conf.Extensions = []ExtensionConfig{{Name: "missing"}}
+	_, e = conf.Chain()
+	assert.Error(t, e)
+}
+
+func TestExtensionConfigUnmarshal(t *testing.T) {
+	var ext = ExtensionConfig{Name: "retry", Settings: map[string]interface{}{
+		"enabled": "true",
+		"limit":   "2",
+		"codes":   []interface{}{502, 503.0},
+		"timeout": "50ms",
+		"backoff": map[interface{}]interface{}{"type": "exponential", "wait": 1000000, "jitter": .5},
+	}}
+	var conf = &RetryConfig{}
+	require.NoError(t, ext.Unmarshal(conf))
+	assert.Equal(t, &RetryConfig{
+		Enabled: true,
+		Limit:   2,
+		Codes:   []int{502, 503},
+		Timeout: 50 * time.Millisecond,
+		Backoff: &BackoffConfig{Type: "exponential", Wait: time.Millisecond, Jitter: .5},
+	}, conf)
+
+	for _, settings := range []map[string]interface{}{
+		{"timeout": "fast"},
+		{"limit": 1.5},
+		{"codes": "502"},
+		{"backoff": "fixed"},
+	} {
+		assert.Error(t, ExtensionConfig{Name: "retry", Settings: settings}.Unmarshal(&RetryConfig{}), settings)
+	}
+	assert.Error(t, ext.Unmarshal(RetryConfig{}))
+}
@@ -0,0 +1,88 @@
+package transport
+
+import (
+	"net/http"
+	"sync"
+)
+
+// ReloadableChain is a DynamicChain rendered from a ChainConfig that can be
+// given a new configuration while the service is running, so that retry
+// limits, backoff values, quota waits, and circuit breaker thresholds can be
+// tuned without a deploy. Transports produced by the chain pick up the new
+// decorators on their next request.
+//
+// Decorators are rebuilt on every change, but the stateful components of the
+// configuration are kept: the latency recorder of the metrics settings, the
+// CircuitBreaker of the breaker settings, and the QuotaThrottle of the quota
+// settings. The breaker and throttle are given the new settings with their
+// Reconfigure methods so that open circuits and learned quotas survive a
+// change. Decorators built from Extensions hold no such guarantee and start
+// over. Options of the http.Transport, such as MaxIdleConnsPerHost, only
+// apply to transports created after a change.
+type ReloadableChain struct {
+	lock   sync.Mutex
+	chain  *DynamicChain
+	config *ChainConfig
+}
+
+// NewReloadableChain creates a ReloadableChain from the configuration.
+func NewReloadableChain(conf *ChainConfig) (*ReloadableChain, error) {
+	var chain, e = conf.Chain()
+	if e != nil {
+		return nil, e
+	}
+	return &ReloadableChain{chain: NewDynamicChain(chain...), config: conf}, nil
+}
+
+// Reconfigure renders the configuration and atomically replaces the
+// decorators of the chain. The previous decorators remain in use if the
+// configuration is invalid.
+func (c *ReloadableChain) Reconfigure(conf *ChainConfig) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if conf.Metrics != nil && conf.Metrics.recorder == nil && c.config.Metrics != nil {
+		conf.Metrics.recorder = c.config.Metrics.recorder
+	}
+	if conf.Breaker != nil && conf.Breaker.breaker == nil && c.config.Breaker != nil {
+		conf.Breaker.breaker = c.config.Breaker.breaker
+	}
+	if conf.Quota != nil && conf.Quota.throttle == nil && c.config.Quota != nil {
+		conf.Quota.throttle = c.config.Quota.throttle
+	}
+	var chain, e = conf.Chain()
+	if e != nil {
+		return e
+	}
+	if conf.Breaker != nil && conf.Breaker.breaker != nil {
+		conf.Breaker.breaker.Reconfigure(conf.Breaker.options()...)
+	}
+	if conf.Quota != nil && conf.Quota.throttle != nil {
+		conf.Quota.throttle.Reconfigure(conf.Quota.options()...)
+	}
+	c.chain.Swap(chain)
+	c.config = conf
+	return nil
+}
+
+// Config returns the configuration currently in use. It must not be
+// modified; pass a new configuration to Reconfigure instead.
+func (c *ReloadableChain) Config() *ChainConfig {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.config
+}
+
+// Apply wraps the given RoundTripper with the chain. The returned
+// RoundTripper uses the latest configuration for every request.
+func (c *ReloadableChain) Apply(base http.RoundTripper) http.RoundTripper {
+	return c.chain.Apply(base)
+}
+
+// Factory returns a Factory of transports created with the Options of the
+// configuration in use when each one is created and decorated with the
+// chain.
+func (c *ReloadableChain) Factory() Factory {
+	return func() http.RoundTripper {
+		return c.Apply(New(c.Config().Options()...))
+	}
+}
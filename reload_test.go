@@ -0,0 +1,114 @@
+package transport
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newReloadTestConfig(limit int) *ChainConfig {
+	var conf = NewChainComponent().Settings()
+	conf.Metrics.Enabled = true
+	conf.Retry.Enabled = true
+	conf.Retry.Limit = limit
+	conf.Retry.Backoff = &BackoffConfig{Type: BackoffTypeFixed, Wait: time.Millisecond}
+	return conf
+}
+
+func TestReloadableChain(t *testing.T) {
+	var first = newReloadTestConfig(1)
+	var chain, e = NewReloadableChain(first)
+	require.NoError(t, e)
+	var attempts int
+	var rt = chain.Apply(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		attempts = attempts + 1
+		return &http.Response{StatusCode: http.StatusServiceUnavailable, Body: http.NoBody}, nil
+	}))
+	var send = func() int {
+		attempts = 0
+		var req, _ = http.NewRequest(http.MethodGet, "https://api.example.com/", nil)
+		var _, e = rt.RoundTrip(req)
+		require.NoError(t, e)
+		return attempts
+	}
+	assert.Equal(t, 2, send())
+
+	var second = newReloadTestConfig(3)
+	require.NoError(t, chain.Reconfigure(second))
+	assert.Equal(t, 4, send())
+	assert.Equal(t, second, chain.Config())
+	assert.Same(t, first.Metrics.Recorder(), second.Metrics.Recorder(), "recorded latencies were lost")
+
+	var invalid = newReloadTestConfig(5)
+	invalid.Retry.Backoff.Type = "linear"
+	assert.Error(t, chain.Reconfigure(invalid))
+	assert.Equal(t, 4, send(), "invalid configuration was applied")
+	assert.Equal(t, second, chain.Config())
+
+	var created = chain.Factory()()
+	assert.NotNil(t, created)
+
+	_, e = NewReloadableChain(invalid)
+	assert.Error(t, e)
+}
+
+func TestReloadableChainKeepsBreakerAndQuota(t *testing.T) {
+	var newConfig = func(threshold int, maxWait time.Duration) *ChainConfig {
+		var conf = NewChainComponent().Settings()
+		conf.Breaker.Enabled = true
+		conf.Breaker.Threshold = threshold
+		conf.Breaker.OpenDuration = time.Hour
+		conf.Quota.Enabled = true
+		conf.Quota.MaxWait = maxWait
+		return conf
+	}
+	var first = newConfig(1, time.Second)
+	var chain, e = NewReloadableChain(first)
+	require.NoError(t, e)
+	var attempts int
+	var rt = chain.Apply(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		attempts = attempts + 1
+		return &http.Response{
+			StatusCode: http.StatusServiceUnavailable,
+			Header:     http.Header{"X-Ratelimit-Limit": []string{"100"}, "X-Ratelimit-Remaining": []string{"90"}, "X-Ratelimit-Reset": []string{"3600"}},
+			Body:       http.NoBody,
+		}, nil
+	}))
+	var send = func() error {
+		var req, _ = http.NewRequest(http.MethodGet, "https://api.example.com/", nil)
+		var _, e = rt.RoundTrip(req)
+		return e
+	}
+	require.NoError(t, send())
+	var breaker = first.Breaker.Breaker()
+	require.Equal(t, CircuitOpen, breaker.State("api.example.com"))
+	var _, learned = first.Quota.Throttle().Quota("api.example.com")
+	require.True(t, learned)
+
+	var second = newConfig(10, time.Minute)
+	require.NoError(t, chain.Reconfigure(second))
+	assert.Same(t, breaker, second.Breaker.Breaker(), "breaker state was lost")
+	assert.Same(t, first.Quota.Throttle(), second.Quota.Throttle(), "learned quotas were lost")
+	assert.Equal(t, CircuitOpen, breaker.State("api.example.com"), "open circuit was closed by the reload")
+	assert.ErrorIs(t, send(), ErrCircuitOpen)
+	assert.Equal(t, 1, attempts)
+
+	breaker.lock.Lock()
+	assert.Equal(t, 10, breaker.threshold, "new threshold was not applied")
+	breaker.lock.Unlock()
+	var throttle = second.Quota.Throttle()
+	throttle.lock.Lock()
+	assert.Equal(t, time.Minute, throttle.maxWait, "new maximum wait was not applied")
+	throttle.lock.Unlock()
+
+	var invalid = newConfig(20, time.Minute)
+	invalid.Retry.Enabled = true
+	invalid.Retry.Backoff.Type = "linear"
+	assert.Error(t, chain.Reconfigure(invalid))
+	breaker.lock.Lock()
+	assert.Equal(t, 10, breaker.threshold, "invalid configuration was applied")
+	breaker.lock.Unlock()
+}
@@ -0,0 +1,150 @@
+package transport
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"sync"
+	"time"
+)
+
+const certificateRenewalSource = "certificate_renewal"
+
+var errNoCertificate = errors.New("transport: issuer returned no certificate")
+
+// RenewingCertificateSource is a ClientCertificateSource that caches a
+// certificate from an issuer, such as a VaultPKI, and renews it once a share
+// of its lifetime has passed. Handshakes use the cached certificate while a
+// renewal is pending and only wait for the issuer when there is no valid
+// certificate. Run renews certificates in the background so that handshakes
+// rarely wait at all.
+type RenewingCertificateSource struct {
+	issue       func(ctx context.Context) (*tls.Certificate, error)
+	renewAt     float64
+	retry       time.Duration
+	clock       Clock
+	lock        sync.Mutex
+	certificate *tls.Certificate
+	renewAfter  time.Time
+	expires     time.Time
+}
+
+// RenewingCertificateOption is a configuration for the
+// RenewingCertificateSource.
+type RenewingCertificateOption func(*RenewingCertificateSource) *RenewingCertificateSource
+
+// RenewingCertificateOptionRenewAt sets the share of the lifetime of a
+// certificate, between zero and one, after which it is renewed. The default
+// is two thirds.
+func RenewingCertificateOptionRenewAt(fraction float64) RenewingCertificateOption {
+	return func(s *RenewingCertificateSource) *RenewingCertificateSource {
+		s.renewAt = fraction
+		return s
+	}
+}
+
+// RenewingCertificateOptionRetry sets how long Run waits before trying again
+// after a failed renewal. The default is 10 seconds.
+func RenewingCertificateOptionRetry(retry time.Duration) RenewingCertificateOption {
+	return func(s *RenewingCertificateSource) *RenewingCertificateSource {
+		s.retry = retry
+		return s
+	}
+}
+
+// RenewingCertificateOptionClock configures the Clock used to schedule
+// renewals.
+func RenewingCertificateOptionClock(clock Clock) RenewingCertificateOption {
+	return func(s *RenewingCertificateSource) *RenewingCertificateSource {
+		s.clock = clock
+		return s
+	}
+}
+
+// NewRenewingCertificateSource creates a RenewingCertificateSource that
+// obtains certificates from the issuer. No certificate is issued until one is
+// first needed or Run is called.
+func NewRenewingCertificateSource(issue func(ctx context.Context) (*tls.Certificate, error), opts ...RenewingCertificateOption) *RenewingCertificateSource {
+	var s = &RenewingCertificateSource{
+		issue:   issue,
+		renewAt: 2.0 / 3.0,
+		retry:   10 * time.Second,
+		clock:   NewSystemClock(),
+	}
+	for _, opt := range opts {
+		s = opt(s)
+	}
+	return s
+}
+
+// ClientCertificate returns the cached certificate, renewing it first if it
+// is due. A failed renewal is reported only if the cached certificate has
+// expired.
+func (s *RenewingCertificateSource) ClientCertificate(ctx context.Context) (*tls.Certificate, error) {
+	var now = s.clock.Now()
+	s.lock.Lock()
+	var certificate, renewAfter, expires = s.certificate, s.renewAfter, s.expires
+	s.lock.Unlock()
+	if certificate != nil && now.Before(renewAfter) {
+		return certificate, nil
+	}
+	var renewed, e = s.Renew(ctx)
+	if e != nil {
+		if certificate != nil && now.Before(expires) {
+			return certificate, nil
+		}
+		return nil, e
+	}
+	return renewed, nil
+}
+
+// Renew issues a new certificate and caches it.
+func (s *RenewingCertificateSource) Renew(ctx context.Context) (*tls.Certificate, error) {
+	var certificate, e = s.issue(ctx)
+	if e != nil {
+		return nil, e
+	}
+	var leaf = certificate.Leaf
+	if leaf == nil && len(certificate.Certificate) < 1 {
+		return nil, errNoCertificate
+	}
+	if leaf == nil {
+		if leaf, e = x509.ParseCertificate(certificate.Certificate[0]); e != nil {
+			return nil, e
+		}
+		certificate.Leaf = leaf
+	}
+	var lifetime = leaf.NotAfter.Sub(leaf.NotBefore)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.certificate = certificate
+	s.expires = leaf.NotAfter
+	s.renewAfter = leaf.NotBefore.Add(time.Duration(float64(lifetime) * s.renewAt))
+	return certificate, nil
+}
+
+// Run renews the certificate whenever it is due until the context is
+// cancelled. Failed renewals are retried on the retry interval.
+func (s *RenewingCertificateSource) Run(ctx context.Context) {
+	defer labelGoroutine(ctx, certificateRenewalSource, "")()
+	var timer = s.clock.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C():
+			var wait = s.retry
+			if _, e := s.Renew(ctx); e == nil {
+				s.lock.Lock()
+				wait = s.renewAfter.Sub(s.clock.Now())
+				s.lock.Unlock()
+			}
+			if wait <= 0 {
+				wait = s.retry
+			}
+			timer.Reset(wait)
+		}
+	}
+}
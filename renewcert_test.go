@@ -0,0 +1,70 @@
+package transport
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenewingCertificateSource(t *testing.T) {
+	var clock = newFakeClock()
+	var leaf, key = newTestCertificate(t, "", time.Hour)
+	clock.now = leaf.NotBefore
+	var issued int
+	var failing bool
+	var source = NewRenewingCertificateSource(func(context.Context) (*tls.Certificate, error) {
+		if failing {
+			return nil, errors.New("vault sealed")
+		}
+		issued = issued + 1
+		return &tls.Certificate{Certificate: [][]byte{leaf.Raw}, PrivateKey: key}, nil
+	}, RenewingCertificateOptionClock(clock), RenewingCertificateOptionRenewAt(.5))
+	var ctx = context.Background()
+
+	var certificate, e = source.ClientCertificate(ctx)
+	require.NoError(t, e)
+	assert.Equal(t, leaf.Raw, certificate.Leaf.Raw)
+	_, _ = source.ClientCertificate(ctx)
+	assert.Equal(t, 1, issued)
+
+	// Half of the lifetime has passed so the certificate is renewed.
+	clock.advance(31 * time.Minute)
+	_, e = source.ClientCertificate(ctx)
+	require.NoError(t, e)
+	assert.Equal(t, 2, issued)
+
+	// A failed renewal keeps the certificate until it expires.
+	failing = true
+	clock.now = leaf.NotAfter.Add(-time.Second)
+	_, e = source.ClientCertificate(ctx)
+	assert.NoError(t, e)
+	clock.advance(time.Second)
+	_, e = source.ClientCertificate(ctx)
+	assert.Error(t, e)
+}
+
+func TestRenewingCertificateSourceRun(t *testing.T) {
+	var ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	var clock = newFakeClock()
+	var leaf, key = newTestCertificate(t, "", time.Hour)
+	clock.now = leaf.NotBefore
+	var issued int
+	var source = NewRenewingCertificateSource(func(context.Context) (*tls.Certificate, error) {
+		issued = issued + 1
+		if issued == 2 {
+			cancel()
+		}
+		return &tls.Certificate{Certificate: [][]byte{leaf.Raw}, PrivateKey: key}, nil
+	}, RenewingCertificateOptionClock(clock), RenewingCertificateOptionRenewAt(.5))
+	source.Run(ctx)
+	assert.GreaterOrEqual(t, issued, 2)
+	var waits = clock.recorded()
+	require.GreaterOrEqual(t, len(waits), 2)
+	assert.InDelta(t, float64(leaf.NotAfter.Sub(leaf.NotBefore)/2), float64(waits[1]), float64(time.Second))
+}
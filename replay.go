@@ -0,0 +1,170 @@
+package transport
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ReplayPolicy determines whether the attempts made by Retry for a request
+// share the replay protection values of the request.
+type ReplayPolicy int
+
+const (
+	// ReplayPolicyRegenerate creates a new nonce and timestamp for every
+	// attempt so that servers that remember nonces accept retries.
+	ReplayPolicyRegenerate ReplayPolicy = iota
+	// ReplayPolicyReuse sends the same nonce and timestamp with every
+	// attempt so that servers can recognize retries as duplicates.
+	ReplayPolicyReuse
+)
+
+// ReplayProtection is a decorator that adds a unique nonce and a timestamp to
+// requests for APIs with anti-replay requirements, optionally with an HMAC
+// that binds the two together. It is installed outside of Retry, which
+// applies the policy to each attempt.
+type ReplayProtection struct {
+	wrapped         http.RoundTripper
+	nonceHeader     string
+	timestampHeader string
+	signatureHeader string
+	key             []byte
+	nonce           func() (string, error)
+	format          func(time.Time) string
+	policy          ReplayPolicy
+	clock           Clock
+}
+
+// ReplayProtectionOption is a configuration for the ReplayProtection
+// decorator.
+type ReplayProtectionOption func(*ReplayProtection) *ReplayProtection
+
+// ReplayProtectionOptionHeaders sets the names of the nonce and timestamp
+// headers. The defaults are X-Nonce and X-Timestamp.
+func ReplayProtectionOptionHeaders(nonce string, timestamp string) ReplayProtectionOption {
+	return func(p *ReplayProtection) *ReplayProtection {
+		p.nonceHeader = nonce
+		p.timestampHeader = timestamp
+		return p
+	}
+}
+
+// ReplayProtectionOptionNonce sets the function that generates nonces. The
+// default is 16 random bytes encoded as hex.
+func ReplayProtectionOptionNonce(nonce func() (string, error)) ReplayProtectionOption {
+	return func(p *ReplayProtection) *ReplayProtection {
+		p.nonce = nonce
+		return p
+	}
+}
+
+// ReplayProtectionOptionTimestampFormat sets the function that formats the
+// timestamp. The default is the decimal number of seconds since the Unix
+// epoch.
+func ReplayProtectionOptionTimestampFormat(format func(time.Time) string) ReplayProtectionOption {
+	return func(p *ReplayProtection) *ReplayProtection {
+		p.format = format
+		return p
+	}
+}
+
+// ReplayProtectionOptionSignature adds a header containing the hex encoded
+// HMAC-SHA256, using the key, of the timestamp and nonce joined by a period.
+func ReplayProtectionOptionSignature(header string, key []byte) ReplayProtectionOption {
+	return func(p *ReplayProtection) *ReplayProtection {
+		p.signatureHeader = header
+		p.key = key
+		return p
+	}
+}
+
+// ReplayProtectionOptionPolicy sets the ReplayPolicy. The default is
+// ReplayPolicyRegenerate.
+func ReplayProtectionOptionPolicy(policy ReplayPolicy) ReplayProtectionOption {
+	return func(p *ReplayProtection) *ReplayProtection {
+		p.policy = policy
+		return p
+	}
+}
+
+// ReplayProtectionOptionClock configures the Clock used for timestamps.
+func ReplayProtectionOptionClock(clock Clock) ReplayProtectionOption {
+	return func(p *ReplayProtection) *ReplayProtection {
+		p.clock = clock
+		return p
+	}
+}
+
+func randomNonce() (string, error) {
+	var b = make([]byte, 16)
+	if _, e := rand.Read(b); e != nil {
+		return "", e
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// stamp sets the replay protection headers of a request that the caller
+// owns.
+func (c *ReplayProtection) stamp(r *http.Request) error {
+	var nonce, e = c.nonce()
+	if e != nil {
+		return e
+	}
+	var timestamp = c.format(c.clock.Now())
+	r.Header.Set(c.nonceHeader, nonce)
+	r.Header.Set(c.timestampHeader, timestamp)
+	if c.signatureHeader != "" {
+		var mac = hmac.New(sha256.New, c.key)
+		_, _ = mac.Write([]byte(timestamp + "." + nonce))
+		r.Header.Set(c.signatureHeader, hex.EncodeToString(mac.Sum(nil)))
+	}
+	return nil
+}
+
+// RoundTrip adds the replay protection headers and calls the wrapped
+// transport.
+func (c *ReplayProtection) RoundTrip(r *http.Request) (*http.Response, error) {
+	var ctx = r.Context()
+	if c.policy == ReplayPolicyRegenerate {
+		var attempts int
+		ctx = withAttemptHook(ctx, func(attempt *http.Request) (*http.Request, error) {
+			// The first attempt uses the values set below.
+			attempts = attempts + 1
+			if attempts == 1 {
+				return attempt, nil
+			}
+			attempt = attempt.Clone(attempt.Context())
+			return attempt, c.stamp(attempt)
+		})
+	}
+	r = r.Clone(ctx)
+	if e := c.stamp(r); e != nil {
+		return nil, e
+	}
+	return c.wrapped.RoundTrip(r)
+}
+
+// NewReplayProtection configures a RoundTripper decorator that adds replay
+// protection headers to requests.
+func NewReplayProtection(opts ...ReplayProtectionOption) func(http.RoundTripper) http.RoundTripper {
+	return func(wrapped http.RoundTripper) http.RoundTripper {
+		var p = &ReplayProtection{
+			wrapped:         wrapped,
+			nonceHeader:     "X-Nonce",
+			timestampHeader: "X-Timestamp",
+			nonce:           randomNonce,
+			format: func(t time.Time) string {
+				return strconv.FormatInt(t.Unix(), 10)
+			},
+			clock: NewSystemClock(),
+		}
+		for _, opt := range opts {
+			p = opt(p)
+		}
+		return p
+	}
+}
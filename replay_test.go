@@ -0,0 +1,86 @@
+package transport
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newReplayTestTransport(opts ...ReplayProtectionOption) (http.RoundTripper, *[]http.Header) {
+	var seen []http.Header
+	var clock = newFakeClock()
+	var nonce int
+	opts = append([]ReplayProtectionOption{
+		ReplayProtectionOptionClock(clock),
+		ReplayProtectionOptionNonce(func() (string, error) {
+			nonce = nonce + 1
+			clock.advance(time.Second)
+			return "nonce-" + strconv.Itoa(nonce), nil
+		}),
+	}, opts...)
+	var chain = Chain{
+		NewReplayProtection(opts...),
+		NewRetrier(NewFixedBackoffPolicy(0), NewLimitedRetryPolicy(2, NewStatusCodeRetryPolicy(http.StatusServiceUnavailable))),
+	}
+	return chain.Apply(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		seen = append(seen, r.Header.Clone())
+		return &http.Response{StatusCode: http.StatusServiceUnavailable, Body: http.NoBody}, nil
+	})), &seen
+}
+
+func TestReplayProtectionRegenerate(t *testing.T) {
+	var key = []byte("secret")
+	var rt, seen = newReplayTestTransport(ReplayProtectionOptionSignature("X-Signature", key))
+	var req, _ = http.NewRequest(http.MethodPost, "https://example.com/", nil)
+	var _, e = rt.RoundTrip(req)
+	require.NoError(t, e)
+	require.Len(t, *seen, 3)
+	for x, header := range *seen {
+		assert.Equal(t, "nonce-"+strconv.Itoa(x+1), header.Get("X-Nonce"))
+		assert.Equal(t, strconv.Itoa(x+1), header.Get("X-Timestamp"))
+		var mac = hmac.New(sha256.New, key)
+		_, _ = mac.Write([]byte(header.Get("X-Timestamp") + "." + header.Get("X-Nonce")))
+		assert.Equal(t, hex.EncodeToString(mac.Sum(nil)), header.Get("X-Signature"))
+	}
+	assert.Empty(t, req.Header.Get("X-Nonce"), "original request was modified")
+}
+
+func TestReplayProtectionReuse(t *testing.T) {
+	var rt, seen = newReplayTestTransport(
+		ReplayProtectionOptionPolicy(ReplayPolicyReuse),
+		ReplayProtectionOptionHeaders("Idempotency-Nonce", "Request-Time"),
+		ReplayProtectionOptionTimestampFormat(func(t time.Time) string {
+			return t.UTC().Format(time.RFC3339)
+		}),
+	)
+	var req, _ = http.NewRequest(http.MethodPost, "https://example.com/", nil)
+	var _, e = rt.RoundTrip(req)
+	require.NoError(t, e)
+	require.Len(t, *seen, 3)
+	for _, header := range *seen {
+		assert.Equal(t, "nonce-1", header.Get("Idempotency-Nonce"))
+		assert.Equal(t, "1970-01-01T00:00:01Z", header.Get("Request-Time"))
+	}
+}
+
+func TestReplayProtectionNonceError(t *testing.T) {
+	var rt = NewReplayProtection(ReplayProtectionOptionNonce(func() (string, error) {
+		return "", errors.New("no entropy")
+	}))(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		t.Fatal("request was sent without a nonce")
+		return nil, nil
+	}))
+	var req, _ = http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	var _, e = rt.RoundTrip(req)
+	assert.EqualError(t, e, "no entropy")
+	var nonce, _ = randomNonce()
+	assert.Len(t, nonce, 32)
+}
@@ -0,0 +1,53 @@
+package transport
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// PlaintextRequestError is returned by the RequireTLS decorator for requests
+// that would have been sent without TLS.
+type PlaintextRequestError struct {
+	// URL is the address of the refused request with credentials removed by
+	// ScrubURLCredentials.
+	URL string
+}
+
+func (e *PlaintextRequestError) Error() string {
+	return fmt.Sprintf("transport: refusing to send a request without TLS to %s", e.URL)
+}
+
+// RequireTLS is a decorator that refuses to send requests over plaintext
+// HTTP so that credentials added by other decorators cannot leak through a
+// misconfigured URL.
+type RequireTLS struct {
+	wrapped    http.RoundTripper
+	exceptions map[string]bool
+}
+
+// RoundTrip returns a *PlaintextRequestError for requests that do not use
+// HTTPS unless the host is an exception. The request body is closed.
+func (c *RequireTLS) RoundTrip(r *http.Request) (*http.Response, error) {
+	if strings.EqualFold(r.URL.Scheme, "https") || c.exceptions[strings.ToLower(r.URL.Hostname())] {
+		return c.wrapped.RoundTrip(r)
+	}
+	if r.Body != nil {
+		_ = r.Body.Close()
+	}
+	var u, _ = ScrubURLCredentials(r.URL)
+	return nil, &PlaintextRequestError{URL: u.String()}
+}
+
+// NewRequireTLS configures a RoundTripper decorator that only sends HTTPS
+// requests. Requests to the exception hosts, such as localhost or 127.0.0.1,
+// may also use plaintext HTTP. Exceptions are host names without a port.
+func NewRequireTLS(exceptions ...string) func(http.RoundTripper) http.RoundTripper {
+	var allowed = make(map[string]bool, len(exceptions))
+	for _, exception := range exceptions {
+		allowed[strings.ToLower(exception)] = true
+	}
+	return func(wrapped http.RoundTripper) http.RoundTripper {
+		return &RequireTLS{wrapped: wrapped, exceptions: allowed}
+	}
+}
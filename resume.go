@@ -0,0 +1,230 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const resumeSource = "resume"
+
+// ResumeDownload is a decorator that recovers from failures while reading
+// the body of a large download. When reading the body fails part way, the
+// request is sent again with a Range header starting at the first byte that
+// was not received and the new response is appended to the stream given to
+// the caller, which only sees the error if the download cannot be resumed.
+//
+// Only 200 responses to GET requests are resumed, and only when the server
+// advertises Accept-Ranges: bytes. The ETag, or Last-Modified time, of the
+// first response is sent in If-Range so that a resource which changed is
+// never stitched together from two versions. Responses that the transport
+// decompressed are not resumed because their offsets do not match those of
+// the content the server sent.
+type ResumeDownload struct {
+	wrapped    http.RoundTripper
+	maxResumes int
+	minSize    int64
+}
+
+// ResumeDownloadOption is a configuration for the ResumeDownload decorator.
+type ResumeDownloadOption func(*ResumeDownload) *ResumeDownload
+
+// ResumeDownloadOptionMaxResumes sets the number of times a single download
+// may be resumed. The default is 3.
+func ResumeDownloadOptionMaxResumes(max int) ResumeDownloadOption {
+	return func(r *ResumeDownload) *ResumeDownload {
+		r.maxResumes = max
+		return r
+	}
+}
+
+// ResumeDownloadOptionMinSize limits resuming to responses with a
+// Content-Length of at least size bytes. Responses of unknown length are
+// resumed only when the size is zero, which is the default.
+func ResumeDownloadOptionMinSize(size int64) ResumeDownloadOption {
+	return func(r *ResumeDownload) *ResumeDownload {
+		r.minSize = size
+		return r
+	}
+}
+
+// RoundTrip sends the request and, for resumable responses, replaces the
+// body with one that resumes the download when reading fails.
+func (c *ResumeDownload) RoundTrip(r *http.Request) (*http.Response, error) {
+	var resp, e = c.wrapped.RoundTrip(r)
+	if e != nil || !c.resumable(r, resp) {
+		return resp, e
+	}
+	var validator = resp.Header.Get("ETag")
+	if validator == "" || strings.HasPrefix(validator, "W/") {
+		validator = resp.Header.Get("Last-Modified")
+	}
+	var body = &resumingBody{
+		ctx:       r.Context(),
+		wrapped:   c.wrapped,
+		request:   r,
+		body:      resp.Body,
+		length:    resp.ContentLength,
+		validator: validator,
+		remaining: c.maxResumes,
+	}
+	return withBody(resp, body), nil
+}
+
+func (c *ResumeDownload) resumable(r *http.Request, resp *http.Response) bool {
+	if r.Method != http.MethodGet || r.Header.Get("Range") != "" || c.maxResumes < 1 {
+		return false
+	}
+	if resp.StatusCode != http.StatusOK || resp.Uncompressed || resp.Body == nil || resp.Body == http.NoBody {
+		return false
+	}
+	if !strings.EqualFold(strings.TrimSpace(resp.Header.Get("Accept-Ranges")), "bytes") {
+		return false
+	}
+	if c.minSize > 0 && resp.ContentLength < c.minSize {
+		return false
+	}
+	return !IsStreamingResponse(resp)
+}
+
+// resumingBody reads the body of a download and, when a read fails, replaces
+// the underlying body with the remainder of the content.
+type resumingBody struct {
+	lock      sync.Mutex
+	ctx       context.Context
+	wrapped   http.RoundTripper
+	request   *http.Request
+	body      io.ReadCloser
+	offset    int64
+	length    int64
+	validator string
+	remaining int
+	resumes   int
+	closed    bool
+}
+
+func (b *resumingBody) Read(p []byte) (int, error) {
+	var n, e = b.current().Read(p)
+	b.offset = b.offset + int64(n)
+	if e == nil || e == io.EOF {
+		return n, e
+	}
+	if b.length >= 0 && b.offset >= b.length {
+		return n, io.EOF
+	}
+	for b.remaining > 0 && b.ctx.Err() == nil && !b.isClosed() {
+		b.remaining = b.remaining - 1
+		b.resumes = b.resumes + 1
+		var body, resumeErr = b.resume()
+		emitEvent(b.ctx, TransportEvent{
+			Type:    EventDownloadResumed,
+			Source:  resumeSource,
+			Request: b.request,
+			Err:     e,
+			Attempt: b.resumes,
+		})
+		if resumeErr != nil {
+			continue
+		}
+		if !b.replace(body) {
+			break
+		}
+		if n > 0 {
+			return n, nil
+		}
+		return b.Read(p)
+	}
+	return n, e
+}
+
+// current returns the body being read. Reads are never concurrent but Close
+// may be called while a read is blocked, so the body is swapped under the
+// lock rather than held for the duration of a read.
+func (b *resumingBody) current() io.ReadCloser {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.body
+}
+
+func (b *resumingBody) isClosed() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.closed
+}
+
+// replace swaps in the body of a resumed response and closes the failed one.
+// It reports false, and closes the new body, if the caller closed the
+// download while it was being resumed.
+func (b *resumingBody) replace(body io.ReadCloser) bool {
+	b.lock.Lock()
+	if b.closed {
+		b.lock.Unlock()
+		_ = body.Close()
+		return false
+	}
+	var previous = b.body
+	b.body = body
+	b.lock.Unlock()
+	_ = previous.Close()
+	return true
+}
+
+// resume requests the content that follows the offset.
+func (b *resumingBody) resume() (io.ReadCloser, error) {
+	var req = b.request.Clone(b.ctx)
+	req.Header.Set("Range", "bytes="+strconv.FormatInt(b.offset, 10)+"-")
+	if b.validator != "" {
+		req.Header.Set("If-Range", b.validator)
+	}
+	var resp, e = b.wrapped.RoundTrip(req)
+	if e != nil {
+		return nil, e
+	}
+	if resp.StatusCode != http.StatusPartialContent || !b.validRange(resp.Header.Get("Content-Range")) {
+		drainBody(resp)
+		return nil, fmt.Errorf("transport: download could not be resumed at byte %d: status %d", b.offset, resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+// validRange reports whether a Content-Range header starts at the offset and
+// describes the same content length as the first response.
+func (b *resumingBody) validRange(contentRange string) bool {
+	var spec, found = strings.CutPrefix(contentRange, "bytes ")
+	if !found {
+		return false
+	}
+	var span, total, _ = strings.Cut(spec, "/")
+	var first, _, _ = strings.Cut(span, "-")
+	var start, e = strconv.ParseInt(first, 10, 64)
+	if e != nil || start != b.offset {
+		return false
+	}
+	return b.length < 0 || total == "*" || total == strconv.FormatInt(b.length, 10)
+}
+
+func (b *resumingBody) Close() error {
+	b.lock.Lock()
+	b.closed = true
+	var body = b.body
+	b.lock.Unlock()
+	return body.Close()
+}
+
+// NewResumeDownload configures a RoundTripper decorator that resumes
+// downloads whose body fails part way through. The range requests are sent
+// to the wrapped transport, so installing it outside of a retry decorator
+// lets them be retried as well.
+func NewResumeDownload(opts ...ResumeDownloadOption) func(http.RoundTripper) http.RoundTripper {
+	return func(wrapped http.RoundTripper) http.RoundTripper {
+		var r = &ResumeDownload{wrapped: wrapped, maxResumes: 3}
+		for _, opt := range opts {
+			r = opt(r)
+		}
+		return r
+	}
+}
@@ -0,0 +1,121 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const resumeContent = "0123456789abcdefghij"
+
+// newFlakyDownload returns a transport that serves resumeContent but fails
+// after sending chunk bytes of every response.
+func newFlakyDownload(chunk int, header http.Header, ranges *[]string) http.RoundTripper {
+	return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		var status = http.StatusOK
+		var start int
+		var responseHeader = header.Clone()
+		if value := r.Header.Get("Range"); value != "" {
+			*ranges = append(*ranges, value+" "+r.Header.Get("If-Range"))
+			start, _ = strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(value, "bytes="), "-"))
+			status = http.StatusPartialContent
+			responseHeader.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, len(resumeContent)-1, len(resumeContent)))
+		}
+		var end = start + chunk
+		var body io.Reader = strings.NewReader(resumeContent[start:])
+		if end < len(resumeContent) {
+			body = io.MultiReader(strings.NewReader(resumeContent[start:end]), readerFunc(func([]byte) (int, error) {
+				return 0, syscall.ECONNRESET
+			}))
+		}
+		return &http.Response{
+			StatusCode:    status,
+			Header:        responseHeader,
+			Body:          io.NopCloser(body),
+			ContentLength: int64(len(resumeContent) - start),
+			Request:       r,
+		}, nil
+	})
+}
+
+func TestResumeDownload(t *testing.T) {
+	var ranges []string
+	var header = http.Header{"Accept-Ranges": []string{"bytes"}, "Etag": []string{`"v1"`}}
+	var rt = NewResumeDownload()(newFlakyDownload(8, header, &ranges))
+	var collector = &eventCollector{}
+	var req, _ = http.NewRequestWithContext(WithEventSubscriber(context.Background(), collector), http.MethodGet, "https://example.com/file", nil)
+	var resp, e = rt.RoundTrip(req)
+	require.NoError(t, e)
+	var content []byte
+	content, e = io.ReadAll(resp.Body)
+	require.NoError(t, e)
+	assert.Equal(t, resumeContent, string(content))
+	assert.Equal(t, []string{`bytes=8- "v1"`, `bytes=16- "v1"`}, ranges)
+	assert.Equal(t, []EventType{EventDownloadResumed, EventDownloadResumed}, collector.types())
+	assert.ErrorIs(t, collector.events[0].Err, syscall.ECONNRESET)
+	assert.NoError(t, resp.Body.Close())
+}
+
+func TestResumeDownloadRefused(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		header http.Header
+		opts   []ResumeDownloadOption
+		method string
+		ranges int
+	}{
+		{name: "no ranges", header: http.Header{}},
+		{name: "not get", header: http.Header{"Accept-Ranges": []string{"bytes"}}, method: http.MethodPost},
+		{name: "small", header: http.Header{"Accept-Ranges": []string{"bytes"}}, opts: []ResumeDownloadOption{ResumeDownloadOptionMinSize(1 << 20)}},
+		{name: "exhausted", header: http.Header{"Accept-Ranges": []string{"bytes"}}, opts: []ResumeDownloadOption{ResumeDownloadOptionMaxResumes(1)}, ranges: 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var ranges []string
+			var rt = NewResumeDownload(tc.opts...)(newFlakyDownload(8, tc.header, &ranges))
+			var method = tc.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			var req, _ = http.NewRequest(method, "https://example.com/file", nil)
+			var resp, e = rt.RoundTrip(req)
+			require.NoError(t, e)
+			_, e = io.ReadAll(resp.Body)
+			assert.ErrorIs(t, e, syscall.ECONNRESET)
+			assert.Len(t, ranges, tc.ranges)
+		})
+	}
+}
+
+func TestResumeDownloadChanged(t *testing.T) {
+	var ranges []string
+	var flaky = newFlakyDownload(8, http.Header{"Accept-Ranges": []string{"bytes"}, "Last-Modified": []string{"Mon, 02 Jan 2006 15:04:05 GMT"}}, &ranges)
+	var rt = NewResumeDownload()(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		var resp, e = flaky.RoundTrip(r)
+		if r.Header.Get("Range") != "" {
+			// The resource changed so the server ignores the range.
+			resp.StatusCode = http.StatusOK
+			resp.Header.Del("Content-Range")
+		}
+		return resp, e
+	}))
+	var req, _ = http.NewRequest(http.MethodGet, "https://example.com/file", nil)
+	var resp, e = rt.RoundTrip(req)
+	require.NoError(t, e)
+	var content []byte
+	content, e = io.ReadAll(resp.Body)
+	assert.ErrorIs(t, e, syscall.ECONNRESET)
+	assert.Equal(t, resumeContent[:8], string(content))
+	assert.Equal(t, []string{
+		"bytes=8- Mon, 02 Jan 2006 15:04:05 GMT",
+		"bytes=8- Mon, 02 Jan 2006 15:04:05 GMT",
+		"bytes=8- Mon, 02 Jan 2006 15:04:05 GMT",
+	}, ranges)
+}
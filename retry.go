@@ -3,9 +3,13 @@ package transport
 import (
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"io"
+	"math"
 	"math/rand"
 	"net/http"
+	"sync"
 	"time"
 )
 
@@ -17,13 +21,84 @@ import (
 type requestCopier struct {
 	original *http.Request
 	body     []byte
+	getBody  func() (io.ReadCloser, error)
+}
+
+// copierBuffers holds the scratch buffers used to drain request bodies. The
+// drained content is copied into an exactly sized slice so that the buffer
+// can be returned to the pool immediately rather than being grown repeatedly
+// by io.ReadAll for every request.
+var copierBuffers = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+func readBody(body io.Reader) ([]byte, error) {
+	var buf = copierBuffers.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer copierBuffers.Put(buf)
+	var _, e = buf.ReadFrom(body)
+	var result = make([]byte, buf.Len())
+	copy(result, buf.Bytes())
+	return result, e
+}
+
+// replayBody is a read-only view of the captured body. All copies share the
+// same underlying bytes.
+type replayBody struct {
+	bytes.Reader
+}
+
+func newReplayBody(b []byte) *replayBody {
+	var body = &replayBody{}
+	body.Reset(b)
+	return body
+}
+
+// Close is a no-op.
+func (*replayBody) Close() error {
+	return nil
+}
+
+// errorBody is installed as the request body when a copy cannot produce its
+// own body so that the failure is reported by the transport when the body is
+// read.
+type errorBody struct {
+	err error
+}
+
+func (b errorBody) Read([]byte) (int, error) {
+	return 0, b.err
+}
+
+// Close is a no-op.
+func (errorBody) Close() error {
+	return nil
 }
 
 func newRequestCopier(r *http.Request) (*requestCopier, error) {
+	if r.Body != nil && r.Body != http.NoBody && r.GetBody != nil {
+		// The request is already replayable, as is the case for any
+		// request constructed by http.NewRequest with an in-memory body, so
+		// there is no need to buffer another copy of the content. Every copy
+		// gets a fresh body from GetBody so the original is released now.
+		_ = r.Body.Close()
+		r.Body = nil
+		return &requestCopier{original: r, getBody: r.GetBody}, nil
+	}
+	if source, ok := r.Body.(sourceBody); ok {
+		// A decorator replaced the request without its GetBody function but
+		// the body can still be opened again from its source.
+		_ = r.Body.Close()
+		r.Body = nil
+		r.GetBody = source.source.Open
+		return &requestCopier{original: r, getBody: r.GetBody}, nil
+	}
 	var body []byte
 	var e error
 	if r.Body != nil {
-		body, e = io.ReadAll(r.Body)
+		body, e = readBody(r.Body)
 	}
 	// Setting the request body to nil after capturing it so that it is not
 	// included in the deep copy. This code already manages copying the
@@ -33,15 +108,35 @@ func newRequestCopier(r *http.Request) (*requestCopier, error) {
 }
 
 func (r *requestCopier) Copy() *http.Request {
+	var newRequest, e = r.copyRequest()
+	if e != nil {
+		newRequest.Body = errorBody{err: e}
+	}
+	return newRequest
+}
+
+// copyRequest is a counterpart for Copy that reports a failure to regenerate
+// the body rather than deferring it to the transport that reads the body.
+func (r *requestCopier) copyRequest() (*http.Request, error) {
 	var newRequest = r.original.Clone(r.original.Context())
 	newRequest.Body = nil
-	if r.body != nil {
-		newRequest.Body = io.NopCloser(bytes.NewBuffer(r.body))
-		newRequest.GetBody = func() (io.ReadCloser, error) {
-			return io.NopCloser(bytes.NewBuffer(r.body)), nil
+	if r.getBody != nil {
+		var body, e = r.getBody()
+		if e != nil {
+			return newRequest, e
 		}
+		newRequest.Body = body
+		return newRequest, nil
 	}
-	return newRequest
+	if r.body != nil {
+		newRequest.Body = newReplayBody(r.body)
+		newRequest.GetBody = r.replayBody
+	}
+	return newRequest, nil
+}
+
+func (r *requestCopier) replayBody() (io.ReadCloser, error) {
+	return newReplayBody(r.body), nil
 }
 
 // Retrier determines whether or not the transport will automatically retry
@@ -67,11 +162,30 @@ type Backoffer interface {
 // BackoffPolicy is a factory that generates a Backoffer.
 type BackoffPolicy func() Backoffer
 
+// BackoffStop may be returned by a Backoffer instead of a delay to signal that
+// no more attempts should be made. Decorators that receive it return the
+// outcome of the most recent attempt.
+const BackoffStop time.Duration = -1
+
+// Exhauster can be implemented by a Retrier that stops retrying because it
+// reached a limit rather than because the outcome was acceptable.
+type Exhauster interface {
+	Exhausted() bool
+}
+
+// AttemptLimiter is implemented by Retriers that bound the number of attempts
+// made for a request. RemainingAttempts reports how many attempts, including
+// the next one, the Retrier may still allow.
+type AttemptLimiter interface {
+	RemainingAttempts() int
+}
+
 // LimitedRetrier wraps a series of retry policies in a hard upper limit.
 type LimitedRetrier struct {
-	limit    int
-	attempts int
-	retries  []Retrier
+	limit     int
+	attempts  int
+	exhausted bool
+	retries   []Retrier
 }
 
 // NewLimitedRetryPolicy wraps a series of retry policies in an upper limit.
@@ -104,6 +218,12 @@ func (r *LimitedRetrier) Request(req *http.Request) *http.Request {
 // Once the limit is reached then this method always returns false.
 func (r *LimitedRetrier) Retry(req *http.Request, resp *http.Response, e error) bool {
 	if r.attempts >= r.limit {
+		for _, retry := range r.retries {
+			if retry.Retry(req, resp, e) {
+				r.exhausted = true
+				break
+			}
+		}
 		return false
 	}
 	r.attempts = r.attempts + 1
@@ -115,6 +235,17 @@ func (r *LimitedRetrier) Retry(req *http.Request, resp *http.Response, e error)
 	return false
 }
 
+// Exhausted returns true if the limit was reached while one of the wrapped
+// policies still wanted to retry.
+func (r *LimitedRetrier) Exhausted() bool {
+	return r.exhausted
+}
+
+// RemainingAttempts returns the number of attempts that may still be made.
+func (r *LimitedRetrier) RemainingAttempts() int {
+	return r.limit - r.attempts + 1
+}
+
 // StatusCodeRetrier retries based on HTTP status codes.
 type StatusCodeRetrier struct {
 	codes []int
@@ -157,15 +288,123 @@ func NewTimeoutRetryPolicy(timeout time.Duration) RetryPolicy {
 
 // Retry the request if the context exceeded the deadline.
 func (r *TimeoutRetrier) Retry(req *http.Request, resp *http.Response, e error) bool {
-	return e == context.DeadlineExceeded
+	return errors.Is(e, context.DeadlineExceeded)
 }
 
-// Request adds a timeout to the request context.
+// Request adds a timeout to the request context. The timeout context is
+// released when either the timeout fires or the parent context is canceled,
+// which the Retry decorator does at the end of every attempt.
 func (r *TimeoutRetrier) Request(req *http.Request) *http.Request {
-	var ctx, _ = context.WithTimeout(req.Context(), r.timeout) // nolint
+	var ctx, cancel = context.WithTimeout(req.Context(), r.timeout)
+	context.AfterFunc(ctx, cancel)
 	return req.WithContext(ctx)
 }
 
+// AttemptTimeoutRetrier retries attempts that ended because they exceeded
+// the per-attempt timeout of the Retry decorator.
+type AttemptTimeoutRetrier struct{}
+
+// NewAttemptTimeoutRetryPolicy generates a RetryPolicy that retries attempts
+// ended by the per-attempt timeout set with RetryOptionTimeouts. Unlike the
+// TimeoutRetrier it does not add a timeout of its own and does not retry
+// requests that failed because the overall deadline passed.
+func NewAttemptTimeoutRetryPolicy() RetryPolicy {
+	var retrier = &AttemptTimeoutRetrier{}
+	return func() Retrier {
+		return retrier
+	}
+}
+
+// Retry the request if the attempt ran out of time.
+func (r *AttemptTimeoutRetrier) Retry(req *http.Request, resp *http.Response, e error) bool {
+	return errors.Is(e, ErrAttemptTimeout)
+}
+
+// ErrorRetrier retries requests that failed with an error matching one of a
+// set of targets.
+type ErrorRetrier struct {
+	targets []error
+}
+
+// NewErrorRetryPolicy generates a RetryPolicy that retries when the error of
+// an attempt matches any of the targets with errors.Is, such as
+// ErrServerStatus for the errors of the ErrorFromStatus decorator.
+func NewErrorRetryPolicy(targets ...error) RetryPolicy {
+	var retrier = &ErrorRetrier{targets: targets}
+	return func() Retrier {
+		return retrier
+	}
+}
+
+// Retry the request if the error matches one of the targets.
+func (r *ErrorRetrier) Retry(req *http.Request, resp *http.Response, e error) bool {
+	for _, target := range r.targets {
+		if e != nil && errors.Is(e, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// IdempotentRetrier limits a series of retry policies to requests that are
+// safe to send more than once. These are requests with an idempotent method,
+// such as GET or PUT, and requests that carry an Idempotency-Key or
+// X-Idempotency-Key header, which is the same rule the http.Transport uses
+// when it retries on its own.
+type IdempotentRetrier struct {
+	retries []Retrier
+}
+
+// NewIdempotentRetryPolicy wraps a series of retry policies so that they only
+// retry idempotent requests. Without it, a POST that timed out or failed with
+// a 5xx response after the upstream acted on it could be applied twice.
+func NewIdempotentRetryPolicy(policies ...RetryPolicy) RetryPolicy {
+	return func() Retrier {
+		var retries = make([]Retrier, 0, len(policies))
+		for _, policy := range policies {
+			retries = append(retries, policy())
+		}
+		return &IdempotentRetrier{retries: retries}
+	}
+}
+
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	_, hasKey := req.Header["Idempotency-Key"]
+	if !hasKey {
+		_, hasKey = req.Header["X-Idempotency-Key"]
+	}
+	return hasKey
+}
+
+// Request implements Requester by calling the wrapped Request methods where
+// needed.
+func (r *IdempotentRetrier) Request(req *http.Request) *http.Request {
+	for _, retry := range r.retries {
+		if requester, ok := retry.(Requester); ok {
+			req = requester.Request(req)
+		}
+	}
+	return req
+}
+
+// Retry the request if it is idempotent and one of the wrapped policies
+// would retry it.
+func (r *IdempotentRetrier) Retry(req *http.Request, resp *http.Response, e error) bool {
+	if !isIdempotent(req) {
+		return false
+	}
+	for _, retry := range r.retries {
+		if retry.Retry(req, resp, e) {
+			return true
+		}
+	}
+	return false
+}
+
 // FixedBackoffer signals the client to wait for a static amount of time.
 type FixedBackoffer struct {
 	wait time.Duration
@@ -185,6 +424,11 @@ func (b *FixedBackoffer) Backoff(*http.Request, *http.Response, error) time.Dura
 	return b.wait
 }
 
+// BackoffCtx for a static amount of time.
+func (b *FixedBackoffer) BackoffCtx(context.Context, int, *http.Request, *http.Response, error) time.Duration {
+	return b.wait
+}
+
 // ExponentialBackoffer signals the client to wait for an initial amount of time,
 // doubling every retry
 type ExponentialBackoffer struct {
@@ -206,6 +450,19 @@ func (b *ExponentialBackoffer) Backoff(*http.Request, *http.Response, error) tim
 	return current
 }
 
+// BackoffCtx for the initial amount of time doubled once for each attempt
+// after the first. Unlike Backoff, it does not modify the Backoffer.
+func (b *ExponentialBackoffer) BackoffCtx(_ context.Context, attempt int, _ *http.Request, _ *http.Response, _ error) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	var wait = b.wait
+	for x := 1; x < attempt && wait < math.MaxInt64/2; x = x + 1 {
+		wait = wait * 2
+	}
+	return wait
+}
+
 // PercentJitteredBackoffer adjusts the backoff time by a random amount within
 // N percent of the duration to help with thundering herds.
 type PercentJitteredBackoffer struct {
@@ -214,21 +471,43 @@ type PercentJitteredBackoffer struct {
 	random  func() float64
 }
 
+// JitterOption is a configuration for the PercentJitteredBackoffer.
+type JitterOption func(*PercentJitteredBackoffer) *PercentJitteredBackoffer
+
+// JitterOptionRandSource configures the source of randomness used to compute
+// the jitter. This is primarily useful for making tests reproducible. The
+// source is shared by every Backoffer the policy creates and must not be used
+// elsewhere.
+func JitterOptionRandSource(source *rand.Rand) JitterOption {
+	var random = lockedRandom(source)
+	return func(b *PercentJitteredBackoffer) *PercentJitteredBackoffer {
+		b.random = random
+		return b
+	}
+}
+
 // NewPercentJitteredBackoffPolicy wraps any backoff policy and applies a
 // percentage based jitter to the original policy's value. The percentage float
 // should be between 0 and 1. The jitter will be applied as a positive and
 // negative value equally.
-func NewPercentJitteredBackoffPolicy(wrapped BackoffPolicy, jitterPercent float64) BackoffPolicy {
+func NewPercentJitteredBackoffPolicy(wrapped BackoffPolicy, jitterPercent float64, opts ...JitterOption) BackoffPolicy {
 	return func() Backoffer {
-		return &PercentJitteredBackoffer{
+		var b = &PercentJitteredBackoffer{
 			wrapped: wrapped(),
 			jitter:  jitterPercent,
 			random:  rand.Float64,
 		}
+		for _, opt := range opts {
+			b = opt(b)
+		}
+		return b
 	}
 }
 
 func calculateJitteredBackoff(original time.Duration, percentage float64, random func() float64) time.Duration {
+	if original == BackoffStop {
+		return original
+	}
 	var jitterWindow = time.Duration(percentage * float64(original))
 	var jitter = time.Duration(random() * float64(jitterWindow))
 	if random() > .5 {
@@ -243,60 +522,447 @@ func (b *PercentJitteredBackoffer) Backoff(r *http.Request, response *http.Respo
 	return calculateJitteredBackoff(d, b.jitter, b.random)
 }
 
+// BackoffCtx for a jittered amount of the wrapped BackoffCtx value.
+func (b *PercentJitteredBackoffer) BackoffCtx(ctx context.Context, attempt int, r *http.Request, response *http.Response, e error) time.Duration {
+	var d = backoffFor(b.wrapped, ctx, attempt, r, response, e)
+	return calculateJitteredBackoff(d, b.jitter, b.random)
+}
+
+// jitterRandom returns the source of randomness selected by the options.
+func jitterRandom(opts []JitterOption) func() float64 {
+	var b = &PercentJitteredBackoffer{random: rand.Float64}
+	for _, opt := range opts {
+		b = opt(b)
+	}
+	return b.random
+}
+
+// FullJitteredBackoffer waits for a random amount of time between zero and the
+// wrapped duration. This is the "full jitter" strategy described by AWS.
+type FullJitteredBackoffer struct {
+	wrapped Backoffer
+	random  func() float64
+}
+
+// NewFullJitterBackoffPolicy wraps any backoff policy and replaces each delay
+// with a random duration between zero and the original value. Spreading
+// retries over the whole window keeps large fleets from retrying in step,
+// which percent jitter around an exponential curve does not prevent. The
+// JitterOptions of NewPercentJitteredBackoffPolicy are also accepted.
+func NewFullJitterBackoffPolicy(wrapped BackoffPolicy, opts ...JitterOption) BackoffPolicy {
+	var random = jitterRandom(opts)
+	return func() Backoffer {
+		return &FullJitteredBackoffer{wrapped: wrapped(), random: random}
+	}
+}
+
+// Backoff for a random amount up to the wrapped value.
+func (b *FullJitteredBackoffer) Backoff(r *http.Request, response *http.Response, e error) time.Duration {
+	return fullJitter(b.wrapped.Backoff(r, response, e), b.random)
+}
+
+// BackoffCtx for a random amount up to the wrapped BackoffCtx value.
+func (b *FullJitteredBackoffer) BackoffCtx(ctx context.Context, attempt int, r *http.Request, response *http.Response, e error) time.Duration {
+	return fullJitter(backoffFor(b.wrapped, ctx, attempt, r, response, e), b.random)
+}
+
+func fullJitter(original time.Duration, random func() float64) time.Duration {
+	if original == BackoffStop {
+		return original
+	}
+	return time.Duration(random() * float64(original))
+}
+
+// EqualJitteredBackoffer waits for half of the wrapped duration plus a random
+// amount up to the other half. This is the "equal jitter" strategy described
+// by AWS.
+type EqualJitteredBackoffer struct {
+	wrapped Backoffer
+	random  func() float64
+}
+
+// NewEqualJitterBackoffPolicy wraps any backoff policy and replaces each delay
+// with a random duration between half of and the full original value. It
+// trades some of the spread of full jitter for a guaranteed minimum delay.
+// The JitterOptions of NewPercentJitteredBackoffPolicy are also accepted.
+func NewEqualJitterBackoffPolicy(wrapped BackoffPolicy, opts ...JitterOption) BackoffPolicy {
+	var random = jitterRandom(opts)
+	return func() Backoffer {
+		return &EqualJitteredBackoffer{wrapped: wrapped(), random: random}
+	}
+}
+
+// Backoff for half of the wrapped value plus a random amount up to the rest.
+func (b *EqualJitteredBackoffer) Backoff(r *http.Request, response *http.Response, e error) time.Duration {
+	return equalJitter(b.wrapped.Backoff(r, response, e), b.random)
+}
+
+// BackoffCtx for half of the wrapped BackoffCtx value plus a random amount up
+// to the rest.
+func (b *EqualJitteredBackoffer) BackoffCtx(ctx context.Context, attempt int, r *http.Request, response *http.Response, e error) time.Duration {
+	return equalJitter(backoffFor(b.wrapped, ctx, attempt, r, response, e), b.random)
+}
+
+func equalJitter(original time.Duration, random func() float64) time.Duration {
+	if original == BackoffStop {
+		return original
+	}
+	var half = original / 2
+	return half + time.Duration(random()*float64(original-half))
+}
+
+// SleepBudgetBackoffer limits the total time a single request spends waiting
+// between attempts.
+type SleepBudgetBackoffer struct {
+	wrapped Backoffer
+	budget  time.Duration
+	spent   time.Duration
+}
+
+// NewSleepBudgetBackoffPolicy wraps any backoff policy and tracks the delays it
+// returns for each request. Once the next delay would take the total past the
+// budget, BackoffStop is returned instead so that the request stops retrying
+// rather than sleeping past the point where a response is still useful.
+func NewSleepBudgetBackoffPolicy(wrapped BackoffPolicy, budget time.Duration) BackoffPolicy {
+	return func() Backoffer {
+		return &SleepBudgetBackoffer{wrapped: wrapped(), budget: budget}
+	}
+}
+
+// Backoff for the wrapped value while it fits in the remaining budget.
+func (b *SleepBudgetBackoffer) Backoff(r *http.Request, response *http.Response, e error) time.Duration {
+	return b.spend(b.wrapped.Backoff(r, response, e))
+}
+
+// BackoffCtx for the wrapped BackoffCtx value while it fits in the remaining
+// budget.
+func (b *SleepBudgetBackoffer) BackoffCtx(ctx context.Context, attempt int, r *http.Request, response *http.Response, e error) time.Duration {
+	return b.spend(backoffFor(b.wrapped, ctx, attempt, r, response, e))
+}
+
+func (b *SleepBudgetBackoffer) spend(d time.Duration) time.Duration {
+	if d == BackoffStop || b.spent+d > b.budget {
+		return BackoffStop
+	}
+	b.spent = b.spent + d
+	return d
+}
+
+const retrySource = "retry"
+
 // Retry is a wrapper for applying various retry policies to requests.
 type Retry struct {
-	wrapped       http.RoundTripper
-	backoffPolicy BackoffPolicy
-	retryPolicies []RetryPolicy
+	wrapped        http.RoundTripper
+	backoffPolicy  BackoffPolicy
+	retryPolicies  []RetryPolicy
+	exhaustedError bool
+	clock          Clock
+	budget         bool
+	minAttempt     time.Duration
+	expectContinue bool
+	expectMinSize  int64
+	attemptTimeout time.Duration
+	overallTimeout time.Duration
+}
+
+// ErrInsufficientBudget is returned by the Retry decorator when a deadline
+// budget is configured and too little time remains before the request deadline
+// to start the first attempt.
+var ErrInsufficientBudget = fmt.Errorf("transport: insufficient time before the request deadline to start an attempt: %w", context.DeadlineExceeded)
+
+// ErrAttemptTimeout is wrapped by the error of an attempt that exceeded the
+// per-attempt timeout set with RetryOptionTimeouts.
+var ErrAttemptTimeout = fmt.Errorf("transport: attempt timed out: %w", context.DeadlineExceeded)
+
+// RetryOption is a configuration for the Retry decorator.
+type RetryOption func(*Retry) *Retry
+
+// RetryOptionExhaustedError configures the Retry decorator to return a
+// *RetryExhaustedError when a retry limit is reached while the outcome of the
+// final attempt would otherwise have been retried.
+func RetryOptionExhaustedError() RetryOption {
+	return func(r *Retry) *Retry {
+		r.exhaustedError = true
+		return r
+	}
+}
+
+// RetryOptionDeadlineBudget configures the Retry decorator to share the time
+// remaining before the request deadline between attempts. Each attempt is
+// given an even share of the remaining time across the attempts still allowed
+// by any AttemptLimiter, but never less than minAttempt. Attempts that would
+// start with less than minAttempt remaining are not made. When a retry is
+// refused for this reason the outcome of the previous attempt is returned.
+// Requests without a deadline are not affected.
+func RetryOptionDeadlineBudget(minAttempt time.Duration) RetryOption {
+	return func(r *Retry) *Retry {
+		r.budget = true
+		r.minAttempt = minAttempt
+		return r
+	}
+}
+
+// RetryOptionExpectContinue configures the Retry decorator to manage the
+// Expect: 100-continue header of each attempt. Requests with bodies of at
+// least minSize bytes that can be re-read from their source through GetBody
+// are sent with the header so that a server rejecting the request does not
+// cause the whole body to be uploaded on every attempt. Requests whose bodies
+// the decorator must replay from memory have the header removed. The wrapped
+// transport must set ExpectContinueTimeout for the header to have an effect.
+func RetryOptionExpectContinue(minSize int64) RetryOption {
+	return func(r *Retry) *Retry {
+		r.expectContinue = true
+		r.expectMinSize = minSize
+		return r
+	}
+}
+
+// RetryOptionTimeouts limits the time given to each attempt and to the
+// request as a whole, including every attempt and the waits between them.
+// Either may be zero to leave it unlimited. Attempts never run past the
+// overall timeout, or the deadline of the request context if it is earlier,
+// so the final attempt only gets the time that remains. An attempt that runs
+// out of time fails with an error wrapping ErrAttemptTimeout, which
+// NewAttemptTimeoutRetryPolicy retries. Both timeouts also cover reading the
+// body of the response that is returned.
+func RetryOptionTimeouts(attempt time.Duration, overall time.Duration) RetryOption {
+	return func(r *Retry) *Retry {
+		r.attemptTimeout = attempt
+		r.overallTimeout = overall
+		return r
+	}
+}
+
+// RetryOptionClock configures the Clock used to wait between attempts and to
+// measure their durations.
+func RetryOptionClock(clock Clock) RetryOption {
+	return func(r *Retry) *Retry {
+		r.clock = clock
+		return r
+	}
 }
 
 // RoundTrip executes a request and applies one or more retry policies.
 func (c *Retry) RoundTrip(r *http.Request) (*http.Response, error) {
+	if passThrough(r) {
+		return c.wrapped.RoundTrip(r)
+	}
+	if c.overallTimeout <= 0 {
+		return c.roundTrip(r)
+	}
+	var ctx, cancel = context.WithTimeout(r.Context(), c.overallTimeout)
+	var response, e = c.roundTrip(r.WithContext(ctx))
+	if e != nil {
+		cancel()
+		return response, e
+	}
+	return withCancelBody(response, cancel), nil
+}
+
+func (c *Retry) roundTrip(r *http.Request) (*http.Response, error) {
+	if RetrySkipped(r.Context()) {
+		return c.wrapped.RoundTrip(r)
+	}
+	var start = c.clock.Now()
 	var copier, e = newRequestCopier(r)
 	var parentCtx = r.Context()
 	if e != nil {
-		return nil, e
+		return nil, newAttemptError(e, 0, 0, c.clock.Now().Sub(start))
 	}
-	var response *http.Response
-	var requestCtx, cancel = context.WithCancel(parentCtx)
-	var req = copier.Copy().WithContext(requestCtx)
 
 	var retriers = make([]Retrier, 0, len(c.retryPolicies))
 	var backoffer = c.backoffPolicy()
 	for _, retryPolicy := range c.retryPolicies {
 		retriers = append(retriers, retryPolicy())
 	}
+
+	if remaining, ok := c.remainingBudget(parentCtx); ok && !c.budgetAllows(remaining) {
+		return nil, newAttemptError(ErrInsufficientBudget, 0, 0, c.clock.Now().Sub(start))
+	}
+
+	var durations = make([]time.Duration, 0, 1)
+	var response, cancel, err = c.attempt(parentCtx, copier, retriers, &durations)
+	for c.shouldRetry(r, response, err, retriers) {
+		var delay = backoffFor(backoffer, parentCtx, len(durations), r, response, err)
+		if delay == BackoffStop {
+			break
+		}
+		if remaining, ok := c.remainingBudget(parentCtx); ok && !c.budgetAllows(remaining-delay) {
+			// The next attempt could not finish before the deadline so the
+			// current outcome is the best available.
+			break
+		}
+		// Release the previous attempt, and anything a Requester attached to
+		// its context, before waiting on the next one rather than holding
+		// every attempt open until the method returns. The body is drained
+		// first so that its connection can be reused by the next attempt.
+		drainBody(response)
+		cancel()
+		if parentCtx.Err() != nil {
+			return nil, newAttemptError(parentCtx.Err(), len(durations), 0, c.clock.Now().Sub(start))
+		}
+		emitEvent(parentCtx, TransportEvent{
+			Type: EventRetryScheduled, Source: retrySource, Request: r,
+			Response: response, Err: err, Attempt: len(durations), Delay: delay,
+		})
+		var timer = c.clock.NewTimer(delay)
+		select {
+		case <-parentCtx.Done():
+			timer.Stop()
+			return nil, newAttemptError(parentCtx.Err(), len(durations), 0, c.clock.Now().Sub(start))
+		case <-timer.C():
+		}
+		response, cancel, err = c.attempt(parentCtx, copier, retriers, &durations)
+	}
+	if c.exhaustedError && c.exhausted(retriers) {
+		var elapsed = c.clock.Now().Sub(start)
+		var exhaustedErr = newRetryExhaustedError(response, err, durations, elapsed)
+		cancel()
+		return nil, newAttemptError(exhaustedErr, len(durations), 0, elapsed)
+	}
+	if err != nil {
+		cancel()
+		return response, newAttemptError(err, len(durations), 0, c.clock.Now().Sub(start))
+	}
+	return withCancelBody(response, cancel), nil
+}
+
+// attempt issues a single copy of the request using a context that belongs to
+// that attempt alone. The returned cancel function must always be called.
+func (c *Retry) attempt(parentCtx context.Context, copier *requestCopier, retriers []Retrier, durations *[]time.Duration) (*http.Response, context.CancelFunc, error) {
+	var requestCtx, cancel = c.attemptContext(parentCtx, retriers)
+	var req = copier.Copy().WithContext(requestCtx)
+	if c.expectContinue {
+		c.setExpectContinue(req, copier)
+	}
 	for _, retrier := range retriers {
 		if requester, ok := retrier.(Requester); ok {
 			req = requester.Request(req)
 		}
 	}
+	for _, hook := range attemptHooks(parentCtx) {
+		var e error
+		if req, e = hook(req); e != nil {
+			*durations = append(*durations, 0)
+			return nil, cancel, e
+		}
+	}
+	var attempt = len(*durations) + 1
+	Annotate(parentCtx, AnnotationAttempt, attempt)
+	emitEvent(parentCtx, TransportEvent{Type: EventAttemptStarted, Source: retrySource, Request: req, Attempt: attempt})
+	var attemptStart = c.clock.Now()
+	var response, e = c.wrapped.RoundTrip(req)
+	if e != nil && c.attemptTimeout > 0 && parentCtx.Err() == nil && errors.Is(requestCtx.Err(), context.DeadlineExceeded) {
+		e = fmt.Errorf("%w: %w", ErrAttemptTimeout, e)
+	}
+	var duration = c.clock.Now().Sub(attemptStart)
+	*durations = append(*durations, duration)
+	emitEvent(parentCtx, TransportEvent{
+		Type: EventAttemptFinished, Source: retrySource, Request: req,
+		Response: response, Err: e, Attempt: attempt, Duration: duration,
+	})
+	return response, cancel, e
+}
 
-	response, e = c.wrapped.RoundTrip(req)
-	for c.shouldRetry(r, response, e, retriers) {
-		select {
-		case <-parentCtx.Done():
-			cancel()
-			return nil, parentCtx.Err()
-		case <-time.After(backoffer.Backoff(r, response, e)):
+type attemptHooksKey struct{}
+
+// withAttemptHook adds a function that Retry applies to the request of every
+// attempt. It allows decorators installed outside of Retry to change a
+// request between attempts. A hook that fails ends the attempt with its error.
+func withAttemptHook(ctx context.Context, hook func(*http.Request) (*http.Request, error)) context.Context {
+	var hooks = attemptHooks(ctx)
+	hooks = append(hooks[:len(hooks):len(hooks)], hook)
+	return context.WithValue(ctx, attemptHooksKey{}, hooks)
+}
+
+func attemptHooks(ctx context.Context) []func(*http.Request) (*http.Request, error) {
+	var hooks, _ = ctx.Value(attemptHooksKey{}).([]func(*http.Request) (*http.Request, error))
+	return hooks
+}
+
+// attemptContext creates the context for a single attempt. When a deadline
+// budget applies, the attempt is limited to its share of the remaining time.
+// The per-attempt timeout, if any, further limits it. Deriving from the parent
+// context ensures no attempt outlives the overall deadline.
+func (c *Retry) attemptContext(parentCtx context.Context, retriers []Retrier) (context.Context, context.CancelFunc) {
+	var timeout = c.attemptTimeout
+	if remaining, ok := c.remainingBudget(parentCtx); ok {
+		var share = remaining / time.Duration(attemptsLeft(retriers))
+		if share < c.minAttempt {
+			share = c.minAttempt
 		}
-		cancel()
-		requestCtx, cancel = context.WithCancel(parentCtx) // nolint
-		var req = copier.Copy().WithContext(requestCtx)
-		for _, retrier := range retriers {
-			if requester, ok := retrier.(Requester); ok {
-				req = requester.Request(req)
+		if timeout <= 0 || share < timeout {
+			timeout = share
+		}
+	}
+	if timeout <= 0 {
+		return context.WithCancel(parentCtx)
+	}
+	return context.WithTimeout(parentCtx, timeout)
+}
+
+func (c *Retry) setExpectContinue(req *http.Request, copier *requestCopier) {
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	if copier.getBody == nil {
+		req.Header.Del("Expect")
+		return
+	}
+	if req.ContentLength > 0 && req.ContentLength >= c.expectMinSize {
+		req.Header.Set("Expect", "100-continue")
+	}
+}
+
+// remainingBudget returns the time left before the request deadline. The
+// boolean is false if no budget applies to the request.
+func (c *Retry) remainingBudget(ctx context.Context) (time.Duration, bool) {
+	if !c.budget {
+		return 0, false
+	}
+	var deadline, ok = ctx.Deadline()
+	if !ok {
+		return 0, false
+	}
+	return deadline.Sub(c.clock.Now()), true
+}
+
+func (c *Retry) budgetAllows(remaining time.Duration) bool {
+	return remaining > 0 && remaining >= c.minAttempt
+}
+
+// attemptsLeft returns the smallest number of remaining attempts reported by
+// the retriers, or one if none of them limit attempts.
+func attemptsLeft(retriers []Retrier) int {
+	var left int
+	for _, retrier := range retriers {
+		if limiter, ok := retrier.(AttemptLimiter); ok {
+			if n := limiter.RemainingAttempts(); left == 0 || n < left {
+				left = n
 			}
 		}
-		response, e = c.wrapped.RoundTrip(req)
 	}
-	if e != nil {
-		cancel()
+	if left < 1 {
+		return 1
 	}
-	return response, e // nolint
+	return left
+}
+
+func (c *Retry) exhausted(retriers []Retrier) bool {
+	for _, retrier := range retriers {
+		if exhauster, ok := retrier.(Exhauster); ok && exhauster.Exhausted() {
+			return true
+		}
+	}
+	return false
 }
 
 func (c *Retry) shouldRetry(r *http.Request, response *http.Response, e error, retriers []Retrier) bool {
+	// Re-issuing a request after a stream has started would replay events
+	// the caller may already have consumed.
+	if IsStreamingResponse(response) {
+		return false
+	}
 	for _, retrier := range retriers {
 		if retrier.Retry(r, response, e) {
 			return true
@@ -308,7 +974,17 @@ func (c *Retry) shouldRetry(r *http.Request, response *http.Response, e error, r
 // NewRetrier configures a RoundTripper decorator to perform some number of
 // retries.
 func NewRetrier(backoffPolicy BackoffPolicy, retryPolicies ...RetryPolicy) func(http.RoundTripper) http.RoundTripper {
+	return NewRetrierWithOptions(backoffPolicy, retryPolicies)
+}
+
+// NewRetrierWithOptions is a counterpart for NewRetrier that accepts
+// additional RetryOptions.
+func NewRetrierWithOptions(backoffPolicy BackoffPolicy, retryPolicies []RetryPolicy, opts ...RetryOption) func(http.RoundTripper) http.RoundTripper {
 	return func(wrapped http.RoundTripper) http.RoundTripper {
-		return &Retry{wrapped: wrapped, backoffPolicy: backoffPolicy, retryPolicies: retryPolicies}
+		var r = &Retry{wrapped: wrapped, backoffPolicy: backoffPolicy, retryPolicies: retryPolicies, clock: NewSystemClock()}
+		for _, opt := range opts {
+			r = opt(r)
+		}
+		return r
 	}
 }
@@ -3,13 +3,16 @@ package transport
 import (
 	"bytes"
 	"context"
+	"errors"
 	"io"
+	"math/rand"
 	"net/http"
 	"testing"
 	"time"
 
 	gomock "github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestRequestCopier(t *testing.T) {
@@ -53,6 +56,58 @@ func TestRequestCopier(t *testing.T) {
 	}
 }
 
+func TestRequestCopierUsesGetBody(t *testing.T) {
+	var bodyContent = "TEST"
+	var request, _ = http.NewRequest(http.MethodPost, "/", bytes.NewBufferString(bodyContent))
+	var calls int
+	var getBody = request.GetBody
+	request.GetBody = func() (io.ReadCloser, error) {
+		calls = calls + 1
+		return getBody()
+	}
+	var copier, e = newRequestCopier(request)
+	if e != nil {
+		t.Fatal(e.Error())
+	}
+	if copier.body != nil {
+		t.Fatal("expected the body to not be buffered")
+	}
+	for x := 0; x < 2; x = x + 1 {
+		var b, _ = io.ReadAll(copier.Copy().Body)
+		if string(b) != bodyContent {
+			t.Fatalf("expected %q but got %q", bodyContent, string(b))
+		}
+	}
+	if calls != 2 {
+		t.Fatalf("expected GetBody to be called twice but got %d", calls)
+	}
+
+	var failure = errors.New("failure")
+	request, _ = http.NewRequest(http.MethodPost, "/", bytes.NewBufferString(bodyContent))
+	request.GetBody = func() (io.ReadCloser, error) {
+		return nil, failure
+	}
+	copier, _ = newRequestCopier(request)
+	var _, readErr = io.ReadAll(copier.Copy().Body)
+	if !errors.Is(readErr, failure) {
+		t.Fatalf("expected the GetBody error but got %v", readErr)
+	}
+}
+
+func BenchmarkRequestCopier(b *testing.B) {
+	var body = bytes.Repeat([]byte("a"), 16*1024)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for x := 0; x < b.N; x = x + 1 {
+		var request, _ = http.NewRequest(http.MethodPost, "/", io.NopCloser(bytes.NewReader(body)))
+		var copier, _ = newRequestCopier(request)
+		for y := 0; y < 3; y = y + 1 {
+			var r = copier.Copy()
+			_, _ = io.Copy(io.Discard, r.Body)
+		}
+	}
+}
+
 func newRoundTripWithLatencyFunc(resp *http.Response, latency time.Duration) func(r *http.Request) (*http.Response, error) {
 	return func(r *http.Request) (*http.Response, error) {
 		resp.Request = r
@@ -275,6 +330,71 @@ func TestJitterCalculation(t *testing.T) {
 	}
 }
 
+func TestFullAndEqualJitter(t *testing.T) {
+	var constant = func(v float64) func() float64 {
+		return func() float64 { return v }
+	}
+	if result := fullJitter(time.Second, constant(0)); result != 0 {
+		t.Fatal(result)
+	}
+	if result := fullJitter(time.Second, constant(.25)); result != 250*time.Millisecond {
+		t.Fatal(result)
+	}
+	if result := equalJitter(time.Second, constant(0)); result != 500*time.Millisecond {
+		t.Fatal(result)
+	}
+	if result := equalJitter(time.Second, constant(.5)); result != 750*time.Millisecond {
+		t.Fatal(result)
+	}
+}
+
+func TestFullAndEqualJitterPolicies(t *testing.T) {
+	var source = rand.New(rand.NewSource(1))
+	var full = NewFullJitterBackoffPolicy(NewExponentialBackoffPolicy(time.Second), JitterOptionRandSource(source))()
+	var equal = NewEqualJitterBackoffPolicy(NewExponentialBackoffPolicy(time.Second), JitterOptionRandSource(source))()
+	for attempt := 1; attempt <= 4; attempt = attempt + 1 {
+		var ceiling = time.Second << (attempt - 1)
+		var f = full.Backoff(nil, nil, nil)
+		if f < 0 || f > ceiling {
+			t.Fatalf("full jitter %s outside of [0, %s]", f, ceiling)
+		}
+		var e = equal.(BackofferCtx).BackoffCtx(context.Background(), attempt, nil, nil, nil)
+		if e < ceiling/2 || e > ceiling {
+			t.Fatalf("equal jitter %s outside of [%s, %s]", e, ceiling/2, ceiling)
+		}
+	}
+}
+
+func TestSleepBudgetBackoffPolicy(t *testing.T) {
+	var backoffer = NewSleepBudgetBackoffPolicy(NewFixedBackoffPolicy(40*time.Millisecond), 100*time.Millisecond)()
+	assert.Equal(t, 40*time.Millisecond, backoffer.Backoff(nil, nil, nil))
+	assert.Equal(t, 40*time.Millisecond, backoffer.Backoff(nil, nil, nil))
+	assert.Equal(t, BackoffStop, backoffer.Backoff(nil, nil, nil))
+
+	var jittered = NewPercentJitteredBackoffPolicy(NewSleepBudgetBackoffPolicy(NewFixedBackoffPolicy(time.Second), 0), .5)()
+	assert.Equal(t, BackoffStop, jittered.Backoff(nil, nil, nil), "jitter did not preserve the stop signal")
+}
+
+func TestRetrySleepBudget(t *testing.T) {
+	var ctrl = gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var clock = newFakeClock()
+	var wrapped = NewMockRoundTripper(ctrl)
+	var rt = NewRetrierWithOptions(
+		NewSleepBudgetBackoffPolicy(NewExponentialBackoffPolicy(10*time.Millisecond), 35*time.Millisecond),
+		[]RetryPolicy{NewStatusCodeRetryPolicy(http.StatusInternalServerError)},
+		RetryOptionClock(clock),
+	)(wrapped)
+	wrapped.EXPECT().RoundTrip(gomock.Any()).Return(&http.Response{StatusCode: http.StatusInternalServerError, Body: http.NoBody}, nil).Times(3)
+
+	var req, _ = http.NewRequest(http.MethodGet, "/", nil)
+	var resp, e = rt.RoundTrip(req)
+	require.NoError(t, e)
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.Equal(t, []time.Duration{10 * time.Millisecond, 20 * time.Millisecond}, clock.recorded())
+}
+
 func TestNewExponentialBackofferPolicy(t *testing.T) {
 	exponentialBackoffPolicy := NewExponentialBackoffPolicy(time.Second)
 	backoffer1 := exponentialBackoffPolicy()
@@ -295,3 +415,231 @@ func TestNewExponentialBackofferPolicy(t *testing.T) {
 	assert.Equal(t, backoffer1DurationRound1, backoffer2DurationRound1)
 
 }
+
+func TestRetryCancelsPreviousAttempts(t *testing.T) {
+	t.Parallel()
+
+	var ctrl = gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var wrapped = NewMockRoundTripper(ctrl)
+	var rt = NewRetrier(
+		NewFixedBackoffPolicy(0),
+		NewStatusCodeRetryPolicy(http.StatusInternalServerError),
+	)(wrapped)
+
+	var contexts []context.Context
+	var capture = func(code int) func(r *http.Request) (*http.Response, error) {
+		return func(r *http.Request) (*http.Response, error) {
+			contexts = append(contexts, r.Context())
+			return &http.Response{StatusCode: code, Body: http.NoBody}, nil
+		}
+	}
+	wrapped.EXPECT().RoundTrip(gomock.Any()).DoAndReturn(capture(http.StatusInternalServerError)).Times(2)
+	wrapped.EXPECT().RoundTrip(gomock.Any()).DoAndReturn(capture(http.StatusOK))
+
+	var req, _ = http.NewRequest(http.MethodGet, "/", nil)
+	var resp, e = rt.RoundTrip(req)
+	if e != nil {
+		t.Fatal(e.Error())
+	}
+	if contexts[0].Err() == nil || contexts[1].Err() == nil {
+		t.Fatal("expected previous attempt contexts to be canceled")
+	}
+	if contexts[2].Err() != nil {
+		t.Fatal("final attempt context canceled before the body was closed")
+	}
+	_ = resp.Body.Close()
+	if contexts[2].Err() == nil {
+		t.Fatal("final attempt context not released when the body was closed")
+	}
+}
+
+func TestLimitedRetrierRemainingAttempts(t *testing.T) {
+	var retrier = NewLimitedRetryPolicy(2, NewStatusCodeRetryPolicy(http.StatusInternalServerError))().(*LimitedRetrier)
+	var resp = &http.Response{StatusCode: http.StatusInternalServerError}
+	assert.Equal(t, 3, retrier.RemainingAttempts())
+	assert.True(t, retrier.Retry(nil, resp, nil))
+	assert.Equal(t, 2, retrier.RemainingAttempts())
+	assert.True(t, retrier.Retry(nil, resp, nil))
+	assert.Equal(t, 1, retrier.RemainingAttempts())
+	assert.False(t, retrier.Retry(nil, resp, nil))
+	assert.Equal(t, 1, retrier.RemainingAttempts())
+}
+
+func TestRetryDeadlineBudgetSharesRemainingTime(t *testing.T) {
+	var timeouts []time.Duration
+	var wrapped = RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		var deadline, ok = r.Context().Deadline()
+		require.True(t, ok)
+		timeouts = append(timeouts, time.Until(deadline))
+		return &http.Response{StatusCode: http.StatusInternalServerError, Body: http.NoBody}, nil
+	})
+	var rt = NewRetrierWithOptions(
+		NewFixedBackoffPolicy(0),
+		[]RetryPolicy{NewLimitedRetryPolicy(3, NewStatusCodeRetryPolicy(http.StatusInternalServerError))},
+		RetryOptionDeadlineBudget(0),
+	)(wrapped)
+	var ctx, cancel = context.WithTimeout(context.Background(), 4*time.Second)
+	defer cancel()
+	var req, _ = http.NewRequestWithContext(ctx, http.MethodGet, "/", nil)
+	var resp, e = rt.RoundTrip(req)
+	require.NoError(t, e)
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	require.Len(t, timeouts, 4)
+	assert.InDelta(t, time.Second, timeouts[0], float64(100*time.Millisecond))
+	assert.InDelta(t, 4*time.Second, timeouts[3], float64(100*time.Millisecond))
+}
+
+func TestRetryDeadlineBudgetRefusesDoomedRetry(t *testing.T) {
+	var calls int
+	var wrapped = RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		calls = calls + 1
+		return &http.Response{StatusCode: http.StatusInternalServerError, Body: http.NoBody}, nil
+	})
+	var rt = NewRetrierWithOptions(
+		NewFixedBackoffPolicy(time.Hour),
+		[]RetryPolicy{NewStatusCodeRetryPolicy(http.StatusInternalServerError)},
+		RetryOptionDeadlineBudget(0),
+	)(wrapped)
+	var ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var req, _ = http.NewRequestWithContext(ctx, http.MethodGet, "/", nil)
+	var resp, e = rt.RoundTrip(req)
+	require.NoError(t, e)
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.Equal(t, 1, calls)
+}
+
+func TestRetryDeadlineBudgetRefusesFirstAttempt(t *testing.T) {
+	var wrapped = RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		t.Fatal("attempt should not have been made")
+		return nil, nil
+	})
+	var rt = NewRetrierWithOptions(
+		NewFixedBackoffPolicy(0),
+		[]RetryPolicy{NewStatusCodeRetryPolicy(http.StatusInternalServerError)},
+		RetryOptionDeadlineBudget(time.Minute),
+	)(wrapped)
+	var ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var req, _ = http.NewRequestWithContext(ctx, http.MethodGet, "/", nil)
+	var _, e = rt.RoundTrip(req)
+	assert.ErrorIs(t, e, ErrInsufficientBudget)
+	assert.ErrorIs(t, e, context.DeadlineExceeded)
+}
+
+func TestRetryOptionTimeouts(t *testing.T) {
+	var deadlines []time.Time
+	var rt = NewRetrierWithOptions(
+		NewFixedBackoffPolicy(0),
+		[]RetryPolicy{NewLimitedRetryPolicy(5, NewAttemptTimeoutRetryPolicy())},
+		RetryOptionTimeouts(50*time.Millisecond, 120*time.Millisecond),
+	)(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		var deadline, _ = r.Context().Deadline()
+		deadlines = append(deadlines, deadline)
+		<-r.Context().Done()
+		return nil, r.Context().Err()
+	}))
+	var req, _ = http.NewRequest(http.MethodGet, "/", nil)
+	var _, e = rt.RoundTrip(req)
+	assert.ErrorIs(t, e, context.DeadlineExceeded)
+	assert.NotErrorIs(t, e, ErrAttemptTimeout, "the overall timeout was reported as an attempt timeout")
+	require.Len(t, deadlines, 3)
+	assert.InDelta(t, 50*time.Millisecond, deadlines[1].Sub(deadlines[0]), float64(20*time.Millisecond))
+	assert.InDelta(t, 70*time.Millisecond, deadlines[2].Sub(deadlines[0]), float64(20*time.Millisecond), "the final attempt outlived the overall timeout")
+}
+
+func TestRetryOptionTimeoutsBody(t *testing.T) {
+	var attempts int
+	var rt = NewRetrierWithOptions(
+		NewFixedBackoffPolicy(0),
+		[]RetryPolicy{NewLimitedRetryPolicy(1, NewAttemptTimeoutRetryPolicy())},
+		RetryOptionTimeouts(20*time.Millisecond, time.Minute),
+	)(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		attempts = attempts + 1
+		if attempts == 1 {
+			<-r.Context().Done()
+			return nil, r.Context().Err()
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(readerFunc(func(p []byte) (int, error) {
+			if e := r.Context().Err(); e != nil {
+				return 0, e
+			}
+			return 0, io.EOF
+		}))}, nil
+	}))
+	var req, _ = http.NewRequest(http.MethodGet, "/", nil)
+	var resp, e = rt.RoundTrip(req)
+	require.NoError(t, e)
+	assert.Equal(t, 2, attempts)
+	var _, readErr = io.ReadAll(resp.Body)
+	assert.NoError(t, readErr, "the overall timeout ended before the body was read")
+	require.NoError(t, resp.Body.Close())
+
+	var failed = NewRetrierWithOptions(
+		NewFixedBackoffPolicy(0),
+		[]RetryPolicy{NewLimitedRetryPolicy(0, NewAttemptTimeoutRetryPolicy())},
+		RetryOptionTimeouts(time.Millisecond, 0),
+	)(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		<-r.Context().Done()
+		return nil, r.Context().Err()
+	}))
+	_, e = failed.RoundTrip(req)
+	assert.ErrorIs(t, e, ErrAttemptTimeout)
+	assert.ErrorIs(t, e, context.DeadlineExceeded)
+}
+
+func TestRetryOptionExpectContinue(t *testing.T) {
+	var expects []string
+	var rt = NewRetrierWithOptions(
+		NewFixedBackoffPolicy(0),
+		[]RetryPolicy{NewLimitedRetryPolicy(1, NewStatusCodeRetryPolicy(http.StatusInternalServerError))},
+		RetryOptionExpectContinue(4),
+	)(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		expects = append(expects, r.Header.Get("Expect"))
+		return &http.Response{StatusCode: http.StatusInternalServerError, Body: http.NoBody}, nil
+	}))
+
+	var req, _ = http.NewRequest(http.MethodPost, "/", bytes.NewBufferString("large"))
+	_, _ = rt.RoundTrip(req)
+	assert.Equal(t, []string{"100-continue", "100-continue"}, expects)
+
+	expects = nil
+	req, _ = http.NewRequest(http.MethodPost, "/", bytes.NewBufferString("abc"))
+	_, _ = rt.RoundTrip(req)
+	assert.Equal(t, []string{"", ""}, expects)
+
+	expects = nil
+	req, _ = http.NewRequest(http.MethodPost, "/", io.NopCloser(bytes.NewBufferString("large")))
+	req.Header.Set("Expect", "100-continue")
+	_, _ = rt.RoundTrip(req)
+	assert.Equal(t, []string{"", ""}, expects)
+}
+
+func TestIdempotentRetryPolicy(t *testing.T) {
+	var policy = NewIdempotentRetryPolicy(NewStatusCodeRetryPolicy(http.StatusBadGateway), NewTimeoutRetryPolicy(time.Second))
+	var resp = &http.Response{StatusCode: http.StatusBadGateway}
+	for _, tc := range []struct {
+		method string
+		header string
+		retry  bool
+	}{
+		{method: http.MethodGet, retry: true},
+		{method: http.MethodPut, retry: true},
+		{method: http.MethodDelete, retry: true},
+		{method: http.MethodPost, retry: false},
+		{method: http.MethodPatch, retry: false},
+		{method: http.MethodPost, header: "Idempotency-Key", retry: true},
+		{method: http.MethodPatch, header: "X-Idempotency-Key", retry: true},
+	} {
+		var req, _ = http.NewRequest(tc.method, "https://example.com/", nil)
+		if tc.header != "" {
+			req.Header.Set(tc.header, "key")
+		}
+		var retrier = policy()
+		assert.Equal(t, tc.retry, retrier.Retry(req, resp, nil), tc.method+" "+tc.header)
+		var _, hasDeadline = retrier.(Requester).Request(req).Context().Deadline()
+		assert.True(t, hasDeadline, "timeout was not applied")
+	}
+}
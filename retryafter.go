@@ -7,38 +7,70 @@ import (
 	"time"
 )
 
+const retryAfterSource = "retryafter"
+
 // RetryAfter determines whether or not the transport will automatically retry
 // a request based on configured behaviors for 429 responses with Retry-After header.
 type RetryAfter struct {
 	wrapped       http.RoundTripper
 	backoffPolicy BackoffPolicy
+	clock         Clock
+}
+
+// RetryAfterOption is a configuration for the RetryAfter decorator.
+type RetryAfterOption func(*RetryAfter) *RetryAfter
+
+// RetryAfterOptionClock configures the Clock used to wait between attempts.
+func RetryAfterOptionClock(clock Clock) RetryAfterOption {
+	return func(r *RetryAfter) *RetryAfter {
+		r.clock = clock
+		return r
+	}
 }
 
 // RoundTrip executes a request and applies one or more retry policies.
 func (c *RetryAfter) RoundTrip(r *http.Request) (*http.Response, error) {
+	if passThrough(r) || RetrySkipped(r.Context()) {
+		return c.wrapped.RoundTrip(r)
+	}
+	var start = c.clock.Now()
 	var copier, e = newRequestCopier(r)
 	var parentCtx = r.Context()
 	if e != nil {
-		return nil, e
+		return nil, newAttemptError(e, 0, 0, c.clock.Now().Sub(start))
 	}
 	var response *http.Response
-	var requestCtx, cancel = context.WithCancel(parentCtx)
-	var req = copier.Copy().WithContext(requestCtx)
-
+	var cancel context.CancelFunc
 	var backoffer = c.backoffPolicy()
 	var retryAfter time.Duration
+	var attempts int
 	for {
+		if attempts > 0 {
+			emitEvent(parentCtx, TransportEvent{
+				Type: EventRetryScheduled, Source: retryAfterSource, Request: r,
+				Response: response, Attempt: attempts, Delay: retryAfter,
+			})
+		}
 		if retryAfter > 0 {
+			var timer = c.clock.NewTimer(retryAfter)
 			select {
 			case <-parentCtx.Done():
-				cancel()
-				return nil, parentCtx.Err()
-			case <-time.After(retryAfter):
+				timer.Stop()
+				return nil, newAttemptError(parentCtx.Err(), attempts, 0, c.clock.Now().Sub(start))
+			case <-timer.C():
 			}
-			requestCtx, cancel = context.WithCancel(parentCtx) // nolint
-			req = copier.Copy().WithContext(requestCtx)
 		}
+		var requestCtx context.Context
+		requestCtx, cancel = context.WithCancel(parentCtx)
+		var req = copier.Copy().WithContext(requestCtx)
+		attempts = attempts + 1
+		emitEvent(parentCtx, TransportEvent{Type: EventAttemptStarted, Source: retryAfterSource, Request: req, Attempt: attempts})
+		var attemptStart = c.clock.Now()
 		response, e = c.wrapped.RoundTrip(req)
+		emitEvent(parentCtx, TransportEvent{
+			Type: EventAttemptFinished, Source: retryAfterSource, Request: req,
+			Response: response, Err: e, Attempt: attempts, Duration: c.clock.Now().Sub(attemptStart),
+		})
 		if e != nil {
 			break
 		}
@@ -47,7 +79,10 @@ func (c *RetryAfter) RoundTrip(r *http.Request) (*http.Response, error) {
 		} else {
 			retryAfterString := response.Header.Get("Retry-After")
 			if retryAfterString == "" {
-				retryAfter = backoffer.Backoff(r, response, e)
+				retryAfter = backoffFor(backoffer, parentCtx, attempts, r, response, e)
+				if retryAfter == BackoffStop {
+					break
+				}
 			} else {
 				var retryAfterInt int
 				var err error
@@ -57,17 +92,26 @@ func (c *RetryAfter) RoundTrip(r *http.Request) (*http.Response, error) {
 				retryAfter = time.Duration(retryAfterInt) * time.Second
 			}
 		}
+		// Release the previous attempt before waiting on the next one. The
+		// body is drained first so that its connection can be reused.
+		drainBody(response)
+		cancel()
 	}
 	if e != nil {
 		cancel()
+		return response, newAttemptError(e, attempts, 0, c.clock.Now().Sub(start))
 	}
-	return response, e // nolint
+	return withCancelBody(response, cancel), nil
 }
 
 // NewRetryAfter configures a RoundTripper decorator to honor a status code 429 response,
 // using the Retry-After header directive when present, or the backoffPolicy if not present.
-func NewRetryAfter() func(http.RoundTripper) http.RoundTripper {
+func NewRetryAfter(opts ...RetryAfterOption) func(http.RoundTripper) http.RoundTripper {
 	return func(wrapped http.RoundTripper) http.RoundTripper {
-		return &RetryAfter{wrapped: wrapped, backoffPolicy: NewExponentialBackoffPolicy(1 * time.Second)}
+		var r = &RetryAfter{wrapped: wrapped, backoffPolicy: NewExponentialBackoffPolicy(1 * time.Second), clock: NewSystemClock()}
+		for _, opt := range opts {
+			r = opt(r)
+		}
+		return r
 	}
 }
@@ -44,7 +44,8 @@ func TestRetryAfter429NoRetryAfter(t *testing.T) {
 	defer ctrl.Finish()
 
 	var wrapped = NewMockRoundTripper(ctrl)
-	var rt = NewRetryAfter()(wrapped)
+	var clock = newFakeClock()
+	var rt = NewRetryAfter(RetryAfterOptionClock(clock))(wrapped)
 
 	var rtFunc429 = func(r *http.Request) (*http.Response, error) {
 		return &http.Response{
@@ -62,9 +63,9 @@ func TestRetryAfter429NoRetryAfter(t *testing.T) {
 	wrapped.EXPECT().RoundTrip(gomock.Any()).DoAndReturn(rtFunc200).Times(1)
 
 	var req, _ = http.NewRequest(http.MethodGet, "/", nil)
-	start := time.Now()
+	start := clock.Now()
 	var resp, e = rt.RoundTrip(req)
-	duration := time.Since(start)
+	duration := clock.Now().Sub(start)
 	if e != nil {
 		t.Fatal(e.Error())
 	}
@@ -85,7 +86,8 @@ func TestRetryAfter429ComboNoRetryAfterAndWithRetryAfter(t *testing.T) {
 	defer ctrl.Finish()
 
 	var wrapped = NewMockRoundTripper(ctrl)
-	var rt = NewRetryAfter()(wrapped)
+	var clock = newFakeClock()
+	var rt = NewRetryAfter(RetryAfterOptionClock(clock))(wrapped)
 
 	var rtFunc429NoRetryAfter = func(r *http.Request) (*http.Response, error) {
 		return &http.Response{
@@ -113,9 +115,9 @@ func TestRetryAfter429ComboNoRetryAfterAndWithRetryAfter(t *testing.T) {
 	wrapped.EXPECT().RoundTrip(gomock.Any()).DoAndReturn(rtFunc200).Times(1)
 
 	var req, _ = http.NewRequest(http.MethodGet, "/", nil)
-	start := time.Now()
+	start := clock.Now()
 	var resp, e = rt.RoundTrip(req)
-	duration := time.Since(start)
+	duration := clock.Now().Sub(start)
 	if e != nil {
 		t.Fatal(e.Error())
 	}
@@ -0,0 +1,55 @@
+package transport
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// RetryExhaustedError is returned by the Retry decorator, when configured with
+// RetryOptionExhaustedError, if a retry limit was reached before the request
+// produced an acceptable outcome.
+type RetryExhaustedError struct {
+	// Response is the response to the final attempt, if any. The body has
+	// already been drained and closed so only the status and headers are
+	// available.
+	Response *http.Response
+	// Err is the error from the final attempt, if any.
+	Err error
+	// Attempts is the total number of attempts made, including the first.
+	Attempts int
+	// Durations contains the time taken by each attempt in order.
+	Durations []time.Duration
+	// Elapsed is the total time spent, including any backoff.
+	Elapsed time.Duration
+}
+
+func newRetryExhaustedError(response *http.Response, e error, durations []time.Duration, elapsed time.Duration) *RetryExhaustedError {
+	if response != nil && response.Body != nil {
+		drainBody(response)
+		response = withBody(response, http.NoBody)
+	}
+	return &RetryExhaustedError{
+		Response:  response,
+		Err:       e,
+		Attempts:  len(durations),
+		Durations: durations,
+		Elapsed:   elapsed,
+	}
+}
+
+func (e *RetryExhaustedError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("retries exhausted after %d attempts in %s: %s", e.Attempts, e.Elapsed, e.Err.Error())
+	}
+	var status = 0
+	if e.Response != nil {
+		status = e.Response.StatusCode
+	}
+	return fmt.Sprintf("retries exhausted after %d attempts in %s: last status %d", e.Attempts, e.Elapsed, status)
+}
+
+// Unwrap returns the error from the final attempt.
+func (e *RetryExhaustedError) Unwrap() error {
+	return e.Err
+}
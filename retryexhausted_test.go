@@ -0,0 +1,93 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryExhaustedErrorStatus(t *testing.T) {
+	t.Parallel()
+
+	var ctrl = gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var wrapped = NewMockRoundTripper(ctrl)
+	var rt = NewRetrierWithOptions(
+		NewFixedBackoffPolicy(0),
+		[]RetryPolicy{NewLimitedRetryPolicy(2, NewStatusCodeRetryPolicy(http.StatusInternalServerError))},
+		RetryOptionExhaustedError(),
+	)(wrapped)
+
+	wrapped.EXPECT().RoundTrip(gomock.Any()).Return(&http.Response{
+		StatusCode: http.StatusInternalServerError,
+		Body:       http.NoBody,
+	}, nil).Times(3)
+
+	var req, _ = http.NewRequest(http.MethodGet, "/", nil)
+	var resp, e = rt.RoundTrip(req)
+	assert.Nil(t, resp)
+	var exhausted *RetryExhaustedError
+	require.True(t, errors.As(e, &exhausted))
+	assert.Equal(t, 3, exhausted.Attempts)
+	assert.Len(t, exhausted.Durations, 3)
+	assert.Equal(t, http.StatusInternalServerError, exhausted.Response.StatusCode)
+	assert.Nil(t, exhausted.Unwrap())
+	assert.NotEmpty(t, exhausted.Error())
+}
+
+func TestRetryExhaustedErrorWrapsCause(t *testing.T) {
+	t.Parallel()
+
+	var ctrl = gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var wrapped = NewMockRoundTripper(ctrl)
+	var rt = NewRetrierWithOptions(
+		NewFixedBackoffPolicy(0),
+		[]RetryPolicy{NewLimitedRetryPolicy(1, NewTimeoutRetryPolicy(0))},
+		RetryOptionExhaustedError(),
+	)(wrapped)
+
+	wrapped.EXPECT().RoundTrip(gomock.Any()).Return(nil, context.DeadlineExceeded).Times(2)
+
+	var req, _ = http.NewRequest(http.MethodGet, "/", nil)
+	var _, e = rt.RoundTrip(req)
+	assert.ErrorIs(t, e, context.DeadlineExceeded)
+	var exhausted *RetryExhaustedError
+	require.True(t, errors.As(e, &exhausted))
+	assert.Equal(t, 2, exhausted.Attempts)
+}
+
+func TestRetryExhaustedErrorNotReturnedOnSuccess(t *testing.T) {
+	t.Parallel()
+
+	var ctrl = gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var wrapped = NewMockRoundTripper(ctrl)
+	var rt = NewRetrierWithOptions(
+		NewFixedBackoffPolicy(0),
+		[]RetryPolicy{NewLimitedRetryPolicy(1, NewStatusCodeRetryPolicy(http.StatusInternalServerError))},
+		RetryOptionExhaustedError(),
+	)(wrapped)
+
+	wrapped.EXPECT().RoundTrip(gomock.Any()).Return(&http.Response{
+		StatusCode: http.StatusInternalServerError,
+		Body:       http.NoBody,
+	}, nil)
+	wrapped.EXPECT().RoundTrip(gomock.Any()).Return(&http.Response{
+		StatusCode: http.StatusOK,
+		Body:       http.NoBody,
+	}, nil)
+
+	var req, _ = http.NewRequest(http.MethodGet, "/", nil)
+	var resp, e = rt.RoundTrip(req)
+	require.NoError(t, e)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
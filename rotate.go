@@ -1,8 +1,11 @@
 package transport
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"sync"
+	"sync/atomic"
 )
 
 // Rotator contains multiple instances of a RoundTripper and rotates through
@@ -10,10 +13,48 @@ import (
 // more than one TCP connection per host is required.
 type Rotator struct {
 	numberOfInstances int
-	currentOffset     int
-	instances         []http.RoundTripper
+	currentOffset     atomic.Uint64
+	instances         []atomic.Pointer[rotatorInstance]
 	factory           Factory
-	lock              *sync.Mutex
+	outliers          *OutlierDetection
+	detectOutliers    bool
+	outlierOpts       []OutlierDetectionOption
+	strategy          rotatorStrategy
+	newStrategy       func(instances int) rotatorStrategy
+}
+
+// rotatorStrategy selects instances in place of the default round-robin.
+type rotatorStrategy interface {
+	// pick returns the offset of the instance for the request, preferring
+	// those for which available returns true, and a function to call once
+	// the request has returned.
+	pick(r *http.Request, available func(offset int) bool) (int, func())
+	// reset forgets what is known about an instance that has been replaced.
+	reset(offset int)
+}
+
+// rotatorExclusionsKey is the context key of the rotatorExclusions of a
+// request.
+type rotatorExclusionsKey struct{}
+
+// rotatorExclusions records the instances of each Rotator that have been
+// given an attempt of a request so that hedges of the request are sent to
+// other instances.
+type rotatorExclusions struct {
+	lock sync.Mutex
+	used map[*Rotator]map[int]bool
+}
+
+// withRotatorExclusions returns a context in which every Rotator sends each
+// attempt of a request to a different instance while it has any left.
+func withRotatorExclusions(ctx context.Context) context.Context {
+	return context.WithValue(ctx, rotatorExclusionsKey{}, &rotatorExclusions{used: make(map[*Rotator]map[int]bool)})
+}
+
+// rotatorInstance holds one member of the rotation so that it can be replaced
+// without locking the request path.
+type rotatorInstance struct {
+	wrapped http.RoundTripper
 }
 
 // RotatorOption is a configuration for the Rotator decorator
@@ -28,25 +69,74 @@ func RotatorOptionInstances(number int) RotatorOption {
 	}
 }
 
+// RotatorOptionOutlierDetection enables OutlierDetection over the instances
+// of the rotator. Ejected instances are skipped by the rotation until their
+// ejection ends or they are replaced, such as by a rolling Recycler.
+func RotatorOptionOutlierDetection(opts ...OutlierDetectionOption) RotatorOption {
+	return func(r *Rotator) *Rotator {
+		r.detectOutliers = true
+		r.outlierOpts = append(r.outlierOpts, opts...)
+		return r
+	}
+}
+
 // NewRotator uses the given factory as a source and generates a number of
 // instances based on the options given. The instances are called in a naive,
 // round-robin manner.
 func NewRotator(factory Factory, opts ...RotatorOption) *Rotator {
-	var r = &Rotator{factory: factory, lock: &sync.Mutex{}}
+	var r = &Rotator{factory: factory}
 	for _, opt := range opts {
 		r = opt(r)
 	}
-	for x := 0; x < r.numberOfInstances; x = x + 1 {
-		r.instances = append(r.instances, r.factory())
-	}
 	// Defensively maintain at least one in the set at all times.
-	if len(r.instances) < 1 {
-		r.instances = append(r.instances, r.factory())
+	if r.numberOfInstances < 1 {
 		r.numberOfInstances = 1
 	}
+	if r.newStrategy != nil {
+		r.strategy = r.newStrategy(r.numberOfInstances)
+	}
+	if r.detectOutliers {
+		r.outliers = newOutlierDetection(r.numberOfInstances, r.outlierOpts...)
+	}
+	r.instances = make([]atomic.Pointer[rotatorInstance], r.numberOfInstances)
+	for x := 0; x < r.numberOfInstances; x = x + 1 {
+		r.replace(x)
+	}
 	return r
 }
 
+// replace swaps the instance at the given offset for a new one from the
+// factory. Requests already using the old instance are unaffected.
+func (c *Rotator) replace(offset int) {
+	c.instances[offset].Store(&rotatorInstance{wrapped: c.factory()})
+	if c.outliers != nil {
+		c.outliers.reset(offset)
+	}
+	if c.strategy != nil {
+		c.strategy.reset(offset)
+	}
+}
+
+// Close closes every instance of the rotation.
+func (c *Rotator) Close(ctx context.Context) error {
+	var errs []error
+	for x := range c.instances {
+		if e := closeTransport(ctx, c.instances[x].Load().wrapped); e != nil {
+			errs = append(errs, e)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Ejected returns the offsets of the instances that outlier detection has
+// removed from the rotation.
+func (c *Rotator) Ejected() []int {
+	if c.outliers == nil {
+		return nil
+	}
+	return c.outliers.Ejected()
+}
+
 // NewRotatorFactory is a counterpart for NewRotator that generates a Factory
 // function for use with other decorators.
 func NewRotatorFactory(factory Factory, opts ...RotatorOption) Factory {
@@ -56,11 +146,82 @@ func NewRotatorFactory(factory Factory, opts ...RotatorOption) Factory {
 }
 
 // RoundTrip round-robins the outgoing requests against all of the internal
-// instances.
+// instances unless another strategy is configured.
 func (c *Rotator) RoundTrip(r *http.Request) (*http.Response, error) {
-	c.lock.Lock()
-	c.currentOffset = (c.currentOffset + 1) % c.numberOfInstances
-	var offset = c.currentOffset
-	c.lock.Unlock()
-	return c.instances[offset].RoundTrip(r)
+	var exclusions *rotatorExclusions
+	if r != nil {
+		exclusions, _ = r.Context().Value(rotatorExclusionsKey{}).(*rotatorExclusions)
+	}
+	if c.outliers == nil && c.strategy == nil && exclusions == nil {
+		var offset = c.currentOffset.Add(1) % uint64(c.numberOfInstances)
+		return c.instances[offset].Load().wrapped.RoundTrip(r)
+	}
+	var selected, done = c.pick(r, exclusions)
+	if c.outliers == nil {
+		defer done()
+		return c.instances[selected].Load().wrapped.RoundTrip(r)
+	}
+	var start = c.outliers.clock.Now()
+	var resp, e = c.instances[selected].Load().wrapped.RoundTrip(r)
+	done()
+	var ctx = context.Background()
+	if r != nil {
+		ctx = r.Context()
+	}
+	c.outliers.observe(ctx, selected, c.outliers.clock.Now().Sub(start), resp, e)
+	return resp, e
+}
+
+// pick selects the instance for a request with the strategy, or the rotation
+// when there is none, skipping ejected instances and those already given to
+// other attempts of the same request.
+func (c *Rotator) pick(r *http.Request, exclusions *rotatorExclusions) (int, func()) {
+	var usable = c.usable
+	if exclusions != nil {
+		exclusions.lock.Lock()
+		defer exclusions.lock.Unlock()
+		var used = exclusions.used[c]
+		var unused = func(offset int) bool {
+			return !used[offset] && c.usable(offset)
+		}
+		// Once every usable instance has an attempt the request is spread
+		// over them again rather than sent to an ejected instance.
+		for x := 0; x < c.numberOfInstances; x = x + 1 {
+			if unused(x) {
+				usable = unused
+				break
+			}
+		}
+	}
+	var selected int
+	var done = func() {}
+	if c.strategy != nil {
+		selected, done = c.strategy.pick(r, usable)
+	} else {
+		selected = c.available(int(c.currentOffset.Add(1)%uint64(c.numberOfInstances)), usable)
+	}
+	if exclusions != nil {
+		if exclusions.used[c] == nil {
+			exclusions.used[c] = make(map[int]bool)
+		}
+		exclusions.used[c][selected] = true
+	}
+	return selected, done
+}
+
+// usable reports whether the instance at the offset is in the rotation.
+func (c *Rotator) usable(offset int) bool {
+	return c.outliers == nil || !c.outliers.ejected(offset, c.outliers.clock.Now())
+}
+
+// available returns the first instance from the offset onwards that is
+// usable.
+func (c *Rotator) available(offset int, usable func(offset int) bool) int {
+	for x := 0; x < c.numberOfInstances; x = x + 1 {
+		var candidate = (offset + x) % c.numberOfInstances
+		if usable(candidate) {
+			return candidate
+		}
+	}
+	return offset
 }
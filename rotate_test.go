@@ -27,11 +27,23 @@ func TestRotatorOptionInstances(t *testing.T) {
 		t.Fatal("did not create the right number of instances")
 	}
 	_, _ = r.RoundTrip(nil)
-	if r.currentOffset != 1 {
+	if r.currentOffset.Load()%uint64(r.numberOfInstances) != 1 {
 		t.Fatal("did not rotate through instances after using")
 	}
 	_, _ = r.RoundTrip(nil)
-	if r.currentOffset != 0 {
+	if r.currentOffset.Load()%uint64(r.numberOfInstances) != 0 {
 		t.Fatal("did not rotate back through the beginning")
 	}
 }
+
+func BenchmarkRotatorParallel(b *testing.B) {
+	var factory = func() http.RoundTripper {
+		return &roundTripperForRotatorTests{v: "string"}
+	}
+	var r = NewRotator(factory, RotatorOptionInstances(4))
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_, _ = r.RoundTrip(nil)
+		}
+	})
+}
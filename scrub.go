@@ -0,0 +1,65 @@
+package transport
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const scrubbedValue = "[REDACTED]"
+
+// sensitiveQueryParameters are the lowercase names of query parameters that
+// carry credentials, including the signatures of presigned cloud storage
+// URLs.
+var sensitiveQueryParameters = map[string]bool{
+	"token":                true,
+	"access_token":         true,
+	"id_token":             true,
+	"refresh_token":        true,
+	"key":                  true,
+	"api_key":              true,
+	"apikey":               true,
+	"secret":               true,
+	"client_secret":        true,
+	"password":             true,
+	"passwd":               true,
+	"auth":                 true,
+	"sig":                  true,
+	"signature":            true,
+	"x-amz-signature":      true,
+	"x-amz-credential":     true,
+	"x-amz-security-token": true,
+	"x-goog-signature":     true,
+	"x-goog-credential":    true,
+}
+
+// jwtPattern matches values that look like JSON Web Tokens whatever the name
+// of the parameter that carries them.
+var jwtPattern = regexp.MustCompile(`^eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*$`)
+
+// ScrubURLCredentials returns a copy of the URL with credentials removed so
+// that it can be logged. User information is removed and the values of query
+// parameters that carry credentials, such as token, key, or the signature of
+// a presigned URL, are replaced with [REDACTED]. The boolean is true if
+// anything was removed.
+func ScrubURLCredentials(u *url.URL) (*url.URL, bool) {
+	var scrubbed = *u
+	var found = scrubbed.User != nil
+	scrubbed.User = nil
+	if scrubbed.RawQuery == "" {
+		return &scrubbed, found
+	}
+	var query = scrubbed.Query()
+	for name, values := range query {
+		for x, value := range values {
+			if sensitiveQueryParameters[strings.ToLower(name)] || jwtPattern.MatchString(value) {
+				values[x] = scrubbedValue
+				found = true
+			}
+		}
+	}
+	if found {
+		scrubbed.RawQuery = query.Encode()
+	}
+	return &scrubbed, found
+}
@@ -0,0 +1,271 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const cachedSecretsSource = "cached_secrets"
+
+// SecretProvider retrieves secret values, such as API keys, by name.
+// Implementations return a new slice on every call that the caller may
+// zero once it is no longer needed.
+type SecretProvider interface {
+	Secret(ctx context.Context, name string) ([]byte, error)
+}
+
+// SecretProviderFunc converts a function to a SecretProvider.
+type SecretProviderFunc func(ctx context.Context, name string) ([]byte, error)
+
+// Secret calls the wrapped function.
+func (f SecretProviderFunc) Secret(ctx context.Context, name string) ([]byte, error) {
+	return f(ctx, name)
+}
+
+// splitSecretName separates a secret name of the form id#field into the
+// identifier of the secret and the field of its JSON document to select.
+func splitSecretName(name string) (string, string) {
+	var id, field, _ = strings.Cut(name, "#")
+	return id, field
+}
+
+// secretField selects a string field from a JSON object.
+func secretField(document []byte, name string, field string) ([]byte, error) {
+	var fields map[string]interface{}
+	if e := json.Unmarshal(document, &fields); e != nil {
+		return nil, fmt.Errorf("transport: secret %s is not a JSON object: %w", name, e)
+	}
+	var value, ok = fields[field].(string)
+	if !ok {
+		return nil, fmt.Errorf("transport: secret %s has no string field %s", name, field)
+	}
+	return []byte(value), nil
+}
+
+// NewAWSSecretsManagerProvider creates a SecretProvider for AWS Secrets
+// Manager. This package does not include an AWS client so the function
+// retrieves the SecretString of a secret by its ID or ARN, usually by calling
+// GetSecretValue of the AWS SDK. Names of the form id#field select a field of
+// secrets that hold JSON objects.
+func NewAWSSecretsManagerProvider(getSecretString func(ctx context.Context, secretID string) (string, error)) SecretProvider {
+	return SecretProviderFunc(func(ctx context.Context, name string) ([]byte, error) {
+		var id, field = splitSecretName(name)
+		var value, e = getSecretString(ctx, id)
+		if e != nil {
+			return nil, e
+		}
+		if field == "" {
+			return []byte(value), nil
+		}
+		return secretField([]byte(value), name, field)
+	})
+}
+
+type cachedSecret struct {
+	value   []byte
+	fetched time.Time
+}
+
+// CachedSecrets is a SecretProvider that keeps the values of another
+// provider in memory so that they are not retrieved for every request.
+// Values are refreshed after a TTL, or by Run on an interval so that rotated
+// secrets are picked up without waiting on the request path. Close zeroes
+// every cached value.
+type CachedSecrets struct {
+	provider SecretProvider
+	ttl      time.Duration
+	clock    Clock
+	lock     sync.Mutex
+	secrets  map[string]*cachedSecret
+	closed   bool
+}
+
+// CachedSecretsOption is a configuration for the CachedSecrets.
+type CachedSecretsOption func(*CachedSecrets) *CachedSecrets
+
+// CachedSecretsOptionTTL sets how long a value is used before it is
+// retrieved again, which is also the interval on which Run refreshes values.
+// The default is 5 minutes.
+func CachedSecretsOptionTTL(ttl time.Duration) CachedSecretsOption {
+	return func(c *CachedSecrets) *CachedSecrets {
+		c.ttl = ttl
+		return c
+	}
+}
+
+// CachedSecretsOptionClock configures the Clock used to expire values.
+func CachedSecretsOptionClock(clock Clock) CachedSecretsOption {
+	return func(c *CachedSecrets) *CachedSecrets {
+		c.clock = clock
+		return c
+	}
+}
+
+// NewCachedSecrets creates an empty CachedSecrets over the provider.
+func NewCachedSecrets(provider SecretProvider, opts ...CachedSecretsOption) *CachedSecrets {
+	var c = &CachedSecrets{
+		provider: provider,
+		ttl:      5 * time.Minute,
+		clock:    NewSystemClock(),
+		secrets:  make(map[string]*cachedSecret),
+	}
+	for _, opt := range opts {
+		c = opt(c)
+	}
+	return c
+}
+
+// ErrSecretsClosed is returned by CachedSecrets after Close.
+var ErrSecretsClosed = errors.New("transport: secrets have been closed")
+
+// Secret returns a copy of the cached value for the name, retrieving it if
+// it is missing or older than the TTL. A failure to refresh a value is
+// reported rather than returning the stale value.
+func (c *CachedSecrets) Secret(ctx context.Context, name string) ([]byte, error) {
+	var now = c.clock.Now()
+	c.lock.Lock()
+	if c.closed {
+		c.lock.Unlock()
+		return nil, ErrSecretsClosed
+	}
+	if cached, ok := c.secrets[name]; ok && now.Sub(cached.fetched) < c.ttl {
+		var value = append([]byte(nil), cached.value...)
+		c.lock.Unlock()
+		return value, nil
+	}
+	c.lock.Unlock()
+	return c.refresh(ctx, name)
+}
+
+// refresh retrieves the value for the name and replaces the cached one.
+func (c *CachedSecrets) refresh(ctx context.Context, name string) ([]byte, error) {
+	var value, e = c.provider.Secret(ctx, name)
+	if e != nil {
+		return nil, e
+	}
+	var now = c.clock.Now()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.closed {
+		zero(value)
+		return nil, ErrSecretsClosed
+	}
+	if previous, ok := c.secrets[name]; ok {
+		zero(previous.value)
+	}
+	c.secrets[name] = &cachedSecret{value: value, fetched: now}
+	return append([]byte(nil), value...), nil
+}
+
+// Names returns the name of every cached secret in sorted order.
+func (c *CachedSecrets) Names() []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	var names = make([]string, 0, len(c.secrets))
+	for name := range c.secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Run refreshes every cached secret on the TTL interval until the context is
+// cancelled. Failed refreshes leave the cached value in place until it is
+// requested after its TTL.
+func (c *CachedSecrets) Run(ctx context.Context) {
+	defer labelGoroutine(ctx, cachedSecretsSource, "")()
+	var timer = c.clock.NewTimer(c.ttl)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C():
+			for _, name := range c.Names() {
+				if value, e := c.refresh(ctx, name); e == nil {
+					zero(value)
+				}
+			}
+			timer.Reset(c.ttl)
+		}
+	}
+}
+
+// Close zeroes and discards every cached value. Later calls to Secret fail
+// with ErrSecretsClosed.
+func (c *CachedSecrets) Close() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for name, cached := range c.secrets {
+		zero(cached.value)
+		delete(c.secrets, name)
+	}
+	c.closed = true
+	return nil
+}
+
+func zero(b []byte) {
+	for x := range b {
+		b[x] = 0
+	}
+}
+
+// SecretHeader is a decorator that sets a request header to the value of a
+// secret, such as an API key, so that the value never needs to be part of
+// the service configuration.
+type SecretHeader struct {
+	wrapped http.RoundTripper
+	secrets SecretProvider
+	header  string
+	name    string
+	prefix  string
+}
+
+// SecretHeaderOption is a configuration for the SecretHeader decorator.
+type SecretHeaderOption func(*SecretHeader) *SecretHeader
+
+// SecretHeaderOptionPrefix sets text that is placed before the secret in the
+// header value, such as "Bearer ".
+func SecretHeaderOptionPrefix(prefix string) SecretHeaderOption {
+	return func(s *SecretHeader) *SecretHeader {
+		s.prefix = prefix
+		return s
+	}
+}
+
+// RoundTrip sets the header and calls the wrapped transport. Requests fail
+// without being sent, and their bodies are closed, if the secret cannot be
+// retrieved.
+func (c *SecretHeader) RoundTrip(r *http.Request) (*http.Response, error) {
+	var value, e = c.secrets.Secret(r.Context(), c.name)
+	if e != nil {
+		if r.Body != nil {
+			_ = r.Body.Close()
+		}
+		return nil, e
+	}
+	r = r.Clone(r.Context())
+	r.Header.Set(c.header, c.prefix+string(value))
+	zero(value)
+	return c.wrapped.RoundTrip(r)
+}
+
+// NewSecretHeader configures a RoundTripper decorator that sets the header
+// to the value of the named secret. The provider is asked for the secret on
+// every request so it should usually be a CachedSecrets.
+func NewSecretHeader(secrets SecretProvider, header string, name string, opts ...SecretHeaderOption) func(http.RoundTripper) http.RoundTripper {
+	return func(wrapped http.RoundTripper) http.RoundTripper {
+		var s = &SecretHeader{wrapped: wrapped, secrets: secrets, header: header, name: name}
+		for _, opt := range opts {
+			s = opt(s)
+		}
+		return s
+	}
+}
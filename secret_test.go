@@ -0,0 +1,92 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCachedSecrets(t *testing.T) {
+	var clock = newFakeClock()
+	var version = "v1"
+	var fetches int
+	var provider = NewAWSSecretsManagerProvider(func(_ context.Context, id string) (string, error) {
+		fetches = fetches + 1
+		assert.Equal(t, "partner", id)
+		return `{"key":"` + version + `"}`, nil
+	})
+	var secrets = NewCachedSecrets(provider, CachedSecretsOptionClock(clock), CachedSecretsOptionTTL(time.Minute))
+	var ctx = context.Background()
+
+	var value, e = secrets.Secret(ctx, "partner#key")
+	require.NoError(t, e)
+	assert.Equal(t, "v1", string(value))
+	zero(value)
+	value, _ = secrets.Secret(ctx, "partner#key")
+	assert.Equal(t, "v1", string(value), "zeroing a returned value changed the cache")
+	assert.Equal(t, 1, fetches)
+
+	version = "v2"
+	clock.advance(time.Minute)
+	value, _ = secrets.Secret(ctx, "partner#key")
+	assert.Equal(t, "v2", string(value))
+	assert.Equal(t, []string{"partner#key"}, secrets.Names())
+
+	_, e = secrets.Secret(ctx, "partner#missing")
+	assert.Error(t, e)
+
+	require.NoError(t, secrets.Close())
+	assert.Empty(t, secrets.Names())
+	_, e = secrets.Secret(ctx, "partner#key")
+	assert.ErrorIs(t, e, ErrSecretsClosed)
+}
+
+func TestCachedSecretsRun(t *testing.T) {
+	var ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	var fetches int
+	var secrets = NewCachedSecrets(SecretProviderFunc(func(context.Context, string) ([]byte, error) {
+		fetches = fetches + 1
+		if fetches == 3 {
+			cancel()
+		}
+		return []byte("value"), nil
+	}), CachedSecretsOptionClock(newFakeClock()))
+	_, _ = secrets.Secret(ctx, "name")
+	secrets.Run(ctx)
+	assert.GreaterOrEqual(t, fetches, 3)
+}
+
+func TestSecretHeader(t *testing.T) {
+	var failing bool
+	var secrets = SecretProviderFunc(func(context.Context, string) ([]byte, error) {
+		if failing {
+			return nil, errors.New("access denied")
+		}
+		return []byte("token"), nil
+	})
+	var calls int
+	var rt = NewSecretHeader(secrets, "Authorization", "api", SecretHeaderOptionPrefix("Bearer "))(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		calls = calls + 1
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	}))
+	var req, _ = http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	var _, e = rt.RoundTrip(req)
+	require.NoError(t, e)
+	assert.Empty(t, req.Header.Get("Authorization"))
+
+	failing = true
+	var body = &closeRecordingBody{Reader: strings.NewReader("payload")}
+	req, _ = http.NewRequest(http.MethodPost, "https://example.com/", body)
+	_, e = rt.RoundTrip(req)
+	assert.EqualError(t, e, "access denied")
+	assert.Equal(t, 1, calls)
+	assert.True(t, body.closed, "request body was not closed")
+}
@@ -0,0 +1,78 @@
+package transport
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ServerTiming is a single metric reported by an upstream through the
+// Server-Timing response header.
+type ServerTiming struct {
+	Name        string
+	Duration    time.Duration
+	Description string
+}
+
+// ParseServerTiming extracts all metrics from the Server-Timing headers of a
+// response. Metrics without a name are skipped and metrics without a
+// duration are returned with a zero Duration.
+func ParseServerTiming(header http.Header) []ServerTiming {
+	var timings []ServerTiming
+	for _, value := range header.Values("Server-Timing") {
+		for _, metric := range splitQuoted(value, ',') {
+			var params = splitQuoted(metric, ';')
+			var timing = ServerTiming{Name: strings.TrimSpace(params[0])}
+			if timing.Name == "" {
+				continue
+			}
+			for _, param := range params[1:] {
+				var key, val, _ = strings.Cut(param, "=")
+				val = unquote(strings.TrimSpace(val))
+				switch strings.ToLower(strings.TrimSpace(key)) {
+				case "dur":
+					if ms, err := strconv.ParseFloat(val, 64); err == nil {
+						timing.Duration = time.Duration(ms * float64(time.Millisecond))
+					}
+				case "desc":
+					timing.Description = val
+				}
+			}
+			timings = append(timings, timing)
+		}
+	}
+	return timings
+}
+
+// splitQuoted splits the value on the separator except where the separator
+// appears within a quoted string.
+func splitQuoted(value string, sep byte) []string {
+	var parts []string
+	var quoted, escaped bool
+	var start int
+	for x := 0; x < len(value); x = x + 1 {
+		switch {
+		case escaped:
+			escaped = false
+		case quoted && value[x] == '\\':
+			escaped = true
+		case value[x] == '"':
+			quoted = !quoted
+		case !quoted && value[x] == sep:
+			parts = append(parts, value[start:x])
+			start = x + 1
+		}
+	}
+	return append(parts, value[start:])
+}
+
+func unquote(value string) string {
+	if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+		return value
+	}
+	if unquoted, err := strconv.Unquote(value); err == nil {
+		return unquoted
+	}
+	return value[1 : len(value)-1]
+}
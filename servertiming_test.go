@@ -0,0 +1,22 @@
+package transport
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseServerTiming(t *testing.T) {
+	var header = http.Header{}
+	header.Add("Server-Timing", `cache;desc="Cache Read, Warm";dur=23.2, db;dur=53`)
+	header.Add("Server-Timing", `miss, ;dur=1, app;DUR=bad;desc=render`)
+	assert.Equal(t, []ServerTiming{
+		{Name: "cache", Duration: 23200 * time.Microsecond, Description: "Cache Read, Warm"},
+		{Name: "db", Duration: 53 * time.Millisecond},
+		{Name: "miss"},
+		{Name: "app", Description: "render"},
+	}, ParseServerTiming(header))
+	assert.Empty(t, ParseServerTiming(http.Header{}))
+}
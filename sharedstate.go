@@ -0,0 +1,119 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SharedState stores counters that are shared by every replica of a service
+// so that quotas and budgets can be consumed by the fleet as a whole rather
+// than by each replica independently. Implementations must be safe for
+// concurrent use.
+type SharedState interface {
+	// Increment adds delta to the counter stored under key and returns the
+	// new value. A counter that does not exist starts at zero and is removed
+	// once ttl has passed since it was created.
+	Increment(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error)
+}
+
+type memoryCounter struct {
+	value   int64
+	expires time.Time
+}
+
+// MemorySharedState is a SharedState held in process memory. It coordinates
+// the components of a single process and is useful in tests.
+type MemorySharedState struct {
+	lock     sync.Mutex
+	counters map[string]*memoryCounter
+	clock    Clock
+}
+
+// NewMemorySharedState creates an empty MemorySharedState that uses the given
+// Clock to expire counters. A nil Clock uses the system clock.
+func NewMemorySharedState(clock Clock) *MemorySharedState {
+	if clock == nil {
+		clock = NewSystemClock()
+	}
+	return &MemorySharedState{counters: make(map[string]*memoryCounter), clock: clock}
+}
+
+// Increment adds delta to the counter stored under key.
+func (s *MemorySharedState) Increment(_ context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	var now = s.clock.Now()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var counter, ok = s.counters[key]
+	if !ok || !counter.expires.After(now) {
+		counter = &memoryCounter{expires: now.Add(ttl)}
+		s.counters[key] = counter
+	}
+	counter.value = counter.value + delta
+	// Expired counters are only replaced when used again so sweep them here
+	// to bound memory when keys change over time, such as per window.
+	for k, c := range s.counters {
+		if !c.expires.After(now) {
+			delete(s.counters, k)
+		}
+	}
+	return counter.value, nil
+}
+
+// RedisEvaler runs a Lua script on a Redis server. It matches the shape of
+// the Eval method of most Redis clients so that this package does not depend
+// on any one of them. For example, with github.com/redis/go-redis:
+//
+//	transport.RedisEvalerFunc(func(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+//		return client.Eval(ctx, script, keys, args...).Result()
+//	})
+type RedisEvaler interface {
+	Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error)
+}
+
+// RedisEvalerFunc converts a function to a RedisEvaler.
+type RedisEvalerFunc func(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error)
+
+// Eval calls the wrapped function.
+func (f RedisEvalerFunc) Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	return f(ctx, script, keys, args...)
+}
+
+// redisIncrementScript increments a counter and sets its expiry only when the
+// increment created it so that the TTL runs from creation.
+const redisIncrementScript = `local value = redis.call("INCRBY", KEYS[1], ARGV[1])
+if value == tonumber(ARGV[1]) then
+  redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return value`
+
+// RedisSharedState is a SharedState stored in Redis.
+type RedisSharedState struct {
+	client RedisEvaler
+	prefix string
+}
+
+// NewRedisSharedState creates a SharedState that stores counters in Redis
+// using the given client. Every key is prefixed with the given value so that
+// several services may share a Redis server.
+func NewRedisSharedState(client RedisEvaler, prefix string) *RedisSharedState {
+	return &RedisSharedState{client: client, prefix: prefix}
+}
+
+// Increment adds delta to the counter stored under key.
+func (s *RedisSharedState) Increment(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	var millis = ttl.Milliseconds()
+	if millis < 1 {
+		millis = 1
+	}
+	var result, e = s.client.Eval(ctx, redisIncrementScript, []string{s.prefix + key}, delta, millis)
+	if e != nil {
+		return 0, e
+	}
+	var value, ok = result.(int64)
+	if !ok {
+		return 0, fmt.Errorf("transport: unexpected Redis result %T for counter %q", result, key)
+	}
+	return value, nil
+}
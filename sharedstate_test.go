@@ -0,0 +1,78 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemorySharedState(t *testing.T) {
+	var clock = newFakeClock()
+	var state = NewMemorySharedState(clock)
+	var ctx = context.Background()
+
+	var value, e = state.Increment(ctx, "a", 1, time.Second)
+	require.NoError(t, e)
+	assert.Equal(t, int64(1), value)
+	value, _ = state.Increment(ctx, "a", 2, time.Hour)
+	assert.Equal(t, int64(3), value, "the ttl was reset by a later increment")
+	value, _ = state.Increment(ctx, "b", 1, time.Hour)
+	assert.Equal(t, int64(1), value)
+
+	clock.advance(time.Second)
+	value, _ = state.Increment(ctx, "a", 1, time.Second)
+	assert.Equal(t, int64(1), value, "the counter did not expire")
+}
+
+func TestRedisSharedState(t *testing.T) {
+	var gotKeys []string
+	var gotArgs []interface{}
+	var result interface{} = int64(4)
+	var failure error
+	var client = RedisEvalerFunc(func(_ context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+		assert.Equal(t, redisIncrementScript, script)
+		gotKeys = keys
+		gotArgs = args
+		return result, failure
+	})
+	var state = NewRedisSharedState(client, "svc:")
+
+	var value, e = state.Increment(context.Background(), "counter", 2, 1500*time.Millisecond)
+	require.NoError(t, e)
+	assert.Equal(t, int64(4), value)
+	assert.Equal(t, []string{"svc:counter"}, gotKeys)
+	assert.Equal(t, []interface{}{int64(2), int64(1500)}, gotArgs)
+
+	result = "4"
+	_, e = state.Increment(context.Background(), "counter", 1, time.Second)
+	assert.Error(t, e)
+
+	failure = errors.New("connection refused")
+	_, e = state.Increment(context.Background(), "counter", 1, time.Second)
+	assert.Equal(t, failure, e)
+}
+
+func TestQuotaThrottleSharedState(t *testing.T) {
+	var clock = newFakeClock()
+	var shared = NewMemorySharedState(clock)
+	var first = NewQuotaThrottle(QuotaThrottleOptionClock(clock), QuotaThrottleOptionSharedState(shared))
+	var second = NewQuotaThrottle(QuotaThrottleOptionClock(clock), QuotaThrottleOptionSharedState(shared))
+	var req, _ = http.NewRequest(http.MethodGet, "https://api.example.com/", nil)
+	var response = quotaResponse(2, 10*time.Second)
+	response.Header.Set("X-RateLimit-Limit", "2")
+	first.Observe(req, response)
+	second.Observe(req, response)
+
+	require.NoError(t, first.Wait(context.Background(), req))
+	require.NoError(t, second.Wait(context.Background(), req))
+	assert.Empty(t, clock.recorded())
+	// Each replica still has a request left locally but the fleet has used
+	// the whole limit.
+	require.NoError(t, first.Wait(context.Background(), req))
+	assert.Equal(t, []time.Duration{10 * time.Second}, clock.recorded())
+}
@@ -0,0 +1,157 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+)
+
+// ErrTransportClosed is returned for requests sent through a
+// ClosableTransport after Close was called.
+var ErrTransportClosed = errors.New("transport: transport is closed")
+
+// Closer is implemented by decorators and transports that hold resources,
+// such as goroutines or idle connections, that must be released when a
+// service shuts down. Close should return once the resources are released or
+// the context is done.
+type Closer interface {
+	Close(ctx context.Context) error
+}
+
+// CloserFunc converts a function to a Closer.
+type CloserFunc func(ctx context.Context) error
+
+// Close calls the wrapped function.
+func (f CloserFunc) Close(ctx context.Context) error {
+	return f(ctx)
+}
+
+type idleConnectionCloser interface {
+	CloseIdleConnections()
+}
+
+// closeTransport releases the resources of a RoundTripper. Those that do not
+// implement Closer have their idle connections closed if they support it.
+func closeTransport(ctx context.Context, rt http.RoundTripper) error {
+	switch t := rt.(type) {
+	case Closer:
+		return t.Close(ctx)
+	case idleConnectionCloser:
+		t.CloseIdleConnections()
+	}
+	return nil
+}
+
+// ClosableTransport is a decorated transport that can be shut down
+// gracefully. Close refuses new requests, waits for those in flight to
+// finish, and then closes each component in order. A request is in flight
+// until its response body is closed.
+type ClosableTransport struct {
+	wrapped    http.RoundTripper
+	components []http.RoundTripper
+	lock       sync.Mutex
+	closed     bool
+	closers    []Closer
+	inFlight   sync.WaitGroup
+	once       sync.Once
+	err        error
+}
+
+// ApplyClosable wraps the given RoundTripper with the Decorator chain and
+// returns a ClosableTransport that closes, from outermost to innermost, every
+// decorator that implements Closer followed by the base transport.
+func (c Chain) ApplyClosable(base http.RoundTripper) *ClosableTransport {
+	var components = []http.RoundTripper{base}
+	for x := len(c) - 1; x >= 0; x = x - 1 {
+		base = c[x](base)
+		components = append(components, base)
+	}
+	var ordered = make([]http.RoundTripper, 0, len(components))
+	for x := len(components) - 1; x >= 0; x = x - 1 {
+		if !containsTransport(ordered, components[x]) {
+			ordered = append(ordered, components[x])
+		}
+	}
+	return &ClosableTransport{wrapped: base, components: ordered}
+}
+
+// containsTransport reports whether the RoundTripper is already in the list,
+// which happens when a decorator returns the transport it wraps. Transports
+// of types that cannot be compared, such as functions, are never found.
+func containsTransport(list []http.RoundTripper, rt http.RoundTripper) bool {
+	if rt == nil || !reflect.TypeOf(rt).Comparable() {
+		return false
+	}
+	for _, existing := range list {
+		if existing == rt {
+			return true
+		}
+	}
+	return false
+}
+
+// OnClose adds Closers for components outside of the chain, such as a Prober
+// or CachedSecrets, that are closed after the requests in flight finish and
+// before the chain itself, in the order they are added.
+func (t *ClosableTransport) OnClose(closers ...Closer) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.closers = append(t.closers, closers...)
+}
+
+// RoundTrip sends the request through the chain unless the transport is
+// closed.
+func (t *ClosableTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	t.lock.Lock()
+	if t.closed {
+		t.lock.Unlock()
+		return nil, ErrTransportClosed
+	}
+	t.inFlight.Add(1)
+	t.lock.Unlock()
+	var resp, e = t.wrapped.RoundTrip(r)
+	if e != nil {
+		t.inFlight.Done()
+		return resp, e
+	}
+	return withCancelBody(resp, t.inFlight.Done), nil
+}
+
+// Close shuts the transport down. It waits for requests in flight until the
+// context is done and then closes the components regardless. The errors of
+// every step are joined. Calling Close again returns the same result.
+func (t *ClosableTransport) Close(ctx context.Context) error {
+	t.once.Do(func() {
+		t.lock.Lock()
+		t.closed = true
+		var closers = append([]Closer(nil), t.closers...)
+		t.lock.Unlock()
+
+		var errs []error
+		var drained = make(chan struct{})
+		go func() {
+			t.inFlight.Wait()
+			close(drained)
+		}()
+		select {
+		case <-drained:
+		case <-ctx.Done():
+			errs = append(errs, fmt.Errorf("transport: requests still in flight: %w", ctx.Err()))
+		}
+		for _, closer := range closers {
+			if e := closer.Close(ctx); e != nil {
+				errs = append(errs, e)
+			}
+		}
+		for _, component := range t.components {
+			if e := closeTransport(ctx, component); e != nil {
+				errs = append(errs, e)
+			}
+		}
+		t.err = errors.Join(errs...)
+	})
+	return t.err
+}
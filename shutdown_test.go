@@ -0,0 +1,146 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+type closeRecordingTransport struct {
+	name   string
+	closed *[]string
+	err    error
+}
+
+func (c *closeRecordingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(""))}, nil
+}
+
+func (c *closeRecordingTransport) Close(context.Context) error {
+	*c.closed = append(*c.closed, c.name)
+	return c.err
+}
+
+type closeRecordingDecorator struct {
+	closeRecordingTransport
+	wrapped http.RoundTripper
+}
+
+func (c *closeRecordingDecorator) RoundTrip(r *http.Request) (*http.Response, error) {
+	return c.wrapped.RoundTrip(r)
+}
+
+func newCloseRecordingDecorator(name string, closed *[]string) func(http.RoundTripper) http.RoundTripper {
+	return func(wrapped http.RoundTripper) http.RoundTripper {
+		return &closeRecordingDecorator{closeRecordingTransport: closeRecordingTransport{name: name, closed: closed}, wrapped: wrapped}
+	}
+}
+
+func TestClosableTransportOrder(t *testing.T) {
+	var closed []string
+	var passthrough = func(wrapped http.RoundTripper) http.RoundTripper { return wrapped }
+	var transport = Chain{
+		newCloseRecordingDecorator("outer", &closed),
+		passthrough,
+		NewHeaders(nil, nil),
+		newCloseRecordingDecorator("inner", &closed),
+	}.ApplyClosable(&closeRecordingTransport{name: "base", closed: &closed})
+	transport.OnClose(CloserFunc(func(context.Context) error {
+		closed = append(closed, "hook")
+		return nil
+	}))
+
+	var req, _ = http.NewRequest(http.MethodGet, "http://localhost", http.NoBody)
+	var resp, e = transport.RoundTrip(req)
+	if e != nil {
+		t.Fatal(e)
+	}
+	_ = resp.Body.Close()
+
+	if e = transport.Close(context.Background()); e != nil {
+		t.Fatal(e)
+	}
+	var expected = "hook,outer,inner,base"
+	if strings.Join(closed, ",") != expected {
+		t.Fatalf("expected close order %s but got %v", expected, closed)
+	}
+	if e = transport.Close(context.Background()); e != nil {
+		t.Fatal(e)
+	}
+	if len(closed) != 4 {
+		t.Fatalf("components were closed more than once: %v", closed)
+	}
+	if _, e = transport.RoundTrip(req); !errors.Is(e, ErrTransportClosed) {
+		t.Fatalf("expected ErrTransportClosed but got %v", e)
+	}
+}
+
+func TestClosableTransportDrains(t *testing.T) {
+	var closed []string
+	var transport = Chain{}.ApplyClosable(&closeRecordingTransport{name: "base", closed: &closed})
+	var req, _ = http.NewRequest(http.MethodGet, "http://localhost", http.NoBody)
+	var resp, e = transport.RoundTrip(req)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	var result = make(chan error)
+	go func() {
+		result <- transport.Close(context.Background())
+	}()
+	select {
+	case <-result:
+		t.Fatal("closed while a response body was open")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if _, e = transport.RoundTrip(req); !errors.Is(e, ErrTransportClosed) {
+		t.Fatalf("expected ErrTransportClosed while draining but got %v", e)
+	}
+	_ = resp.Body.Close()
+	select {
+	case e = <-result:
+		if e != nil {
+			t.Fatal(e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("did not close after the response body was closed")
+	}
+	if len(closed) != 1 {
+		t.Fatalf("expected the base to be closed but got %v", closed)
+	}
+}
+
+func TestClosableTransportTimeout(t *testing.T) {
+	var closed []string
+	var failure = errors.New("close failed")
+	var transport = Chain{}.ApplyClosable(&closeRecordingTransport{name: "base", closed: &closed, err: failure})
+	var req, _ = http.NewRequest(http.MethodGet, "http://localhost", http.NoBody)
+	if _, e := transport.RoundTrip(req); e != nil {
+		t.Fatal(e)
+	}
+
+	var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	var e = transport.Close(ctx)
+	if !errors.Is(e, context.DeadlineExceeded) {
+		t.Fatalf("expected a deadline error but got %v", e)
+	}
+	if !errors.Is(e, failure) {
+		t.Fatalf("expected the close error but got %v", e)
+	}
+	if len(closed) != 1 {
+		t.Fatalf("expected the base to be closed after the timeout but got %v", closed)
+	}
+}
+
+func TestClosableTransportIdleConnections(t *testing.T) {
+	var base = &http.Transport{}
+	var transport = Chain{NewHeaders(nil, nil)}.ApplyClosable(base)
+	if e := transport.Close(context.Background()); e != nil {
+		t.Fatal(e)
+	}
+}
@@ -0,0 +1,826 @@
+package transport
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"math/big"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignatureAlgorithm names an algorithm from the HTTP Signature Algorithms
+// registry of RFC 9421.
+type SignatureAlgorithm string
+
+const (
+	// SignatureHMACSHA256 is HMAC using SHA-256 with a shared secret.
+	SignatureHMACSHA256 SignatureAlgorithm = "hmac-sha256"
+	// SignatureEd25519 is EdDSA using curve edwards25519.
+	SignatureEd25519 SignatureAlgorithm = "ed25519"
+	// SignatureECDSAP256SHA256 is ECDSA using curve P-256 and SHA-256.
+	SignatureECDSAP256SHA256 SignatureAlgorithm = "ecdsa-p256-sha256"
+	// SignatureRSAPSSSHA512 is RSASSA-PSS using SHA-512.
+	SignatureRSAPSSSHA512 SignatureAlgorithm = "rsa-pss-sha512"
+	// SignatureRSAV15SHA256 is RSASSA-PKCS1-v1_5 using SHA-256.
+	SignatureRSAV15SHA256 SignatureAlgorithm = "rsa-v1_5-sha256"
+)
+
+// SignatureKey is a key used with HTTP message signatures. Key is the []byte
+// secret for SignatureHMACSHA256 and otherwise an ed25519, *ecdsa, or *rsa
+// key. Verification accepts either the public or the private key.
+type SignatureKey struct {
+	ID        string
+	Algorithm SignatureAlgorithm
+	Key       interface{}
+}
+
+func (k SignatureKey) publicKey() interface{} {
+	switch key := k.Key.(type) {
+	case ed25519.PrivateKey:
+		return key.Public()
+	case *ecdsa.PrivateKey:
+		return &key.PublicKey
+	case *rsa.PrivateKey:
+		return &key.PublicKey
+	}
+	return k.Key
+}
+
+func (k SignatureKey) verify(base []byte, signature []byte) error {
+	var invalid = fmt.Errorf("transport: key %s is not valid for %s", k.ID, k.Algorithm)
+	var verified bool
+	switch k.Algorithm {
+	case SignatureHMACSHA256:
+		var secret, ok = k.Key.([]byte)
+		if !ok {
+			return invalid
+		}
+		var mac = hmac.New(sha256.New, secret)
+		_, _ = mac.Write(base)
+		verified = hmac.Equal(mac.Sum(nil), signature)
+	case SignatureEd25519:
+		var key, ok = k.publicKey().(ed25519.PublicKey)
+		if !ok {
+			return invalid
+		}
+		verified = ed25519.Verify(key, base, signature)
+	case SignatureECDSAP256SHA256:
+		var key, ok = k.publicKey().(*ecdsa.PublicKey)
+		if !ok {
+			return invalid
+		}
+		var digest = sha256.Sum256(base)
+		verified = len(signature) == 64 && ecdsa.Verify(
+			key, digest[:], new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:]),
+		)
+	case SignatureRSAPSSSHA512:
+		var key, ok = k.publicKey().(*rsa.PublicKey)
+		if !ok {
+			return invalid
+		}
+		var digest = sha512.Sum512(base)
+		verified = rsa.VerifyPSS(key, crypto.SHA512, digest[:], signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}) == nil
+	case SignatureRSAV15SHA256:
+		var key, ok = k.publicKey().(*rsa.PublicKey)
+		if !ok {
+			return invalid
+		}
+		var digest = sha256.Sum256(base)
+		verified = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil
+	default:
+		return fmt.Errorf("transport: unsupported signature algorithm %s", k.Algorithm)
+	}
+	if !verified {
+		return fmt.Errorf("%w: signature does not match key %s", ErrSignatureInvalid, k.ID)
+	}
+	return nil
+}
+
+func (k SignatureKey) sign(base []byte) ([]byte, error) {
+	if k.Algorithm == SignatureHMACSHA256 {
+		var secret, ok = k.Key.([]byte)
+		if !ok {
+			return nil, fmt.Errorf("transport: key %s is not valid for %s", k.ID, k.Algorithm)
+		}
+		var mac = hmac.New(sha256.New, secret)
+		_, _ = mac.Write(base)
+		return mac.Sum(nil), nil
+	}
+	var signer, ok = k.Key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("transport: key %s is not a private key", k.ID)
+	}
+	switch k.Algorithm {
+	case SignatureEd25519:
+		return signer.Sign(rand.Reader, base, crypto.Hash(0))
+	case SignatureECDSAP256SHA256:
+		var digest = sha256.Sum256(base)
+		var der, e = signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+		if e != nil {
+			return nil, e
+		}
+		// RFC 9421 uses the fixed size concatenation of r and s rather than
+		// the ASN.1 encoding produced by crypto.Signer.
+		var parsed struct{ R, S *big.Int }
+		if _, e = asn1.Unmarshal(der, &parsed); e != nil {
+			return nil, e
+		}
+		var signature = make([]byte, 64)
+		parsed.R.FillBytes(signature[:32])
+		parsed.S.FillBytes(signature[32:])
+		return signature, nil
+	case SignatureRSAPSSSHA512:
+		var digest = sha512.Sum512(base)
+		return signer.Sign(rand.Reader, digest[:], &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA512})
+	case SignatureRSAV15SHA256:
+		var digest = sha256.Sum256(base)
+		return signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+	return nil, fmt.Errorf("transport: unsupported signature algorithm %s", k.Algorithm)
+}
+
+// signatureComponent is a component identifier from a covered components
+// list. The req flag selects the request of a response.
+type signatureComponent struct {
+	name string
+	req  bool
+}
+
+func (c signatureComponent) String() string {
+	if c.req {
+		return strconv.Quote(c.name) + ";req"
+	}
+	return strconv.Quote(c.name)
+}
+
+// splitStructured splits a structured field on the separator, ignoring
+// separators within quoted strings and inner lists.
+func splitStructured(value string, separator byte) []string {
+	var parts []string
+	var quoted bool
+	var depth int
+	var start int
+	for x := 0; x < len(value); x = x + 1 {
+		switch {
+		case quoted && value[x] == '\\':
+			x = x + 1
+		case value[x] == '"':
+			quoted = !quoted
+		case quoted:
+		case value[x] == '(':
+			depth = depth + 1
+		case value[x] == ')':
+			depth = depth - 1
+		case value[x] == separator && depth == 0:
+			parts = append(parts, strings.TrimSpace(value[start:x]))
+			start = x + 1
+		}
+	}
+	return append(parts, strings.TrimSpace(value[start:]))
+}
+
+// parseDictionary returns the members of a structured field dictionary,
+// such as Signature-Input, without parsing their values.
+func parseDictionary(values []string) map[string]string {
+	var members = make(map[string]string)
+	for _, member := range splitStructured(strings.Join(values, ", "), ',') {
+		var label, value, ok = strings.Cut(member, "=")
+		if ok {
+			members[strings.TrimSpace(label)] = strings.TrimSpace(value)
+		}
+	}
+	return members
+}
+
+// parseSignatureInput parses a member of Signature-Input into its covered
+// components and its parameters. String parameters are unquoted.
+func parseSignatureInput(value string) ([]signatureComponent, map[string]string, error) {
+	var end = strings.LastIndexByte(value, ')')
+	if !strings.HasPrefix(value, "(") || end < 0 {
+		return nil, nil, fmt.Errorf("transport: malformed signature input %s", value)
+	}
+	var components []signatureComponent
+	for _, item := range splitStructured(strings.TrimSpace(value[1:end]), ' ') {
+		if item == "" {
+			continue
+		}
+		var parts = splitStructured(item, ';')
+		if !strings.HasPrefix(parts[0], `"`) {
+			return nil, nil, fmt.Errorf("transport: malformed component %s", parts[0])
+		}
+		var component = signatureComponent{name: unquote(parts[0])}
+		for _, param := range parts[1:] {
+			if param != "req" {
+				return nil, nil, fmt.Errorf("transport: unsupported component parameter %s", param)
+			}
+			component.req = true
+		}
+		components = append(components, component)
+	}
+	var params = make(map[string]string)
+	for _, param := range splitStructured(value[end+1:], ';')[1:] {
+		var key, v, _ = strings.Cut(param, "=")
+		params[key] = unquote(v)
+	}
+	return components, params, nil
+}
+
+// componentValue returns the value of a component of the request, or of the
+// response when one is given.
+func componentValue(c signatureComponent, req *http.Request, resp *http.Response) (string, error) {
+	if resp != nil && c.req {
+		resp = nil
+	}
+	if resp == nil && req == nil {
+		return "", fmt.Errorf("transport: component %s refers to a missing request", c)
+	}
+	if !strings.HasPrefix(c.name, "@") {
+		var header http.Header
+		if resp != nil {
+			header = resp.Header
+		} else {
+			header = req.Header
+		}
+		var values = append([]string(nil), header.Values(c.name)...)
+		if len(values) < 1 {
+			return "", fmt.Errorf("transport: covered header %s is missing", c.name)
+		}
+		for x := range values {
+			values[x] = strings.TrimSpace(values[x])
+		}
+		return strings.Join(values, ", "), nil
+	}
+	if c.name == "@status" {
+		if resp == nil {
+			return "", errors.New("transport: component @status requires a response")
+		}
+		return strconv.Itoa(resp.StatusCode), nil
+	}
+	if resp != nil {
+		return "", fmt.Errorf("transport: component %s requires a request", c.name)
+	}
+	switch c.name {
+	case "@method":
+		return req.Method, nil
+	case "@target-uri":
+		return req.URL.String(), nil
+	case "@authority":
+		var host = req.Host
+		if host == "" {
+			host = req.URL.Host
+		}
+		host = strings.ToLower(host)
+		if (req.URL.Scheme == "https" && strings.HasSuffix(host, ":443")) || (req.URL.Scheme == "http" && strings.HasSuffix(host, ":80")) {
+			host = host[:strings.LastIndexByte(host, ':')]
+		}
+		return host, nil
+	case "@scheme":
+		return strings.ToLower(req.URL.Scheme), nil
+	case "@request-target":
+		return req.URL.RequestURI(), nil
+	case "@path":
+		var path = req.URL.EscapedPath()
+		if path == "" {
+			path = "/"
+		}
+		return path, nil
+	case "@query":
+		return "?" + req.URL.RawQuery, nil
+	}
+	return "", fmt.Errorf("transport: unsupported component %s", c.name)
+}
+
+// signatureBase creates the signature base of RFC 9421 for the covered
+// components and the serialized signature parameters.
+func signatureBase(components []signatureComponent, params string, req *http.Request, resp *http.Response) ([]byte, error) {
+	var base strings.Builder
+	for _, component := range components {
+		var value, e = componentValue(component, req, resp)
+		if e != nil {
+			return nil, e
+		}
+		base.WriteString(component.String())
+		base.WriteString(": ")
+		base.WriteString(value)
+		base.WriteString("\n")
+	}
+	base.WriteString(`"@signature-params": `)
+	base.WriteString(params)
+	return []byte(base.String()), nil
+}
+
+var (
+	// ErrSignatureMissing indicates that a response was not signed.
+	ErrSignatureMissing = errors.New("transport: signature missing")
+	// ErrSignatureInvalid indicates that a signature, or the content digest
+	// that it covers, did not match the response.
+	ErrSignatureInvalid = errors.New("transport: signature invalid")
+	// ErrSignatureExpired indicates that a signature was created too long
+	// ago or is past its expiry.
+	ErrSignatureExpired = errors.New("transport: signature expired")
+	// ErrSignatureUnknownKey indicates that a response was signed by a key
+	// that is not trusted.
+	ErrSignatureUnknownKey = errors.New("transport: signature key unknown")
+)
+
+// SignatureError is returned by the VerifyResponseSignature decorator when a
+// response fails verification. Use errors.Is with the ErrSignature values to
+// find the reason.
+type SignatureError struct {
+	// Response is the response that failed verification. The body has
+	// already been drained and closed so only the status and headers are
+	// available.
+	Response *http.Response
+	// Err is the reason that verification failed.
+	Err error
+}
+
+func (e *SignatureError) Error() string {
+	return "response signature verification failed: " + e.Err.Error()
+}
+
+// Unwrap returns the reason that verification failed.
+func (e *SignatureError) Unwrap() error {
+	return e.Err
+}
+
+// ResponseSignatureVerifier checks the signature of a response.
+// Implementations that read the body must replace it so that it remains
+// readable by the caller.
+type ResponseSignatureVerifier interface {
+	VerifyResponse(resp *http.Response) error
+}
+
+// HTTPSignatureVerifier verifies HTTP message signatures as defined by RFC
+// 9421 using the Signature-Input and Signature headers of a response. When
+// the content-digest header is covered the digest is also compared with the
+// body so that the body is protected by the signature.
+//
+// A signature that covers nothing proves nothing, so every signature must
+// cover either @status or the @method and @target-uri of the request, and
+// must cover content-digest whenever the response has a body. Signatures
+// that do not are rejected regardless of the components added with
+// HTTPSignatureVerifierOptionRequire.
+type HTTPSignatureVerifier struct {
+	keys     map[string]SignatureKey
+	label    string
+	required []string
+	maxAge   time.Duration
+	clock    Clock
+}
+
+// HTTPSignatureVerifierOption is a configuration for the
+// HTTPSignatureVerifier.
+type HTTPSignatureVerifierOption func(*HTTPSignatureVerifier) *HTTPSignatureVerifier
+
+// HTTPSignatureVerifierOptionLabel selects the signature to verify when a
+// response has more than one. The default is the first signature, by label,
+// that was created by a trusted key.
+func HTTPSignatureVerifierOptionLabel(label string) HTTPSignatureVerifierOption {
+	return func(v *HTTPSignatureVerifier) *HTTPSignatureVerifier {
+		v.label = label
+		return v
+	}
+}
+
+// HTTPSignatureVerifierOptionRequire sets additional components, such as
+// content-type, that a signature must cover to be accepted.
+func HTTPSignatureVerifierOptionRequire(components ...string) HTTPSignatureVerifierOption {
+	return func(v *HTTPSignatureVerifier) *HTTPSignatureVerifier {
+		v.required = append(v.required, components...)
+		return v
+	}
+}
+
+// HTTPSignatureVerifierOptionMaxAge rejects signatures with a created time
+// older than the age. Signatures past their expires time are always
+// rejected.
+func HTTPSignatureVerifierOptionMaxAge(age time.Duration) HTTPSignatureVerifierOption {
+	return func(v *HTTPSignatureVerifier) *HTTPSignatureVerifier {
+		v.maxAge = age
+		return v
+	}
+}
+
+// HTTPSignatureVerifierOptionClock configures the Clock used to check
+// signature times.
+func HTTPSignatureVerifierOptionClock(clock Clock) HTTPSignatureVerifierOption {
+	return func(v *HTTPSignatureVerifier) *HTTPSignatureVerifier {
+		v.clock = clock
+		return v
+	}
+}
+
+// NewHTTPSignatureVerifier creates an HTTPSignatureVerifier that trusts the
+// keys, which are matched to signatures by their keyid parameter.
+func NewHTTPSignatureVerifier(keys []SignatureKey, opts ...HTTPSignatureVerifierOption) *HTTPSignatureVerifier {
+	var v = &HTTPSignatureVerifier{
+		keys:  make(map[string]SignatureKey, len(keys)),
+		clock: NewSystemClock(),
+	}
+	for _, key := range keys {
+		v.keys[key.ID] = key
+	}
+	for _, opt := range opts {
+		v = opt(v)
+	}
+	return v
+}
+
+// VerifyResponse verifies a signature of the response.
+func (v *HTTPSignatureVerifier) VerifyResponse(resp *http.Response) error {
+	var inputs = parseDictionary(resp.Header.Values("Signature-Input"))
+	var signatures = parseDictionary(resp.Header.Values("Signature"))
+	var labels = make([]string, 0, len(inputs))
+	for label := range inputs {
+		if _, ok := signatures[label]; ok && (v.label == "" || v.label == label) {
+			labels = append(labels, label)
+		}
+	}
+	if len(labels) < 1 {
+		return ErrSignatureMissing
+	}
+	sort.Strings(labels)
+	for _, label := range labels {
+		var components, params, e = parseSignatureInput(inputs[label])
+		if e != nil {
+			return e
+		}
+		var key, ok = v.keys[params["keyid"]]
+		if !ok {
+			continue
+		}
+		return v.verify(resp, key, components, params, inputs[label], signatures[label])
+	}
+	return ErrSignatureUnknownKey
+}
+
+func (v *HTTPSignatureVerifier) verify(resp *http.Response, key SignatureKey, components []signatureComponent, params map[string]string, input string, signature string) error {
+	if alg, ok := params["alg"]; ok && alg != string(key.Algorithm) {
+		return fmt.Errorf("%w: algorithm %s does not match key %s", ErrSignatureInvalid, alg, key.ID)
+	}
+	if len(components) < 1 {
+		return fmt.Errorf("%w: no components are covered", ErrSignatureInvalid)
+	}
+	var covered = make(map[string]bool, len(components))
+	var bound = make(map[string]bool, len(components))
+	for _, component := range components {
+		if component.req {
+			bound[strings.ToLower(component.name)] = true
+			continue
+		}
+		covered[strings.ToLower(component.name)] = true
+	}
+	if !covered["@status"] && !(bound["@method"] && bound["@target-uri"]) {
+		return fmt.Errorf("%w: neither @status nor the request @method and @target-uri are covered", ErrSignatureInvalid)
+	}
+	if hasResponseBody(resp) && !covered["content-digest"] {
+		return fmt.Errorf("%w: content-digest is not covered", ErrSignatureInvalid)
+	}
+	for _, required := range v.required {
+		if !covered[strings.ToLower(required)] {
+			return fmt.Errorf("%w: %s is not covered", ErrSignatureInvalid, required)
+		}
+	}
+	var now = v.clock.Now()
+	if expires, ok := params["expires"]; ok {
+		var seconds, e = strconv.ParseInt(expires, 10, 64)
+		if e != nil || !now.Before(time.Unix(seconds, 0)) {
+			return ErrSignatureExpired
+		}
+	}
+	if v.maxAge > 0 {
+		var seconds, e = strconv.ParseInt(params["created"], 10, 64)
+		if e != nil || now.Sub(time.Unix(seconds, 0)) > v.maxAge {
+			return ErrSignatureExpired
+		}
+	}
+	if !strings.HasPrefix(signature, ":") || !strings.HasSuffix(signature, ":") || len(signature) < 2 {
+		return fmt.Errorf("%w: malformed signature", ErrSignatureInvalid)
+	}
+	var decoded, e = base64.StdEncoding.DecodeString(signature[1 : len(signature)-1])
+	if e != nil {
+		return fmt.Errorf("%w: %s", ErrSignatureInvalid, e.Error())
+	}
+	var base []byte
+	if base, e = signatureBase(components, input, resp.Request, resp); e != nil {
+		return fmt.Errorf("%w: %s", ErrSignatureInvalid, e.Error())
+	}
+	if e = key.verify(base, decoded); e != nil {
+		return e
+	}
+	if covered["content-digest"] {
+		return verifyContentDigest(resp)
+	}
+	return nil
+}
+
+// hasResponseBody reports whether the response may have content.
+func hasResponseBody(resp *http.Response) bool {
+	return resp.Body != nil && resp.Body != http.NoBody && resp.ContentLength != 0
+}
+
+// verifyContentDigest compares the Content-Digest header of the response
+// with the body. At least one digest must use a supported algorithm.
+func verifyContentDigest(resp *http.Response) error {
+	var content, e = bufferResponseBody(resp)
+	if e != nil {
+		return e
+	}
+	var checked bool
+	for algorithm, value := range parseDictionary(resp.Header.Values("Content-Digest")) {
+		var h hash.Hash
+		switch DigestAlgorithm(algorithm) {
+		case DigestSHA256:
+			h = sha256.New()
+		case DigestSHA512:
+			h = sha512.New()
+		default:
+			continue
+		}
+		_, _ = h.Write(content)
+		if value != ":"+base64.StdEncoding.EncodeToString(h.Sum(nil))+":" {
+			return fmt.Errorf("%w: content digest does not match the body", ErrSignatureInvalid)
+		}
+		checked = true
+	}
+	if !checked {
+		return fmt.Errorf("%w: no supported content digest", ErrSignatureInvalid)
+	}
+	return nil
+}
+
+// bufferResponseBody reads the body of the response into memory and
+// replaces it with a copy. Verifiers must leave the response they are given
+// readable, so the copy made by withBody is written over the original. This
+// is safe because the body has been read to EOF and so the trailers are
+// already in place.
+func bufferResponseBody(resp *http.Response) ([]byte, error) {
+	if resp.Body == nil || resp.Body == http.NoBody {
+		return nil, nil
+	}
+	var content, e = readBody(resp.Body)
+	_ = resp.Body.Close()
+	if e != nil {
+		return nil, e
+	}
+	*resp = *withBody(resp, newReplayBody(content))
+	return content, nil
+}
+
+// HMACSignatureVerifier verifies responses signed with an HMAC of the body
+// in a header, as used by many webhook and partner APIs.
+type HMACSignatureVerifier struct {
+	header string
+	key    []byte
+	prefix string
+	hash   func() hash.Hash
+	decode func(string) ([]byte, error)
+}
+
+// HMACSignatureVerifierOption is a configuration for the
+// HMACSignatureVerifier.
+type HMACSignatureVerifierOption func(*HMACSignatureVerifier) *HMACSignatureVerifier
+
+// HMACSignatureVerifierOptionPrefix sets text that precedes the signature in
+// the header value, such as "sha256=".
+func HMACSignatureVerifierOptionPrefix(prefix string) HMACSignatureVerifierOption {
+	return func(v *HMACSignatureVerifier) *HMACSignatureVerifier {
+		v.prefix = prefix
+		return v
+	}
+}
+
+// HMACSignatureVerifierOptionSHA512 uses SHA-512 rather than the default of
+// SHA-256.
+func HMACSignatureVerifierOptionSHA512() HMACSignatureVerifierOption {
+	return func(v *HMACSignatureVerifier) *HMACSignatureVerifier {
+		v.hash = sha512.New
+		return v
+	}
+}
+
+// HMACSignatureVerifierOptionBase64 reads the signature as standard base64
+// rather than the default of hex.
+func HMACSignatureVerifierOptionBase64() HMACSignatureVerifierOption {
+	return func(v *HMACSignatureVerifier) *HMACSignatureVerifier {
+		v.decode = base64.StdEncoding.DecodeString
+		return v
+	}
+}
+
+// NewHMACSignatureVerifier creates an HMACSignatureVerifier that reads the
+// signature from the header and checks it using the shared key.
+func NewHMACSignatureVerifier(header string, key []byte, opts ...HMACSignatureVerifierOption) *HMACSignatureVerifier {
+	var v = &HMACSignatureVerifier{
+		header: header,
+		key:    key,
+		hash:   sha256.New,
+		decode: hex.DecodeString,
+	}
+	for _, opt := range opts {
+		v = opt(v)
+	}
+	return v
+}
+
+// VerifyResponse compares the signature header with an HMAC of the body.
+func (v *HMACSignatureVerifier) VerifyResponse(resp *http.Response) error {
+	var value = resp.Header.Get(v.header)
+	if value == "" {
+		return ErrSignatureMissing
+	}
+	if !strings.HasPrefix(value, v.prefix) {
+		return fmt.Errorf("%w: malformed signature", ErrSignatureInvalid)
+	}
+	var signature, e = v.decode(strings.TrimPrefix(value, v.prefix))
+	if e != nil {
+		return fmt.Errorf("%w: %s", ErrSignatureInvalid, e.Error())
+	}
+	var content []byte
+	if content, e = bufferResponseBody(resp); e != nil {
+		return e
+	}
+	var mac = hmac.New(v.hash, v.key)
+	_, _ = mac.Write(content)
+	if !hmac.Equal(mac.Sum(nil), signature) {
+		return fmt.Errorf("%w: signature does not match the body", ErrSignatureInvalid)
+	}
+	return nil
+}
+
+// VerifyResponseSignature is a decorator that rejects responses that fail
+// signature verification, for clients of webhook callbacks and APIs whose
+// responses must not be tampered with in transit.
+type VerifyResponseSignature struct {
+	wrapped  http.RoundTripper
+	verifier ResponseSignatureVerifier
+}
+
+// RoundTrip calls the wrapped transport and verifies the response. Responses
+// that fail verification are closed and a *SignatureError is returned.
+func (c *VerifyResponseSignature) RoundTrip(r *http.Request) (*http.Response, error) {
+	var resp, e = c.wrapped.RoundTrip(r)
+	if e != nil {
+		return resp, e
+	}
+	if resp.Request == nil {
+		resp.Request = r
+	}
+	if e = c.verifier.VerifyResponse(resp); e != nil {
+		drainBody(resp)
+		return nil, &SignatureError{Response: withBody(resp, http.NoBody), Err: e}
+	}
+	return resp, nil
+}
+
+// NewVerifyResponseSignature configures a RoundTripper decorator that
+// verifies every response with the verifier.
+func NewVerifyResponseSignature(verifier ResponseSignatureVerifier) func(http.RoundTripper) http.RoundTripper {
+	return func(wrapped http.RoundTripper) http.RoundTripper {
+		return &VerifyResponseSignature{wrapped: wrapped, verifier: verifier}
+	}
+}
+
+// HTTPSignature is a decorator that signs requests with HTTP Message
+// Signatures as defined by RFC 9421, setting the Signature-Input and
+// Signature headers. Each request that passes through it is signed again so
+// that, when installed inside a retry or hedging decorator, every attempt has
+// a fresh created time.
+type HTTPSignature struct {
+	wrapped    http.RoundTripper
+	key        SignatureKey
+	label      string
+	components []signatureComponent
+	expires    time.Duration
+	tag        string
+	clock      Clock
+}
+
+// HTTPSignatureOption is a configuration for the HTTPSignature decorator.
+type HTTPSignatureOption func(*HTTPSignature) *HTTPSignature
+
+// HTTPSignatureOptionComponents sets the covered components, such as @method,
+// @target-uri, or content-digest. Header fields that are absent from a
+// request are left out of its signature. The default is @method,
+// @authority, @path, @query, content-type, and content-digest.
+func HTTPSignatureOptionComponents(components ...string) HTTPSignatureOption {
+	return func(s *HTTPSignature) *HTTPSignature {
+		s.components = s.components[:0]
+		for _, component := range components {
+			s.components = append(s.components, signatureComponent{name: strings.ToLower(component)})
+		}
+		return s
+	}
+}
+
+// HTTPSignatureOptionLabel sets the label of the signature. The default is
+// sig1.
+func HTTPSignatureOptionLabel(label string) HTTPSignatureOption {
+	return func(s *HTTPSignature) *HTTPSignature {
+		s.label = label
+		return s
+	}
+}
+
+// HTTPSignatureOptionExpires adds an expires parameter the duration after the
+// created time.
+func HTTPSignatureOptionExpires(expires time.Duration) HTTPSignatureOption {
+	return func(s *HTTPSignature) *HTTPSignature {
+		s.expires = expires
+		return s
+	}
+}
+
+// HTTPSignatureOptionTag adds a tag parameter, which identifies the profile
+// of the signature that a partner expects.
+func HTTPSignatureOptionTag(tag string) HTTPSignatureOption {
+	return func(s *HTTPSignature) *HTTPSignature {
+		s.tag = tag
+		return s
+	}
+}
+
+// HTTPSignatureOptionClock configures the Clock used for the created time.
+func HTTPSignatureOptionClock(clock Clock) HTTPSignatureOption {
+	return func(s *HTTPSignature) *HTTPSignature {
+		s.clock = clock
+		return s
+	}
+}
+
+// RoundTrip signs the request and calls the wrapped transport. Existing
+// signature headers are replaced.
+func (c *HTTPSignature) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.Header.Del("Signature-Input")
+	r.Header.Del("Signature")
+	var components = make([]signatureComponent, 0, len(c.components))
+	var identifiers = make([]string, 0, len(c.components))
+	for _, component := range c.components {
+		if !strings.HasPrefix(component.name, "@") && len(r.Header.Values(component.name)) < 1 {
+			continue
+		}
+		components = append(components, component)
+		identifiers = append(identifiers, component.String())
+	}
+	var created = c.clock.Now().Unix()
+	var params = "(" + strings.Join(identifiers, " ") + ");created=" + strconv.FormatInt(created, 10)
+	if c.expires > 0 {
+		params = params + ";expires=" + strconv.FormatInt(created+int64(c.expires/time.Second), 10)
+	}
+	params = params + ";keyid=" + strconv.Quote(c.key.ID) + ";alg=" + strconv.Quote(string(c.key.Algorithm))
+	if c.tag != "" {
+		params = params + ";tag=" + strconv.Quote(c.tag)
+	}
+	var base, e = signatureBase(components, params, r, nil)
+	if e != nil {
+		return nil, e
+	}
+	var signature []byte
+	if signature, e = c.key.sign(base); e != nil {
+		return nil, e
+	}
+	r.Header.Set("Signature-Input", c.label+"="+params)
+	r.Header.Set("Signature", c.label+"=:"+base64.StdEncoding.EncodeToString(signature)+":")
+	return c.wrapped.RoundTrip(r)
+}
+
+// NewHTTPSignature configures a RoundTripper decorator that signs requests
+// with the key. The key must be the []byte secret for SignatureHMACSHA256 or
+// otherwise a crypto.Signer, such as an ed25519.PrivateKey or an
+// *ecdsa.PrivateKey. Place it after any decorator in a Chain that adds a
+// covered header, such as ContentDigest.
+func NewHTTPSignature(key SignatureKey, opts ...HTTPSignatureOption) func(http.RoundTripper) http.RoundTripper {
+	return func(wrapped http.RoundTripper) http.RoundTripper {
+		var s = &HTTPSignature{
+			wrapped: wrapped,
+			key:     key,
+			label:   "sig1",
+			components: []signatureComponent{
+				{name: "@method"}, {name: "@authority"}, {name: "@path"}, {name: "@query"},
+				{name: "content-type"}, {name: "content-digest"},
+			},
+			clock: NewSystemClock(),
+		}
+		for _, opt := range opts {
+			s = opt(s)
+		}
+		return s
+	}
+}
@@ -0,0 +1,230 @@
+package transport
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSignedResponse(t *testing.T, key ed25519.PrivateKey, input string, body string) *http.Response {
+	var req, _ = http.NewRequest(http.MethodPost, "https://example.com/hooks?id=1", nil)
+	var digest = sha256.Sum256([]byte(body))
+	var resp = &http.Response{
+		StatusCode: http.StatusOK,
+		Header: http.Header{
+			"Content-Type":   []string{"application/json"},
+			"Content-Digest": []string{"sha-256=:" + base64.StdEncoding.EncodeToString(digest[:]) + ":"},
+		},
+		Body:    io.NopCloser(strings.NewReader(body)),
+		Request: req,
+	}
+	var components, _, e = parseSignatureInput(input)
+	require.NoError(t, e)
+	var base []byte
+	base, e = signatureBase(components, input, req, resp)
+	require.NoError(t, e)
+	resp.Header.Set("Signature-Input", "sig1="+input)
+	resp.Header.Set("Signature", "sig1=:"+base64.StdEncoding.EncodeToString(ed25519.Sign(key, base))+":")
+	return resp
+}
+
+func TestSignatureBase(t *testing.T) {
+	var req, _ = http.NewRequest(http.MethodPost, "https://Example.com:443/foo?param=Value&Pet=dog", nil)
+	req.Header.Set("Content-Type", "application/json")
+	var input = `("@method" "@authority" "@path" "@query" "content-type");created=1618884473;keyid="test-key"`
+	var components, params, e = parseSignatureInput(input)
+	require.NoError(t, e)
+	assert.Equal(t, "test-key", params["keyid"])
+	assert.Equal(t, "1618884473", params["created"])
+	var base []byte
+	base, e = signatureBase(components, input, req, nil)
+	require.NoError(t, e)
+	assert.Equal(t, strings.Join([]string{
+		`"@method": POST`,
+		`"@authority": example.com`,
+		`"@path": /foo`,
+		`"@query": ?param=Value&Pet=dog`,
+		`"content-type": application/json`,
+		`"@signature-params": ` + input,
+	}, "\n"), string(base))
+}
+
+func TestHTTPSignatureVerifier(t *testing.T) {
+	var public, private, _ = ed25519.GenerateKey(rand.Reader)
+	var clock = newFakeClock()
+	var input = `("@status" "content-digest" "@method";req);created=0;keyid="partner";alg="ed25519"`
+	var verifier = NewHTTPSignatureVerifier(
+		[]SignatureKey{{ID: "partner", Algorithm: SignatureEd25519, Key: public}},
+		HTTPSignatureVerifierOptionRequire("@status", "content-digest"),
+		HTTPSignatureVerifierOptionMaxAge(time.Minute),
+		HTTPSignatureVerifierOptionClock(clock),
+	)
+
+	var resp = newSignedResponse(t, private, input, `{"ok":true}`)
+	require.NoError(t, verifier.VerifyResponse(resp))
+	var body, _ = io.ReadAll(resp.Body)
+	assert.Equal(t, `{"ok":true}`, string(body), "body was not replaced after verification")
+
+	resp = newSignedResponse(t, private, input, `{"ok":true}`)
+	resp.StatusCode = http.StatusCreated
+	assert.ErrorIs(t, verifier.VerifyResponse(resp), ErrSignatureInvalid)
+
+	resp = newSignedResponse(t, private, input, `{"ok":true}`)
+	resp.Body = io.NopCloser(strings.NewReader(`{"ok":false}`))
+	assert.ErrorIs(t, verifier.VerifyResponse(resp), ErrSignatureInvalid)
+
+	resp = newSignedResponse(t, private, `("@status");created=0;keyid="partner"`, "")
+	assert.ErrorIs(t, verifier.VerifyResponse(resp), ErrSignatureInvalid, "required component was not enforced")
+
+	resp = newSignedResponse(t, private, strings.Replace(input, "partner", "other", 1), `{"ok":true}`)
+	assert.ErrorIs(t, verifier.VerifyResponse(resp), ErrSignatureUnknownKey)
+
+	resp = newSignedResponse(t, private, input, `{"ok":true}`)
+	clock.advance(2 * time.Minute)
+	assert.ErrorIs(t, verifier.VerifyResponse(resp), ErrSignatureExpired)
+
+	resp.Header.Del("Signature")
+	assert.ErrorIs(t, verifier.VerifyResponse(resp), ErrSignatureMissing)
+}
+
+func TestHTTPSignatureVerifierDefaults(t *testing.T) {
+	var public, private, _ = ed25519.GenerateKey(rand.Reader)
+	var verifier = NewHTTPSignatureVerifier([]SignatureKey{{ID: "partner", Algorithm: SignatureEd25519, Key: public}})
+	for _, tc := range []struct {
+		name  string
+		input string
+		body  string
+		err   error
+	}{
+		{name: "status and digest", input: `("@status" "content-digest")`, body: `{"ok":true}`},
+		{name: "request bound", input: `("@method";req "@target-uri";req "content-digest")`, body: `{"ok":true}`},
+		{name: "status without body", input: `("@status")`},
+		{name: "empty", input: `()`, err: ErrSignatureInvalid},
+		{name: "headers only", input: `("content-type")`, err: ErrSignatureInvalid},
+		{name: "method only", input: `("@method";req "content-digest")`, body: `{"ok":true}`, err: ErrSignatureInvalid},
+		{name: "body without digest", input: `("@status" "content-type")`, body: `{"ok":true}`, err: ErrSignatureInvalid},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var resp = newSignedResponse(t, private, tc.input+`;created=0;keyid="partner"`, tc.body)
+			resp.ContentLength = int64(len(tc.body))
+			var e = verifier.VerifyResponse(resp)
+			if tc.err == nil {
+				assert.NoError(t, e)
+				return
+			}
+			assert.ErrorIs(t, e, tc.err)
+		})
+	}
+}
+
+func TestHMACSignatureVerifier(t *testing.T) {
+	var key = []byte("secret")
+	var mac = hmac.New(sha256.New, key)
+	_, _ = mac.Write([]byte("payload"))
+	var verifier = NewHMACSignatureVerifier("X-Signature-256", key, HMACSignatureVerifierOptionPrefix("sha256="))
+
+	var resp = &http.Response{
+		Header: http.Header{"X-Signature-256": []string{"sha256=" + hex.EncodeToString(mac.Sum(nil))}},
+		Body:   io.NopCloser(strings.NewReader("payload")),
+	}
+	require.NoError(t, verifier.VerifyResponse(resp))
+	var body, _ = io.ReadAll(resp.Body)
+	assert.Equal(t, "payload", string(body))
+
+	resp.Body = io.NopCloser(strings.NewReader("tampered"))
+	assert.ErrorIs(t, verifier.VerifyResponse(resp), ErrSignatureInvalid)
+
+	var encoded = NewHMACSignatureVerifier("X-Signature", key, HMACSignatureVerifierOptionBase64())
+	resp = &http.Response{
+		Header: http.Header{"X-Signature": []string{base64.StdEncoding.EncodeToString(mac.Sum(nil))}},
+		Body:   io.NopCloser(strings.NewReader("payload")),
+	}
+	assert.NoError(t, encoded.VerifyResponse(resp))
+}
+
+func TestVerifyResponseSignature(t *testing.T) {
+	var rt = NewVerifyResponseSignature(NewHMACSignatureVerifier("X-Signature", []byte("secret")))(
+		RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{},
+				Body:       io.NopCloser(strings.NewReader("unsigned")),
+			}, nil
+		}),
+	)
+	var req, _ = http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	var resp, e = rt.RoundTrip(req)
+	assert.Nil(t, resp)
+	var signatureErr *SignatureError
+	require.True(t, errors.As(e, &signatureErr))
+	assert.ErrorIs(t, e, ErrSignatureMissing)
+	assert.Equal(t, http.StatusOK, signatureErr.Response.StatusCode)
+	assert.Equal(t, http.NoBody, signatureErr.Response.Body)
+}
+
+func TestHTTPSignature(t *testing.T) {
+	var _, edKey, _ = ed25519.GenerateKey(rand.Reader)
+	var ecKey, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	var rsaKey, _ = rsa.GenerateKey(rand.Reader, 2048)
+	var keys = []SignatureKey{
+		{ID: "hmac", Algorithm: SignatureHMACSHA256, Key: []byte("secret")},
+		{ID: "ed25519", Algorithm: SignatureEd25519, Key: edKey},
+		{ID: "ecdsa", Algorithm: SignatureECDSAP256SHA256, Key: ecKey},
+		{ID: "rsa-pss", Algorithm: SignatureRSAPSSSHA512, Key: rsaKey},
+		{ID: "rsa", Algorithm: SignatureRSAV15SHA256, Key: rsaKey},
+	}
+	for _, key := range keys {
+		t.Run(key.ID, func(t *testing.T) {
+			var clock = newFakeClock()
+			clock.advance(time.Hour)
+			var chain = Chain{
+				NewContentDigest(),
+				NewHTTPSignature(key, HTTPSignatureOptionClock(clock), HTTPSignatureOptionExpires(time.Minute), HTTPSignatureOptionTag("partner")),
+			}
+			var rt = chain.Apply(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+				var input = parseDictionary(r.Header.Values("Signature-Input"))["sig1"]
+				assert.Equal(t, `("@method" "@authority" "@path" "@query" "content-digest");created=3600;expires=3660;keyid="`+key.ID+`";alg="`+string(key.Algorithm)+`";tag="partner"`, input)
+				var components, _, e = parseSignatureInput(input)
+				require.NoError(t, e)
+				var base []byte
+				base, e = signatureBase(components, input, r, nil)
+				require.NoError(t, e)
+				var signature = parseDictionary(r.Header.Values("Signature"))["sig1"]
+				var decoded, _ = base64.StdEncoding.DecodeString(strings.Trim(signature, ":"))
+				assert.NoError(t, key.verify(base, decoded))
+				return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+			}))
+			var req, _ = http.NewRequest(http.MethodPost, "https://partner.example.com/orders?id=1", strings.NewReader("{}"))
+			var _, e = rt.RoundTrip(req)
+			require.NoError(t, e)
+			assert.Empty(t, req.Header.Get("Signature"), "original request was modified")
+		})
+	}
+}
+
+func TestHTTPSignatureInvalidKey(t *testing.T) {
+	var rt = NewHTTPSignature(SignatureKey{ID: "public", Algorithm: SignatureEd25519, Key: ed25519.PublicKey{}})(
+		RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			t.Fatal("unsigned request was sent")
+			return nil, nil
+		}),
+	)
+	var req, _ = http.NewRequest(http.MethodGet, "https://partner.example.com/", nil)
+	var _, e = rt.RoundTrip(req)
+	assert.EqualError(t, e, "transport: key public is not a private key")
+}
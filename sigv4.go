@@ -0,0 +1,234 @@
+package transport
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	sigV4Algorithm      = "AWS4-HMAC-SHA256"
+	sigV4TimeFormat     = "20060102T150405Z"
+	sigV4DateFormat     = "20060102"
+	sigV4UnsignedBody   = "UNSIGNED-PAYLOAD"
+	sigV4ContentSHA256  = "X-Amz-Content-Sha256"
+	sigV4SecurityHeader = "X-Amz-Security-Token"
+)
+
+// SigV4 is a decorator that signs requests with AWS Signature Version 4 so
+// that they can be sent to AWS services and to API Gateway endpoints that use
+// IAM authorization.
+type SigV4 struct {
+	wrapped     http.RoundTripper
+	credentials AWSCredentialsProvider
+	region      string
+	service     string
+	unsigned    bool
+	clock       Clock
+}
+
+// SigV4Option is a configuration for the SigV4 decorator.
+type SigV4Option func(*SigV4) *SigV4
+
+// SigV4OptionUnsignedPayload leaves the body out of the signature so that
+// large uploads are not buffered to hash them. Only services that accept
+// UNSIGNED-PAYLOAD, such as S3, allow it.
+func SigV4OptionUnsignedPayload() SigV4Option {
+	return func(s *SigV4) *SigV4 {
+		s.unsigned = true
+		return s
+	}
+}
+
+// SigV4OptionClock configures the Clock used to date signatures.
+func SigV4OptionClock(clock Clock) SigV4Option {
+	return func(s *SigV4) *SigV4 {
+		s.clock = clock
+		return s
+	}
+}
+
+// RoundTrip signs a copy of the request and calls the wrapped transport.
+// Requests fail without being sent if no credentials are available.
+func (c *SigV4) RoundTrip(r *http.Request) (*http.Response, error) {
+	var credentials, e = c.credentials.AWSCredentials(r.Context())
+	if e != nil {
+		if r.Body != nil {
+			_ = r.Body.Close()
+		}
+		return nil, e
+	}
+	var payloadHash = sigV4UnsignedBody
+	if c.unsigned {
+		r = r.Clone(r.Context())
+	} else if r, payloadHash, e = c.hashBody(r); e != nil {
+		return nil, e
+	}
+	c.sign(r, credentials, payloadHash, c.clock.Now().UTC())
+	return c.wrapped.RoundTrip(r)
+}
+
+// hashBody returns the SHA-256 of the request body and a copy of the request
+// with the body buffered so that it can still be sent.
+func (c *SigV4) hashBody(r *http.Request) (*http.Request, string, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		var digest = sha256.Sum256(nil)
+		return r.Clone(r.Context()), hex.EncodeToString(digest[:]), nil
+	}
+	var content, e = readBody(r.Body)
+	_ = r.Body.Close()
+	if e != nil {
+		return nil, "", e
+	}
+	r = withRequestBody(r, newReplayBody(content))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return newReplayBody(content), nil
+	}
+	var digest = sha256.Sum256(content)
+	return r, hex.EncodeToString(digest[:]), nil
+}
+
+func (c *SigV4) sign(r *http.Request, credentials AWSCredentials, payloadHash string, now time.Time) {
+	var amzDate = now.Format(sigV4TimeFormat)
+	var scope = strings.Join([]string{now.Format(sigV4DateFormat), c.region, c.service, "aws4_request"}, "/")
+	r.Header.Del("Authorization")
+	r.Header.Set("X-Amz-Date", amzDate)
+	if credentials.SessionToken != "" {
+		r.Header.Set(sigV4SecurityHeader, credentials.SessionToken)
+	}
+	if c.service == "s3" || payloadHash == sigV4UnsignedBody {
+		r.Header.Set(sigV4ContentSHA256, payloadHash)
+	}
+
+	var headers = map[string]string{"host": sigV4Host(r)}
+	for name, values := range r.Header {
+		var lower = strings.ToLower(name)
+		if lower == "content-type" || lower == "content-md5" || strings.HasPrefix(lower, "x-amz-") {
+			var trimmed = make([]string, 0, len(values))
+			for _, value := range values {
+				trimmed = append(trimmed, strings.Join(strings.Fields(value), " "))
+			}
+			headers[lower] = strings.Join(trimmed, ",")
+		}
+	}
+	var names = make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	var signedHeaders = strings.Join(names, ";")
+
+	var path = r.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if c.service != "s3" {
+		path = sigV4Escape(path, false)
+	}
+	var canonicalRequest = strings.Join([]string{
+		r.Method,
+		path,
+		sigV4Query(r.URL),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	var requestHash = sha256.Sum256([]byte(canonicalRequest))
+	var stringToSign = strings.Join([]string{sigV4Algorithm, amzDate, scope, hex.EncodeToString(requestHash[:])}, "\n")
+
+	var key = sigV4HMAC([]byte("AWS4"+credentials.SecretAccessKey), now.Format(sigV4DateFormat))
+	for _, part := range []string{c.region, c.service, "aws4_request"} {
+		key = sigV4HMAC(key, part)
+	}
+	var signature = hex.EncodeToString(sigV4HMAC(key, stringToSign))
+	r.Header.Set("Authorization", sigV4Algorithm+
+		" Credential="+credentials.AccessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+
+		", Signature="+signature)
+}
+
+func sigV4HMAC(key []byte, value string) []byte {
+	var mac = hmac.New(sha256.New, key)
+	_, _ = mac.Write([]byte(value))
+	return mac.Sum(nil)
+}
+
+// sigV4Host returns the Host header that will be sent, without the default
+// port of the scheme.
+func sigV4Host(r *http.Request) string {
+	var host = r.Host
+	if host == "" {
+		host = r.URL.Host
+	}
+	switch {
+	case r.URL.Scheme == "https" && strings.HasSuffix(host, ":443"):
+		return strings.TrimSuffix(host, ":443")
+	case r.URL.Scheme == "http" && strings.HasSuffix(host, ":80"):
+		return strings.TrimSuffix(host, ":80")
+	}
+	return host
+}
+
+// sigV4Query returns the query with every name and value encoded and sorted.
+func sigV4Query(u *url.URL) string {
+	var query = u.Query()
+	var pairs = make([]string, 0, len(query))
+	for name, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, sigV4Escape(name, true)+"="+sigV4Escape(value, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// sigV4Escape percent-encodes every byte other than the unreserved
+// characters and, unless escapeSlash is set, the slash.
+func sigV4Escape(value string, escapeSlash bool) string {
+	const hexDigits = "0123456789ABCDEF"
+	var escaped strings.Builder
+	for x := 0; x < len(value); x = x + 1 {
+		var b = value[x]
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9',
+			b == '-', b == '_', b == '.', b == '~', b == '/' && !escapeSlash:
+			escaped.WriteByte(b)
+		default:
+			escaped.WriteByte('%')
+			escaped.WriteByte(hexDigits[b>>4])
+			escaped.WriteByte(hexDigits[b&15])
+		}
+	}
+	return escaped.String()
+}
+
+// NewSigV4 configures a RoundTripper decorator that signs requests for the
+// service, such as execute-api or s3, in the region using credentials from
+// the provider, usually one created by NewDefaultAWSCredentials. Install it
+// inside any retry or hedging decorator so that every attempt gets a fresh
+// signature.
+func NewSigV4(credentials AWSCredentialsProvider, region string, service string, opts ...SigV4Option) func(http.RoundTripper) http.RoundTripper {
+	return func(wrapped http.RoundTripper) http.RoundTripper {
+		var s = &SigV4{
+			wrapped:     wrapped,
+			credentials: credentials,
+			region:      region,
+			service:     service,
+			clock:       NewSystemClock(),
+		}
+		for _, opt := range opts {
+			s = opt(s)
+		}
+		return s
+	}
+}
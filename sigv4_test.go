@@ -0,0 +1,121 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSigV4TestClock returns a clock set to the time of the examples in the
+// AWS Signature Version 4 test suite.
+func newSigV4TestClock() *fakeClock {
+	var clock = newFakeClock()
+	clock.advance(time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC).Sub(clock.Now()))
+	return clock
+}
+
+var sigV4TestCredentials = NewStaticAWSCredentials(AWSCredentials{
+	AccessKeyID:     "AKIDEXAMPLE",
+	SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+})
+
+func TestSigV4TestSuite(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		url           string
+		service       string
+		contentType   string
+		authorization string
+	}{
+		{
+			name:          "get-vanilla",
+			url:           "https://example.amazonaws.com/",
+			service:       "service",
+			authorization: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		{
+			name:          "get-vanilla-query-order-key-case",
+			url:           "https://example.amazonaws.com/?Param2=value2&Param1=value1",
+			service:       "service",
+			authorization: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+		},
+		{
+			name:          "iam-list-users",
+			url:           "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08",
+			service:       "iam",
+			contentType:   "application/x-www-form-urlencoded; charset=utf-8",
+			authorization: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, SignedHeaders=content-type;host;x-amz-date, Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var seen *http.Request
+			var rt = NewSigV4(sigV4TestCredentials, "us-east-1", tc.service, SigV4OptionClock(newSigV4TestClock()))(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+				seen = r
+				return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+			}))
+			var req, _ = http.NewRequest(http.MethodGet, tc.url, nil)
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+			var _, e = rt.RoundTrip(req)
+			require.NoError(t, e)
+			assert.Equal(t, tc.authorization, seen.Header.Get("Authorization"))
+			assert.Equal(t, "20150830T123600Z", seen.Header.Get("X-Amz-Date"))
+			assert.Empty(t, req.Header.Get("Authorization"), "request was modified")
+		})
+	}
+}
+
+func TestSigV4Body(t *testing.T) {
+	var credentials = NewStaticAWSCredentials(AWSCredentials{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		SessionToken:    "session",
+	})
+	var body string
+	var seen http.Header
+	var wrapped = RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		var content, _ = io.ReadAll(r.Body)
+		body = string(content)
+		seen = r.Header.Clone()
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	})
+	var req, _ = http.NewRequest(http.MethodPut, "https://bucket.s3.amazonaws.com/key", strings.NewReader("content"))
+	var _, e = NewSigV4(credentials, "us-east-1", "s3", SigV4OptionClock(newSigV4TestClock()))(wrapped).RoundTrip(req)
+	require.NoError(t, e)
+	assert.Equal(t, "content", body)
+	assert.Equal(t, "ed7002b439e9ac845f22357d822bac1444730fbdb6016d3ec9432297b9ec9f73", seen.Get("X-Amz-Content-Sha256"))
+	assert.Equal(t, "session", seen.Get("X-Amz-Security-Token"))
+	assert.Contains(t, seen.Get("Authorization"), "SignedHeaders=host;x-amz-content-sha256;x-amz-date;x-amz-security-token,")
+
+	req, _ = http.NewRequest(http.MethodPut, "https://bucket.s3.amazonaws.com/key", strings.NewReader("content"))
+	_, e = NewSigV4(credentials, "us-east-1", "s3", SigV4OptionUnsignedPayload())(wrapped).RoundTrip(req)
+	require.NoError(t, e)
+	assert.Equal(t, "content", body)
+	assert.Equal(t, "UNSIGNED-PAYLOAD", seen.Get("X-Amz-Content-Sha256"))
+}
+
+func TestSigV4CredentialsError(t *testing.T) {
+	var failing = AWSCredentialsProviderFunc(func(context.Context) (AWSCredentials, error) {
+		return AWSCredentials{}, ErrNoAWSCredentials
+	})
+	var rt = NewSigV4(failing, "us-east-1", "execute-api")(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		t.Fatal("request was sent without a signature")
+		return nil, nil
+	}))
+	var req, _ = http.NewRequest(http.MethodGet, "https://api.example.com/", nil)
+	var _, e = rt.RoundTrip(req)
+	assert.True(t, errors.Is(e, ErrNoAWSCredentials))
+}
+
+func TestSigV4Escape(t *testing.T) {
+	assert.Equal(t, "/a%2520b/~c", sigV4Escape("/a%20b/~c", false))
+	assert.Equal(t, "a%2Fb%3D", sigV4Escape("a/b=", true))
+}
@@ -0,0 +1,58 @@
+package transport
+
+import "context"
+
+type skipKey struct{}
+
+// skipFlags identifies the decorators that a request opts out of.
+type skipFlags uint8
+
+const (
+	skipRetry skipFlags = 1 << iota
+	skipCache
+	skipLogging
+)
+
+func withSkip(ctx context.Context, flag skipFlags) context.Context {
+	return context.WithValue(ctx, skipKey{}, skipped(ctx)|flag)
+}
+
+func skipped(ctx context.Context) skipFlags {
+	var flags, _ = ctx.Value(skipKey{}).(skipFlags)
+	return flags
+}
+
+// SkipRetry marks requests made with the context as opting out of the Retry
+// and RetryAfter decorators. Each request is sent exactly once by those
+// decorators and the outcome is returned unchanged.
+func SkipRetry(ctx context.Context) context.Context {
+	return withSkip(ctx, skipRetry)
+}
+
+// SkipCache marks requests made with the context as opting out of response
+// caching. This package does not include a cache so the flag is for caching
+// decorators to honor through CacheSkipped.
+func SkipCache(ctx context.Context) context.Context {
+	return withSkip(ctx, skipCache)
+}
+
+// SkipLogging marks requests made with the context as opting out of the access
+// log, such as for frequent health checks.
+func SkipLogging(ctx context.Context) context.Context {
+	return withSkip(ctx, skipLogging)
+}
+
+// RetrySkipped reports whether SkipRetry was applied to the context.
+func RetrySkipped(ctx context.Context) bool {
+	return skipped(ctx)&skipRetry != 0
+}
+
+// CacheSkipped reports whether SkipCache was applied to the context.
+func CacheSkipped(ctx context.Context) bool {
+	return skipped(ctx)&skipCache != 0
+}
+
+// LoggingSkipped reports whether SkipLogging was applied to the context.
+func LoggingSkipped(ctx context.Context) bool {
+	return skipped(ctx)&skipLogging != 0
+}
@@ -0,0 +1,62 @@
+package transport
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/asecurityteam/logevent/v2"
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSkipFlags(t *testing.T) {
+	var ctx = context.Background()
+	assert.False(t, RetrySkipped(ctx))
+	assert.False(t, CacheSkipped(ctx))
+	assert.False(t, LoggingSkipped(ctx))
+
+	ctx = SkipLogging(SkipRetry(ctx))
+	assert.True(t, RetrySkipped(ctx))
+	assert.False(t, CacheSkipped(ctx))
+	assert.True(t, LoggingSkipped(ctx))
+
+	ctx = SkipCache(ctx)
+	assert.True(t, CacheSkipped(ctx))
+}
+
+func TestSkipRetry(t *testing.T) {
+	var ctrl = gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var wrapped = NewMockRoundTripper(ctrl)
+	var chain = Chain{
+		NewRetryAfter(),
+		NewRetrier(NewFixedBackoffPolicy(time.Millisecond), NewStatusCodeRetryPolicy(http.StatusInternalServerError)),
+	}
+	var rt = chain.Apply(wrapped)
+	wrapped.EXPECT().RoundTrip(gomock.Any()).Return(&http.Response{StatusCode: http.StatusInternalServerError, Body: http.NoBody}, nil)
+
+	var req, _ = http.NewRequest(http.MethodGet, "/", nil)
+	req = req.WithContext(SkipRetry(req.Context()))
+	var resp, e = rt.RoundTrip(req)
+	require.NoError(t, e)
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+}
+
+func TestSkipLogging(t *testing.T) {
+	var ctrl = gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var logger = NewMockLogger(ctrl)
+	var wrapped = NewMockRoundTripper(ctrl)
+	var req = httptest.NewRequest(http.MethodGet, "https://localhost/", http.NoBody)
+	req = req.WithContext(SkipLogging(logevent.NewContext(req.Context(), logger)))
+	wrapped.EXPECT().RoundTrip(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil)
+
+	var _, e = NewAccessLog()(wrapped).RoundTrip(req)
+	require.NoError(t, e)
+}
@@ -0,0 +1,96 @@
+package transport
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// DeadlineSlack is a decorator that reserves part of the time remaining
+// before the deadline of a request for the caller to process the response.
+// The request, including the reading of its response body, is given a
+// deadline that falls before the one of the caller so that a slow upstream
+// fails the request while the caller still has time to handle the outcome,
+// rather than a seemingly successful request failing with a deadline
+// exceeded error part way through decoding its body. Requests without a
+// deadline are not affected.
+type DeadlineSlack struct {
+	wrapped  http.RoundTripper
+	slack    time.Duration
+	fraction float64
+	minimum  time.Duration
+	clock    Clock
+}
+
+// DeadlineSlackOption is a configuration for the DeadlineSlack decorator.
+type DeadlineSlackOption func(*DeadlineSlack) *DeadlineSlack
+
+// DeadlineSlackOptionFraction reserves a fraction, between 0 and 1, of the
+// time remaining before the deadline when it is more than the fixed slack.
+func DeadlineSlackOptionFraction(fraction float64) DeadlineSlackOption {
+	return func(d *DeadlineSlack) *DeadlineSlack {
+		d.fraction = fraction
+		return d
+	}
+}
+
+// DeadlineSlackOptionMinimum sets the least time given to the request. Less
+// slack is reserved when reserving all of it would leave the request with less
+// than the minimum. The default is zero.
+func DeadlineSlackOptionMinimum(minimum time.Duration) DeadlineSlackOption {
+	return func(d *DeadlineSlack) *DeadlineSlack {
+		d.minimum = minimum
+		return d
+	}
+}
+
+// DeadlineSlackOptionClock configures the Clock used to measure the time
+// remaining before a deadline.
+func DeadlineSlackOptionClock(clock Clock) DeadlineSlackOption {
+	return func(d *DeadlineSlack) *DeadlineSlack {
+		d.clock = clock
+		return d
+	}
+}
+
+// RoundTrip sends the request with a deadline that leaves the slack to the
+// caller. The shortened deadline is released when the response body is
+// closed.
+func (c *DeadlineSlack) RoundTrip(r *http.Request) (*http.Response, error) {
+	var deadline, ok = r.Context().Deadline()
+	if !ok {
+		return c.wrapped.RoundTrip(r)
+	}
+	var remaining = deadline.Sub(c.clock.Now())
+	var reserve = c.slack
+	if fractional := time.Duration(c.fraction * float64(remaining)); fractional > reserve {
+		reserve = fractional
+	}
+	var budget = remaining - reserve
+	if budget < c.minimum {
+		budget = c.minimum
+	}
+	if budget >= remaining {
+		return c.wrapped.RoundTrip(r)
+	}
+	var ctx, cancel = context.WithDeadline(r.Context(), deadline.Add(budget-remaining))
+	var resp, e = c.wrapped.RoundTrip(r.WithContext(ctx))
+	if e != nil {
+		cancel()
+		return resp, e
+	}
+	return withCancelBody(resp, cancel), nil
+}
+
+// NewDeadlineSlack configures a RoundTripper decorator that reserves the slack
+// from the deadline of every request for processing the response. It should
+// be placed outside of any Retry decorator so that retries also stop in time.
+func NewDeadlineSlack(slack time.Duration, opts ...DeadlineSlackOption) func(http.RoundTripper) http.RoundTripper {
+	return func(wrapped http.RoundTripper) http.RoundTripper {
+		var d = &DeadlineSlack{wrapped: wrapped, slack: slack, clock: NewSystemClock()}
+		for _, opt := range opts {
+			d = opt(d)
+		}
+		return d
+	}
+}
@@ -0,0 +1,75 @@
+package transport
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeadlineSlack(t *testing.T) {
+	// The contexts measure the deadline against the system time so the fake
+	// clock must start from it.
+	var clock = &fakeClock{now: time.Now()}
+	var deadline = clock.Now().Add(10 * time.Second)
+	var tests = []struct {
+		name     string
+		opts     []DeadlineSlackOption
+		expected time.Duration
+	}{
+		{name: "fixed", expected: 8 * time.Second},
+		{name: "fraction", opts: []DeadlineSlackOption{DeadlineSlackOptionFraction(.5)}, expected: 5 * time.Second},
+		{name: "small fraction", opts: []DeadlineSlackOption{DeadlineSlackOptionFraction(.1)}, expected: 8 * time.Second},
+		{name: "minimum", opts: []DeadlineSlackOption{DeadlineSlackOptionMinimum(9 * time.Second)}, expected: 9 * time.Second},
+		{name: "minimum above remaining", opts: []DeadlineSlackOption{DeadlineSlackOptionMinimum(time.Minute)}, expected: 10 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attemptCtx context.Context
+			var rt = NewDeadlineSlack(2*time.Second, append(tt.opts, DeadlineSlackOptionClock(clock))...)(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+				attemptCtx = r.Context()
+				return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+			}))
+			var ctx, cancel = context.WithDeadline(context.Background(), deadline)
+			defer cancel()
+			var req, _ = http.NewRequestWithContext(ctx, http.MethodGet, "https://example.com/", nil)
+			var resp, e = rt.RoundTrip(req)
+			require.NoError(t, e)
+			var reduced, ok = attemptCtx.Deadline()
+			require.True(t, ok)
+			assert.Equal(t, clock.Now().Add(tt.expected), reduced)
+			require.NoError(t, resp.Body.Close())
+			if tt.expected < 10*time.Second {
+				assert.ErrorIs(t, attemptCtx.Err(), context.Canceled, "closing the body did not release the context")
+			}
+		})
+	}
+}
+
+func TestDeadlineSlackNoDeadline(t *testing.T) {
+	var attemptCtx context.Context
+	var rt = NewDeadlineSlack(time.Second)(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		attemptCtx = r.Context()
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	}))
+	var req, _ = http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	var _, e = rt.RoundTrip(req)
+	require.NoError(t, e)
+	assert.Equal(t, req.Context(), attemptCtx)
+}
+
+func TestDeadlineSlackExpires(t *testing.T) {
+	var rt = NewDeadlineSlack(time.Hour, DeadlineSlackOptionMinimum(10*time.Millisecond))(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		<-r.Context().Done()
+		return nil, r.Context().Err()
+	}))
+	var ctx, cancel = context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	var req, _ = http.NewRequestWithContext(ctx, http.MethodGet, "https://example.com/", nil)
+	var _, e = rt.RoundTrip(req)
+	assert.ErrorIs(t, e, context.DeadlineExceeded)
+	assert.NoError(t, ctx.Err(), "the slack of the caller was used")
+}
@@ -0,0 +1,266 @@
+package transport
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const sloBuckets = 10
+
+// SLO describes the objectives for an operation. A request fails the
+// availability objective if it returns an error or a 5xx status code, and
+// fails the latency objective if it takes longer than Latency.
+type SLO struct {
+	// Availability is the target ratio of successful requests, such as .999.
+	Availability float64
+	// Latency is the duration within which requests should complete. Latency
+	// objectives are not tracked if it is zero.
+	Latency time.Duration
+	// LatencyTarget is the target ratio of requests that complete within
+	// Latency, such as .99.
+	LatencyTarget float64
+}
+
+// SLOStatus is a snapshot of how an operation performed against its SLO over
+// the tracking window. A burn rate of one means that errors are consuming the
+// budget exactly as fast as the objective allows. Higher values consume it
+// faster.
+type SLOStatus struct {
+	Operation        string
+	Requests         int64
+	Failures         int64
+	Slow             int64
+	AvailabilityBurn float64
+	LatencyBurn      float64
+}
+
+type sloBucket struct {
+	epoch    int64
+	requests int64
+	failures int64
+	slow     int64
+}
+
+type sloOperation struct {
+	slo      SLO
+	buckets  [sloBuckets]sloBucket
+	alerting bool
+}
+
+// SLOTracker records the outcome of requests per operation, as named by the
+// TagOperation tag, and computes burn rates over a sliding window.
+type SLOTracker struct {
+	lock           sync.Mutex
+	targets        map[string]SLO
+	defaultSLO     *SLO
+	operations     map[string]*sloOperation
+	window         time.Duration
+	clock          Clock
+	alertThreshold float64
+	alertMin       int64
+	alert          func(SLOStatus)
+}
+
+// SLOTrackerOption is a configuration for the SLOTracker.
+type SLOTrackerOption func(*SLOTracker) *SLOTracker
+
+// SLOTrackerOptionWindow sets the sliding window over which burn rates are
+// computed. The default is one hour.
+func SLOTrackerOptionWindow(window time.Duration) SLOTrackerOption {
+	return func(t *SLOTracker) *SLOTracker {
+		t.window = window
+		return t
+	}
+}
+
+// SLOTrackerOptionDefault sets the SLO used for operations that have no
+// target of their own, including requests with no operation tag.
+func SLOTrackerOptionDefault(slo SLO) SLOTrackerOption {
+	return func(t *SLOTracker) *SLOTracker {
+		t.defaultSLO = &slo
+		return t
+	}
+}
+
+// SLOTrackerOptionAlert calls the callback when either burn rate of an
+// operation reaches the threshold, once the window contains at least
+// minRequests requests. The callback is called again only after the burn
+// rates fall below the threshold and then reach it again. It is called while
+// the request that crossed the threshold is being handled and must not block.
+func SLOTrackerOptionAlert(threshold float64, minRequests int64, callback func(SLOStatus)) SLOTrackerOption {
+	return func(t *SLOTracker) *SLOTracker {
+		t.alertThreshold = threshold
+		t.alertMin = minRequests
+		t.alert = callback
+		return t
+	}
+}
+
+// SLOTrackerOptionClock configures the Clock used to place requests in the
+// window.
+func SLOTrackerOptionClock(clock Clock) SLOTrackerOption {
+	return func(t *SLOTracker) *SLOTracker {
+		t.clock = clock
+		return t
+	}
+}
+
+// NewSLOTracker creates an SLOTracker for the given operation targets.
+func NewSLOTracker(targets map[string]SLO, opts ...SLOTrackerOption) *SLOTracker {
+	var t = &SLOTracker{
+		targets:    targets,
+		operations: make(map[string]*sloOperation),
+		window:     time.Hour,
+		clock:      NewSystemClock(),
+	}
+	for _, opt := range opts {
+		t = opt(t)
+	}
+	return t
+}
+
+func (t *SLOTracker) bucketWidth() int64 {
+	var width = int64(t.window) / sloBuckets
+	if width < 1 {
+		return 1
+	}
+	return width
+}
+
+// Record adds the outcome of a request for the operation. Outcomes for
+// operations without an SLO are discarded.
+func (t *SLOTracker) Record(operation string, failed bool, duration time.Duration) {
+	t.lock.Lock()
+	var op = t.operation(operation)
+	if op == nil {
+		t.lock.Unlock()
+		return
+	}
+	var epoch = t.clock.Now().UnixNano() / t.bucketWidth()
+	var bucket = &op.buckets[epoch%sloBuckets]
+	if bucket.epoch != epoch {
+		*bucket = sloBucket{epoch: epoch}
+	}
+	bucket.requests = bucket.requests + 1
+	if failed {
+		bucket.failures = bucket.failures + 1
+	}
+	if op.slo.Latency > 0 && duration > op.slo.Latency {
+		bucket.slow = bucket.slow + 1
+	}
+	var alert func(SLOStatus)
+	var status SLOStatus
+	if t.alert != nil {
+		status = t.status(operation, op, epoch)
+		var burning = status.Requests >= t.alertMin &&
+			(status.AvailabilityBurn >= t.alertThreshold || status.LatencyBurn >= t.alertThreshold)
+		if burning && !op.alerting {
+			alert = t.alert
+		}
+		op.alerting = burning
+	}
+	t.lock.Unlock()
+	if alert != nil {
+		alert(status)
+	}
+}
+
+func (t *SLOTracker) operation(name string) *sloOperation {
+	if op, ok := t.operations[name]; ok {
+		return op
+	}
+	var slo, ok = t.targets[name]
+	if !ok {
+		if t.defaultSLO == nil {
+			return nil
+		}
+		slo = *t.defaultSLO
+	}
+	var op = &sloOperation{slo: slo}
+	t.operations[name] = op
+	return op
+}
+
+func (t *SLOTracker) status(name string, op *sloOperation, epoch int64) SLOStatus {
+	var status = SLOStatus{Operation: name}
+	for _, bucket := range op.buckets {
+		if epoch-bucket.epoch >= sloBuckets {
+			continue
+		}
+		status.Requests = status.Requests + bucket.requests
+		status.Failures = status.Failures + bucket.failures
+		status.Slow = status.Slow + bucket.slow
+	}
+	if status.Requests > 0 {
+		status.AvailabilityBurn = burnRate(status.Failures, status.Requests, op.slo.Availability)
+		if op.slo.Latency > 0 {
+			status.LatencyBurn = burnRate(status.Slow, status.Requests, op.slo.LatencyTarget)
+		}
+	}
+	return status
+}
+
+func burnRate(bad int64, total int64, target float64) float64 {
+	var budget = 1 - target
+	var ratio = float64(bad) / float64(total)
+	if budget <= 0 {
+		if bad > 0 {
+			return math.Inf(1)
+		}
+		return 0
+	}
+	return ratio / budget
+}
+
+// Status returns the current SLOStatus of an operation and whether the
+// operation has recorded any requests.
+func (t *SLOTracker) Status(operation string) (SLOStatus, bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	var op, ok = t.operations[operation]
+	if !ok {
+		return SLOStatus{}, false
+	}
+	return t.status(operation, op, t.clock.Now().UnixNano()/t.bucketWidth()), true
+}
+
+// Statuses returns the SLOStatus of every operation that has recorded
+// requests, ordered by operation name.
+func (t *SLOTracker) Statuses() []SLOStatus {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	var epoch = t.clock.Now().UnixNano() / t.bucketWidth()
+	var statuses = make([]SLOStatus, 0, len(t.operations))
+	for name, op := range t.operations {
+		statuses = append(statuses, t.status(name, op, epoch))
+	}
+	sort.Slice(statuses, func(i int, j int) bool {
+		return statuses[i].Operation < statuses[j].Operation
+	})
+	return statuses
+}
+
+type sloTransport struct {
+	wrapped http.RoundTripper
+	tracker *SLOTracker
+}
+
+// RoundTrip records the outcome of the request in the tracker.
+func (c *sloTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	var start = c.tracker.clock.Now()
+	var resp, e = c.wrapped.RoundTrip(r)
+	var failed = e != nil || resp.StatusCode >= http.StatusInternalServerError
+	c.tracker.Record(tag(r.Context(), TagOperation), failed, c.tracker.clock.Now().Sub(start))
+	return resp, e
+}
+
+// NewSLOTracking configures a RoundTripper decorator that records every
+// request in the tracker under the operation named by its TagOperation tag.
+func NewSLOTracking(tracker *SLOTracker) func(http.RoundTripper) http.RoundTripper {
+	return func(wrapped http.RoundTripper) http.RoundTripper {
+		return &sloTransport{wrapped: wrapped, tracker: tracker}
+	}
+}
@@ -0,0 +1,104 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSLOTrackerBurnRates(t *testing.T) {
+	var clock = newFakeClock()
+	var tracker = NewSLOTracker(
+		map[string]SLO{"get-user": {Availability: .9, Latency: time.Second, LatencyTarget: .5}},
+		SLOTrackerOptionWindow(10*time.Minute),
+		SLOTrackerOptionClock(clock),
+	)
+	for x := 0; x < 8; x = x + 1 {
+		tracker.Record("get-user", false, time.Millisecond)
+	}
+	tracker.Record("get-user", true, 2*time.Second)
+	tracker.Record("get-user", true, time.Millisecond)
+	tracker.Record("unknown", true, time.Millisecond)
+
+	var status, ok = tracker.Status("get-user")
+	require.True(t, ok)
+	assert.Equal(t, int64(10), status.Requests)
+	assert.Equal(t, int64(2), status.Failures)
+	assert.Equal(t, int64(1), status.Slow)
+	assert.InDelta(t, 2, status.AvailabilityBurn, 1e-9)
+	assert.InDelta(t, .2, status.LatencyBurn, 1e-9)
+	_, ok = tracker.Status("unknown")
+	assert.False(t, ok)
+
+	clock.advance(5 * time.Minute)
+	tracker.Record("get-user", false, time.Millisecond)
+	status, _ = tracker.Status("get-user")
+	assert.Equal(t, int64(11), status.Requests)
+
+	clock.advance(6 * time.Minute)
+	status, _ = tracker.Status("get-user")
+	assert.Equal(t, int64(1), status.Requests)
+	assert.Zero(t, status.AvailabilityBurn)
+}
+
+func TestSLOTrackerAlert(t *testing.T) {
+	var alerts []SLOStatus
+	var tracker = NewSLOTracker(
+		nil,
+		SLOTrackerOptionDefault(SLO{Availability: .5}),
+		SLOTrackerOptionAlert(1, 4, func(s SLOStatus) { alerts = append(alerts, s) }),
+		SLOTrackerOptionClock(newFakeClock()),
+	)
+	tracker.Record("", true, 0)
+	tracker.Record("", true, 0)
+	tracker.Record("", true, 0)
+	assert.Empty(t, alerts, "alerted before the minimum number of requests")
+	tracker.Record("", true, 0)
+	tracker.Record("", true, 0)
+	require.Len(t, alerts, 1)
+	assert.Equal(t, int64(4), alerts[0].Requests)
+	for x := 0; x < 6; x = x + 1 {
+		tracker.Record("", false, 0)
+	}
+	tracker.Record("", true, 0)
+	tracker.Record("", true, 0)
+	assert.Len(t, alerts, 2)
+	assert.Len(t, tracker.Statuses(), 1)
+}
+
+func TestBurnRateWithoutBudget(t *testing.T) {
+	assert.True(t, math.IsInf(burnRate(1, 10, 1), 1))
+	assert.Zero(t, burnRate(0, 10, 1))
+}
+
+func TestSLOTracking(t *testing.T) {
+	var tracker = NewSLOTracker(map[string]SLO{"get-user": {Availability: .99}})
+	var responses = []*http.Response{
+		{StatusCode: http.StatusOK, Body: http.NoBody},
+		{StatusCode: http.StatusServiceUnavailable, Body: http.NoBody},
+		nil,
+	}
+	var rt = NewSLOTracking(tracker)(RoundTripperFunc(func(*http.Request) (*http.Response, error) {
+		var resp = responses[0]
+		responses = responses[1:]
+		if resp == nil {
+			return nil, errors.New("failed")
+		}
+		return resp, nil
+	}))
+	var ctx = WithTags(context.Background(), map[string]string{TagOperation: "get-user"})
+	var req, _ = http.NewRequestWithContext(ctx, http.MethodGet, "/", nil)
+	for x := 0; x < 3; x = x + 1 {
+		_, _ = rt.RoundTrip(req)
+	}
+	var status, ok = tracker.Status("get-user")
+	require.True(t, ok)
+	assert.Equal(t, int64(3), status.Requests)
+	assert.Equal(t, int64(2), status.Failures)
+}
@@ -0,0 +1,96 @@
+package transport
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/asecurityteam/logevent/v2"
+)
+
+const softTimeoutSource = "soft_timeout"
+
+type softTimeoutLog struct {
+	Host      string `logevent:"host"`
+	Method    string `logevent:"http_method"`
+	URIPath   string `logevent:"uri_path"`
+	Threshold int    `logevent:"threshold"`
+	Message   string `logevent:"message,default=soft-timeout"`
+}
+
+// LogSoftTimeout is the default callback of the SoftTimeout decorator. It
+// writes a warning, with the threshold in milliseconds, to the logger in the
+// context of the request.
+func LogSoftTimeout(r *http.Request, threshold time.Duration) {
+	logevent.FromContext(r.Context()).Warn(softTimeoutLog{
+		Host:      r.URL.Host,
+		Method:    r.Method,
+		URIPath:   r.URL.Path,
+		Threshold: int(threshold / time.Millisecond),
+	})
+}
+
+// SoftTimeout is a decorator that reports requests that are still waiting for
+// a response after a threshold without ending them. Setting the threshold
+// below the hard timeout of the client reveals latency regressions before
+// they turn into failures. Each slow request is reported once, through the
+// callback and an EventSoftTimeout event.
+type SoftTimeout struct {
+	wrapped   http.RoundTripper
+	threshold time.Duration
+	callback  func(*http.Request, time.Duration)
+	clock     Clock
+}
+
+// SoftTimeoutOption is a configuration for the SoftTimeout decorator.
+type SoftTimeoutOption func(*SoftTimeout) *SoftTimeout
+
+// SoftTimeoutOptionCallback sets the function called, from a separate
+// goroutine, with each request that exceeds the threshold and the threshold
+// itself. It may record a metric or log the request. The default is
+// LogSoftTimeout.
+func SoftTimeoutOptionCallback(callback func(*http.Request, time.Duration)) SoftTimeoutOption {
+	return func(s *SoftTimeout) *SoftTimeout {
+		s.callback = callback
+		return s
+	}
+}
+
+// SoftTimeoutOptionClock configures the Clock used to time requests.
+func SoftTimeoutOptionClock(clock Clock) SoftTimeoutOption {
+	return func(s *SoftTimeout) *SoftTimeout {
+		s.clock = clock
+		return s
+	}
+}
+
+// RoundTrip sends the request and reports it if the response headers have not
+// arrived within the threshold.
+func (c *SoftTimeout) RoundTrip(r *http.Request) (*http.Response, error) {
+	var done = make(chan struct{})
+	var timer = c.clock.NewTimer(c.threshold)
+	goLabeled(r.Context(), softTimeoutSource, r.URL.Host, func(context.Context) {
+		defer timer.Stop()
+		select {
+		case <-done:
+		case <-r.Context().Done():
+		case <-timer.C():
+			emitEvent(r.Context(), TransportEvent{Type: EventSoftTimeout, Source: softTimeoutSource, Request: r, Duration: c.threshold})
+			c.callback(r, c.threshold)
+		}
+	})
+	defer close(done)
+	return c.wrapped.RoundTrip(r)
+}
+
+// NewSoftTimeout configures a RoundTripper decorator that reports requests
+// taking longer than the threshold to receive a response.
+func NewSoftTimeout(threshold time.Duration, opts ...SoftTimeoutOption) func(http.RoundTripper) http.RoundTripper {
+	return func(wrapped http.RoundTripper) http.RoundTripper {
+		var s = &SoftTimeout{wrapped: wrapped, threshold: threshold, callback: LogSoftTimeout, clock: NewSystemClock()}
+		for _, opt := range opts {
+			s = opt(s)
+		}
+		return s
+	}
+}
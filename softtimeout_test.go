@@ -0,0 +1,59 @@
+package transport
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/asecurityteam/logevent/v2"
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSoftTimeout(t *testing.T) {
+	var reported = make(chan time.Duration, 1)
+	var release = make(chan struct{})
+	var collector = &eventCollector{}
+	var rt = NewSoftTimeout(10*time.Millisecond, SoftTimeoutOptionCallback(func(r *http.Request, threshold time.Duration) {
+		assert.Equal(t, "/slow", r.URL.Path)
+		reported <- threshold
+	}))(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		<-release
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	}))
+	var done = make(chan error)
+	go func() {
+		var req, _ = http.NewRequest(http.MethodGet, "https://example.com/slow", nil)
+		req = req.WithContext(WithEventSubscriber(req.Context(), collector))
+		var _, e = rt.RoundTrip(req)
+		done <- e
+	}()
+	assert.Equal(t, 10*time.Millisecond, <-reported)
+	close(release)
+	require.NoError(t, <-done, "the request was not allowed to continue")
+	assert.Equal(t, []EventType{EventSoftTimeout}, collector.types())
+}
+
+func TestSoftTimeoutFast(t *testing.T) {
+	var rt = NewSoftTimeout(time.Hour, SoftTimeoutOptionCallback(func(*http.Request, time.Duration) {
+		t.Error("a fast request was reported")
+	}))(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	}))
+	var req, _ = http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	var _, e = rt.RoundTrip(req)
+	require.NoError(t, e)
+}
+
+func TestLogSoftTimeout(t *testing.T) {
+	var ctrl = gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var logger = NewMockLogger(ctrl)
+	logger.EXPECT().Warn(softTimeoutLog{Host: "example.com", Method: http.MethodGet, URIPath: "/slow", Threshold: 250})
+	var req = httptest.NewRequest(http.MethodGet, "https://example.com/slow", http.NoBody)
+	req = req.WithContext(logevent.NewContext(req.Context(), logger))
+	LogSoftTimeout(req, 250*time.Millisecond)
+}
@@ -0,0 +1,79 @@
+package transport
+
+import (
+	"errors"
+	"net/http"
+)
+
+var (
+	// ErrClientStatus matches, with errors.Is, the StatusError and
+	// UpstreamError of a response with a 4xx status.
+	ErrClientStatus = errors.New("transport: client error status")
+	// ErrServerStatus matches, with errors.Is, the StatusError and
+	// UpstreamError of a response with a 5xx status.
+	ErrServerStatus = errors.New("transport: server error status")
+)
+
+// statusClassIs reports whether the status belongs to the class represented
+// by the target error.
+func statusClassIs(status int, target error) bool {
+	switch target {
+	case ErrClientStatus:
+		return status >= 400 && status <= 499
+	case ErrServerStatus:
+		return status >= 500 && status <= 599
+	}
+	return false
+}
+
+// NewStatusClassPolicy generates a policy for the ErrorFromStatus decorator
+// that returns a *StatusError for responses in any of the status classes,
+// given as the first digit of the status such as 5 for 5xx.
+func NewStatusClassPolicy(classes ...int) func(*http.Response) error {
+	return func(resp *http.Response) error {
+		for _, class := range classes {
+			if resp.StatusCode/100 == class {
+				return newStatusError(resp)
+			}
+		}
+		return nil
+	}
+}
+
+// ErrorFromStatus is a decorator that converts responses into errors
+// according to a policy, so that retry policies based on errors and callers
+// using errors.Is handle failures the same way whether they happened on the
+// network or were reported with an HTTP status.
+type ErrorFromStatus struct {
+	wrapped http.RoundTripper
+	policy  func(*http.Response) error
+}
+
+// RoundTrip returns the error given by the policy, if any, in place of the
+// response. The response body is drained and closed when it is replaced by an
+// error.
+func (c *ErrorFromStatus) RoundTrip(r *http.Request) (*http.Response, error) {
+	var resp, e = c.wrapped.RoundTrip(r)
+	if e != nil {
+		return resp, e
+	}
+	if e = c.policy(resp); e != nil {
+		drainBody(resp)
+		return nil, e
+	}
+	return resp, nil
+}
+
+// NewErrorFromStatus configures a RoundTripper decorator that replaces
+// responses with the error returned by the policy. The policy returns nil for
+// responses that should be returned as they are. Policies that keep part of
+// the body in their error, such as NewStatusClassPolicy or one built on
+// ParseUpstreamError, must read it before returning because the body is
+// closed afterwards. Place the decorator inside of a Retry decorator for its
+// errors to be seen by error based retry policies such as
+// NewErrorRetryPolicy.
+func NewErrorFromStatus(policy func(*http.Response) error) func(http.RoundTripper) http.RoundTripper {
+	return func(wrapped http.RoundTripper) http.RoundTripper {
+		return &ErrorFromStatus{wrapped: wrapped, policy: policy}
+	}
+}
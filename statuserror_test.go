@@ -0,0 +1,115 @@
+package transport
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatusErrorIs(t *testing.T) {
+	var tests = []struct {
+		status int
+		client bool
+		server bool
+	}{
+		{status: http.StatusBadRequest, client: true},
+		{status: http.StatusTooManyRequests, client: true},
+		{status: http.StatusInternalServerError, server: true},
+		{status: http.StatusServiceUnavailable, server: true},
+		{status: http.StatusFound},
+	}
+	for _, test := range tests {
+		for _, e := range []error{&StatusError{StatusCode: test.status}, &UpstreamError{StatusCode: test.status}} {
+			if errors.Is(e, ErrClientStatus) != test.client {
+				t.Fatalf("%T with status %d: expected ErrClientStatus match to be %t", e, test.status, test.client)
+			}
+			if errors.Is(e, ErrServerStatus) != test.server {
+				t.Fatalf("%T with status %d: expected ErrServerStatus match to be %t", e, test.status, test.server)
+			}
+		}
+	}
+}
+
+func TestErrorFromStatus(t *testing.T) {
+	var status int
+	var body *closeTrackingBody
+	var base = RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		body = &closeTrackingBody{Reader: strings.NewReader("upstream failed")}
+		return &http.Response{StatusCode: status, Header: http.Header{}, Body: body, Request: r}, nil
+	})
+	var client = &http.Client{Transport: NewErrorFromStatus(NewStatusClassPolicy(5))(base)}
+
+	status = http.StatusBadGateway
+	var resp, e = client.Get("http://localhost/widgets")
+	if resp != nil {
+		t.Fatal("returned a response along with the error")
+	}
+	if !errors.Is(e, ErrServerStatus) {
+		t.Fatalf("expected an ErrServerStatus match but got %v", e)
+	}
+	var statusErr *StatusError
+	if !errors.As(e, &statusErr) || statusErr.StatusCode != http.StatusBadGateway || string(statusErr.Body) != "upstream failed" {
+		t.Fatalf("unexpected error %v", e)
+	}
+	if !body.closed {
+		t.Fatal("did not close the response body")
+	}
+
+	status = http.StatusNotFound
+	if resp, e = client.Get("http://localhost/widgets"); e != nil || resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected the response to pass through but got %v", e)
+	}
+	if body.closed {
+		t.Fatal("closed the body of a response that passed through")
+	}
+	_ = resp.Body.Close()
+}
+
+func TestErrorFromStatusCustomPolicy(t *testing.T) {
+	var base = RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusServiceUnavailable,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(`{"code":"maintenance","message":"back soon"}`)),
+		}, nil
+	})
+	var rt = NewErrorFromStatus(func(resp *http.Response) error {
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return ParseUpstreamError(resp)
+		}
+		return nil
+	})(base)
+	var req, _ = http.NewRequest(http.MethodGet, "http://localhost/widgets", http.NoBody)
+	var _, e = rt.RoundTrip(req)
+	var upstream *UpstreamError
+	if !errors.As(e, &upstream) || upstream.Code != "maintenance" || !errors.Is(e, ErrServerStatus) {
+		t.Fatalf("unexpected error %v", e)
+	}
+}
+
+func TestErrorFromStatusRetry(t *testing.T) {
+	var attempts int
+	var base = RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		attempts = attempts + 1
+		var status = http.StatusServiceUnavailable
+		if attempts == 3 {
+			status = http.StatusOK
+		}
+		return &http.Response{StatusCode: status, Header: http.Header{}, Body: http.NoBody}, nil
+	})
+	var rt = Chain{
+		NewRetrier(NewFixedBackoffPolicy(time.Millisecond), NewLimitedRetryPolicy(5, NewErrorRetryPolicy(ErrServerStatus))),
+		NewErrorFromStatus(NewStatusClassPolicy(5)),
+	}.Apply(base)
+	var req, _ = http.NewRequest(http.MethodGet, "http://localhost/widgets", http.NoBody)
+	var resp, e = rt.RoundTrip(req)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if resp.StatusCode != http.StatusOK || attempts != 3 {
+		t.Fatalf("expected a success on the third attempt but got %d after %d", resp.StatusCode, attempts)
+	}
+}
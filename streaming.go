@@ -0,0 +1,43 @@
+package transport
+
+import (
+	"context"
+	"mime"
+	"net/http"
+)
+
+const contentTypeEventStream = "text/event-stream"
+
+type streamingKey struct{}
+
+// WithStreaming marks requests made with the context as expecting a streaming
+// response, such as a long poll, whose body is intentionally unbounded.
+func WithStreaming(ctx context.Context) context.Context {
+	return context.WithValue(ctx, streamingKey{}, true)
+}
+
+// IsStreamingRequest reports whether the request was marked with
+// WithStreaming or asks for a server-sent event stream.
+func IsStreamingRequest(r *http.Request) bool {
+	if streaming, _ := r.Context().Value(streamingKey{}).(bool); streaming {
+		return true
+	}
+	return isEventStream(r.Header.Get("Accept"))
+}
+
+// IsStreamingResponse reports whether the response is a server-sent event
+// stream or a response to a streaming request.
+func IsStreamingResponse(resp *http.Response) bool {
+	if resp == nil {
+		return false
+	}
+	if isEventStream(resp.Header.Get("Content-Type")) {
+		return true
+	}
+	return resp.Request != nil && IsStreamingRequest(resp.Request)
+}
+
+func isEventStream(contentType string) bool {
+	var mediaType, _, _ = mime.ParseMediaType(contentType)
+	return mediaType == contentTypeEventStream
+}
@@ -0,0 +1,63 @@
+package transport
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsStreamingRequest(t *testing.T) {
+	var req, _ = http.NewRequest(http.MethodGet, "https://localhost", nil)
+	assert.False(t, IsStreamingRequest(req))
+	req.Header.Set("Accept", "text/event-stream")
+	assert.True(t, IsStreamingRequest(req))
+
+	req, _ = http.NewRequestWithContext(WithStreaming(context.Background()), http.MethodGet, "https://localhost", nil)
+	assert.True(t, IsStreamingRequest(req))
+}
+
+func TestIsStreamingResponse(t *testing.T) {
+	assert.False(t, IsStreamingResponse(nil))
+	assert.False(t, IsStreamingResponse(&http.Response{Header: http.Header{}}))
+	assert.True(t, IsStreamingResponse(&http.Response{
+		Header: http.Header{"Content-Type": []string{"text/event-stream; charset=utf-8"}},
+	}))
+
+	var req, _ = http.NewRequestWithContext(WithStreaming(context.Background()), http.MethodGet, "https://localhost", nil)
+	assert.True(t, IsStreamingResponse(&http.Response{Header: http.Header{}, Request: req}))
+}
+
+func TestRetryDoesNotReissueStreamingResponses(t *testing.T) {
+	var calls int
+	var rt = NewRetrier(
+		NewFixedBackoffPolicy(0),
+		NewLimitedRetryPolicy(3, NewStatusCodeRetryPolicy(http.StatusOK)),
+	)(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		calls = calls + 1
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+			Body:       http.NoBody,
+		}, nil
+	}))
+	var req, _ = http.NewRequest(http.MethodGet, "https://localhost", nil)
+	var _, e = rt.RoundTrip(req)
+	assert.NoError(t, e)
+	assert.Equal(t, 1, calls)
+}
+
+func TestRetryPassesThroughStreamingRequests(t *testing.T) {
+	var req, _ = http.NewRequestWithContext(WithStreaming(context.Background()), http.MethodGet, "https://localhost", nil)
+	var rt = NewRetrier(
+		NewFixedBackoffPolicy(0),
+		NewLimitedRetryPolicy(3, NewStatusCodeRetryPolicy(http.StatusInternalServerError)),
+	)(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		assert.Equal(t, req, r)
+		return &http.Response{StatusCode: http.StatusInternalServerError, Body: http.NoBody}, nil
+	}))
+	var resp, e = rt.RoundTrip(req)
+	assert.NoError(t, e)
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+}
@@ -0,0 +1,160 @@
+package transport
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const subscriptionSource = "event_subscription"
+
+// Subscription is an EventSubscriber that filters events and delivers them to
+// another subscriber from a separate goroutine. Events are queued in a bounded
+// buffer and dropped when it is full so that a slow subscriber, such as one
+// shipping audit records to a remote store, never stalls the request path.
+type Subscription struct {
+	subscriber EventSubscriber
+	types      map[EventType]bool
+	hosts      map[string]bool
+	size       int
+	lock       sync.RWMutex
+	closed     bool
+	events     chan TransportEvent
+	done       chan struct{}
+	dropped    atomic.Int64
+}
+
+// SubscriptionOption is a configuration for the Subscription.
+type SubscriptionOption func(*Subscription) *Subscription
+
+// SubscriptionOptionTypes limits delivery to events of the given types. By
+// default events of every type are delivered.
+func SubscriptionOptionTypes(types ...EventType) SubscriptionOption {
+	return func(s *Subscription) *Subscription {
+		s.types = make(map[EventType]bool, len(types))
+		for _, t := range types {
+			s.types[t] = true
+		}
+		return s
+	}
+}
+
+// SubscriptionOptionHosts limits delivery to events for requests to the given
+// hosts, compared without regard to case or port. Events that do not relate
+// to a request, such as EventTransportRecycled, are not delivered when hosts
+// are given.
+func SubscriptionOptionHosts(hosts ...string) SubscriptionOption {
+	return func(s *Subscription) *Subscription {
+		s.hosts = make(map[string]bool, len(hosts))
+		for _, host := range hosts {
+			s.hosts[strings.ToLower(host)] = true
+		}
+		return s
+	}
+}
+
+// SubscriptionOptionBuffer sets the number of events that may wait for
+// delivery before new ones are dropped. The default is 1024.
+func SubscriptionOptionBuffer(size int) SubscriptionOption {
+	return func(s *Subscription) *Subscription {
+		s.size = size
+		return s
+	}
+}
+
+// NewSubscription starts delivering the events given to the Subscription to
+// the subscriber. The subscriber is called from a single goroutine, one event
+// at a time, until Close is called.
+func NewSubscription(subscriber EventSubscriber, opts ...SubscriptionOption) *Subscription {
+	var s = &Subscription{subscriber: subscriber, size: 1024, done: make(chan struct{})}
+	for _, opt := range opts {
+		s = opt(s)
+	}
+	if s.size < 1 {
+		s.size = 1
+	}
+	s.events = make(chan TransportEvent, s.size)
+	goLabeled(context.Background(), subscriptionSource, "", func(context.Context) {
+		defer close(s.done)
+		for event := range s.events {
+			s.subscriber.OnEvent(event)
+		}
+	})
+	return s
+}
+
+// OnEvent queues the event for delivery if it matches the filters. It never
+// blocks.
+func (s *Subscription) OnEvent(e TransportEvent) {
+	if !s.matches(e) {
+		return
+	}
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if s.closed {
+		return
+	}
+	select {
+	case s.events <- e:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+func (s *Subscription) matches(e TransportEvent) bool {
+	if s.types != nil && !s.types[e.Type] {
+		return false
+	}
+	if s.hosts == nil {
+		return true
+	}
+	if e.Request == nil || e.Request.URL == nil {
+		return false
+	}
+	return s.hosts[strings.ToLower(e.Request.URL.Hostname())]
+}
+
+// Dropped returns the number of matching events that were discarded because
+// the buffer was full.
+func (s *Subscription) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+// Close stops accepting events and waits for those already queued to be
+// delivered.
+func (s *Subscription) Close() error {
+	s.lock.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.events)
+	}
+	s.lock.Unlock()
+	<-s.done
+	return nil
+}
+
+type eventSubscribingTransport struct {
+	wrapped     http.RoundTripper
+	subscribers []EventSubscriber
+}
+
+// RoundTrip installs the subscribers in the context of the request.
+func (c *eventSubscribingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	var ctx = r.Context()
+	for _, subscriber := range c.subscribers {
+		ctx = WithEventSubscriber(ctx, subscriber)
+	}
+	return c.wrapped.RoundTrip(r.WithContext(ctx))
+}
+
+// NewEventSubscribing configures a RoundTripper decorator that installs the
+// subscribers in the context of every request so that the events of the
+// decorators it wraps are delivered to them. Wrap slow subscribers in a
+// Subscription so that they do not delay requests.
+func NewEventSubscribing(subscribers ...EventSubscriber) func(http.RoundTripper) http.RoundTripper {
+	return func(wrapped http.RoundTripper) http.RoundTripper {
+		return &eventSubscribingTransport{wrapped: wrapped, subscribers: subscribers}
+	}
+}
@@ -0,0 +1,67 @@
+package transport
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscriptionFilters(t *testing.T) {
+	var collector = &eventCollector{}
+	var subscription = NewSubscription(collector,
+		SubscriptionOptionTypes(EventRetryScheduled, EventTransportRecycled),
+		SubscriptionOptionHosts("API.example.com"),
+	)
+	var rt = Chain{
+		NewEventSubscribing(subscription),
+		NewRetrier(NewFixedBackoffPolicy(time.Millisecond), NewLimitedRetryPolicy(1, NewStatusCodeRetryPolicy(http.StatusServiceUnavailable))),
+	}.Apply(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusServiceUnavailable, Body: http.NoBody}, nil
+	}))
+	for _, url := range []string{"https://api.example.com:8443/", "https://other.example.com/"} {
+		var req, _ = http.NewRequest(http.MethodGet, url, nil)
+		var _, e = rt.RoundTrip(req)
+		require.NoError(t, e)
+	}
+	subscription.OnEvent(TransportEvent{Type: EventTransportRecycled})
+	require.NoError(t, subscription.Close())
+
+	assert.Equal(t, []EventType{EventRetryScheduled}, collector.types())
+	assert.Equal(t, "api.example.com:8443", collector.events[0].Request.URL.Host)
+	assert.Equal(t, int64(0), subscription.Dropped())
+
+	subscription.OnEvent(TransportEvent{Type: EventRetryScheduled, Request: collector.events[0].Request})
+	assert.Len(t, collector.types(), 1, "event delivered after close")
+}
+
+func TestSubscriptionDropsWhenFull(t *testing.T) {
+	var release = make(chan struct{})
+	var delivered = make(chan EventType, 10)
+	var subscription = NewSubscription(EventSubscriberFunc(func(e TransportEvent) {
+		<-release
+		delivered <- e.Type
+	}), SubscriptionOptionBuffer(2))
+
+	subscription.OnEvent(TransportEvent{Type: EventAttemptStarted})
+	// Wait for the first event to be taken from the buffer by the blocked
+	// subscriber so that exactly two more fit.
+	require.Eventually(t, func() bool { return len(subscription.events) == 0 }, time.Second, time.Millisecond)
+	var start = time.Now()
+	for x := 0; x < 5; x = x + 1 {
+		subscription.OnEvent(TransportEvent{Type: EventAttemptFinished})
+	}
+	assert.Less(t, time.Since(start), time.Second, "full buffer blocked the caller")
+	assert.Equal(t, int64(3), subscription.Dropped())
+
+	close(release)
+	require.NoError(t, subscription.Close())
+	close(delivered)
+	var types []EventType
+	for e := range delivered {
+		types = append(types, e)
+	}
+	assert.Equal(t, []EventType{EventAttemptStarted, EventAttemptFinished, EventAttemptFinished}, types)
+}
@@ -0,0 +1,38 @@
+package transport
+
+import "context"
+
+// TagOperation is the tag conventionally used to name the logical operation
+// a request performs, such as "get-user", independently of its URL.
+const TagOperation = "operation"
+
+type tagsKey struct{}
+
+// WithTags adds dimension tags, such as "operation" or "caller", to the
+// context. Decorators that report on requests made with the context include
+// the tags so that requests can be grouped without relying on URL paths,
+// which often have too many distinct values. Tags already in the context are
+// kept unless replaced by one with the same key.
+func WithTags(ctx context.Context, tags map[string]string) context.Context {
+	var merged = TagsFromContext(ctx)
+	for k, v := range tags {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, tagsKey{}, merged)
+}
+
+// TagsFromContext returns a copy of the tags in the context.
+func TagsFromContext(ctx context.Context) map[string]string {
+	var tags, _ = ctx.Value(tagsKey{}).(map[string]string)
+	var result = make(map[string]string, len(tags))
+	for k, v := range tags {
+		result[k] = v
+	}
+	return result
+}
+
+// tag returns a single tag from the context without copying the tag set.
+func tag(ctx context.Context, key string) string {
+	var tags, _ = ctx.Value(tagsKey{}).(map[string]string)
+	return tags[key]
+}
@@ -0,0 +1,21 @@
+package transport
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithTags(t *testing.T) {
+	assert.Empty(t, TagsFromContext(context.Background()))
+	assert.Empty(t, tag(context.Background(), TagOperation))
+
+	var ctx = WithTags(context.Background(), map[string]string{TagOperation: "get-user", "caller": "api"})
+	ctx = WithTags(ctx, map[string]string{TagOperation: "list-users"})
+	var tags = TagsFromContext(ctx)
+	assert.Equal(t, map[string]string{TagOperation: "list-users", "caller": "api"}, tags)
+	assert.Equal(t, "list-users", tag(ctx, TagOperation))
+	tags["new"] = "value"
+	assert.NotContains(t, TagsFromContext(ctx), "new")
+}
@@ -0,0 +1,108 @@
+package transport
+
+import (
+	"io"
+	"net/http"
+	"sync"
+)
+
+// ResponseTee is a decorator that copies response bodies to a sink as the
+// application reads them. Bodies are never buffered, so the sink receives
+// exactly the bytes the application consumed and in the same order.
+type ResponseTee struct {
+	wrapped http.RoundTripper
+	sink    func(*http.Request) io.WriteCloser
+}
+
+// RoundTrip sends the request and replaces the body of the response with one
+// that writes everything read from it to the sink for the request.
+func (c *ResponseTee) RoundTrip(r *http.Request) (*http.Response, error) {
+	var resp, e = c.wrapped.RoundTrip(r)
+	if e != nil || resp.Body == nil || resp.Body == http.NoBody {
+		return resp, e
+	}
+	var sink = c.sink(r)
+	if sink == nil {
+		return resp, e
+	}
+	return withBody(resp, &teeBody{body: resp.Body, sink: sink}), nil
+}
+
+// errorCloser is implemented by sinks, such as an io.PipeWriter, that can
+// be told why the content they received is incomplete.
+type errorCloser interface {
+	CloseWithError(error) error
+}
+
+// teeBody writes the content read from the body to the sink. The sink is
+// closed once, when the body reaches EOF or is closed by the application.
+type teeBody struct {
+	body      io.ReadCloser
+	sink      io.WriteCloser
+	lock      sync.Mutex
+	failed    bool
+	sinkDone  bool
+	readError error
+}
+
+func (b *teeBody) Read(p []byte) (int, error) {
+	var n, e = b.body.Read(p)
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if n > 0 && !b.failed && !b.sinkDone {
+		if _, writeErr := b.sink.Write(p[:n]); writeErr != nil {
+			// A failing sink must not break the application so the copy is
+			// abandoned and the sink told why, if it can be.
+			b.failed = true
+			b.closeSink(writeErr)
+		}
+	}
+	switch {
+	case e == io.EOF:
+		b.closeSink(nil)
+	case e != nil:
+		b.readError = e
+	}
+	return n, e
+}
+
+func (b *teeBody) Close() error {
+	var e = b.body.Close()
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	var reason = b.readError
+	if reason == nil {
+		reason = io.ErrUnexpectedEOF
+	}
+	b.closeSink(reason)
+	return e
+}
+
+// closeSink must be called with the lock held. A nil reason means the sink
+// received the whole body.
+func (b *teeBody) closeSink(reason error) {
+	if b.sinkDone {
+		return
+	}
+	b.sinkDone = true
+	if closer, ok := b.sink.(errorCloser); ok && reason != nil {
+		_ = closer.CloseWithError(reason)
+		return
+	}
+	_ = b.sink.Close()
+}
+
+// NewResponseTee configures a RoundTripper decorator that copies response
+// bodies to the sink returned for each request, such as a file, a hash, or
+// an archive writer, as the application reads them. A nil sink skips the
+// request. The sink is closed when the body is read to EOF or closed. Sinks
+// that implement CloseWithError, like io.PipeWriter, are instead given
+// the read error, or io.ErrUnexpectedEOF, when the application closes the
+// body before reading all of it, and the write error when writing to the
+// sink fails. Write errors stop the copy but are not returned to the
+// application.
+func NewResponseTee(sink func(*http.Request) io.WriteCloser) func(http.RoundTripper) http.RoundTripper {
+	return func(wrapped http.RoundTripper) http.RoundTripper {
+		return &ResponseTee{wrapped: wrapped, sink: sink}
+	}
+}
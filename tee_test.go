@@ -0,0 +1,119 @@
+package transport
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingSink struct {
+	bytes.Buffer
+	closed   int
+	closeErr error
+	writeErr error
+}
+
+func (s *recordingSink) Write(p []byte) (int, error) {
+	if s.writeErr != nil {
+		return 0, s.writeErr
+	}
+	return s.Buffer.Write(p)
+}
+
+func (s *recordingSink) Close() error {
+	s.closed = s.closed + 1
+	return nil
+}
+
+func (s *recordingSink) CloseWithError(e error) error {
+	s.closeErr = e
+	return s.Close()
+}
+
+func newTeeTransport(body string) http.RoundTripper {
+	return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(body)),
+			Trailer:    http.Header{"X-Checksum": []string{"abc"}},
+		}, nil
+	})
+}
+
+func TestResponseTee(t *testing.T) {
+	var sinks []*recordingSink
+	var rt = NewResponseTee(func(r *http.Request) io.WriteCloser {
+		if r.URL.Path == "/skip" {
+			return nil
+		}
+		var sink = &recordingSink{}
+		sinks = append(sinks, sink)
+		return sink
+	})(newTeeTransport("archived content"))
+
+	var req, _ = http.NewRequest(http.MethodGet, "https://example.com/file", nil)
+	var resp, e = rt.RoundTrip(req)
+	require.NoError(t, e)
+	var content, _ = io.ReadAll(resp.Body)
+	assert.Equal(t, "archived content", string(content))
+	require.NoError(t, resp.Body.Close())
+	require.Len(t, sinks, 1)
+	assert.Equal(t, "archived content", sinks[0].String())
+	assert.Equal(t, 1, sinks[0].closed, "sink was not closed exactly once")
+	assert.NoError(t, sinks[0].closeErr)
+	assert.Equal(t, "abc", resp.Trailer.Get("X-Checksum"))
+
+	// Closing before EOF tells the sink the copy is incomplete.
+	resp, _ = rt.RoundTrip(req)
+	var partial = make([]byte, 8)
+	_, _ = io.ReadFull(resp.Body, partial)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, "archived", sinks[1].String())
+	assert.ErrorIs(t, sinks[1].closeErr, io.ErrUnexpectedEOF)
+
+	req, _ = http.NewRequest(http.MethodGet, "https://example.com/skip", nil)
+	resp, _ = rt.RoundTrip(req)
+	content, _ = io.ReadAll(resp.Body)
+	assert.Equal(t, "archived content", string(content))
+	assert.Len(t, sinks, 2)
+}
+
+func TestResponseTeeSinkFailure(t *testing.T) {
+	var failure = errors.New("disk full")
+	var sink = &recordingSink{writeErr: failure}
+	var rt = NewResponseTee(func(*http.Request) io.WriteCloser {
+		return sink
+	})(newTeeTransport("content"))
+	var req, _ = http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	var resp, e = rt.RoundTrip(req)
+	require.NoError(t, e)
+	var content []byte
+	content, e = io.ReadAll(resp.Body)
+	require.NoError(t, e, "sink failure reached the application")
+	assert.Equal(t, "content", string(content))
+	require.NoError(t, resp.Body.Close())
+	assert.ErrorIs(t, sink.closeErr, failure)
+	assert.Equal(t, 1, sink.closed)
+}
+
+func TestResponseTeePipe(t *testing.T) {
+	var reader, writer = io.Pipe()
+	var rt = NewResponseTee(func(*http.Request) io.WriteCloser {
+		return writer
+	})(newTeeTransport(strings.Repeat("x", 1<<16)))
+	var done = make(chan []byte)
+	go func() {
+		var content, _ = io.ReadAll(reader)
+		done <- content
+	}()
+	var req, _ = http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	var resp, _ = rt.RoundTrip(req)
+	var content, _ = io.ReadAll(resp.Body)
+	assert.Equal(t, content, <-done)
+}
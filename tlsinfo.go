@@ -0,0 +1,96 @@
+package transport
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"net"
+	"net/http"
+)
+
+// TLSInfo describes the negotiated parameters of a TLS connection for
+// auditing the cryptography used by outbound requests.
+type TLSInfo struct {
+	// Version is the protocol version, such as "TLS 1.3".
+	Version string
+	// CipherSuite is the IANA name of the cipher suite.
+	CipherSuite string
+	// NegotiatedProtocol is the protocol selected by ALPN, such as "h2".
+	NegotiatedProtocol string
+	// ServerName is the name the server was verified against.
+	ServerName string
+	// Resumed is true if the session was resumed from a previous connection.
+	Resumed bool
+	// PeerCertificates contains the hex encoded SHA-256 fingerprints of the
+	// certificates presented by the server, starting with the leaf.
+	PeerCertificates []string
+}
+
+// NewTLSInfo creates a TLSInfo from the state of a connection.
+func NewTLSInfo(state tls.ConnectionState) TLSInfo {
+	var info = TLSInfo{
+		Version:            tls.VersionName(state.Version),
+		CipherSuite:        tls.CipherSuiteName(state.CipherSuite),
+		NegotiatedProtocol: state.NegotiatedProtocol,
+		ServerName:         state.ServerName,
+		Resumed:            state.DidResume,
+	}
+	for _, certificate := range state.PeerCertificates {
+		var fingerprint = sha256.Sum256(certificate.Raw)
+		info.PeerCertificates = append(info.PeerCertificates, hex.EncodeToString(fingerprint[:]))
+	}
+	return info
+}
+
+// connectionStater is implemented by *tls.Conn and by the connections of
+// other TLS implementations.
+type connectionStater interface {
+	ConnectionState() tls.ConnectionState
+}
+
+// TLSInfoFromResponse returns the TLSInfo of the connection that carried the
+// response. It uses the TLS state of the response or, when a response has
+// none, such as one created by a decorator, the state collected by the
+// TraceInfo of the request context. The boolean is false if neither is
+// available.
+func TLSInfoFromResponse(resp *http.Response) (TLSInfo, bool) {
+	if resp == nil {
+		return TLSInfo{}, false
+	}
+	if resp.TLS != nil {
+		return NewTLSInfo(*resp.TLS), true
+	}
+	if resp.Request == nil {
+		return TLSInfo{}, false
+	}
+	if trace := TraceFromContext(resp.Request.Context()); trace != nil {
+		return trace.TLS()
+	}
+	return TLSInfo{}, false
+}
+
+// OptionTLSInfo calls the hook with the TLSInfo of every TLS connection the
+// Transport establishes, such as to write an audit record. It wraps the
+// DialTLSContext of the Transport or, if there is none, installs one that
+// performs the handshake using the TLSClientConfig and TLSHandshakeTimeout.
+// Apply it after any options that change those values.
+func OptionTLSInfo(hook func(ctx context.Context, addr string, info TLSInfo)) Option {
+	return func(t *http.Transport) *http.Transport {
+		var dial DialContextFunc = t.DialTLSContext
+		if dial == nil {
+			dial = transportDialTLSContext(t, NewDialRetry(DialRetryOptionAttempts(1)))
+		}
+		t.DialTLSContext = func(ctx context.Context, network string, addr string) (net.Conn, error) {
+			var conn, e = dial(ctx, network, addr)
+			if e != nil {
+				return nil, e
+			}
+			if stater, ok := conn.(connectionStater); ok {
+				hook(ctx, addr, NewTLSInfo(stater.ConnectionState()))
+			}
+			return conn, nil
+		}
+		return t
+	}
+}
@@ -0,0 +1,63 @@
+package transport
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptionTLSInfo(t *testing.T) {
+	var server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	var fingerprint = sha256.Sum256(server.Certificate().Raw)
+
+	var dialed []TLSInfo
+	var tr = New(
+		OptionTLSClientConfig(server.Client().Transport.(*http.Transport).TLSClientConfig),
+		OptionTLSInfo(func(_ context.Context, addr string, info TLSInfo) {
+			assert.Equal(t, server.Listener.Addr().String(), addr)
+			dialed = append(dialed, info)
+		}),
+	)
+	defer tr.CloseIdleConnections()
+	for x := 0; x < 2; x = x + 1 {
+		var req, _ = http.NewRequest(http.MethodGet, server.URL, nil)
+		var resp, e = tr.RoundTrip(req)
+		require.NoError(t, e)
+		_ = resp.Body.Close()
+		var info, ok = TLSInfoFromResponse(resp)
+		require.True(t, ok)
+		assert.Equal(t, "TLS 1.3", info.Version)
+		assert.Equal(t, []string{hex.EncodeToString(fingerprint[:])}, info.PeerCertificates)
+	}
+	require.Len(t, dialed, 1, "the connection was not reused")
+	assert.Equal(t, "TLS 1.3", dialed[0].Version)
+	assert.Equal(t, "TLS_AES_128_GCM_SHA256", dialed[0].CipherSuite)
+	assert.Equal(t, []string{hex.EncodeToString(fingerprint[:])}, dialed[0].PeerCertificates)
+}
+
+func TestTLSInfoFromTrace(t *testing.T) {
+	var server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	var tr = server.Client().Transport
+	var ctx, trace = WithTrace(context.Background())
+	var req, _ = http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	var resp, e = tr.RoundTrip(req)
+	require.NoError(t, e)
+	_ = resp.Body.Close()
+	resp.TLS = nil
+	var info, ok = TLSInfoFromResponse(resp)
+	require.True(t, ok)
+	assert.Equal(t, "TLS 1.3", info.Version)
+	var traced, _ = trace.TLS()
+	assert.Equal(t, info, traced)
+
+	_, ok = TLSInfoFromResponse(&http.Response{Request: req.WithContext(context.Background())})
+	assert.False(t, ok)
+}
@@ -0,0 +1,129 @@
+package transport
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// TraceTimings contains the connection phase timings of the most recent
+// request made with a traced context.
+type TraceTimings struct {
+	// DNS is the time spent resolving the host name.
+	DNS time.Duration
+	// Connect is the time spent establishing the TCP connection.
+	Connect time.Duration
+	// TLSHandshake is the time spent on the TLS handshake.
+	TLSHandshake time.Duration
+	// FirstByte is the time between requesting a connection and receiving the
+	// first byte of the response.
+	FirstByte time.Duration
+	// ConnReused is true if an idle connection was reused, in which case the
+	// DNS, Connect, and TLSHandshake timings are zero.
+	ConnReused bool
+}
+
+// TraceInfo collects TraceTimings through an httptrace.ClientTrace. It is
+// safe for concurrent use because decorators such as the Hedger issue
+// multiple requests with the same context.
+type TraceInfo struct {
+	lock    sync.Mutex
+	timings TraceTimings
+	getConn time.Time
+	dns     time.Time
+	connect time.Time
+	tls     time.Time
+	tlsInfo *TLSInfo
+}
+
+// Timings returns a copy of the collected timings.
+func (t *TraceInfo) Timings() TraceTimings {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.timings
+}
+
+// TLS returns the TLSInfo of the connection used by the most recent request.
+// The boolean is false if the connection did not use TLS.
+func (t *TraceInfo) TLS() (TLSInfo, bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.tlsInfo == nil {
+		return TLSInfo{}, false
+	}
+	return *t.tlsInfo, true
+}
+
+func (t *TraceInfo) record(f func(*TraceInfo)) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	f(t)
+}
+
+func (t *TraceInfo) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GetConn: func(string) {
+			t.record(func(t *TraceInfo) {
+				t.timings = TraceTimings{}
+				t.tlsInfo = nil
+				t.getConn = time.Now()
+			})
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			var tlsInfo *TLSInfo
+			if stater, ok := info.Conn.(connectionStater); ok {
+				var state = NewTLSInfo(stater.ConnectionState())
+				tlsInfo = &state
+			}
+			t.record(func(t *TraceInfo) {
+				t.timings.ConnReused = info.Reused
+				t.tlsInfo = tlsInfo
+			})
+		},
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.record(func(t *TraceInfo) { t.dns = time.Now() })
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.record(func(t *TraceInfo) { t.timings.DNS = time.Since(t.dns) })
+		},
+		ConnectStart: func(string, string) {
+			t.record(func(t *TraceInfo) { t.connect = time.Now() })
+		},
+		ConnectDone: func(string, string, error) {
+			t.record(func(t *TraceInfo) { t.timings.Connect = time.Since(t.connect) })
+		},
+		TLSHandshakeStart: func() {
+			t.record(func(t *TraceInfo) { t.tls = time.Now() })
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.record(func(t *TraceInfo) { t.timings.TLSHandshake = time.Since(t.tls) })
+		},
+		GotFirstResponseByte: func() {
+			t.record(func(t *TraceInfo) { t.timings.FirstByte = time.Since(t.getConn) })
+		},
+	}
+}
+
+type traceInfoKey struct{}
+
+// WithTrace installs an httptrace.ClientTrace in the context that collects
+// connection timings into the returned TraceInfo. Decorators that report
+// timings read the TraceInfo with TraceFromContext rather than installing
+// their own traces. If the context already carries a TraceInfo then it is
+// returned unchanged.
+func WithTrace(ctx context.Context) (context.Context, *TraceInfo) {
+	if info := TraceFromContext(ctx); info != nil {
+		return ctx, info
+	}
+	var info = &TraceInfo{}
+	ctx = httptrace.WithClientTrace(ctx, info.clientTrace())
+	return context.WithValue(ctx, traceInfoKey{}, info), info
+}
+
+// TraceFromContext returns the TraceInfo installed by WithTrace or nil.
+func TraceFromContext(ctx context.Context) *TraceInfo {
+	var info, _ = ctx.Value(traceInfoKey{}).(*TraceInfo)
+	return info
+}
@@ -0,0 +1,42 @@
+package transport
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithTrace(t *testing.T) {
+	var server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	var client = server.Client()
+
+	var ctx, info = WithTrace(context.Background())
+	var again, same = WithTrace(ctx)
+	assert.Equal(t, ctx, again)
+	assert.Same(t, info, same)
+	assert.Same(t, info, TraceFromContext(ctx))
+	assert.Nil(t, TraceFromContext(context.Background()))
+
+	var req, _ = http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	var resp, e = client.Do(req)
+	require.NoError(t, e)
+	_ = resp.Body.Close()
+	var timings = info.Timings()
+	assert.False(t, timings.ConnReused)
+	assert.True(t, timings.Connect > 0)
+	assert.True(t, timings.TLSHandshake > 0)
+	assert.True(t, timings.FirstByte >= timings.TLSHandshake)
+
+	resp, e = client.Do(req)
+	require.NoError(t, e)
+	_ = resp.Body.Close()
+	timings = info.Timings()
+	assert.True(t, timings.ConnReused)
+	assert.Zero(t, timings.TLSHandshake)
+	assert.True(t, timings.FirstByte > 0)
+}
@@ -0,0 +1,189 @@
+package transport
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// TraceContext identifies the distributed trace and span that a request is
+// part of. IDs are lowercase hex strings of 32 and 16 characters.
+type TraceContext struct {
+	TraceID    string
+	SpanID     string
+	Sampled    bool
+	TraceState string
+}
+
+// ErrInvalidTraceParent is returned when a traceparent header cannot be
+// parsed.
+var ErrInvalidTraceParent = errors.New("transport: invalid traceparent header")
+
+type traceContextKey struct{}
+
+// WithTraceContext installs the TraceContext of the current span in the
+// context for propagation by the TracePropagation decorator.
+func WithTraceContext(ctx context.Context, tc TraceContext) context.Context {
+	return context.WithValue(ctx, traceContextKey{}, tc)
+}
+
+// TraceContextFromContext returns the TraceContext installed in the context
+// and whether there was one.
+func TraceContextFromContext(ctx context.Context) (TraceContext, bool) {
+	var tc, ok = ctx.Value(traceContextKey{}).(TraceContext)
+	return tc, ok
+}
+
+// ParseTraceParent parses the value of a W3C traceparent header.
+func ParseTraceParent(value string) (TraceContext, error) {
+	var parts = strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return TraceContext{}, ErrInvalidTraceParent
+	}
+	var flags, e = hex.DecodeString(parts[3])
+	if e != nil || len(flags) != 1 || !validTraceID(parts[1], 32) || !validTraceID(parts[2], 16) {
+		return TraceContext{}, ErrInvalidTraceParent
+	}
+	return TraceContext{TraceID: parts[1], SpanID: parts[2], Sampled: flags[0]&1 == 1}, nil
+}
+
+// TraceContextFromHeaders extracts the TraceContext of an inbound request from
+// W3C trace context headers or, if those are absent, from B3 headers.
+func TraceContextFromHeaders(header http.Header) (TraceContext, bool) {
+	if tc, e := ParseTraceParent(header.Get("traceparent")); e == nil {
+		tc.TraceState = header.Get("tracestate")
+		return tc, true
+	}
+	if single := header.Get("b3"); single != "" {
+		var parts = strings.Split(single, "-")
+		if len(parts) >= 2 && validB3TraceID(parts[0]) && validTraceID(parts[1], 16) {
+			var tc = TraceContext{TraceID: padTraceID(parts[0]), SpanID: parts[1]}
+			tc.Sampled = len(parts) > 2 && (parts[2] == "1" || parts[2] == "d")
+			return tc, true
+		}
+	}
+	var traceID, spanID = header.Get("X-B3-TraceId"), header.Get("X-B3-SpanId")
+	if validB3TraceID(traceID) && validTraceID(spanID, 16) {
+		return TraceContext{
+			TraceID: padTraceID(traceID),
+			SpanID:  spanID,
+			Sampled: header.Get("X-B3-Sampled") == "1" || header.Get("X-B3-Flags") == "1",
+		}, true
+	}
+	return TraceContext{}, false
+}
+
+func validTraceID(id string, length int) bool {
+	if len(id) != length || strings.Trim(id, "0") == "" {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= '0' && c <= '9') && !(c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func validB3TraceID(id string) bool {
+	return validTraceID(id, 16) || validTraceID(id, 32)
+}
+
+func padTraceID(id string) string {
+	return strings.Repeat("0", 32-len(id)) + id
+}
+
+// TraceFormat identifies a set of trace propagation headers.
+type TraceFormat int
+
+const (
+	// TraceFormatW3C writes the W3C traceparent and tracestate headers.
+	TraceFormatW3C TraceFormat = iota
+	// TraceFormatB3 writes the multiple X-B3-* headers.
+	TraceFormatB3
+	// TraceFormatB3Single writes the single b3 header.
+	TraceFormatB3Single
+)
+
+// TracePropagation is a decorator that injects trace context headers into
+// outgoing requests. Each request is given a new span ID that is a child of
+// the span found in the request context.
+type TracePropagation struct {
+	wrapped http.RoundTripper
+	formats []TraceFormat
+}
+
+// TracePropagationOption is a configuration for the TracePropagation
+// decorator.
+type TracePropagationOption func(*TracePropagation) *TracePropagation
+
+// TracePropagationOptionFormats sets the header formats that are written. The
+// default is TraceFormatW3C.
+func TracePropagationOptionFormats(formats ...TraceFormat) TracePropagationOption {
+	return func(t *TracePropagation) *TracePropagation {
+		t.formats = formats
+		return t
+	}
+}
+
+// RoundTrip injects the trace context headers and calls the wrapped
+// transport. Requests without a TraceContext in their context, or that
+// already carry a traceparent header, are not modified.
+func (c *TracePropagation) RoundTrip(r *http.Request) (*http.Response, error) {
+	var tc, ok = TraceContextFromContext(r.Context())
+	if !ok || r.Header.Get("traceparent") != "" {
+		return c.wrapped.RoundTrip(r)
+	}
+	var spanID, e = newSpanID()
+	if e != nil {
+		return nil, e
+	}
+	r = r.Clone(r.Context())
+	if r.Header == nil {
+		r.Header = http.Header{}
+	}
+	var sampled = "0"
+	if tc.Sampled {
+		sampled = "1"
+	}
+	for _, format := range c.formats {
+		switch format {
+		case TraceFormatW3C:
+			r.Header.Set("traceparent", "00-"+tc.TraceID+"-"+spanID+"-0"+sampled)
+			if tc.TraceState != "" {
+				r.Header.Set("tracestate", tc.TraceState)
+			}
+		case TraceFormatB3:
+			r.Header.Set("X-B3-TraceId", tc.TraceID)
+			r.Header.Set("X-B3-SpanId", spanID)
+			r.Header.Set("X-B3-ParentSpanId", tc.SpanID)
+			r.Header.Set("X-B3-Sampled", sampled)
+		case TraceFormatB3Single:
+			r.Header.Set("b3", tc.TraceID+"-"+spanID+"-"+sampled+"-"+tc.SpanID)
+		}
+	}
+	return c.wrapped.RoundTrip(r)
+}
+
+func newSpanID() (string, error) {
+	var id [8]byte
+	if _, e := rand.Read(id[:]); e != nil {
+		return "", e
+	}
+	return hex.EncodeToString(id[:]), nil
+}
+
+// NewTracePropagation configures a RoundTripper decorator that propagates the
+// TraceContext found in the request context to the upstream.
+func NewTracePropagation(opts ...TracePropagationOption) func(http.RoundTripper) http.RoundTripper {
+	return func(wrapped http.RoundTripper) http.RoundTripper {
+		var t = &TracePropagation{wrapped: wrapped, formats: []TraceFormat{TraceFormatW3C}}
+		for _, opt := range opts {
+			t = opt(t)
+		}
+		return t
+	}
+}
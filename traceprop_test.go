@@ -0,0 +1,87 @@
+package transport
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	testSpanID  = "00f067aa0ba902b7"
+)
+
+func TestParseTraceParent(t *testing.T) {
+	var tc, e = ParseTraceParent("00-" + testTraceID + "-" + testSpanID + "-01")
+	require.NoError(t, e)
+	assert.Equal(t, TraceContext{TraceID: testTraceID, SpanID: testSpanID, Sampled: true}, tc)
+
+	for _, value := range []string{
+		"",
+		"00-" + testTraceID + "-" + testSpanID,
+		"00-" + testTraceID + "-" + testSpanID + "-01-extra",
+		"ff-" + testTraceID + "-" + testSpanID + "-01",
+		"00-00000000000000000000000000000000-" + testSpanID + "-01",
+		"00-" + strings.ToUpper(testTraceID) + "-" + testSpanID + "-01",
+		"00-" + testTraceID + "-0000000000000000-01",
+	} {
+		_, e = ParseTraceParent(value)
+		assert.ErrorIs(t, e, ErrInvalidTraceParent, value)
+	}
+	_, e = ParseTraceParent("01-" + testTraceID + "-" + testSpanID + "-00-future")
+	assert.NoError(t, e)
+}
+
+func TestTraceContextFromHeaders(t *testing.T) {
+	var tc, ok = TraceContextFromHeaders(http.Header{
+		"Traceparent": []string{"00-" + testTraceID + "-" + testSpanID + "-00"},
+		"Tracestate":  []string{"vendor=value"},
+	})
+	assert.True(t, ok)
+	assert.Equal(t, TraceContext{TraceID: testTraceID, SpanID: testSpanID, TraceState: "vendor=value"}, tc)
+
+	tc, ok = TraceContextFromHeaders(http.Header{"B3": []string{"a3ce929d0e0e4736-" + testSpanID + "-1"}})
+	assert.True(t, ok)
+	assert.Equal(t, TraceContext{TraceID: "0000000000000000a3ce929d0e0e4736", SpanID: testSpanID, Sampled: true}, tc)
+
+	tc, ok = TraceContextFromHeaders(http.Header{
+		"X-B3-Traceid": []string{testTraceID},
+		"X-B3-Spanid":  []string{testSpanID},
+		"X-B3-Sampled": []string{"1"},
+	})
+	assert.True(t, ok)
+	assert.Equal(t, TraceContext{TraceID: testTraceID, SpanID: testSpanID, Sampled: true}, tc)
+
+	_, ok = TraceContextFromHeaders(http.Header{})
+	assert.False(t, ok)
+}
+
+func TestTracePropagation(t *testing.T) {
+	var fixture = &fixtureHeaderTransport{Response: &http.Response{StatusCode: http.StatusOK}}
+	var rt = NewTracePropagation(TracePropagationOptionFormats(TraceFormatW3C, TraceFormatB3, TraceFormatB3Single))(fixture)
+
+	var req, _ = http.NewRequest(http.MethodGet, "/", nil)
+	_, _ = rt.RoundTrip(req)
+	assert.Empty(t, fixture.Request.Header.Get("traceparent"))
+
+	var ctx = WithTraceContext(context.Background(), TraceContext{TraceID: testTraceID, SpanID: testSpanID, Sampled: true, TraceState: "a=b"})
+	req = req.WithContext(ctx)
+	_, _ = rt.RoundTrip(req)
+	var header = fixture.Request.Header
+	var child, e = ParseTraceParent(header.Get("traceparent"))
+	require.NoError(t, e)
+	assert.Equal(t, testTraceID, child.TraceID)
+	assert.NotEqual(t, testSpanID, child.SpanID)
+	assert.True(t, child.Sampled)
+	assert.Equal(t, "a=b", header.Get("tracestate"))
+	assert.Equal(t, testTraceID, header.Get("X-B3-TraceId"))
+	assert.Equal(t, child.SpanID, header.Get("X-B3-SpanId"))
+	assert.Equal(t, testSpanID, header.Get("X-B3-ParentSpanId"))
+	assert.Equal(t, "1", header.Get("X-B3-Sampled"))
+	assert.Equal(t, testTraceID+"-"+child.SpanID+"-1-"+testSpanID, header.Get("b3"))
+	assert.Empty(t, req.Header.Get("traceparent"))
+}
@@ -0,0 +1,201 @@
+// Package transporttest provides helpers for testing code that uses the
+// transport package without waiting on real time.
+package transporttest
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/asecurityteam/transport"
+)
+
+// FakeClock is a transport.Clock whose time only moves when the test moves
+// it. Timers fire when Advance passes their deadline. With
+// FakeClockOptionAutoAdvance, every timer fires as soon as it is created and
+// moves the clock forward by its duration, which suits decorators such as
+// Retry and RetryAfter that block on a single timer.
+//
+// Every duration waited on is recorded so that tests can assert the backoff
+// schedule with AssertWaits.
+type FakeClock struct {
+	lock   sync.Mutex
+	cond   *sync.Cond
+	now    time.Time
+	auto   bool
+	timers []*fakeTimer
+	waits  []time.Duration
+}
+
+// FakeClockOption is a configuration for the FakeClock.
+type FakeClockOption func(*FakeClock) *FakeClock
+
+// FakeClockOptionAutoAdvance makes timers fire immediately, advancing the
+// clock by their duration.
+func FakeClockOptionAutoAdvance() FakeClockOption {
+	return func(c *FakeClock) *FakeClock {
+		c.auto = true
+		return c
+	}
+}
+
+// NewFakeClock creates a FakeClock that starts at the given time.
+func NewFakeClock(start time.Time, opts ...FakeClockOption) *FakeClock {
+	var c = &FakeClock{now: start}
+	c.cond = sync.NewCond(&c.lock)
+	for _, opt := range opts {
+		c = opt(c)
+	}
+	return c
+}
+
+// Now returns the time of the clock.
+func (c *FakeClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+// After returns the channel of a new timer.
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	return c.NewTimer(d).C()
+}
+
+// NewTimer creates a timer that fires once the clock reaches the current
+// time plus the duration.
+func (c *FakeClock) NewTimer(d time.Duration) transport.Timer {
+	var t = &fakeTimer{clock: c, c: make(chan time.Time, 1)}
+	t.Reset(d)
+	return t
+}
+
+// Advance moves the clock forward and fires, in order of their deadlines,
+// the timers that expire on the way. It returns the new time.
+func (c *FakeClock) Advance(d time.Duration) time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.now = c.now.Add(d)
+	c.fire()
+	return c.now
+}
+
+// Waits returns the durations of every timer created or reset, in order.
+func (c *FakeClock) Waits() []time.Duration {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]time.Duration(nil), c.waits...)
+}
+
+// Pending returns the number of timers that have not fired or been stopped.
+func (c *FakeClock) Pending() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return len(c.timers)
+}
+
+// BlockUntil waits until at least n timers are pending. Tests call it
+// before Advance so that the code under test has created the timer that the
+// advance is meant to fire.
+func (c *FakeClock) BlockUntil(n int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for len(c.timers) < n {
+		c.cond.Wait()
+	}
+}
+
+// fire must be called with the lock held.
+func (c *FakeClock) fire() {
+	sort.SliceStable(c.timers, func(i int, j int) bool {
+		return c.timers[i].deadline.Before(c.timers[j].deadline)
+	})
+	var remaining = c.timers[:0]
+	for _, t := range c.timers {
+		if t.deadline.After(c.now) {
+			remaining = append(remaining, t)
+			continue
+		}
+		select {
+		case t.c <- c.now:
+		default:
+		}
+	}
+	c.timers = remaining
+}
+
+// remove must be called with the lock held.
+func (c *FakeClock) remove(t *fakeTimer) bool {
+	for x, pending := range c.timers {
+		if pending == t {
+			c.timers = append(c.timers[:x], c.timers[x+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+type fakeTimer struct {
+	clock    *FakeClock
+	c        chan time.Time
+	deadline time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.lock.Lock()
+	defer t.clock.lock.Unlock()
+	return t.clock.remove(t)
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	var c = t.clock
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	var active = c.remove(t)
+	select {
+	case <-t.c:
+	default:
+	}
+	c.waits = append(c.waits, d)
+	t.deadline = c.now.Add(d)
+	if c.auto && d > 0 {
+		c.now = t.deadline
+	}
+	c.timers = append(c.timers, t)
+	c.fire()
+	c.cond.Broadcast()
+	return active
+}
+
+// AssertWaits reports a test error if the durations waited on by the clock
+// differ from the expected ones.
+func AssertWaits(t testing.TB, clock *FakeClock, expected ...time.Duration) bool {
+	t.Helper()
+	var waits = clock.Waits()
+	if len(waits) != len(expected) {
+		t.Errorf("transporttest: expected waits %v but got %v", expected, waits)
+		return false
+	}
+	for x := range waits {
+		if waits[x] != expected[x] {
+			t.Errorf("transporttest: expected waits %v but got %v", expected, waits)
+			return false
+		}
+	}
+	return true
+}
+
+// AssertElapsed reports a test error if the clock has not moved exactly the
+// expected duration past the start time.
+func AssertElapsed(t testing.TB, clock *FakeClock, start time.Time, expected time.Duration) bool {
+	t.Helper()
+	if elapsed := clock.Now().Sub(start); elapsed != expected {
+		t.Errorf("transporttest: expected %s to elapse but got %s", expected, elapsed)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,110 @@
+package transporttest_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/asecurityteam/transport"
+	"github.com/asecurityteam/transport/transporttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFakeClockTimers(t *testing.T) {
+	var start = time.Unix(0, 0)
+	var clock = transporttest.NewFakeClock(start)
+	var first = clock.NewTimer(2 * time.Second)
+	var second = clock.NewTimer(time.Second)
+	var stopped = clock.NewTimer(time.Second)
+	assert.True(t, stopped.Stop())
+	assert.False(t, stopped.Stop())
+	assert.Equal(t, 2, clock.Pending())
+
+	clock.Advance(time.Second)
+	assert.Equal(t, start.Add(time.Second), <-second.C())
+	select {
+	case <-first.C():
+		t.Fatal("timer fired before its deadline")
+	default:
+	}
+	clock.Advance(time.Second)
+	assert.Equal(t, start.Add(2*time.Second), <-first.C())
+	assert.Equal(t, 0, clock.Pending())
+	assert.False(t, first.Reset(time.Minute))
+	assert.Equal(t, 1, clock.Pending())
+
+	transporttest.AssertWaits(t, clock, 2*time.Second, time.Second, time.Second, time.Minute)
+	transporttest.AssertElapsed(t, clock, start, 2*time.Second)
+	var mock = &recordingT{TB: t}
+	assert.False(t, transporttest.AssertWaits(mock, clock, time.Second))
+	assert.False(t, transporttest.AssertElapsed(mock, clock, start, time.Second))
+	assert.Len(t, mock.errors, 2)
+}
+
+// recordingT records the errors of failed assertions rather than failing the
+// test.
+type recordingT struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recordingT) Helper() {}
+
+func (r *recordingT) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestFakeClockAutoAdvanceRetry(t *testing.T) {
+	var start = time.Unix(0, 0)
+	var clock = transporttest.NewFakeClock(start, transporttest.FakeClockOptionAutoAdvance())
+	var calls int
+	var rt = transport.NewRetrierWithOptions(
+		transport.NewFixedBackoffPolicy(time.Hour),
+		[]transport.RetryPolicy{transport.NewLimitedRetryPolicy(2, transport.NewStatusCodeRetryPolicy(http.StatusServiceUnavailable))},
+		transport.RetryOptionClock(clock),
+	)(transport.RoundTripperFunc(func(*http.Request) (*http.Response, error) {
+		calls = calls + 1
+		return &http.Response{StatusCode: http.StatusServiceUnavailable, Body: http.NoBody}, nil
+	}))
+	var req, _ = http.NewRequest(http.MethodGet, "https://api.example.com/", nil)
+	var resp, e = rt.RoundTrip(req)
+	require.NoError(t, e)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, 3, calls)
+	transporttest.AssertWaits(t, clock, time.Hour, time.Hour)
+	transporttest.AssertElapsed(t, clock, start, 2*time.Hour)
+}
+
+func TestFakeClockHedger(t *testing.T) {
+	var clock = transporttest.NewFakeClock(time.Unix(0, 0))
+	var hosts = make(chan int, 2)
+	var calls int
+	var rt = transport.NewHedger(
+		transport.NewFixedBackoffPolicy(100*time.Millisecond),
+		transport.HedgerOptionClock(clock),
+	)(transport.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		var call = <-hosts
+		if call == 1 {
+			<-r.Context().Done()
+			return nil, r.Context().Err()
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	}))
+	hosts <- 1
+	hosts <- 2
+	var done = make(chan *http.Response)
+	go func() {
+		var req, _ = http.NewRequest(http.MethodGet, "https://api.example.com/", nil)
+		var resp, _ = rt.RoundTrip(req)
+		calls = calls + 1
+		done <- resp
+	}()
+	clock.BlockUntil(1)
+	clock.Advance(100 * time.Millisecond)
+	var resp = <-done
+	require.NotNil(t, resp)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 1, calls)
+}
@@ -0,0 +1,234 @@
+package transporttest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ErrFaultSequenceExhausted is returned by a FaultSequence for every request
+// made after its last outcome has been used.
+var ErrFaultSequenceExhausted = errors.New("transporttest: fault sequence exhausted")
+
+// Fault is one outcome of a FaultSequence. A Fault with an Err, or with Hang
+// set, fails the request. Any other Fault returns a response.
+type Fault struct {
+	// StatusCode of the response.
+	StatusCode int
+	// Header of the response.
+	Header http.Header
+	// Body of the response.
+	Body string
+	// Err is returned instead of a response.
+	Err error
+	// Hang blocks until the request context is done and returns its error,
+	// as a server that never answers does.
+	Hang bool
+}
+
+// FaultStatus returns a Fault that responds with the status code.
+func FaultStatus(code int) Fault {
+	return Fault{StatusCode: code, Header: http.Header{}}
+}
+
+// FaultTimeout returns a Fault that fails immediately with an error that is
+// both context.DeadlineExceeded and a net.Error reporting a timeout.
+func FaultTimeout() Fault {
+	return Fault{Err: fmt.Errorf("transporttest: timeout: %w", context.DeadlineExceeded)}
+}
+
+// FaultHang returns a Fault that waits for the request context to end, which
+// suits per-attempt timeouts such as the TimeoutRetryPolicy.
+func FaultHang() Fault {
+	return Fault{Hang: true}
+}
+
+// FaultConnectionReset returns a Fault that fails with ECONNRESET as if the
+// server closed the connection.
+func FaultConnectionReset() Fault {
+	return Fault{Err: fmt.Errorf("transporttest: read: %w", syscall.ECONNRESET)}
+}
+
+// FaultError returns a Fault that fails with the error.
+func FaultError(e error) Fault {
+	return Fault{Err: e}
+}
+
+// WithHeader returns a copy of the Fault that responds with the header.
+func (f Fault) WithHeader(name string, value string) Fault {
+	var header = f.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Add(name, value)
+	f.Header = header
+	return f
+}
+
+// WithRetryAfter returns a copy of the Fault that responds with a
+// Retry-After header of the duration, rounded up to whole seconds.
+func (f Fault) WithRetryAfter(d time.Duration) Fault {
+	return f.WithHeader("Retry-After", strconv.Itoa(int(math.Ceil(d.Seconds()))))
+}
+
+// WithBody returns a copy of the Fault that responds with the body.
+func (f Fault) WithBody(body string) Fault {
+	f.Body = body
+	return f
+}
+
+// Times returns n copies of the Fault for use with NewFaultSequence.
+func (f Fault) Times(n int) []Fault {
+	var faults = make([]Fault, 0, n)
+	for x := 0; x < n; x = x + 1 {
+		faults = append(faults, f)
+	}
+	return faults
+}
+
+// FaultSequence is a RoundTripper that answers each request with the next
+// outcome of a sequence so that retry, backoff, and circuit breaking chains
+// can be tested against realistic failures. It is safe for concurrent use.
+type FaultSequence struct {
+	lock   sync.Mutex
+	faults []Fault
+	calls  int
+}
+
+// NewFaultSequence creates a FaultSequence that returns the outcomes in
+// order.
+func NewFaultSequence(faults ...Fault) *FaultSequence {
+	return &FaultSequence{faults: faults}
+}
+
+// ParseFaultSequence creates a FaultSequence from a comma separated list of
+// outcomes, such as "timeout, 429 retry-after=2s, 500*2, 200". Each outcome
+// is a status code, timeout, hang, or reset, and may be followed by a
+// repeat count such as *2. Status codes accept the retry-after=<duration>
+// and body=<text> settings.
+func ParseFaultSequence(spec string) (*FaultSequence, error) {
+	var faults []Fault
+	for _, step := range strings.Split(spec, ",") {
+		var fields = strings.Fields(step)
+		if len(fields) < 1 {
+			return nil, fmt.Errorf("transporttest: empty outcome in fault sequence %q", spec)
+		}
+		var name, count = fields[0], 1
+		if offset := strings.IndexByte(name, '*'); offset >= 0 {
+			var e error
+			if count, e = strconv.Atoi(name[offset+1:]); e != nil || count < 1 {
+				return nil, fmt.Errorf("transporttest: invalid repeat count in %q", step)
+			}
+			name = name[:offset]
+		}
+		var fault Fault
+		switch strings.ToLower(name) {
+		case "timeout":
+			fault = FaultTimeout()
+		case "hang":
+			fault = FaultHang()
+		case "reset":
+			fault = FaultConnectionReset()
+		default:
+			var code, e = strconv.Atoi(name)
+			if e != nil || code < 100 || code > 999 {
+				return nil, fmt.Errorf("transporttest: unknown outcome %q", name)
+			}
+			fault = FaultStatus(code)
+		}
+		for _, setting := range fields[1:] {
+			var key, value, _ = strings.Cut(setting, "=")
+			if fault.Err != nil || fault.Hang {
+				return nil, fmt.Errorf("transporttest: %s does not accept %q", name, setting)
+			}
+			switch strings.ToLower(key) {
+			case "retry-after":
+				var d, e = time.ParseDuration(value)
+				if e != nil {
+					return nil, fmt.Errorf("transporttest: invalid retry-after in %q: %w", step, e)
+				}
+				fault = fault.WithRetryAfter(d)
+			case "body":
+				fault = fault.WithBody(value)
+			default:
+				return nil, fmt.Errorf("transporttest: unknown setting %q", setting)
+			}
+		}
+		faults = append(faults, fault.Times(count)...)
+	}
+	return NewFaultSequence(faults...), nil
+}
+
+// MustParseFaultSequence is like ParseFaultSequence but panics if the
+// specification is invalid.
+func MustParseFaultSequence(spec string) *FaultSequence {
+	var s, e = ParseFaultSequence(spec)
+	if e != nil {
+		panic(e)
+	}
+	return s
+}
+
+// RoundTrip answers the request with the next outcome of the sequence, or
+// ErrFaultSequenceExhausted once every outcome has been used.
+func (s *FaultSequence) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.Body != nil {
+		_, _ = io.Copy(io.Discard, r.Body)
+		_ = r.Body.Close()
+	}
+	s.lock.Lock()
+	var offset = s.calls
+	s.calls = s.calls + 1
+	s.lock.Unlock()
+	if offset >= len(s.faults) {
+		return nil, ErrFaultSequenceExhausted
+	}
+	var fault = s.faults[offset]
+	switch {
+	case fault.Hang:
+		<-r.Context().Done()
+		return nil, r.Context().Err()
+	case fault.Err != nil:
+		return nil, fault.Err
+	}
+	var header = fault.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", fault.StatusCode, http.StatusText(fault.StatusCode)),
+		StatusCode:    fault.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(fault.Body)),
+		ContentLength: int64(len(fault.Body)),
+		Request:       r,
+	}, nil
+}
+
+// Calls returns the number of requests the sequence has answered.
+func (s *FaultSequence) Calls() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.calls
+}
+
+// Remaining returns the number of outcomes that have not been used.
+func (s *FaultSequence) Remaining() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.calls >= len(s.faults) {
+		return 0
+	}
+	return len(s.faults) - s.calls
+}
@@ -0,0 +1,99 @@
+package transporttest_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/asecurityteam/transport"
+	"github.com/asecurityteam/transport/transporttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFaultSequenceRetryChain(t *testing.T) {
+	var start = time.Unix(0, 0)
+	var clock = transporttest.NewFakeClock(start, transporttest.FakeClockOptionAutoAdvance())
+	var faults = transporttest.MustParseFaultSequence("timeout, 429 retry-after=2s, 500, 200 body=ok")
+	var rt = transport.Chain{
+		transport.NewRetrierWithOptions(
+			transport.NewFixedBackoffPolicy(100*time.Millisecond),
+			[]transport.RetryPolicy{transport.NewLimitedRetryPolicy(5,
+				transport.NewStatusCodeRetryPolicy(http.StatusInternalServerError),
+				transport.NewTimeoutRetryPolicy(time.Minute),
+			)},
+			transport.RetryOptionClock(clock),
+		),
+		transport.NewRetryAfter(transport.RetryAfterOptionClock(clock)),
+	}.Apply(faults)
+
+	var req, _ = http.NewRequest(http.MethodGet, "https://api.example.com/", nil)
+	var resp, e = rt.RoundTrip(req)
+	require.NoError(t, e)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var body, _ = io.ReadAll(resp.Body)
+	assert.Equal(t, "ok", string(body))
+	assert.Equal(t, 4, faults.Calls())
+	assert.Equal(t, 0, faults.Remaining())
+	transporttest.AssertWaits(t, clock, 100*time.Millisecond, 2*time.Second, 100*time.Millisecond)
+}
+
+func TestFaultSequenceOutcomes(t *testing.T) {
+	var custom = errors.New("custom")
+	var faults = transporttest.NewFaultSequence(append(
+		transporttest.FaultStatus(http.StatusServiceUnavailable).WithHeader("X-Reason", "maintenance").Times(2),
+		transporttest.FaultTimeout(),
+		transporttest.FaultConnectionReset(),
+		transporttest.FaultError(custom),
+		transporttest.FaultHang(),
+	)...)
+	var req, _ = http.NewRequest(http.MethodGet, "https://api.example.com/", nil)
+
+	for x := 0; x < 2; x = x + 1 {
+		var resp, e = faults.RoundTrip(req)
+		require.NoError(t, e)
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		assert.Equal(t, "maintenance", resp.Header.Get("X-Reason"))
+	}
+	var _, e = faults.RoundTrip(req)
+	var netErr net.Error
+	require.True(t, errors.As(e, &netErr))
+	assert.True(t, netErr.Timeout())
+	assert.ErrorIs(t, e, context.DeadlineExceeded)
+	_, e = faults.RoundTrip(req)
+	assert.ErrorIs(t, e, syscall.ECONNRESET)
+	assert.True(t, transport.IsConnectionLostError(e))
+	_, e = faults.RoundTrip(req)
+	assert.ErrorIs(t, e, custom)
+
+	var ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	_, e = faults.RoundTrip(req.WithContext(ctx))
+	assert.ErrorIs(t, e, context.Canceled)
+
+	_, e = faults.RoundTrip(req)
+	assert.ErrorIs(t, e, transporttest.ErrFaultSequenceExhausted)
+	assert.Equal(t, 7, faults.Calls())
+}
+
+func TestParseFaultSequence(t *testing.T) {
+	var faults, e = transporttest.ParseFaultSequence("503*2, 429 retry-after=1500ms, reset")
+	require.NoError(t, e)
+	assert.Equal(t, 4, faults.Remaining())
+	var req, _ = http.NewRequest(http.MethodGet, "https://api.example.com/", nil)
+	_, _ = faults.RoundTrip(req)
+	_, _ = faults.RoundTrip(req)
+	var resp, _ = faults.RoundTrip(req)
+	assert.Equal(t, "2", resp.Header.Get("Retry-After"))
+
+	for _, spec := range []string{"", "200,", "teapot", "500*0", "200 retry-after=soon", "timeout body=x", "200 color=red"} {
+		_, e = transporttest.ParseFaultSequence(spec)
+		assert.Error(t, e, spec)
+	}
+	assert.Panics(t, func() { transporttest.MustParseFaultSequence("teapot") })
+}
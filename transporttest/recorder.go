@@ -0,0 +1,146 @@
+package transporttest
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// Exchange is a request captured by a Recorder along with the outcome of
+// sending it.
+type Exchange struct {
+	// Request is a copy of the request as it arrived at the Recorder. Its
+	// body has been read into Body.
+	Request *http.Request
+	// Body is the content of the request body.
+	Body []byte
+	// Response is the response returned for the request, if any. Its body
+	// has been read into ResponseBody and replaced for the caller.
+	Response *http.Response
+	// ResponseBody is the content of the response body.
+	ResponseBody []byte
+	// Err is the error returned for the request, if any.
+	Err error
+}
+
+// Recorder is a RoundTripper that captures every request it is given, and
+// the response or error returned for it, so that tests can assert what a
+// chain of decorators sent without matching arguments of mocks. Requests
+// are passed to a wrapped RoundTripper, or answered with an empty 200
+// response when there is none. It is safe for concurrent use.
+type Recorder struct {
+	lock      sync.Mutex
+	wrapped   http.RoundTripper
+	exchanges []Exchange
+}
+
+// NewRecorder creates a Recorder that sends requests to the wrapped
+// RoundTripper, which may be nil.
+func NewRecorder(wrapped http.RoundTripper) *Recorder {
+	return &Recorder{wrapped: wrapped}
+}
+
+// RoundTrip records the request, sends it, and records the outcome. Request
+// and response bodies are read into memory and replaced so that both the
+// wrapped RoundTripper and the caller can still read them.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var exchange = Exchange{Request: req.Clone(req.Context())}
+	if req.Body != nil && req.Body != http.NoBody {
+		var content, e = io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if e != nil {
+			return nil, e
+		}
+		exchange.Body = content
+		exchange.Request.Body = http.NoBody
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(content))
+	}
+
+	var resp *http.Response
+	var e error
+	if r.wrapped == nil {
+		resp = &http.Response{
+			Status:     "200 OK",
+			StatusCode: http.StatusOK,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     http.Header{},
+			Body:       http.NoBody,
+			Request:    req,
+		}
+	} else {
+		resp, e = r.wrapped.RoundTrip(req)
+	}
+	if resp != nil && resp.Body != nil && resp.Body != http.NoBody {
+		var content, readErr = io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if readErr != nil && e == nil {
+			e = readErr
+		}
+		exchange.ResponseBody = content
+		resp.Body = io.NopCloser(bytes.NewReader(content))
+	}
+	exchange.Response = resp
+	exchange.Err = e
+
+	r.lock.Lock()
+	r.exchanges = append(r.exchanges, exchange)
+	r.lock.Unlock()
+	return resp, e
+}
+
+// Exchanges returns every exchange in the order the requests arrived.
+func (r *Recorder) Exchanges() []Exchange {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]Exchange(nil), r.exchanges...)
+}
+
+// Requests returns a copy of every request in the order they arrived. The
+// bodies of the copies can be read without affecting the recording.
+func (r *Recorder) Requests() []*http.Request {
+	var exchanges = r.Exchanges()
+	var requests = make([]*http.Request, 0, len(exchanges))
+	for _, exchange := range exchanges {
+		requests = append(requests, exchange.request())
+	}
+	return requests
+}
+
+// LastRequestTo returns a copy of the most recent request sent to the host,
+// which is compared with the host and port of the request URL, or nil if
+// there is none.
+func (r *Recorder) LastRequestTo(host string) *http.Request {
+	var exchanges = r.Exchanges()
+	for x := len(exchanges) - 1; x >= 0; x = x - 1 {
+		if exchanges[x].Request.URL.Host == host {
+			return exchanges[x].request()
+		}
+	}
+	return nil
+}
+
+// Len returns the number of requests recorded.
+func (r *Recorder) Len() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return len(r.exchanges)
+}
+
+// Reset discards every recorded exchange.
+func (r *Recorder) Reset() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.exchanges = nil
+}
+
+func (e Exchange) request() *http.Request {
+	var req = e.Request.Clone(e.Request.Context())
+	if e.Body != nil {
+		req.Body = io.NopCloser(bytes.NewReader(e.Body))
+	}
+	return req
+}
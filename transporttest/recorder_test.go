@@ -0,0 +1,74 @@
+package transporttest_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/asecurityteam/transport"
+	"github.com/asecurityteam/transport/transporttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorder(t *testing.T) {
+	var recorder = transporttest.NewRecorder(transport.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Host == "down.example.com" {
+			return nil, errors.New("unavailable")
+		}
+		var content, _ = io.ReadAll(r.Body)
+		return &http.Response{StatusCode: http.StatusCreated, Body: io.NopCloser(strings.NewReader("echo:" + string(content)))}, nil
+	}))
+
+	var first, _ = http.NewRequest(http.MethodPost, "https://api.example.com/items", strings.NewReader("one"))
+	var resp, e = recorder.RoundTrip(first)
+	require.NoError(t, e)
+	var body, _ = io.ReadAll(resp.Body)
+	assert.Equal(t, "echo:one", string(body), "response body was consumed")
+
+	var second, _ = http.NewRequest(http.MethodPost, "https://api.example.com/items", strings.NewReader("two"))
+	_, _ = recorder.RoundTrip(second)
+	var failed, _ = http.NewRequest(http.MethodGet, "https://down.example.com/", nil)
+	_, e = recorder.RoundTrip(failed)
+	assert.EqualError(t, e, "unavailable")
+
+	assert.Equal(t, 3, recorder.Len())
+	var requests = recorder.Requests()
+	require.Len(t, requests, 3)
+	for x, expected := range []string{"one", "two", ""} {
+		var content []byte
+		if requests[x].Body != nil {
+			content, _ = io.ReadAll(requests[x].Body)
+		}
+		assert.Equal(t, expected, string(content))
+	}
+	var last = recorder.LastRequestTo("api.example.com")
+	require.NotNil(t, last)
+	body, _ = io.ReadAll(last.Body)
+	assert.Equal(t, "two", string(body))
+	body, _ = io.ReadAll(recorder.LastRequestTo("api.example.com").Body)
+	assert.Equal(t, "two", string(body), "recorded body was consumed")
+	assert.Nil(t, recorder.LastRequestTo("other.example.com"))
+
+	var exchanges = recorder.Exchanges()
+	assert.Equal(t, http.StatusCreated, exchanges[0].Response.StatusCode)
+	assert.Equal(t, "echo:two", string(exchanges[1].ResponseBody))
+	assert.EqualError(t, exchanges[2].Err, "unavailable")
+
+	recorder.Reset()
+	assert.Equal(t, 0, recorder.Len())
+}
+
+func TestRecorderChain(t *testing.T) {
+	var recorder = transporttest.NewRecorder(nil)
+	var rt = transport.NewHeader(func(*http.Request) (string, string) {
+		return "X-Key", "value"
+	})(recorder)
+	var req, _ = http.NewRequest(http.MethodGet, "https://api.example.com/", nil)
+	var resp, e = rt.RoundTrip(req)
+	require.NoError(t, e)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "value", recorder.LastRequestTo("api.example.com").Header.Get("X-Key"))
+}
@@ -0,0 +1,222 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"strings"
+)
+
+const multipartSource = "multipart_body"
+
+// BodySource produces a fresh copy of a request body each time it is opened
+// so that decorators which send a request more than once, such as the retry
+// and hedging decorators, can re-read large uploads from their source rather
+// than buffering them in memory.
+type BodySource interface {
+	// Open returns a new reader positioned at the start of the body.
+	Open() (io.ReadCloser, error)
+	// Size returns the length of the body, or -1 if it is not known.
+	Size() int64
+}
+
+// bodySourceFunc adapts a function to the BodySource interface.
+type bodySourceFunc struct {
+	open func() (io.ReadCloser, error)
+	size int64
+}
+
+func (s bodySourceFunc) Open() (io.ReadCloser, error) {
+	return s.open()
+}
+
+func (s bodySourceFunc) Size() int64 {
+	return s.size
+}
+
+// NewBodySource creates a BodySource from a function that opens the body and
+// the length of the body, or -1 if it is not known.
+func NewBodySource(open func() (io.ReadCloser, error), size int64) BodySource {
+	return bodySourceFunc{open: open, size: size}
+}
+
+// NewBytesBodySource creates a BodySource for content held in memory.
+func NewBytesBodySource(content []byte) BodySource {
+	return NewBodySource(func() (io.ReadCloser, error) {
+		return newReplayBody(content), nil
+	}, int64(len(content)))
+}
+
+// NewFileBodySource creates a BodySource that opens the file at the path for
+// each attempt. The size is read when the source is created, and an error is
+// returned if the file cannot be found.
+func NewFileBodySource(path string) (BodySource, error) {
+	var info, e = os.Stat(path)
+	if e != nil {
+		return nil, e
+	}
+	return NewBodySource(func() (io.ReadCloser, error) {
+		return os.Open(path)
+	}, info.Size()), nil
+}
+
+// NewSectionBodySource creates a BodySource for size bytes of the reader
+// starting at the offset. It suits uploads that send a large file in chunks,
+// where each chunk is a request that may be retried on its own.
+func NewSectionBodySource(r io.ReaderAt, offset int64, size int64) BodySource {
+	return NewBodySource(func() (io.ReadCloser, error) {
+		return io.NopCloser(io.NewSectionReader(r, offset, size)), nil
+	}, size)
+}
+
+// sourceBody is the body of a request created by NewRequestWithBodySource. It
+// carries its source so that the request copier can open it again even when
+// a decorator has dropped the GetBody function of the request.
+type sourceBody struct {
+	io.ReadCloser
+	source BodySource
+}
+
+// NewRequestWithBodySource creates a request whose body, and GetBody
+// function, are opened from the source. The ContentLength is set when the
+// size of the source is known and, if the source describes its content type
+// like a MultipartBody does, so is the Content-Type header.
+func NewRequestWithBodySource(ctx context.Context, method string, url string, source BodySource) (*http.Request, error) {
+	var body, e = source.Open()
+	if e != nil {
+		return nil, e
+	}
+	var r *http.Request
+	if r, e = http.NewRequestWithContext(ctx, method, url, nil); e != nil {
+		_ = body.Close()
+		return nil, e
+	}
+	r.Body = sourceBody{ReadCloser: body, source: source}
+	r.GetBody = source.Open
+	r.ContentLength = source.Size()
+	if r.ContentLength == 0 {
+		_ = body.Close()
+		r.Body = http.NoBody
+		r.GetBody = func() (io.ReadCloser, error) { return http.NoBody, nil }
+	}
+	if typed, ok := source.(interface{ ContentType() string }); ok {
+		r.Header.Set("Content-Type", typed.ContentType())
+	}
+	return r, nil
+}
+
+// multipartPart is a single part of a MultipartBody.
+type multipartPart struct {
+	header textproto.MIMEHeader
+	source BodySource
+}
+
+// MultipartBody is a BodySource for multipart/form-data uploads. The parts
+// are streamed from their own sources each time the body is opened and are
+// always separated by the same boundary, so a retried attempt sends exactly
+// the same content, and Content-Type, as the first one without holding the
+// files in memory.
+type MultipartBody struct {
+	boundary string
+	parts    []multipartPart
+}
+
+// NewMultipartBody creates an empty MultipartBody with a random boundary.
+func NewMultipartBody() *MultipartBody {
+	return &MultipartBody{boundary: multipart.NewWriter(io.Discard).Boundary()}
+}
+
+// AddField adds a form field with the value.
+func (b *MultipartBody) AddField(name string, value string) *MultipartBody {
+	var header = make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"`, escapeQuotes(name)))
+	return b.AddPart(header, NewBytesBodySource([]byte(value)))
+}
+
+// AddFile adds a file field whose content is read from the source each time
+// the body is opened.
+func (b *MultipartBody) AddFile(name string, filename string, source BodySource) *MultipartBody {
+	var header = make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, escapeQuotes(name), escapeQuotes(filename)))
+	header.Set("Content-Type", "application/octet-stream")
+	return b.AddPart(header, source)
+}
+
+// AddPart adds a part with a custom header.
+func (b *MultipartBody) AddPart(header textproto.MIMEHeader, source BodySource) *MultipartBody {
+	b.parts = append(b.parts, multipartPart{header: header, source: source})
+	return b
+}
+
+// ContentType returns the multipart/form-data media type with the boundary.
+func (b *MultipartBody) ContentType() string {
+	return "multipart/form-data; boundary=" + b.boundary
+}
+
+// Size returns the length of the encoded body, or -1 if the size of any part
+// is not known.
+func (b *MultipartBody) Size() int64 {
+	var counter = &countingWriter{}
+	var w = multipart.NewWriter(counter)
+	_ = w.SetBoundary(b.boundary)
+	var size int64
+	for _, part := range b.parts {
+		var partSize = part.source.Size()
+		if partSize < 0 {
+			return -1
+		}
+		size = size + partSize
+		_, _ = w.CreatePart(part.header)
+	}
+	_ = w.Close()
+	return size + counter.n
+}
+
+// Open streams a fresh copy of the encoded body. Errors from opening or
+// reading a part are returned by Read.
+func (b *MultipartBody) Open() (io.ReadCloser, error) {
+	var reader, writer = io.Pipe()
+	goLabeled(context.Background(), multipartSource, "", func(context.Context) {
+		var w = multipart.NewWriter(writer)
+		_ = w.SetBoundary(b.boundary)
+		for _, part := range b.parts {
+			var dst, e = w.CreatePart(part.header)
+			if e != nil {
+				_ = writer.CloseWithError(e)
+				return
+			}
+			var src io.ReadCloser
+			if src, e = part.source.Open(); e != nil {
+				_ = writer.CloseWithError(e)
+				return
+			}
+			_, e = io.Copy(dst, src)
+			_ = src.Close()
+			if e != nil {
+				_ = writer.CloseWithError(e)
+				return
+			}
+		}
+		_ = writer.CloseWithError(w.Close())
+	})
+	return reader, nil
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n = w.n + int64(len(p))
+	return len(p), nil
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
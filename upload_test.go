@@ -0,0 +1,117 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultipartBodyRetry(t *testing.T) {
+	var path = filepath.Join(t.TempDir(), "report.csv")
+	require.NoError(t, os.WriteFile(path, []byte("a,b\n1,2\n"), 0600))
+	var file, e = NewFileBodySource(path)
+	require.NoError(t, e)
+	var body = NewMultipartBody().
+		AddField("title", `quarterly "report"`).
+		AddFile("file", "report.csv", file)
+
+	var contentTypes []string
+	var bodies []string
+	var lengths []int64
+	var rt = NewRetrier(
+		NewFixedBackoffPolicy(time.Millisecond),
+		NewLimitedRetryPolicy(2, NewStatusCodeRetryPolicy(http.StatusServiceUnavailable)),
+	)(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		var content, e = io.ReadAll(r.Body)
+		require.NoError(t, e)
+		contentTypes = append(contentTypes, r.Header.Get("Content-Type"))
+		bodies = append(bodies, string(content))
+		lengths = append(lengths, r.ContentLength)
+		if len(bodies) == 1 {
+			return &http.Response{StatusCode: http.StatusServiceUnavailable, Body: http.NoBody}, nil
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	}))
+
+	var req *http.Request
+	req, e = NewRequestWithBodySource(context.Background(), http.MethodPost, "https://api.example.com/upload", body)
+	require.NoError(t, e)
+	var resp *http.Response
+	resp, e = rt.RoundTrip(req)
+	require.NoError(t, e)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Len(t, bodies, 2)
+	assert.Equal(t, bodies[0], bodies[1], "retried attempt sent a different body")
+	assert.Equal(t, contentTypes[0], contentTypes[1])
+	assert.Equal(t, int64(len(bodies[0])), lengths[1])
+	assert.Equal(t, body.Size(), lengths[0])
+
+	var _, params, _ = mime.ParseMediaType(contentTypes[1])
+	var reader = multipart.NewReader(strings.NewReader(bodies[1]), params["boundary"])
+	var form, formErr = reader.ReadForm(1 << 20)
+	require.NoError(t, formErr)
+	assert.Equal(t, []string{`quarterly "report"`}, form.Value["title"])
+	require.Len(t, form.File["file"], 1)
+	assert.Equal(t, "report.csv", form.File["file"][0].Filename)
+	var uploaded, _ = form.File["file"][0].Open()
+	var content, _ = io.ReadAll(uploaded)
+	assert.Equal(t, "a,b\n1,2\n", string(content))
+}
+
+func TestBodySourceWithoutGetBody(t *testing.T) {
+	var opened int
+	var source = NewBodySource(func() (io.ReadCloser, error) {
+		opened = opened + 1
+		return io.NopCloser(strings.NewReader("chunk")), nil
+	}, -1)
+	var req, e = NewRequestWithBodySource(context.Background(), http.MethodPut, "https://api.example.com/", source)
+	require.NoError(t, e)
+	assert.Equal(t, int64(-1), req.ContentLength)
+	req.GetBody = nil
+
+	var copier *requestCopier
+	copier, e = newRequestCopier(req)
+	require.NoError(t, e)
+	assert.Nil(t, copier.body, "source body was buffered")
+	for x := 0; x < 2; x = x + 1 {
+		var content, _ = io.ReadAll(copier.Copy().Body)
+		assert.Equal(t, "chunk", string(content))
+	}
+	assert.Equal(t, 3, opened)
+}
+
+func TestBodySources(t *testing.T) {
+	var section = NewSectionBodySource(strings.NewReader("0123456789"), 2, 4)
+	assert.Equal(t, int64(4), section.Size())
+	var reader, _ = section.Open()
+	var content, _ = io.ReadAll(reader)
+	assert.Equal(t, "2345", string(content))
+
+	var _, e = NewFileBodySource(filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, e)
+
+	var req *http.Request
+	req, e = NewRequestWithBodySource(context.Background(), http.MethodPost, "https://api.example.com/", NewBytesBodySource(nil))
+	require.NoError(t, e)
+	assert.Equal(t, http.NoBody, req.Body)
+
+	var failing = errors.New("unreadable")
+	var body = NewMultipartBody().AddFile("file", "data.bin", NewBodySource(func() (io.ReadCloser, error) {
+		return nil, failing
+	}, -1))
+	assert.Equal(t, int64(-1), body.Size())
+	reader, _ = body.Open()
+	_, e = io.ReadAll(reader)
+	assert.ErrorIs(t, e, failing)
+}
@@ -0,0 +1,160 @@
+package transport
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+)
+
+// UpstreamError describes a response with a client or server error status
+// using the reason given in its body, when the body has a recognized shape.
+type UpstreamError struct {
+	// Response is the rejected response. When returned by the
+	// UpstreamErrorParsing decorator the body has already been drained and
+	// closed so only the status and headers are available.
+	Response *http.Response
+	// StatusCode is the status of the response.
+	StatusCode int
+	// Code is the machine readable reason for the failure, such as the type
+	// of an RFC 7807 problem or the code of an error object. It is empty if
+	// the body did not give one.
+	Code string
+	// Message is the human readable description of the failure. It is empty
+	// if the body did not give one.
+	Message string
+	// Body contains the raw response body, up to the first 64KiB.
+	Body []byte
+}
+
+func (e *UpstreamError) Error() string {
+	var reason = e.Message
+	if e.Code != "" && e.Message != "" {
+		reason = e.Code + ": " + e.Message
+	} else if e.Code != "" {
+		reason = e.Code
+	}
+	if reason == "" {
+		return fmt.Sprintf("transport: upstream responded with status %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("transport: upstream responded with status %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), reason)
+}
+
+// Is matches ErrClientStatus and ErrServerStatus according to the class of
+// the status.
+func (e *UpstreamError) Is(target error) bool {
+	return statusClassIs(e.StatusCode, target)
+}
+
+// problemDetails is the RFC 7807 problem document.
+type problemDetails struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Detail string `json:"detail"`
+}
+
+// ParseUpstreamError reads up to 64KiB of the response body and extracts the
+// reason for a failure from RFC 7807 problem documents and the common JSON
+// error shapes:
+//
+//	{"error": "message"}
+//	{"error": "code", "error_description": "message"}
+//	{"error": {"code": "code", "message": "message"}}
+//	{"code": "code", "message": "message"}
+//
+// The body of the response is replaced so that it can still be read in full.
+// Bodies of other shapes produce an UpstreamError without a code or message.
+func ParseUpstreamError(resp *http.Response) *UpstreamError {
+	var result = &UpstreamError{Response: resp, StatusCode: resp.StatusCode}
+	if resp.Body == nil || resp.Body == http.NoBody {
+		return result
+	}
+	var body, _ = io.ReadAll(io.LimitReader(resp.Body, maxStatusErrorBody))
+	result.Body = body
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+	var mediaType, _, _ = mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == "application/problem+json" {
+		var problem problemDetails
+		if json.Unmarshal(body, &problem) == nil {
+			if problem.Type != "about:blank" {
+				result.Code = problem.Type
+			}
+			result.Message = problem.Detail
+			if result.Message == "" {
+				result.Message = problem.Title
+			}
+		}
+		return result
+	}
+	var document map[string]interface{}
+	if json.Unmarshal(body, &document) != nil {
+		return result
+	}
+	switch reason := document["error"].(type) {
+	case string:
+		if description := jsonString(document["error_description"]); description != "" {
+			result.Code = reason
+			result.Message = description
+		} else {
+			result.Message = reason
+		}
+		return result
+	case map[string]interface{}:
+		document = reason
+	}
+	result.Code = jsonString(document["code"])
+	result.Message = jsonString(document["message"])
+	return result
+}
+
+// jsonString formats a decoded JSON scalar, such as a numeric error code, as
+// a string.
+func jsonString(v interface{}) string {
+	switch value := v.(type) {
+	case string:
+		return value
+	case float64, bool:
+		return fmt.Sprint(value)
+	}
+	return ""
+}
+
+// UpstreamErrorParsing is a decorator that converts responses with a client
+// or server error status into an *UpstreamError so that callers and logs
+// receive the structured reason for the failure rather than only a status
+// code.
+type UpstreamErrorParsing struct {
+	wrapped http.RoundTripper
+}
+
+// RoundTrip returns an *UpstreamError in place of a response with a status of
+// 400 or above. Redirects and other responses are returned unchanged. The
+// code of the error is recorded under AnnotationUpstreamErrorCode.
+func (c *UpstreamErrorParsing) RoundTrip(r *http.Request) (*http.Response, error) {
+	var resp, e = c.wrapped.RoundTrip(r)
+	if e != nil || resp.StatusCode < http.StatusBadRequest {
+		return resp, e
+	}
+	var upstream = ParseUpstreamError(resp)
+	drainBody(resp)
+	upstream.Response = withBody(resp, http.NoBody)
+	if upstream.Code != "" {
+		Annotate(r.Context(), AnnotationUpstreamErrorCode, upstream.Code)
+	}
+	return nil, upstream
+}
+
+// NewUpstreamErrorParsing configures a RoundTripper decorator that returns
+// an *UpstreamError for every response with a status of 400 or above. It
+// should be placed outside of any Retry decorator so that retry
+// policies based on the status code continue to see the responses.
+func NewUpstreamErrorParsing() func(http.RoundTripper) http.RoundTripper {
+	return func(wrapped http.RoundTripper) http.RoundTripper {
+		return &UpstreamErrorParsing{wrapped: wrapped}
+	}
+}
@@ -0,0 +1,108 @@
+package transport
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestParseUpstreamError(t *testing.T) {
+	var tests = []struct {
+		name        string
+		contentType string
+		body        string
+		code        string
+		message     string
+	}{
+		{name: "problem", contentType: "application/problem+json; charset=utf-8", body: `{"type":"https://example.com/probs/out-of-credit","title":"You do not have enough credit.","detail":"Your balance is 30, but that costs 50."}`, code: "https://example.com/probs/out-of-credit", message: "Your balance is 30, but that costs 50."},
+		{name: "problem blank", contentType: "application/problem+json", body: `{"type":"about:blank","title":"Not Found"}`, message: "Not Found"},
+		{name: "error string", contentType: "application/json", body: `{"error":"widget not found"}`, message: "widget not found"},
+		{name: "oauth", contentType: "application/json", body: `{"error":"invalid_grant","error_description":"token expired"}`, code: "invalid_grant", message: "token expired"},
+		{name: "error object", contentType: "application/json", body: `{"error":{"code":404,"message":"widget not found"}}`, code: "404", message: "widget not found"},
+		{name: "flat", body: `{"code":"NOT_FOUND","message":"widget not found"}`, code: "NOT_FOUND", message: "widget not found"},
+		{name: "text", contentType: "text/plain", body: `upstream unavailable`},
+		{name: "array", contentType: "application/json", body: `["unexpected"]`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var resp = &http.Response{
+				StatusCode: http.StatusNotFound,
+				Header:     http.Header{"Content-Type": []string{test.contentType}},
+				Body:       io.NopCloser(strings.NewReader(test.body)),
+			}
+			var upstream = ParseUpstreamError(resp)
+			if upstream.Code != test.code || upstream.Message != test.message {
+				t.Fatalf("expected %q and %q but got %q and %q", test.code, test.message, upstream.Code, upstream.Message)
+			}
+			if string(upstream.Body) != test.body {
+				t.Fatalf("unexpected raw body %q", upstream.Body)
+			}
+			var remaining, _ = io.ReadAll(resp.Body)
+			if string(remaining) != test.body {
+				t.Fatalf("response body was not preserved: %q", remaining)
+			}
+		})
+	}
+}
+
+func TestUpstreamErrorString(t *testing.T) {
+	var tests = []struct {
+		err      UpstreamError
+		expected string
+	}{
+		{UpstreamError{StatusCode: 503}, "transport: upstream responded with status 503 Service Unavailable"},
+		{UpstreamError{StatusCode: 404, Message: "missing"}, "transport: upstream responded with status 404 Not Found: missing"},
+		{UpstreamError{StatusCode: 400, Code: "invalid"}, "transport: upstream responded with status 400 Bad Request: invalid"},
+		{UpstreamError{StatusCode: 400, Code: "invalid", Message: "bad name"}, "transport: upstream responded with status 400 Bad Request: invalid: bad name"},
+	}
+	for _, test := range tests {
+		if test.err.Error() != test.expected {
+			t.Fatalf("expected %q but got %q", test.expected, test.err.Error())
+		}
+	}
+}
+
+func TestUpstreamErrorParsing(t *testing.T) {
+	var status = http.StatusConflict
+	var body *closeTrackingBody
+	var base = RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		body = &closeTrackingBody{Reader: strings.NewReader(`{"code":"conflict","message":"version mismatch"}`)}
+		return &http.Response{
+			StatusCode: status,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       body,
+		}, nil
+	})
+	var rt = NewUpstreamErrorParsing()(base)
+	var req, _ = http.NewRequest(http.MethodPut, "http://localhost/widgets/1", http.NoBody)
+	req = req.WithContext(WithAnnotations(req.Context()))
+
+	var resp, e = rt.RoundTrip(req)
+	if resp != nil {
+		t.Fatal("returned a response along with the error")
+	}
+	var upstream *UpstreamError
+	if !errors.As(e, &upstream) {
+		t.Fatalf("expected an UpstreamError but got %v", e)
+	}
+	if upstream.StatusCode != http.StatusConflict || upstream.Code != "conflict" || upstream.Message != "version mismatch" {
+		t.Fatalf("unexpected error %+v", upstream)
+	}
+	if upstream.Response.StatusCode != http.StatusConflict || upstream.Response.Body != http.NoBody {
+		t.Fatal("expected the drained response in the error")
+	}
+	if !body.closed {
+		t.Fatal("did not close the response body")
+	}
+	if code, _ := Annotation(req.Context(), AnnotationUpstreamErrorCode); code != "conflict" {
+		t.Fatalf("expected the code to be annotated but got %q", code)
+	}
+
+	for _, status = range []int{http.StatusOK, http.StatusFound, http.StatusNotModified} {
+		if resp, e = rt.RoundTrip(req); e != nil || resp.StatusCode != status {
+			t.Fatalf("status %d: expected the response to pass through but got %v", status, e)
+		}
+	}
+}
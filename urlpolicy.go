@@ -0,0 +1,263 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const urlPolicySource = "url_policy"
+
+// URLAction is the outcome of a URLRule.
+type URLAction int
+
+const (
+	// URLAllow sends requests to matching URLs.
+	URLAllow URLAction = iota
+	// URLDeny blocks requests to matching URLs.
+	URLDeny
+)
+
+func (a URLAction) String() string {
+	if a == URLDeny {
+		return "deny"
+	}
+	return "allow"
+}
+
+// URLRule allows or denies requests to the URLs that match a pattern.
+// Patterns are matched against the scheme, host, port, and path of the URL,
+// such as https://api.example.com:8443/v1/items. User information, the query,
+// and the fragment are never matched so that they cannot be used to make a
+// URL look like an allowed one. Exactly one of Glob and Regexp must be set.
+type URLRule struct {
+	Action URLAction
+	// Glob is a pattern such as https://*.example.com/v1/**. The scheme, host
+	// and port, and path of the glob are matched separately against those of
+	// the URL. In each of them, * matches any characters and ? matches a
+	// single character other than a slash, question mark, number sign, at
+	// sign, or colon. In the path, ** also matches slashes. A glob without a
+	// path matches the root path.
+	Glob string
+	// Regexp is a regular expression, which is anchored to match the whole
+	// of scheme://host:port/path.
+	Regexp string
+}
+
+func (r URLRule) String() string {
+	if r.Regexp != "" {
+		return r.Action.String() + " regexp " + r.Regexp
+	}
+	return r.Action.String() + " glob " + r.Glob
+}
+
+// globToRegexp converts a glob pattern for one part of a URL to an anchored
+// regular expression. Only the path allows ** to match slashes.
+func globToRegexp(glob string, path bool) string {
+	var expr strings.Builder
+	expr.WriteString("^")
+	for x := 0; x < len(glob); x = x + 1 {
+		switch {
+		case strings.HasPrefix(glob[x:], "**") && path:
+			expr.WriteString("[^?#]*")
+			x = x + 1
+		case strings.HasPrefix(glob[x:], "**"):
+			expr.WriteString("[^/?#@:]*")
+			x = x + 1
+		case glob[x] == '*':
+			expr.WriteString("[^/?#@:]*")
+		case glob[x] == '?':
+			expr.WriteString("[^/?#@:]")
+		default:
+			expr.WriteString(regexp.QuoteMeta(glob[x : x+1]))
+		}
+	}
+	expr.WriteString("$")
+	return expr.String()
+}
+
+// compileGlob splits a glob into the patterns for the scheme, host and port,
+// and path of a URL.
+func compileGlob(glob string) (*regexp.Regexp, *regexp.Regexp, *regexp.Regexp, error) {
+	var separator = strings.Index(glob, "://")
+	if separator < 0 {
+		return nil, nil, nil, errors.New("glob has no scheme")
+	}
+	var authority = glob[separator+3:]
+	var path = "^/?$"
+	if slash := strings.Index(authority, "/"); slash >= 0 {
+		authority, path = authority[:slash], globToRegexp(authority[slash:], true)
+	}
+	var scheme, e = regexp.Compile(globToRegexp(strings.ToLower(glob[:separator]), false))
+	if e != nil {
+		return nil, nil, nil, e
+	}
+	host, e := regexp.Compile(globToRegexp(strings.ToLower(authority), false))
+	if e != nil {
+		return nil, nil, nil, e
+	}
+	pathExpr, e := regexp.Compile(path)
+	if e != nil {
+		return nil, nil, nil, e
+	}
+	return scheme, host, pathExpr, nil
+}
+
+// URLPolicyError is returned by the EnforceURLPolicy decorator for requests
+// that the policy blocks.
+type URLPolicyError struct {
+	// URL is the blocked address with credentials removed by
+	// ScrubURLCredentials.
+	URL string
+	// Rule is the rule that denied the request, or empty if no rule matched
+	// and the default action denied it.
+	Rule string
+}
+
+func (e *URLPolicyError) Error() string {
+	if e.Rule == "" {
+		return fmt.Sprintf("transport: request to %s blocked by default URL policy", e.URL)
+	}
+	return fmt.Sprintf("transport: request to %s blocked by URL policy rule %q", e.URL, e.Rule)
+}
+
+type compiledURLRule struct {
+	rule   URLRule
+	expr   *regexp.Regexp
+	scheme *regexp.Regexp
+	host   *regexp.Regexp
+	path   *regexp.Regexp
+}
+
+func (c compiledURLRule) match(scheme string, host string, path string) bool {
+	if c.expr != nil {
+		return c.expr.MatchString(scheme + "://" + host + path)
+	}
+	return c.scheme.MatchString(scheme) && c.host.MatchString(host) && c.path.MatchString(path)
+}
+
+// URLPolicy evaluates URLs against an ordered list of rules for egress
+// policy enforcement. The first rule that matches a URL decides whether it is
+// allowed so rules with precedence are listed first. It counts the requests
+// that were blocked for each destination host.
+type URLPolicy struct {
+	rules         []compiledURLRule
+	defaultAction URLAction
+	lock          sync.Mutex
+	blocked       map[string]int64
+}
+
+// URLPolicyOption is a configuration for the URLPolicy.
+type URLPolicyOption func(*URLPolicy) *URLPolicy
+
+// URLPolicyOptionDefault sets the action for URLs that match no rule. The
+// default is URLDeny so that only explicitly allowed destinations are
+// reachable.
+func URLPolicyOptionDefault(action URLAction) URLPolicyOption {
+	return func(p *URLPolicy) *URLPolicy {
+		p.defaultAction = action
+		return p
+	}
+}
+
+// NewURLPolicy compiles the rules into a URLPolicy. An error is returned if
+// a rule has an invalid pattern.
+func NewURLPolicy(rules []URLRule, opts ...URLPolicyOption) (*URLPolicy, error) {
+	var p = &URLPolicy{defaultAction: URLDeny, blocked: make(map[string]int64)}
+	for _, rule := range rules {
+		var compiled = compiledURLRule{rule: rule}
+		var e error
+		switch {
+		case rule.Glob != "" && rule.Regexp != "":
+			return nil, errors.New("transport: URL rule sets both Glob and Regexp")
+		case rule.Glob != "":
+			compiled.scheme, compiled.host, compiled.path, e = compileGlob(rule.Glob)
+		case rule.Regexp != "":
+			compiled.expr, e = regexp.Compile("^(?:" + rule.Regexp + ")$")
+		default:
+			return nil, errors.New("transport: URL rule has no pattern")
+		}
+		if e != nil {
+			return nil, fmt.Errorf("transport: invalid URL rule %q: %w", rule.String(), e)
+		}
+		p.rules = append(p.rules, compiled)
+	}
+	for _, opt := range opts {
+		p = opt(p)
+	}
+	return p, nil
+}
+
+// Check returns a *URLPolicyError if the URL is not allowed.
+func (p *URLPolicy) Check(u *url.URL) error {
+	var scheme = strings.ToLower(u.Scheme)
+	var host = strings.ToLower(u.Host)
+	var path = u.EscapedPath()
+	for _, rule := range p.rules {
+		if rule.match(scheme, host, path) {
+			if rule.rule.Action == URLAllow {
+				return nil
+			}
+			return &URLPolicyError{URL: policyErrorURL(u), Rule: rule.rule.String()}
+		}
+	}
+	if p.defaultAction == URLAllow {
+		return nil
+	}
+	return &URLPolicyError{URL: policyErrorURL(u)}
+}
+
+func policyErrorURL(u *url.URL) string {
+	var scrubbed, _ = ScrubURLCredentials(u)
+	scrubbed.Fragment = ""
+	scrubbed.RawFragment = ""
+	return scrubbed.String()
+}
+
+// Blocked returns the number of blocked requests for each destination host.
+func (p *URLPolicy) Blocked() map[string]int64 {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	var blocked = make(map[string]int64, len(p.blocked))
+	for host, count := range p.blocked {
+		blocked[host] = count
+	}
+	return blocked
+}
+
+// EnforceURLPolicy is a decorator that blocks requests to URLs that a
+// URLPolicy does not allow.
+type EnforceURLPolicy struct {
+	wrapped http.RoundTripper
+	policy  *URLPolicy
+}
+
+// RoundTrip checks the request URL and either calls the wrapped transport or
+// returns a *URLPolicyError. Blocked requests are counted, emitted as
+// EventRequestBlocked, and have their body closed.
+func (c *EnforceURLPolicy) RoundTrip(r *http.Request) (*http.Response, error) {
+	var e = c.policy.Check(r.URL)
+	if e == nil {
+		return c.wrapped.RoundTrip(r)
+	}
+	if r.Body != nil {
+		_ = r.Body.Close()
+	}
+	c.policy.lock.Lock()
+	c.policy.blocked[strings.ToLower(r.URL.Host)] = c.policy.blocked[strings.ToLower(r.URL.Host)] + 1
+	c.policy.lock.Unlock()
+	emitEvent(r.Context(), TransportEvent{Type: EventRequestBlocked, Source: urlPolicySource, Request: r, Err: e})
+	return nil, e
+}
+
+// NewEnforceURLPolicy configures a RoundTripper decorator that enforces the
+// policy. Place it first in a chain so that blocked requests are not retried.
+func NewEnforceURLPolicy(policy *URLPolicy) func(http.RoundTripper) http.RoundTripper {
+	return func(wrapped http.RoundTripper) http.RoundTripper {
+		return &EnforceURLPolicy{wrapped: wrapped, policy: policy}
+	}
+}
@@ -0,0 +1,302 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// VaultPKI issues client certificates from the PKI secrets engine of
+// HashiCorp Vault. It is an issuer for NewRenewingCertificateSource, which
+// renews the short lived certificates that Vault issues before they expire.
+type VaultPKI struct {
+	address    string
+	mount      string
+	role       string
+	commonName string
+	altNames   []string
+	uriSANs    []string
+	ttl        time.Duration
+	namespace  string
+	token      func(ctx context.Context) (string, error)
+	client     *http.Client
+}
+
+// VaultPKIOption is a configuration for the VaultPKI.
+type VaultPKIOption func(*VaultPKI) *VaultPKI
+
+// VaultPKIOptionMount sets the path at which the PKI engine is mounted. The
+// default is pki.
+func VaultPKIOptionMount(mount string) VaultPKIOption {
+	return func(v *VaultPKI) *VaultPKI {
+		v.mount = mount
+		return v
+	}
+}
+
+// VaultPKIOptionToken sets the function that provides the Vault token for
+// each request, such as one read from the environment or obtained from an
+// auth method.
+func VaultPKIOptionToken(token func(ctx context.Context) (string, error)) VaultPKIOption {
+	return func(v *VaultPKI) *VaultPKI {
+		v.token = token
+		return v
+	}
+}
+
+// VaultPKIOptionTTL sets the requested lifetime of issued certificates. The
+// default is the TTL of the role.
+func VaultPKIOptionTTL(ttl time.Duration) VaultPKIOption {
+	return func(v *VaultPKI) *VaultPKI {
+		v.ttl = ttl
+		return v
+	}
+}
+
+// VaultPKIOptionAltNames adds DNS or email subject alternative names to
+// issued certificates.
+func VaultPKIOptionAltNames(names ...string) VaultPKIOption {
+	return func(v *VaultPKI) *VaultPKI {
+		v.altNames = append(v.altNames, names...)
+		return v
+	}
+}
+
+// VaultPKIOptionURISANs adds URI subject alternative names, such as SPIFFE
+// IDs, to issued certificates.
+func VaultPKIOptionURISANs(uris ...string) VaultPKIOption {
+	return func(v *VaultPKI) *VaultPKI {
+		v.uriSANs = append(v.uriSANs, uris...)
+		return v
+	}
+}
+
+// VaultPKIOptionNamespace sets the Vault Enterprise namespace of the engine.
+func VaultPKIOptionNamespace(namespace string) VaultPKIOption {
+	return func(v *VaultPKI) *VaultPKI {
+		v.namespace = namespace
+		return v
+	}
+}
+
+// VaultPKIOptionClient sets the client used to reach Vault. The default is
+// a client created by NewClient.
+func VaultPKIOptionClient(client *http.Client) VaultPKIOption {
+	return func(v *VaultPKI) *VaultPKI {
+		v.client = client
+		return v
+	}
+}
+
+// NewVaultPKI creates a VaultPKI that issues certificates for the common
+// name from the role of the Vault server at the address, such as
+// https://vault.example.com:8200.
+func NewVaultPKI(address string, role string, commonName string, opts ...VaultPKIOption) *VaultPKI {
+	var v = &VaultPKI{
+		address:    strings.TrimSuffix(address, "/"),
+		mount:      "pki",
+		role:       role,
+		commonName: commonName,
+		token: func(context.Context) (string, error) {
+			return "", nil
+		},
+	}
+	for _, opt := range opts {
+		v = opt(v)
+	}
+	if v.client == nil {
+		v.client = NewClient()
+	}
+	return v
+}
+
+type vaultIssueRequest struct {
+	CommonName string `json:"common_name"`
+	AltNames   string `json:"alt_names,omitempty"`
+	URISANs    string `json:"uri_sans,omitempty"`
+	TTL        string `json:"ttl,omitempty"`
+}
+
+type vaultIssueResponse struct {
+	Data struct {
+		Certificate string   `json:"certificate"`
+		CAChain     []string `json:"ca_chain"`
+		PrivateKey  string   `json:"private_key"`
+	} `json:"data"`
+	Errors []string `json:"errors"`
+}
+
+// Issue requests a new certificate and private key from Vault.
+func (v *VaultPKI) Issue(ctx context.Context) (*tls.Certificate, error) {
+	var token, e = v.token(ctx)
+	if e != nil {
+		return nil, e
+	}
+	var body = vaultIssueRequest{
+		CommonName: v.commonName,
+		AltNames:   strings.Join(v.altNames, ","),
+		URISANs:    strings.Join(v.uriSANs, ","),
+	}
+	if v.ttl > 0 {
+		body.TTL = v.ttl.String()
+	}
+	var payload []byte
+	if payload, e = json.Marshal(body); e != nil {
+		return nil, e
+	}
+	var url = fmt.Sprintf("%s/v1/%s/issue/%s", v.address, strings.Trim(v.mount, "/"), v.role)
+	var req *http.Request
+	if req, e = http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload)); e != nil {
+		return nil, e
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if v.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.namespace)
+	}
+	var resp *http.Response
+	if resp, e = v.client.Do(req); e != nil {
+		return nil, e
+	}
+	defer resp.Body.Close()
+	var issued vaultIssueResponse
+	var decodeErr = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&issued)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("transport: vault pki issue failed with status %d: %s", resp.StatusCode, strings.Join(issued.Errors, "; "))
+	}
+	if decodeErr != nil {
+		return nil, decodeErr
+	}
+	var chain = issued.Data.Certificate
+	for _, ca := range issued.Data.CAChain {
+		chain = chain + "\n" + ca
+	}
+	var certificate tls.Certificate
+	if certificate, e = tls.X509KeyPair([]byte(chain), []byte(issued.Data.PrivateKey)); e != nil {
+		return nil, e
+	}
+	if certificate.Leaf, e = x509.ParseCertificate(certificate.Certificate[0]); e != nil {
+		return nil, e
+	}
+	return &certificate, nil
+}
+
+// VaultKV is a SecretProvider that reads secrets from the version 2 KV
+// secrets engine of HashiCorp Vault. Names have the form path#field, such as
+// partner/api#key, and select a field of the latest version of the secret.
+type VaultKV struct {
+	address   string
+	mount     string
+	namespace string
+	token     func(ctx context.Context) (string, error)
+	client    *http.Client
+}
+
+// VaultKVOption is a configuration for the VaultKV.
+type VaultKVOption func(*VaultKV) *VaultKV
+
+// VaultKVOptionMount sets the path at which the KV engine is mounted. The
+// default is secret.
+func VaultKVOptionMount(mount string) VaultKVOption {
+	return func(v *VaultKV) *VaultKV {
+		v.mount = mount
+		return v
+	}
+}
+
+// VaultKVOptionToken sets the function that provides the Vault token for
+// each request.
+func VaultKVOptionToken(token func(ctx context.Context) (string, error)) VaultKVOption {
+	return func(v *VaultKV) *VaultKV {
+		v.token = token
+		return v
+	}
+}
+
+// VaultKVOptionNamespace sets the Vault Enterprise namespace of the engine.
+func VaultKVOptionNamespace(namespace string) VaultKVOption {
+	return func(v *VaultKV) *VaultKV {
+		v.namespace = namespace
+		return v
+	}
+}
+
+// VaultKVOptionClient sets the client used to reach Vault. The default is a
+// client created by NewClient.
+func VaultKVOptionClient(client *http.Client) VaultKVOption {
+	return func(v *VaultKV) *VaultKV {
+		v.client = client
+		return v
+	}
+}
+
+// NewVaultKV creates a VaultKV for the Vault server at the address.
+func NewVaultKV(address string, opts ...VaultKVOption) *VaultKV {
+	var v = &VaultKV{
+		address: strings.TrimSuffix(address, "/"),
+		mount:   "secret",
+		token: func(context.Context) (string, error) {
+			return "", nil
+		},
+	}
+	for _, opt := range opts {
+		v = opt(v)
+	}
+	if v.client == nil {
+		v.client = NewClient()
+	}
+	return v
+}
+
+type vaultKVResponse struct {
+	Data struct {
+		Data json.RawMessage `json:"data"`
+	} `json:"data"`
+	Errors []string `json:"errors"`
+}
+
+// Secret reads the field of the secret at the path given by the name.
+func (v *VaultKV) Secret(ctx context.Context, name string) ([]byte, error) {
+	var path, field = splitSecretName(name)
+	if field == "" {
+		return nil, fmt.Errorf("transport: vault secret name %s does not select a field", name)
+	}
+	var token, e = v.token(ctx)
+	if e != nil {
+		return nil, e
+	}
+	var url = fmt.Sprintf("%s/v1/%s/data/%s", v.address, strings.Trim(v.mount, "/"), strings.Trim(path, "/"))
+	var req *http.Request
+	if req, e = http.NewRequestWithContext(ctx, http.MethodGet, url, nil); e != nil {
+		return nil, e
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if v.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.namespace)
+	}
+	var resp *http.Response
+	if resp, e = v.client.Do(req); e != nil {
+		return nil, e
+	}
+	defer resp.Body.Close()
+	var secret vaultKVResponse
+	var decodeErr = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&secret)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("transport: vault kv read of %s failed with status %d: %s", path, resp.StatusCode, strings.Join(secret.Errors, "; "))
+	}
+	if decodeErr != nil {
+		return nil, decodeErr
+	}
+	return secretField(secret.Data.Data, name, field)
+}
@@ -0,0 +1,78 @@
+package transport
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVaultPKIIssue(t *testing.T) {
+	var leaf, key = newTestCertificate(t, "", time.Hour)
+	var der, e = x509.MarshalECPrivateKey(key.(*ecdsa.PrivateKey))
+	require.NoError(t, e)
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/pki-int/issue/service", r.URL.Path)
+		assert.Equal(t, "s.token", r.Header.Get("X-Vault-Token"))
+		assert.Equal(t, "team", r.Header.Get("X-Vault-Namespace"))
+		var body map[string]string
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, map[string]string{"common_name": "service.example.com", "ttl": "1h0m0s"}, body)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{
+				"certificate": string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf.Raw})),
+				"private_key": string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})),
+			},
+		})
+	}))
+	defer server.Close()
+
+	var vault = NewVaultPKI(server.URL, "service", "service.example.com",
+		VaultPKIOptionMount("pki-int"),
+		VaultPKIOptionNamespace("team"),
+		VaultPKIOptionTTL(time.Hour),
+		VaultPKIOptionToken(func(context.Context) (string, error) {
+			return "s.token", nil
+		}),
+	)
+	var source = NewRenewingCertificateSource(vault.Issue)
+	var certificate, issueErr = source.ClientCertificate(context.Background())
+	require.NoError(t, issueErr)
+	assert.Equal(t, leaf.Raw, certificate.Leaf.Raw)
+}
+
+func TestVaultPKIIssueError(t *testing.T) {
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"errors":["permission denied"]}`))
+	}))
+	defer server.Close()
+	var _, e = NewVaultPKI(server.URL, "service", "service.example.com").Issue(context.Background())
+	assert.EqualError(t, e, "transport: vault pki issue failed with status 403: permission denied")
+}
+
+func TestVaultKV(t *testing.T) {
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/kv/data/partner/api", r.URL.Path)
+		assert.Equal(t, "s.token", r.Header.Get("X-Vault-Token"))
+		_, _ = w.Write([]byte(`{"data":{"data":{"key":"secret-value"},"metadata":{"version":3}}}`))
+	}))
+	defer server.Close()
+	var kv = NewVaultKV(server.URL, VaultKVOptionMount("kv"), VaultKVOptionToken(func(context.Context) (string, error) {
+		return "s.token", nil
+	}))
+	var value, e = kv.Secret(context.Background(), "partner/api#key")
+	require.NoError(t, e)
+	assert.Equal(t, "secret-value", string(value))
+
+	_, e = kv.Secret(context.Background(), "partner/api")
+	assert.Error(t, e)
+}
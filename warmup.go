@@ -0,0 +1,77 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+const warmupSource = "warmup"
+
+// Warmup establishes connections to each of the hosts ahead of the first
+// real requests so that they do not pay for DNS lookups, TCP connections,
+// and TLS handshakes, such as right after a deploy. Hosts are base URLs like
+// https://api.example.com. Warmup sends connsPerHost concurrent HEAD requests
+// to the path of each URL, or to / if it has none, and waits for all of them.
+// The connections remain in the pool of the transport for as long as its
+// idle limits allow, so MaxIdleConnsPerHost should be at least connsPerHost.
+// HTTP/2 hosts share a single connection regardless of connsPerHost.
+//
+// The requests skip retries and logging so the transport may be a decorated
+// chain. Responses of any status count as warm connections. The returned
+// error joins the failure of every request that could not be sent.
+func Warmup(ctx context.Context, rt http.RoundTripper, hosts []string, connsPerHost int) error {
+	if connsPerHost < 1 {
+		connsPerHost = 1
+	}
+	ctx = SkipLogging(SkipRetry(ctx))
+	var lock sync.Mutex
+	var errs []error
+	var wg sync.WaitGroup
+	for _, host := range hosts {
+		var u, e = url.Parse(host)
+		if e != nil {
+			lock.Lock()
+			errs = append(errs, fmt.Errorf("transport: warmup of %s: %w", host, e))
+			lock.Unlock()
+			continue
+		}
+		if u.Path == "" {
+			u.Path = "/"
+		}
+		for x := 0; x < connsPerHost; x = x + 1 {
+			wg.Add(1)
+			goLabeled(ctx, warmupSource, u.Host, func(ctx context.Context) {
+				defer wg.Done()
+				var e = warm(ctx, rt, u)
+				if e == nil {
+					return
+				}
+				lock.Lock()
+				defer lock.Unlock()
+				errs = append(errs, fmt.Errorf("transport: warmup of %s: %w", host, e))
+			})
+		}
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+func warm(ctx context.Context, rt http.RoundTripper, u *url.URL) error {
+	var req, e = http.NewRequestWithContext(ctx, http.MethodHead, u.String(), nil)
+	if e != nil {
+		return e
+	}
+	var resp *http.Response
+	resp, e = rt.RoundTrip(req)
+	if e != nil {
+		return e
+	}
+	// Draining the body returns the connection to the pool.
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.Body.Close()
+}
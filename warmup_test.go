@@ -0,0 +1,61 @@
+package transport
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWarmup(t *testing.T) {
+	var arrived sync.WaitGroup
+	arrived.Add(3)
+	var conns atomic.Int32
+	var server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodHead, r.Method)
+		arrived.Done()
+		// Hold every request until all have arrived so that each one needs
+		// its own connection.
+		arrived.Wait()
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	var tr = New(OptionMaxIdleConnsPerHost(3))
+	defer tr.CloseIdleConnections()
+	var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, Warmup(ctx, tr, []string{server.URL}, 3))
+	assert.Equal(t, int32(3), conns.Load())
+
+	arrived.Add(1)
+	var req, _ = http.NewRequest(http.MethodHead, server.URL, nil)
+	var resp, e = tr.RoundTrip(req)
+	require.NoError(t, e)
+	_ = resp.Body.Close()
+	assert.Equal(t, int32(3), conns.Load(), "warm connection was not reused")
+}
+
+func TestWarmupErrors(t *testing.T) {
+	var e = Warmup(context.Background(), RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		assert.True(t, RetrySkipped(r.Context()))
+		assert.True(t, LoggingSkipped(r.Context()))
+		return nil, context.DeadlineExceeded
+	}), []string{"https://a.example.com", "://bad"}, 2)
+	require.Error(t, e)
+	assert.ErrorIs(t, e, context.DeadlineExceeded)
+	assert.Contains(t, e.Error(), "a.example.com")
+	assert.Contains(t, e.Error(), "://bad")
+}